@@ -38,7 +38,11 @@ func main() {
 	adminKey := auth.AdminKey()
 	fmt.Printf("DS2API_ADMIN_KEY loaded: %s...\n", adminKey)
 	_ = adminKey
-	app := server.NewApp()
+	app, err := server.NewApp()
+	if err != nil {
+		config.Logger.Error("startup failed", "error", err)
+		os.Exit(1)
+	}
 	port := strings.TrimSpace(os.Getenv("PORT"))
 	fmt.Printf("PORT from env: '%s'\n", port)
 	if port == "" {
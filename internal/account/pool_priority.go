@@ -0,0 +1,51 @@
+package account
+
+import (
+	"context"
+	"time"
+
+	"ds2api/internal/config"
+)
+
+var lowPriorityPollInterval = 250 * time.Millisecond
+
+// AcquireLowPriority is meant for background work such as batch jobs. It only
+// takes a slot while no interactive request is queued and at least one slot
+// of global headroom remains, polling until a slot frees up or ctx ends.
+func (p *Pool) AcquireLowPriority(ctx context.Context, exclude map[string]bool) (config.Account, bool) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	exclude = normalizeExclude(exclude)
+	ticker := time.NewTicker(lowPriorityPollInterval)
+	defer ticker.Stop()
+	for {
+		if ctx.Err() != nil {
+			return config.Account{}, false
+		}
+		p.mu.Lock()
+		if p.lowPriorityAllowedLocked() {
+			if acc, ok := p.acquireLocked("", exclude); ok {
+				p.mu.Unlock()
+				return acc, true
+			}
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return config.Account{}, false
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pool) lowPriorityAllowedLocked() bool {
+	if len(p.waiters) > 0 {
+		return false
+	}
+	if p.globalMaxInflight > 1 && p.currentInUseLocked() >= p.globalMaxInflight-1 {
+		return false
+	}
+	return true
+}
@@ -294,3 +294,60 @@ func TestPoolAcquireWaitQueueLimitReturnsFalse(t *testing.T) {
 		t.Fatal("timed out waiting for first queued acquire")
 	}
 }
+
+func TestPoolAcquireLowPriorityYieldsToWaiters(t *testing.T) {
+	pool := newSingleAccountPoolForTest(t, "1")
+	held, ok := pool.Acquire("", nil)
+	if !ok {
+		t.Fatal("expected initial acquire to succeed")
+	}
+
+	waiterDone := make(chan string, 1)
+	go func() {
+		acc, ok := pool.AcquireWait(context.Background(), "", nil)
+		if ok {
+			waiterDone <- "interactive"
+			pool.Release(acc.Identifier())
+		}
+	}()
+	waitForWaitingCount(t, pool, 1)
+
+	lowDone := make(chan bool, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go func() {
+		acc, ok := pool.AcquireLowPriority(ctx, nil)
+		if ok {
+			pool.Release(acc.Identifier())
+		}
+		lowDone <- ok
+	}()
+
+	pool.Release(held.Identifier())
+	select {
+	case got := <-waiterDone:
+		if got != "interactive" {
+			t.Fatalf("unexpected waiter result %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("interactive waiter was not served first")
+	}
+	if ok := <-lowDone; !ok {
+		t.Fatal("expected low-priority acquire to succeed once the pool is idle")
+	}
+}
+
+func TestPoolAcquireLowPriorityStopsOnContextCancel(t *testing.T) {
+	pool := newSingleAccountPoolForTest(t, "1")
+	held, ok := pool.Acquire("", nil)
+	if !ok {
+		t.Fatal("expected initial acquire to succeed")
+	}
+	defer pool.Release(held.Identifier())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, ok := pool.AcquireLowPriority(ctx, nil); ok {
+		t.Fatal("expected low-priority acquire to give up when the pool stays busy")
+	}
+}
@@ -0,0 +1,262 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+)
+
+const (
+	messageBatchMaxRequests  = 100000
+	messageBatchCustomIDMax  = 64
+	messageBatchListDefault  = 20
+	messageBatchListMaxLimit = 1000
+)
+
+func (h *Handler) CreateMessageBatch(w http.ResponseWriter, r *http.Request) {
+	bg, ok := h.Auth.(BatchAuthResolver)
+	if !ok || config.IsVercel() {
+		writeClaudeError(w, http.StatusServiceUnavailable, "Message batches are not available on this deployment.")
+		return
+	}
+	a, err := bg.DetermineCaller(r)
+	if err != nil {
+		writeClaudeAuthError(w, err)
+		return
+	}
+	if a == nil || a.CallerID == "" {
+		writeClaudeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	if a.DeepSeekToken != "" {
+		writeClaudeError(w, http.StatusBadRequest, "Message batches run on the managed account pool and require a configured API key.")
+		return
+	}
+
+	var req struct {
+		Requests []json.RawMessage `json:"requests"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeClaudeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	requests, err := parseMessageBatchRequests(req.Requests)
+	if err != nil {
+		writeClaudeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for i, item := range requests {
+		payload, _ := json.Marshal(item.Params)
+		if err := bg.CheckBackground(auth.BackgroundRequest{CallerID: a.CallerID, KeyID: a.KeyID, Path: "/v1/messages", Body: payload}); err != nil {
+			writeClaudeAuthError(w, fmt.Errorf("requests.%d: %w", i, err))
+			return
+		}
+	}
+	store := h.getMessageBatchStore()
+	rec, err := store.create(a.CallerID, a.KeyID, requests)
+	if err != nil {
+		writeClaudeError(w, http.StatusInternalServerError, "failed to store message batch.")
+		return
+	}
+	store.Notify()
+	writeJSON(w, http.StatusOK, rec.apiObject())
+}
+
+func parseMessageBatchRequests(raw []json.RawMessage) ([]messageBatchRequest, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("requests: must contain at least one request.")
+	}
+	if len(raw) > messageBatchMaxRequests {
+		return nil, fmt.Errorf("requests: at most %d requests are allowed per batch.", messageBatchMaxRequests)
+	}
+	out := make([]messageBatchRequest, 0, len(raw))
+	seen := map[string]bool{}
+	for i, item := range raw {
+		var parsed messageBatchRequest
+		if err := json.Unmarshal(item, &parsed); err != nil {
+			return nil, fmt.Errorf("requests.%d: must be an object with 'custom_id' and 'params'.", i)
+		}
+		if !validMessageBatchCustomID(parsed.CustomID) {
+			return nil, fmt.Errorf("requests.%d.custom_id: must be 1-%d characters of letters, digits, '_' or '-'.", i, messageBatchCustomIDMax)
+		}
+		if seen[parsed.CustomID] {
+			return nil, fmt.Errorf("requests.%d.custom_id: '%s' is used more than once.", i, parsed.CustomID)
+		}
+		if parsed.Params == nil {
+			return nil, fmt.Errorf("requests.%d.params: must be a JSON object.", i)
+		}
+		seen[parsed.CustomID] = true
+		out = append(out, parsed)
+	}
+	return out, nil
+}
+
+func validMessageBatchCustomID(id string) bool {
+	if id == "" || len(id) > messageBatchCustomIDMax {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *Handler) GetMessageBatch(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.messageBatchCaller(w, r)
+	if !ok {
+		return
+	}
+	rec, err := h.getMessageBatchStore().Get(owner, strings.TrimSpace(chi.URLParam(r, "batch_id")))
+	if err != nil {
+		writeClaudeError(w, http.StatusNotFound, "Message batch not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, rec.apiObject())
+}
+
+// ListMessageBatches returns batches newest first. after_id pages toward
+// older batches and before_id toward newer ones.
+func (h *Handler) ListMessageBatches(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.messageBatchCaller(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	limit := messageBatchListDefault
+	if raw := strings.TrimSpace(q.Get("limit")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > messageBatchListMaxLimit {
+			writeClaudeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d.", messageBatchListMaxLimit))
+			return
+		}
+		limit = n
+	}
+	items := h.getMessageBatchStore().List(owner)
+	newest := make([]messageBatchRecord, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		newest = append(newest, items[i])
+	}
+	start, end := 0, len(newest)
+	if after := strings.TrimSpace(q.Get("after_id")); after != "" {
+		start = end
+		for i, item := range newest {
+			if item.ID == after {
+				start = i + 1
+				break
+			}
+		}
+	}
+	if before := strings.TrimSpace(q.Get("before_id")); before != "" {
+		end = start
+		for i := start; i < len(newest); i++ {
+			if newest[i].ID == before {
+				end = i
+				break
+			}
+		}
+	}
+	hasMore := end-start > limit
+	if hasMore {
+		if q.Get("before_id") != "" && q.Get("after_id") == "" {
+			start = end - limit
+		} else {
+			end = start + limit
+		}
+	}
+	data := make([]any, 0, end-start)
+	for _, item := range newest[start:end] {
+		data = append(data, item.apiObject())
+	}
+	out := map[string]any{"data": data, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if end > start {
+		out["first_id"] = newest[start].ID
+		out["last_id"] = newest[end-1].ID
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// CancelMessageBatch moves a batch to canceling; the runner stops after the
+// request in flight and marks every request that never ran as canceled.
+func (h *Handler) CancelMessageBatch(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.messageBatchCaller(w, r)
+	if !ok {
+		return
+	}
+	id := strings.TrimSpace(chi.URLParam(r, "batch_id"))
+	store := h.getMessageBatchStore()
+	if _, err := store.Get(owner, id); err != nil {
+		writeClaudeError(w, http.StatusNotFound, "Message batch not found.")
+		return
+	}
+	rec, err := store.Update(id, func(rec *messageBatchRecord) {
+		if rec.Status == messageBatchInProgress {
+			rec.Status = messageBatchCanceling
+			rec.CancelInitiatedAt = time.Now().Unix()
+		}
+	})
+	if err != nil {
+		writeClaudeError(w, http.StatusInternalServerError, "failed to update message batch.")
+		return
+	}
+	store.Cancel(id)
+	store.Notify()
+	writeJSON(w, http.StatusOK, rec.apiObject())
+}
+
+// MessageBatchResults streams the JSONL results of an ended batch.
+func (h *Handler) MessageBatchResults(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.messageBatchCaller(w, r)
+	if !ok {
+		return
+	}
+	id := strings.TrimSpace(chi.URLParam(r, "batch_id"))
+	store := h.getMessageBatchStore()
+	rec, err := store.Get(owner, id)
+	if err != nil {
+		writeClaudeError(w, http.StatusNotFound, "Message batch not found.")
+		return
+	}
+	if rec.Status != messageBatchEnded {
+		writeClaudeError(w, http.StatusConflict, fmt.Sprintf("Message batch '%s' has not ended yet; results are not available.", id))
+		return
+	}
+	f, err := os.Open(store.resultsPath(id))
+	if err != nil {
+		writeClaudeError(w, http.StatusNotFound, "Message batch results not found.")
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/x-jsonl")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, f)
+}
+
+func (h *Handler) messageBatchCaller(w http.ResponseWriter, r *http.Request) (string, bool) {
+	bg, ok := h.Auth.(BatchAuthResolver)
+	if !ok {
+		writeClaudeError(w, http.StatusServiceUnavailable, "Message batches are not available on this deployment.")
+		return "", false
+	}
+	a, err := bg.DetermineCaller(r)
+	if err != nil {
+		writeClaudeAuthError(w, err)
+		return "", false
+	}
+	if a == nil || a.CallerID == "" {
+		writeClaudeError(w, http.StatusUnauthorized, "unauthorized")
+		return "", false
+	}
+	return a.CallerID, true
+}
@@ -0,0 +1,312 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/auth"
+)
+
+type messageBatchAuthStub struct {
+	acquired    int
+	deniedModel string
+}
+
+// check refuses requests for deniedModel the way a model-scoped key would.
+func (s *messageBatchAuthStub) check(job auth.BackgroundRequest) error {
+	var params struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(job.Body, &params)
+	if s.deniedModel != "" && params.Model == s.deniedModel {
+		return auth.ErrForbidden
+	}
+	return nil
+}
+
+func (s *messageBatchAuthStub) managed() *auth.RequestAuth {
+	return &auth.RequestAuth{UseConfigToken: true, CallerID: "caller:batch", DeepSeekToken: "account-token", TriedAccounts: map[string]bool{}}
+}
+
+func (s *messageBatchAuthStub) Determine(_ *http.Request) (*auth.RequestAuth, error) {
+	return s.managed(), nil
+}
+
+func (s *messageBatchAuthStub) DetermineCaller(_ *http.Request) (*auth.RequestAuth, error) {
+	return &auth.RequestAuth{CallerID: "caller:batch", TriedAccounts: map[string]bool{}}, nil
+}
+
+func (s *messageBatchAuthStub) Release(_ *auth.RequestAuth) {}
+
+func (s *messageBatchAuthStub) CheckBackground(job auth.BackgroundRequest) error {
+	return s.check(job)
+}
+
+func (s *messageBatchAuthStub) ServeBackground(_ *auth.RequestAuth, w http.ResponseWriter, req *http.Request, serve http.HandlerFunc) {
+	serve(w, req)
+}
+
+func (s *messageBatchAuthStub) AcquireBackground(_ context.Context, job auth.BackgroundRequest) (*auth.RequestAuth, error) {
+	s.acquired++
+	if err := s.check(job); err != nil {
+		return nil, err
+	}
+	a := s.managed()
+	a.CallerID = job.CallerID
+	return a, nil
+}
+
+type messageBatchDSStub struct {
+	streamStatusClaudeDSStub
+	calls int
+}
+
+func (s *messageBatchDSStub) CallCompletion(ctx context.Context, a *auth.RequestAuth, payload map[string]any, pow string, attempts int) (*http.Response, error) {
+	s.calls++
+	return s.streamStatusClaudeDSStub.CallCompletion(ctx, a, payload, pow, attempts)
+}
+
+func newMessageBatchTestHandler(t *testing.T) (*Handler, *messageBatchDSStub, chi.Router) {
+	t.Helper()
+	ds := &messageBatchDSStub{}
+	h := &Handler{Store: streamStatusClaudeStoreStub{}, Auth: &messageBatchAuthStub{}, DS: ds, DataDir: t.TempDir()}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	return h, ds, r
+}
+
+func doMessageBatchRequest(t *testing.T, r chi.Router, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("x-api-key", "managed-key")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeMessageBatch(t *testing.T, rec *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode failed: %v body=%s", err, rec.Body.String())
+	}
+	return out
+}
+
+func drainMessageBatches(t *testing.T, h *Handler) {
+	t.Helper()
+	for i := 0; i < 10; i++ {
+		rec, ok := h.getMessageBatchStore().NextRunnable()
+		if !ok {
+			return
+		}
+		h.runMessageBatch(context.Background(), rec)
+	}
+	t.Fatal("message batches did not settle")
+}
+
+func messageBatchResultLines(t *testing.T, r chi.Router, id string) map[string]map[string]any {
+	t.Helper()
+	rec := doMessageBatchRequest(t, r, http.MethodGet, "/v1/messages/batches/"+id+"/results", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("results failed: %d %s", rec.Code, rec.Body.String())
+	}
+	out := map[string]map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n") {
+		var item struct {
+			CustomID string         `json:"custom_id"`
+			Result   map[string]any `json:"result"`
+		}
+		if err := json.Unmarshal([]byte(line), &item); err != nil {
+			t.Fatalf("bad result line %q: %v", line, err)
+		}
+		out[item.CustomID] = item.Result
+	}
+	return out
+}
+
+func TestMessageBatchRunsRequestsAndServesResults(t *testing.T) {
+	h, ds, r := newMessageBatchTestHandler(t)
+	body := `{"requests":[
+		{"custom_id":"ok-1","params":{"model":"claude-sonnet-4-5","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"hi"}]}},
+		{"custom_id":"bad-1","params":{"model":"claude-sonnet-4-5","max_tokens":64}}
+	]}`
+	create := doMessageBatchRequest(t, r, http.MethodPost, "/anthropic/v1/messages/batches", body)
+	if create.Code != http.StatusOK {
+		t.Fatalf("create failed: %d %s", create.Code, create.Body.String())
+	}
+	batch := decodeMessageBatch(t, create)
+	if batch["type"] != "message_batch" || batch["processing_status"] != "in_progress" || batch["results_url"] != nil {
+		t.Fatalf("unexpected batch: %#v", batch)
+	}
+	id := batch["id"].(string)
+	if !strings.HasPrefix(id, "msgbatch_") {
+		t.Fatalf("unexpected batch id: %s", id)
+	}
+	if early := doMessageBatchRequest(t, r, http.MethodGet, "/v1/messages/batches/"+id+"/results", ""); early.Code != http.StatusConflict {
+		t.Fatalf("expected 409 before the batch ends, got %d", early.Code)
+	}
+
+	drainMessageBatches(t, h)
+
+	got := decodeMessageBatch(t, doMessageBatchRequest(t, r, http.MethodGet, "/v1/messages/batches/"+id, ""))
+	counts, _ := got["request_counts"].(map[string]any)
+	if got["processing_status"] != "ended" || got["ended_at"] == nil || got["results_url"] != "/v1/messages/batches/"+id+"/results" {
+		t.Fatalf("unexpected ended batch: %#v", got)
+	}
+	if counts["processing"] != float64(0) || counts["succeeded"] != float64(1) || counts["errored"] != float64(1) {
+		t.Fatalf("unexpected request counts: %#v", counts)
+	}
+	if h.Auth.(*messageBatchAuthStub).acquired != 2 || ds.calls != 1 {
+		t.Fatalf("expected background acquisition per request, acquired=%d calls=%d", h.Auth.(*messageBatchAuthStub).acquired, ds.calls)
+	}
+
+	results := messageBatchResultLines(t, r, id)
+	ok := results["ok-1"]
+	msg, _ := ok["message"].(map[string]any)
+	if ok["type"] != "succeeded" || msg["type"] != "message" || msg["role"] != "assistant" {
+		t.Fatalf("unexpected succeeded result: %#v", ok)
+	}
+	bad := results["bad-1"]
+	errEnvelope, _ := bad["error"].(map[string]any)
+	inner, _ := errEnvelope["error"].(map[string]any)
+	if bad["type"] != "errored" || errEnvelope["type"] != "error" || !strings.Contains(inner["message"].(string), "messages") {
+		t.Fatalf("unexpected errored result: %#v", bad)
+	}
+}
+
+func TestMessageBatchCancelMarksPendingRequestsCanceled(t *testing.T) {
+	h, ds, r := newMessageBatchTestHandler(t)
+	first := decodeMessageBatch(t, doMessageBatchRequest(t, r, http.MethodPost, "/v1/messages/batches", `{"requests":[{"custom_id":"a","params":{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"x"}]}},{"custom_id":"b","params":{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"y"}]}}]}`))
+	id := first["id"].(string)
+
+	cancel := decodeMessageBatch(t, doMessageBatchRequest(t, r, http.MethodPost, "/v1/messages/batches/"+id+"/cancel", ""))
+	if cancel["processing_status"] != "canceling" || cancel["cancel_initiated_at"] == nil {
+		t.Fatalf("unexpected cancel response: %#v", cancel)
+	}
+	drainMessageBatches(t, h)
+	if ds.calls != 0 {
+		t.Fatalf("expected no requests to run after cancel, got %d", ds.calls)
+	}
+	got := decodeMessageBatch(t, doMessageBatchRequest(t, r, http.MethodGet, "/v1/messages/batches/"+id, ""))
+	counts, _ := got["request_counts"].(map[string]any)
+	if got["processing_status"] != "ended" || counts["canceled"] != float64(2) {
+		t.Fatalf("unexpected canceled batch: %#v", got)
+	}
+	for customID, result := range messageBatchResultLines(t, r, id) {
+		if result["type"] != "canceled" {
+			t.Fatalf("expected %s to be canceled, got %#v", customID, result)
+		}
+	}
+}
+
+func TestMessageBatchResumesAndExpires(t *testing.T) {
+	h, ds, r := newMessageBatchTestHandler(t)
+	params := `"params":{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"x"}]}`
+	created := decodeMessageBatch(t, doMessageBatchRequest(t, r, http.MethodPost, "/v1/messages/batches", `{"requests":[{"custom_id":"a",`+params+`},{"custom_id":"b",`+params+`}]}`))
+	id := created["id"].(string)
+
+	// Simulate a previous process that finished "a" and left a torn write
+	// behind before stopping.
+	store := h.getMessageBatchStore()
+	prior := `{"custom_id":"a","result":{"type":"succeeded","message":{}}}` + "\n" + `{"custom_id":"b","res`
+	if err := os.WriteFile(store.resultsPath(id), []byte(prior), 0o644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	restarted := &Handler{Store: h.Store, Auth: h.Auth, DS: ds, DataDir: h.DataDir}
+	drainMessageBatches(t, restarted)
+	if ds.calls != 1 {
+		t.Fatalf("expected only b to run after resume, got %d calls", ds.calls)
+	}
+	if results := messageBatchResultLines(t, r, id); len(results) != 2 || results["b"]["type"] != "succeeded" {
+		t.Fatalf("unexpected resumed results: %#v", results)
+	}
+
+	expiring := decodeMessageBatch(t, doMessageBatchRequest(t, r, http.MethodPost, "/v1/messages/batches", `{"requests":[{"custom_id":"late",`+params+`}]}`))
+	if _, err := store.Update(expiring["id"].(string), func(rec *messageBatchRecord) {
+		rec.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+		rec.CreatedAt++ // keep list order stable within the same second
+	}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	drainMessageBatches(t, h)
+	if results := messageBatchResultLines(t, r, expiring["id"].(string)); results["late"]["type"] != "expired" {
+		t.Fatalf("expected expired result, got %#v", results)
+	}
+
+	list := decodeMessageBatch(t, doMessageBatchRequest(t, r, http.MethodGet, "/v1/messages/batches?limit=1", ""))
+	data, _ := list["data"].([]any)
+	if len(data) != 1 || list["has_more"] != true || list["first_id"] != expiring["id"] {
+		t.Fatalf("unexpected list page: %#v", list)
+	}
+	next := decodeMessageBatch(t, doMessageBatchRequest(t, r, http.MethodGet, "/v1/messages/batches?limit=1&after_id="+expiring["id"].(string), ""))
+	if next["first_id"] != id || next["has_more"] != false {
+		t.Fatalf("unexpected second page: %#v", next)
+	}
+}
+
+func TestMessageBatchCreateValidation(t *testing.T) {
+	h := &Handler{Store: streamStatusClaudeStoreStub{}, Auth: streamStatusClaudeAuthStub{}, DataDir: t.TempDir()}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	if rec := doMessageBatchRequest(t, r, http.MethodPost, "/v1/messages/batches", `{"requests":[]}`); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without batch auth support, got %d", rec.Code)
+	}
+
+	_, _, managed := newMessageBatchTestHandler(t)
+	cases := map[string]string{
+		`{"requests":[]}`: "at least one",
+		`{"requests":[{"custom_id":"a","params":{}},{"custom_id":"a","params":{}}]}`: "more than once",
+		`{"requests":[{"custom_id":"bad id","params":{}}]}`:                          "custom_id",
+		`{"requests":[{"custom_id":"a"}]}`:                                           "params",
+	}
+	for body, want := range cases {
+		rec := doMessageBatchRequest(t, managed, http.MethodPost, "/v1/messages/batches", body)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("body %s: expected 400 mentioning %q, got %d %s", body, want, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestMessageBatchChecksParamsModelScope(t *testing.T) {
+	h, ds, r := newMessageBatchTestHandler(t)
+	stub := h.Auth.(*messageBatchAuthStub)
+	stub.deniedModel = "claude-opus-4-1"
+	denied := `{"requests":[
+		{"custom_id":"a","params":{"model":"claude-sonnet-4-5","max_tokens":64,"messages":[{"role":"user","content":"hi"}]}},
+		{"custom_id":"b","params":{"model":"claude-opus-4-1","max_tokens":64,"messages":[{"role":"user","content":"hi"}]}}
+	]}`
+	rec := doMessageBatchRequest(t, r, http.MethodPost, "/v1/messages/batches", denied)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "requests.1") {
+		t.Fatalf("expected 403 naming the out-of-scope request, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// The scope may narrow after creation: the line is then refused at run time.
+	stub.deniedModel = ""
+	create := doMessageBatchRequest(t, r, http.MethodPost, "/v1/messages/batches", denied)
+	if create.Code != http.StatusOK {
+		t.Fatalf("create failed: %d %s", create.Code, create.Body.String())
+	}
+	id := decodeMessageBatch(t, create)["id"].(string)
+	stub.deniedModel = "claude-opus-4-1"
+	drainMessageBatches(t, h)
+
+	results := messageBatchResultLines(t, r, id)
+	if results["a"]["type"] != "succeeded" || results["b"]["type"] != "errored" || ds.calls != 1 {
+		t.Fatalf("expected only the in-scope request to run, calls=%d results=%#v", ds.calls, results)
+	}
+	errEnvelope, _ := results["b"]["error"].(map[string]any)
+	inner, _ := errEnvelope["error"].(map[string]any)
+	if inner["code"] != "forbidden" {
+		t.Fatalf("expected a permission error for the refused request, got %#v", results["b"])
+	}
+}
@@ -0,0 +1,201 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/batchstore"
+	"ds2api/internal/config"
+)
+
+// RunMessageBatches processes queued message batches one at a time until ctx
+// ends. Batches left in progress by a previous process are resumed first.
+func (h *Handler) RunMessageBatches(ctx context.Context) {
+	h.getMessageBatchStore().Run(ctx, h.runMessageBatch)
+}
+
+func (h *Handler) runMessageBatch(ctx context.Context, rec messageBatchRecord) {
+	store := h.getMessageBatchStore()
+	runCtx, finish := store.Start(ctx, rec.ID)
+	defer finish()
+
+	requests, err := store.loadRequests(rec.ID)
+	if err != nil {
+		config.Logger.Warn("[message_batch] read requests failed", "batch_id", rec.ID, "error", err)
+		_, _ = store.Update(rec.ID, func(r *messageBatchRecord) {
+			r.Errored += r.processing()
+			r.Status = messageBatchEnded
+			r.EndedAt = time.Now().Unix()
+		})
+		return
+	}
+	done, err := store.loadResults(rec.ID)
+	if err != nil {
+		config.Logger.Warn("[message_batch] read results failed", "batch_id", rec.ID, "error", err)
+		return
+	}
+	rec, err = store.Update(rec.ID, func(r *messageBatchRecord) {
+		r.Succeeded, r.Errored, r.Canceled, r.Expired = 0, 0, 0, 0
+		for _, kind := range done {
+			r.count(kind)
+		}
+	})
+	if err != nil {
+		return
+	}
+	if rec.Status == messageBatchInProgress {
+		h.processMessageBatch(runCtx, rec, requests, done)
+	}
+	if ctx.Err() != nil {
+		// Shutting down: leave the batch in progress for the next start.
+		return
+	}
+	h.endMessageBatch(rec.ID, requests, done)
+}
+
+func (h *Handler) processMessageBatch(ctx context.Context, rec messageBatchRecord, requests []messageBatchRequest, done map[string]string) {
+	store := h.getMessageBatchStore()
+	for _, item := range requests {
+		if done[item.CustomID] != "" {
+			continue
+		}
+		current, err := store.Load(rec.ID)
+		if err != nil || current.Status != messageBatchInProgress || time.Now().Unix() >= current.ExpiresAt || ctx.Err() != nil {
+			return
+		}
+		result, ok := h.executeMessageBatchRequest(ctx, rec, item)
+		if !ok {
+			return
+		}
+		if !h.recordMessageBatchResult(rec.ID, item.CustomID, result, done) {
+			return
+		}
+	}
+}
+
+// endMessageBatch marks every request that never ran as canceled or expired
+// and moves the batch to ended.
+func (h *Handler) endMessageBatch(id string, requests []messageBatchRequest, done map[string]string) {
+	store := h.getMessageBatchStore()
+	rec, err := store.Load(id)
+	if err != nil || rec.Status == messageBatchEnded {
+		return
+	}
+	kind := messageBatchResultExpired
+	if rec.Status == messageBatchCanceling {
+		kind = messageBatchResultCanceled
+	}
+	for _, item := range requests {
+		if done[item.CustomID] != "" {
+			continue
+		}
+		if !h.recordMessageBatchResult(id, item.CustomID, map[string]any{"type": kind}, done) {
+			return
+		}
+	}
+	_, err = store.Update(id, func(r *messageBatchRecord) {
+		r.Status = messageBatchEnded
+		r.EndedAt = time.Now().Unix()
+	})
+	if err != nil {
+		config.Logger.Warn("[message_batch] finalize failed", "batch_id", id, "error", err)
+	}
+}
+
+func (h *Handler) recordMessageBatchResult(id, customID string, result map[string]any, done map[string]string) bool {
+	store := h.getMessageBatchStore()
+	kind, _ := result["type"].(string)
+	if err := store.appendResult(id, customID, result); err != nil {
+		config.Logger.Warn("[message_batch] write result failed", "batch_id", id, "error", err)
+		return false
+	}
+	done[customID] = kind
+	_, _ = store.Update(id, func(r *messageBatchRecord) {
+		r.count(kind)
+	})
+	return true
+}
+
+func (b *messageBatchRecord) count(kind string) {
+	switch kind {
+	case messageBatchResultSucceeded:
+		b.Succeeded++
+	case messageBatchResultErrored:
+		b.Errored++
+	case messageBatchResultCanceled:
+		b.Canceled++
+	case messageBatchResultExpired:
+		b.Expired++
+	}
+}
+
+// executeMessageBatchRequest runs one request through Messages on a pooled
+// account acquired at low priority, under the checks of the key that created
+// the batch. ok is false when the batch was canceled
+// or the server is stopping, in which case the request is left for later.
+func (h *Handler) executeMessageBatchRequest(ctx context.Context, rec messageBatchRecord, item messageBatchRequest) (map[string]any, bool) {
+	bg, ok := h.Auth.(BatchAuthResolver)
+	if !ok {
+		return messageBatchError("api_error", "Batch execution is not available on this deployment."), true
+	}
+	params := cloneMap(item.Params)
+	delete(params, "stream")
+	payload, _ := json.Marshal(params)
+	rw := batchstore.NewResponse()
+	a, err := bg.AcquireBackground(ctx, auth.BackgroundRequest{CallerID: rec.Owner, KeyID: rec.KeyID, Path: "/v1/messages", Body: payload})
+	switch {
+	case ctx.Err() != nil:
+		return nil, false
+	case err != nil:
+		// The request gets the error Messages would have sent the key.
+		writeClaudeAuthError(rw, err)
+	default:
+		defer h.Auth.Release(a)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/v1/messages", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		exec := &Handler{Store: h.Store, Auth: messageBatchAuth{a: a}, DS: h.DS, DataDir: h.DataDir, PromptCache: h.PromptCache}
+		bg.ServeBackground(a, rw, req, exec.Messages)
+		if ctx.Err() != nil {
+			return nil, false
+		}
+	}
+	var body map[string]any
+	_ = json.Unmarshal(rw.Body.Bytes(), &body)
+	if rw.Status >= 200 && rw.Status < 300 && body != nil {
+		return map[string]any{"type": messageBatchResultSucceeded, "message": body}, true
+	}
+	if errBody, _ := body["error"].(map[string]any); errBody != nil {
+		return map[string]any{
+			"type":  messageBatchResultErrored,
+			"error": map[string]any{"type": "error", "error": errBody},
+		}, true
+	}
+	return messageBatchError("api_error", strings.TrimSpace(rw.Body.String())), true
+}
+
+func messageBatchError(errType, message string) map[string]any {
+	return map[string]any{
+		"type": messageBatchResultErrored,
+		"error": map[string]any{
+			"type":  "error",
+			"error": map[string]any{"type": errType, "message": message},
+		},
+	}
+}
+
+// messageBatchAuth hands the runner's pre-acquired account to Messages.
+// Release is a no-op because the runner owns the lease.
+type messageBatchAuth struct {
+	a *auth.RequestAuth
+}
+
+func (b messageBatchAuth) Determine(_ *http.Request) (*auth.RequestAuth, error) {
+	return b.a, nil
+}
+
+func (b messageBatchAuth) Release(_ *auth.RequestAuth) {}
@@ -0,0 +1,215 @@
+package claude
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ds2api/internal/batchstore"
+	"ds2api/internal/config"
+)
+
+const (
+	messageBatchInProgress = "in_progress"
+	messageBatchCanceling  = "canceling"
+	messageBatchEnded      = "ended"
+
+	messageBatchResultSucceeded = "succeeded"
+	messageBatchResultErrored   = "errored"
+	messageBatchResultCanceled  = "canceled"
+	messageBatchResultExpired   = "expired"
+
+	messageBatchIDPrefix = "msgbatch_"
+	messageBatchWindow   = 24 * time.Hour
+)
+
+type messageBatchRequest struct {
+	CustomID string         `json:"custom_id"`
+	Params   map[string]any `json:"params"`
+}
+
+// messageBatchRecord is the persisted state of a message batch. Counts are
+// written after every request so a restarted runner can pick up where it
+// stopped.
+type messageBatchRecord struct {
+	ID                string `json:"id"`
+	Owner             string `json:"owner"`
+	KeyID             string `json:"key_id,omitempty"`
+	Status            string `json:"processing_status"`
+	Total             int    `json:"total"`
+	Succeeded         int    `json:"succeeded"`
+	Errored           int    `json:"errored"`
+	Canceled          int    `json:"canceled"`
+	Expired           int    `json:"expired"`
+	CreatedAt         int64  `json:"created_at"`
+	ExpiresAt         int64  `json:"expires_at"`
+	CancelInitiatedAt int64  `json:"cancel_initiated_at,omitempty"`
+	EndedAt           int64  `json:"ended_at,omitempty"`
+}
+
+func (b messageBatchRecord) apiObject() map[string]any {
+	var resultsURL any
+	if b.Status == messageBatchEnded {
+		resultsURL = "/v1/messages/batches/" + b.ID + "/results"
+	}
+	return map[string]any{
+		"id":                b.ID,
+		"type":              "message_batch",
+		"processing_status": b.Status,
+		"request_counts": map[string]any{
+			"processing": b.processing(),
+			"succeeded":  b.Succeeded,
+			"errored":    b.Errored,
+			"canceled":   b.Canceled,
+			"expired":    b.Expired,
+		},
+		"created_at":          formatBatchTime(b.CreatedAt),
+		"expires_at":          formatBatchTime(b.ExpiresAt),
+		"cancel_initiated_at": formatBatchTime(b.CancelInitiatedAt),
+		"ended_at":            formatBatchTime(b.EndedAt),
+		"archived_at":         nil,
+		"results_url":         resultsURL,
+	}
+}
+
+func (b messageBatchRecord) processing() int {
+	n := b.Total - b.Succeeded - b.Errored - b.Canceled - b.Expired
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+func (b messageBatchRecord) BatchID() string {
+	return b.ID
+}
+
+func (b messageBatchRecord) BatchOwner() string {
+	return b.Owner
+}
+
+func (b messageBatchRecord) BatchCreatedAt() int64 {
+	return b.CreatedAt
+}
+
+func (b messageBatchRecord) Runnable() bool {
+	return b.Status != messageBatchEnded
+}
+
+func formatBatchTime(unix int64) any {
+	if unix == 0 {
+		return nil
+	}
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+// messageBatchStore persists batches as <dir>/<id>.json with the submitted
+// requests in <id>.requests.jsonl. Results are appended to
+// <id>.results.jsonl as each request finishes.
+type messageBatchStore struct {
+	*batchstore.Store[messageBatchRecord]
+}
+
+func newMessageBatchStore(dir string) *messageBatchStore {
+	return &messageBatchStore{batchstore.New[messageBatchRecord](dir, messageBatchIDPrefix)}
+}
+
+func (s *messageBatchStore) requestsPath(id string) string {
+	return s.Path(id, ".requests.jsonl")
+}
+
+func (s *messageBatchStore) resultsPath(id string) string {
+	return s.Path(id, ".results.jsonl")
+}
+
+func (s *messageBatchStore) create(owner, keyID string, requests []messageBatchRequest) (messageBatchRecord, error) {
+	if err := s.MkdirAll(); err != nil {
+		return messageBatchRecord{}, err
+	}
+	now := time.Now()
+	rec := messageBatchRecord{
+		ID:        s.NewID(),
+		Owner:     owner,
+		KeyID:     keyID,
+		Status:    messageBatchInProgress,
+		Total:     len(requests),
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(messageBatchWindow).Unix(),
+	}
+	buf := make([]byte, 0)
+	for _, item := range requests {
+		b, err := json.Marshal(item)
+		if err != nil {
+			return messageBatchRecord{}, err
+		}
+		buf = append(append(buf, b...), '\n')
+	}
+	if err := os.WriteFile(s.requestsPath(rec.ID), buf, 0o644); err != nil {
+		return messageBatchRecord{}, err
+	}
+	if err := s.Create(rec); err != nil {
+		_ = os.Remove(s.requestsPath(rec.ID))
+		return messageBatchRecord{}, err
+	}
+	return rec, nil
+}
+
+func (s *messageBatchStore) loadRequests(id string) ([]messageBatchRequest, error) {
+	f, err := os.Open(s.requestsPath(id))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []messageBatchRequest
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var item messageBatchRequest
+		if err := dec.Decode(&item); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+// loadResults reads the result type recorded for each custom_id so far.
+func (s *messageBatchStore) loadResults(id string) (map[string]string, error) {
+	lines, err := batchstore.ReadJSONLines(s.resultsPath(id))
+	if err != nil {
+		return nil, err
+	}
+	done := map[string]string{}
+	for _, line := range lines {
+		var item struct {
+			CustomID string `json:"custom_id"`
+			Result   struct {
+				Type string `json:"type"`
+			} `json:"result"`
+		}
+		if json.Unmarshal(line, &item) == nil {
+			done[item.CustomID] = item.Result.Type
+		}
+	}
+	return done, nil
+}
+
+func (s *messageBatchStore) appendResult(id, customID string, result map[string]any) error {
+	return batchstore.AppendJSONLine(s.resultsPath(id), map[string]any{"custom_id": customID, "result": result})
+}
+
+func (h *Handler) dataDir() string {
+	if strings.TrimSpace(h.DataDir) != "" {
+		return h.DataDir
+	}
+	return config.DataDir()
+}
+
+func (h *Handler) getMessageBatchStore() *messageBatchStore {
+	h.batchesOnce.Do(func() {
+		h.batches = newMessageBatchStore(filepath.Join(h.dataDir(), "message_batches"))
+	})
+	return h.batches
+}
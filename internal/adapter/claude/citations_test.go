@@ -0,0 +1,50 @@
+package claude
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ds2api/internal/promptcache"
+	"ds2api/internal/util"
+)
+
+func TestHandleClaudeStreamRealtimeEmitsCitationsDeltas(t *testing.T) {
+	h := &Handler{}
+	resp := makeClaudeSSEHTTPResponse(
+		`data: {"p":"response/search_results","v":[{"url":"https://go.dev","title":"Go","snippet":"The Go language","cite_index":1}]}`,
+		`data: {"p":"response/content","v":"Go is simple[citation:1]. More"}`,
+		`data: {"p":"response/content","v":" text."}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
+
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, false, true, nil, util.DefaultToolChoicePolicy(), util.ToolCallPolicy{}, util.StopPolicy{}, promptcache.Usage{})
+
+	frames := parseClaudeFrames(t, rec.Body.String())
+	if starts := findClaudeFrames(frames, "content_block_start"); len(starts) != 2 {
+		t.Fatalf("expected the cited passage to close its block, got %d starts body=%s", len(starts), rec.Body.String())
+	}
+	var text strings.Builder
+	var citation map[string]any
+	for _, f := range findClaudeFrames(frames, "content_block_delta") {
+		delta, _ := f.Payload["delta"].(map[string]any)
+		switch delta["type"] {
+		case "text_delta":
+			text.WriteString(asString(delta["text"]))
+		case "citations_delta":
+			citation, _ = delta["citation"].(map[string]any)
+			if f.Payload["index"] != float64(0) {
+				t.Fatalf("citation should belong to the first block, got %#v", f.Payload)
+			}
+		}
+	}
+	if text.String() != "Go is simple[1]. More text." {
+		t.Fatalf("unexpected text %q", text.String())
+	}
+	if citation["type"] != "web_search_result_location" || citation["url"] != "https://go.dev" || citation["cited_text"] != "The Go language" {
+		t.Fatalf("unexpected citation %#v", citation)
+	}
+}
@@ -14,6 +14,16 @@ type AuthResolver interface {
 	Release(a *auth.RequestAuth)
 }
 
+// BatchAuthResolver is implemented by resolvers that can identify a caller
+// without leasing an account and bind a pooled account outside a request;
+// message batches require it.
+type BatchAuthResolver interface {
+	DetermineCaller(req *http.Request) (*auth.RequestAuth, error)
+	CheckBackground(job auth.BackgroundRequest) error
+	AcquireBackground(ctx context.Context, job auth.BackgroundRequest) (*auth.RequestAuth, error)
+	ServeBackground(a *auth.RequestAuth, w http.ResponseWriter, req *http.Request, serve http.HandlerFunc)
+}
+
 type DeepSeekCaller interface {
 	CreateSession(ctx context.Context, a *auth.RequestAuth, maxAttempts int) (string, error)
 	GetPow(ctx context.Context, a *auth.RequestAuth, maxAttempts int) (string, error)
@@ -22,8 +32,11 @@ type DeepSeekCaller interface {
 
 type ConfigReader interface {
 	ClaudeMapping() map[string]string
+	ThinkingSignatureSecret() string
+	ThinkingReinjectHistory() bool
 }
 
 var _ AuthResolver = (*auth.Resolver)(nil)
+var _ BatchAuthResolver = (*auth.Resolver)(nil)
 var _ DeepSeekCaller = (*deepseek.Client)(nil)
 var _ ConfigReader = (*config.Store)(nil)
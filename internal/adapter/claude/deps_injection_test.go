@@ -7,6 +7,8 @@ type mockClaudeConfig struct {
 }
 
 func (m mockClaudeConfig) ClaudeMapping() map[string]string { return m.m }
+func (mockClaudeConfig) ThinkingSignatureSecret() string    { return "test-secret" }
+func (mockClaudeConfig) ThinkingReinjectHistory() bool      { return false }
 
 func TestNormalizeClaudeRequestUsesConfigInterfaceMapping(t *testing.T) {
 	req := map[string]any{
@@ -2,9 +2,15 @@ package claude
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	"ds2api/internal/ratelimit"
 )
 
 func TestWriteClaudeErrorIncludesUnifiedFields(t *testing.T) {
@@ -32,3 +38,48 @@ func TestWriteClaudeErrorIncludesUnifiedFields(t *testing.T) {
 		t.Fatal("expected param field")
 	}
 }
+
+func TestWriteClaudeAuthErrorDistinguishesExpiredKeys(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{auth.ErrAPIKeyExpired, http.StatusUnauthorized, "api_key_expired"},
+		{auth.ErrUnauthorized, http.StatusUnauthorized, "authentication_failed"},
+		{auth.ErrNoAccount, http.StatusTooManyRequests, "rate_limit_exceeded"},
+		{fmt.Errorf("%w: model not allowed", auth.ErrForbidden), http.StatusForbidden, "forbidden"},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		writeClaudeAuthError(rec, tc.err)
+		var body map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		errObj, _ := body["error"].(map[string]any)
+		if rec.Code != tc.status || errObj["code"] != tc.code {
+			t.Fatalf("%v: expected %d %s, got %d %v", tc.err, tc.status, tc.code, rec.Code, errObj["code"])
+		}
+	}
+}
+
+func TestWriteClaudeAuthErrorSetsRateLimitHeaders(t *testing.T) {
+	err := &auth.RateLimitError{Decision: ratelimit.Decision{
+		Status: ratelimit.Status{
+			Limits:        config.RateLimitConfig{RequestsPerMinute: 10},
+			ResetRequests: 6 * time.Second,
+		},
+		Reason:     ratelimit.ReasonRequests,
+		RetryAfter: 5500 * time.Millisecond,
+	}}
+	rec := httptest.NewRecorder()
+	writeClaudeAuthError(rec, err)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "6" || rec.Header().Get("x-ratelimit-limit-requests") != "10" || rec.Header().Get("x-ratelimit-remaining-requests") != "0" {
+		t.Fatalf("expected Retry-After and x-ratelimit-* headers, got %v", rec.Header())
+	}
+	if rec.Header().Get("anthropic-ratelimit-requests-remaining") != "0" || rec.Header().Get("anthropic-ratelimit-requests-reset") == "" {
+		t.Fatalf("expected anthropic-ratelimit-* headers, got %v", rec.Header())
+	}
+}
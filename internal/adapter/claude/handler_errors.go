@@ -1,25 +1,77 @@
 package claude
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ds2api/internal/auth"
+)
+
+// claudeToolChoiceViolation is returned when tool_choice "any" or "tool"
+// produced no usable tool call.
+const claudeToolChoiceViolation = "tool_choice requires at least one valid tool call."
 
 func writeClaudeError(w http.ResponseWriter, status int, message string) {
+	writeClaudeErrorWithCode(w, status, message, "")
+}
+
+func writeClaudeErrorWithCode(w http.ResponseWriter, status int, message, code string) {
+	if code == "" {
+		code = claudeErrorCode(status)
+	}
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{
+			"type":    "invalid_request_error",
+			"message": message,
+			"code":    code,
+			"param":   nil,
+		},
+	})
+}
+
+// writeClaudeAuthError reports a failed Auth.Determine: 429 when the key is
+// over its rate limits or monthly quota or no account is free, 403 for a key
+// scope violation, otherwise 401, with distinct codes for expired keys and
+// exhausted quotas. Rate limit refusals carry both the x-ratelimit-* and
+// anthropic-ratelimit-* headers.
+func writeClaudeAuthError(w http.ResponseWriter, err error) {
+	var limited *auth.RateLimitError
+	var quota *auth.QuotaError
+	switch {
+	case errors.As(err, &limited):
+		limited.Decision.WriteRejection(w.Header())
+		limited.Decision.WriteAnthropicHeaders(w.Header())
+		writeClaudeError(w, http.StatusTooManyRequests, err.Error())
+	case errors.As(err, &quota):
+		w.Header().Set("Retry-After", strconv.Itoa(quota.RetryAfterSeconds()))
+		writeClaudeErrorWithCode(w, http.StatusTooManyRequests, err.Error(), "insufficient_quota")
+	case errors.Is(err, auth.ErrNoAccount):
+		writeClaudeError(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, auth.ErrAPIKeyExpired):
+		writeClaudeErrorWithCode(w, http.StatusUnauthorized, err.Error(), "api_key_expired")
+	case errors.Is(err, auth.ErrForbidden):
+		writeClaudeError(w, http.StatusForbidden, err.Error())
+	default:
+		writeClaudeError(w, http.StatusUnauthorized, err.Error())
+	}
+}
+
+func claudeErrorCode(status int) string {
 	code := "invalid_request"
 	switch status {
 	case http.StatusUnauthorized:
 		code = "authentication_failed"
+	case http.StatusForbidden:
+		code = "forbidden"
 	case http.StatusTooManyRequests:
 		code = "rate_limit_exceeded"
 	case http.StatusNotFound:
 		code = "not_found"
+	case http.StatusUnprocessableEntity:
+		code = "tool_choice_violation"
 	case http.StatusInternalServerError:
 		code = "internal_error"
 	}
-	writeJSON(w, status, map[string]any{
-		"error": map[string]any{
-			"type":    "invalid_request_error",
-			"message": message,
-			"code":    code,
-			"param":   nil,
-		},
-	})
+	return code
 }
@@ -8,11 +8,12 @@ import (
 	"strings"
 	"time"
 
-	"ds2api/internal/auth"
 	"ds2api/internal/config"
 	claudefmt "ds2api/internal/format/claude"
+	"ds2api/internal/promptcache"
 	"ds2api/internal/sse"
 	streamengine "ds2api/internal/stream"
+	"ds2api/internal/util"
 )
 
 func (h *Handler) Messages(w http.ResponseWriter, r *http.Request) {
@@ -21,12 +22,7 @@ func (h *Handler) Messages(w http.ResponseWriter, r *http.Request) {
 	}
 	a, err := h.Auth.Determine(r)
 	if err != nil {
-		status := http.StatusUnauthorized
-		detail := err.Error()
-		if err == auth.ErrNoAccount {
-			status = http.StatusTooManyRequests
-		}
-		writeClaudeError(w, status, detail)
+		writeClaudeAuthError(w, err)
 		return
 	}
 	defer h.Auth.Release(a)
@@ -42,6 +38,11 @@ func (h *Handler) Messages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	stdReq := norm.Standard
+	cacheUsage, err := h.applyPromptCache(a, req, norm)
+	if err != nil {
+		writeClaudeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	sessionID, err := h.DS.CreateSession(r.Context(), a, 3)
 	if err != nil {
@@ -67,22 +68,37 @@ func (h *Handler) Messages(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if stdReq.Stream {
-		h.handleClaudeStreamRealtime(w, r, resp, stdReq.ResponseModel, norm.NormalizedMessages, stdReq.Thinking, stdReq.Search, stdReq.ToolNames)
+		h.handleClaudeStreamRealtime(w, r, resp, stdReq.ResponseModel, norm.NormalizedMessages, stdReq.Thinking, stdReq.Search, stdReq.ToolNames, stdReq.ToolChoice, stdReq.ToolCalls, stdReq.StopPolicy, cacheUsage)
+		return
+	}
+	result := sse.CollectStreamWithPolicy(resp, stdReq.Thinking, true, stdReq.StopPolicy)
+	finalText := result.Text
+	var citations []util.CitationSpan
+	if stdReq.Search {
+		finalText, citations = util.ResolveCitations(finalText, result.SearchResults)
+	}
+	detected := stdReq.ToolCalls.Check(util.ParseToolCalls(finalText, stdReq.ToolNames)).Calls
+	if stdReq.ToolChoice.IsRequired() && len(detected) == 0 && result.FinishReason != util.OutputFinishContentFilter {
+		writeClaudeError(w, http.StatusUnprocessableEntity, claudeToolChoiceViolation)
 		return
 	}
-	result := sse.CollectStream(resp, stdReq.Thinking, true)
-	respBody := claudefmt.BuildMessageResponse(
+	respBody := claudefmt.BuildMessageResponseFromCalls(
 		fmt.Sprintf("msg_%d", time.Now().UnixNano()),
 		stdReq.ResponseModel,
 		norm.NormalizedMessages,
 		result.Thinking,
-		result.Text,
-		stdReq.ToolNames,
+		finalText,
+		detected,
+		result.FinishReason,
+		result.StopSequence,
 	)
+	claudefmt.AttachCitations(respBody, finalText, citations)
+	claudefmt.AttachThinkingSignatures(respBody, h.thinkingSecret())
+	claudefmt.AttachPromptCacheUsage(respBody, cacheUsage)
 	writeJSON(w, http.StatusOK, respBody)
 }
 
-func (h *Handler) handleClaudeStreamRealtime(w http.ResponseWriter, r *http.Request, resp *http.Response, model string, messages []any, thinkingEnabled, searchEnabled bool, toolNames []string) {
+func (h *Handler) handleClaudeStreamRealtime(w http.ResponseWriter, r *http.Request, resp *http.Response, model string, messages []any, thinkingEnabled, searchEnabled bool, toolNames []string, toolChoice util.ToolChoicePolicy, toolCalls util.ToolCallPolicy, stopPolicy util.StopPolicy, cacheUsage promptcache.Usage) {
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
@@ -109,7 +125,12 @@ func (h *Handler) handleClaudeStreamRealtime(w http.ResponseWriter, r *http.Requ
 		thinkingEnabled,
 		searchEnabled,
 		toolNames,
+		toolChoice,
+		toolCalls,
+		stopPolicy,
 	)
+	streamRuntime.thinkingSecret = h.thinkingSecret()
+	streamRuntime.cacheUsage = cacheUsage
 	streamRuntime.sendMessageStart()
 
 	initialType := "text"
@@ -132,3 +153,10 @@ func (h *Handler) handleClaudeStreamRealtime(w http.ResponseWriter, r *http.Requ
 		OnFinalize: streamRuntime.onFinalize,
 	})
 }
+
+func (h *Handler) thinkingSecret() string {
+	if h.Store == nil {
+		return ""
+	}
+	return h.Store.ThinkingSignatureSecret()
+}
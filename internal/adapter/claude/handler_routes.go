@@ -2,12 +2,14 @@ package claude
 
 import (
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"ds2api/internal/config"
 	"ds2api/internal/deepseek"
+	"ds2api/internal/promptcache"
 	"ds2api/internal/util"
 )
 
@@ -18,6 +20,15 @@ type Handler struct {
 	Store ConfigReader
 	Auth  AuthResolver
 	DS    DeepSeekCaller
+	// DataDir overrides where message batches are kept; empty means
+	// config.DataDir().
+	DataDir string
+	// PromptCache tracks cache_control prefixes for usage accounting; nil
+	// reports no cache activity.
+	PromptCache *promptcache.Cache
+
+	batchesOnce sync.Once
+	batches     *messageBatchStore
 }
 
 var (
@@ -30,12 +41,22 @@ func RegisterRoutes(r chi.Router, h *Handler) {
 	r.Get("/anthropic/v1/models", h.ListModels)
 	r.Post("/anthropic/v1/messages", h.Messages)
 	r.Post("/anthropic/v1/messages/count_tokens", h.CountTokens)
+	registerMessageBatchRoutes(r, "/anthropic/v1/messages/batches", h)
+	registerMessageBatchRoutes(r, "/v1/messages/batches", h)
 	r.Post("/v1/messages", h.Messages)
 	r.Post("/messages", h.Messages)
 	r.Post("/v1/messages/count_tokens", h.CountTokens)
 	r.Post("/messages/count_tokens", h.CountTokens)
 }
 
+func registerMessageBatchRoutes(r chi.Router, prefix string, h *Handler) {
+	r.Post(prefix, h.CreateMessageBatch)
+	r.Get(prefix, h.ListMessageBatches)
+	r.Get(prefix+"/{batch_id}", h.GetMessageBatch)
+	r.Post(prefix+"/{batch_id}/cancel", h.CancelMessageBatch)
+	r.Get(prefix+"/{batch_id}/results", h.MessageBatchResults)
+}
+
 func (h *Handler) ListModels(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, http.StatusOK, config.ClaudeModelsResponse())
 }
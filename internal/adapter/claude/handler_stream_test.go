@@ -1,7 +1,10 @@
 package claude
 
 import (
+	claudefmt "ds2api/internal/format/claude"
+	"ds2api/internal/promptcache"
 	"ds2api/internal/sse"
+	"ds2api/internal/util"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -81,7 +84,7 @@ func TestHandleClaudeStreamRealtimeTextIncrementsWithEventHeaders(t *testing.T)
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
 
-	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, false, false, nil)
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, false, false, nil, util.DefaultToolChoicePolicy(), util.ToolCallPolicy{}, util.StopPolicy{}, promptcache.Usage{})
 
 	body := rec.Body.String()
 	if !strings.Contains(body, "event: message_start") {
@@ -122,7 +125,7 @@ func TestHandleClaudeStreamRealtimeThinkingDelta(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
 
-	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, true, false, nil)
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, true, false, nil, util.DefaultToolChoicePolicy(), util.ToolCallPolicy{}, util.StopPolicy{}, promptcache.Usage{})
 
 	frames := parseClaudeFrames(t, rec.Body.String())
 	foundThinkingDelta := false
@@ -138,6 +141,31 @@ func TestHandleClaudeStreamRealtimeThinkingDelta(t *testing.T) {
 	}
 }
 
+func TestHandleClaudeStreamRealtimeSignsThinkingBlock(t *testing.T) {
+	h := &Handler{Store: mockClaudeConfig{}}
+	resp := makeClaudeSSEHTTPResponse(
+		`data: {"p":"response/thinking_content","v":"first "}`,
+		`data: {"p":"response/thinking_content","v":"second"}`,
+		`data: {"p":"response/content","v":"done"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
+
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-opus-4-6", []any{map[string]any{"role": "user", "content": "hi"}}, true, false, nil, util.DefaultToolChoicePolicy(), util.ToolCallPolicy{}, util.StopPolicy{}, promptcache.Usage{})
+
+	var signature string
+	for _, f := range findClaudeFrames(parseClaudeFrames(t, rec.Body.String()), "content_block_delta") {
+		delta, _ := f.Payload["delta"].(map[string]any)
+		if delta["type"] == "signature_delta" {
+			signature = asString(delta["signature"])
+		}
+	}
+	if !claudefmt.VerifyThinkingSignature("test-secret", "first second", signature) {
+		t.Fatalf("expected verifiable signature_delta, body=%s", rec.Body.String())
+	}
+}
+
 func TestHandleClaudeStreamRealtimeToolSafety(t *testing.T) {
 	h := &Handler{}
 	resp := makeClaudeSSEHTTPResponse(
@@ -148,7 +176,7 @@ func TestHandleClaudeStreamRealtimeToolSafety(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
 
-	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "use tool"}}, false, false, []string{"search"})
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "use tool"}}, false, false, []string{"search"}, util.DefaultToolChoicePolicy(), util.ToolCallPolicy{}, util.StopPolicy{}, promptcache.Usage{})
 
 	frames := parseClaudeFrames(t, rec.Body.String())
 	for _, f := range findClaudeFrames(frames, "content_block_delta") {
@@ -183,6 +211,104 @@ func TestHandleClaudeStreamRealtimeToolSafety(t *testing.T) {
 	}
 }
 
+func TestHandleClaudeStreamRealtimeStreamsToolInputAsJSONDeltas(t *testing.T) {
+	h := &Handler{}
+	resp := makeClaudeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"Writing file.\n{\"tool_calls\":[{\"name\":\"write\",\"input\":{\"path\":\"a.txt\",\"body\":\"part one "}`,
+		`data: {"p":"response/content","v":"part two\"}}]}"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
+
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "write"}}, false, false, []string{"write"}, util.DefaultToolChoicePolicy(), util.ToolCallPolicy{}, util.StopPolicy{}, promptcache.Usage{})
+
+	frames := parseClaudeFrames(t, rec.Body.String())
+	toolIndex := -1
+	var text, partial strings.Builder
+	var deltaCount int
+	for _, f := range frames {
+		switch f.Event {
+		case "content_block_start":
+			block, _ := f.Payload["content_block"].(map[string]any)
+			if block["type"] == "tool_use" {
+				if input, _ := block["input"].(map[string]any); len(input) != 0 {
+					t.Fatalf("expected empty input on tool_use start, got %#v", input)
+				}
+				toolIndex = int(f.Payload["index"].(float64))
+			}
+		case "content_block_delta":
+			delta, _ := f.Payload["delta"].(map[string]any)
+			switch delta["type"] {
+			case "text_delta":
+				text.WriteString(asString(delta["text"]))
+			case "input_json_delta":
+				if int(f.Payload["index"].(float64)) != toolIndex {
+					t.Fatalf("input_json_delta outside the tool_use block: %#v", f.Payload)
+				}
+				deltaCount++
+				partial.WriteString(asString(delta["partial_json"]))
+			}
+		}
+	}
+	if strings.TrimSpace(text.String()) != "Writing file." {
+		t.Fatalf("expected leading text to stream without tool JSON, got %q", text.String())
+	}
+	if deltaCount < 2 {
+		t.Fatalf("expected input to arrive across several deltas, got %d body=%s", deltaCount, rec.Body.String())
+	}
+	var input map[string]any
+	if err := json.Unmarshal([]byte(partial.String()), &input); err != nil || input["body"] != "part one part two" {
+		t.Fatalf("unexpected assembled input %q err=%v", partial.String(), err)
+	}
+	foundToolUseStop := false
+	for _, f := range findClaudeFrames(frames, "message_delta") {
+		delta, _ := f.Payload["delta"].(map[string]any)
+		foundToolUseStop = foundToolUseStop || delta["stop_reason"] == "tool_use"
+	}
+	if !foundToolUseStop {
+		t.Fatalf("expected stop_reason=tool_use, body=%s", rec.Body.String())
+	}
+}
+
+func TestHandleClaudeStreamRealtimeToolChoiceLimits(t *testing.T) {
+	h := &Handler{}
+	resp := makeClaudeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"{\"tool_calls\":[{\"name\":\"search\",\"input\":{\"q\":\"a\"}},{\"name\":\"search\",\"input\":{\"q\":\"b\"}}]}"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
+	choice := util.ToolChoicePolicy{Mode: util.ToolChoiceRequired}
+
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "use tool"}}, false, false, []string{"search"}, choice, util.ToolCallPolicy{DisableParallel: true}, util.StopPolicy{}, promptcache.Usage{})
+
+	toolUses := 0
+	for _, f := range findClaudeFrames(parseClaudeFrames(t, rec.Body.String()), "content_block_start") {
+		contentBlock, _ := f.Payload["content_block"].(map[string]any)
+		if contentBlock["type"] == "tool_use" {
+			toolUses++
+		}
+	}
+	if toolUses != 1 {
+		t.Fatalf("expected disable_parallel_tool_use to keep one call, got %d body=%s", toolUses, rec.Body.String())
+	}
+
+	resp = makeClaudeSSEHTTPResponse(`data: {"p":"response/content","v":"plain answer"}`, `data: [DONE]`)
+	rec = httptest.NewRecorder()
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "use tool"}}, false, false, []string{"search"}, choice, util.ToolCallPolicy{}, util.StopPolicy{}, promptcache.Usage{})
+
+	frames := parseClaudeFrames(t, rec.Body.String())
+	errs := findClaudeFrames(frames, "error")
+	if len(errs) != 1 || len(findClaudeFrames(frames, "message_stop")) != 0 {
+		t.Fatalf("expected tool_choice violation error, body=%s", rec.Body.String())
+	}
+	errObj, _ := errs[0].Payload["error"].(map[string]any)
+	if errObj["code"] != "tool_choice_violation" {
+		t.Fatalf("unexpected error payload: %#v", errObj)
+	}
+}
+
 func TestHandleClaudeStreamRealtimeUpstreamErrorEvent(t *testing.T) {
 	h := &Handler{}
 	resp := makeClaudeSSEHTTPResponse(
@@ -191,7 +317,7 @@ func TestHandleClaudeStreamRealtimeUpstreamErrorEvent(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
 
-	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, false, false, nil)
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, false, false, nil, util.DefaultToolChoicePolicy(), util.ToolCallPolicy{}, util.StopPolicy{}, promptcache.Usage{})
 
 	frames := parseClaudeFrames(t, rec.Body.String())
 	errFrames := findClaudeFrames(frames, "error")
@@ -228,7 +354,7 @@ func TestHandleClaudeStreamRealtimePingEvent(t *testing.T) {
 
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
-	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, false, false, nil)
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, false, false, nil, util.DefaultToolChoicePolicy(), util.ToolCallPolicy{}, util.StopPolicy{}, promptcache.Usage{})
 
 	frames := parseClaudeFrames(t, rec.Body.String())
 	if len(findClaudeFrames(frames, "ping")) == 0 {
@@ -255,3 +381,110 @@ func asString(v any) string {
 	s, _ := v.(string)
 	return s
 }
+
+func TestHandleClaudeStreamRealtimeMessageDeltaReportsUsage(t *testing.T) {
+	h := &Handler{}
+	resp := makeClaudeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"hello world"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
+
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, false, false, nil, util.DefaultToolChoicePolicy(), util.ToolCallPolicy{}, util.StopPolicy{}, promptcache.Usage{})
+
+	frames := parseClaudeFrames(t, rec.Body.String())
+	deltas := findClaudeFrames(frames, "message_delta")
+	if len(deltas) != 1 {
+		t.Fatalf("expected one message_delta, got=%d body=%s", len(deltas), rec.Body.String())
+	}
+	usage, _ := deltas[0].Payload["usage"].(map[string]any)
+	if in, _ := usage["input_tokens"].(float64); in <= 0 {
+		t.Fatalf("expected input_tokens in message_delta usage, got %#v", usage)
+	}
+	if out, _ := usage["output_tokens"].(float64); out <= 0 {
+		t.Fatalf("expected output_tokens in message_delta usage, got %#v", usage)
+	}
+}
+
+func TestHandleClaudeStreamRealtimeStopSequence(t *testing.T) {
+	h := &Handler{}
+	resp := makeClaudeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"Thought: ok\nObs"}`,
+		`data: {"p":"response/content","v":"ervation: leaked"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
+
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, false, false, nil, util.DefaultToolChoicePolicy(), util.ToolCallPolicy{}, util.StopPolicy{Sequences: []string{"\nObservation:"}}, promptcache.Usage{})
+
+	body := rec.Body.String()
+	frames := parseClaudeFrames(t, body)
+	combined := strings.Builder{}
+	for _, f := range findClaudeFrames(frames, "content_block_delta") {
+		delta, _ := f.Payload["delta"].(map[string]any)
+		combined.WriteString(asString(delta["text"]))
+	}
+	if combined.String() != "Thought: ok" {
+		t.Fatalf("expected output cut at stop sequence, got %q body=%s", combined.String(), body)
+	}
+	deltas := findClaudeFrames(frames, "message_delta")
+	if len(deltas) != 1 {
+		t.Fatalf("expected one message_delta, body=%s", body)
+	}
+	delta, _ := deltas[0].Payload["delta"].(map[string]any)
+	if delta["stop_reason"] != "stop_sequence" || delta["stop_sequence"] != "\nObservation:" {
+		t.Fatalf("unexpected stop fields: %#v", delta)
+	}
+}
+
+func TestHandleClaudeStreamRealtimeMaxTokens(t *testing.T) {
+	h := &Handler{}
+	resp := makeClaudeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"`+strings.Repeat("abcd", 50)+`"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
+
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, false, false, nil, util.DefaultToolChoicePolicy(), util.ToolCallPolicy{}, util.StopPolicy{MaxTokens: 10}, promptcache.Usage{})
+
+	frames := parseClaudeFrames(t, rec.Body.String())
+	deltas := findClaudeFrames(frames, "message_delta")
+	if len(deltas) != 1 {
+		t.Fatalf("expected one message_delta, body=%s", rec.Body.String())
+	}
+	delta, _ := deltas[0].Payload["delta"].(map[string]any)
+	if delta["stop_reason"] != "max_tokens" {
+		t.Fatalf("expected stop_reason=max_tokens, got %#v", delta)
+	}
+	usage, _ := deltas[0].Payload["usage"].(map[string]any)
+	if out, _ := usage["output_tokens"].(float64); out > 10 {
+		t.Fatalf("expected output_tokens within budget, got %#v", usage)
+	}
+}
+
+func TestHandleClaudeStreamRealtimeContentFilterIsRefusal(t *testing.T) {
+	h := &Handler{}
+	resp := makeClaudeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"partial"}`,
+		`data: {"code":"content_filter"}`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
+
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, false, false, nil, util.DefaultToolChoicePolicy(), util.ToolCallPolicy{}, util.StopPolicy{}, promptcache.Usage{})
+
+	frames := parseClaudeFrames(t, rec.Body.String())
+	if len(findClaudeFrames(frames, "error")) != 0 {
+		t.Fatalf("content filter must not surface as an error event, body=%s", rec.Body.String())
+	}
+	deltas := findClaudeFrames(frames, "message_delta")
+	if len(deltas) != 1 {
+		t.Fatalf("expected one message_delta, body=%s", rec.Body.String())
+	}
+	if delta, _ := deltas[0].Payload["delta"].(map[string]any); delta["stop_reason"] != "refusal" {
+		t.Fatalf("expected stop_reason=refusal, got %#v", delta)
+	}
+}
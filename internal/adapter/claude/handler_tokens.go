@@ -10,7 +10,7 @@ import (
 func (h *Handler) CountTokens(w http.ResponseWriter, r *http.Request) {
 	a, err := h.Auth.Determine(r)
 	if err != nil {
-		writeClaudeError(w, http.StatusUnauthorized, err.Error())
+		writeClaudeAuthError(w, err)
 		return
 	}
 	defer h.Auth.Release(a)
@@ -28,7 +28,7 @@ func (h *Handler) CountTokens(w http.ResponseWriter, r *http.Request) {
 	}
 	inputTokens := 0
 	if sys, ok := req["system"].(string); ok {
-		inputTokens += util.EstimateTokens(sys)
+		inputTokens += util.CountTokens(sys)
 	}
 	for _, item := range messages {
 		msg, ok := item.(map[string]any)
@@ -36,12 +36,12 @@ func (h *Handler) CountTokens(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		inputTokens += 2
-		inputTokens += util.EstimateTokens(extractMessageContent(msg["content"]))
+		inputTokens += util.CountTokens(extractMessageContent(msg["content"]))
 	}
 	if tools, ok := req["tools"].([]any); ok {
 		for _, t := range tools {
 			b, _ := json.Marshal(t)
-			inputTokens += util.EstimateTokens(string(b))
+			inputTokens += util.CountTokens(string(b))
 		}
 	}
 	if inputTokens < 1 {
@@ -3,6 +3,9 @@ package claude
 import (
 	"strings"
 	"testing"
+
+	claudefmt "ds2api/internal/format/claude"
+	"ds2api/internal/util"
 )
 
 // ─── normalizeClaudeMessages ─────────────────────────────────────────
@@ -11,7 +14,7 @@ func TestNormalizeClaudeMessagesSimpleString(t *testing.T) {
 	msgs := []any{
 		map[string]any{"role": "user", "content": "Hello"},
 	}
-	got := normalizeClaudeMessages(msgs)
+	got, _ := normalizeClaudeMessages(msgs, claudeThinkingHistory{})
 	if len(got) != 1 {
 		t.Fatalf("expected 1 message, got %d", len(got))
 	}
@@ -31,7 +34,7 @@ func TestNormalizeClaudeMessagesArrayContent(t *testing.T) {
 			},
 		},
 	}
-	got := normalizeClaudeMessages(msgs)
+	got, _ := normalizeClaudeMessages(msgs, claudeThinkingHistory{})
 	m := got[0].(map[string]any)
 	if m["content"] != "line1\nline2" {
 		t.Fatalf("expected joined text, got %q", m["content"])
@@ -47,7 +50,7 @@ func TestNormalizeClaudeMessagesToolResult(t *testing.T) {
 			},
 		},
 	}
-	got := normalizeClaudeMessages(msgs)
+	got, _ := normalizeClaudeMessages(msgs, claudeThinkingHistory{})
 	m := got[0].(map[string]any)
 	content, _ := m["content"].(string)
 	if !strings.Contains(content, "[TOOL_RESULT_HISTORY]") || !strings.Contains(content, "content: tool output") {
@@ -57,14 +60,14 @@ func TestNormalizeClaudeMessagesToolResult(t *testing.T) {
 
 func TestNormalizeClaudeMessagesSkipsNonMap(t *testing.T) {
 	msgs := []any{"not a map", 42}
-	got := normalizeClaudeMessages(msgs)
+	got, _ := normalizeClaudeMessages(msgs, claudeThinkingHistory{})
 	if len(got) != 0 {
 		t.Fatalf("expected 0 messages for non-map items, got %d", len(got))
 	}
 }
 
 func TestNormalizeClaudeMessagesEmpty(t *testing.T) {
-	got := normalizeClaudeMessages(nil)
+	got, _ := normalizeClaudeMessages(nil, claudeThinkingHistory{})
 	if len(got) != 0 {
 		t.Fatalf("expected 0, got %d", len(got))
 	}
@@ -74,7 +77,7 @@ func TestNormalizeClaudeMessagesPreservesRole(t *testing.T) {
 	msgs := []any{
 		map[string]any{"role": "assistant", "content": "response"},
 	}
-	got := normalizeClaudeMessages(msgs)
+	got, _ := normalizeClaudeMessages(msgs, claudeThinkingHistory{})
 	m := got[0].(map[string]any)
 	if m["role"] != "assistant" {
 		t.Fatalf("expected 'assistant', got %q", m["role"])
@@ -92,7 +95,7 @@ func TestNormalizeClaudeMessagesMixedContentBlocks(t *testing.T) {
 			},
 		},
 	}
-	got := normalizeClaudeMessages(msgs)
+	got, _ := normalizeClaudeMessages(msgs, claudeThinkingHistory{})
 	m := got[0].(map[string]any)
 	if m["content"] != "Hello\nWorld" {
 		t.Fatalf("expected only text parts joined, got %q", m["content"])
@@ -114,7 +117,7 @@ func TestBuildClaudeToolPromptSingleTool(t *testing.T) {
 			},
 		},
 	}
-	prompt := buildClaudeToolPrompt(tools)
+	prompt := buildClaudeToolPrompt(tools, util.DefaultToolChoicePolicy(), util.ToolCallPolicy{})
 	if prompt == "" {
 		t.Fatal("expected non-empty prompt")
 	}
@@ -135,7 +138,7 @@ func TestBuildClaudeToolPromptMultipleTools(t *testing.T) {
 		map[string]any{"name": "tool1", "description": "desc1"},
 		map[string]any{"name": "tool2", "description": "desc2"},
 	}
-	prompt := buildClaudeToolPrompt(tools)
+	prompt := buildClaudeToolPrompt(tools, util.DefaultToolChoicePolicy(), util.ToolCallPolicy{})
 	if !containsStr(prompt, "tool1") || !containsStr(prompt, "tool2") {
 		t.Fatalf("expected both tools in prompt")
 	}
@@ -143,7 +146,7 @@ func TestBuildClaudeToolPromptMultipleTools(t *testing.T) {
 
 func TestBuildClaudeToolPromptSkipsNonMap(t *testing.T) {
 	tools := []any{"not a map"}
-	prompt := buildClaudeToolPrompt(tools)
+	prompt := buildClaudeToolPrompt(tools, util.DefaultToolChoicePolicy(), util.ToolCallPolicy{})
 	if prompt == "" {
 		t.Fatal("expected non-empty prompt even with invalid tools")
 	}
@@ -348,3 +351,37 @@ func findSubstring(s, sub string) bool {
 	}
 	return false
 }
+
+func TestNormalizeClaudeMessagesThinkingBlocks(t *testing.T) {
+	history := claudeThinkingHistory{Secret: "secret"}
+	assistant := func(blocks ...any) []any {
+		return []any{map[string]any{"role": "assistant", "content": blocks}}
+	}
+	signed := map[string]any{"type": "thinking", "thinking": "plan", "signature": claudefmt.SignThinking("secret", "plan")}
+	text := map[string]any{"type": "text", "text": "answer"}
+
+	got, err := normalizeClaudeMessages(assistant(signed, map[string]any{"type": "redacted_thinking", "data": "opaque"}, text), history)
+	if err != nil {
+		t.Fatalf("expected signed blocks to be accepted: %v", err)
+	}
+	if content := got[0].(map[string]any)["content"]; content != "answer" {
+		t.Fatalf("expected reasoning to stay out of the prompt by default, got %q", content)
+	}
+
+	history.Reinject = true
+	got, _ = normalizeClaudeMessages(assistant(signed, text), history)
+	if content, _ := got[0].(map[string]any)["content"].(string); !strings.Contains(content, "[THINKING_HISTORY]\nplan\n[/THINKING_HISTORY]") {
+		t.Fatalf("expected reasoning to be re-injected, got %q", content)
+	}
+
+	tampered := map[string]any{"type": "thinking", "thinking": "other plan", "signature": signed["signature"]}
+	for _, blocks := range [][]any{
+		assistant(tampered, text),
+		assistant(map[string]any{"type": "thinking", "thinking": "plan"}, text),
+		assistant(map[string]any{"type": "redacted_thinking"}, text),
+	} {
+		if _, err := normalizeClaudeMessages(blocks, history); err == nil {
+			t.Fatalf("expected rejection for %#v", blocks)
+		}
+	}
+}
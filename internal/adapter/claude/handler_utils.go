@@ -4,11 +4,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+
+	claudefmt "ds2api/internal/format/claude"
+	"ds2api/internal/util"
 )
 
-func normalizeClaudeMessages(messages []any) []any {
+// claudeThinkingHistory decides how thinking blocks sent back by the client
+// are treated: they must carry a signature made with Secret, and only when
+// Reinject is set does their text reach the upstream prompt.
+type claudeThinkingHistory struct {
+	Secret   string
+	Reinject bool
+}
+
+func normalizeClaudeMessages(messages []any, history claudeThinkingHistory) ([]any, error) {
 	out := make([]any, 0, len(messages))
-	for _, m := range messages {
+	for i, m := range messages {
 		msg, ok := m.(map[string]any)
 		if !ok {
 			continue
@@ -17,12 +28,31 @@ func normalizeClaudeMessages(messages []any) []any {
 		switch content := msg["content"].(type) {
 		case []any:
 			parts := make([]string, 0, len(content))
-			for _, block := range content {
+			for j, block := range content {
 				b, ok := block.(map[string]any)
 				if !ok {
 					continue
 				}
 				typeStr, _ := b["type"].(string)
+				switch typeStr {
+				case "thinking":
+					thinking, _ := b["thinking"].(string)
+					signature, _ := b["signature"].(string)
+					if !claudefmt.VerifyThinkingSignature(history.Secret, thinking, signature) {
+						return nil, fmt.Errorf("messages.%d.content.%d: Invalid `signature` in `thinking` block", i, j)
+					}
+					if history.Reinject && strings.TrimSpace(thinking) != "" {
+						parts = append(parts, "[THINKING_HISTORY]\n"+thinking+"\n[/THINKING_HISTORY]")
+					}
+					continue
+				case "redacted_thinking":
+					// The payload is opaque, so it is checked for presence and never
+					// replayed.
+					if data, _ := b["data"].(string); strings.TrimSpace(data) == "" {
+						return nil, fmt.Errorf("messages.%d.content.%d: `redacted_thinking` block requires `data`", i, j)
+					}
+					continue
+				}
 				if typeStr == "text" {
 					if t, ok := b["text"].(string); ok {
 						parts = append(parts, t)
@@ -36,10 +66,10 @@ func normalizeClaudeMessages(messages []any) []any {
 		}
 		out = append(out, copied)
 	}
-	return out
+	return out, nil
 }
 
-func buildClaudeToolPrompt(tools []any) string {
+func buildClaudeToolPrompt(tools []any, toolChoice util.ToolChoicePolicy, toolCalls util.ToolCallPolicy) string {
 	parts := []string{"You are Claude, a helpful AI assistant. You have access to these tools:"}
 	for _, t := range tools {
 		m, ok := t.(map[string]any)
@@ -47,6 +77,9 @@ func buildClaudeToolPrompt(tools []any) string {
 			continue
 		}
 		name, _ := m["name"].(string)
+		if !toolChoice.Allows(name) {
+			continue
+		}
 		desc, _ := m["description"].(string)
 		schema, _ := json.Marshal(m["input_schema"])
 		parts = append(parts, fmt.Sprintf("Tool: %s\nDescription: %s\nParameters: %s", name, desc, schema))
@@ -56,6 +89,15 @@ func buildClaudeToolPrompt(tools []any) string {
 		"History markers in conversation: [TOOL_CALL_HISTORY]...[/TOOL_CALL_HISTORY] are your previous tool calls; [TOOL_RESULT_HISTORY]...[/TOOL_RESULT_HISTORY] are runtime tool outputs, not user input.",
 		"After a valid [TOOL_RESULT_HISTORY], continue with final answer instead of repeating the same call unless required fields are still missing.",
 	)
+	switch toolChoice.Mode {
+	case util.ToolChoiceRequired:
+		parts = append(parts, "For this response, you MUST call at least one of the tools above.")
+	case util.ToolChoiceForced:
+		parts = append(parts, "For this response, you MUST call exactly this tool: "+toolChoice.ForcedName+". Do not call any other tool.")
+	}
+	if toolCalls.DisableParallel {
+		parts = append(parts, "Call at most one tool in this response.")
+	}
 	return strings.Join(parts, "\n\n")
 }
 
@@ -0,0 +1,146 @@
+package claude
+
+import (
+	"fmt"
+	"time"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/promptcache"
+)
+
+// claudeCacheBreakpointLimit matches Anthropic's cap on cache_control blocks
+// per request.
+const claudeCacheBreakpointLimit = 4
+
+// buildClaudePromptPrefix walks tools, system and the normalized messages in
+// prompt order and places a breakpoint after every segment that carries
+// cache_control. It returns nil when the request has no markers.
+func buildClaudePromptPrefix(req map[string]any, normalizedMessages []any, scope ...string) (*promptcache.Prefix, error) {
+	prefix := promptcache.NewPrefix(scope...)
+	marks := 0
+	mark := func(raw any, path string) error {
+		ttl, ok, err := parseClaudeCacheControl(raw, path)
+		if err != nil || !ok {
+			return err
+		}
+		marks++
+		prefix.Mark(ttl)
+		return nil
+	}
+
+	tools, _ := req["tools"].([]any)
+	for i, item := range tools {
+		tool, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		prefix.Add(withoutCacheControl(tool))
+		if err := mark(tool["cache_control"], fmt.Sprintf("tools.%d", i)); err != nil {
+			return nil, err
+		}
+	}
+
+	switch system := req["system"].(type) {
+	case string:
+		if system != "" {
+			prefix.Add(system)
+		}
+	case []any:
+		for i, item := range system {
+			block, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			prefix.Add(withoutCacheControl(block))
+			if err := mark(block["cache_control"], fmt.Sprintf("system.%d", i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// normalizeClaudeMessages drops non-object entries, so raw and normalized
+	// indices are tracked separately.
+	messages, _ := req["messages"].([]any)
+	n := 0
+	for i, item := range messages {
+		msg, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		if n < len(normalizedMessages) {
+			prefix.Add(normalizedMessages[n])
+		}
+		n++
+		var ttl time.Duration
+		content, _ := msg["content"].([]any)
+		for j, raw := range content {
+			block, _ := raw.(map[string]any)
+			if block == nil {
+				continue
+			}
+			blockTTL, ok, err := parseClaudeCacheControl(block["cache_control"], fmt.Sprintf("messages.%d.content.%d", i, j))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				marks++
+				ttl = blockTTL
+			}
+		}
+		// Normalized messages are whole turns, so every marker inside a
+		// message resolves to the end of that message.
+		if ttl > 0 {
+			prefix.Mark(ttl)
+		}
+	}
+	if marks > claudeCacheBreakpointLimit {
+		return nil, fmt.Errorf("A maximum of %d blocks with cache_control may be provided. Found %d.", claudeCacheBreakpointLimit, marks)
+	}
+	if marks == 0 {
+		return nil, nil
+	}
+	return prefix, nil
+}
+
+func parseClaudeCacheControl(raw any, path string) (time.Duration, bool, error) {
+	if raw == nil {
+		return 0, false, nil
+	}
+	cc, ok := raw.(map[string]any)
+	if !ok {
+		return 0, false, fmt.Errorf("%s.cache_control: must be an object", path)
+	}
+	if typ, _ := cc["type"].(string); typ != "ephemeral" {
+		return 0, false, fmt.Errorf("%s.cache_control.type: Input should be 'ephemeral'", path)
+	}
+	switch cc["ttl"] {
+	case nil, "5m":
+		return promptcache.TTLDefault, true, nil
+	case "1h":
+		return promptcache.TTLExtended, true, nil
+	}
+	return 0, false, fmt.Errorf("%s.cache_control.ttl: Input should be '5m' or '1h'", path)
+}
+
+func withoutCacheControl(block map[string]any) map[string]any {
+	if _, ok := block["cache_control"]; !ok {
+		return block
+	}
+	out := cloneMap(block)
+	delete(out, "cache_control")
+	return out
+}
+
+// applyPromptCache records the request's cache breakpoints for the caller and
+// returns the resulting usage split.
+func (h *Handler) applyPromptCache(a *auth.RequestAuth, req map[string]any, norm claudeNormalizedRequest) (promptcache.Usage, error) {
+	owner := ""
+	if a != nil {
+		owner = a.CallerID
+	}
+	prefix, err := buildClaudePromptPrefix(req, norm.NormalizedMessages, owner, norm.Standard.ResolvedModel)
+	if err != nil || prefix == nil {
+		return promptcache.Usage{}, err
+	}
+	return h.PromptCache.Apply(prefix), nil
+}
@@ -0,0 +1,77 @@
+package claude
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/promptcache"
+)
+
+func doCachedClaudeMessage(t *testing.T, r chi.Router, system, question string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{
+		"model": "claude-sonnet-4-5",
+		"system": []any{
+			map[string]any{"type": "text", "text": system, "cache_control": map[string]any{"type": "ephemeral", "ttl": "1h"}},
+		},
+		"messages": []any{map[string]any{"role": "user", "content": question}},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMessagesReportPromptCacheUsage(t *testing.T) {
+	h := &Handler{Store: streamStatusClaudeStoreStub{}, Auth: streamStatusClaudeAuthStub{}, DS: streamStatusClaudeDSStub{}, PromptCache: promptcache.New()}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	system := strings.Repeat("You are a careful reviewer. ", 400)
+
+	usageOf := func(rec *httptest.ResponseRecorder) map[string]any {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+		}
+		var out map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		usage, _ := out["usage"].(map[string]any)
+		return usage
+	}
+	first := usageOf(doCachedClaudeMessage(t, r, system, "first question"))
+	if first["cache_creation_input_tokens"].(float64) < promptcache.MinTokens || first["cache_read_input_tokens"] != float64(0) {
+		t.Fatalf("expected a cache write on the first request, got %#v", first)
+	}
+	second := usageOf(doCachedClaudeMessage(t, r, system, "second question"))
+	if second["cache_read_input_tokens"] != first["cache_creation_input_tokens"] || second["cache_creation_input_tokens"] != float64(0) {
+		t.Fatalf("expected a cache read on the second request, got %#v", second)
+	}
+	if in := second["input_tokens"].(float64); in <= 0 || in >= second["cache_read_input_tokens"].(float64) {
+		t.Fatalf("expected input_tokens to exclude the cached prefix, got %#v", second)
+	}
+}
+
+func TestBuildClaudePromptPrefixValidatesCacheControl(t *testing.T) {
+	marker := func(ttl string) map[string]any {
+		return map[string]any{"type": "text", "text": "x", "cache_control": map[string]any{"type": "ephemeral", "ttl": ttl}}
+	}
+	bad := map[string]any{"messages": []any{map[string]any{"role": "user", "content": []any{marker("10m")}}}}
+	if _, err := buildClaudePromptPrefix(bad, []any{map[string]any{"role": "user", "content": "x"}}); err == nil || !strings.Contains(err.Error(), "messages.0.content.0.cache_control.ttl") {
+		t.Fatalf("expected ttl validation error, got %v", err)
+	}
+	blocks := []any{marker("5m"), marker("5m"), marker("5m"), marker("5m"), marker("1h")}
+	tooMany := map[string]any{"messages": []any{map[string]any{"role": "user", "content": blocks}}}
+	if _, err := buildClaudePromptPrefix(tooMany, []any{map[string]any{"role": "user", "content": "x"}}); err == nil || !strings.Contains(err.Error(), "maximum of 4") {
+		t.Fatalf("expected breakpoint limit error, got %v", err)
+	}
+	plain := map[string]any{"messages": []any{map[string]any{"role": "user", "content": "x"}}}
+	if prefix, err := buildClaudePromptPrefix(plain, []any{map[string]any{"role": "user", "content": "x"}}); err != nil || prefix != nil {
+		t.Fatalf("expected no prefix without markers, got %v %v", prefix, err)
+	}
+}
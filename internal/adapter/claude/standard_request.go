@@ -20,14 +20,31 @@ func normalizeClaudeRequest(store ConfigReader, req map[string]any) (claudeNorma
 	if strings.TrimSpace(model) == "" || len(messagesRaw) == 0 {
 		return claudeNormalizedRequest{}, fmt.Errorf("Request must include 'model' and 'messages'.")
 	}
+	stopPolicy := util.StopPolicy{
+		Sequences: util.ParseStopSequences(req["stop_sequences"]),
+		MaxTokens: util.PositiveIntFrom(req["max_tokens"]),
+	}
 	if _, ok := req["max_tokens"]; !ok {
 		req["max_tokens"] = 8192
 	}
-	normalizedMessages := normalizeClaudeMessages(messagesRaw)
+	toolsRequested, _ := req["tools"].([]any)
+	toolChoice, toolCalls, err := parseClaudeToolChoice(req["tool_choice"], toolsRequested)
+	if err != nil {
+		return claudeNormalizedRequest{}, err
+	}
+	normalizedMessages, err := normalizeClaudeMessages(messagesRaw, claudeThinkingHistory{
+		Secret:   store.ThinkingSignatureSecret(),
+		Reinject: store.ThinkingReinjectHistory(),
+	})
+	if err != nil {
+		return claudeNormalizedRequest{}, err
+	}
 	payload := cloneMap(req)
+	if system, ok := req["system"].([]any); ok {
+		payload["system"] = claudeSystemText(system)
+	}
 	payload["messages"] = normalizedMessages
-	toolsRequested, _ := req["tools"].([]any)
-	payload["messages"] = injectClaudeToolPrompt(payload, normalizedMessages, toolsRequested)
+	payload["messages"] = injectClaudeToolPrompt(payload, normalizedMessages, toolsRequested, toolChoice, toolCalls)
 
 	dsPayload := convertClaudeToDeepSeek(payload, store)
 	dsModel, _ := dsPayload["model"].(string)
@@ -36,8 +53,9 @@ func normalizeClaudeRequest(store ConfigReader, req map[string]any) (claudeNorma
 		thinkingEnabled = false
 		searchEnabled = false
 	}
+	thinkingEnabled, stopPolicy = claudeReasoningControl(req["thinking"]).Apply(thinkingEnabled, stopPolicy)
 	finalPrompt := deepseek.MessagesPrepare(toMessageMaps(dsPayload["messages"]))
-	toolNames := extractClaudeToolNames(toolsRequested)
+	toolNames := allowedClaudeToolNames(extractClaudeToolNames(toolsRequested), toolChoice)
 
 	return claudeNormalizedRequest{
 		Standard: util.StandardRequest{
@@ -48,7 +66,10 @@ func normalizeClaudeRequest(store ConfigReader, req map[string]any) (claudeNorma
 			Messages:       payload["messages"].([]any),
 			FinalPrompt:    finalPrompt,
 			ToolNames:      toolNames,
+			ToolChoice:     toolChoice,
+			ToolCalls:      toolCalls,
 			Stream:         util.ToBool(req["stream"]),
+			StopPolicy:     stopPolicy,
 			Thinking:       thinkingEnabled,
 			Search:         searchEnabled,
 		},
@@ -56,11 +77,11 @@ func normalizeClaudeRequest(store ConfigReader, req map[string]any) (claudeNorma
 	}, nil
 }
 
-func injectClaudeToolPrompt(payload map[string]any, normalizedMessages []any, tools []any) []any {
-	if len(tools) == 0 {
+func injectClaudeToolPrompt(payload map[string]any, normalizedMessages []any, tools []any, toolChoice util.ToolChoicePolicy, toolCalls util.ToolCallPolicy) []any {
+	if len(tools) == 0 || toolChoice.IsNone() {
 		return normalizedMessages
 	}
-	toolPrompt := strings.TrimSpace(buildClaudeToolPrompt(tools))
+	toolPrompt := strings.TrimSpace(buildClaudeToolPrompt(tools, toolChoice, toolCalls))
 	if toolPrompt == "" {
 		return normalizedMessages
 	}
@@ -90,6 +111,22 @@ func injectClaudeToolPrompt(payload map[string]any, normalizedMessages []any, to
 	return append([]any{map[string]any{"role": "system", "content": toolPrompt}}, messages...)
 }
 
+// claudeSystemText flattens a system prompt given as content blocks, e.g. to
+// carry cache_control, into the plain text the prompt builder expects.
+func claudeSystemText(blocks []any) string {
+	parts := make([]string, 0, len(blocks))
+	for _, item := range blocks {
+		block, _ := item.(map[string]any)
+		if block == nil || block["type"] != "text" {
+			continue
+		}
+		if text, _ := block["text"].(string); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
 func mergeSystemPrompt(base, extra string) string {
 	base = strings.TrimSpace(base)
 	extra = strings.TrimSpace(extra)
@@ -111,3 +148,89 @@ func cloneAnySlice(in []any) []any {
 	copy(out, in)
 	return out
 }
+
+// claudeReasoningControl maps the Anthropic `thinking` parameter onto the
+// DeepSeek thinking switch; budget_tokens caps the relayed reasoning.
+func claudeReasoningControl(raw any) util.ReasoningControl {
+	thinking, ok := raw.(map[string]any)
+	if !ok {
+		return util.ReasoningControl{}
+	}
+	kind, _ := thinking["type"].(string)
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "enabled":
+		return util.ReasoningControl{
+			Enabled: util.BoolPtr(true),
+			Budget:  util.PositiveIntFrom(thinking["budget_tokens"]),
+		}
+	case "disabled":
+		return util.ReasoningControl{Enabled: util.BoolPtr(false)}
+	default:
+		return util.ReasoningControl{}
+	}
+}
+
+// parseClaudeToolChoice maps Anthropic `tool_choice` onto the shared policy:
+// "any" requires a call, "tool" forces one by name and "none" turns tools
+// off. disable_parallel_tool_use becomes the single-call limit.
+func parseClaudeToolChoice(raw any, tools []any) (util.ToolChoicePolicy, util.ToolCallPolicy, error) {
+	policy := util.DefaultToolChoicePolicy()
+	var calls util.ToolCallPolicy
+	if raw == nil {
+		return policy, calls, nil
+	}
+	choice, ok := raw.(map[string]any)
+	if !ok {
+		return util.ToolChoicePolicy{}, calls, fmt.Errorf("tool_choice must be an object")
+	}
+	declared := extractClaudeToolNames(tools)
+	kind, _ := choice["type"].(string)
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "", "auto":
+	case "any":
+		policy.Mode = util.ToolChoiceRequired
+	case "tool":
+		name, _ := choice["name"].(string)
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return util.ToolChoicePolicy{}, calls, fmt.Errorf("tool_choice.name is required when tool_choice.type is \"tool\"")
+		}
+		policy.Mode = util.ToolChoiceForced
+		policy.ForcedName = name
+		policy.Allowed = map[string]struct{}{name: {}}
+	case "none":
+		policy.Mode = util.ToolChoiceNone
+		return policy, calls, nil
+	default:
+		return util.ToolChoicePolicy{}, calls, fmt.Errorf("Unsupported tool_choice.type: %q", kind)
+	}
+	if policy.IsRequired() && len(declared) == 0 {
+		return util.ToolChoicePolicy{}, calls, fmt.Errorf("tool_choice.type=%s requires non-empty tools.", kind)
+	}
+	if policy.Mode == util.ToolChoiceForced {
+		found := false
+		for _, name := range declared {
+			found = found || name == policy.ForcedName
+		}
+		if !found {
+			return util.ToolChoicePolicy{}, calls, fmt.Errorf("tool_choice tool %q is not declared in tools", policy.ForcedName)
+		}
+	}
+	calls.DisableParallel = util.ToBool(choice["disable_parallel_tool_use"])
+	return policy, calls, nil
+}
+
+// allowedClaudeToolNames narrows the names the tool-call parser accepts so a
+// forced or disabled tool_choice also holds on the way out.
+func allowedClaudeToolNames(names []string, policy util.ToolChoicePolicy) []string {
+	if policy.IsNone() {
+		return nil
+	}
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		if policy.Allows(name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"ds2api/internal/config"
+	"ds2api/internal/util"
 )
 
 func TestNormalizeClaudeRequest(t *testing.T) {
@@ -90,3 +91,84 @@ func TestNormalizeClaudeRequestInjectsToolsIntoTopLevelSystem(t *testing.T) {
 		t.Fatalf("expected tool prompt injected, got=%q", norm.Standard.FinalPrompt)
 	}
 }
+
+func TestNormalizeClaudeRequestThinkingParameter(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{}`)
+	store := config.LoadStore()
+	req := map[string]any{
+		"model":    "claude-sonnet-4-5",
+		"messages": []any{map[string]any{"role": "user", "content": "hello"}},
+		"thinking": map[string]any{"type": "enabled", "budget_tokens": float64(2048)},
+	}
+	norm, err := normalizeClaudeRequest(store, req)
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if !norm.Standard.Thinking || norm.Standard.StopPolicy.ThinkingBudget != 2048 {
+		t.Fatalf("expected enabled thinking with budget, got thinking=%v policy=%#v", norm.Standard.Thinking, norm.Standard.StopPolicy)
+	}
+
+	req = map[string]any{
+		"model":    "claude-opus-4-6",
+		"messages": []any{map[string]any{"role": "user", "content": "hello"}},
+		"thinking": map[string]any{"type": "disabled"},
+	}
+	norm, err = normalizeClaudeRequest(store, req)
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if norm.Standard.Thinking {
+		t.Fatalf("expected thinking disabled regardless of model alias")
+	}
+}
+
+func TestNormalizeClaudeRequestToolChoice(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{}`)
+	store := config.LoadStore()
+	newReq := func(choice map[string]any) map[string]any {
+		return map[string]any{
+			"model":    "claude-sonnet-4-5",
+			"messages": []any{map[string]any{"role": "user", "content": "hello"}},
+			"tools": []any{
+				map[string]any{"name": "search", "description": "Search"},
+				map[string]any{"name": "fetch", "description": "Fetch"},
+			},
+			"tool_choice": choice,
+		}
+	}
+
+	norm, err := normalizeClaudeRequest(store, newReq(map[string]any{"type": "tool", "name": "fetch", "disable_parallel_tool_use": true}))
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	std := norm.Standard
+	if std.ToolChoice.Mode != util.ToolChoiceForced || std.ToolChoice.ForcedName != "fetch" {
+		t.Fatalf("expected forced fetch, got %#v", std.ToolChoice)
+	}
+	if len(std.ToolNames) != 1 || std.ToolNames[0] != "fetch" || !std.ToolCalls.DisableParallel {
+		t.Fatalf("expected only fetch with single-call limit, got names=%v calls=%#v", std.ToolNames, std.ToolCalls)
+	}
+	if !containsStr(std.FinalPrompt, "MUST call exactly this tool: fetch") || containsStr(std.FinalPrompt, "Tool: search") {
+		t.Fatalf("expected forced-tool prompt, got=%q", std.FinalPrompt)
+	}
+
+	norm, err = normalizeClaudeRequest(store, newReq(map[string]any{"type": "any"}))
+	if err != nil || !norm.Standard.ToolChoice.IsRequired() || len(norm.Standard.ToolNames) != 2 {
+		t.Fatalf("expected any to require a call, got %#v err=%v", norm.Standard.ToolChoice, err)
+	}
+
+	norm, err = normalizeClaudeRequest(store, newReq(map[string]any{"type": "none"}))
+	if err != nil || len(norm.Standard.ToolNames) != 0 || containsStr(norm.Standard.FinalPrompt, "You have access to these tools") {
+		t.Fatalf("expected none to drop tools, got names=%v err=%v", norm.Standard.ToolNames, err)
+	}
+
+	for _, bad := range []map[string]any{
+		{"type": "tool", "name": "missing"},
+		{"type": "tool"},
+		{"type": "sometimes"},
+	} {
+		if _, err := normalizeClaudeRequest(store, newReq(bad)); err == nil {
+			t.Fatalf("expected error for tool_choice %#v", bad)
+		}
+	}
+}
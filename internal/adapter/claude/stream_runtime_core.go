@@ -6,8 +6,12 @@ import (
 	"strings"
 	"time"
 
+	"ds2api/internal/adapter/openai"
+	claudefmt "ds2api/internal/format/claude"
+	"ds2api/internal/promptcache"
 	"ds2api/internal/sse"
 	streamengine "ds2api/internal/stream"
+	"ds2api/internal/util"
 )
 
 type claudeStreamRuntime struct {
@@ -15,17 +19,29 @@ type claudeStreamRuntime struct {
 	rc       *http.ResponseController
 	canFlush bool
 
-	model     string
-	toolNames []string
-	messages  []any
+	model      string
+	toolNames  []string
+	toolChoice util.ToolChoicePolicy
+	toolCalls  util.ToolCallPolicy
+	messages   []any
 
 	thinkingEnabled   bool
 	searchEnabled     bool
 	bufferToolContent bool
+	sieve             *openai.ToolSieve
+	toolBlocks        map[int]int
+	emittedCalls      []util.ParsedToolCall
 
-	messageID string
-	thinking  strings.Builder
-	text      strings.Builder
+	messageID     string
+	limiter       *util.OutputLimiter
+	citations     *util.CitationRewriter
+	citationSpans []util.CitationSpan
+	thinking      strings.Builder
+	text          strings.Builder
+
+	thinkingSecret    string
+	cacheUsage        promptcache.Usage
+	thinkingBlockText strings.Builder
 
 	nextBlockIndex     int
 	thinkingBlockOpen  bool
@@ -45,7 +61,23 @@ func newClaudeStreamRuntime(
 	thinkingEnabled bool,
 	searchEnabled bool,
 	toolNames []string,
+	toolChoice util.ToolChoicePolicy,
+	toolCalls util.ToolCallPolicy,
+	stopPolicy util.StopPolicy,
 ) *claudeStreamRuntime {
+	var citations *util.CitationRewriter
+	if searchEnabled {
+		citations = util.NewCitationRewriter()
+	}
+	// Search answers keep buffering tool-bearing text so citation spans stay
+	// aligned; otherwise the sieve streams text and tool input as it arrives.
+	var sieve *openai.ToolSieve
+	if len(toolNames) > 0 && citations == nil {
+		sieve = openai.NewToolSieve(toolNames)
+		if !toolCalls.Active() {
+			sieve.StreamArguments()
+		}
+	}
 	return &claudeStreamRuntime{
 		w:                  w,
 		rc:                 rc,
@@ -54,9 +86,15 @@ func newClaudeStreamRuntime(
 		messages:           messages,
 		thinkingEnabled:    thinkingEnabled,
 		searchEnabled:      searchEnabled,
-		bufferToolContent:  len(toolNames) > 0,
+		bufferToolContent:  len(toolNames) > 0 && sieve == nil,
+		sieve:              sieve,
+		toolBlocks:         map[int]int{},
 		toolNames:          toolNames,
+		toolChoice:         toolChoice,
+		toolCalls:          toolCalls,
 		messageID:          fmt.Sprintf("msg_%d", time.Now().UnixNano()),
+		limiter:            util.NewOutputLimiter(stopPolicy),
+		citations:          citations,
 		thinkingBlockIndex: -1,
 		textBlockIndex:     -1,
 	}
@@ -66,6 +104,9 @@ func (s *claudeStreamRuntime) onParsed(parsed sse.LineResult) streamengine.Parse
 	if !parsed.Parsed {
 		return streamengine.ParsedDecision{}
 	}
+	if parsed.ContentFilter {
+		return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReason(util.OutputFinishContentFilter)}
+	}
 	if parsed.ErrorMessage != "" {
 		s.upstreamErr = parsed.ErrorMessage
 		return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReason("upstream_error")}
@@ -74,73 +115,131 @@ func (s *claudeStreamRuntime) onParsed(parsed sse.LineResult) streamengine.Parse
 		return streamengine.ParsedDecision{Stop: true}
 	}
 
+	if s.citations != nil {
+		s.citations.AddSources(parsed.SearchResults)
+	}
 	contentSeen := false
 	for _, p := range parsed.Parts {
 		if p.Text == "" {
 			continue
 		}
-		if p.Type != "thinking" && s.searchEnabled && sse.IsCitation(p.Text) {
-			continue
-		}
 		contentSeen = true
 
 		if p.Type == "thinking" {
 			if !s.thinkingEnabled {
 				continue
 			}
-			s.thinking.WriteString(p.Text)
-			s.closeTextBlock()
-			if !s.thinkingBlockOpen {
-				s.thinkingBlockIndex = s.nextBlockIndex
-				s.nextBlockIndex++
-				s.send("content_block_start", map[string]any{
-					"type":  "content_block_start",
-					"index": s.thinkingBlockIndex,
-					"content_block": map[string]any{
-						"type":     "thinking",
-						"thinking": "",
-					},
-				})
-				s.thinkingBlockOpen = true
-			}
-			s.send("content_block_delta", map[string]any{
-				"type":  "content_block_delta",
-				"index": s.thinkingBlockIndex,
-				"delta": map[string]any{
-					"type":     "thinking_delta",
-					"thinking": p.Text,
-				},
-			})
-			continue
+			s.emitThinking(s.limiter.PushThinking(p.Text))
+		} else {
+			s.emitText(s.limiter.PushText(p.Text))
+		}
+		if s.limiter.Done() {
+			return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReason(s.limiter.FinishReason()), ContentSeen: contentSeen}
 		}
+	}
 
-		s.text.WriteString(p.Text)
-		if s.bufferToolContent {
+	return streamengine.ParsedDecision{ContentSeen: contentSeen}
+}
+
+func (s *claudeStreamRuntime) emitThinking(text string) {
+	if text == "" {
+		return
+	}
+	s.thinking.WriteString(text)
+	s.thinkingBlockText.WriteString(text)
+	s.closeTextBlock()
+	if !s.thinkingBlockOpen {
+		s.thinkingBlockIndex = s.nextBlockIndex
+		s.nextBlockIndex++
+		s.send("content_block_start", map[string]any{
+			"type":  "content_block_start",
+			"index": s.thinkingBlockIndex,
+			"content_block": map[string]any{
+				"type":     "thinking",
+				"thinking": "",
+			},
+		})
+		s.thinkingBlockOpen = true
+	}
+	s.send("content_block_delta", map[string]any{
+		"type":  "content_block_delta",
+		"index": s.thinkingBlockIndex,
+		"delta": map[string]any{
+			"type":     "thinking_delta",
+			"thinking": text,
+		},
+	})
+}
+
+// emitText rewrites [citation:N] markers for search models; each cited
+// passage ends its text block with citations_delta events.
+func (s *claudeStreamRuntime) emitText(text string) {
+	if s.citations == nil || text == "" {
+		s.emitCitedText(text, nil)
+		return
+	}
+	base := s.citations.Offset()
+	text, spans := s.citations.Push(text)
+	s.citationSpans = append(s.citationSpans, spans...)
+	for i := range spans {
+		spans[i].Start -= base
+		spans[i].End -= base
+	}
+	s.emitCitedText(text, spans)
+}
+
+func (s *claudeStreamRuntime) emitCitedText(text string, spans []util.CitationSpan) {
+	if text == "" {
+		return
+	}
+	s.text.WriteString(text)
+	if s.bufferToolContent {
+		return
+	}
+	if s.sieve != nil {
+		s.emitSieveEvents(s.sieve.Push(text))
+		return
+	}
+	for _, seg := range claudefmt.SplitCitedText(text, spans) {
+		s.emitTextDelta(seg.Text)
+		if len(seg.Citations) == 0 {
 			continue
 		}
-		s.closeThinkingBlock()
-		if !s.textBlockOpen {
-			s.textBlockIndex = s.nextBlockIndex
-			s.nextBlockIndex++
-			s.send("content_block_start", map[string]any{
-				"type":  "content_block_start",
+		for _, citation := range seg.Citations {
+			s.send("content_block_delta", map[string]any{
+				"type":  "content_block_delta",
 				"index": s.textBlockIndex,
-				"content_block": map[string]any{
-					"type": "text",
-					"text": "",
+				"delta": map[string]any{
+					"type":     "citations_delta",
+					"citation": citation,
 				},
 			})
-			s.textBlockOpen = true
 		}
-		s.send("content_block_delta", map[string]any{
-			"type":  "content_block_delta",
+		s.closeTextBlock()
+	}
+}
+
+func (s *claudeStreamRuntime) emitTextDelta(text string) {
+	s.closeThinkingBlock()
+	if !s.textBlockOpen {
+		s.textBlockIndex = s.nextBlockIndex
+		s.nextBlockIndex++
+		s.send("content_block_start", map[string]any{
+			"type":  "content_block_start",
 			"index": s.textBlockIndex,
-			"delta": map[string]any{
-				"type": "text_delta",
-				"text": p.Text,
+			"content_block": map[string]any{
+				"type": "text",
+				"text": "",
 			},
 		})
+		s.textBlockOpen = true
 	}
-
-	return streamengine.ParsedDecision{ContentSeen: contentSeen}
+	s.send("content_block_delta", map[string]any{
+		"type":  "content_block_delta",
+		"index": s.textBlockIndex,
+		"delta": map[string]any{
+			"type": "text_delta",
+			"text": text,
+		},
+	})
 }
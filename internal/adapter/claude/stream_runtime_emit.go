@@ -2,10 +2,9 @@ package claude
 
 import (
 	"encoding/json"
-	"fmt"
 	"strings"
 
-	"ds2api/internal/util"
+	claudefmt "ds2api/internal/format/claude"
 )
 
 func (s *claudeStreamRuntime) send(event string, v any) {
@@ -26,12 +25,16 @@ func (s *claudeStreamRuntime) sendError(message string) {
 	if msg == "" {
 		msg = "upstream stream error"
 	}
+	s.sendErrorWithCode(msg, "api_error", "internal_error")
+}
+
+func (s *claudeStreamRuntime) sendErrorWithCode(message, errType, code string) {
 	s.send("error", map[string]any{
 		"type": "error",
 		"error": map[string]any{
-			"type":    "api_error",
-			"message": msg,
-			"code":    "internal_error",
+			"type":    errType,
+			"message": message,
+			"code":    code,
 			"param":   nil,
 		},
 	})
@@ -42,7 +45,8 @@ func (s *claudeStreamRuntime) sendPing() {
 }
 
 func (s *claudeStreamRuntime) sendMessageStart() {
-	inputTokens := util.EstimateTokens(fmt.Sprintf("%v", s.messages))
+	usage := map[string]any{"input_tokens": claudefmt.EstimateInputTokens(s.messages), "output_tokens": 0}
+	claudefmt.ApplyPromptCacheUsage(usage, s.cacheUsage)
 	s.send("message_start", map[string]any{
 		"type": "message_start",
 		"message": map[string]any{
@@ -53,7 +57,7 @@ func (s *claudeStreamRuntime) sendMessageStart() {
 			"content":       []any{},
 			"stop_reason":   nil,
 			"stop_sequence": nil,
-			"usage":         map[string]any{"input_tokens": inputTokens, "output_tokens": 0},
+			"usage":         usage,
 		},
 	})
 }
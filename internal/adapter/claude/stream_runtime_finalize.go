@@ -1,17 +1,26 @@
 package claude
 
 import (
-	"fmt"
-	"time"
-
+	claudefmt "ds2api/internal/format/claude"
 	streamengine "ds2api/internal/stream"
 	"ds2api/internal/util"
 )
 
+// closeThinkingBlock signs the finished block with a signature_delta so the
+// client can send it back in a later turn.
 func (s *claudeStreamRuntime) closeThinkingBlock() {
 	if !s.thinkingBlockOpen {
 		return
 	}
+	s.send("content_block_delta", map[string]any{
+		"type":  "content_block_delta",
+		"index": s.thinkingBlockIndex,
+		"delta": map[string]any{
+			"type":      "signature_delta",
+			"signature": claudefmt.SignThinking(s.thinkingSecret, s.thinkingBlockText.String()),
+		},
+	})
+	s.thinkingBlockText.Reset()
 	s.send("content_block_stop", map[string]any{
 		"type":  "content_block_stop",
 		"index": s.thinkingBlockIndex,
@@ -38,70 +47,95 @@ func (s *claudeStreamRuntime) finalize(stopReason string) {
 	}
 	s.ended = true
 
+	s.emitText(s.limiter.Flush())
+	if s.citations != nil {
+		s.emitCitedText(s.citations.Flush(), nil)
+	}
+	var stopSequence any
+	switch s.limiter.FinishReason() {
+	case util.OutputFinishStopSequence:
+		stopReason = "stop_sequence"
+		stopSequence = s.limiter.MatchedSequence()
+	case util.OutputFinishMaxTokens:
+		stopReason = "max_tokens"
+	}
+
 	s.closeThinkingBlock()
 	s.closeTextBlock()
 
 	finalThinking := s.thinking.String()
 	finalText := s.text.String()
 
+	if s.sieve != nil {
+		s.emitSieveEvents(s.sieve.Flush())
+		s.closeToolBlocks()
+		s.closeTextBlock()
+		if s.toolChoice.IsRequired() && len(s.emittedCalls) == 0 && stopReason != "refusal" {
+			s.sendErrorWithCode(claudeToolChoiceViolation, "invalid_request_error", "tool_choice_violation")
+			return
+		}
+		if len(s.emittedCalls) > 0 {
+			stopReason = "tool_use"
+			stopSequence = nil
+		}
+	}
+
 	if s.bufferToolContent {
-		detected := util.ParseToolCalls(finalText, s.toolNames)
+		detected := s.toolCalls.Check(util.ParseToolCalls(finalText, s.toolNames)).Calls
+		if s.toolChoice.IsRequired() && len(detected) == 0 && stopReason != "refusal" {
+			s.sendErrorWithCode(claudeToolChoiceViolation, "invalid_request_error", "tool_choice_violation")
+			return
+		}
 		if len(detected) > 0 {
 			stopReason = "tool_use"
-			for i, tc := range detected {
-				idx := s.nextBlockIndex + i
+			stopSequence = nil
+			for _, tc := range detected {
+				s.sendToolUseBlock(tc)
+			}
+		} else if finalText != "" {
+			for _, seg := range claudefmt.SplitCitedText(finalText, s.citationSpans) {
+				idx := s.nextBlockIndex
+				s.nextBlockIndex++
 				s.send("content_block_start", map[string]any{
 					"type":  "content_block_start",
 					"index": idx,
 					"content_block": map[string]any{
-						"type":  "tool_use",
-						"id":    fmt.Sprintf("toolu_%d_%d", time.Now().Unix(), idx),
-						"name":  tc.Name,
-						"input": tc.Input,
+						"type": "text",
+						"text": "",
 					},
 				})
-				s.send("content_block_stop", map[string]any{
-					"type":  "content_block_stop",
+				s.send("content_block_delta", map[string]any{
+					"type":  "content_block_delta",
 					"index": idx,
+					"delta": map[string]any{
+						"type": "text_delta",
+						"text": seg.Text,
+					},
 				})
+				for _, citation := range seg.Citations {
+					s.send("content_block_delta", map[string]any{
+						"type":  "content_block_delta",
+						"index": idx,
+						"delta": map[string]any{
+							"type":     "citations_delta",
+							"citation": citation,
+						},
+					})
+				}
+				s.sendBlockStop(idx)
 			}
-			s.nextBlockIndex += len(detected)
-		} else if finalText != "" {
-			idx := s.nextBlockIndex
-			s.nextBlockIndex++
-			s.send("content_block_start", map[string]any{
-				"type":  "content_block_start",
-				"index": idx,
-				"content_block": map[string]any{
-					"type": "text",
-					"text": "",
-				},
-			})
-			s.send("content_block_delta", map[string]any{
-				"type":  "content_block_delta",
-				"index": idx,
-				"delta": map[string]any{
-					"type": "text_delta",
-					"text": finalText,
-				},
-			})
-			s.send("content_block_stop", map[string]any{
-				"type":  "content_block_stop",
-				"index": idx,
-			})
 		}
 	}
 
-	outputTokens := util.EstimateTokens(finalThinking) + util.EstimateTokens(finalText)
+	usage := claudefmt.BuildMessageUsage(s.messages, finalThinking, finalText)
+	claudefmt.ApplyPromptCacheUsage(usage, s.cacheUsage)
 	s.send("message_delta", map[string]any{
 		"type": "message_delta",
 		"delta": map[string]any{
 			"stop_reason":   stopReason,
-			"stop_sequence": nil,
-		},
-		"usage": map[string]any{
-			"output_tokens": outputTokens,
+			"stop_sequence": stopSequence,
 		},
+		"usage": usage,
 	})
 	s.send("message_stop", map[string]any{"type": "message_stop"})
 }
@@ -115,5 +149,9 @@ func (s *claudeStreamRuntime) onFinalize(reason streamengine.StopReason, scanner
 		s.sendError(scannerErr.Error())
 		return
 	}
+	if string(reason) == util.OutputFinishContentFilter {
+		s.finalize("refusal")
+		return
+	}
 	s.finalize("end_turn")
 }
@@ -0,0 +1,114 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"ds2api/internal/adapter/openai"
+	"ds2api/internal/util"
+)
+
+// emitSieveEvents relays sieved text as text deltas and tool calls as
+// tool_use blocks whose input arrives through input_json_delta events.
+func (s *claudeStreamRuntime) emitSieveEvents(events []openai.ToolSieveEvent) {
+	for _, evt := range events {
+		if evt.Content != "" {
+			s.closeToolBlocks()
+			s.emitTextDelta(evt.Content)
+		}
+		for _, d := range evt.ArgDeltas {
+			if d.Name != "" {
+				s.toolBlocks[d.Index] = s.startToolUseBlock(d.Name)
+			}
+			if idx, ok := s.toolBlocks[d.Index]; ok && d.Arguments != "" {
+				s.sendInputJSONDelta(idx, d.Arguments)
+			}
+		}
+		if len(evt.ToolCalls) > 0 {
+			s.emitToolCalls(evt.ToolCalls)
+		}
+	}
+}
+
+// emitToolCalls completes the calls of one tool_calls array: blocks already
+// streaming are closed, the rest are sent whole.
+func (s *claudeStreamRuntime) emitToolCalls(calls []util.ParsedToolCall) {
+	calls = s.toolCalls.Check(calls).Calls
+	if s.toolCalls.DisableParallel && len(s.emittedCalls) > 0 {
+		calls = nil
+	}
+	for i, tc := range calls {
+		if idx, ok := s.toolBlocks[i]; ok {
+			s.sendBlockStop(idx)
+			delete(s.toolBlocks, i)
+		} else {
+			s.sendToolUseBlock(tc)
+		}
+		s.emittedCalls = append(s.emittedCalls, tc)
+	}
+	s.closeToolBlocks()
+}
+
+func (s *claudeStreamRuntime) startToolUseBlock(name string) int {
+	s.closeThinkingBlock()
+	s.closeTextBlock()
+	idx := s.nextBlockIndex
+	s.nextBlockIndex++
+	s.send("content_block_start", map[string]any{
+		"type":  "content_block_start",
+		"index": idx,
+		"content_block": map[string]any{
+			"type":  "tool_use",
+			"id":    fmt.Sprintf("toolu_%d_%d", time.Now().Unix(), idx),
+			"name":  name,
+			"input": map[string]any{},
+		},
+	})
+	return idx
+}
+
+// sendToolUseBlock emits a complete call the way Anthropic does: an empty
+// input on content_block_start followed by a single input_json_delta.
+func (s *claudeStreamRuntime) sendToolUseBlock(tc util.ParsedToolCall) {
+	idx := s.startToolUseBlock(tc.Name)
+	input, _ := json.Marshal(tc.Input)
+	s.sendInputJSONDelta(idx, string(input))
+	s.sendBlockStop(idx)
+}
+
+func (s *claudeStreamRuntime) sendInputJSONDelta(idx int, partial string) {
+	s.send("content_block_delta", map[string]any{
+		"type":  "content_block_delta",
+		"index": idx,
+		"delta": map[string]any{
+			"type":         "input_json_delta",
+			"partial_json": partial,
+		},
+	})
+}
+
+func (s *claudeStreamRuntime) sendBlockStop(idx int) {
+	s.send("content_block_stop", map[string]any{
+		"type":  "content_block_stop",
+		"index": idx,
+	})
+}
+
+// closeToolBlocks ends streamed blocks the final parse did not confirm, e.g.
+// when the capture turned out not to be a tool call after all.
+func (s *claudeStreamRuntime) closeToolBlocks() {
+	if len(s.toolBlocks) == 0 {
+		return
+	}
+	indexes := make([]int, 0, len(s.toolBlocks))
+	for _, idx := range s.toolBlocks {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+	for _, idx := range indexes {
+		s.sendBlockStop(idx)
+	}
+	s.toolBlocks = map[int]int{}
+}
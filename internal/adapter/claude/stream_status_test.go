@@ -60,6 +60,10 @@ func (streamStatusClaudeStoreStub) ClaudeMapping() map[string]string {
 	}
 }
 
+func (streamStatusClaudeStoreStub) ThinkingSignatureSecret() string { return "test-secret" }
+
+func (streamStatusClaudeStoreStub) ThinkingReinjectHistory() bool { return false }
+
 func captureClaudeStatusMiddleware(statuses *[]int) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
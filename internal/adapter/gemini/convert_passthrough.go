@@ -3,6 +3,8 @@ package gemini
 import (
 	"encoding/json"
 	"strings"
+
+	"ds2api/internal/util"
 )
 
 func collectGeminiPassThrough(req map[string]any) map[string]any {
@@ -29,6 +31,41 @@ func collectGeminiPassThrough(req map[string]any) map[string]any {
 	return out
 }
 
+func geminiStopPolicy(req map[string]any) util.StopPolicy {
+	cfg, _ := req["generationConfig"].(map[string]any)
+	return util.StopPolicy{
+		Sequences: util.ParseStopSequences(cfg["stopSequences"]),
+		MaxTokens: util.PositiveIntFrom(cfg["maxOutputTokens"]),
+	}
+}
+
+// geminiReasoningControl reads generationConfig.thinkingConfig: a
+// thinkingBudget of 0 disables thinking, -1 leaves it uncapped, and
+// includeThoughts decides whether reasoning is returned.
+func geminiReasoningControl(req map[string]any) util.ReasoningControl {
+	cfg, _ := req["generationConfig"].(map[string]any)
+	thinking, _ := cfg["thinkingConfig"].(map[string]any)
+	ctrl := util.ReasoningControl{}
+	if raw, ok := thinking["thinkingBudget"]; ok && raw != nil {
+		budget := util.IntFrom(raw)
+		ctrl.Enabled = util.BoolPtr(budget != 0)
+		if budget > 0 {
+			ctrl.Budget = budget
+		}
+	}
+	if raw, ok := thinking["includeThoughts"]; ok && raw != nil {
+		ctrl.Hide = util.BoolPtr(!util.ToBool(raw))
+	}
+	return ctrl
+}
+
+// geminiIncludeThoughts reports whether the request asked for thought parts.
+func geminiIncludeThoughts(req map[string]any) bool {
+	cfg, _ := req["generationConfig"].(map[string]any)
+	thinking, _ := cfg["thinkingConfig"].(map[string]any)
+	return util.ToBool(thinking["includeThoughts"])
+}
+
 func asString(v any) string {
 	s, _ := v.(string)
 	return s
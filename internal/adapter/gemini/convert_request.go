@@ -27,8 +27,16 @@ func normalizeGeminiRequest(store ConfigReader, routeModel string, req map[strin
 	}
 
 	toolsRaw := convertGeminiTools(req["tools"])
-	finalPrompt, toolNames := openai.BuildPromptForAdapter(messagesRaw, toolsRaw, "")
+	toolChoice, err := parseGeminiToolConfig(req["toolConfig"], toolsRaw)
+	if err != nil {
+		return util.StandardRequest{}, err
+	}
+	if _, err := parseGeminiSafetySettings(req["safetySettings"]); err != nil {
+		return util.StandardRequest{}, err
+	}
+	finalPrompt, toolNames := openai.BuildPromptForAdapterWithPolicy(messagesRaw, toolsRaw, "", toolChoice)
 	passThrough := collectGeminiPassThrough(req)
+	thinkingEnabled, stopPolicy := geminiReasoningControl(req).Apply(thinkingEnabled, geminiStopPolicy(req))
 
 	return util.StandardRequest{
 		Surface:        "google_gemini",
@@ -38,7 +46,9 @@ func normalizeGeminiRequest(store ConfigReader, routeModel string, req map[strin
 		Messages:       messagesRaw,
 		FinalPrompt:    finalPrompt,
 		ToolNames:      toolNames,
+		ToolChoice:     toolChoice,
 		Stream:         stream,
+		StopPolicy:     stopPolicy,
 		Thinking:       thinkingEnabled,
 		Search:         searchEnabled,
 		PassThrough:    passThrough,
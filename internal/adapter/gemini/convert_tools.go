@@ -1,6 +1,11 @@
 package gemini
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+
+	"ds2api/internal/util"
+)
 
 func convertGeminiTools(raw any) []any {
 	tools, _ := raw.([]any)
@@ -69,3 +74,66 @@ func convertGeminiTools(raw any) []any {
 	}
 	return out
 }
+
+// parseGeminiToolConfig maps toolConfig.functionCallingConfig onto a tool
+// choice policy. ANY requires a call (forcing it when only one function is
+// allowed), NONE hides the tools, and VALIDATED behaves like AUTO because
+// parsed calls are always checked against the declared names.
+func parseGeminiToolConfig(raw any, tools []any) (util.ToolChoicePolicy, error) {
+	policy := util.DefaultToolChoicePolicy()
+	declared := map[string]struct{}{}
+	for _, item := range tools {
+		tool, _ := item.(map[string]any)
+		fn, _ := tool["function"].(map[string]any)
+		if name := strings.TrimSpace(asString(fn["name"])); name != "" {
+			declared[name] = struct{}{}
+		}
+	}
+	if len(declared) > 0 {
+		policy.Allowed = declared
+	}
+
+	toolConfig, _ := raw.(map[string]any)
+	cfg, _ := toolConfig["functionCallingConfig"].(map[string]any)
+	if cfg == nil {
+		return policy, nil
+	}
+	mode := strings.ToUpper(strings.TrimSpace(asString(cfg["mode"])))
+	switch mode {
+	case "", "MODE_UNSPECIFIED", "AUTO", "VALIDATED":
+		policy.Mode = util.ToolChoiceAuto
+	case "ANY":
+		policy.Mode = util.ToolChoiceRequired
+	case "NONE":
+		return util.ToolChoicePolicy{Mode: util.ToolChoiceNone}, nil
+	default:
+		return util.ToolChoicePolicy{}, fmt.Errorf("Invalid value at 'tool_config.function_calling_config.mode' (%s)", asString(cfg["mode"]))
+	}
+
+	if rawNames, ok := cfg["allowedFunctionNames"].([]any); ok && len(rawNames) > 0 {
+		if mode != "ANY" && mode != "VALIDATED" {
+			return util.ToolChoicePolicy{}, fmt.Errorf("allowed_function_names is only allowed when function calling mode is ANY or VALIDATED.")
+		}
+		allowed := map[string]struct{}{}
+		for _, item := range rawNames {
+			name := strings.TrimSpace(asString(item))
+			if _, ok := declared[name]; !ok {
+				return util.ToolChoicePolicy{}, fmt.Errorf("allowed_function_names contains undeclared function %q.", name)
+			}
+			allowed[name] = struct{}{}
+		}
+		policy.Allowed = allowed
+	}
+	if policy.Mode == util.ToolChoiceRequired {
+		if len(declared) == 0 {
+			return util.ToolChoicePolicy{}, fmt.Errorf("Function calling mode ANY requires at least one function declaration.")
+		}
+		if len(policy.Allowed) == 1 {
+			for name := range policy.Allowed {
+				policy.Mode = util.ToolChoiceForced
+				policy.ForcedName = name
+			}
+		}
+	}
+	return policy, nil
+}
@@ -0,0 +1,110 @@
+package gemini
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/util"
+)
+
+const geminiTwoTools = `[{"functionDeclarations":[
+	{"name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}},
+	{"name":"get_time","parameters":{"type":"object"}}
+]}]`
+
+func geminiToolConfigRequest(t *testing.T, toolConfig string) map[string]any {
+	t.Helper()
+	var req map[string]any
+	body := `{"contents":[{"role":"user","parts":[{"text":"weather in Paris?"}]}],"tools":` + geminiTwoTools
+	if toolConfig != "" {
+		body += `,"toolConfig":` + toolConfig
+	}
+	if err := json.Unmarshal([]byte(body+"}"), &req); err != nil {
+		t.Fatalf("bad fixture: %v", err)
+	}
+	return req
+}
+
+func TestParseGeminiToolConfigModes(t *testing.T) {
+	cases := []struct {
+		config string
+		mode   util.ToolChoiceMode
+		forced string
+		names  int
+	}{
+		{``, util.ToolChoiceAuto, "", 2},
+		{`{"functionCallingConfig":{"mode":"AUTO"}}`, util.ToolChoiceAuto, "", 2},
+		{`{"functionCallingConfig":{"mode":"ANY"}}`, util.ToolChoiceRequired, "", 2},
+		{`{"functionCallingConfig":{"mode":"ANY","allowedFunctionNames":["get_time"]}}`, util.ToolChoiceForced, "get_time", 1},
+		{`{"functionCallingConfig":{"mode":"VALIDATED","allowedFunctionNames":["get_weather"]}}`, util.ToolChoiceAuto, "", 1},
+		{`{"functionCallingConfig":{"mode":"NONE"}}`, util.ToolChoiceNone, "", 0},
+	}
+	for _, tc := range cases {
+		stdReq, err := normalizeGeminiRequest(testGeminiConfig{}, "gemini-2.5-pro", geminiToolConfigRequest(t, tc.config), false)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.config, err)
+		}
+		if stdReq.ToolChoice.Mode != tc.mode || stdReq.ToolChoice.ForcedName != tc.forced || len(stdReq.ToolNames) != tc.names {
+			t.Fatalf("%s: got policy %#v names %v", tc.config, stdReq.ToolChoice, stdReq.ToolNames)
+		}
+	}
+
+	for _, bad := range []string{
+		`{"functionCallingConfig":{"mode":"SOMETIMES"}}`,
+		`{"functionCallingConfig":{"mode":"AUTO","allowedFunctionNames":["get_time"]}}`,
+		`{"functionCallingConfig":{"mode":"ANY","allowedFunctionNames":["unknown"]}}`,
+	} {
+		if _, err := normalizeGeminiRequest(testGeminiConfig{}, "gemini-2.5-pro", geminiToolConfigRequest(t, bad), false); err == nil {
+			t.Fatalf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestGeminiAnyModeReportsMalformedFunctionCall(t *testing.T) {
+	toolConfig := `{"functionCallingConfig":{"mode":"ANY","allowedFunctionNames":["get_weather"]}}`
+	outputs := []string{
+		`data: {"p":"response/content","v":"It is sunny in Paris."}`,
+		`data: {"p":"response/content","v":"{\"tool_calls\":[{\"name\":\"get_time\",\"input\":{}}]}"}`,
+	}
+	for _, output := range outputs {
+		for _, stream := range []bool{false, true} {
+			h := &Handler{Store: testGeminiConfig{}, Auth: testGeminiAuth{}, DS: testGeminiDS{resp: makeGeminiUpstreamResponse(output, `data: [DONE]`)}}
+			r := chi.NewRouter()
+			RegisterRoutes(r, h)
+			body, _ := json.Marshal(geminiToolConfigRequest(t, toolConfig))
+			path := "/v1beta/models/gemini-2.5-pro:generateContent"
+			if stream {
+				path = "/v1beta/models/gemini-2.5-pro:streamGenerateContent"
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(body))))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+			}
+			got := rec.Body.String()
+			if !strings.Contains(got, `"finishReason":"MALFORMED_FUNCTION_CALL"`) || strings.Contains(got, "functionCall\"") || strings.Contains(got, "sunny") {
+				t.Fatalf("stream=%v: expected MALFORMED_FUNCTION_CALL without content, got %s", stream, got)
+			}
+		}
+	}
+}
+
+func TestGeminiNoneModeHidesTools(t *testing.T) {
+	stdReq, err := normalizeGeminiRequest(testGeminiConfig{}, "gemini-2.5-pro", geminiToolConfigRequest(t, `{"functionCallingConfig":{"mode":"NONE"}}`), false)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if strings.Contains(stdReq.FinalPrompt, "get_weather") || len(stdReq.ToolNames) != 0 {
+		t.Fatalf("expected tools to be hidden from the prompt, got %q", stdReq.FinalPrompt)
+	}
+	out := buildGeminiGenerateContentResponse("gemini-2.5-pro", stdReq.FinalPrompt, "", `{"tool_calls":[{"name":"get_weather","input":{}}]}`, stdReq.ToolNames, stdReq.ToolChoice, false, "", "", nil)
+	parts := out["candidates"].([]map[string]any)[0]["content"].(map[string]any)["parts"].([]map[string]any)
+	if _, ok := parts[0]["functionCall"]; ok {
+		t.Fatalf("NONE mode must not return function calls: %#v", parts)
+	}
+}
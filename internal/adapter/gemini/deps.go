@@ -11,6 +11,7 @@ import (
 
 type AuthResolver interface {
 	Determine(req *http.Request) (*auth.RequestAuth, error)
+	DetermineCaller(req *http.Request) (*auth.RequestAuth, error)
 	Release(a *auth.RequestAuth)
 }
 
@@ -22,6 +23,7 @@ type DeepSeekCaller interface {
 
 type ConfigReader interface {
 	ModelAliases() map[string]string
+	EmbeddingsSettings() config.EmbeddingsConfig
 }
 
 var _ AuthResolver = (*auth.Resolver)(nil)
@@ -0,0 +1,55 @@
+package gemini
+
+import "ds2api/internal/util"
+
+// buildGeminiGroundingMetadata reports search citations the way Gemini's
+// Google Search grounding does: one chunk per cited source and one support
+// per cited passage. Segment indices are byte offsets into text.
+func buildGeminiGroundingMetadata(text string, spans []util.CitationSpan) map[string]any {
+	if len(spans) == 0 {
+		return nil
+	}
+	chunks := make([]map[string]any, 0, len(spans))
+	chunkIndex := map[int]int{}
+	for _, span := range spans {
+		if _, ok := chunkIndex[span.Source.Index]; ok {
+			continue
+		}
+		chunkIndex[span.Source.Index] = len(chunks)
+		chunks = append(chunks, map[string]any{
+			"web": map[string]any{
+				"uri":   span.Source.URL,
+				"title": span.Source.Title,
+			},
+		})
+	}
+
+	supports := make([]map[string]any, 0, len(spans))
+	for i := 0; i < len(spans); {
+		j := i
+		for j+1 < len(spans) && spans[j+1].SegmentStart == spans[i].SegmentStart {
+			j++
+		}
+		segText, start, end := util.CitationSegment(text, spans[i])
+		indices := make([]int, 0, j-i+1)
+		for _, span := range spans[i : j+1] {
+			indices = append(indices, chunkIndex[span.Source.Index])
+		}
+		i = j + 1
+		if segText == "" {
+			continue
+		}
+		supports = append(supports, map[string]any{
+			"segment": map[string]any{
+				"startIndex": start,
+				"endIndex":   end,
+				"text":       segText,
+			},
+			"groundingChunkIndices": indices,
+		})
+	}
+	return map[string]any{
+		"groundingChunks":   chunks,
+		"groundingSupports": supports,
+	}
+}
@@ -0,0 +1,69 @@
+package gemini
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ds2api/internal/util"
+)
+
+var geminiSearchLines = []string{
+	`data: {"p":"response/search_results","v":[{"url":"https://go.dev","title":"Go","cite_index":1},{"url":"https://rust-lang.org","title":"Rust","cite_index":2}]}`,
+	`data: {"p":"response/content","v":"Go is simple[citation:1][citation:2].\nRust is safe[citation:2]."}`,
+	`data: [DONE]`,
+}
+
+func TestNonStreamGenerateContentReportsGroundingMetadata(t *testing.T) {
+	h := &Handler{}
+	rec := httptest.NewRecorder()
+	h.handleNonStreamGenerateContent(rec, makeGeminiUpstreamResponse(geminiSearchLines...), "gemini-2.5-pro", "prompt", false, true, false, nil, util.ToolChoicePolicy{}, nil, util.StopPolicy{})
+
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response failed: %v body=%s", err, rec.Body.String())
+	}
+	candidate := out["candidates"].([]any)[0].(map[string]any)
+	text := candidate["content"].(map[string]any)["parts"].([]any)[0].(map[string]any)["text"]
+	if text != "Go is simple[1][2].\nRust is safe[2]." {
+		t.Fatalf("unexpected text %q", text)
+	}
+	grounding, _ := candidate["groundingMetadata"].(map[string]any)
+	chunks, _ := grounding["groundingChunks"].([]any)
+	supports, _ := grounding["groundingSupports"].([]any)
+	if len(chunks) != 2 || len(supports) != 2 {
+		t.Fatalf("unexpected grounding %#v", grounding)
+	}
+	first := supports[0].(map[string]any)
+	segment := first["segment"].(map[string]any)
+	if segment["text"] != "Go is simple" || segment["startIndex"] != float64(0) || segment["endIndex"] != float64(12) {
+		t.Fatalf("unexpected first segment %#v", segment)
+	}
+	if idx := first["groundingChunkIndices"].([]any); len(idx) != 2 {
+		t.Fatalf("expected both sources on first support, got %#v", first)
+	}
+	second := supports[1].(map[string]any)["segment"].(map[string]any)
+	if second["text"] != "Rust is safe" {
+		t.Fatalf("unexpected second segment %#v", second)
+	}
+}
+
+func TestStreamGenerateContentSendsGroundingOnFinalChunk(t *testing.T) {
+	h := &Handler{}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-2.5-pro:streamGenerateContent", nil)
+	h.handleStreamGenerateContent(rec, req, makeGeminiUpstreamResponse(geminiSearchLines...), "gemini-2.5-pro", "prompt", false, true, false, nil, util.ToolChoicePolicy{}, nil, util.StopPolicy{})
+
+	frames := extractGeminiSSEFrames(t, rec.Body.String())
+	last := frames[len(frames)-1]
+	candidate := last["candidates"].([]any)[0].(map[string]any)
+	if _, ok := candidate["groundingMetadata"]; !ok {
+		t.Fatalf("expected groundingMetadata on final chunk, got %#v", last)
+	}
+	for _, frame := range frames[:len(frames)-1] {
+		if _, ok := frame["candidates"].([]any)[0].(map[string]any)["groundingMetadata"]; ok {
+			t.Fatalf("grounding should only travel with the final chunk: %#v", frame)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package gemini
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/embeddings"
+)
+
+const geminiEmbeddingMaxDimensions = 8192
+
+// geminiEmbedRequest is one EmbedContentRequest reduced to what the
+// embeddings providers understand. title is not used: providers embed plain
+// text only.
+type geminiEmbedRequest struct {
+	model      string
+	input      string
+	taskType   string
+	dimensions int
+}
+
+func (h *Handler) EmbedContent(w http.ResponseWriter, r *http.Request) {
+	var req map[string]any
+	if !h.decodeEmbedRequest(w, r, &req) {
+		return
+	}
+	item, err := parseGeminiEmbedRequest(req, chi.URLParam(r, "model"), "")
+	if err != nil {
+		writeGeminiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	vectors, err := h.embedGemini(r, []geminiEmbedRequest{item})
+	if err != nil {
+		writeGeminiEmbeddingsError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"embedding": map[string]any{"values": vectors[0]}})
+}
+
+func (h *Handler) BatchEmbedContents(w http.ResponseWriter, r *http.Request) {
+	var req map[string]any
+	if !h.decodeEmbedRequest(w, r, &req) {
+		return
+	}
+	rawItems, _ := req["requests"].([]any)
+	if len(rawItems) == 0 {
+		writeGeminiError(w, http.StatusBadRequest, "Request must include non-empty requests.")
+		return
+	}
+	routeModel := chi.URLParam(r, "model")
+	items := make([]geminiEmbedRequest, 0, len(rawItems))
+	for i, raw := range rawItems {
+		itemReq, _ := raw.(map[string]any)
+		item, err := parseGeminiEmbedRequest(itemReq, routeModel, fmt.Sprintf("requests[%d].", i))
+		if err != nil {
+			writeGeminiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		items = append(items, item)
+	}
+	vectors, err := h.embedGemini(r, items)
+	if err != nil {
+		writeGeminiEmbeddingsError(w, err)
+		return
+	}
+	out := make([]any, 0, len(vectors))
+	for _, vec := range vectors {
+		out = append(out, map[string]any{"values": vec})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"embeddings": out})
+}
+
+// decodeEmbedRequest authenticates the caller without binding a pooled
+// account (embeddings never reach DeepSeek) and decodes the body.
+func (h *Handler) decodeEmbedRequest(w http.ResponseWriter, r *http.Request, req *map[string]any) bool {
+	if _, err := h.Auth.DetermineCaller(r); err != nil {
+		writeGeminiAuthError(w, err)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeGeminiError(w, http.StatusBadRequest, "invalid json")
+		return false
+	}
+	return true
+}
+
+func parseGeminiEmbedRequest(req map[string]any, routeModel, path string) (geminiEmbedRequest, error) {
+	model := strings.TrimSpace(routeModel)
+	if m, _ := req["model"].(string); strings.TrimSpace(m) != "" {
+		model = strings.TrimPrefix(strings.TrimSpace(m), "models/")
+	}
+	if model == "" {
+		return geminiEmbedRequest{}, fmt.Errorf("model is required in request path")
+	}
+	content, _ := req["content"].(map[string]any)
+	input := geminiEmbedText(content)
+	if input == "" {
+		return geminiEmbedRequest{}, fmt.Errorf("%scontent must include at least one text part.", path)
+	}
+	taskType, _ := req["taskType"].(string)
+	dims := 0
+	if raw, ok := req["outputDimensionality"]; ok && raw != nil {
+		n, ok := raw.(float64)
+		if !ok || n != float64(int(n)) || n < 1 || n > geminiEmbeddingMaxDimensions {
+			return geminiEmbedRequest{}, fmt.Errorf("%soutputDimensionality must be an integer between 1 and %d.", path, geminiEmbeddingMaxDimensions)
+		}
+		dims = int(n)
+	}
+	return geminiEmbedRequest{model: model, input: input, taskType: strings.TrimSpace(taskType), dimensions: dims}, nil
+}
+
+// geminiEmbedText joins the text parts of a Content; Gemini embeds a whole
+// Content as a single vector.
+func geminiEmbedText(content map[string]any) string {
+	parts, _ := content["parts"].([]any)
+	texts := make([]string, 0, len(parts))
+	for _, item := range parts {
+		part, _ := item.(map[string]any)
+		if text := strings.TrimSpace(asString(part["text"])); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// embedGemini runs the configured embeddings provider, issuing one provider
+// call per distinct model / taskType / dimensions combination and returning
+// vectors in request order.
+func (h *Handler) embedGemini(r *http.Request, items []geminiEmbedRequest) ([][]float64, error) {
+	provider, err := embeddings.New(h.Store.EmbeddingsSettings())
+	if err != nil {
+		return nil, err
+	}
+	type groupKey struct {
+		model      string
+		taskType   string
+		dimensions int
+	}
+	groups := map[groupKey][]int{}
+	order := make([]groupKey, 0, 1)
+	for i, item := range items {
+		key := groupKey{item.model, item.taskType, item.dimensions}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+	vectors := make([][]float64, len(items))
+	for _, key := range order {
+		indices := groups[key]
+		inputs := make([]string, 0, len(indices))
+		for _, i := range indices {
+			inputs = append(inputs, items[i].input)
+		}
+		result, err := provider.Embed(r.Context(), embeddings.Request{Model: key.model, Inputs: inputs, Dimensions: key.dimensions, TaskType: key.taskType})
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range indices {
+			vectors[i] = result.Vectors[j]
+		}
+	}
+	return vectors, nil
+}
+
+func writeGeminiEmbeddingsError(w http.ResponseWriter, err error) {
+	var perr *embeddings.Error
+	if errors.As(err, &perr) {
+		writeGeminiError(w, perr.Status, perr.Message)
+		return
+	}
+	writeGeminiError(w, http.StatusBadGateway, err.Error())
+}
@@ -0,0 +1,89 @@
+package gemini
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/config"
+)
+
+type embedGeminiConfig struct {
+	testGeminiConfig
+	provider string
+}
+
+func (c embedGeminiConfig) EmbeddingsSettings() config.EmbeddingsConfig {
+	return config.EmbeddingsConfig{Provider: c.provider}
+}
+
+func doGeminiEmbed(t *testing.T, r chi.Router, path, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("x-goog-api-key", "direct-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	var out map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	return rec.Code, out
+}
+
+func TestGeminiEmbedContent(t *testing.T) {
+	r := chi.NewRouter()
+	RegisterRoutes(r, &Handler{Store: embedGeminiConfig{provider: "local"}, Auth: callerOnlyGeminiAuth{}})
+
+	code, out := doGeminiEmbed(t, r, "/v1beta/models/text-embedding-004:embedContent",
+		`{"content":{"parts":[{"text":"hello"},{"text":"world"}]},"taskType":"RETRIEVAL_QUERY","outputDimensionality":64}`)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %#v", code, out)
+	}
+	embedding, _ := out["embedding"].(map[string]any)
+	if values, _ := embedding["values"].([]any); len(values) != 64 {
+		t.Fatalf("expected 64 values, got %#v", out)
+	}
+
+	code, out = doGeminiEmbed(t, r, "/v1beta/models/text-embedding-004:embedContent", `{"content":{"parts":[]}}`)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty content, got %d %#v", code, out)
+	}
+	code, _ = doGeminiEmbed(t, r, "/v1beta/models/text-embedding-004:embedContent", `{"content":{"parts":[{"text":"x"}]},"outputDimensionality":0}`)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid outputDimensionality, got %d", code)
+	}
+}
+
+func TestGeminiBatchEmbedContentsKeepsOrder(t *testing.T) {
+	r := chi.NewRouter()
+	RegisterRoutes(r, &Handler{Store: embedGeminiConfig{provider: "local"}, Auth: callerOnlyGeminiAuth{}})
+
+	body := `{"requests":[
+		{"model":"models/text-embedding-004","content":{"parts":[{"text":"first"}]},"outputDimensionality":32},
+		{"model":"models/text-embedding-004","content":{"parts":[{"text":"second"}]}},
+		{"model":"models/text-embedding-004","content":{"parts":[{"text":"third"}]},"outputDimensionality":32}
+	]}`
+	code, out := doGeminiEmbed(t, r, "/v1beta/models/text-embedding-004:batchEmbedContents", body)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %#v", code, out)
+	}
+	items, _ := out["embeddings"].([]any)
+	if len(items) != 3 {
+		t.Fatalf("expected 3 embeddings, got %#v", out)
+	}
+	for i, want := range []int{32, 256, 32} {
+		values, _ := items[i].(map[string]any)["values"].([]any)
+		if len(values) != want {
+			t.Fatalf("embedding %d: expected %d values, got %d", i, want, len(values))
+		}
+	}
+
+	r = chi.NewRouter()
+	RegisterRoutes(r, &Handler{Store: testGeminiConfig{}, Auth: callerOnlyGeminiAuth{}})
+	code, out = doGeminiEmbed(t, r, "/v1beta/models/text-embedding-004:batchEmbedContents", body)
+	if code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 without a provider, got %d %#v", code, out)
+	}
+}
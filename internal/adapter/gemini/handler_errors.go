@@ -1,6 +1,12 @@
 package gemini
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ds2api/internal/auth"
+)
 
 func writeGeminiError(w http.ResponseWriter, status int, message string) {
 	errorStatus := "INVALID_ARGUMENT"
@@ -26,3 +32,39 @@ func writeGeminiError(w http.ResponseWriter, status int, message string) {
 		},
 	})
 }
+
+// writeGeminiAuthError reports a failed Auth.Determine: 429 when the key is
+// over its rate limits or monthly quota or no account is free, 403 for a key
+// scope violation, otherwise 401. Expired keys carry an ErrorInfo reason like
+// Google's own API_KEY_* errors.
+func writeGeminiAuthError(w http.ResponseWriter, err error) {
+	var limited *auth.RateLimitError
+	var quota *auth.QuotaError
+	switch {
+	case errors.As(err, &limited):
+		limited.Decision.WriteRejection(w.Header())
+		writeGeminiError(w, http.StatusTooManyRequests, err.Error())
+	case errors.As(err, &quota):
+		w.Header().Set("Retry-After", strconv.Itoa(quota.RetryAfterSeconds()))
+		writeGeminiError(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, auth.ErrNoAccount):
+		writeGeminiError(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, auth.ErrForbidden):
+		writeGeminiError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, auth.ErrAPIKeyExpired):
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"error": map[string]any{
+				"code":    http.StatusUnauthorized,
+				"message": err.Error(),
+				"status":  "UNAUTHENTICATED",
+				"details": []map[string]any{{
+					"@type":  "type.googleapis.com/google.rpc.ErrorInfo",
+					"reason": "API_KEY_EXPIRED",
+					"domain": "ds2api",
+				}},
+			},
+		})
+	default:
+		writeGeminiError(w, http.StatusUnauthorized, err.Error())
+	}
+}
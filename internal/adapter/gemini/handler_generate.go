@@ -8,7 +8,6 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
-	"ds2api/internal/auth"
 	"ds2api/internal/sse"
 	"ds2api/internal/util"
 )
@@ -16,12 +15,7 @@ import (
 func (h *Handler) handleGenerateContent(w http.ResponseWriter, r *http.Request, stream bool) {
 	a, err := h.Auth.Determine(r)
 	if err != nil {
-		status := http.StatusUnauthorized
-		detail := err.Error()
-		if err == auth.ErrNoAccount {
-			status = http.StatusTooManyRequests
-		}
-		writeGeminiError(w, status, detail)
+		writeGeminiAuthError(w, err)
 		return
 	}
 	defer h.Auth.Release(a)
@@ -61,13 +55,13 @@ func (h *Handler) handleGenerateContent(w http.ResponseWriter, r *http.Request,
 	}
 
 	if stream {
-		h.handleStreamGenerateContent(w, r, resp, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.Search, stdReq.ToolNames)
+		h.handleStreamGenerateContent(w, r, resp, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.Search, geminiIncludeThoughts(req), stdReq.ToolNames, stdReq.ToolChoice, geminiSafetyCategories(req), stdReq.StopPolicy)
 		return
 	}
-	h.handleNonStreamGenerateContent(w, resp, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.ToolNames)
+	h.handleNonStreamGenerateContent(w, resp, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.Search, geminiIncludeThoughts(req), stdReq.ToolNames, stdReq.ToolChoice, geminiSafetyCategories(req), stdReq.StopPolicy)
 }
 
-func (h *Handler) handleNonStreamGenerateContent(w http.ResponseWriter, resp *http.Response, model, finalPrompt string, thinkingEnabled bool, toolNames []string) {
+func (h *Handler) handleNonStreamGenerateContent(w http.ResponseWriter, resp *http.Response, model, finalPrompt string, thinkingEnabled, searchEnabled, includeThoughts bool, toolNames []string, toolChoice util.ToolChoicePolicy, safety []string, stopPolicy util.StopPolicy) {
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
@@ -75,41 +69,87 @@ func (h *Handler) handleNonStreamGenerateContent(w http.ResponseWriter, resp *ht
 		return
 	}
 
-	result := sse.CollectStream(resp, thinkingEnabled, true)
-	writeJSON(w, http.StatusOK, buildGeminiGenerateContentResponse(model, finalPrompt, result.Thinking, result.Text, toolNames))
+	result := sse.CollectStreamWithPolicy(resp, thinkingEnabled, true, stopPolicy)
+	if result.FinishReason == util.OutputFinishContentFilter && strings.TrimSpace(result.Text) == "" && strings.TrimSpace(result.Thinking) == "" {
+		writeJSON(w, http.StatusOK, geminiBlockedResponse(model, finalPrompt))
+		return
+	}
+	finalText := result.Text
+	var citations []util.CitationSpan
+	if searchEnabled {
+		finalText, citations = util.ResolveCitations(finalText, result.SearchResults)
+	}
+	out := buildGeminiGenerateContentResponse(model, finalPrompt, result.Thinking, finalText, toolNames, toolChoice, includeThoughts, result.FinishReason, result.ErrorMessage, safety)
+	candidate := out["candidates"].([]map[string]any)[0]
+	if grounding := buildGeminiGroundingMetadata(finalText, citations); grounding != nil && candidate["content"] != nil {
+		candidate["groundingMetadata"] = grounding
+	}
+	writeJSON(w, http.StatusOK, out)
 }
 
-func buildGeminiGenerateContentResponse(model, finalPrompt, finalThinking, finalText string, toolNames []string) map[string]any {
-	parts := buildGeminiPartsFromFinal(finalText, finalThinking, toolNames)
-	usage := buildGeminiUsage(finalPrompt, finalThinking, finalText)
-	return map[string]any{
-		"candidates": []map[string]any{
-			{
-				"index": 0,
-				"content": map[string]any{
-					"role":  "model",
-					"parts": parts,
-				},
-				"finishReason": "STOP",
-			},
+func buildGeminiGenerateContentResponse(model, finalPrompt, finalThinking, finalText string, toolNames []string, toolChoice util.ToolChoicePolicy, includeThoughts bool, outputFinish, errorMessage string, safety []string) map[string]any {
+	parts := buildGeminiPartsFromFinal(finalText, finalThinking, toolNames, includeThoughts)
+	candidate := map[string]any{
+		"index": 0,
+		"content": map[string]any{
+			"role":  "model",
+			"parts": parts,
 		},
+	}
+	applyGeminiFinish(candidate, outputFinish, errorMessage, safety)
+	if outputFinish != util.OutputFinishContentFilter && geminiMissingRequiredCall(toolChoice, parts) {
+		candidate = geminiMalformedCallCandidate()
+	}
+	return map[string]any{
+		"candidates":    []map[string]any{candidate},
 		"modelVersion":  model,
-		"usageMetadata": usage,
+		"usageMetadata": buildGeminiUsage(finalPrompt, finalThinking, finalText),
 	}
 }
 
-func buildGeminiUsage(finalPrompt, finalThinking, finalText string) map[string]any {
-	promptTokens := util.EstimateTokens(finalPrompt)
-	reasoningTokens := util.EstimateTokens(finalThinking)
-	completionTokens := util.EstimateTokens(finalText)
+// geminiMissingRequiredCall reports a mode ANY answer that produced no valid
+// call to an allowed function.
+func geminiMissingRequiredCall(toolChoice util.ToolChoicePolicy, parts []map[string]any) bool {
+	if !toolChoice.IsRequired() {
+		return false
+	}
+	for _, part := range parts {
+		if _, ok := part["functionCall"]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// geminiMalformedCallCandidate mirrors Gemini, which returns no content when
+// a forced function call cannot be produced.
+func geminiMalformedCallCandidate() map[string]any {
 	return map[string]any{
+		"index":         0,
+		"finishReason":  "MALFORMED_FUNCTION_CALL",
+		"finishMessage": "Malformed function call: the model did not call an allowed function.",
+	}
+}
+
+func buildGeminiUsage(finalPrompt, finalThinking, finalText string) map[string]any {
+	promptTokens := util.CountTokens(finalPrompt)
+	reasoningTokens := util.CountTokens(finalThinking)
+	completionTokens := util.CountTokens(finalText)
+	usage := map[string]any{
 		"promptTokenCount":     promptTokens,
 		"candidatesTokenCount": reasoningTokens + completionTokens,
 		"totalTokenCount":      promptTokens + reasoningTokens + completionTokens,
 	}
+	if reasoningTokens > 0 {
+		usage["thoughtsTokenCount"] = reasoningTokens
+	}
+	return usage
 }
 
-func buildGeminiPartsFromFinal(finalText, finalThinking string, toolNames []string) []map[string]any {
+// buildGeminiPartsFromFinal renders the buffered answer. With includeThoughts
+// the reasoning leads as a `thought` part; otherwise it only stands in for an
+// empty answer.
+func buildGeminiPartsFromFinal(finalText, finalThinking string, toolNames []string, includeThoughts bool) []map[string]any {
 	detected := util.ParseToolCalls(finalText, toolNames)
 	if len(detected) == 0 && strings.TrimSpace(finalThinking) != "" {
 		detected = util.ParseToolCalls(finalThinking, toolNames)
@@ -127,6 +167,12 @@ func buildGeminiPartsFromFinal(finalText, finalThinking string, toolNames []stri
 		return parts
 	}
 
+	if includeThoughts && finalThinking != "" {
+		return []map[string]any{
+			{"text": finalThinking, "thought": true},
+			{"text": finalText},
+		}
+	}
 	text := finalText
 	if strings.TrimSpace(text) == "" {
 		text = finalThinking
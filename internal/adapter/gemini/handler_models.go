@@ -0,0 +1,109 @@
+package gemini
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/config"
+	"ds2api/internal/embeddings"
+)
+
+// DeepSeek serves a 128K context window; chat replies are capped at 8K tokens
+// and reasoner replies (thinking included) at 64K.
+const (
+	geminiInputTokenLimit          = 131072
+	geminiChatOutputTokenLimit     = 8192
+	geminiReasonerOutputTokenLimit = 65536
+)
+
+var geminiGenerationMethods = []string{"generateContent", "streamGenerateContent", "countTokens"}
+
+var geminiEmbeddingMethods = []string{"embedContent", "batchEmbedContents"}
+
+// ListModels returns the native DeepSeek models followed by every gemini-*
+// alias, so SDKs that validate a configured Gemini model name find it.
+func (h *Handler) ListModels(w http.ResponseWriter, _ *http.Request) {
+	methods := h.geminiMethods()
+	models := make([]any, 0, len(config.DeepSeekModels))
+	for _, m := range config.DeepSeekModels {
+		models = append(models, geminiModelObject(m.ID, m.ID, methods))
+	}
+	for _, alias := range h.geminiAliases() {
+		if resolved, ok := config.ResolveModel(h.Store, alias); ok {
+			models = append(models, geminiModelObject(alias, resolved, methods))
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"models": models})
+}
+
+func (h *Handler) GetModel(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(chi.URLParam(r, "model"))
+	resolved, ok := config.ResolveModel(h.Store, name)
+	if !ok {
+		writeGeminiError(w, http.StatusNotFound, "models/"+name+" is not found for API version v1beta, or is not supported for generateContent.")
+		return
+	}
+	writeJSON(w, http.StatusOK, geminiModelObject(name, resolved, h.geminiMethods()))
+}
+
+// geminiMethods lists the methods every model supports. A configured
+// embeddings provider embeds under any model name, so with one all models
+// serve embedContent and batchEmbedContents too.
+func (h *Handler) geminiMethods() []string {
+	if h.Store == nil {
+		return geminiGenerationMethods
+	}
+	if _, err := embeddings.New(h.Store.EmbeddingsSettings()); err != nil {
+		return geminiGenerationMethods
+	}
+	methods := make([]string, 0, len(geminiGenerationMethods)+len(geminiEmbeddingMethods))
+	return append(append(methods, geminiGenerationMethods...), geminiEmbeddingMethods...)
+}
+
+func (h *Handler) geminiAliases() []string {
+	aliases := config.DefaultModelAliases()
+	if h.Store != nil {
+		for k, v := range h.Store.ModelAliases() {
+			aliases[k] = v
+		}
+	}
+	out := make([]string, 0, len(aliases))
+	for alias := range aliases {
+		if strings.HasPrefix(strings.ToLower(alias), "gemini-") {
+			out = append(out, alias)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// geminiModelObject describes name in the google.ai.generativelanguage Model
+// shape, with limits and flags taken from the DeepSeek model it resolves to.
+func geminiModelObject(name, resolved string, methods []string) map[string]any {
+	thinking, search, _ := config.GetModelConfig(resolved)
+	outputLimit := geminiChatOutputTokenLimit
+	if thinking {
+		outputLimit = geminiReasonerOutputTokenLimit
+	}
+	description := "DeepSeek " + resolved + " served through DS2API."
+	if search {
+		description = "DeepSeek " + resolved + " with web search, served through DS2API."
+	}
+	return map[string]any{
+		"name":                       "models/" + name,
+		"baseModelId":                resolved,
+		"version":                    "001",
+		"displayName":                name,
+		"description":                description,
+		"inputTokenLimit":            geminiInputTokenLimit,
+		"outputTokenLimit":           outputLimit,
+		"supportedGenerationMethods": methods,
+		"temperature":                1.0,
+		"maxTemperature":             2.0,
+		"topP":                       0.95,
+		"thinking":                   thinking,
+	}
+}
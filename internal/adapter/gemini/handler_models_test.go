@@ -0,0 +1,127 @@
+package gemini
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/util"
+)
+
+// callerOnlyGeminiAuth fails Determine so any attempt to bind a pooled
+// account surfaces as an error.
+type callerOnlyGeminiAuth struct{ testGeminiAuth }
+
+func (callerOnlyGeminiAuth) Determine(_ *http.Request) (*auth.RequestAuth, error) {
+	return nil, auth.ErrNoAccount
+}
+
+func (callerOnlyGeminiAuth) DetermineCaller(_ *http.Request) (*auth.RequestAuth, error) {
+	return &auth.RequestAuth{CallerID: "caller:test", TriedAccounts: map[string]bool{}}, nil
+}
+
+func TestGeminiModelDiscovery(t *testing.T) {
+	r := chi.NewRouter()
+	RegisterRoutes(r, &Handler{Store: testGeminiConfig{}, Auth: testGeminiAuth{}})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1beta/models", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	var list struct {
+		Models []map[string]any `json:"models"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &list)
+	names := map[string]map[string]any{}
+	for _, m := range list.Models {
+		names[m["name"].(string)] = m
+	}
+	reasoner, ok := names["models/deepseek-reasoner"]
+	if !ok || reasoner["thinking"] != true || reasoner["outputTokenLimit"] != float64(geminiReasonerOutputTokenLimit) {
+		t.Fatalf("unexpected reasoner entry: %#v", reasoner)
+	}
+	if alias, ok := names["models/gemini-2.5-pro"]; !ok || alias["baseModelId"] != "deepseek-chat" || alias["thinking"] != false {
+		t.Fatalf("expected gemini alias entry, got %#v", alias)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1beta/models/gemini-2.5-flash", nil))
+	var model map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &model)
+	methods, _ := model["supportedGenerationMethods"].([]any)
+	if rec.Code != http.StatusOK || model["name"] != "models/gemini-2.5-flash" || len(methods) == 0 || model["inputTokenLimit"] != float64(geminiInputTokenLimit) {
+		t.Fatalf("unexpected model object: %d %#v", rec.Code, model)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1beta/models/unknown-model", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "NOT_FOUND") {
+		t.Fatalf("expected NOT_FOUND, got %d body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGeminiCountTokensMatchesPromptWithoutAccount(t *testing.T) {
+	r := chi.NewRouter()
+	h := &Handler{Store: testGeminiConfig{}, Auth: callerOnlyGeminiAuth{}}
+	RegisterRoutes(r, h)
+
+	reqBody := map[string]any{
+		"systemInstruction": map[string]any{"parts": []any{map[string]any{"text": "Be brief."}}},
+		"contents":          []any{map[string]any{"role": "user", "parts": []any{map[string]any{"text": "How many tokens is this?"}}}},
+	}
+	stdReq, err := normalizeGeminiRequest(h.Store, "gemini-2.5-pro", reqBody, false)
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	want := float64(util.CountTokens(stdReq.FinalPrompt))
+
+	for _, body := range []any{reqBody, map[string]any{"generateContentRequest": reqBody}} {
+		raw, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-2.5-pro:countTokens", strings.NewReader(string(raw)))
+		req.Header.Set("x-goog-api-key", "direct-token")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+		}
+		var out map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		if out["totalTokens"] != want || want <= 0 {
+			t.Fatalf("expected totalTokens=%v, got %#v", want, out)
+		}
+	}
+}
+
+func TestGeminiModelsListEmbeddingMethodsWithProvider(t *testing.T) {
+	for _, tc := range []struct {
+		provider string
+		embeds   bool
+	}{{"", false}, {"local", true}} {
+		r := chi.NewRouter()
+		RegisterRoutes(r, &Handler{Store: embedGeminiConfig{provider: tc.provider}, Auth: testGeminiAuth{}})
+		for _, path := range []string{"/v1beta/models", "/v1beta/models/gemini-2.5-flash"} {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			var out struct {
+				Models  []map[string]any `json:"models"`
+				Methods []string         `json:"supportedGenerationMethods"`
+			}
+			_ = json.Unmarshal(rec.Body.Bytes(), &out)
+			methods := out.Methods
+			if len(out.Models) > 0 {
+				raw, _ := json.Marshal(out.Models[0]["supportedGenerationMethods"])
+				_ = json.Unmarshal(raw, &methods)
+			}
+			joined := strings.Join(methods, ",")
+			if !strings.Contains(joined, "generateContent") || strings.Contains(joined, "embedContent,batchEmbedContents") != tc.embeds {
+				t.Fatalf("provider %q, %s: unexpected methods %v", tc.provider, path, methods)
+			}
+		}
+	}
+}
@@ -17,8 +17,18 @@ type Handler struct {
 }
 
 func RegisterRoutes(r chi.Router, h *Handler) {
+	// GET /v1/models belongs to the OpenAI surface, so model discovery is only
+	// exposed under v1beta, which is what the GenAI SDKs call.
+	r.Get("/v1beta/models", h.ListModels)
+	r.Get("/v1beta/models/{model}", h.GetModel)
+	r.Post("/v1beta/models/{model}:countTokens", h.CountTokens)
+	r.Post("/v1beta/models/{model}:embedContent", h.EmbedContent)
+	r.Post("/v1beta/models/{model}:batchEmbedContents", h.BatchEmbedContents)
 	r.Post("/v1beta/models/{model}:generateContent", h.GenerateContent)
 	r.Post("/v1beta/models/{model}:streamGenerateContent", h.StreamGenerateContent)
+	r.Post("/v1/models/{model}:countTokens", h.CountTokens)
+	r.Post("/v1/models/{model}:embedContent", h.EmbedContent)
+	r.Post("/v1/models/{model}:batchEmbedContents", h.BatchEmbedContents)
 	r.Post("/v1/models/{model}:generateContent", h.GenerateContent)
 	r.Post("/v1/models/{model}:streamGenerateContent", h.StreamGenerateContent)
 }
@@ -10,9 +10,10 @@ import (
 	"ds2api/internal/deepseek"
 	"ds2api/internal/sse"
 	streamengine "ds2api/internal/stream"
+	"ds2api/internal/util"
 )
 
-func (h *Handler) handleStreamGenerateContent(w http.ResponseWriter, r *http.Request, resp *http.Response, model, finalPrompt string, thinkingEnabled, searchEnabled bool, toolNames []string) {
+func (h *Handler) handleStreamGenerateContent(w http.ResponseWriter, r *http.Request, resp *http.Response, model, finalPrompt string, thinkingEnabled, searchEnabled, includeThoughts bool, toolNames []string, toolChoice util.ToolChoicePolicy, safety []string, stopPolicy util.StopPolicy) {
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
@@ -27,7 +28,7 @@ func (h *Handler) handleStreamGenerateContent(w http.ResponseWriter, r *http.Req
 
 	rc := http.NewResponseController(w)
 	_, canFlush := w.(http.Flusher)
-	runtime := newGeminiStreamRuntime(w, rc, canFlush, model, finalPrompt, thinkingEnabled, searchEnabled, toolNames)
+	runtime := newGeminiStreamRuntime(w, rc, canFlush, model, finalPrompt, thinkingEnabled, searchEnabled, includeThoughts, toolNames, toolChoice, safety, stopPolicy)
 
 	initialType := "text"
 	if thinkingEnabled {
@@ -59,11 +60,21 @@ type geminiStreamRuntime struct {
 
 	thinkingEnabled bool
 	searchEnabled   bool
+	includeThoughts bool
 	bufferContent   bool
 	toolNames       []string
+	toolChoice      util.ToolChoicePolicy
+	safety          []string
 
-	thinking strings.Builder
-	text     strings.Builder
+	limiter       *util.OutputLimiter
+	citations     *util.CitationRewriter
+	citationSpans []util.CitationSpan
+	thinking      strings.Builder
+	text          strings.Builder
+	// upstreamFinish records a content filter or upstream error that ended
+	// the stream; errorMessage carries the latter's message.
+	upstreamFinish string
+	errorMessage   string
 }
 
 func newGeminiStreamRuntime(
@@ -74,8 +85,16 @@ func newGeminiStreamRuntime(
 	finalPrompt string,
 	thinkingEnabled bool,
 	searchEnabled bool,
+	includeThoughts bool,
 	toolNames []string,
+	toolChoice util.ToolChoicePolicy,
+	safety []string,
+	stopPolicy util.StopPolicy,
 ) *geminiStreamRuntime {
+	var citations *util.CitationRewriter
+	if searchEnabled {
+		citations = util.NewCitationRewriter()
+	}
 	return &geminiStreamRuntime{
 		w:               w,
 		rc:              rc,
@@ -84,8 +103,13 @@ func newGeminiStreamRuntime(
 		finalPrompt:     finalPrompt,
 		thinkingEnabled: thinkingEnabled,
 		searchEnabled:   searchEnabled,
+		includeThoughts: includeThoughts,
 		bufferContent:   len(toolNames) > 0,
 		toolNames:       toolNames,
+		toolChoice:      toolChoice,
+		safety:          safety,
+		limiter:         util.NewOutputLimiter(stopPolicy),
+		citations:       citations,
 	}
 }
 
@@ -103,51 +127,124 @@ func (s *geminiStreamRuntime) onParsed(parsed sse.LineResult) streamengine.Parse
 	if !parsed.Parsed {
 		return streamengine.ParsedDecision{}
 	}
-	if parsed.ContentFilter || parsed.ErrorMessage != "" || parsed.Stop {
+	if parsed.ContentFilter {
+		s.upstreamFinish = util.OutputFinishContentFilter
+		return streamengine.ParsedDecision{Stop: true}
+	}
+	if parsed.ErrorMessage != "" {
+		s.upstreamFinish, s.errorMessage = util.OutputFinishUpstreamError, parsed.ErrorMessage
+		return streamengine.ParsedDecision{Stop: true}
+	}
+	if parsed.Stop {
 		return streamengine.ParsedDecision{Stop: true}
 	}
 
+	if s.citations != nil {
+		s.citations.AddSources(parsed.SearchResults)
+	}
 	contentSeen := false
 	for _, p := range parsed.Parts {
 		if p.Text == "" {
 			continue
 		}
-		if p.Type != "thinking" && s.searchEnabled && sse.IsCitation(p.Text) {
-			continue
-		}
 		contentSeen = true
 		if p.Type == "thinking" {
 			if s.thinkingEnabled {
-				s.thinking.WriteString(p.Text)
+				s.emitThought(s.limiter.PushThinking(p.Text))
 			}
-			continue
+		} else {
+			s.emitText(s.limiter.PushText(p.Text))
 		}
-		s.text.WriteString(p.Text)
-		if s.bufferContent {
-			continue
+		if s.limiter.Done() {
+			return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReason(s.limiter.FinishReason()), ContentSeen: contentSeen}
 		}
-		s.sendChunk(map[string]any{
-			"candidates": []map[string]any{
-				{
-					"index": 0,
-					"content": map[string]any{
-						"role":  "model",
-						"parts": []map[string]any{{"text": p.Text}},
-					},
+	}
+	return streamengine.ParsedDecision{ContentSeen: contentSeen}
+}
+
+// emitText rewrites [citation:N] markers for search models; the grounding
+// metadata for the whole answer travels with the final chunk.
+func (s *geminiStreamRuntime) emitText(text string) {
+	if s.citations != nil && text != "" {
+		var spans []util.CitationSpan
+		text, spans = s.citations.Push(text)
+		s.citationSpans = append(s.citationSpans, spans...)
+	}
+	s.emitRewrittenText(text)
+}
+
+func (s *geminiStreamRuntime) emitThought(text string) {
+	if text == "" {
+		return
+	}
+	s.thinking.WriteString(text)
+	if !s.includeThoughts || s.bufferContent {
+		return
+	}
+	s.sendChunk(map[string]any{
+		"candidates": []map[string]any{
+			{
+				"index": 0,
+				"content": map[string]any{
+					"role":  "model",
+					"parts": []map[string]any{{"text": text, "thought": true}},
 				},
 			},
-			"modelVersion": s.model,
-		})
+		},
+		"modelVersion": s.model,
+	})
+}
+
+func (s *geminiStreamRuntime) emitRewrittenText(text string) {
+	if text == "" {
+		return
 	}
-	return streamengine.ParsedDecision{ContentSeen: contentSeen}
+	s.text.WriteString(text)
+	if s.bufferContent {
+		return
+	}
+	s.sendChunk(map[string]any{
+		"candidates": []map[string]any{
+			{
+				"index": 0,
+				"content": map[string]any{
+					"role":  "model",
+					"parts": []map[string]any{{"text": text}},
+				},
+			},
+		},
+		"modelVersion": s.model,
+	})
 }
 
 func (s *geminiStreamRuntime) finalize() {
+	// Text held back by the limiter or citation rewriter is dropped once the
+	// upstream filter fires; what was already streamed cannot be recalled.
+	blocked := s.upstreamFinish == util.OutputFinishContentFilter
+	if !blocked {
+		s.emitText(s.limiter.Flush())
+		if s.citations != nil {
+			s.emitRewrittenText(s.citations.Flush())
+		}
+	}
 	finalThinking := s.thinking.String()
 	finalText := s.text.String()
 
-	if s.bufferContent {
-		parts := buildGeminiPartsFromFinal(finalText, finalThinking, s.toolNames)
+	if blocked && strings.TrimSpace(finalText) == "" && strings.TrimSpace(finalThinking) == "" {
+		s.sendChunk(geminiBlockedResponse(s.model, s.finalPrompt))
+		return
+	}
+
+	if s.bufferContent && !blocked {
+		parts := buildGeminiPartsFromFinal(finalText, finalThinking, s.toolNames, s.includeThoughts)
+		if geminiMissingRequiredCall(s.toolChoice, parts) {
+			s.sendChunk(map[string]any{
+				"candidates":    []map[string]any{geminiMalformedCallCandidate()},
+				"modelVersion":  s.model,
+				"usageMetadata": buildGeminiUsage(s.finalPrompt, finalThinking, finalText),
+			})
+			return
+		}
 		s.sendChunk(map[string]any{
 			"candidates": []map[string]any{
 				{
@@ -162,19 +259,25 @@ func (s *geminiStreamRuntime) finalize() {
 		})
 	}
 
-	s.sendChunk(map[string]any{
-		"candidates": []map[string]any{
-			{
-				"index": 0,
-				"content": map[string]any{
-					"role": "model",
-					"parts": []map[string]any{
-						{"text": ""},
-					},
-				},
-				"finishReason": "STOP",
+	candidate := map[string]any{
+		"index": 0,
+		"content": map[string]any{
+			"role": "model",
+			"parts": []map[string]any{
+				{"text": ""},
 			},
 		},
+	}
+	outputFinish := s.limiter.FinishReason()
+	if outputFinish == util.OutputFinishNone {
+		outputFinish = s.upstreamFinish
+	}
+	applyGeminiFinish(candidate, outputFinish, s.errorMessage, s.safety)
+	if grounding := buildGeminiGroundingMetadata(finalText, s.citationSpans); grounding != nil && !blocked {
+		candidate["groundingMetadata"] = grounding
+	}
+	s.sendChunk(map[string]any{
+		"candidates":    []map[string]any{candidate},
 		"modelVersion":  s.model,
 		"usageMetadata": buildGeminiUsage(s.finalPrompt, finalThinking, finalText),
 	})
@@ -13,12 +13,17 @@ import (
 	"github.com/go-chi/chi/v5"
 
 	"ds2api/internal/auth"
+	"ds2api/internal/config"
 )
 
 type testGeminiConfig struct{}
 
 func (testGeminiConfig) ModelAliases() map[string]string { return nil }
 
+func (testGeminiConfig) EmbeddingsSettings() config.EmbeddingsConfig {
+	return config.EmbeddingsConfig{}
+}
+
 type testGeminiAuth struct {
 	a   *auth.RequestAuth
 	err error
@@ -39,6 +44,10 @@ func (m testGeminiAuth) Determine(_ *http.Request) (*auth.RequestAuth, error) {
 	}, nil
 }
 
+func (m testGeminiAuth) DetermineCaller(r *http.Request) (*auth.RequestAuth, error) {
+	return m.Determine(r)
+}
+
 func (testGeminiAuth) Release(_ *auth.RequestAuth) {}
 
 type testGeminiDS struct {
@@ -214,3 +223,79 @@ func extractGeminiSSEFrames(t *testing.T, body string) []map[string]any {
 	}
 	return out
 }
+
+func TestStreamGenerateContentEnforcesMaxOutputTokens(t *testing.T) {
+	upstream := makeGeminiUpstreamResponse(
+		`data: {"p":"response/content","v":"`+strings.Repeat("word ", 40)+`"}`,
+		`data: [DONE]`,
+	)
+	h := &Handler{
+		Store: testGeminiConfig{},
+		Auth:  testGeminiAuth{},
+		DS:    testGeminiDS{resp: upstream},
+	}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+
+	body := `{"contents":[{"role":"user","parts":[{"text":"hello"}]}],"generationConfig":{"maxOutputTokens":5}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/models/gemini-2.5-pro:streamGenerateContent?alt=sse", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if !strings.Contains(rec.Body.String(), `"finishReason":"MAX_TOKENS"`) {
+		t.Fatalf("expected MAX_TOKENS finish reason, got body=%s", rec.Body.String())
+	}
+	frames := extractGeminiSSEFrames(t, rec.Body.String())
+	last := frames[len(frames)-1]
+	usage, _ := last["usageMetadata"].(map[string]any)
+	if n, _ := usage["candidatesTokenCount"].(float64); n > 5 {
+		t.Fatalf("expected candidates within budget, got %#v", usage)
+	}
+}
+
+func TestGenerateContentIncludeThoughtsHonoursThinkingBudget(t *testing.T) {
+	upstream := makeGeminiUpstreamResponse(
+		`data: {"p":"response/thinking_content","v":"`+strings.Repeat("think ", 40)+`"}`,
+		`data: {"p":"response/content","v":"answer"}`,
+		`data: [DONE]`,
+	)
+	h := &Handler{
+		Store: testGeminiConfig{},
+		Auth:  testGeminiAuth{},
+		DS:    testGeminiDS{resp: upstream},
+	}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+
+	body := `{"contents":[{"role":"user","parts":[{"text":"hello"}]}],"generationConfig":{"thinkingConfig":{"thinkingBudget":4,"includeThoughts":true}}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-2.5-flash:generateContent", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	candidates, _ := out["candidates"].([]any)
+	candidate, _ := candidates[0].(map[string]any)
+	content, _ := candidate["content"].(map[string]any)
+	parts, _ := content["parts"].([]any)
+	if len(parts) != 2 {
+		t.Fatalf("expected thought and answer parts, got %#v", parts)
+	}
+	thought, _ := parts[0].(map[string]any)
+	if thought["thought"] != true {
+		t.Fatalf("expected leading thought part, got %#v", thought)
+	}
+	if text, _ := thought["text"].(string); text == "" || len(text) > 20 {
+		t.Fatalf("expected thought cut at the thinking budget, got %q", text)
+	}
+	if answer, _ := parts[1].(map[string]any); answer["text"] != "answer" {
+		t.Fatalf("expected answer part, got %#v", parts[1])
+	}
+}
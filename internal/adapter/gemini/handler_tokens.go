@@ -0,0 +1,45 @@
+package gemini
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/util"
+)
+
+// CountTokens measures the prompt generateContent would send, so totalTokens
+// matches usageMetadata.promptTokenCount. It never binds a pooled account.
+func (h *Handler) CountTokens(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.Auth.DetermineCaller(r); err != nil {
+		writeGeminiAuthError(w, err)
+		return
+	}
+
+	var req map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeGeminiError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	// Clients either send contents directly or wrap a whole request in
+	// generateContentRequest to include systemInstruction and tools.
+	if inner, ok := req["generateContentRequest"].(map[string]any); ok {
+		req = inner
+	}
+
+	routeModel := strings.TrimSpace(chi.URLParam(r, "model"))
+	stdReq, err := normalizeGeminiRequest(h.Store, routeModel, req, false)
+	if err != nil {
+		writeGeminiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	total := util.CountTokens(stdReq.FinalPrompt)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"totalTokens": total,
+		"promptTokensDetails": []any{
+			map[string]any{"modality": "TEXT", "tokenCount": total},
+		},
+	})
+}
@@ -0,0 +1,125 @@
+package gemini
+
+import (
+	"fmt"
+	"strings"
+
+	"ds2api/internal/util"
+)
+
+var geminiHarmCategories = map[string]bool{
+	"HARM_CATEGORY_HARASSMENT":        true,
+	"HARM_CATEGORY_HATE_SPEECH":       true,
+	"HARM_CATEGORY_SEXUALLY_EXPLICIT": true,
+	"HARM_CATEGORY_DANGEROUS_CONTENT": true,
+	"HARM_CATEGORY_CIVIC_INTEGRITY":   true,
+}
+
+var geminiHarmThresholds = map[string]bool{
+	"HARM_BLOCK_THRESHOLD_UNSPECIFIED": true,
+	"BLOCK_LOW_AND_ABOVE":              true,
+	"BLOCK_MEDIUM_AND_ABOVE":           true,
+	"BLOCK_ONLY_HIGH":                  true,
+	"BLOCK_NONE":                       true,
+	"OFF":                              true,
+}
+
+// parseGeminiSafetySettings validates safetySettings and returns the requested
+// categories in order. DeepSeek's filter cannot be tuned, so thresholds are
+// only checked; the categories are echoed back as safetyRatings.
+func parseGeminiSafetySettings(raw any) ([]string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	settings, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("safetySettings must be a list.")
+	}
+	categories := make([]string, 0, len(settings))
+	seen := map[string]bool{}
+	for i, item := range settings {
+		setting, _ := item.(map[string]any)
+		category := strings.ToUpper(strings.TrimSpace(asString(setting["category"])))
+		if !geminiHarmCategories[category] {
+			return nil, fmt.Errorf("Invalid value at 'safety_settings[%d].category' (%s)", i, asString(setting["category"]))
+		}
+		threshold := strings.ToUpper(strings.TrimSpace(asString(setting["threshold"])))
+		if !geminiHarmThresholds[threshold] {
+			return nil, fmt.Errorf("Invalid value at 'safety_settings[%d].threshold' (%s)", i, asString(setting["threshold"]))
+		}
+		if seen[category] {
+			return nil, fmt.Errorf("Duplicate category in safety_settings: %s", category)
+		}
+		seen[category] = true
+		categories = append(categories, category)
+	}
+	return categories, nil
+}
+
+// geminiSafetyCategories returns the already validated safetySettings
+// categories of req.
+func geminiSafetyCategories(req map[string]any) []string {
+	categories, _ := parseGeminiSafetySettings(req["safetySettings"])
+	return categories
+}
+
+// geminiSafetyRatings reports NEGLIGIBLE for every requested category while
+// the upstream filter stays quiet. DeepSeek does not say which category made
+// it block, so a block is a single HARM_CATEGORY_UNSPECIFIED rating.
+func geminiSafetyRatings(categories []string, blocked bool) []map[string]any {
+	if blocked {
+		return []map[string]any{{"category": "HARM_CATEGORY_UNSPECIFIED", "probability": "HIGH", "blocked": true}}
+	}
+	if len(categories) == 0 {
+		return nil
+	}
+	out := make([]map[string]any, 0, len(categories))
+	for _, category := range categories {
+		out = append(out, map[string]any{"category": category, "probability": "NEGLIGIBLE"})
+	}
+	return out
+}
+
+// geminiFinishReason maps a finish outcome onto Gemini's enum; a matched stop
+// sequence is reported as a natural STOP.
+func geminiFinishReason(outputFinish string) string {
+	switch outputFinish {
+	case util.OutputFinishMaxTokens:
+		return "MAX_TOKENS"
+	case util.OutputFinishContentFilter:
+		return "SAFETY"
+	case util.OutputFinishUpstreamError:
+		return "OTHER"
+	}
+	return "STOP"
+}
+
+// geminiBlockedResponse is what Gemini returns when a prompt is blocked before
+// any output: promptFeedback and usage, but no candidates.
+func geminiBlockedResponse(model, finalPrompt string) map[string]any {
+	return map[string]any{
+		"promptFeedback": map[string]any{
+			"blockReason":   "SAFETY",
+			"safetyRatings": geminiSafetyRatings(nil, true),
+		},
+		"modelVersion":  model,
+		"usageMetadata": buildGeminiUsage(finalPrompt, "", ""),
+	}
+}
+
+// applyGeminiFinish decorates a finished candidate. A SAFETY candidate loses
+// its content, as Gemini never returns blocked output; an upstream error is
+// reported as OTHER with the message in finishMessage.
+func applyGeminiFinish(candidate map[string]any, outputFinish, errorMessage string, categories []string) {
+	candidate["finishReason"] = geminiFinishReason(outputFinish)
+	blocked := outputFinish == util.OutputFinishContentFilter
+	if blocked {
+		delete(candidate, "content")
+	}
+	if ratings := geminiSafetyRatings(categories, blocked); ratings != nil {
+		candidate["safetyRatings"] = ratings
+	}
+	if outputFinish == util.OutputFinishUpstreamError && errorMessage != "" {
+		candidate["finishMessage"] = errorMessage
+	}
+}
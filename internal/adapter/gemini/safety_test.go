@@ -0,0 +1,100 @@
+package gemini
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func doGeminiGenerate(t *testing.T, stream bool, body string, upstream ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	h := &Handler{Store: testGeminiConfig{}, Auth: testGeminiAuth{}, DS: testGeminiDS{resp: makeGeminiUpstreamResponse(upstream...)}}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	path := "/v1beta/models/gemini-2.5-pro:generateContent"
+	if stream {
+		path = "/v1beta/models/gemini-2.5-pro:streamGenerateContent"
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return rec
+}
+
+// lastGeminiChunk returns the final JSON object of a non-stream body or SSE
+// stream.
+func lastGeminiChunk(t *testing.T, raw string) map[string]any {
+	t.Helper()
+	last := raw
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.HasPrefix(line, "data: ") {
+			last = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	var out map[string]any
+	if err := json.Unmarshal([]byte(last), &out); err != nil {
+		t.Fatalf("invalid chunk %q: %v", last, err)
+	}
+	return out
+}
+
+const geminiPlainRequest = `{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`
+
+func TestGeminiContentFilterMapsToSafety(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		rec := doGeminiGenerate(t, stream, geminiPlainRequest, `data: {"code":"content_filter"}`)
+		out := lastGeminiChunk(t, rec.Body.String())
+		feedback, _ := out["promptFeedback"].(map[string]any)
+		if rec.Code != http.StatusOK || feedback["blockReason"] != "SAFETY" || out["candidates"] != nil {
+			t.Fatalf("stream=%v: expected a blocked prompt, got %d %s", stream, rec.Code, rec.Body.String())
+		}
+
+		rec = doGeminiGenerate(t, stream, geminiPlainRequest,
+			`data: {"p":"response/content","v":"Partial answer"}`,
+			`data: {"code":"content_filter"}`,
+		)
+		out = lastGeminiChunk(t, rec.Body.String())
+		candidate := out["candidates"].([]any)[0].(map[string]any)
+		ratings, _ := candidate["safetyRatings"].([]any)
+		if candidate["finishReason"] != "SAFETY" || candidate["content"] != nil || len(ratings) != 1 || ratings[0].(map[string]any)["blocked"] != true {
+			t.Fatalf("stream=%v: expected a SAFETY candidate without content, got %#v", stream, candidate)
+		}
+	}
+}
+
+func TestGeminiUpstreamErrorMapsToOther(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		rec := doGeminiGenerate(t, stream, geminiPlainRequest,
+			`data: {"p":"response/content","v":"Hello"}`,
+			`data: {"error":"upstream exploded"}`,
+		)
+		candidate := lastGeminiChunk(t, rec.Body.String())["candidates"].([]any)[0].(map[string]any)
+		if candidate["finishReason"] != "OTHER" || !strings.Contains(candidate["finishMessage"].(string), "upstream exploded") {
+			t.Fatalf("stream=%v: expected OTHER with finishMessage, got %#v", stream, candidate)
+		}
+	}
+}
+
+func TestGeminiSafetySettingsAreEchoed(t *testing.T) {
+	body := `{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"safetySettings":[
+		{"category":"HARM_CATEGORY_HARASSMENT","threshold":"BLOCK_NONE"},
+		{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","threshold":"BLOCK_ONLY_HIGH"}
+	]}`
+	for _, stream := range []bool{false, true} {
+		rec := doGeminiGenerate(t, stream, body, `data: {"p":"response/content","v":"Hello"}`, `data: [DONE]`)
+		candidate := lastGeminiChunk(t, rec.Body.String())["candidates"].([]any)[0].(map[string]any)
+		ratings, _ := candidate["safetyRatings"].([]any)
+		if candidate["finishReason"] != "STOP" || len(ratings) != 2 || ratings[1].(map[string]any)["category"] != "HARM_CATEGORY_DANGEROUS_CONTENT" || ratings[0].(map[string]any)["probability"] != "NEGLIGIBLE" {
+			t.Fatalf("stream=%v: expected echoed ratings, got %#v", stream, candidate)
+		}
+	}
+
+	bad := `{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"safetySettings":[{"category":"HARM_CATEGORY_HARASSMENT","threshold":"BLOCK_SOMETIMES"}]}`
+	rec := doGeminiGenerate(t, false, bad, `data: [DONE]`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "safety_settings[0].threshold") {
+		t.Fatalf("expected invalid threshold to be rejected, got %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,78 @@
+package ollama
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ollamaMessagesToOpenAI maps Ollama chat messages onto the OpenAI shape the
+// shared prompt builder understands. Ollama tool calls carry no IDs, so each
+// assistant call gets a positional one that the following tool results reuse.
+func ollamaMessagesToOpenAI(raw []any) []any {
+	out := make([]any, 0, len(raw))
+	pendingIDs := []string{}
+	callSeq := 0
+	for _, item := range raw {
+		msg, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		role := strings.ToLower(strings.TrimSpace(asString(msg["role"])))
+		converted := map[string]any{
+			"role":    role,
+			"content": asString(msg["content"]),
+		}
+		switch role {
+		case "assistant":
+			calls, _ := msg["tool_calls"].([]any)
+			if len(calls) > 0 {
+				converted["tool_calls"], pendingIDs = ollamaToolCallsToOpenAI(calls, &callSeq)
+			}
+		case "tool":
+			name := strings.TrimSpace(asString(msg["tool_name"]))
+			if name == "" {
+				name = strings.TrimSpace(asString(msg["name"]))
+			}
+			if name != "" {
+				converted["name"] = name
+			}
+			if len(pendingIDs) > 0 {
+				converted["tool_call_id"] = pendingIDs[0]
+				pendingIDs = pendingIDs[1:]
+			}
+		}
+		out = append(out, converted)
+	}
+	return out
+}
+
+func ollamaToolCallsToOpenAI(calls []any, seq *int) ([]any, []string) {
+	out := make([]any, 0, len(calls))
+	ids := make([]string, 0, len(calls))
+	for _, item := range calls {
+		call, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		fn, _ := call["function"].(map[string]any)
+		name := strings.TrimSpace(asString(fn["name"]))
+		if name == "" {
+			continue
+		}
+		*seq++
+		id := strings.TrimSpace(asString(call["id"]))
+		if id == "" {
+			id = fmt.Sprintf("call_%d", *seq)
+		}
+		ids = append(ids, id)
+		out = append(out, map[string]any{
+			"id":   id,
+			"type": "function",
+			"function": map[string]any{
+				"name":      name,
+				"arguments": fn["arguments"],
+			},
+		})
+	}
+	return out, ids
+}
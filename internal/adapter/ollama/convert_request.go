@@ -0,0 +1,129 @@
+package ollama
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"ds2api/internal/adapter/openai"
+	"ds2api/internal/config"
+	"ds2api/internal/util"
+)
+
+// errOllamaModelNotFound marks requests naming an unknown model; Ollama
+// answers those with 404.
+var errOllamaModelNotFound = errors.New("not found")
+
+func normalizeOllamaChatRequest(store ConfigReader, req map[string]any) (util.StandardRequest, error) {
+	messagesRaw, _ := req["messages"].([]any)
+	return buildOllamaStandardRequest(store, "ollama_chat", req, ollamaMessagesToOpenAI(messagesRaw), req["tools"])
+}
+
+// normalizeOllamaGenerateRequest turns a prompt/system pair into a two-message
+// conversation. Tools are not part of the generate API.
+func normalizeOllamaGenerateRequest(store ConfigReader, req map[string]any) (util.StandardRequest, error) {
+	messages := make([]any, 0, 2)
+	if system := strings.TrimSpace(asString(req["system"])); system != "" {
+		messages = append(messages, map[string]any{"role": "system", "content": system})
+	}
+	messages = append(messages, map[string]any{"role": "user", "content": asString(req["prompt"])})
+	return buildOllamaStandardRequest(store, "ollama_generate", req, messages, nil)
+}
+
+func buildOllamaStandardRequest(store ConfigReader, surface string, req map[string]any, messages []any, toolsRaw any) (util.StandardRequest, error) {
+	model := strings.TrimSpace(asString(req["model"]))
+	if model == "" {
+		return util.StandardRequest{}, fmt.Errorf("model is required")
+	}
+	resolvedModel, ok := resolveOllamaModel(store, model)
+	if !ok {
+		return util.StandardRequest{}, fmt.Errorf("model '%s' %w", model, errOllamaModelNotFound)
+	}
+	thinkingEnabled, searchEnabled, _ := config.GetModelConfig(resolvedModel)
+	reasoning, err := ollamaReasoningControl(req["think"])
+	if err != nil {
+		return util.StandardRequest{}, err
+	}
+	thinkingEnabled, stopPolicy := reasoning.Apply(thinkingEnabled, ollamaStopPolicy(req))
+	finalPrompt, toolNames := openai.BuildPromptForAdapter(messages, toolsRaw, "")
+
+	return util.StandardRequest{
+		Surface:        surface,
+		RequestedModel: model,
+		ResolvedModel:  resolvedModel,
+		ResponseModel:  model,
+		Messages:       messages,
+		FinalPrompt:    finalPrompt,
+		ToolNames:      toolNames,
+		Stream:         ollamaStreamEnabled(req),
+		StopPolicy:     stopPolicy,
+		Thinking:       thinkingEnabled,
+		Search:         searchEnabled,
+		PassThrough:    collectOllamaPassThrough(req),
+	}, nil
+}
+
+// isOllamaLoadProbe reports Ollama's "load the model" request: a chat without
+// messages or a generate without a prompt.
+func isOllamaLoadProbe(req map[string]any, generate bool) bool {
+	if generate {
+		return strings.TrimSpace(asString(req["prompt"])) == ""
+	}
+	messages, _ := req["messages"].([]any)
+	return len(messages) == 0
+}
+
+// ollamaStreamEnabled follows Ollama's default of streaming unless the
+// request says "stream": false.
+func ollamaStreamEnabled(req map[string]any) bool {
+	v, ok := req["stream"].(bool)
+	return !ok || v
+}
+
+// ollamaReasoningControl maps the `think` flag: a boolean toggles DeepSeek
+// thinking, while "low"/"medium"/"high" follow the OpenAI effort levels.
+func ollamaReasoningControl(raw any) (util.ReasoningControl, error) {
+	switch v := raw.(type) {
+	case nil:
+		return util.ReasoningControl{}, nil
+	case bool:
+		return util.ReasoningControl{Enabled: util.BoolPtr(v)}, nil
+	case string:
+		return util.ParseReasoningEffort(v)
+	default:
+		return util.ReasoningControl{}, fmt.Errorf("invalid think value")
+	}
+}
+
+func ollamaStopPolicy(req map[string]any) util.StopPolicy {
+	opts, _ := req["options"].(map[string]any)
+	return util.StopPolicy{
+		Sequences: util.ParseStopSequences(opts["stop"]),
+		// num_predict of -1 (unlimited) or -2 (fill context) leaves no cap.
+		MaxTokens: util.PositiveIntFrom(opts["num_predict"]),
+	}
+}
+
+func collectOllamaPassThrough(req map[string]any) map[string]any {
+	opts, _ := req["options"].(map[string]any)
+	out := map[string]any{}
+	for src, dst := range map[string]string{
+		"temperature": "temperature",
+		"top_p":       "top_p",
+		"num_predict": "max_tokens",
+		"stop":        "stop",
+	} {
+		if v, ok := opts[src]; ok {
+			out[dst] = v
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func asString(v any) string {
+	s, _ := v.(string)
+	return s
+}
@@ -0,0 +1,113 @@
+package ollama
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/sse"
+	"ds2api/internal/util"
+)
+
+func (h *Handler) handleCompletion(w http.ResponseWriter, r *http.Request, generate bool) {
+	started := time.Now()
+	raw, err := io.ReadAll(r.Body)
+	var req map[string]any
+	if err != nil || json.Unmarshal(raw, &req) != nil {
+		writeOllamaError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	// Scope checks read the model from the body.
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+	// A load probe never reaches upstream, so it must not hold a pooled
+	// account or wait for one: only the caller is checked.
+	probe := isOllamaLoadProbe(req, generate)
+	var a *auth.RequestAuth
+	if probe {
+		a, err = h.Auth.DetermineCaller(r)
+	} else {
+		a, err = h.Auth.Determine(r)
+	}
+	if err != nil {
+		writeOllamaAuthError(w, err)
+		return
+	}
+	if !probe {
+		defer h.Auth.Release(a)
+	}
+
+	var stdReq util.StandardRequest
+	if generate {
+		stdReq, err = normalizeOllamaGenerateRequest(h.Store, req)
+	} else {
+		stdReq, err = normalizeOllamaChatRequest(h.Store, req)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errOllamaModelNotFound) {
+			status = http.StatusNotFound
+		}
+		writeOllamaError(w, status, err.Error())
+		return
+	}
+	if probe {
+		out := buildOllamaChunk(stdReq.ResponseModel, generate, "", "", nil)
+		writeJSON(w, http.StatusOK, markOllamaDone(out, "load", started, "", "", ""))
+		return
+	}
+
+	sessionID, err := h.DS.CreateSession(r.Context(), a, 3)
+	if err != nil {
+		if a.UseConfigToken {
+			writeOllamaError(w, http.StatusUnauthorized, "Account token is invalid. Please re-login the account in admin.")
+		} else {
+			writeOllamaError(w, http.StatusUnauthorized, "Invalid token.")
+		}
+		return
+	}
+	pow, err := h.DS.GetPow(r.Context(), a, 3)
+	if err != nil {
+		writeOllamaError(w, http.StatusUnauthorized, "Failed to get PoW (invalid token or unknown error).")
+		return
+	}
+	resp, err := h.DS.CallCompletion(r.Context(), a, stdReq.CompletionPayload(sessionID), pow, 3)
+	if err != nil {
+		writeOllamaError(w, http.StatusInternalServerError, "Failed to get completion.")
+		return
+	}
+	if stdReq.Stream {
+		h.handleStream(w, r, resp, stdReq, generate, started)
+		return
+	}
+	h.handleNonStream(w, resp, stdReq, generate, started)
+}
+
+func (h *Handler) handleNonStream(w http.ResponseWriter, resp *http.Response, stdReq util.StandardRequest, generate bool, started time.Time) {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		writeOllamaError(w, resp.StatusCode, strings.TrimSpace(string(body)))
+		return
+	}
+
+	result := sse.CollectStreamWithPolicy(resp, stdReq.Thinking, true, stdReq.StopPolicy)
+	finalText := result.Text
+	if stdReq.Search {
+		finalText, _ = util.ResolveCitations(finalText, result.SearchResults)
+	}
+	var toolCalls []util.ParsedToolCall
+	if len(stdReq.ToolNames) > 0 {
+		toolCalls = util.ParseToolCalls(finalText, stdReq.ToolNames)
+	}
+	content := finalText
+	if len(toolCalls) > 0 {
+		content = ""
+	}
+	out := buildOllamaChunk(stdReq.ResponseModel, generate, content, result.Thinking, toolCalls)
+	writeJSON(w, http.StatusOK, markOllamaDone(out, ollamaDoneReason(result.FinishReason), started, stdReq.FinalPrompt, result.Thinking, finalText))
+}
@@ -0,0 +1,36 @@
+package ollama
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ds2api/internal/auth"
+)
+
+// writeOllamaError uses Ollama's flat error shape: {"error": "..."}.
+func writeOllamaError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{"error": message})
+}
+
+// writeOllamaAuthError reports a failed Auth.Determine: 429 when the key is
+// over its rate limits or monthly quota or no account is free, 403 for a key
+// scope violation, otherwise 401.
+func writeOllamaAuthError(w http.ResponseWriter, err error) {
+	status := http.StatusUnauthorized
+	var limited *auth.RateLimitError
+	var quota *auth.QuotaError
+	switch {
+	case errors.As(err, &limited):
+		limited.Decision.WriteRejection(w.Header())
+		status = http.StatusTooManyRequests
+	case errors.As(err, &quota):
+		w.Header().Set("Retry-After", strconv.Itoa(quota.RetryAfterSeconds()))
+		status = http.StatusTooManyRequests
+	case errors.Is(err, auth.ErrNoAccount):
+		status = http.StatusTooManyRequests
+	case errors.Is(err, auth.ErrForbidden):
+		status = http.StatusForbidden
+	}
+	writeOllamaError(w, status, err.Error())
+}
@@ -0,0 +1,112 @@
+package ollama
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"ds2api/internal/config"
+)
+
+// ollamaCompatVersion is reported by /api/version. Clients gate tool calling
+// and the `think` flag on it, so it tracks the Ollama release that shipped
+// both.
+const ollamaCompatVersion = "0.9.0"
+
+func (h *Handler) Version(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"version": ollamaCompatVersion})
+}
+
+// Tags lists the native DeepSeek models; aliases still resolve on chat and
+// generate but would only clutter model pickers.
+func (h *Handler) Tags(w http.ResponseWriter, _ *http.Request) {
+	models := make([]map[string]any, 0, len(config.DeepSeekModels))
+	for _, m := range config.DeepSeekModels {
+		models = append(models, ollamaModelEntry(m))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"models": models})
+}
+
+func (h *Handler) Show(w http.ResponseWriter, r *http.Request) {
+	var req map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOllamaError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	name, _ := req["model"].(string)
+	if strings.TrimSpace(name) == "" {
+		name, _ = req["name"].(string)
+	}
+	name = strings.TrimSpace(name)
+	resolved, ok := resolveOllamaModel(h.Store, name)
+	if !ok {
+		writeOllamaError(w, http.StatusNotFound, "model '"+name+"' not found")
+		return
+	}
+	info := ollamaModelInfo(resolved)
+	_, search, _ := config.GetModelConfig(resolved)
+	capabilities := []string{"completion", "tools", "thinking"}
+	if search {
+		capabilities = append(capabilities, "web_search")
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"modelfile":    "FROM " + resolved + "\n",
+		"parameters":   "",
+		"template":     "{{ .Prompt }}",
+		"details":      ollamaModelDetails(),
+		"model_info":   map[string]any{"general.architecture": "deepseek", "general.basename": resolved},
+		"capabilities": capabilities,
+		"modified_at":  ollamaModifiedAt(info),
+	})
+}
+
+// resolveOllamaModel accepts Ollama-style tagged names such as
+// "deepseek-chat:latest" by retrying without the tag.
+func resolveOllamaModel(store ConfigReader, name string) (string, bool) {
+	if resolved, ok := config.ResolveModel(store, name); ok {
+		return resolved, true
+	}
+	if idx := strings.LastIndex(name, ":"); idx > 0 {
+		return config.ResolveModel(store, name[:idx])
+	}
+	return "", false
+}
+
+func ollamaModelInfo(id string) config.ModelInfo {
+	for _, m := range config.DeepSeekModels {
+		if m.ID == id {
+			return m
+		}
+	}
+	return config.ModelInfo{ID: id}
+}
+
+func ollamaModelEntry(m config.ModelInfo) map[string]any {
+	digest := sha256.Sum256([]byte(m.ID))
+	return map[string]any{
+		"name":        m.ID,
+		"model":       m.ID,
+		"modified_at": ollamaModifiedAt(m),
+		"size":        0,
+		"digest":      hex.EncodeToString(digest[:]),
+		"details":     ollamaModelDetails(),
+	}
+}
+
+func ollamaModelDetails() map[string]any {
+	return map[string]any{
+		"parent_model":       "",
+		"format":             "remote",
+		"family":             "deepseek",
+		"families":           []string{"deepseek"},
+		"parameter_size":     "",
+		"quantization_level": "",
+	}
+}
+
+func ollamaModifiedAt(m config.ModelInfo) string {
+	return time.Unix(m.Created, 0).UTC().Format(time.RFC3339)
+}
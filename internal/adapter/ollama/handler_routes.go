@@ -0,0 +1,33 @@
+package ollama
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/util"
+)
+
+var writeJSON = util.WriteJSON
+
+type Handler struct {
+	Store ConfigReader
+	Auth  AuthResolver
+	DS    DeepSeekCaller
+}
+
+func RegisterRoutes(r chi.Router, h *Handler) {
+	r.Post("/api/chat", h.Chat)
+	r.Post("/api/generate", h.Generate)
+	r.Get("/api/tags", h.Tags)
+	r.Post("/api/show", h.Show)
+	r.Get("/api/version", h.Version)
+}
+
+func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
+	h.handleCompletion(w, r, false)
+}
+
+func (h *Handler) Generate(w http.ResponseWriter, r *http.Request) {
+	h.handleCompletion(w, r, true)
+}
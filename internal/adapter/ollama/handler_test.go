@@ -0,0 +1,264 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/auth"
+)
+
+type testOllamaConfig struct{}
+
+func (testOllamaConfig) ModelAliases() map[string]string { return nil }
+
+type testOllamaAuth struct{}
+
+func (testOllamaAuth) Determine(_ *http.Request) (*auth.RequestAuth, error) {
+	return &auth.RequestAuth{
+		DeepSeekToken: "direct-token",
+		CallerID:      "caller:test",
+		TriedAccounts: map[string]bool{},
+	}, nil
+}
+
+func (a testOllamaAuth) DetermineCaller(r *http.Request) (*auth.RequestAuth, error) {
+	return a.Determine(r)
+}
+
+func (testOllamaAuth) Release(_ *auth.RequestAuth) {}
+
+// probeOnlyOllamaAuth has no account to hand out, like an exhausted pool.
+type probeOnlyOllamaAuth struct{ testOllamaAuth }
+
+func (probeOnlyOllamaAuth) Determine(_ *http.Request) (*auth.RequestAuth, error) {
+	return nil, auth.ErrNoAccount
+}
+
+type testOllamaDS struct {
+	resp    *http.Response
+	payload *map[string]any
+}
+
+func (m testOllamaDS) CreateSession(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "session-id", nil
+}
+
+func (m testOllamaDS) GetPow(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "pow", nil
+}
+
+func (m testOllamaDS) CallCompletion(_ context.Context, _ *auth.RequestAuth, payload map[string]any, _ string, _ int) (*http.Response, error) {
+	if m.payload != nil {
+		*m.payload = payload
+	}
+	return m.resp, nil
+}
+
+func makeOllamaUpstreamResponse(lines ...string) *http.Response {
+	body := strings.Join(lines, "\n") + "\n"
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func newOllamaTestRouter(ds testOllamaDS) http.Handler {
+	r := chi.NewRouter()
+	RegisterRoutes(r, &Handler{Store: testOllamaConfig{}, Auth: testOllamaAuth{}, DS: ds})
+	return r
+}
+
+func serveOllama(t *testing.T, router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeNDJSON(t *testing.T, body string) []map[string]any {
+	t.Helper()
+	out := []map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatalf("invalid ndjson line %q: %v", line, err)
+		}
+		out = append(out, obj)
+	}
+	return out
+}
+
+func TestChatNonStreamReturnsMessageWithThinking(t *testing.T) {
+	var payload map[string]any
+	router := newOllamaTestRouter(testOllamaDS{
+		resp: makeOllamaUpstreamResponse(
+			`data: {"p":"response/thinking_content","v":"pondering"}`,
+			`data: {"p":"response/content","v":"hello"}`,
+			`data: [DONE]`,
+		),
+		payload: &payload,
+	})
+	rec := serveOllama(t, router, http.MethodPost, "/api/chat",
+		`{"model":"deepseek-chat:latest","stream":false,"think":true,"messages":[{"role":"user","content":"hi"}],"options":{"temperature":0.2}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	if payload["thinking_enabled"] != true || payload["temperature"] != 0.2 {
+		t.Fatalf("expected think and options on upstream payload, got %#v", payload)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	msg, _ := out["message"].(map[string]any)
+	if msg["content"] != "hello" || msg["thinking"] != "pondering" {
+		t.Fatalf("unexpected message: %#v", msg)
+	}
+	if out["done"] != true || out["done_reason"] != "stop" || out["model"] != "deepseek-chat:latest" {
+		t.Fatalf("unexpected final fields: %#v", out)
+	}
+	if n, _ := out["eval_count"].(float64); n <= 0 {
+		t.Fatalf("expected eval_count, got %#v", out)
+	}
+}
+
+func TestChatStreamEmitsToolCallsWithoutLeakingJSON(t *testing.T) {
+	router := newOllamaTestRouter(testOllamaDS{resp: makeOllamaUpstreamResponse(
+		`data: {"p":"response/content","v":"Checking.\n{\"tool_calls\":[{\"name\":\"get_weather\",\"input\":{\"city\":\"Paris\"}}]}"}`,
+		`data: [DONE]`,
+	)})
+	rec := serveOllama(t, router, http.MethodPost, "/api/chat", `{
+		"model":"deepseek-chat",
+		"messages":[{"role":"user","content":"weather?"}],
+		"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}}]
+	}`)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("expected ndjson content type, got %q", ct)
+	}
+	lines := decodeNDJSON(t, rec.Body.String())
+	var content strings.Builder
+	var calls []any
+	for _, line := range lines[:len(lines)-1] {
+		msg, _ := line["message"].(map[string]any)
+		c, _ := msg["content"].(string)
+		content.WriteString(c)
+		if tc, ok := msg["tool_calls"].([]any); ok {
+			calls = append(calls, tc...)
+		}
+	}
+	if strings.Contains(content.String(), "tool_calls") {
+		t.Fatalf("raw tool JSON leaked into content: %q", content.String())
+	}
+	if len(calls) != 1 {
+		t.Fatalf("expected one tool call, got %#v", calls)
+	}
+	fn, _ := calls[0].(map[string]any)["function"].(map[string]any)
+	args, _ := fn["arguments"].(map[string]any)
+	if fn["name"] != "get_weather" || args["city"] != "Paris" {
+		t.Fatalf("unexpected tool call: %#v", fn)
+	}
+	if last := lines[len(lines)-1]; last["done"] != true {
+		t.Fatalf("expected final done line, got %#v", last)
+	}
+}
+
+func TestGenerateStreamHonoursNumPredict(t *testing.T) {
+	router := newOllamaTestRouter(testOllamaDS{resp: makeOllamaUpstreamResponse(
+		`data: {"p":"response/content","v":"`+strings.Repeat("word ", 40)+`"}`,
+		`data: [DONE]`,
+	)})
+	rec := serveOllama(t, router, http.MethodPost, "/api/generate",
+		`{"model":"deepseek-chat","prompt":"write","system":"be brief","options":{"num_predict":5}}`)
+	lines := decodeNDJSON(t, rec.Body.String())
+	last := lines[len(lines)-1]
+	if last["done"] != true || last["done_reason"] != "length" {
+		t.Fatalf("expected length finish, got %#v", last)
+	}
+	if _, ok := lines[0]["response"].(string); !ok {
+		t.Fatalf("expected generate chunks to carry response, got %#v", lines[0])
+	}
+}
+
+func TestGenerateEmptyPromptLoadsModel(t *testing.T) {
+	router := chi.NewRouter()
+	RegisterRoutes(router, &Handler{Store: testOllamaConfig{}, Auth: probeOnlyOllamaAuth{}, DS: testOllamaDS{}})
+	rec := serveOllama(t, router, http.MethodPost, "/api/generate", `{"model":"deepseek-reasoner"}`)
+	var out map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	if rec.Code != http.StatusOK || out["done_reason"] != "load" {
+		t.Fatalf("expected load reply without an account, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serveOllama(t, router, http.MethodPost, "/api/generate", `{"model":"deepseek-reasoner","prompt":"hi"}`)
+	if rec.Code == http.StatusOK {
+		t.Fatalf("expected a real completion to need an account, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTagsShowAndVersion(t *testing.T) {
+	router := newOllamaTestRouter(testOllamaDS{})
+
+	rec := serveOllama(t, router, http.MethodGet, "/api/tags", "")
+	var tags map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &tags)
+	models, _ := tags["models"].([]any)
+	if len(models) == 0 {
+		t.Fatalf("expected models, got %s", rec.Body.String())
+	}
+
+	rec = serveOllama(t, router, http.MethodPost, "/api/show", `{"model":"deepseek-reasoner:latest"}`)
+	var show map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &show)
+	caps, _ := show["capabilities"].([]any)
+	if rec.Code != http.StatusOK || len(caps) == 0 {
+		t.Fatalf("unexpected show response: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serveOllama(t, router, http.MethodPost, "/api/show", `{"model":"llama3:8b"}`)
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), `"error"`) {
+		t.Fatalf("expected 404 error for unknown model, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serveOllama(t, router, http.MethodGet, "/api/version", "")
+	if !strings.Contains(rec.Body.String(), ollamaCompatVersion) {
+		t.Fatalf("unexpected version body: %s", rec.Body.String())
+	}
+}
+
+func TestNormalizeOllamaChatRequestMapsThinkAndToolHistory(t *testing.T) {
+	req := map[string]any{
+		"model": "deepseek-reasoner",
+		"think": false,
+		"messages": []any{
+			map[string]any{"role": "user", "content": "weather?"},
+			map[string]any{"role": "assistant", "tool_calls": []any{
+				map[string]any{"function": map[string]any{"name": "get_weather", "arguments": map[string]any{"city": "Paris"}}},
+			}},
+			map[string]any{"role": "tool", "tool_name": "get_weather", "content": "sunny"},
+		},
+	}
+	std, err := normalizeOllamaChatRequest(testOllamaConfig{}, req)
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if std.Thinking {
+		t.Fatalf("expected think=false to disable thinking")
+	}
+	toolMsg, _ := std.Messages[2].(map[string]any)
+	if toolMsg["tool_call_id"] != "call_1" || toolMsg["name"] != "get_weather" {
+		t.Fatalf("expected tool result linked to the assistant call, got %#v", toolMsg)
+	}
+	if !strings.Contains(std.FinalPrompt, "sunny") {
+		t.Fatalf("expected tool result in prompt")
+	}
+}
@@ -0,0 +1,78 @@
+package ollama
+
+import (
+	"time"
+
+	"ds2api/internal/util"
+)
+
+// buildOllamaChunk renders one reply object. Chat replies carry a `message`,
+// generate replies a flat `response`; both add `thinking` when present.
+func buildOllamaChunk(model string, generate bool, text, thinking string, toolCalls []util.ParsedToolCall) map[string]any {
+	out := map[string]any{
+		"model":      model,
+		"created_at": time.Now().UTC().Format(time.RFC3339Nano),
+		"done":       false,
+	}
+	if generate {
+		out["response"] = text
+		if thinking != "" {
+			out["thinking"] = thinking
+		}
+		return out
+	}
+	message := map[string]any{
+		"role":    "assistant",
+		"content": text,
+	}
+	if thinking != "" {
+		message["thinking"] = thinking
+	}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = formatOllamaToolCalls(toolCalls)
+	}
+	out["message"] = message
+	return out
+}
+
+// markOllamaDone turns a reply into the final object with Ollama's timing and
+// token counters. DeepSeek does not report load or prompt timings, so the
+// whole request time is attributed to evaluation.
+func markOllamaDone(out map[string]any, doneReason string, started time.Time, finalPrompt, thinking, text string) map[string]any {
+	elapsed := time.Since(started).Nanoseconds()
+	out["done"] = true
+	out["done_reason"] = doneReason
+	out["total_duration"] = elapsed
+	out["load_duration"] = 0
+	out["prompt_eval_count"] = util.CountTokens(finalPrompt)
+	out["prompt_eval_duration"] = 0
+	out["eval_count"] = util.CountTokens(thinking) + util.CountTokens(text)
+	out["eval_duration"] = elapsed
+	return out
+}
+
+func formatOllamaToolCalls(calls []util.ParsedToolCall) []map[string]any {
+	out := make([]map[string]any, 0, len(calls))
+	for i, tc := range calls {
+		args := tc.Input
+		if args == nil {
+			args = map[string]any{}
+		}
+		out = append(out, map[string]any{
+			"function": map[string]any{
+				"index":     i,
+				"name":      tc.Name,
+				"arguments": args,
+			},
+		})
+	}
+	return out
+}
+
+// ollamaDoneReason maps a local stop policy outcome onto Ollama's vocabulary.
+func ollamaDoneReason(outputFinish string) string {
+	if outputFinish == util.OutputFinishMaxTokens {
+		return "length"
+	}
+	return "stop"
+}
@@ -0,0 +1,196 @@
+package ollama
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ds2api/internal/adapter/openai"
+	"ds2api/internal/deepseek"
+	"ds2api/internal/sse"
+	streamengine "ds2api/internal/stream"
+	"ds2api/internal/util"
+)
+
+func (h *Handler) handleStream(w http.ResponseWriter, r *http.Request, resp *http.Response, stdReq util.StandardRequest, generate bool, started time.Time) {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		writeOllamaError(w, resp.StatusCode, strings.TrimSpace(string(body)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache, no-transform")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	rc := http.NewResponseController(w)
+	_, canFlush := w.(http.Flusher)
+	runtime := newOllamaStreamRuntime(w, rc, canFlush, stdReq, generate, started)
+
+	initialType := "text"
+	if stdReq.Thinking {
+		initialType = "thinking"
+	}
+	streamengine.ConsumeSSE(streamengine.ConsumeConfig{
+		Context:             r.Context(),
+		Body:                resp.Body,
+		ThinkingEnabled:     stdReq.Thinking,
+		InitialType:         initialType,
+		KeepAliveInterval:   time.Duration(deepseek.KeepAliveTimeout) * time.Second,
+		IdleTimeout:         time.Duration(deepseek.StreamIdleTimeout) * time.Second,
+		MaxKeepAliveNoInput: deepseek.MaxKeepaliveCount,
+	}, streamengine.ConsumeHooks{
+		OnParsed: runtime.onParsed,
+		OnFinalize: func(_ streamengine.StopReason, _ error) {
+			runtime.finalize()
+		},
+	})
+}
+
+// ollamaStreamRuntime writes one JSON object per line. Tool calls are held
+// back by the shared tool sieve and sent as a single message.tool_calls line.
+type ollamaStreamRuntime struct {
+	w        http.ResponseWriter
+	rc       *http.ResponseController
+	canFlush bool
+
+	model       string
+	finalPrompt string
+	generate    bool
+	started     time.Time
+
+	thinkingEnabled bool
+	limiter         *util.OutputLimiter
+	citations       *util.CitationRewriter
+	sieve           *openai.ToolSieve
+	errorMessage    string
+	thinking        strings.Builder
+	text            strings.Builder
+}
+
+func newOllamaStreamRuntime(w http.ResponseWriter, rc *http.ResponseController, canFlush bool, stdReq util.StandardRequest, generate bool, started time.Time) *ollamaStreamRuntime {
+	s := &ollamaStreamRuntime{
+		w:               w,
+		rc:              rc,
+		canFlush:        canFlush,
+		model:           stdReq.ResponseModel,
+		finalPrompt:     stdReq.FinalPrompt,
+		generate:        generate,
+		started:         started,
+		thinkingEnabled: stdReq.Thinking,
+		limiter:         util.NewOutputLimiter(stdReq.StopPolicy),
+	}
+	if stdReq.Search {
+		s.citations = util.NewCitationRewriter()
+	}
+	if len(stdReq.ToolNames) > 0 {
+		s.sieve = openai.NewToolSieve(stdReq.ToolNames)
+	}
+	return s
+}
+
+func (s *ollamaStreamRuntime) sendLine(payload map[string]any) {
+	b, _ := json.Marshal(payload)
+	_, _ = s.w.Write(b)
+	_, _ = s.w.Write([]byte("\n"))
+	if s.canFlush {
+		_ = s.rc.Flush()
+	}
+}
+
+func (s *ollamaStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedDecision {
+	if !parsed.Parsed {
+		return streamengine.ParsedDecision{}
+	}
+	if parsed.ErrorMessage != "" {
+		s.errorMessage = parsed.ErrorMessage
+		return streamengine.ParsedDecision{Stop: true}
+	}
+	if parsed.ContentFilter || parsed.Stop {
+		return streamengine.ParsedDecision{Stop: true}
+	}
+
+	if s.citations != nil {
+		s.citations.AddSources(parsed.SearchResults)
+	}
+	contentSeen := false
+	for _, p := range parsed.Parts {
+		if p.Text == "" {
+			continue
+		}
+		contentSeen = true
+		if p.Type == "thinking" {
+			if s.thinkingEnabled {
+				s.emitThinking(s.limiter.PushThinking(p.Text))
+			}
+		} else {
+			s.emitText(s.limiter.PushText(p.Text))
+		}
+		if s.limiter.Done() {
+			return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReason(s.limiter.FinishReason()), ContentSeen: contentSeen}
+		}
+	}
+	return streamengine.ParsedDecision{ContentSeen: contentSeen}
+}
+
+func (s *ollamaStreamRuntime) emitThinking(text string) {
+	if text == "" {
+		return
+	}
+	s.thinking.WriteString(text)
+	s.sendLine(buildOllamaChunk(s.model, s.generate, "", text, nil))
+}
+
+func (s *ollamaStreamRuntime) emitText(text string) {
+	if s.citations != nil && text != "" {
+		text, _ = s.citations.Push(text)
+	}
+	s.emitSieved(text)
+}
+
+func (s *ollamaStreamRuntime) emitSieved(text string) {
+	if s.sieve == nil {
+		s.emitContent(text)
+		return
+	}
+	if text == "" {
+		return
+	}
+	s.emitSieveEvents(s.sieve.Push(text))
+}
+
+func (s *ollamaStreamRuntime) emitSieveEvents(events []openai.ToolSieveEvent) {
+	for _, evt := range events {
+		s.emitContent(evt.Content)
+		if len(evt.ToolCalls) > 0 {
+			s.sendLine(buildOllamaChunk(s.model, s.generate, "", "", evt.ToolCalls))
+		}
+	}
+}
+
+func (s *ollamaStreamRuntime) emitContent(text string) {
+	if text == "" {
+		return
+	}
+	s.text.WriteString(text)
+	s.sendLine(buildOllamaChunk(s.model, s.generate, text, "", nil))
+}
+
+func (s *ollamaStreamRuntime) finalize() {
+	if s.errorMessage != "" {
+		s.sendLine(map[string]any{"error": s.errorMessage})
+		return
+	}
+	s.emitText(s.limiter.Flush())
+	if s.citations != nil {
+		s.emitSieved(s.citations.Flush())
+	}
+	if s.sieve != nil {
+		s.emitSieveEvents(s.sieve.Flush())
+	}
+	final := buildOllamaChunk(s.model, s.generate, "", "", nil)
+	s.sendLine(markOllamaDone(final, ollamaDoneReason(s.limiter.FinishReason()), s.started, s.finalPrompt, s.thinking.String(), s.text.String()))
+}
@@ -0,0 +1,195 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+)
+
+const (
+	batchListDefaultLimit = 20
+	batchListMaxLimit     = 100
+)
+
+var batchEndpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/responses":        true,
+	"/v1/embeddings":       true,
+	"/v1/completions":      true,
+}
+
+func (h *Handler) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	a, err := h.Auth.DetermineCaller(r)
+	if err != nil {
+		writeOpenAIAuthError(w, err)
+		return
+	}
+	owner := responseStoreOwner(a)
+	if owner == "" {
+		writeOpenAIError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	if a.DeepSeekToken != "" {
+		writeOpenAIError(w, http.StatusBadRequest, "Batches run on the managed account pool and require a configured API key.")
+		return
+	}
+	bg, ok := h.Auth.(BackgroundAuthResolver)
+	if !ok || config.IsVercel() {
+		writeOpenAIError(w, http.StatusServiceUnavailable, "Batch execution is not available on this deployment.")
+		return
+	}
+
+	var req map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	inputFileID, _ := req["input_file_id"].(string)
+	inputFileID = strings.TrimSpace(inputFileID)
+	endpoint, _ := req["endpoint"].(string)
+	endpoint = strings.TrimSpace(endpoint)
+	window, _ := req["completion_window"].(string)
+	window = strings.TrimSpace(window)
+	if inputFileID == "" {
+		writeOpenAIError(w, http.StatusBadRequest, "Request must include 'input_file_id'.")
+		return
+	}
+	if !batchEndpoints[endpoint] {
+		writeOpenAIError(w, http.StatusBadRequest, "endpoint must be one of /v1/chat/completions, /v1/responses, /v1/embeddings or /v1/completions.")
+		return
+	}
+	if window != "24h" {
+		writeOpenAIError(w, http.StatusBadRequest, "completion_window must be '24h'.")
+		return
+	}
+	// Line bodies are checked as they run; the endpoint can be checked now.
+	if err := bg.CheckBackground(auth.BackgroundRequest{CallerID: owner, KeyID: a.KeyID, Path: endpoint}); err != nil {
+		writeOpenAIAuthError(w, err)
+		return
+	}
+	metadata, err := parseChatCompletionMetadata(req["metadata"])
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	file, err := h.getFileStore().get(owner, inputFileID)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("Input file '%s' not found.", inputFileID))
+		return
+	}
+	if file.Purpose != filePurposeBatch {
+		writeOpenAIError(w, http.StatusBadRequest, "Input file must be uploaded with purpose 'batch'.")
+		return
+	}
+
+	store := h.getBatchStore()
+	rec, err := store.create(batchRecord{
+		Owner:            owner,
+		KeyID:            a.KeyID,
+		Endpoint:         endpoint,
+		InputFileID:      inputFileID,
+		CompletionWindow: window,
+		Metadata:         metadata,
+	})
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, "failed to store batch.")
+		return
+	}
+	store.Notify()
+	writeJSON(w, http.StatusOK, rec.apiObject())
+}
+
+func (h *Handler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.chatCompletionCaller(w, r)
+	if !ok {
+		return
+	}
+	rec, err := h.getBatchStore().Get(owner, strings.TrimSpace(chi.URLParam(r, "batch_id")))
+	if err != nil {
+		writeOpenAIError(w, http.StatusNotFound, "Batch not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, rec.apiObject())
+}
+
+func (h *Handler) ListBatches(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.chatCompletionCaller(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	limit := batchListDefaultLimit
+	if raw := strings.TrimSpace(q.Get("limit")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > batchListMaxLimit {
+			writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d.", batchListMaxLimit))
+			return
+		}
+		limit = n
+	}
+	items := h.getBatchStore().List(owner)
+	data := make([]any, 0, min(limit, len(items)))
+	after := strings.TrimSpace(q.Get("after"))
+	skipping := after != ""
+	hasMore := false
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		if skipping {
+			if item.ID == after {
+				skipping = false
+			}
+			continue
+		}
+		if len(data) == limit {
+			hasMore = true
+			break
+		}
+		data = append(data, item.apiObject())
+	}
+	writeJSON(w, http.StatusOK, buildChatCompletionList(data, hasMore))
+}
+
+// CancelBatch moves a batch to cancelling; the runner stops after the request
+// in flight and finalizes it as cancelled with whatever results it has.
+func (h *Handler) CancelBatch(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.chatCompletionCaller(w, r)
+	if !ok {
+		return
+	}
+	id := strings.TrimSpace(chi.URLParam(r, "batch_id"))
+	store := h.getBatchStore()
+	if _, err := store.Get(owner, id); err != nil {
+		writeOpenAIError(w, http.StatusNotFound, "Batch not found.")
+		return
+	}
+	conflict := false
+	rec, err := store.Update(id, func(rec *batchRecord) {
+		switch {
+		case rec.Status == batchStatusCancelling:
+		case rec.terminal() || rec.Status == batchStatusFinalizing:
+			conflict = true
+		default:
+			rec.Status = batchStatusCancelling
+			rec.CancellingAt = time.Now().Unix()
+		}
+	})
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, "failed to update batch.")
+		return
+	}
+	if conflict {
+		writeOpenAIErrorWithCode(w, http.StatusConflict, fmt.Sprintf("Cannot cancel a batch with status '%s'.", rec.Status), "invalid_batch_status")
+		return
+	}
+	store.Cancel(id)
+	store.Notify()
+	writeJSON(w, http.StatusOK, rec.apiObject())
+}
@@ -0,0 +1,330 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/auth"
+)
+
+type batchAuthStub struct {
+	acquired int
+}
+
+func (s *batchAuthStub) managed() *auth.RequestAuth {
+	return &auth.RequestAuth{UseConfigToken: true, CallerID: "caller:batch", DeepSeekToken: "account-token", TriedAccounts: map[string]bool{}}
+}
+
+func (s *batchAuthStub) Determine(_ *http.Request) (*auth.RequestAuth, error) {
+	return s.managed(), nil
+}
+
+func (s *batchAuthStub) DetermineCaller(_ *http.Request) (*auth.RequestAuth, error) {
+	return &auth.RequestAuth{CallerID: "caller:batch", TriedAccounts: map[string]bool{}}, nil
+}
+
+func (s *batchAuthStub) Release(_ *auth.RequestAuth) {}
+
+func (s *batchAuthStub) CheckBackground(_ auth.BackgroundRequest) error {
+	return nil
+}
+
+func (s *batchAuthStub) ServeBackground(_ *auth.RequestAuth, w http.ResponseWriter, req *http.Request, serve http.HandlerFunc) {
+	serve(w, req)
+}
+
+func (s *batchAuthStub) AcquireBackground(_ context.Context, job auth.BackgroundRequest) (*auth.RequestAuth, error) {
+	s.acquired++
+	a := s.managed()
+	a.CallerID = job.CallerID
+	return a, nil
+}
+
+func newBatchTestHandler(t *testing.T, ds DeepSeekCaller) (*Handler, chi.Router) {
+	t.Helper()
+	h := &Handler{Store: mockOpenAIConfig{wideInput: true, embedProv: "deterministic"}, Auth: &batchAuthStub{}, DS: ds, DataDir: t.TempDir()}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	return h, r
+}
+
+func doBatchJSON(t *testing.T, r chi.Router, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer managed-key")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func uploadTestFile(t *testing.T, r chi.Router, purpose, name, content string) map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("purpose", purpose)
+	fw, _ := mw.CreateFormFile("file", name)
+	_, _ = fw.Write([]byte(content))
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/files", &buf)
+	req.Header.Set("Authorization", "Bearer managed-key")
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("upload failed: %d %s", rec.Code, rec.Body.String())
+	}
+	return decodeJSONBody(t, rec.Body.String())
+}
+
+func drainBatches(t *testing.T, h *Handler) {
+	t.Helper()
+	for i := 0; i < 10; i++ {
+		rec, ok := h.getBatchStore().NextRunnable()
+		if !ok {
+			return
+		}
+		h.runBatch(context.Background(), rec)
+	}
+	t.Fatal("batches did not settle")
+}
+
+func readFileContent(t *testing.T, r chi.Router, id string) string {
+	t.Helper()
+	rec := doBatchJSON(t, r, http.MethodGet, "/v1/files/"+id+"/content", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("content failed: %d %s", rec.Code, rec.Body.String())
+	}
+	return rec.Body.String()
+}
+
+func TestFilesUploadListRetrieveContentDelete(t *testing.T) {
+	_, r := newBatchTestHandler(t, nil)
+	file := uploadTestFile(t, r, "batch", "input.jsonl", "hello\n")
+	id, _ := file["id"].(string)
+	if !strings.HasPrefix(id, "file-") || file["bytes"].(float64) != 6 || file["filename"] != "input.jsonl" || file["purpose"] != "batch" {
+		t.Fatalf("unexpected file object: %#v", file)
+	}
+
+	list := decodeJSONBody(t, doBatchJSON(t, r, http.MethodGet, "/v1/files?purpose=batch", "").Body.String())
+	if data, _ := list["data"].([]any); len(data) != 1 {
+		t.Fatalf("expected one listed file, got %#v", list)
+	}
+	other := decodeJSONBody(t, doBatchJSON(t, r, http.MethodGet, "/v1/files?purpose=vision", "").Body.String())
+	if data, _ := other["data"].([]any); len(data) != 0 {
+		t.Fatalf("expected purpose filter to exclude file, got %#v", other)
+	}
+	if got := readFileContent(t, r, id); got != "hello\n" {
+		t.Fatalf("unexpected content %q", got)
+	}
+
+	del := doBatchJSON(t, r, http.MethodDelete, "/v1/files/"+id, "")
+	if out := decodeJSONBody(t, del.Body.String()); out["deleted"] != true {
+		t.Fatalf("unexpected delete response: %s", del.Body.String())
+	}
+	if rec := doBatchJSON(t, r, http.MethodGet, "/v1/files/"+id, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", rec.Code)
+	}
+}
+
+func TestFilesRejectsUnknownPurpose(t *testing.T) {
+	_, r := newBatchTestHandler(t, nil)
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("purpose", "nope")
+	fw, _ := mw.CreateFormFile("file", "a.txt")
+	_, _ = fw.Write([]byte("x"))
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/files", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestBatchRunsChatCompletionsAndWritesOutputAndErrors(t *testing.T) {
+	ds := &sequenceDSStub{bodies: []string{`data: {"p":"response/content","v":"pong"}`}}
+	h, r := newBatchTestHandler(t, ds)
+	input := strings.Join([]string{
+		`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"deepseek-chat","messages":[{"role":"user","content":"ping"}],"stream":true}}`,
+		`{"custom_id":"b","method":"POST","url":"/v1/chat/completions","body":{"model":"missing-model","messages":[{"role":"user","content":"ping"}]}}`,
+	}, "\n") + "\n"
+	file := uploadTestFile(t, r, "batch", "in.jsonl", input)
+
+	create := doBatchJSON(t, r, http.MethodPost, "/v1/batches", `{"input_file_id":"`+file["id"].(string)+`","endpoint":"/v1/chat/completions","completion_window":"24h","metadata":{"job":"nightly"}}`)
+	if create.Code != http.StatusOK {
+		t.Fatalf("create failed: %d %s", create.Code, create.Body.String())
+	}
+	batch := decodeJSONBody(t, create.Body.String())
+	if batch["status"] != "validating" || batch["object"] != "batch" {
+		t.Fatalf("unexpected batch: %#v", batch)
+	}
+	batchID := batch["id"].(string)
+
+	drainBatches(t, h)
+
+	got := decodeJSONBody(t, doBatchJSON(t, r, http.MethodGet, "/v1/batches/"+batchID, "").Body.String())
+	if got["status"] != "completed" {
+		t.Fatalf("expected completed batch, got %#v", got)
+	}
+	counts, _ := got["request_counts"].(map[string]any)
+	if counts["total"] != float64(2) || counts["completed"] != float64(1) || counts["failed"] != float64(1) {
+		t.Fatalf("unexpected request counts: %#v", counts)
+	}
+	if h.Auth.(*batchAuthStub).acquired != 2 {
+		t.Fatalf("expected each line to acquire a background account")
+	}
+
+	output := readFileContent(t, r, got["output_file_id"].(string))
+	var line map[string]any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &line); err != nil {
+		t.Fatalf("output is not a single JSON line: %q", output)
+	}
+	resp, _ := line["response"].(map[string]any)
+	body, _ := resp["body"].(map[string]any)
+	if line["custom_id"] != "a" || resp["status_code"] != float64(200) || body["object"] != "chat.completion" {
+		t.Fatalf("unexpected output line: %#v", line)
+	}
+	errorsOut := readFileContent(t, r, got["error_file_id"].(string))
+	if !strings.Contains(errorsOut, `"custom_id":"b"`) || !strings.Contains(errorsOut, `"status_code":400`) {
+		t.Fatalf("unexpected error file: %s", errorsOut)
+	}
+}
+
+func TestBatchEmbeddingsEndpoint(t *testing.T) {
+	h, r := newBatchTestHandler(t, nil)
+	file := uploadTestFile(t, r, "batch", "in.jsonl", `{"custom_id":"e1","method":"POST","url":"/v1/embeddings","body":{"model":"deepseek-chat","input":"hello"}}`)
+	create := doBatchJSON(t, r, http.MethodPost, "/v1/batches", `{"input_file_id":"`+file["id"].(string)+`","endpoint":"/v1/embeddings","completion_window":"24h"}`)
+	batchID := decodeJSONBody(t, create.Body.String())["id"].(string)
+
+	drainBatches(t, h)
+
+	got := decodeJSONBody(t, doBatchJSON(t, r, http.MethodGet, "/v1/batches/"+batchID, "").Body.String())
+	if got["status"] != "completed" || got["error_file_id"] != nil {
+		t.Fatalf("unexpected batch: %#v", got)
+	}
+	if output := readFileContent(t, r, got["output_file_id"].(string)); !strings.Contains(output, `"object":"list"`) {
+		t.Fatalf("unexpected embeddings output: %s", output)
+	}
+}
+
+func TestBatchValidationFailureReportsLineErrors(t *testing.T) {
+	h, r := newBatchTestHandler(t, nil)
+	input := `{"custom_id":"a","method":"POST","url":"/v1/responses","body":{}}` + "\n" + `not json` + "\n"
+	file := uploadTestFile(t, r, "batch", "in.jsonl", input)
+	create := doBatchJSON(t, r, http.MethodPost, "/v1/batches", `{"input_file_id":"`+file["id"].(string)+`","endpoint":"/v1/chat/completions","completion_window":"24h"}`)
+	batchID := decodeJSONBody(t, create.Body.String())["id"].(string)
+
+	drainBatches(t, h)
+
+	got := decodeJSONBody(t, doBatchJSON(t, r, http.MethodGet, "/v1/batches/"+batchID, "").Body.String())
+	if got["status"] != "failed" {
+		t.Fatalf("expected failed batch, got %#v", got)
+	}
+	errs, _ := got["errors"].(map[string]any)
+	data, _ := errs["data"].([]any)
+	if len(data) != 2 {
+		t.Fatalf("expected two line errors, got %#v", errs)
+	}
+	first, _ := data[0].(map[string]any)
+	if first["code"] != "mismatched_endpoint" || first["line"] != float64(1) {
+		t.Fatalf("unexpected first error: %#v", first)
+	}
+}
+
+func TestBatchResumesWithoutRepeatingFinishedLines(t *testing.T) {
+	ds := &sequenceDSStub{bodies: []string{`data: {"p":"response/content","v":"pong"}`}}
+	h, r := newBatchTestHandler(t, ds)
+	input := strings.Join([]string{
+		`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"deepseek-chat","messages":[{"role":"user","content":"one"}]}}`,
+		`{"custom_id":"b","method":"POST","url":"/v1/chat/completions","body":{"model":"deepseek-chat","messages":[{"role":"user","content":"two"}]}}`,
+	}, "\n")
+	file := uploadTestFile(t, r, "batch", "in.jsonl", input)
+	create := doBatchJSON(t, r, http.MethodPost, "/v1/batches", `{"input_file_id":"`+file["id"].(string)+`","endpoint":"/v1/chat/completions","completion_window":"24h"}`)
+	batchID := decodeJSONBody(t, create.Body.String())["id"].(string)
+
+	// Simulate a previous process that finished line "a", left a torn write
+	// behind and stopped while in progress.
+	store := h.getBatchStore()
+	if _, err := store.Update(batchID, func(rec *batchRecord) {
+		rec.Status = batchStatusInProgress
+		rec.Total = 2
+	}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	prior := `{"id":"batch_req_1","custom_id":"a","response":{"status_code":200,"request_id":"req_1","body":{}},"error":null}` + "\n" + `{"id":"batch_req_2","cust`
+	if err := os.WriteFile(store.outputPath(batchID), []byte(prior), 0o644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	restarted := &Handler{Store: h.Store, Auth: h.Auth, DS: ds, DataDir: h.DataDir}
+	drainBatches(t, restarted)
+
+	if len(ds.prompts) != 1 || !strings.Contains(ds.prompts[0], "two") {
+		t.Fatalf("expected only line b to run after resume, prompts=%#v", ds.prompts)
+	}
+	got := decodeJSONBody(t, doBatchJSON(t, r, http.MethodGet, "/v1/batches/"+batchID, "").Body.String())
+	counts, _ := got["request_counts"].(map[string]any)
+	if got["status"] != "completed" || counts["completed"] != float64(2) {
+		t.Fatalf("unexpected resumed batch: %#v", got)
+	}
+	output := readFileContent(t, r, got["output_file_id"].(string))
+	if lines := strings.Split(strings.TrimSpace(output), "\n"); len(lines) != 2 {
+		t.Fatalf("expected two clean output lines, got %q", output)
+	}
+}
+
+func TestBatchCancelBeforeRunAndList(t *testing.T) {
+	h, r := newBatchTestHandler(t, nil)
+	file := uploadTestFile(t, r, "batch", "in.jsonl", `{"custom_id":"a","method":"POST","url":"/v1/embeddings","body":{"model":"deepseek-chat","input":"x"}}`)
+	create := doBatchJSON(t, r, http.MethodPost, "/v1/batches", `{"input_file_id":"`+file["id"].(string)+`","endpoint":"/v1/embeddings","completion_window":"24h"}`)
+	batchID := decodeJSONBody(t, create.Body.String())["id"].(string)
+
+	cancel := doBatchJSON(t, r, http.MethodPost, "/v1/batches/"+batchID+"/cancel", "")
+	if out := decodeJSONBody(t, cancel.Body.String()); out["status"] != "cancelling" {
+		t.Fatalf("unexpected cancel response: %s", cancel.Body.String())
+	}
+	drainBatches(t, h)
+
+	list := decodeJSONBody(t, doBatchJSON(t, r, http.MethodGet, "/v1/batches?limit=5", "").Body.String())
+	data, _ := list["data"].([]any)
+	if len(data) != 1 {
+		t.Fatalf("expected one batch in list, got %#v", list)
+	}
+	item, _ := data[0].(map[string]any)
+	if item["status"] != "cancelled" || item["output_file_id"] != nil {
+		t.Fatalf("unexpected cancelled batch: %#v", item)
+	}
+	again := doBatchJSON(t, r, http.MethodPost, "/v1/batches/"+batchID+"/cancel", "")
+	if again.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for cancelling a finished batch, got %d", again.Code)
+	}
+}
+
+func TestBatchCreateRejectsDirectTokenAndWrongPurpose(t *testing.T) {
+	h := &Handler{Store: mockOpenAIConfig{}, Auth: streamStatusAuthStub{}, DataDir: t.TempDir()}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	rec := doBatchJSON(t, r, http.MethodPost, "/v1/batches", `{"input_file_id":"file-x","endpoint":"/v1/chat/completions","completion_window":"24h"}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "API key") {
+		t.Fatalf("expected direct token rejection, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	_, managed := newBatchTestHandler(t, nil)
+	file := uploadTestFile(t, managed, "user_data", "in.jsonl", "{}")
+	rec = doBatchJSON(t, managed, http.MethodPost, "/v1/batches", `{"input_file_id":"`+file["id"].(string)+`","endpoint":"/v1/chat/completions","completion_window":"24h"}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "purpose") {
+		t.Fatalf("expected purpose rejection, got %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,324 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/batchstore"
+	"ds2api/internal/config"
+)
+
+type batchInputLine struct {
+	Line     int
+	CustomID string
+	Body     map[string]any
+}
+
+// RunBatches processes queued batches one at a time until ctx ends. Batches
+// left validating or in progress by a previous process are resumed first.
+func (h *Handler) RunBatches(ctx context.Context) {
+	h.getBatchStore().Run(ctx, h.runBatch)
+}
+
+func (h *Handler) runBatch(ctx context.Context, rec batchRecord) {
+	store := h.getBatchStore()
+	runCtx, finish := store.Start(ctx, rec.ID)
+	defer finish()
+
+	if rec.Status == batchStatusValidating || rec.Status == batchStatusInProgress {
+		lines, errs := h.loadBatchInput(rec)
+		if len(errs) > 0 {
+			h.failBatch(rec.ID, errs)
+			return
+		}
+		if rec.Status == batchStatusValidating {
+			var err error
+			rec, err = store.Update(rec.ID, func(r *batchRecord) {
+				if r.Status == batchStatusValidating {
+					r.Status = batchStatusInProgress
+					r.InProgressAt = time.Now().Unix()
+					r.Total = len(lines)
+				}
+			})
+			if err != nil {
+				config.Logger.Warn("[batch] update failed", "batch_id", rec.ID, "error", err)
+				return
+			}
+		}
+		if rec.Status == batchStatusInProgress {
+			h.processBatchLines(runCtx, rec, lines)
+		}
+		if ctx.Err() != nil {
+			// Shutting down: leave the batch in progress for the next start.
+			return
+		}
+	}
+	h.finalizeBatch(rec.ID)
+}
+
+func (h *Handler) loadBatchInput(rec batchRecord) ([]batchInputLine, []batchError) {
+	_, f, err := h.getFileStore().open(rec.Owner, rec.InputFileID)
+	if err != nil {
+		return nil, []batchError{{Code: "invalid_file", Message: fmt.Sprintf("Input file '%s' is no longer available.", rec.InputFileID)}}
+	}
+	defer f.Close()
+	return parseBatchInput(f, rec.Endpoint)
+}
+
+func parseBatchInput(r io.Reader, endpoint string) ([]batchInputLine, []batchError) {
+	var lines []batchInputLine
+	var errs []batchError
+	seen := map[string]bool{}
+	reader := bufio.NewReader(r)
+	for lineNo := 1; ; lineNo++ {
+		raw, readErr := reader.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 {
+			var item struct {
+				CustomID string         `json:"custom_id"`
+				Method   string         `json:"method"`
+				URL      string         `json:"url"`
+				Body     map[string]any `json:"body"`
+			}
+			switch {
+			case json.Unmarshal(trimmed, &item) != nil:
+				errs = append(errs, batchError{Code: "invalid_json_line", Message: "This line is not parseable as valid JSON.", Line: lineNo})
+			case strings.TrimSpace(item.CustomID) == "":
+				errs = append(errs, batchError{Code: "missing_required_parameter", Message: "custom_id is required.", Param: "custom_id", Line: lineNo})
+			case seen[item.CustomID]:
+				errs = append(errs, batchError{Code: "duplicate_custom_id", Message: fmt.Sprintf("custom_id '%s' is used more than once.", item.CustomID), Param: "custom_id", Line: lineNo})
+			case !strings.EqualFold(item.Method, http.MethodPost):
+				errs = append(errs, batchError{Code: "invalid_method", Message: "method must be POST.", Param: "method", Line: lineNo})
+			case item.URL != endpoint:
+				errs = append(errs, batchError{Code: "mismatched_endpoint", Message: fmt.Sprintf("url must match the batch endpoint %s.", endpoint), Param: "url", Line: lineNo})
+			case item.Body == nil:
+				errs = append(errs, batchError{Code: "missing_required_parameter", Message: "body must be a JSON object.", Param: "body", Line: lineNo})
+			default:
+				seen[item.CustomID] = true
+				lines = append(lines, batchInputLine{Line: lineNo, CustomID: item.CustomID, Body: item.Body})
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	if len(errs) == 0 && len(lines) == 0 {
+		errs = append(errs, batchError{Code: "empty_file", Message: "The input file contains no requests."})
+	}
+	return lines, errs
+}
+
+func (h *Handler) processBatchLines(ctx context.Context, rec batchRecord, lines []batchInputLine) {
+	store := h.getBatchStore()
+	outPath, errPath := store.outputPath(rec.ID), store.errorPath(rec.ID)
+	doneOut, completed, err := loadBatchResults(outPath)
+	if err != nil {
+		config.Logger.Warn("[batch] read output failed", "batch_id", rec.ID, "error", err)
+		return
+	}
+	doneErr, failed, err := loadBatchResults(errPath)
+	if err != nil {
+		config.Logger.Warn("[batch] read errors failed", "batch_id", rec.ID, "error", err)
+		return
+	}
+	_, _ = store.Update(rec.ID, func(r *batchRecord) {
+		r.Completed, r.Failed = completed, failed
+	})
+
+	for _, line := range lines {
+		if doneOut[line.CustomID] || doneErr[line.CustomID] {
+			continue
+		}
+		current, err := store.Update(rec.ID, func(r *batchRecord) {
+			if r.Status == batchStatusInProgress && time.Now().Unix() >= r.ExpiresAt {
+				r.Status = batchStatusExpired
+				r.ExpiredAt = time.Now().Unix()
+			}
+		})
+		if err != nil || current.Status != batchStatusInProgress || ctx.Err() != nil {
+			return
+		}
+		result, ok := h.executeBatchLine(ctx, rec, line)
+		if !ok {
+			return
+		}
+		path, success := errPath, false
+		if resp, _ := result["response"].(map[string]any); resp != nil {
+			if code, _ := resp["status_code"].(int); code >= 200 && code < 300 {
+				path, success = outPath, true
+			}
+		}
+		if err := batchstore.AppendJSONLine(path, result); err != nil {
+			config.Logger.Warn("[batch] write result failed", "batch_id", rec.ID, "error", err)
+			return
+		}
+		_, _ = store.Update(rec.ID, func(r *batchRecord) {
+			if success {
+				r.Completed++
+			} else {
+				r.Failed++
+			}
+		})
+	}
+}
+
+// executeBatchLine runs one request through the regular handler on a pooled
+// account acquired at low priority, under the checks of the key that created
+// the batch. ok is false when the batch was cancelled
+// or the server is stopping, in which case the line is left for later.
+func (h *Handler) executeBatchLine(ctx context.Context, rec batchRecord, line batchInputLine) (map[string]any, bool) {
+	result := map[string]any{
+		"id":        "batch_req_" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		"custom_id": line.CustomID,
+		"response":  nil,
+		"error":     nil,
+	}
+	bg, ok := h.Auth.(BackgroundAuthResolver)
+	if !ok {
+		result["error"] = map[string]any{"code": "service_unavailable", "message": "Batch execution is not available on this deployment."}
+		return result, true
+	}
+	body := cloneAnyMap(line.Body)
+	delete(body, "stream")
+	delete(body, "stream_options")
+	payload, _ := json.Marshal(body)
+	rw := batchstore.NewResponse()
+	a, err := bg.AcquireBackground(ctx, auth.BackgroundRequest{CallerID: rec.Owner, KeyID: rec.KeyID, Path: rec.Endpoint, Body: payload})
+	switch {
+	case ctx.Err() != nil:
+		return nil, false
+	case err != nil:
+		// The line gets the response the endpoint would have sent the key.
+		writeOpenAIAuthError(rw, err)
+	default:
+		defer h.Auth.Release(a)
+		h.serveBatchLine(ctx, bg, a, rec.Endpoint, payload, rw)
+		if ctx.Err() != nil {
+			return nil, false
+		}
+	}
+	status := rw.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	var respBody any
+	if err := json.Unmarshal(rw.Body.Bytes(), &respBody); err != nil {
+		respBody = rw.Body.String()
+	}
+	result["response"] = map[string]any{
+		"status_code": status,
+		"request_id":  "req_" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		"body":        respBody,
+	}
+	return result, true
+}
+
+func (h *Handler) serveBatchLine(ctx context.Context, bg BackgroundAuthResolver, a *auth.RequestAuth, endpoint string, payload []byte, rw *batchstore.Response) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	exec := &Handler{
+		Store:           h.Store,
+		Auth:            batchAuth{a: a},
+		DS:              h.DS,
+		DataDir:         h.DataDir,
+		responses:       h.getResponseStore(),
+		chatCompletions: h.getChatCompletionStore(),
+	}
+	serve := map[string]http.HandlerFunc{
+		"/v1/chat/completions": exec.ChatCompletions,
+		"/v1/responses":        exec.Responses,
+		"/v1/embeddings":       exec.Embeddings,
+		"/v1/completions":      exec.Completions,
+	}[endpoint]
+	if serve != nil {
+		bg.ServeBackground(a, rw, req, serve)
+	}
+}
+
+func (h *Handler) failBatch(id string, errs []batchError) {
+	_, _ = h.getBatchStore().Update(id, func(r *batchRecord) {
+		r.Status = batchStatusFailed
+		r.FailedAt = time.Now().Unix()
+		r.Errors = errs
+	})
+}
+
+// finalizeBatch publishes the result files and moves the batch to its final
+// status: completed, or cancelled / expired if it was stopped early.
+func (h *Handler) finalizeBatch(id string) {
+	store := h.getBatchStore()
+	rec, err := store.Update(id, func(r *batchRecord) {
+		if r.Status == batchStatusInProgress {
+			r.Status = batchStatusFinalizing
+			r.FinalizingAt = time.Now().Unix()
+		}
+	})
+	if err != nil || rec.terminal() && rec.Status != batchStatusExpired {
+		return
+	}
+	outputID := h.publishBatchResults(rec, store.outputPath(id), "output")
+	errorID := h.publishBatchResults(rec, store.errorPath(id), "errors")
+	_, err = store.Update(id, func(r *batchRecord) {
+		if outputID != "" {
+			r.OutputFileID = outputID
+		}
+		if errorID != "" {
+			r.ErrorFileID = errorID
+		}
+		now := time.Now().Unix()
+		switch r.Status {
+		case batchStatusCancelling:
+			r.Status = batchStatusCancelled
+			r.CancelledAt = now
+		case batchStatusFinalizing:
+			r.Status = batchStatusCompleted
+			r.CompletedAt = now
+		}
+	})
+	if err != nil {
+		config.Logger.Warn("[batch] finalize failed", "batch_id", id, "error", err)
+	}
+}
+
+func (h *Handler) publishBatchResults(rec batchRecord, path, kind string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	if info.Size() == 0 {
+		_ = os.Remove(path)
+		return ""
+	}
+	file, err := h.getFileStore().adopt(rec.Owner, fmt.Sprintf("%s_%s.jsonl", rec.ID, kind), filePurposeBatchOutput, path)
+	if err != nil {
+		config.Logger.Warn("[batch] publish results failed", "batch_id", rec.ID, "error", err)
+		return ""
+	}
+	return file.ID
+}
+
+// batchAuth hands the runner's pre-acquired account to the regular handlers.
+// Release is a no-op because the runner owns the lease.
+type batchAuth struct {
+	a *auth.RequestAuth
+}
+
+func (b batchAuth) Determine(_ *http.Request) (*auth.RequestAuth, error) {
+	return b.a, nil
+}
+
+func (b batchAuth) DetermineCaller(_ *http.Request) (*auth.RequestAuth, error) {
+	return b.a, nil
+}
+
+func (b batchAuth) Release(_ *auth.RequestAuth) {}
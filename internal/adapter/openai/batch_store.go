@@ -0,0 +1,199 @@
+package openai
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	"ds2api/internal/batchstore"
+)
+
+const (
+	batchStatusValidating = "validating"
+	batchStatusFailed     = "failed"
+	batchStatusInProgress = "in_progress"
+	batchStatusFinalizing = "finalizing"
+	batchStatusCompleted  = "completed"
+	batchStatusExpired    = "expired"
+	batchStatusCancelling = "cancelling"
+	batchStatusCancelled  = "cancelled"
+
+	batchCompletionWindow = 24 * time.Hour
+)
+
+type batchError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+	Line    int    `json:"line,omitempty"`
+}
+
+// batchRecord is the persisted state of a batch job. Progress is written after
+// every request line so a restarted runner can pick up where it stopped.
+type batchRecord struct {
+	ID               string         `json:"id"`
+	Owner            string         `json:"owner"`
+	KeyID            string         `json:"key_id,omitempty"`
+	Endpoint         string         `json:"endpoint"`
+	InputFileID      string         `json:"input_file_id"`
+	CompletionWindow string         `json:"completion_window"`
+	Status           string         `json:"status"`
+	OutputFileID     string         `json:"output_file_id,omitempty"`
+	ErrorFileID      string         `json:"error_file_id,omitempty"`
+	Errors           []batchError   `json:"errors,omitempty"`
+	Metadata         map[string]any `json:"metadata,omitempty"`
+	Total            int            `json:"total"`
+	Completed        int            `json:"completed"`
+	Failed           int            `json:"failed"`
+	CreatedAt        int64          `json:"created_at"`
+	InProgressAt     int64          `json:"in_progress_at,omitempty"`
+	ExpiresAt        int64          `json:"expires_at"`
+	FinalizingAt     int64          `json:"finalizing_at,omitempty"`
+	CompletedAt      int64          `json:"completed_at,omitempty"`
+	FailedAt         int64          `json:"failed_at,omitempty"`
+	ExpiredAt        int64          `json:"expired_at,omitempty"`
+	CancellingAt     int64          `json:"cancelling_at,omitempty"`
+	CancelledAt      int64          `json:"cancelled_at,omitempty"`
+}
+
+func (b batchRecord) apiObject() map[string]any {
+	var errs any
+	if len(b.Errors) > 0 {
+		data := make([]any, 0, len(b.Errors))
+		for _, e := range b.Errors {
+			item := map[string]any{"code": e.Code, "message": e.Message, "param": nil, "line": nil}
+			if e.Param != "" {
+				item["param"] = e.Param
+			}
+			if e.Line > 0 {
+				item["line"] = e.Line
+			}
+			data = append(data, item)
+		}
+		errs = map[string]any{"object": "list", "data": data}
+	}
+	metadata := b.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	return map[string]any{
+		"id":                b.ID,
+		"object":            "batch",
+		"endpoint":          b.Endpoint,
+		"errors":            errs,
+		"input_file_id":     b.InputFileID,
+		"completion_window": b.CompletionWindow,
+		"status":            b.Status,
+		"output_file_id":    nilIfEmpty(b.OutputFileID),
+		"error_file_id":     nilIfEmpty(b.ErrorFileID),
+		"created_at":        b.CreatedAt,
+		"in_progress_at":    nilIfZero(b.InProgressAt),
+		"expires_at":        b.ExpiresAt,
+		"finalizing_at":     nilIfZero(b.FinalizingAt),
+		"completed_at":      nilIfZero(b.CompletedAt),
+		"failed_at":         nilIfZero(b.FailedAt),
+		"expired_at":        nilIfZero(b.ExpiredAt),
+		"cancelling_at":     nilIfZero(b.CancellingAt),
+		"cancelled_at":      nilIfZero(b.CancelledAt),
+		"request_counts": map[string]any{
+			"total":     b.Total,
+			"completed": b.Completed,
+			"failed":    b.Failed,
+		},
+		"metadata": metadata,
+	}
+}
+
+func (b batchRecord) terminal() bool {
+	switch b.Status {
+	case batchStatusFailed, batchStatusCompleted, batchStatusExpired, batchStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (b batchRecord) BatchID() string {
+	return b.ID
+}
+
+func (b batchRecord) BatchOwner() string {
+	return b.Owner
+}
+
+func (b batchRecord) BatchCreatedAt() int64 {
+	return b.CreatedAt
+}
+
+func (b batchRecord) Runnable() bool {
+	return !b.terminal()
+}
+
+func nilIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nilIfZero(n int64) any {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+// batchStore persists batches as <dir>/<id>.json. Request results are
+// appended to <id>.output.jsonl / <id>.error.jsonl while the batch runs and
+// moved into the file store once it finishes.
+type batchStore struct {
+	*batchstore.Store[batchRecord]
+}
+
+func newBatchStore(dir string) *batchStore {
+	return &batchStore{batchstore.New[batchRecord](dir, "batch_")}
+}
+
+func (s *batchStore) outputPath(id string) string {
+	return s.Path(id, ".output.jsonl")
+}
+
+func (s *batchStore) errorPath(id string) string {
+	return s.Path(id, ".error.jsonl")
+}
+
+func (s *batchStore) create(rec batchRecord) (batchRecord, error) {
+	now := time.Now()
+	rec.ID = s.NewID()
+	rec.Status = batchStatusValidating
+	rec.CreatedAt = now.Unix()
+	rec.ExpiresAt = now.Add(batchCompletionWindow).Unix()
+	if err := s.Create(rec); err != nil {
+		return batchRecord{}, err
+	}
+	return rec, nil
+}
+
+// loadBatchResults reads the custom_ids already recorded in a result file.
+func loadBatchResults(path string) (map[string]bool, int, error) {
+	lines, err := batchstore.ReadJSONLines(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	done := map[string]bool{}
+	for _, line := range lines {
+		var item struct {
+			CustomID string `json:"custom_id"`
+		}
+		if json.Unmarshal(line, &item) == nil {
+			done[item.CustomID] = true
+		}
+	}
+	return done, len(lines), nil
+}
+
+func (h *Handler) getBatchStore() *batchStore {
+	h.batchesOnce.Do(func() {
+		h.batches = newBatchStore(filepath.Join(h.dataDir(), "batches"))
+	})
+	return h.batches
+}
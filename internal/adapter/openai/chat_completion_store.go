@@ -0,0 +1,224 @@
+package openai
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/auth"
+)
+
+const (
+	chatCompletionListDefaultLimit = 20
+	chatCompletionListMaxLimit     = 100
+	chatCompletionMetadataMaxKeys  = 16
+)
+
+// chatCompletionPersister returns the hook that stores a finished completion
+// for `store: true` requests, or nil when the request did not opt in.
+func (h *Handler) chatCompletionPersister(a *auth.RequestAuth, req map[string]any) (func(obj map[string]any), error) {
+	metadata, err := parseChatCompletionMetadata(req["metadata"])
+	if err != nil {
+		return nil, err
+	}
+	if v, _ := req["store"].(bool); !v {
+		return nil, nil
+	}
+	owner := responseStoreOwner(a)
+	if owner == "" {
+		return nil, nil
+	}
+	messages, _ := req["messages"].([]any)
+	return func(obj map[string]any) {
+		id, _ := obj["id"].(string)
+		stored := cloneAnyMap(obj)
+		stored["metadata"] = metadata
+		h.getChatCompletionStore().putWithInput(owner, id, stored, messages)
+	}, nil
+}
+
+func parseChatCompletionMetadata(raw any) (map[string]any, error) {
+	out := map[string]any{}
+	if raw == nil {
+		return out, nil
+	}
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("metadata must be an object of string values.")
+	}
+	if len(m) > chatCompletionMetadataMaxKeys {
+		return nil, fmt.Errorf("metadata supports at most %d keys.", chatCompletionMetadataMaxKeys)
+	}
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("metadata.%s must be a string.", k)
+		}
+		out[k] = s
+	}
+	return out, nil
+}
+
+func (h *Handler) ListChatCompletions(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.chatCompletionCaller(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	limit := chatCompletionListDefaultLimit
+	if raw := strings.TrimSpace(q.Get("limit")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > chatCompletionListMaxLimit {
+			writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d.", chatCompletionListMaxLimit))
+			return
+		}
+		limit = n
+	}
+	order := strings.ToLower(strings.TrimSpace(q.Get("order")))
+	if order != "" && order != "asc" && order != "desc" {
+		writeOpenAIError(w, http.StatusBadRequest, "order must be 'asc' or 'desc'.")
+		return
+	}
+	model := strings.TrimSpace(q.Get("model"))
+	metadataFilter := map[string]string{}
+	for key, values := range q {
+		if strings.HasPrefix(key, "metadata[") && strings.HasSuffix(key, "]") && len(values) > 0 {
+			metadataFilter[strings.TrimSuffix(strings.TrimPrefix(key, "metadata["), "]")] = values[0]
+		}
+	}
+
+	items := h.getChatCompletionStore().list(owner)
+	if order == "desc" {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	data := make([]any, 0, limit)
+	after := strings.TrimSpace(q.Get("after"))
+	skipping := after != ""
+	hasMore := false
+	for _, item := range items {
+		if skipping {
+			if item.ID == after {
+				skipping = false
+			}
+			continue
+		}
+		if model != "" && item.Value["model"] != model {
+			continue
+		}
+		if !chatCompletionMetadataMatches(item.Value["metadata"], metadataFilter) {
+			continue
+		}
+		if len(data) == limit {
+			hasMore = true
+			break
+		}
+		data = append(data, item.Value)
+	}
+	writeJSON(w, http.StatusOK, buildChatCompletionList(data, hasMore))
+}
+
+func chatCompletionMetadataMatches(raw any, filter map[string]string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	metadata, _ := raw.(map[string]any)
+	for k, v := range filter {
+		if got, _ := metadata[k].(string); got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *Handler) GetChatCompletion(w http.ResponseWriter, r *http.Request) {
+	item, ok := h.lookupStoredChatCompletion(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, item.Value)
+}
+
+func (h *Handler) GetChatCompletionMessages(w http.ResponseWriter, r *http.Request) {
+	item, ok := h.lookupStoredChatCompletion(w, r)
+	if !ok {
+		return
+	}
+	data := make([]any, 0, len(item.Input))
+	for i, raw := range item.Input {
+		msg, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		out := cloneAnyMap(msg)
+		out["id"] = fmt.Sprintf("%s-%d", item.ID, i)
+		data = append(data, out)
+	}
+	writeJSON(w, http.StatusOK, buildChatCompletionList(data, false))
+}
+
+func (h *Handler) DeleteChatCompletion(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.chatCompletionCaller(w, r)
+	if !ok {
+		return
+	}
+	id := strings.TrimSpace(chi.URLParam(r, "completion_id"))
+	if !h.getChatCompletionStore().delete(owner, id) {
+		writeOpenAIError(w, http.StatusNotFound, "Chat completion not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"object":  "chat.completion.deleted",
+		"id":      id,
+		"deleted": true,
+	})
+}
+
+func (h *Handler) lookupStoredChatCompletion(w http.ResponseWriter, r *http.Request) (storedResponse, bool) {
+	owner, ok := h.chatCompletionCaller(w, r)
+	if !ok {
+		return storedResponse{}, false
+	}
+	id := strings.TrimSpace(chi.URLParam(r, "completion_id"))
+	item, ok := h.getChatCompletionStore().getEntry(owner, id)
+	if !ok {
+		writeOpenAIError(w, http.StatusNotFound, "Chat completion not found.")
+		return storedResponse{}, false
+	}
+	return item, true
+}
+
+func (h *Handler) chatCompletionCaller(w http.ResponseWriter, r *http.Request) (string, bool) {
+	a, err := h.Auth.DetermineCaller(r)
+	if err != nil {
+		writeOpenAIAuthError(w, err)
+		return "", false
+	}
+	owner := responseStoreOwner(a)
+	if owner == "" {
+		writeOpenAIError(w, http.StatusUnauthorized, "unauthorized")
+		return "", false
+	}
+	return owner, true
+}
+
+func buildChatCompletionList(data []any, hasMore bool) map[string]any {
+	out := map[string]any{
+		"object":   "list",
+		"data":     data,
+		"first_id": nil,
+		"last_id":  nil,
+		"has_more": hasMore,
+	}
+	if len(data) > 0 {
+		first, _ := data[0].(map[string]any)
+		last, _ := data[len(data)-1].(map[string]any)
+		out["first_id"] = first["id"]
+		out["last_id"] = last["id"]
+	}
+	return out
+}
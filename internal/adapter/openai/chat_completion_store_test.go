@@ -0,0 +1,110 @@
+package openai
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newChatStoreTestRouter(ds *sequenceDSStub) http.Handler {
+	h := &Handler{Store: mockOpenAIConfig{wideInput: true, responsesTTL: 900}, Auth: streamStatusAuthStub{}, DS: ds}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	return r
+}
+
+func doChatStoreRequest(t *testing.T, router http.Handler, method, path, body string) map[string]any {
+	t.Helper()
+	var reader *strings.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	} else {
+		reader = strings.NewReader("")
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s %s: unexpected status %d body=%s", method, path, rec.Code, rec.Body.String())
+	}
+	if strings.HasPrefix(rec.Header().Get("Content-Type"), "text/event-stream") {
+		return nil
+	}
+	return decodeJSONBody(t, rec.Body.String())
+}
+
+func TestStoredChatCompletionsLifecycle(t *testing.T) {
+	ds := &sequenceDSStub{bodies: []string{`data: {"p":"response/content","v":"hello"}`}}
+	router := newChatStoreTestRouter(ds)
+
+	first := doChatStoreRequest(t, router, http.MethodPost, "/v1/chat/completions",
+		`{"model":"deepseek-chat","store":true,"metadata":{"suite":"a"},"messages":[{"role":"user","content":"hi"}]}`)
+	doChatStoreRequest(t, router, http.MethodPost, "/v1/chat/completions",
+		`{"model":"deepseek-chat","store":true,"metadata":{"suite":"b"},"messages":[{"role":"user","content":"yo"}],"stream":true}`)
+	doChatStoreRequest(t, router, http.MethodPost, "/v1/chat/completions",
+		`{"model":"deepseek-chat","messages":[{"role":"user","content":"not stored"}]}`)
+	firstID, _ := first["id"].(string)
+
+	all := doChatStoreRequest(t, router, http.MethodGet, "/v1/chat/completions", "")
+	if data, _ := all["data"].([]any); len(data) != 2 {
+		t.Fatalf("expected 2 stored completions, got %#v", all)
+	}
+
+	filtered := doChatStoreRequest(t, router, http.MethodGet, "/v1/chat/completions?metadata[suite]=b", "")
+	data, _ := filtered["data"].([]any)
+	if len(data) != 1 {
+		t.Fatalf("expected metadata filter to match one completion, got %#v", filtered)
+	}
+	streamed, _ := data[0].(map[string]any)
+	choice, _ := streamed["choices"].([]any)[0].(map[string]any)
+	if msg, _ := choice["message"].(map[string]any); msg["content"] != "hello" {
+		t.Fatalf("expected streamed completion to be stored, got %#v", streamed)
+	}
+
+	page := doChatStoreRequest(t, router, http.MethodGet, "/v1/chat/completions?limit=1", "")
+	if page["has_more"] != true || page["last_id"] != firstID {
+		t.Fatalf("unexpected first page: %#v", page)
+	}
+	next := doChatStoreRequest(t, router, http.MethodGet, "/v1/chat/completions?limit=1&after="+firstID, "")
+	if next["has_more"] != false || next["first_id"] == firstID {
+		t.Fatalf("unexpected second page: %#v", next)
+	}
+
+	got := doChatStoreRequest(t, router, http.MethodGet, "/v1/chat/completions/"+firstID, "")
+	if meta, _ := got["metadata"].(map[string]any); meta["suite"] != "a" {
+		t.Fatalf("expected stored metadata, got %#v", got)
+	}
+	msgs := doChatStoreRequest(t, router, http.MethodGet, "/v1/chat/completions/"+firstID+"/messages", "")
+	msgData, _ := msgs["data"].([]any)
+	if len(msgData) != 1 || msgData[0].(map[string]any)["content"] != "hi" {
+		t.Fatalf("unexpected stored messages: %#v", msgs)
+	}
+
+	deleted := doChatStoreRequest(t, router, http.MethodDelete, "/v1/chat/completions/"+firstID, "")
+	if deleted["deleted"] != true || deleted["object"] != "chat.completion.deleted" {
+		t.Fatalf("unexpected delete response: %#v", deleted)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/v1/chat/completions/"+firstID, nil)
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", rec.Code)
+	}
+}
+
+func TestChatCompletionsRejectsNonStringMetadata(t *testing.T) {
+	router := newChatStoreTestRouter(&sequenceDSStub{bodies: []string{`data: {"p":"response/content","v":"x"}`}})
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(
+		`{"model":"deepseek-chat","store":true,"metadata":{"n":1},"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d body=%s", rec.Code, rec.Body.String())
+	}
+}
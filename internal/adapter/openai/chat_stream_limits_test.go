@@ -0,0 +1,193 @@
+package openai
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/util"
+)
+
+func TestHandleStreamIncludeUsageEmitsTrailingUsageChunk(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"hello"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	h.handleStream(rec, req, resp, "cid-usage", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", IncludeUsage: true}, 0, toolCallGate{}, nil)
+
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done {
+		t.Fatalf("expected [DONE], body=%s", rec.Body.String())
+	}
+	if len(frames) < 2 {
+		t.Fatalf("expected finish and usage chunks, body=%s", rec.Body.String())
+	}
+	last := frames[len(frames)-1]
+	choices, ok := last["choices"].([]any)
+	if !ok || len(choices) != 0 {
+		t.Fatalf("expected trailing usage chunk with empty choices, got %#v", last)
+	}
+	usage, _ := last["usage"].(map[string]any)
+	if total, _ := usage["total_tokens"].(float64); total <= 0 {
+		t.Fatalf("expected non-zero total_tokens, got %#v", usage)
+	}
+	for _, frame := range frames[:len(frames)-1] {
+		if _, ok := frame["usage"]; ok {
+			t.Fatalf("expected usage only on trailing chunk, got %#v", frame)
+		}
+	}
+	if streamFinishReason(frames) != "stop" {
+		t.Fatalf("expected finish_reason=stop, body=%s", rec.Body.String())
+	}
+}
+
+func TestHandleStreamWithoutIncludeUsageKeepsUsageOnFinishChunk(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"hello"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	h.handleStream(rec, req, resp, "cid-usage2", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt"}, 0, toolCallGate{}, nil)
+
+	frames, _ := parseSSEDataFrames(t, rec.Body.String())
+	for _, frame := range frames {
+		if choices, _ := frame["choices"].([]any); len(choices) == 0 {
+			t.Fatalf("unexpected empty-choices chunk without include_usage: %#v", frame)
+		}
+	}
+	last := frames[len(frames)-1]
+	if _, ok := last["usage"].(map[string]any); !ok {
+		t.Fatalf("expected usage on finish chunk, got %#v", last)
+	}
+}
+
+func TestChatCompletionsStreamOptionsIncludeUsageRoute(t *testing.T) {
+	h := &Handler{
+		Store: mockOpenAIConfig{wideInput: true},
+		Auth:  streamStatusAuthStub{},
+		DS:    streamStatusDSStub{resp: makeOpenAISSEHTTPResponse(`data: {"p":"response/content","v":"hello"}`, "data: [DONE]")},
+	}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+
+	reqBody := `{"model":"deepseek-chat","messages":[{"role":"user","content":"hi"}],"stream":true,"stream_options":{"include_usage":true}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(reqBody))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done || len(frames) == 0 {
+		t.Fatalf("expected stream frames, body=%s", rec.Body.String())
+	}
+	last := frames[len(frames)-1]
+	if choices, _ := last["choices"].([]any); len(choices) != 0 {
+		t.Fatalf("expected trailing empty-choices usage chunk, got %#v", last)
+	}
+	if _, ok := last["usage"].(map[string]any); !ok {
+		t.Fatalf("expected usage on trailing chunk, got %#v", last)
+	}
+}
+
+func TestHandleStreamStopSequenceSplitAcrossChunks(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"final answer<|e"}`,
+		`data: {"p":"response/content","v":"nd|> ignored"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	h.handleStream(rec, req, resp, "cid-stop", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", StopPolicy: util.StopPolicy{Sequences: []string{"<|end|>"}}}, 0, toolCallGate{}, nil)
+
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done {
+		t.Fatalf("expected [DONE], body=%s", rec.Body.String())
+	}
+	content := strings.Builder{}
+	for _, frame := range frames {
+		choices, _ := frame["choices"].([]any)
+		for _, item := range choices {
+			choice, _ := item.(map[string]any)
+			delta, _ := choice["delta"].(map[string]any)
+			c, _ := delta["content"].(string)
+			content.WriteString(c)
+		}
+	}
+	if content.String() != "final answer" {
+		t.Fatalf("expected content cut at stop sequence, got %q", content.String())
+	}
+	if streamFinishReason(frames) != "stop" {
+		t.Fatalf("expected finish_reason=stop, body=%s", rec.Body.String())
+	}
+}
+
+func TestHandleStreamMaxTokensReportsLength(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"`+strings.Repeat("abcd", 50)+`"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	h.handleStream(rec, req, resp, "cid-len", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", StopPolicy: util.StopPolicy{MaxTokens: 8}}, 0, toolCallGate{}, nil)
+
+	frames, _ := parseSSEDataFrames(t, rec.Body.String())
+	if streamFinishReason(frames) != "length" {
+		t.Fatalf("expected finish_reason=length, body=%s", rec.Body.String())
+	}
+	last := frames[len(frames)-1]
+	usage, _ := last["usage"].(map[string]any)
+	if n, _ := usage["completion_tokens"].(float64); n > 8 {
+		t.Fatalf("expected completion_tokens within budget, got %#v", usage)
+	}
+}
+
+func TestHandleNonStreamMaxTokensReportsLength(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"`+strings.Repeat("abcd", 50)+`"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+
+	h.handleNonStream(rec, resp, "cid-len2", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", StopPolicy: util.StopPolicy{MaxTokens: 8}}, 0, toolCallGate{}, nil)
+
+	out := decodeJSONBody(t, rec.Body.String())
+	choices, _ := out["choices"].([]any)
+	choice, _ := choices[0].(map[string]any)
+	if choice["finish_reason"] != "length" {
+		t.Fatalf("expected finish_reason=length, got %#v", choice)
+	}
+}
+
+func TestHandleNonStreamContentFilterReportsContentFilter(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"partial"}`,
+		`data: {"code":"content_filter"}`,
+	)
+	rec := httptest.NewRecorder()
+
+	h.handleNonStream(rec, resp, "cid-filter", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt"}, 0, toolCallGate{}, nil)
+
+	out := decodeJSONBody(t, rec.Body.String())
+	choices, _ := out["choices"].([]any)
+	choice, _ := choices[0].(map[string]any)
+	if choice["finish_reason"] != "content_filter" {
+		t.Fatalf("expected finish_reason=content_filter, got %#v", choice)
+	}
+}
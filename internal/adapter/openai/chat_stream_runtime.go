@@ -20,20 +20,29 @@ type chatStreamRuntime struct {
 	created      int64
 	model        string
 	finalPrompt  string
+	cachedTokens int
 	toolNames    []string
 
 	thinkingEnabled bool
 	searchEnabled   bool
+	includeUsage    bool
 
 	firstChunkSent       bool
 	bufferToolContent    bool
 	emitEarlyToolDeltas  bool
 	toolCallsEmitted     bool
 	toolCallsDoneEmitted bool
+	toolCallsResolved    bool
 
+	toolGate          toolCallGate
+	emittedCalls      []util.ParsedToolCall
+	persist           func(obj map[string]any)
+	limiter           *util.OutputLimiter
 	toolSieve         toolStreamSieveState
 	streamToolCallIDs map[int]string
 	streamToolNames   map[int]string
+	citations         *util.CitationRewriter
+	citationSpans     []util.CitationSpan
 	thinking          strings.Builder
 	text              strings.Builder
 }
@@ -51,7 +60,15 @@ func newChatStreamRuntime(
 	toolNames []string,
 	bufferToolContent bool,
 	emitEarlyToolDeltas bool,
+	includeUsage bool,
+	stopPolicy util.StopPolicy,
+	toolGate toolCallGate,
+	persist func(obj map[string]any),
 ) *chatStreamRuntime {
+	var citations *util.CitationRewriter
+	if searchEnabled {
+		citations = util.NewCitationRewriter()
+	}
 	return &chatStreamRuntime{
 		w:                   w,
 		rc:                  rc,
@@ -65,8 +82,13 @@ func newChatStreamRuntime(
 		searchEnabled:       searchEnabled,
 		bufferToolContent:   bufferToolContent,
 		emitEarlyToolDeltas: emitEarlyToolDeltas,
+		includeUsage:        includeUsage,
+		toolGate:            toolGate,
+		persist:             persist,
+		limiter:             util.NewOutputLimiter(stopPolicy),
 		streamToolCallIDs:   map[int]string{},
 		streamToolNames:     map[int]string{},
+		citations:           citations,
 	}
 }
 
@@ -96,11 +118,31 @@ func (s *chatStreamRuntime) sendDone() {
 }
 
 func (s *chatStreamRuntime) finalize(finishReason string) {
+	tail := s.citedTextChoices(s.limiter.Flush())
+	if s.citations != nil {
+		tail = append(tail, s.textChoices(s.citations.Flush())...)
+	}
+	if len(tail) > 0 {
+		s.sendChunk(openaifmt.BuildChatStreamChunk(s.completionID, s.created, s.model, tail, nil))
+	}
+	if s.limiter.FinishReason() == util.OutputFinishMaxTokens {
+		finishReason = "length"
+	}
 	finalThinking := s.thinking.String()
 	finalText := s.text.String()
 	detected := util.ParseToolCalls(finalText, s.toolNames)
+	if len(detected) > 0 && !s.toolCallsDoneEmitted && !s.toolCallsResolved {
+		var fallback string
+		detected, fallback = s.resolveToolCalls(detected)
+		if fallback != "" {
+			s.sendChunk(openaifmt.BuildChatStreamChunk(s.completionID, s.created, s.model, s.contentChoices(fallback), nil))
+		}
+	} else if s.toolCallsResolved {
+		detected = nil
+	}
 	if len(detected) > 0 && !s.toolCallsDoneEmitted {
 		finishReason = "tool_calls"
+		s.emittedCalls = append(s.emittedCalls, detected...)
 		delta := map[string]any{
 			"tool_calls": formatFinalStreamToolCallsWithStableIDs(detected, s.streamToolCallIDs),
 		}
@@ -120,6 +162,15 @@ func (s *chatStreamRuntime) finalize(finishReason string) {
 	} else if s.bufferToolContent {
 		for _, evt := range flushToolSieve(&s.toolSieve, s.toolNames) {
 			if len(evt.ToolCalls) > 0 {
+				calls, fallback := s.resolveToolCalls(evt.ToolCalls)
+				if len(calls) == 0 {
+					if fallback != "" {
+						s.sendChunk(openaifmt.BuildChatStreamChunk(s.completionID, s.created, s.model, s.contentChoices(fallback), nil))
+					}
+					continue
+				}
+				evt.ToolCalls = calls
+				s.emittedCalls = append(s.emittedCalls, calls...)
 				finishReason = "tool_calls"
 				s.toolCallsEmitted = true
 				s.toolCallsDoneEmitted = true
@@ -161,12 +212,29 @@ func (s *chatStreamRuntime) finalize(finishReason string) {
 	if len(detected) > 0 || s.toolCallsEmitted {
 		finishReason = "tool_calls"
 	}
+	usage := openaifmt.BuildChatUsage(s.finalPrompt, finalThinking, finalText)
+	openaifmt.SetCachedTokens(usage, s.cachedTokens)
+	s.persistCompletion(finalThinking, finalText, finishReason)
+	if s.includeUsage {
+		// stream_options.include_usage: usage travels in a trailing chunk with
+		// empty choices, matching the OpenAI streaming contract.
+		s.sendChunk(openaifmt.BuildChatStreamChunk(
+			s.completionID,
+			s.created,
+			s.model,
+			[]map[string]any{openaifmt.BuildChatStreamFinishChoice(0, finishReason)},
+			nil,
+		))
+		s.sendChunk(openaifmt.BuildChatStreamUsageChunk(s.completionID, s.created, s.model, usage))
+		s.sendDone()
+		return
+	}
 	s.sendChunk(openaifmt.BuildChatStreamChunk(
 		s.completionID,
 		s.created,
 		s.model,
 		[]map[string]any{openaifmt.BuildChatStreamFinishChoice(0, finishReason)},
-		openaifmt.BuildChatUsage(s.finalPrompt, finalThinking, finalText),
+		usage,
 	))
 	s.sendDone()
 }
@@ -182,89 +250,178 @@ func (s *chatStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedD
 		return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReasonHandlerRequested}
 	}
 
+	if s.citations != nil {
+		s.citations.AddSources(parsed.SearchResults)
+	}
 	newChoices := make([]map[string]any, 0, len(parsed.Parts))
 	contentSeen := false
 	for _, p := range parsed.Parts {
-		if s.searchEnabled && sse.IsCitation(p.Text) {
+		if p.Type == "thinking" && s.searchEnabled && sse.IsCitation(p.Text) {
 			continue
 		}
 		if p.Text == "" {
 			continue
 		}
 		contentSeen = true
-		delta := map[string]any{}
-		if !s.firstChunkSent {
-			delta["role"] = "assistant"
-			s.firstChunkSent = true
-		}
 		if p.Type == "thinking" {
-			if s.thinkingEnabled {
-				s.thinking.WriteString(p.Text)
-				delta["reasoning_content"] = p.Text
+			if !s.thinkingEnabled {
+				continue
 			}
-		} else {
-			s.text.WriteString(p.Text)
-			if !s.bufferToolContent {
-				delta["content"] = p.Text
-			} else {
-				events := processToolSieveChunk(&s.toolSieve, p.Text, s.toolNames)
-				for _, evt := range events {
-					if len(evt.ToolCallDeltas) > 0 {
-						if !s.emitEarlyToolDeltas {
-							continue
-						}
-						filtered := filterIncrementalToolCallDeltasByAllowed(evt.ToolCallDeltas, s.toolNames, s.streamToolNames)
-						if len(filtered) == 0 {
-							continue
-						}
-						formatted := formatIncrementalStreamToolCallDeltas(filtered, s.streamToolCallIDs)
-						if len(formatted) == 0 {
-							continue
-						}
-						tcDelta := map[string]any{
-							"tool_calls": formatted,
-						}
-						s.toolCallsEmitted = true
-						if !s.firstChunkSent {
-							tcDelta["role"] = "assistant"
-							s.firstChunkSent = true
-						}
-						newChoices = append(newChoices, openaifmt.BuildChatStreamDeltaChoice(0, tcDelta))
-						continue
-					}
-					if len(evt.ToolCalls) > 0 {
-						s.toolCallsEmitted = true
-						s.toolCallsDoneEmitted = true
-						tcDelta := map[string]any{
-							"tool_calls": formatFinalStreamToolCallsWithStableIDs(evt.ToolCalls, s.streamToolCallIDs),
-						}
-						if !s.firstChunkSent {
-							tcDelta["role"] = "assistant"
-							s.firstChunkSent = true
-						}
-						newChoices = append(newChoices, openaifmt.BuildChatStreamDeltaChoice(0, tcDelta))
-						continue
-					}
-					if evt.Content != "" {
-						contentDelta := map[string]any{
-							"content": evt.Content,
-						}
-						if !s.firstChunkSent {
-							contentDelta["role"] = "assistant"
-							s.firstChunkSent = true
-						}
-						newChoices = append(newChoices, openaifmt.BuildChatStreamDeltaChoice(0, contentDelta))
-					}
+			text := s.limiter.PushThinking(p.Text)
+			if text != "" {
+				s.thinking.WriteString(text)
+				delta := map[string]any{"reasoning_content": text}
+				if !s.firstChunkSent {
+					delta["role"] = "assistant"
+					s.firstChunkSent = true
 				}
+				newChoices = append(newChoices, openaifmt.BuildChatStreamDeltaChoice(0, delta))
 			}
+		} else {
+			newChoices = append(newChoices, s.citedTextChoices(s.limiter.PushText(p.Text))...)
 		}
-		if len(delta) > 0 {
-			newChoices = append(newChoices, openaifmt.BuildChatStreamDeltaChoice(0, delta))
+		if s.limiter.Done() {
+			break
 		}
 	}
 
 	if len(newChoices) > 0 {
 		s.sendChunk(openaifmt.BuildChatStreamChunk(s.completionID, s.created, s.model, newChoices, nil))
 	}
+	if s.limiter.Done() {
+		return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReason(s.limiter.FinishReason()), ContentSeen: contentSeen}
+	}
 	return streamengine.ParsedDecision{ContentSeen: contentSeen}
 }
+
+// citedTextChoices rewrites [citation:N] markers for search models and follows
+// the text with a delta carrying the matching url_citation annotations.
+func (s *chatStreamRuntime) citedTextChoices(text string) []map[string]any {
+	if s.citations == nil || text == "" {
+		return s.textChoices(text)
+	}
+	text, spans := s.citations.Push(text)
+	choices := s.textChoices(text)
+	if len(spans) == 0 {
+		return choices
+	}
+	s.citationSpans = append(s.citationSpans, spans...)
+	annotations := make([]map[string]any, 0, len(spans))
+	for _, span := range spans {
+		annotations = append(annotations, openaifmt.BuildChatURLCitation(span))
+	}
+	return append(choices, openaifmt.BuildChatStreamDeltaChoice(0, map[string]any{"annotations": annotations}))
+}
+
+// textChoices records visible text and turns it into delta choices, routing it
+// through the tool sieve when tool calls are being intercepted.
+func (s *chatStreamRuntime) textChoices(text string) []map[string]any {
+	if text == "" {
+		return nil
+	}
+	s.text.WriteString(text)
+	if !s.bufferToolContent {
+		return s.contentChoices(text)
+	}
+	choices := make([]map[string]any, 0, 2)
+	events := processToolSieveChunk(&s.toolSieve, text, s.toolNames)
+	for _, evt := range events {
+		if len(evt.ToolCallDeltas) > 0 {
+			if !s.emitEarlyToolDeltas {
+				continue
+			}
+			filtered := filterIncrementalToolCallDeltasByAllowed(evt.ToolCallDeltas, s.toolNames, s.streamToolNames)
+			if len(filtered) == 0 {
+				continue
+			}
+			formatted := formatIncrementalStreamToolCallDeltas(filtered, s.streamToolCallIDs)
+			if len(formatted) == 0 {
+				continue
+			}
+			tcDelta := map[string]any{
+				"tool_calls": formatted,
+			}
+			s.toolCallsEmitted = true
+			if !s.firstChunkSent {
+				tcDelta["role"] = "assistant"
+				s.firstChunkSent = true
+			}
+			choices = append(choices, openaifmt.BuildChatStreamDeltaChoice(0, tcDelta))
+			continue
+		}
+		if len(evt.ToolCalls) > 0 {
+			calls, fallback := s.resolveToolCalls(evt.ToolCalls)
+			if len(calls) == 0 {
+				choices = append(choices, s.contentChoices(fallback)...)
+				continue
+			}
+			evt.ToolCalls = calls
+			s.emittedCalls = append(s.emittedCalls, calls...)
+			s.toolCallsEmitted = true
+			s.toolCallsDoneEmitted = true
+			tcDelta := map[string]any{
+				"tool_calls": formatFinalStreamToolCallsWithStableIDs(evt.ToolCalls, s.streamToolCallIDs),
+			}
+			if !s.firstChunkSent {
+				tcDelta["role"] = "assistant"
+				s.firstChunkSent = true
+			}
+			choices = append(choices, openaifmt.BuildChatStreamDeltaChoice(0, tcDelta))
+			continue
+		}
+		if evt.Content != "" {
+			contentDelta := map[string]any{
+				"content": evt.Content,
+			}
+			if !s.firstChunkSent {
+				contentDelta["role"] = "assistant"
+				s.firstChunkSent = true
+			}
+			choices = append(choices, openaifmt.BuildChatStreamDeltaChoice(0, contentDelta))
+		}
+	}
+	return choices
+}
+
+// resolveToolCalls runs complete calls through the request's tool call policy.
+// With parallel tool calls disabled, anything after the first emitted call is
+// discarded.
+func (s *chatStreamRuntime) resolveToolCalls(calls []util.ParsedToolCall) ([]util.ParsedToolCall, string) {
+	if !s.toolGate.active() {
+		return calls, ""
+	}
+	s.toolCallsResolved = true
+	if s.toolGate.policy.DisableParallel && s.toolCallsDoneEmitted {
+		return nil, ""
+	}
+	return s.toolGate.resolve(s.text.String(), calls)
+}
+
+func (s *chatStreamRuntime) contentChoices(text string) []map[string]any {
+	if text == "" {
+		return nil
+	}
+	delta := map[string]any{"content": text}
+	if !s.firstChunkSent {
+		delta["role"] = "assistant"
+		s.firstChunkSent = true
+	}
+	return []map[string]any{openaifmt.BuildChatStreamDeltaChoice(0, delta)}
+}
+
+// persistCompletion stores the assembled non-stream equivalent of this stream
+// for `store: true` requests.
+func (s *chatStreamRuntime) persistCompletion(finalThinking, finalText, finishReason string) {
+	if s.persist == nil {
+		return
+	}
+	calls := s.emittedCalls
+	if len(calls) == 0 && s.toolCallsEmitted {
+		calls = util.ParseToolCalls(finalText, s.toolNames)
+	}
+	obj := openaifmt.BuildChatCompletionFromCalls(s.completionID, s.model, s.finalPrompt, finalThinking, finalText, calls, finishReason)
+	openaifmt.AttachChatAnnotations(obj, s.citationSpans)
+	openaifmt.AttachCachedTokens(obj, s.cachedTokens)
+	obj["created"] = s.created
+	s.persist(obj)
+}
@@ -0,0 +1,117 @@
+package openai
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ds2api/internal/util"
+)
+
+var searchSSELines = []string{
+	`data: {"p":"response/search_results","v":[{"url":"https://go.dev","title":"Go","snippet":"The Go language","cite_index":1},{"url":"https://rust-lang.org","title":"Rust","cite_index":2}]}`,
+	`data: {"p":"response/content","v":"Go is simple[cit"}`,
+	`data: {"p":"response/content","v":"ation:1]. Rust is safe[citation:2][citation:7]."}`,
+	`data: [DONE]`,
+}
+
+func TestHandleNonStreamMapsCitationsToURLAnnotations(t *testing.T) {
+	h := &Handler{}
+	rec := httptest.NewRecorder()
+	h.handleNonStream(rec, makeSSEHTTPResponse(searchSSELines...), "cid", util.StandardRequest{ResponseModel: "deepseek-chat-search", FinalPrompt: "prompt", Search: true}, 0, toolCallGate{}, nil)
+
+	out := decodeJSONBody(t, rec.Body.String())
+	msg := out["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	if msg["content"] != "Go is simple[1]. Rust is safe[2]." {
+		t.Fatalf("unexpected content %q", msg["content"])
+	}
+	annotations, _ := msg["annotations"].([]any)
+	if len(annotations) != 2 {
+		t.Fatalf("expected two annotations, got %#v", msg["annotations"])
+	}
+	first := annotations[0].(map[string]any)["url_citation"].(map[string]any)
+	if first["url"] != "https://go.dev" || first["start_index"] != float64(12) || first["end_index"] != float64(15) {
+		t.Fatalf("unexpected annotation %#v", first)
+	}
+}
+
+func TestHandleNonStreamLeavesMarkersAloneWithoutSearch(t *testing.T) {
+	h := &Handler{}
+	rec := httptest.NewRecorder()
+	h.handleNonStream(rec, makeSSEHTTPResponse(searchSSELines...), "cid", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt"}, 0, toolCallGate{}, nil)
+
+	out := decodeJSONBody(t, rec.Body.String())
+	msg := out["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	if _, ok := msg["annotations"]; ok {
+		t.Fatalf("did not expect annotations, got %#v", msg)
+	}
+}
+
+func TestHandleStreamEmitsAnnotationDeltas(t *testing.T) {
+	h := &Handler{}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	h.handleStream(rec, req, makeSSEHTTPResponse(searchSSELines...), "cid", util.StandardRequest{ResponseModel: "deepseek-chat-search", FinalPrompt: "prompt", Search: true}, 0, toolCallGate{}, nil)
+
+	frames, _ := parseSSEDataFrames(t, rec.Body.String())
+	var content strings.Builder
+	var urls []string
+	for _, frame := range frames {
+		for _, c := range frame["choices"].([]any) {
+			delta, _ := c.(map[string]any)["delta"].(map[string]any)
+			if s, ok := delta["content"].(string); ok {
+				content.WriteString(s)
+			}
+			if anns, ok := delta["annotations"].([]any); ok {
+				for _, a := range anns {
+					urls = append(urls, a.(map[string]any)["url_citation"].(map[string]any)["url"].(string))
+				}
+			}
+		}
+	}
+	if content.String() != "Go is simple[1]. Rust is safe[2]." {
+		t.Fatalf("unexpected streamed content %q", content.String())
+	}
+	if strings.Join(urls, ",") != "https://go.dev,https://rust-lang.org" {
+		t.Fatalf("unexpected annotation urls %v", urls)
+	}
+}
+
+func TestHandleResponsesStreamEmitsAnnotationEvents(t *testing.T) {
+	h := &Handler{}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	h.handleResponsesStream(rec, req, makeSSEHTTPResponse(searchSSELines...), "owner-a", "resp_cite", "", util.StandardRequest{ResponseModel: "deepseek-chat-search", FinalPrompt: "prompt", Search: true, ToolChoice: util.DefaultToolChoicePolicy()}, 0, toolCallGate{})
+
+	added, ok := extractSSEEventPayload(rec.Body.String(), "response.output_text.annotation.added")
+	if !ok {
+		t.Fatalf("expected annotation event, body=%s", rec.Body.String())
+	}
+	annotation := added["annotation"].(map[string]any)
+	if annotation["url"] != "https://go.dev" || annotation["start_index"] != float64(12) {
+		t.Fatalf("unexpected annotation %#v", annotation)
+	}
+	completed, _ := extractSSEEventPayload(rec.Body.String(), "response.completed")
+	response := completed["response"].(map[string]any)
+	part := response["output"].([]any)[0].(map[string]any)["content"].([]any)[0].(map[string]any)
+	if part["text"] != "Go is simple[1]. Rust is safe[2]." {
+		t.Fatalf("unexpected output text %#v", part)
+	}
+	if anns, _ := part["annotations"].([]any); len(anns) != 2 {
+		t.Fatalf("expected annotations on output_text, got %#v", part)
+	}
+}
+
+func TestHandleResponsesNonStreamAttachesAnnotations(t *testing.T) {
+	h := &Handler{}
+	rec := httptest.NewRecorder()
+	h.handleResponsesNonStream(rec, makeSSEHTTPResponse(searchSSELines...), "owner-a", "resp_cite2", "", util.StandardRequest{ResponseModel: "deepseek-chat-search", FinalPrompt: "prompt", Search: true, ToolChoice: util.DefaultToolChoicePolicy()}, 0, toolCallGate{})
+
+	out := decodeJSONBody(t, rec.Body.String())
+	part := out["output"].([]any)[0].(map[string]any)["content"].([]any)[0].(map[string]any)
+	anns, _ := part["annotations"].([]any)
+	if len(anns) != 2 || anns[1].(map[string]any)["url"] != "https://rust-lang.org" {
+		t.Fatalf("unexpected annotations %#v", part)
+	}
+}
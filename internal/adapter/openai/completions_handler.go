@@ -0,0 +1,195 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	"ds2api/internal/deepseek"
+	openaifmt "ds2api/internal/format/openai"
+	"ds2api/internal/sse"
+	streamengine "ds2api/internal/stream"
+	"ds2api/internal/util"
+)
+
+func (h *Handler) Completions(w http.ResponseWriter, r *http.Request) {
+	a, err := h.Auth.Determine(r)
+	if err != nil {
+		writeOpenAIAuthError(w, err)
+		return
+	}
+	defer h.Auth.Release(a)
+	r = r.WithContext(auth.WithAuth(r.Context(), a))
+
+	var req map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	prompts, stdReqs, err := normalizeOpenAICompletionRequest(h.Store, req, requestTraceID(r))
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Prompts run one after another, each upstream opened once the previous
+	// one has finished. The first is opened before anything is written, so
+	// its failure is a plain error response.
+	first, status, message := h.openCompletionUpstream(r.Context(), a, stdReqs[0])
+	if first == nil {
+		writeOpenAIError(w, status, message)
+		return
+	}
+
+	completionID := "cmpl-" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	created := time.Now().Unix()
+	echo := util.ToBool(req["echo"])
+	if util.ToBool(req["stream"]) {
+		h.handleCompletionsStream(w, r, a, first, stdReqs, prompts, completionID, created, echo)
+		return
+	}
+	h.handleCompletionsNonStream(w, r, a, first, stdReqs, prompts, completionID, created, echo)
+}
+
+// openCompletionUpstream starts one upstream completion. On failure it
+// returns a nil response with the status and message to report.
+func (h *Handler) openCompletionUpstream(ctx context.Context, a *auth.RequestAuth, stdReq util.StandardRequest) (*http.Response, int, string) {
+	sessionID, err := h.DS.CreateSession(ctx, a, 3)
+	if err != nil {
+		if a.UseConfigToken {
+			return nil, http.StatusUnauthorized, "Account token is invalid. Please re-login the account in admin."
+		}
+		return nil, http.StatusUnauthorized, "Invalid token. If this should be a DS2API key, add it to config.keys first."
+	}
+	pow, err := h.DS.GetPow(ctx, a, 3)
+	if err != nil {
+		return nil, http.StatusUnauthorized, "Failed to get PoW (invalid token or unknown error)."
+	}
+	resp, err := h.DS.CallCompletion(ctx, a, stdReq.CompletionPayload(sessionID), pow, 3)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Failed to get completion."
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, resp.StatusCode, strings.TrimSpace(string(body))
+	}
+	return resp, 0, ""
+}
+
+func (h *Handler) handleCompletionsNonStream(w http.ResponseWriter, r *http.Request, a *auth.RequestAuth, first *http.Response, stdReqs []util.StandardRequest, prompts []string, completionID string, created int64, echo bool) {
+	choices := make([]map[string]any, 0, len(stdReqs))
+	promptTokens, reasoningTokens, completionTokens := 0, 0, 0
+	resp := first
+	for i, stdReq := range stdReqs {
+		if i > 0 {
+			var status int
+			var message string
+			if resp, status, message = h.openCompletionUpstream(r.Context(), a, stdReq); resp == nil {
+				writeOpenAIError(w, status, message)
+				return
+			}
+		}
+		result := sse.CollectStreamWithPolicy(resp, stdReq.Thinking, true, stdReq.StopPolicy)
+		finishReason := "stop"
+		switch result.FinishReason {
+		case util.OutputFinishMaxTokens:
+			finishReason = "length"
+		case util.OutputFinishContentFilter:
+			finishReason = "content_filter"
+		}
+		text := result.Text
+		if echo {
+			text = prompts[i] + text
+		}
+		choices = append(choices, openaifmt.BuildTextCompletionChoice(i, text, finishReason))
+		promptTokens += util.CountTokens(stdReq.FinalPrompt)
+		reasoningTokens += util.CountTokens(result.Thinking)
+		completionTokens += util.CountTokens(result.Text)
+	}
+	model := stdReqs[0].ResponseModel
+	usage := openaifmt.BuildChatUsageFromCounts(promptTokens, reasoningTokens, completionTokens)
+	writeJSON(w, http.StatusOK, openaifmt.BuildTextCompletion(completionID, created, model, choices, usage))
+}
+
+func (h *Handler) handleCompletionsStream(w http.ResponseWriter, r *http.Request, a *auth.RequestAuth, first *http.Response, stdReqs []util.StandardRequest, prompts []string, completionID string, created int64, echo bool) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache, no-transform")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	rc := http.NewResponseController(w)
+	_, canFlush := w.(http.Flusher)
+	if !canFlush {
+		config.Logger.Warn("[stream] response writer does not support flush; streaming may be buffered")
+	}
+
+	model := stdReqs[0].ResponseModel
+	includeUsage := stdReqs[0].IncludeUsage
+	promptTokens, reasoningTokens, completionTokens := 0, 0, 0
+	resp := first
+	for i, stdReq := range stdReqs {
+		if i > 0 {
+			var status int
+			var message string
+			if resp, status, message = h.openCompletionUpstream(r.Context(), a, stdReq); resp == nil {
+				// Earlier choices are already streamed: report the failure
+				// in-band and end the stream without [DONE].
+				sendCompletionsStreamChunk(w, rc, canFlush, openAIErrorBody(status, message, ""))
+				return
+			}
+		}
+		echoText := ""
+		if echo {
+			echoText = prompts[i]
+		}
+		streamRuntime := newCompletionsStreamRuntime(w, rc, canFlush, completionID, created, model, i, stdReq.Search, echoText, stdReq.StopPolicy)
+		initialType := "text"
+		if stdReq.Thinking {
+			initialType = "thinking"
+		}
+		streamengine.ConsumeSSE(streamengine.ConsumeConfig{
+			Context:             r.Context(),
+			Body:                resp.Body,
+			ThinkingEnabled:     stdReq.Thinking,
+			InitialType:         initialType,
+			KeepAliveInterval:   time.Duration(deepseek.KeepAliveTimeout) * time.Second,
+			IdleTimeout:         time.Duration(deepseek.StreamIdleTimeout) * time.Second,
+			MaxKeepAliveNoInput: deepseek.MaxKeepaliveCount,
+		}, streamengine.ConsumeHooks{
+			OnKeepAlive: func() {
+				streamRuntime.sendKeepAlive()
+			},
+			OnParsed: streamRuntime.onParsed,
+			OnFinalize: func(reason streamengine.StopReason, _ error) {
+				if string(reason) == "content_filter" {
+					streamRuntime.finalize("content_filter")
+					return
+				}
+				streamRuntime.finalize("stop")
+			},
+		})
+		_ = resp.Body.Close()
+		if r.Context().Err() != nil {
+			return
+		}
+		promptTokens += util.CountTokens(stdReq.FinalPrompt)
+		reasoningTokens += util.CountTokens(streamRuntime.thinking.String())
+		completionTokens += util.CountTokens(streamRuntime.text.String())
+	}
+
+	usage := openaifmt.BuildChatUsageFromCounts(promptTokens, reasoningTokens, completionTokens)
+	if includeUsage {
+		sendCompletionsStreamChunk(w, rc, canFlush, openaifmt.BuildTextCompletionChunk(completionID, created, model, []map[string]any{}, usage))
+	}
+	_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	if canFlush {
+		_ = rc.Flush()
+	}
+}
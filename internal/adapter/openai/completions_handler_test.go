@@ -0,0 +1,195 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/auth"
+)
+
+type sequenceDSStub struct {
+	bodies   []string
+	prompts  []string
+	sessions int
+	// open counts upstream bodies not closed yet; maxOpen is its peak.
+	open, maxOpen int
+	// failAt makes the call with that 1-based index answer 502.
+	failAt int
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	stub   *sequenceDSStub
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	if !b.closed {
+		b.closed = true
+		b.stub.open--
+	}
+	return b.ReadCloser.Close()
+}
+
+func (m *sequenceDSStub) CreateSession(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	m.sessions++
+	return fmt.Sprintf("session-%d", m.sessions), nil
+}
+
+func (m *sequenceDSStub) GetPow(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "pow", nil
+}
+
+func (m *sequenceDSStub) CallCompletion(_ context.Context, _ *auth.RequestAuth, payload map[string]any, _ string, _ int) (*http.Response, error) {
+	prompt, _ := payload["prompt"].(string)
+	m.prompts = append(m.prompts, prompt)
+	body := m.bodies[0]
+	if len(m.bodies) > 1 {
+		m.bodies = m.bodies[1:]
+	}
+	if len(m.prompts) == m.failAt {
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader("upstream down"))}, nil
+	}
+	resp := makeOpenAISSEHTTPResponse(body, "data: [DONE]")
+	resp.Body = &trackedBody{ReadCloser: resp.Body, stub: m}
+	m.open++
+	m.maxOpen = max(m.maxOpen, m.open)
+	return resp, nil
+}
+
+func serveCompletions(t *testing.T, ds *sequenceDSStub, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	h := &Handler{Store: mockOpenAIConfig{wideInput: true}, Auth: streamStatusAuthStub{}, DS: ds}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	req := httptest.NewRequest(http.MethodPost, "/v1/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCompletionsNonStreamArrayPromptWithEcho(t *testing.T) {
+	ds := &sequenceDSStub{bodies: []string{
+		`data: {"p":"response/content","v":" world"}`,
+		`data: {"p":"response/content","v":" there"}`,
+	}}
+	rec := serveCompletions(t, ds, `{"model":"deepseek-chat","prompt":["hello","hi"],"echo":true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d body=%s", rec.Code, rec.Body.String())
+	}
+	out := decodeJSONBody(t, rec.Body.String())
+	if out["object"] != "text_completion" || !strings.HasPrefix(out["id"].(string), "cmpl-") {
+		t.Fatalf("unexpected envelope: %#v", out)
+	}
+	choices, _ := out["choices"].([]any)
+	if len(choices) != 2 {
+		t.Fatalf("expected 2 choices, got %#v", out["choices"])
+	}
+	first, _ := choices[0].(map[string]any)
+	second, _ := choices[1].(map[string]any)
+	if first["text"] != "hello world" || second["text"] != "hi there" || second["index"] != float64(1) {
+		t.Fatalf("unexpected choices: %#v", choices)
+	}
+	if len(ds.prompts) != 2 || !strings.Contains(ds.prompts[1], "hi") {
+		t.Fatalf("expected one upstream call per prompt, got %#v", ds.prompts)
+	}
+}
+
+func TestCompletionsSuffixAndMaxTokens(t *testing.T) {
+	ds := &sequenceDSStub{bodies: []string{`data: {"p":"response/content","v":"one two three four five six"}`}}
+	rec := serveCompletions(t, ds, `{"model":"deepseek-chat","prompt":"def f(","suffix":"):","max_tokens":2}`)
+	out := decodeJSONBody(t, rec.Body.String())
+	choice, _ := out["choices"].([]any)[0].(map[string]any)
+	if choice["finish_reason"] != "length" {
+		t.Fatalf("expected finish_reason=length, got %#v", choice)
+	}
+	if text, _ := choice["text"].(string); len(text) >= len("one two three four five six") {
+		t.Fatalf("expected truncated text, got %q", text)
+	}
+	if !strings.Contains(ds.prompts[0], "<suffix>):</suffix>") {
+		t.Fatalf("expected suffix in upstream prompt, got %q", ds.prompts[0])
+	}
+}
+
+func TestCompletionsStreamStopSequenceAndUsage(t *testing.T) {
+	ds := &sequenceDSStub{bodies: []string{`data: {"p":"response/content","v":"alpha END beta"}`}}
+	rec := serveCompletions(t, ds, `{"model":"deepseek-chat","prompt":"x","stream":true,"stop":"END","stream_options":{"include_usage":true}}`)
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done || len(frames) < 3 {
+		t.Fatalf("unexpected stream body=%s", rec.Body.String())
+	}
+	text := ""
+	finish := ""
+	for _, frame := range frames {
+		if frame["object"] != "text_completion" {
+			t.Fatalf("unexpected chunk object: %#v", frame)
+		}
+		choices, _ := frame["choices"].([]any)
+		for _, item := range choices {
+			choice, _ := item.(map[string]any)
+			text += choice["text"].(string)
+			if fr, ok := choice["finish_reason"].(string); ok {
+				finish = fr
+			}
+		}
+	}
+	if text != "alpha " || finish != "stop" {
+		t.Fatalf("unexpected text=%q finish=%q", text, finish)
+	}
+	last := frames[len(frames)-1]
+	if choices, _ := last["choices"].([]any); len(choices) != 0 || last["usage"] == nil {
+		t.Fatalf("expected trailing usage chunk, got %#v", last)
+	}
+}
+
+func TestCompletionsRejectsTokenArrayPrompt(t *testing.T) {
+	rec := serveCompletions(t, &sequenceDSStub{bodies: []string{""}}, `{"model":"deepseek-chat","prompt":[[1,2,3]]}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCompletionsRunsPromptsOneAtATime(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		ds := &sequenceDSStub{bodies: []string{`data: {"p":"response/content","v":"ok"}`}}
+		rec := serveCompletions(t, ds, fmt.Sprintf(`{"model":"deepseek-chat","prompt":["a","b","c"],"stream":%t}`, stream))
+		if rec.Code != http.StatusOK || len(ds.prompts) != 3 {
+			t.Fatalf("stream=%t: unexpected status %d calls=%d body=%s", stream, rec.Code, len(ds.prompts), rec.Body.String())
+		}
+		if ds.maxOpen != 1 || ds.open != 0 {
+			t.Fatalf("stream=%t: expected one upstream open at a time, peak=%d left=%d", stream, ds.maxOpen, ds.open)
+		}
+	}
+}
+
+func TestCompletionsReportsLaterPromptFailure(t *testing.T) {
+	ds := &sequenceDSStub{bodies: []string{`data: {"p":"response/content","v":"ok"}`}, failAt: 2}
+	rec := serveCompletions(t, ds, `{"model":"deepseek-chat","prompt":["a","b","c"]}`)
+	if rec.Code != http.StatusBadGateway || len(ds.prompts) != 2 {
+		t.Fatalf("expected the second prompt's 502 and no third call, got %d calls=%d", rec.Code, len(ds.prompts))
+	}
+
+	ds = &sequenceDSStub{bodies: []string{`data: {"p":"response/content","v":"ok"}`}, failAt: 2}
+	rec = serveCompletions(t, ds, `{"model":"deepseek-chat","prompt":["a","b","c"],"stream":true}`)
+	body := rec.Body.String()
+	if !strings.Contains(body, `"text":"ok"`) || !strings.Contains(body, "upstream down") || strings.Contains(body, "[DONE]") {
+		t.Fatalf("expected the first choice then an in-band error, got %s", body)
+	}
+}
+
+func TestCompletionsCapsPromptCount(t *testing.T) {
+	prompts := strings.TrimSuffix(strings.Repeat(`"x",`, completionsMaxPrompts+1), ",")
+	ds := &sequenceDSStub{bodies: []string{""}}
+	rec := serveCompletions(t, ds, `{"model":"deepseek-chat","prompt":[`+prompts+`]}`)
+	if rec.Code != http.StatusBadRequest || ds.sessions != 0 {
+		t.Fatalf("expected 400 before any upstream call, got %d sessions=%d", rec.Code, ds.sessions)
+	}
+}
@@ -0,0 +1,131 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	openaifmt "ds2api/internal/format/openai"
+	"ds2api/internal/sse"
+	streamengine "ds2api/internal/stream"
+	"ds2api/internal/util"
+)
+
+// completionsStreamRuntime streams one choice of a legacy text completion.
+// Array prompts run one runtime per choice over the same response writer.
+type completionsStreamRuntime struct {
+	w        http.ResponseWriter
+	rc       *http.ResponseController
+	canFlush bool
+
+	completionID string
+	created      int64
+	model        string
+	index        int
+
+	searchEnabled bool
+	echoText      string
+
+	limiter  *util.OutputLimiter
+	thinking strings.Builder
+	text     strings.Builder
+}
+
+func newCompletionsStreamRuntime(
+	w http.ResponseWriter,
+	rc *http.ResponseController,
+	canFlush bool,
+	completionID string,
+	created int64,
+	model string,
+	index int,
+	searchEnabled bool,
+	echoText string,
+	stopPolicy util.StopPolicy,
+) *completionsStreamRuntime {
+	return &completionsStreamRuntime{
+		w:             w,
+		rc:            rc,
+		canFlush:      canFlush,
+		completionID:  completionID,
+		created:       created,
+		model:         model,
+		index:         index,
+		searchEnabled: searchEnabled,
+		echoText:      echoText,
+		limiter:       util.NewOutputLimiter(stopPolicy),
+	}
+}
+
+func sendCompletionsStreamChunk(w http.ResponseWriter, rc *http.ResponseController, canFlush bool, v any) {
+	b, _ := json.Marshal(v)
+	_, _ = w.Write([]byte("data: "))
+	_, _ = w.Write(b)
+	_, _ = w.Write([]byte("\n\n"))
+	if canFlush {
+		_ = rc.Flush()
+	}
+}
+
+func (s *completionsStreamRuntime) sendKeepAlive() {
+	if !s.canFlush {
+		return
+	}
+	_, _ = s.w.Write([]byte(": keep-alive\n\n"))
+	_ = s.rc.Flush()
+}
+
+func (s *completionsStreamRuntime) sendText(text string, finishReason any) {
+	if s.echoText != "" {
+		text = s.echoText + text
+		s.echoText = ""
+	}
+	if text == "" && finishReason == nil {
+		return
+	}
+	choice := openaifmt.BuildTextCompletionChoice(s.index, text, finishReason)
+	sendCompletionsStreamChunk(s.w, s.rc, s.canFlush, openaifmt.BuildTextCompletionChunk(s.completionID, s.created, s.model, []map[string]any{choice}, nil))
+}
+
+func (s *completionsStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedDecision {
+	if !parsed.Parsed {
+		return streamengine.ParsedDecision{}
+	}
+	if parsed.ContentFilter || parsed.ErrorMessage != "" {
+		return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReason("content_filter")}
+	}
+	if parsed.Stop {
+		return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReasonHandlerRequested}
+	}
+
+	contentSeen := false
+	for _, p := range parsed.Parts {
+		if p.Text == "" || (s.searchEnabled && sse.IsCitation(p.Text)) {
+			continue
+		}
+		contentSeen = true
+		if p.Type == "thinking" {
+			// Text completions have no reasoning channel; thinking only
+			// counts towards usage and max_tokens.
+			s.thinking.WriteString(s.limiter.PushThinking(p.Text))
+		} else if text := s.limiter.PushText(p.Text); text != "" {
+			s.text.WriteString(text)
+			s.sendText(text, nil)
+		}
+		if s.limiter.Done() {
+			return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReason(s.limiter.FinishReason()), ContentSeen: contentSeen}
+		}
+	}
+	return streamengine.ParsedDecision{ContentSeen: contentSeen}
+}
+
+func (s *completionsStreamRuntime) finalize(finishReason string) {
+	if tail := s.limiter.Flush(); tail != "" {
+		s.text.WriteString(tail)
+		s.sendText(tail, nil)
+	}
+	if s.limiter.FinishReason() == util.OutputFinishMaxTokens {
+		finishReason = "length"
+	}
+	s.sendText("", finishReason)
+}
@@ -15,6 +15,14 @@ type AuthResolver interface {
 	Release(a *auth.RequestAuth)
 }
 
+// BackgroundAuthResolver is implemented by resolvers that can bind a pooled
+// account without an incoming request; the batch runner requires it.
+type BackgroundAuthResolver interface {
+	CheckBackground(job auth.BackgroundRequest) error
+	AcquireBackground(ctx context.Context, job auth.BackgroundRequest) (*auth.RequestAuth, error)
+	ServeBackground(a *auth.RequestAuth, w http.ResponseWriter, req *http.Request, serve http.HandlerFunc)
+}
+
 type DeepSeekCaller interface {
 	CreateSession(ctx context.Context, a *auth.RequestAuth, maxAttempts int) (string, error)
 	GetPow(ctx context.Context, a *auth.RequestAuth, maxAttempts int) (string, error)
@@ -26,10 +34,12 @@ type ConfigReader interface {
 	CompatWideInputStrictOutput() bool
 	ToolcallMode() string
 	ToolcallEarlyEmitConfidence() string
+	ToolcallInvalidArgs() string
 	ResponsesStoreTTLSeconds() int
-	EmbeddingsProvider() string
+	EmbeddingsSettings() config.EmbeddingsConfig
 }
 
 var _ AuthResolver = (*auth.Resolver)(nil)
+var _ BackgroundAuthResolver = (*auth.Resolver)(nil)
 var _ DeepSeekCaller = (*deepseek.Client)(nil)
 var _ ConfigReader = (*config.Store)(nil)
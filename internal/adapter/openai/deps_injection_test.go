@@ -1,14 +1,20 @@
 package openai
 
-import "testing"
+import (
+	"testing"
+
+	"ds2api/internal/config"
+)
 
 type mockOpenAIConfig struct {
 	aliases      map[string]string
 	wideInput    bool
 	toolMode     string
 	earlyEmit    string
+	invalidArgs  string
 	responsesTTL int
 	embedProv    string
+	embedCfg     config.EmbeddingsConfig
 }
 
 func (m mockOpenAIConfig) ModelAliases() map[string]string { return m.aliases }
@@ -17,8 +23,16 @@ func (m mockOpenAIConfig) CompatWideInputStrictOutput() bool {
 }
 func (m mockOpenAIConfig) ToolcallMode() string                { return m.toolMode }
 func (m mockOpenAIConfig) ToolcallEarlyEmitConfidence() string { return m.earlyEmit }
+func (m mockOpenAIConfig) ToolcallInvalidArgs() string         { return m.invalidArgs }
 func (m mockOpenAIConfig) ResponsesStoreTTLSeconds() int       { return m.responsesTTL }
 func (m mockOpenAIConfig) EmbeddingsProvider() string          { return m.embedProv }
+func (m mockOpenAIConfig) EmbeddingsSettings() config.EmbeddingsConfig {
+	cfg := m.embedCfg
+	if cfg.Provider == "" {
+		cfg.Provider = m.embedProv
+	}
+	return cfg
+}
 
 func TestNormalizeOpenAIChatRequestWithConfigInterface(t *testing.T) {
 	cfg := mockOpenAIConfig{
@@ -1,27 +1,21 @@
 package openai
 
 import (
-	"crypto/sha256"
-	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
-	"ds2api/internal/auth"
 	"ds2api/internal/config"
+	"ds2api/internal/embeddings"
 	"ds2api/internal/util"
 )
 
 func (h *Handler) Embeddings(w http.ResponseWriter, r *http.Request) {
 	a, err := h.Auth.Determine(r)
 	if err != nil {
-		status := http.StatusUnauthorized
-		detail := err.Error()
-		if err == auth.ErrNoAccount {
-			status = http.StatusTooManyRequests
-		}
-		writeOpenAIError(w, status, detail)
+		writeOpenAIAuthError(w, err)
 		return
 	}
 	defer h.Auth.Release(a)
@@ -48,30 +42,50 @@ func (h *Handler) Embeddings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	provider := ""
+	dimensions, err := embeddingDimensions(req["dimensions"])
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	encoding := "float"
+	if raw, ok := req["encoding_format"]; ok && raw != nil {
+		encoding, _ = raw.(string)
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if encoding != "float" && encoding != "base64" {
+			writeOpenAIError(w, http.StatusBadRequest, "encoding_format must be 'float' or 'base64'.")
+			return
+		}
+	}
+
+	var settings config.EmbeddingsConfig
 	if h.Store != nil {
-		provider = strings.ToLower(strings.TrimSpace(h.Store.EmbeddingsProvider()))
+		settings = h.Store.EmbeddingsSettings()
 	}
-	if provider == "" {
-		writeOpenAIError(w, http.StatusNotImplemented, "Embeddings provider is not configured. Set embeddings.provider in config.")
+	provider, err := embeddings.New(settings)
+	if err != nil {
+		writeEmbeddingsError(w, err)
 		return
 	}
-	switch provider {
-	case "mock", "deterministic", "builtin":
-		// supported local deterministic provider
-	default:
-		writeOpenAIError(w, http.StatusNotImplemented, fmt.Sprintf("Embeddings provider '%s' is not supported.", provider))
+	result, err := provider.Embed(r.Context(), embeddings.Request{Model: model, Inputs: inputs, Dimensions: dimensions})
+	if err != nil {
+		writeEmbeddingsError(w, err)
 		return
 	}
 
 	data := make([]map[string]any, 0, len(inputs))
-	totalTokens := 0
+	totalTokens := result.PromptTokens
 	for i, input := range inputs {
-		totalTokens += util.EstimateTokens(input)
+		if result.PromptTokens == 0 {
+			totalTokens += util.CountTokens(input)
+		}
+		var vector any = result.Vectors[i]
+		if encoding == "base64" {
+			vector = embeddings.EncodeBase64(result.Vectors[i])
+		}
 		data = append(data, map[string]any{
 			"object":    "embedding",
 			"index":     i,
-			"embedding": deterministicEmbedding(input),
+			"embedding": vector,
 		})
 	}
 	writeJSON(w, http.StatusOK, map[string]any{
@@ -85,6 +99,28 @@ func (h *Handler) Embeddings(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+const embeddingsMaxDimensions = 8192
+
+func embeddingDimensions(raw any) (int, error) {
+	if raw == nil {
+		return 0, nil
+	}
+	n, ok := raw.(float64)
+	if !ok || n != float64(int(n)) || n < 1 || n > embeddingsMaxDimensions {
+		return 0, fmt.Errorf("dimensions must be an integer between 1 and %d.", embeddingsMaxDimensions)
+	}
+	return int(n), nil
+}
+
+func writeEmbeddingsError(w http.ResponseWriter, err error) {
+	var perr *embeddings.Error
+	if errors.As(err, &perr) {
+		writeOpenAIError(w, perr.Status, perr.Message)
+		return
+	}
+	writeOpenAIError(w, http.StatusBadGateway, err.Error())
+}
+
 func extractEmbeddingInputs(raw any) []string {
 	switch v := raw.(type) {
 	case string:
@@ -117,22 +153,3 @@ func extractEmbeddingInputs(raw any) []string {
 		return nil
 	}
 }
-
-func deterministicEmbedding(input string) []float64 {
-	// Keep response shape stable without external dependencies.
-	const dims = 64
-	out := make([]float64, dims)
-	seed := sha256.Sum256([]byte(input))
-	buf := seed[:]
-	for i := 0; i < dims; i++ {
-		if len(buf) < 4 {
-			next := sha256.Sum256(buf)
-			buf = next[:]
-		}
-		v := binary.BigEndian.Uint32(buf[:4])
-		buf = buf[4:]
-		// map [0, 2^32) -> [-1, 1]
-		out[i] = (float64(v)/2147483647.5 - 1.0)
-	}
-	return out
-}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
@@ -94,3 +95,65 @@ func TestEmbeddingsRouteProviderMissing(t *testing.T) {
 		t.Fatalf("expected error.param in response: %#v", out)
 	}
 }
+
+func serveEmbeddings(t *testing.T, cfgJSON, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	store, resolver := newResolverWithConfigJSON(t, cfgJSON)
+	h := &Handler{Store: store, Auth: resolver}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	req := httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestEmbeddingsRouteLocalProviderBase64AndDimensions(t *testing.T) {
+	rec := serveEmbeddings(t, `{"embeddings":{"provider":"local"}}`, `{"model":"gpt-4o","input":"hello","dimensions":16,"encoding_format":"base64"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	out := decodeJSONBody(t, rec.Body.String())
+	data, _ := out["data"].([]any)
+	item, _ := data[0].(map[string]any)
+	enc, ok := item["embedding"].(string)
+	if !ok || len(enc) != 88 {
+		t.Fatalf("expected base64 of 16 float32 values, got %#v", item["embedding"])
+	}
+}
+
+func TestEmbeddingsRouteRejectsBadDimensionsAndEncoding(t *testing.T) {
+	cfg := `{"embeddings":{"provider":"local"}}`
+	if rec := serveEmbeddings(t, cfg, `{"model":"gpt-4o","input":"x","dimensions":0}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for dimensions=0, got %d", rec.Code)
+	}
+	if rec := serveEmbeddings(t, cfg, `{"model":"gpt-4o","input":"x","encoding_format":"int8"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad encoding_format, got %d", rec.Code)
+	}
+}
+
+func TestEmbeddingsRouteForwardsToOpenAICompatibleUpstream(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["model"] != "bge-m3" {
+			t.Errorf("expected mapped model, got %#v", req["model"])
+		}
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"index":0,"embedding":[0.1,0.2,0.3]}],"usage":{"prompt_tokens":3,"total_tokens":3}}`))
+	}))
+	defer upstream.Close()
+
+	rec := serveEmbeddings(t, `{"embeddings":{"provider":"openai","base_url":"`+upstream.URL+`","model_map":{"*":"bge-m3"}}}`, `{"model":"gpt-4o","input":"hello"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	out := decodeJSONBody(t, rec.Body.String())
+	usage, _ := out["usage"].(map[string]any)
+	data, _ := out["data"].([]any)
+	item, _ := data[0].(map[string]any)
+	vec, _ := item["embedding"].([]any)
+	if len(vec) != 3 || usage["prompt_tokens"] != float64(3) || out["model"] != "gpt-4o" {
+		t.Fatalf("unexpected forwarded response: %#v", out)
+	}
+}
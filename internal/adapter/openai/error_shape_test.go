@@ -2,9 +2,15 @@ package openai
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	"ds2api/internal/ratelimit"
 )
 
 func TestWriteOpenAIErrorIncludesUnifiedFields(t *testing.T) {
@@ -32,3 +38,60 @@ func TestWriteOpenAIErrorIncludesUnifiedFields(t *testing.T) {
 		t.Fatal("expected param field")
 	}
 }
+
+func TestWriteOpenAIAuthErrorDistinguishesExpiredKeys(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{auth.ErrAPIKeyExpired, http.StatusUnauthorized, "api_key_expired"},
+		{auth.ErrUnauthorized, http.StatusUnauthorized, "authentication_failed"},
+		{auth.ErrNoAccount, http.StatusTooManyRequests, "rate_limit_exceeded"},
+		{fmt.Errorf("%w: model not allowed", auth.ErrForbidden), http.StatusForbidden, "forbidden"},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		writeOpenAIAuthError(rec, tc.err)
+		var body map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		errObj, _ := body["error"].(map[string]any)
+		if rec.Code != tc.status || errObj["code"] != tc.code {
+			t.Fatalf("%v: expected %d %s, got %d %v", tc.err, tc.status, tc.code, rec.Code, errObj["code"])
+		}
+	}
+}
+
+func TestWriteOpenAIAuthErrorSetsRateLimitHeaders(t *testing.T) {
+	err := &auth.RateLimitError{Decision: ratelimit.Decision{
+		Status: ratelimit.Status{
+			Limits:        config.RateLimitConfig{RequestsPerMinute: 10},
+			ResetRequests: 6 * time.Second,
+		},
+		Reason:     ratelimit.ReasonRequests,
+		RetryAfter: 5500 * time.Millisecond,
+	}}
+	rec := httptest.NewRecorder()
+	writeOpenAIAuthError(rec, err)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "6" || rec.Header().Get("x-ratelimit-limit-requests") != "10" || rec.Header().Get("x-ratelimit-remaining-requests") != "0" {
+		t.Fatalf("expected Retry-After and x-ratelimit-* headers, got %v", rec.Header())
+	}
+}
+
+func TestWriteOpenAIAuthErrorReportsExhaustedQuota(t *testing.T) {
+	err := &auth.QuotaError{Used: 120, Quota: 100, ResetsAt: time.Now().Add(time.Hour)}
+	rec := httptest.NewRecorder()
+	writeOpenAIAuthError(rec, err)
+	var body map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	errObj, _ := body["error"].(map[string]any)
+	if rec.Code != http.StatusTooManyRequests || errObj["code"] != "insufficient_quota" {
+		t.Fatalf("expected 429 insufficient_quota, got %d %v", rec.Code, errObj["code"])
+	}
+	if secs := rec.Header().Get("Retry-After"); secs != "3600" {
+		t.Fatalf("expected Retry-After until the reset, got %q", secs)
+	}
+}
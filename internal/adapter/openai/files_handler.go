@@ -0,0 +1,162 @@
+package openai
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	fileUploadMaxBytes     = 512 << 20
+	fileUploadMemoryBytes  = 32 << 20
+	fileListDefaultLimit   = 10000
+	fileListMaxLimit       = 10000
+	filePurposeBatch       = "batch"
+	filePurposeBatchOutput = "batch_output"
+)
+
+var uploadFilePurposes = map[string]bool{
+	"assistants": true,
+	"batch":      true,
+	"fine-tune":  true,
+	"vision":     true,
+	"user_data":  true,
+	"evals":      true,
+}
+
+func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.chatCompletionCaller(w, r)
+	if !ok {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, fileUploadMaxBytes)
+	if err := r.ParseMultipartForm(fileUploadMemoryBytes); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "request must be multipart/form-data with a file field.")
+		return
+	}
+	defer func() {
+		if r.MultipartForm != nil {
+			_ = r.MultipartForm.RemoveAll()
+		}
+	}()
+	purpose := strings.TrimSpace(r.FormValue("purpose"))
+	if !uploadFilePurposes[purpose] {
+		writeOpenAIError(w, http.StatusBadRequest, "purpose must be one of assistants, batch, fine-tune, vision, user_data or evals.")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "file is required.")
+		return
+	}
+	defer file.Close()
+	filename := filepath.Base(strings.TrimSpace(header.Filename))
+	if filename == "" || filename == "." || filename == string(filepath.Separator) {
+		filename = "upload"
+	}
+	rec, err := h.getFileStore().create(owner, filename, purpose, file)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, "failed to store file.")
+		return
+	}
+	writeJSON(w, http.StatusOK, rec.apiObject())
+}
+
+func (h *Handler) ListFiles(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.chatCompletionCaller(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	limit := fileListDefaultLimit
+	if raw := strings.TrimSpace(q.Get("limit")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > fileListMaxLimit {
+			writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d.", fileListMaxLimit))
+			return
+		}
+		limit = n
+	}
+	order := strings.ToLower(strings.TrimSpace(q.Get("order")))
+	if order != "" && order != "asc" && order != "desc" {
+		writeOpenAIError(w, http.StatusBadRequest, "order must be 'asc' or 'desc'.")
+		return
+	}
+	items := h.getFileStore().list(owner, strings.TrimSpace(q.Get("purpose")))
+	if order == "asc" {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	data := make([]any, 0, min(limit, len(items)))
+	after := strings.TrimSpace(q.Get("after"))
+	skipping := after != ""
+	hasMore := false
+	for _, item := range items {
+		if skipping {
+			if item.ID == after {
+				skipping = false
+			}
+			continue
+		}
+		if len(data) == limit {
+			hasMore = true
+			break
+		}
+		data = append(data, item.apiObject())
+	}
+	writeJSON(w, http.StatusOK, buildChatCompletionList(data, hasMore))
+}
+
+func (h *Handler) GetFile(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.chatCompletionCaller(w, r)
+	if !ok {
+		return
+	}
+	rec, err := h.getFileStore().get(owner, strings.TrimSpace(chi.URLParam(r, "file_id")))
+	if err != nil {
+		writeOpenAIError(w, http.StatusNotFound, "File not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, rec.apiObject())
+}
+
+func (h *Handler) GetFileContent(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.chatCompletionCaller(w, r)
+	if !ok {
+		return
+	}
+	rec, f, err := h.getFileStore().open(owner, strings.TrimSpace(chi.URLParam(r, "file_id")))
+	if err != nil {
+		writeOpenAIError(w, http.StatusNotFound, "File not found.")
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(rec.Bytes, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", rec.Filename))
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, f)
+}
+
+func (h *Handler) DeleteFile(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.chatCompletionCaller(w, r)
+	if !ok {
+		return
+	}
+	id := strings.TrimSpace(chi.URLParam(r, "file_id"))
+	if err := h.getFileStore().delete(owner, id); err != nil {
+		writeOpenAIError(w, http.StatusNotFound, "File not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":      id,
+		"object":  "file",
+		"deleted": true,
+	})
+}
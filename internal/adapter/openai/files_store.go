@@ -0,0 +1,198 @@
+package openai
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ds2api/internal/batchstore"
+	"ds2api/internal/config"
+)
+
+var errFileNotFound = errors.New("file not found")
+
+// fileRecord is the on-disk metadata of an uploaded file. Owner never leaves
+// the server; apiObject renders the public OpenAI shape.
+type fileRecord struct {
+	ID        string `json:"id"`
+	Owner     string `json:"owner"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+}
+
+func (f fileRecord) apiObject() map[string]any {
+	return map[string]any{
+		"id":         f.ID,
+		"object":     "file",
+		"bytes":      f.Bytes,
+		"created_at": f.CreatedAt,
+		"filename":   f.Filename,
+		"purpose":    f.Purpose,
+		"status":     "processed",
+	}
+}
+
+// fileStore keeps uploaded files under <dir>/<id>.data with a JSON sidecar
+// holding the metadata, so content survives restarts.
+type fileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newFileStore(dir string) *fileStore {
+	return &fileStore{dir: dir}
+}
+
+func (s *fileStore) dataPath(id string) string {
+	return filepath.Join(s.dir, id+".data")
+}
+
+func (s *fileStore) metaPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func (s *fileStore) create(owner, filename, purpose string, content io.Reader) (fileRecord, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fileRecord{}, err
+	}
+	id := "file-" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	tmp, err := os.CreateTemp(s.dir, id+".*.tmp")
+	if err != nil {
+		return fileRecord{}, err
+	}
+	n, err := io.Copy(tmp, content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fileRecord{}, err
+	}
+	return s.register(owner, id, filename, purpose, tmp.Name(), n)
+}
+
+// adopt moves an existing local file (e.g. a finished batch output) into the
+// store and registers it under a new file id.
+func (s *fileStore) adopt(owner, filename, purpose, srcPath string) (fileRecord, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fileRecord{}, err
+	}
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return fileRecord{}, err
+	}
+	id := "file-" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	return s.register(owner, id, filename, purpose, srcPath, info.Size())
+}
+
+func (s *fileStore) register(owner, id, filename, purpose, srcPath string, size int64) (fileRecord, error) {
+	rec := fileRecord{
+		ID:        id,
+		Owner:     owner,
+		Bytes:     size,
+		CreatedAt: time.Now().Unix(),
+		Filename:  filename,
+		Purpose:   purpose,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Rename(srcPath, s.dataPath(id)); err != nil {
+		_ = os.Remove(srcPath)
+		return fileRecord{}, err
+	}
+	if err := batchstore.WriteJSONAtomic(s.metaPath(id), rec); err != nil {
+		_ = os.Remove(s.dataPath(id))
+		return fileRecord{}, err
+	}
+	return rec, nil
+}
+
+func (s *fileStore) get(owner, id string) (fileRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getLocked(owner, id)
+}
+
+func (s *fileStore) getLocked(owner, id string) (fileRecord, error) {
+	if !batchstore.ValidID(id, "file-") {
+		return fileRecord{}, errFileNotFound
+	}
+	var rec fileRecord
+	if err := batchstore.ReadJSON(s.metaPath(id), &rec); err != nil {
+		return fileRecord{}, errFileNotFound
+	}
+	if rec.Owner != owner {
+		return fileRecord{}, errFileNotFound
+	}
+	return rec, nil
+}
+
+func (s *fileStore) open(owner, id string) (fileRecord, *os.File, error) {
+	rec, err := s.get(owner, id)
+	if err != nil {
+		return fileRecord{}, nil, err
+	}
+	f, err := os.Open(s.dataPath(id))
+	if err != nil {
+		return fileRecord{}, nil, errFileNotFound
+	}
+	return rec, f, nil
+}
+
+// list returns the owner's files, newest first.
+func (s *fileStore) list(owner, purpose string) []fileRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	matches, _ := filepath.Glob(filepath.Join(s.dir, "file-*.json"))
+	out := make([]fileRecord, 0, len(matches))
+	for _, path := range matches {
+		var rec fileRecord
+		if err := batchstore.ReadJSON(path, &rec); err != nil || rec.Owner != owner {
+			continue
+		}
+		if purpose != "" && rec.Purpose != purpose {
+			continue
+		}
+		out = append(out, rec)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].CreatedAt != out[j].CreatedAt {
+			return out[i].CreatedAt > out[j].CreatedAt
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out
+}
+
+func (s *fileStore) delete(owner, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.getLocked(owner, id); err != nil {
+		return err
+	}
+	_ = os.Remove(s.dataPath(id))
+	return os.Remove(s.metaPath(id))
+}
+
+func (h *Handler) dataDir() string {
+	if strings.TrimSpace(h.DataDir) != "" {
+		return h.DataDir
+	}
+	return config.DataDir()
+}
+
+func (h *Handler) getFileStore() *fileStore {
+	h.filesOnce.Do(func() {
+		h.files = newFileStore(filepath.Join(h.dataDir(), "files"))
+	})
+	return h.files
+}
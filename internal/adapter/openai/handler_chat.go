@@ -1,7 +1,6 @@
 package openai
 
 import (
-	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -13,6 +12,7 @@ import (
 	openaifmt "ds2api/internal/format/openai"
 	"ds2api/internal/sse"
 	streamengine "ds2api/internal/stream"
+	"ds2api/internal/util"
 )
 
 func (h *Handler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
@@ -27,12 +27,7 @@ func (h *Handler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 
 	a, err := h.Auth.Determine(r)
 	if err != nil {
-		status := http.StatusUnauthorized
-		detail := err.Error()
-		if err == auth.ErrNoAccount {
-			status = http.StatusTooManyRequests
-		}
-		writeOpenAIError(w, status, detail)
+		writeOpenAIAuthError(w, err)
 		return
 	}
 	defer h.Auth.Release(a)
@@ -48,6 +43,11 @@ func (h *Handler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 		writeOpenAIError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	persist, err := h.chatCompletionPersister(a, req)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	sessionID, err := h.DS.CreateSession(r.Context(), a, 3)
 	if err != nil {
@@ -69,30 +69,51 @@ func (h *Handler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 		writeOpenAIError(w, http.StatusInternalServerError, "Failed to get completion.")
 		return
 	}
+	cachedTokens := h.promptCacheReadTokens(a, stdReq, req["tools"])
+	toolGate := h.newToolCallGate(r.Context(), a, stdReq)
 	if stdReq.Stream {
-		h.handleStream(w, r, resp, sessionID, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.Search, stdReq.ToolNames)
+		h.handleStream(w, r, resp, sessionID, stdReq, cachedTokens, toolGate, persist)
 		return
 	}
-	h.handleNonStream(w, r.Context(), resp, sessionID, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.ToolNames)
+	h.handleNonStream(w, resp, sessionID, stdReq, cachedTokens, toolGate, persist)
 }
 
-func (h *Handler) handleNonStream(w http.ResponseWriter, ctx context.Context, resp *http.Response, completionID, model, finalPrompt string, thinkingEnabled bool, toolNames []string) {
+func (h *Handler) handleNonStream(w http.ResponseWriter, resp *http.Response, completionID string, stdReq util.StandardRequest, cachedTokens int, toolGate toolCallGate, persist func(obj map[string]any)) {
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
 		writeOpenAIError(w, resp.StatusCode, string(body))
 		return
 	}
-	_ = ctx
-	result := sse.CollectStream(resp, thinkingEnabled, true)
+	result := sse.CollectStreamWithPolicy(resp, stdReq.Thinking, true, stdReq.StopPolicy)
 
 	finalThinking := result.Thinking
 	finalText := result.Text
-	respBody := openaifmt.BuildChatCompletion(completionID, model, finalPrompt, finalThinking, finalText, toolNames)
+	var citations []util.CitationSpan
+	if stdReq.Search {
+		finalText, citations = util.ResolveCitations(finalText, result.SearchResults)
+	}
+	finishReason := "stop"
+	switch result.FinishReason {
+	case util.OutputFinishMaxTokens:
+		finishReason = "length"
+	case util.OutputFinishContentFilter:
+		finishReason = "content_filter"
+	}
+	detected, fallback := toolGate.resolve(finalText, util.ParseToolCalls(finalText, stdReq.ToolNames))
+	if fallback != "" {
+		finalText = fallback
+	}
+	respBody := openaifmt.BuildChatCompletionFromCalls(completionID, stdReq.ResponseModel, stdReq.FinalPrompt, finalThinking, finalText, detected, finishReason)
+	openaifmt.AttachChatAnnotations(respBody, citations)
+	openaifmt.AttachCachedTokens(respBody, cachedTokens)
+	if persist != nil {
+		persist(respBody)
+	}
 	writeJSON(w, http.StatusOK, respBody)
 }
 
-func (h *Handler) handleStream(w http.ResponseWriter, r *http.Request, resp *http.Response, completionID, model, finalPrompt string, thinkingEnabled, searchEnabled bool, toolNames []string) {
+func (h *Handler) handleStream(w http.ResponseWriter, r *http.Request, resp *http.Response, completionID string, stdReq util.StandardRequest, cachedTokens int, toolGate toolCallGate, persist func(obj map[string]any)) {
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
@@ -110,10 +131,12 @@ func (h *Handler) handleStream(w http.ResponseWriter, r *http.Request, resp *htt
 	}
 
 	created := time.Now().Unix()
-	bufferToolContent := len(toolNames) > 0 && h.toolcallFeatureMatchEnabled()
-	emitEarlyToolDeltas := h.toolcallEarlyEmitHighConfidence()
+	bufferToolContent := len(stdReq.ToolNames) > 0 && h.toolcallFeatureMatchEnabled()
+	// Calls that may still be dropped or rewritten by the policy cannot be
+	// streamed incrementally.
+	emitEarlyToolDeltas := h.toolcallEarlyEmitHighConfidence() && !toolGate.active()
 	initialType := "text"
-	if thinkingEnabled {
+	if stdReq.Thinking {
 		initialType = "thinking"
 	}
 
@@ -123,19 +146,24 @@ func (h *Handler) handleStream(w http.ResponseWriter, r *http.Request, resp *htt
 		canFlush,
 		completionID,
 		created,
-		model,
-		finalPrompt,
-		thinkingEnabled,
-		searchEnabled,
-		toolNames,
+		stdReq.ResponseModel,
+		stdReq.FinalPrompt,
+		stdReq.Thinking,
+		stdReq.Search,
+		stdReq.ToolNames,
 		bufferToolContent,
 		emitEarlyToolDeltas,
+		stdReq.IncludeUsage,
+		stdReq.StopPolicy,
+		toolGate,
+		persist,
 	)
+	streamRuntime.cachedTokens = cachedTokens
 
 	streamengine.ConsumeSSE(streamengine.ConsumeConfig{
 		Context:             r.Context(),
 		Body:                resp.Body,
-		ThinkingEnabled:     thinkingEnabled,
+		ThinkingEnabled:     stdReq.Thinking,
 		InitialType:         initialType,
 		KeepAliveInterval:   time.Duration(deepseek.KeepAliveTimeout) * time.Second,
 		IdleTimeout:         time.Duration(deepseek.StreamIdleTimeout) * time.Second,
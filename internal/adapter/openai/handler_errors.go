@@ -1,23 +1,60 @@
 package openai
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ds2api/internal/auth"
+)
 
 func writeOpenAIError(w http.ResponseWriter, status int, message string) {
 	writeOpenAIErrorWithCode(w, status, message, "")
 }
 
 func writeOpenAIErrorWithCode(w http.ResponseWriter, status int, message, code string) {
+	writeJSON(w, status, openAIErrorBody(status, message, code))
+}
+
+// openAIErrorBody is the error envelope of writeOpenAIErrorWithCode, also
+// sent as a stream chunk once a response has started.
+func openAIErrorBody(status int, message, code string) map[string]any {
 	if code == "" {
 		code = openAIErrorCode(status)
 	}
-	writeJSON(w, status, map[string]any{
+	return map[string]any{
 		"error": map[string]any{
 			"message": message,
 			"type":    openAIErrorType(status),
 			"code":    code,
 			"param":   nil,
 		},
-	})
+	}
+}
+
+// writeOpenAIAuthError reports a failed Auth.Determine: 429 when the key is
+// over its rate limits or monthly quota or no account is free, 403 for a key
+// scope violation, otherwise 401, with distinct codes for expired keys and
+// exhausted quotas.
+func writeOpenAIAuthError(w http.ResponseWriter, err error) {
+	var limited *auth.RateLimitError
+	var quota *auth.QuotaError
+	switch {
+	case errors.As(err, &limited):
+		limited.Decision.WriteRejection(w.Header())
+		writeOpenAIError(w, http.StatusTooManyRequests, err.Error())
+	case errors.As(err, &quota):
+		w.Header().Set("Retry-After", strconv.Itoa(quota.RetryAfterSeconds()))
+		writeOpenAIErrorWithCode(w, http.StatusTooManyRequests, err.Error(), "insufficient_quota")
+	case errors.Is(err, auth.ErrNoAccount):
+		writeOpenAIError(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, auth.ErrAPIKeyExpired):
+		writeOpenAIErrorWithCode(w, http.StatusUnauthorized, err.Error(), "api_key_expired")
+	case errors.Is(err, auth.ErrForbidden):
+		writeOpenAIError(w, http.StatusForbidden, err.Error())
+	default:
+		writeOpenAIError(w, http.StatusUnauthorized, err.Error())
+	}
 }
 
 func openAIErrorType(status int) string {
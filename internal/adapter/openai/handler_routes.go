@@ -10,6 +10,7 @@ import (
 
 	"ds2api/internal/auth"
 	"ds2api/internal/config"
+	"ds2api/internal/promptcache"
 	"ds2api/internal/util"
 )
 
@@ -21,11 +22,22 @@ type Handler struct {
 	Store ConfigReader
 	Auth  AuthResolver
 	DS    DeepSeekCaller
+	// DataDir overrides where files and batches are kept; empty means
+	// config.DataDir().
+	DataDir string
+	// PromptCache tracks prompt prefixes for cached_tokens accounting; nil
+	// reports no cache activity.
+	PromptCache *promptcache.Cache
 
-	leaseMu      sync.Mutex
-	streamLeases map[string]streamLease
-	responsesMu  sync.Mutex
-	responses    *responseStore
+	leaseMu         sync.Mutex
+	streamLeases    map[string]streamLease
+	responsesMu     sync.Mutex
+	responses       *responseStore
+	chatCompletions *responseStore
+	filesOnce       sync.Once
+	files           *fileStore
+	batchesOnce     sync.Once
+	batches         *batchStore
 }
 
 type streamLease struct {
@@ -37,9 +49,25 @@ func RegisterRoutes(r chi.Router, h *Handler) {
 	r.Get("/v1/models", h.ListModels)
 	r.Get("/v1/models/{model_id}", h.GetModel)
 	r.Post("/v1/chat/completions", h.ChatCompletions)
+	r.Get("/v1/chat/completions", h.ListChatCompletions)
+	r.Get("/v1/chat/completions/{completion_id}", h.GetChatCompletion)
+	r.Get("/v1/chat/completions/{completion_id}/messages", h.GetChatCompletionMessages)
+	r.Delete("/v1/chat/completions/{completion_id}", h.DeleteChatCompletion)
+	r.Post("/v1/completions", h.Completions)
 	r.Post("/v1/responses", h.Responses)
 	r.Get("/v1/responses/{response_id}", h.GetResponseByID)
 	r.Post("/v1/embeddings", h.Embeddings)
+	r.Post("/v1/tokenize", h.Tokenize)
+	r.Post("/v1/detokenize", h.Detokenize)
+	r.Post("/v1/files", h.UploadFile)
+	r.Get("/v1/files", h.ListFiles)
+	r.Get("/v1/files/{file_id}", h.GetFile)
+	r.Get("/v1/files/{file_id}/content", h.GetFileContent)
+	r.Delete("/v1/files/{file_id}", h.DeleteFile)
+	r.Post("/v1/batches", h.CreateBatch)
+	r.Get("/v1/batches", h.ListBatches)
+	r.Get("/v1/batches/{batch_id}", h.GetBatch)
+	r.Post("/v1/batches/{batch_id}/cancel", h.CancelBatch)
 }
 
 func (h *Handler) ListModels(w http.ResponseWriter, _ *http.Request) {
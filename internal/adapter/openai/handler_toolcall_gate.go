@@ -0,0 +1,124 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	"ds2api/internal/prompt"
+	"ds2api/internal/sse"
+	"ds2api/internal/util"
+)
+
+// toolCallRepairFunc re-prompts upstream once about invalid tool calls and
+// returns the raw text of the new answer.
+type toolCallRepairFunc func(rawText string, invalid []util.InvalidToolCall) (string, bool)
+
+// toolCallGate applies the request's ToolCallPolicy to parsed calls before
+// they are surfaced, including the optional single repair round-trip.
+type toolCallGate struct {
+	policy    util.ToolCallPolicy
+	toolNames []string
+	repair    toolCallRepairFunc
+}
+
+func (g toolCallGate) active() bool {
+	return g.policy.Active()
+}
+
+// resolve returns the calls to expose. When every call was dropped for invalid
+// arguments it returns fallback text to show in their place instead.
+func (g toolCallGate) resolve(rawText string, calls []util.ParsedToolCall) ([]util.ParsedToolCall, string) {
+	if len(calls) == 0 || !g.policy.Active() {
+		return calls, ""
+	}
+	result := g.policy.Check(calls)
+	if len(result.Calls) > 0 || len(result.Invalid) == 0 {
+		return result.Calls, ""
+	}
+	if g.policy.NeedsRepair(result) && g.repair != nil {
+		if repaired, ok := g.repair(rawText, result.Invalid); ok {
+			retry := g.policy.WithoutRepair().Check(util.ParseToolCalls(repaired, g.toolNames))
+			if len(retry.Calls) > 0 {
+				return retry.Calls, ""
+			}
+			if len(retry.Invalid) > 0 {
+				result = retry
+			}
+		}
+	}
+	return nil, util.InvalidToolCallsFallbackText(result.Invalid)
+}
+
+func openAIToolCallPolicy(store ConfigReader, req map[string]any) util.ToolCallPolicy {
+	mode := util.InvalidToolCallPassThrough
+	if store != nil {
+		mode = util.NormalizeInvalidToolCallMode(store.ToolcallInvalidArgs())
+	}
+	policy := util.ToolCallPolicy{
+		Schemas: extractDeclaredToolSchemas(req["tools"]),
+		Invalid: mode,
+	}
+	if v, ok := req["parallel_tool_calls"].(bool); ok && !v {
+		policy.DisableParallel = true
+	}
+	return policy
+}
+
+func extractDeclaredToolSchemas(toolsRaw any) map[string]map[string]any {
+	tools, _ := toolsRaw.([]any)
+	out := map[string]map[string]any{}
+	for _, t := range tools {
+		tool, ok := t.(map[string]any)
+		if !ok {
+			continue
+		}
+		fn, _ := tool["function"].(map[string]any)
+		if len(fn) == 0 {
+			fn = tool
+		}
+		name := strings.TrimSpace(asString(fn["name"]))
+		schema, _ := fn["parameters"].(map[string]any)
+		if name == "" || len(schema) == 0 {
+			continue
+		}
+		out[name] = schema
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func (h *Handler) newToolCallGate(ctx context.Context, a *auth.RequestAuth, stdReq util.StandardRequest) toolCallGate {
+	gate := toolCallGate{policy: stdReq.ToolCalls, toolNames: stdReq.ToolNames}
+	if stdReq.ToolCalls.Invalid != util.InvalidToolCallRepair {
+		return gate
+	}
+	gate.repair = func(rawText string, invalid []util.InvalidToolCall) (string, bool) {
+		sessionID, err := h.DS.CreateSession(ctx, a, 3)
+		if err != nil {
+			return "", false
+		}
+		pow, err := h.DS.GetPow(ctx, a, 3)
+		if err != nil {
+			return "", false
+		}
+		repairReq := stdReq
+		repairReq.FinalPrompt = prompt.AppendTurns(stdReq.FinalPrompt, rawText, util.ToolCallRepairInstruction(invalid))
+		resp, err := h.DS.CallCompletion(ctx, a, repairReq.CompletionPayload(sessionID), pow, 3)
+		if err != nil {
+			return "", false
+		}
+		if resp.StatusCode != http.StatusOK {
+			_ = resp.Body.Close()
+			config.Logger.Warn("[toolcall] repair request failed", "status", resp.StatusCode)
+			return "", false
+		}
+		result := sse.CollectStream(resp, stdReq.Thinking, true)
+		return result.Text, true
+	}
+	return gate
+}
@@ -0,0 +1,212 @@
+package openai
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ds2api/internal/util"
+)
+
+var gateTestSchemas = map[string]map[string]any{
+	"read_file": {
+		"type":     "object",
+		"required": []any{"path"},
+		"properties": map[string]any{
+			"path":  map[string]any{"type": "string"},
+			"lines": map[string]any{"type": "integer"},
+		},
+	},
+	"search": {
+		"type":       "object",
+		"properties": map[string]any{"q": map[string]any{"type": "string"}},
+	},
+}
+
+func nonStreamToolCalls(t *testing.T, body string) (map[string]any, []any) {
+	t.Helper()
+	out := decodeJSONBody(t, body)
+	choices, _ := out["choices"].([]any)
+	if len(choices) != 1 {
+		t.Fatalf("unexpected choices: %#v", out["choices"])
+	}
+	choice, _ := choices[0].(map[string]any)
+	msg, _ := choice["message"].(map[string]any)
+	calls, _ := msg["tool_calls"].([]any)
+	return choice, calls
+}
+
+func TestNormalizeOpenAIChatRequestToolCallPolicy(t *testing.T) {
+	req := map[string]any{
+		"model":               "deepseek-chat",
+		"messages":            []any{map[string]any{"role": "user", "content": "hi"}},
+		"parallel_tool_calls": false,
+		"tools": []any{map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name":       "read_file",
+				"parameters": gateTestSchemas["read_file"],
+			},
+		}},
+	}
+	out, err := normalizeOpenAIChatRequest(mockOpenAIConfig{invalidArgs: "repair"}, req, "")
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if !out.ToolCalls.DisableParallel {
+		t.Fatal("expected parallel tool calls to be disabled")
+	}
+	if out.ToolCalls.Invalid != util.InvalidToolCallRepair {
+		t.Fatalf("unexpected invalid mode: %q", out.ToolCalls.Invalid)
+	}
+	if _, ok := out.ToolCalls.Schemas["read_file"]; !ok {
+		t.Fatalf("expected read_file schema, got %#v", out.ToolCalls.Schemas)
+	}
+}
+
+func TestHandleNonStreamParallelToolCallsDisabledKeepsFirstCall(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"{\"tool_calls\":[{\"name\":\"search\",\"input\":{\"q\":\"a\"}},{\"name\":\"search\",\"input\":{\"q\":\"b\"}}]}"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	gate := toolCallGate{policy: util.ToolCallPolicy{DisableParallel: true}, toolNames: []string{"search"}}
+
+	h.handleNonStream(rec, resp, "cid", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, gate, nil)
+
+	choice, calls := nonStreamToolCalls(t, rec.Body.String())
+	if choice["finish_reason"] != "tool_calls" || len(calls) != 1 {
+		t.Fatalf("expected exactly one tool call, got %#v", choice)
+	}
+	fn, _ := calls[0].(map[string]any)["function"].(map[string]any)
+	if !strings.Contains(fn["arguments"].(string), `"a"`) {
+		t.Fatalf("expected first call to be kept, got %#v", fn)
+	}
+}
+
+func TestHandleNonStreamCoercesToolArgumentsToSchema(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"{\"tool_calls\":[{\"name\":\"read_file\",\"input\":{\"path\":\"a.go\",\"lines\":\"3\"}}]}"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	gate := toolCallGate{policy: util.ToolCallPolicy{Schemas: gateTestSchemas, Invalid: util.InvalidToolCallDrop}, toolNames: []string{"read_file"}}
+
+	h.handleNonStream(rec, resp, "cid", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"read_file"}}, 0, gate, nil)
+
+	_, calls := nonStreamToolCalls(t, rec.Body.String())
+	if len(calls) != 1 {
+		t.Fatalf("expected coerced call to survive, body=%s", rec.Body.String())
+	}
+	fn, _ := calls[0].(map[string]any)["function"].(map[string]any)
+	if !strings.Contains(fn["arguments"].(string), `"lines":3`) {
+		t.Fatalf("expected lines coerced to a number, got %#v", fn["arguments"])
+	}
+}
+
+func TestHandleNonStreamDropsInvalidToolCallWithFallbackText(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"{\"tool_calls\":[{\"name\":\"read_file\",\"input\":{\"lines\":\"all\"}}]}"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	gate := toolCallGate{policy: util.ToolCallPolicy{Schemas: gateTestSchemas, Invalid: util.InvalidToolCallDrop}, toolNames: []string{"read_file"}}
+
+	h.handleNonStream(rec, resp, "cid", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"read_file"}}, 0, gate, nil)
+
+	choice, calls := nonStreamToolCalls(t, rec.Body.String())
+	if len(calls) != 0 || choice["finish_reason"] != "stop" {
+		t.Fatalf("expected invalid call to be dropped, got %#v", choice)
+	}
+	msg, _ := choice["message"].(map[string]any)
+	content, _ := msg["content"].(string)
+	if !strings.Contains(content, `missing required field "path"`) || !strings.Contains(content, "$.lines") {
+		t.Fatalf("expected fallback text describing the errors, got %q", content)
+	}
+}
+
+func TestToolCallGateRepairRetriesOnce(t *testing.T) {
+	attempts := 0
+	gate := toolCallGate{
+		policy:    util.ToolCallPolicy{Schemas: gateTestSchemas, Invalid: util.InvalidToolCallRepair},
+		toolNames: []string{"read_file"},
+		repair: func(rawText string, invalid []util.InvalidToolCall) (string, bool) {
+			attempts++
+			if rawText != "raw" || len(invalid) != 1 {
+				t.Fatalf("unexpected repair input: %q %#v", rawText, invalid)
+			}
+			return `{"tool_calls":[{"name":"read_file","input":{"path":"a.go"}}]}`, true
+		},
+	}
+	calls, fallback := gate.resolve("raw", []util.ParsedToolCall{{Name: "read_file", Input: map[string]any{}}})
+	if attempts != 1 || fallback != "" || len(calls) != 1 || calls[0].Input["path"] != "a.go" {
+		t.Fatalf("unexpected repair result: attempts=%d calls=%#v fallback=%q", attempts, calls, fallback)
+	}
+
+	gate.repair = func(string, []util.InvalidToolCall) (string, bool) {
+		attempts++
+		return `{"tool_calls":[{"name":"read_file","input":{}}]}`, true
+	}
+	calls, fallback = gate.resolve("raw", []util.ParsedToolCall{{Name: "read_file", Input: map[string]any{}}})
+	if attempts != 2 || len(calls) != 0 || fallback == "" {
+		t.Fatalf("expected a single failed repair to fall back to text: attempts=%d calls=%#v", attempts, calls)
+	}
+}
+
+func TestHandleStreamParallelToolCallsDisabledEmitsSingleCall(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"{\"tool_calls\":[{\"name\":\"search\",\"input\":{\"q\":\"a\"}},{\"name\":\"search\",\"input\":{\"q\":\"b\"}}]}"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	gate := toolCallGate{policy: util.ToolCallPolicy{DisableParallel: true}, toolNames: []string{"search"}}
+
+	h.handleStream(rec, req, resp, "cid", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, gate, nil)
+
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done || streamFinishReason(frames) != "tool_calls" {
+		t.Fatalf("expected tool_calls finish, body=%s", rec.Body.String())
+	}
+	total := 0
+	for _, frame := range frames {
+		choices, _ := frame["choices"].([]any)
+		for _, item := range choices {
+			delta, _ := item.(map[string]any)["delta"].(map[string]any)
+			calls, _ := delta["tool_calls"].([]any)
+			total += len(calls)
+		}
+	}
+	if total != 1 {
+		t.Fatalf("expected one streamed tool call, got %d body=%s", total, rec.Body.String())
+	}
+}
+
+func TestHandleStreamDropsInvalidToolCallWithFallbackText(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"{\"tool_calls\":[{\"name\":\"read_file\",\"input\":{}}]}"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	gate := toolCallGate{policy: util.ToolCallPolicy{Schemas: gateTestSchemas, Invalid: util.InvalidToolCallDrop}, toolNames: []string{"read_file"}}
+
+	h.handleStream(rec, req, resp, "cid", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"read_file"}}, 0, gate, nil)
+
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done {
+		t.Fatalf("expected [DONE], body=%s", rec.Body.String())
+	}
+	if streamHasToolCallsDelta(frames) || streamFinishReason(frames) != "stop" {
+		t.Fatalf("expected invalid call to be dropped, body=%s", rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "did not match the tool schema") {
+		t.Fatalf("expected fallback text, body=%s", rec.Body.String())
+	}
+}
@@ -1,13 +1,14 @@
 package openai
 
 import (
-	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"ds2api/internal/util"
 )
 
 func makeSSEHTTPResponse(lines ...string) *http.Response {
@@ -128,7 +129,7 @@ func TestHandleNonStreamToolCallInterceptsChatModel(t *testing.T) {
 	)
 	rec := httptest.NewRecorder()
 
-	h.handleNonStream(rec, context.Background(), resp, "cid1", "deepseek-chat", "prompt", false, []string{"search"})
+	h.handleNonStream(rec, resp, "cid1", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 	if rec.Code != http.StatusOK {
 		t.Fatalf("unexpected status: %d", rec.Code)
 	}
@@ -161,7 +162,7 @@ func TestHandleNonStreamToolCallInterceptsReasonerModel(t *testing.T) {
 	)
 	rec := httptest.NewRecorder()
 
-	h.handleNonStream(rec, context.Background(), resp, "cid2", "deepseek-reasoner", "prompt", true, []string{"search"})
+	h.handleNonStream(rec, resp, "cid2", util.StandardRequest{ResponseModel: "deepseek-reasoner", FinalPrompt: "prompt", Thinking: true, ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 	if rec.Code != http.StatusOK {
 		t.Fatalf("unexpected status: %d", rec.Code)
 	}
@@ -189,7 +190,7 @@ func TestHandleNonStreamUnknownToolNotIntercepted(t *testing.T) {
 	)
 	rec := httptest.NewRecorder()
 
-	h.handleNonStream(rec, context.Background(), resp, "cid2b", "deepseek-chat", "prompt", false, []string{"search"})
+	h.handleNonStream(rec, resp, "cid2b", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 	if rec.Code != http.StatusOK {
 		t.Fatalf("unexpected status: %d", rec.Code)
 	}
@@ -220,7 +221,7 @@ func TestHandleNonStreamEmbeddedToolCallExampleIntercepted(t *testing.T) {
 	)
 	rec := httptest.NewRecorder()
 
-	h.handleNonStream(rec, context.Background(), resp, "cid2c", "deepseek-chat", "prompt", false, []string{"search"})
+	h.handleNonStream(rec, resp, "cid2c", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 	if rec.Code != http.StatusOK {
 		t.Fatalf("unexpected status: %d", rec.Code)
 	}
@@ -249,7 +250,7 @@ func TestHandleNonStreamFencedToolCallExampleNotIntercepted(t *testing.T) {
 	)
 	rec := httptest.NewRecorder()
 
-	h.handleNonStream(rec, context.Background(), resp, "cid2d", "deepseek-chat", "prompt", false, []string{"search"})
+	h.handleNonStream(rec, resp, "cid2d", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 	if rec.Code != http.StatusOK {
 		t.Fatalf("unexpected status: %d", rec.Code)
 	}
@@ -280,7 +281,7 @@ func TestHandleStreamToolCallInterceptsWithoutRawContentLeak(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
 
-	h.handleStream(rec, req, resp, "cid3", "deepseek-chat", "prompt", false, false, []string{"search"})
+	h.handleStream(rec, req, resp, "cid3", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 
 	frames, done := parseSSEDataFrames(t, rec.Body.String())
 	if !done {
@@ -325,7 +326,7 @@ func TestHandleStreamReasonerToolCallInterceptsWithoutRawContentLeak(t *testing.
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
 
-	h.handleStream(rec, req, resp, "cid4", "deepseek-reasoner", "prompt", true, false, []string{"search"})
+	h.handleStream(rec, req, resp, "cid4", util.StandardRequest{ResponseModel: "deepseek-reasoner", FinalPrompt: "prompt", Thinking: true, ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 
 	frames, done := parseSSEDataFrames(t, rec.Body.String())
 	if !done {
@@ -384,7 +385,7 @@ func TestHandleStreamUnknownToolDoesNotLeakRawPayload(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
 
-	h.handleStream(rec, req, resp, "cid5", "deepseek-chat", "prompt", false, false, []string{"search"})
+	h.handleStream(rec, req, resp, "cid5", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 
 	frames, done := parseSSEDataFrames(t, rec.Body.String())
 	if !done {
@@ -410,7 +411,7 @@ func TestHandleStreamUnknownToolNoArgsDoesNotLeakRawPayload(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
 
-	h.handleStream(rec, req, resp, "cid5b", "deepseek-chat", "prompt", false, false, []string{"search"})
+	h.handleStream(rec, req, resp, "cid5b", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 
 	frames, done := parseSSEDataFrames(t, rec.Body.String())
 	if !done {
@@ -437,7 +438,7 @@ func TestHandleStreamToolsPlainTextStreamsBeforeFinish(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
 
-	h.handleStream(rec, req, resp, "cid6", "deepseek-chat", "prompt", false, false, []string{"search"})
+	h.handleStream(rec, req, resp, "cid6", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 
 	frames, done := parseSSEDataFrames(t, rec.Body.String())
 	if !done {
@@ -476,7 +477,7 @@ func TestHandleStreamToolCallMixedWithPlainTextSegments(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
 
-	h.handleStream(rec, req, resp, "cid7", "deepseek-chat", "prompt", false, false, []string{"search"})
+	h.handleStream(rec, req, resp, "cid7", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 
 	frames, done := parseSSEDataFrames(t, rec.Body.String())
 	if !done {
@@ -518,7 +519,7 @@ func TestHandleStreamToolCallAfterLeadingTextStillIntercepted(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
 
-	h.handleStream(rec, req, resp, "cid7b", "deepseek-chat", "prompt", false, false, []string{"search"})
+	h.handleStream(rec, req, resp, "cid7b", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 
 	frames, done := parseSSEDataFrames(t, rec.Body.String())
 	if !done {
@@ -559,7 +560,7 @@ func TestHandleStreamToolCallWithSameChunkTrailingTextStillIntercepted(t *testin
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
 
-	h.handleStream(rec, req, resp, "cid7c", "deepseek-chat", "prompt", false, false, []string{"search"})
+	h.handleStream(rec, req, resp, "cid7c", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 
 	frames, done := parseSSEDataFrames(t, rec.Body.String())
 	if !done {
@@ -603,7 +604,7 @@ func TestHandleStreamToolCallKeyAppearsLateStillNoPrefixLeak(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
 
-	h.handleStream(rec, req, resp, "cid8", "deepseek-chat", "prompt", false, false, []string{"search"})
+	h.handleStream(rec, req, resp, "cid8", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 
 	frames, done := parseSSEDataFrames(t, rec.Body.String())
 	if !done {
@@ -649,7 +650,7 @@ func TestHandleStreamInvalidToolJSONDoesNotLeakRawObject(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
 
-	h.handleStream(rec, req, resp, "cid9", "deepseek-chat", "prompt", false, false, []string{"search"})
+	h.handleStream(rec, req, resp, "cid9", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 
 	frames, done := parseSSEDataFrames(t, rec.Body.String())
 	if !done {
@@ -687,7 +688,7 @@ func TestHandleStreamIncompleteCapturedToolJSONFlushesAsTextOnFinalize(t *testin
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
 
-	h.handleStream(rec, req, resp, "cid10", "deepseek-chat", "prompt", false, false, []string{"search"})
+	h.handleStream(rec, req, resp, "cid10", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 
 	frames, done := parseSSEDataFrames(t, rec.Body.String())
 	if !done {
@@ -722,7 +723,7 @@ func TestHandleStreamToolCallArgumentsEmitIncrementally(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
 
-	h.handleStream(rec, req, resp, "cid11", "deepseek-chat", "prompt", false, false, []string{"search"})
+	h.handleStream(rec, req, resp, "cid11", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search"}}, 0, toolCallGate{}, nil)
 
 	frames, done := parseSSEDataFrames(t, rec.Body.String())
 	if !done {
@@ -757,7 +758,7 @@ func TestHandleStreamMultiToolCallDoesNotMergeNamesOrArguments(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
 
-	h.handleStream(rec, req, resp, "cid12", "deepseek-chat", "prompt", false, false, []string{"search_web", "eval_javascript"})
+	h.handleStream(rec, req, resp, "cid12", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search_web", "eval_javascript"}}, 0, toolCallGate{}, nil)
 
 	frames, done := parseSSEDataFrames(t, rec.Body.String())
 	if !done {
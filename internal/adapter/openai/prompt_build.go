@@ -24,3 +24,10 @@ func buildOpenAIFinalPromptWithPolicy(messagesRaw []any, toolsRaw any, traceID s
 func BuildPromptForAdapter(messagesRaw []any, toolsRaw any, traceID string) (string, []string) {
 	return buildOpenAIFinalPrompt(messagesRaw, toolsRaw, traceID)
 }
+
+// BuildPromptForAdapterWithPolicy is BuildPromptForAdapter with a tool choice
+// policy, so adapters with their own tool_choice dialect share the same
+// required / forced / allowed-tools prompting.
+func BuildPromptForAdapterWithPolicy(messagesRaw []any, toolsRaw any, traceID string, toolPolicy util.ToolChoicePolicy) (string, []string) {
+	return buildOpenAIFinalPromptWithPolicy(messagesRaw, toolsRaw, traceID, toolPolicy)
+}
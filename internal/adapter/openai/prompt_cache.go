@@ -0,0 +1,29 @@
+package openai
+
+import (
+	"ds2api/internal/auth"
+	"ds2api/internal/promptcache"
+	"ds2api/internal/util"
+)
+
+// promptCacheReadTokens treats every message boundary as a cache breakpoint,
+// like OpenAI's automatic prompt caching, and returns the prompt tokens
+// served from the cache.
+func (h *Handler) promptCacheReadTokens(a *auth.RequestAuth, stdReq util.StandardRequest, tools any) int {
+	if h.PromptCache == nil {
+		return 0
+	}
+	owner := ""
+	if a != nil {
+		owner = a.CallerID
+	}
+	prefix := promptcache.NewPrefix(owner, stdReq.ResolvedModel)
+	if tools != nil {
+		prefix.Add(tools)
+	}
+	for _, msg := range stdReq.Messages {
+		prefix.Add(msg)
+		prefix.Mark(promptcache.TTLDefault)
+	}
+	return h.PromptCache.Apply(prefix).ReadTokens
+}
@@ -0,0 +1,59 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/promptcache"
+)
+
+func TestPromptCacheReportsCachedTokens(t *testing.T) {
+	ds := &sequenceDSStub{bodies: []string{`data: {"p":"response/content","v":"ok"}`}}
+	h := &Handler{Store: mockOpenAIConfig{wideInput: true}, Auth: streamStatusAuthStub{}, DS: ds, PromptCache: promptcache.New()}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	system := strings.Repeat("Answer like a patient teacher. ", 300)
+
+	post := func(path, question string) map[string]any {
+		t.Helper()
+		body, _ := json.Marshal(map[string]any{
+			"model":    "deepseek-chat",
+			"messages": []any{map[string]any{"role": "system", "content": system}, map[string]any{"role": "user", "content": question}},
+			"input":    []any{map[string]any{"role": "system", "content": system}, map[string]any{"role": "user", "content": question}},
+		})
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(body)))
+		req.Header.Set("Authorization", "Bearer direct-token")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s failed: %d %s", path, rec.Code, rec.Body.String())
+		}
+		var out map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		usage, _ := out["usage"].(map[string]any)
+		return usage
+	}
+
+	first := post("/v1/chat/completions", "first question")
+	if details, _ := first["prompt_tokens_details"].(map[string]any); details["cached_tokens"] != float64(0) {
+		t.Fatalf("expected no cached tokens on the first request, got %#v", first)
+	}
+	second := post("/v1/chat/completions", "second question")
+	details, _ := second["prompt_tokens_details"].(map[string]any)
+	cached, _ := details["cached_tokens"].(float64)
+	if cached < promptcache.MinTokens || cached > second["prompt_tokens"].(float64) {
+		t.Fatalf("expected the shared system prompt to be cached, got %#v", second)
+	}
+
+	post("/v1/responses", "first question")
+	responses := post("/v1/responses", "another question")
+	inputDetails, _ := responses["input_tokens_details"].(map[string]any)
+	if got, _ := inputDetails["cached_tokens"].(float64); got < promptcache.MinTokens {
+		t.Fatalf("expected cached input tokens in Responses usage, got %#v", responses)
+	}
+}
@@ -1,6 +1,7 @@
 package openai
 
 import (
+	"sort"
 	"sync"
 	"time"
 
@@ -8,14 +9,18 @@ import (
 )
 
 type storedResponse struct {
+	ID        string
 	Owner     string
 	Value     map[string]any
+	Input     []any
+	Seq       uint64
 	ExpiresAt time.Time
 }
 
 type responseStore struct {
 	mu    sync.Mutex
 	ttl   time.Duration
+	seq   uint64
 	items map[string]storedResponse
 }
 
@@ -41,6 +46,12 @@ func responseStoreOwner(a *auth.RequestAuth) string {
 }
 
 func (s *responseStore) put(owner, id string, value map[string]any) {
+	s.putWithInput(owner, id, value, nil)
+}
+
+// putWithInput also keeps the request input (e.g. chat messages) next to the
+// stored object. Re-putting an id keeps its original list position.
+func (s *responseStore) putWithInput(owner, id string, value map[string]any, input []any) {
 	if s == nil || owner == "" || id == "" || value == nil {
 		return
 	}
@@ -48,9 +59,18 @@ func (s *responseStore) put(owner, id string, value map[string]any) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.sweepLocked(now)
-	s.items[responseStoreKey(owner, id)] = storedResponse{
+	key := responseStoreKey(owner, id)
+	seq := s.items[key].Seq
+	if seq == 0 {
+		s.seq++
+		seq = s.seq
+	}
+	s.items[key] = storedResponse{
+		ID:        id,
 		Owner:     owner,
 		Value:     cloneAnyMap(value),
+		Input:     append([]any(nil), input...),
+		Seq:       seq,
 		ExpiresAt: now.Add(s.ttl),
 	}
 }
@@ -73,6 +93,55 @@ func (s *responseStore) get(owner, id string) (map[string]any, bool) {
 	return cloneAnyMap(item.Value), true
 }
 
+func (s *responseStore) getEntry(owner, id string) (storedResponse, bool) {
+	if s == nil || owner == "" || id == "" {
+		return storedResponse{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked(time.Now())
+	item, ok := s.items[responseStoreKey(owner, id)]
+	if !ok || item.Owner != owner {
+		return storedResponse{}, false
+	}
+	item.Value = cloneAnyMap(item.Value)
+	return item, true
+}
+
+// list returns the owner's live entries in insertion order.
+func (s *responseStore) list(owner string) []storedResponse {
+	if s == nil || owner == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked(time.Now())
+	out := make([]storedResponse, 0)
+	for _, item := range s.items {
+		if item.Owner != owner {
+			continue
+		}
+		item.Value = cloneAnyMap(item.Value)
+		out = append(out, item)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	return out
+}
+
+func (s *responseStore) delete(owner, id string) bool {
+	if s == nil || owner == "" || id == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := responseStoreKey(owner, id)
+	if _, ok := s.items[key]; !ok {
+		return false
+	}
+	delete(s.items, key)
+	return true
+}
+
 func (s *responseStore) sweepLocked(now time.Time) {
 	for k, v := range s.items {
 		if now.After(v.ExpiresAt) {
@@ -99,11 +168,29 @@ func (h *Handler) getResponseStore() *responseStore {
 	h.responsesMu.Lock()
 	defer h.responsesMu.Unlock()
 	if h.responses == nil {
-		ttl := 15 * time.Minute
-		if h.Store != nil {
-			ttl = time.Duration(h.Store.ResponsesStoreTTLSeconds()) * time.Second
-		}
-		h.responses = newResponseStore(ttl)
+		h.responses = newResponseStore(h.responseStoreTTL())
 	}
 	return h.responses
 }
+
+// getChatCompletionStore backs `store: true` chat completions with the same
+// TTL store as /v1/responses, kept separate so ids and listings never mix.
+func (h *Handler) getChatCompletionStore() *responseStore {
+	if h == nil {
+		return nil
+	}
+	h.responsesMu.Lock()
+	defer h.responsesMu.Unlock()
+	if h.chatCompletions == nil {
+		h.chatCompletions = newResponseStore(h.responseStoreTTL())
+	}
+	return h.chatCompletions
+}
+
+func (h *Handler) responseStoreTTL() time.Duration {
+	ttl := 15 * time.Minute
+	if h.Store != nil {
+		ttl = time.Duration(h.Store.ResponsesStoreTTLSeconds()) * time.Second
+	}
+	return ttl
+}
@@ -163,19 +163,6 @@ func TestExtractEmbeddingInputs(t *testing.T) {
 	}
 }
 
-func TestDeterministicEmbeddingStable(t *testing.T) {
-	a := deterministicEmbedding("hello")
-	b := deterministicEmbedding("hello")
-	if len(a) != 64 || len(b) != 64 {
-		t.Fatalf("expected 64 dims, got %d and %d", len(a), len(b))
-	}
-	for i := range a {
-		if a[i] != b[i] {
-			t.Fatalf("expected stable embedding at %d: %v != %v", i, a[i], b[i])
-		}
-	}
-}
-
 func TestResponseStorePutGet(t *testing.T) {
 	st := newResponseStore(100 * time.Millisecond)
 	st.put("owner_1", "resp_1", map[string]any{"id": "resp_1"})
@@ -22,7 +22,7 @@ import (
 func (h *Handler) GetResponseByID(w http.ResponseWriter, r *http.Request) {
 	a, err := h.Auth.DetermineCaller(r)
 	if err != nil {
-		writeOpenAIError(w, http.StatusUnauthorized, err.Error())
+		writeOpenAIAuthError(w, err)
 		return
 	}
 
@@ -48,12 +48,7 @@ func (h *Handler) GetResponseByID(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) Responses(w http.ResponseWriter, r *http.Request) {
 	a, err := h.Auth.Determine(r)
 	if err != nil {
-		status := http.StatusUnauthorized
-		detail := err.Error()
-		if err == auth.ErrNoAccount {
-			status = http.StatusTooManyRequests
-		}
-		writeOpenAIError(w, status, detail)
+		writeOpenAIAuthError(w, err)
 		return
 	}
 	defer h.Auth.Release(a)
@@ -98,41 +93,57 @@ func (h *Handler) Responses(w http.ResponseWriter, r *http.Request) {
 	}
 
 	responseID := "resp_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	cachedTokens := h.promptCacheReadTokens(a, stdReq, req["tools"])
+	toolGate := h.newToolCallGate(r.Context(), a, stdReq)
 	if stdReq.Stream {
-		h.handleResponsesStream(w, r, resp, owner, responseID, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.Search, stdReq.ToolNames, stdReq.ToolChoice, traceID)
+		h.handleResponsesStream(w, r, resp, owner, responseID, traceID, stdReq, cachedTokens, toolGate)
 		return
 	}
-	h.handleResponsesNonStream(w, resp, owner, responseID, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.ToolNames, stdReq.ToolChoice, traceID)
+	h.handleResponsesNonStream(w, resp, owner, responseID, traceID, stdReq, cachedTokens, toolGate)
 }
 
-func (h *Handler) handleResponsesNonStream(w http.ResponseWriter, resp *http.Response, owner, responseID, model, finalPrompt string, thinkingEnabled bool, toolNames []string, toolChoice util.ToolChoicePolicy, traceID string) {
+func (h *Handler) handleResponsesNonStream(w http.ResponseWriter, resp *http.Response, owner, responseID, traceID string, stdReq util.StandardRequest, cachedTokens int, toolGate toolCallGate) {
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		writeOpenAIError(w, resp.StatusCode, strings.TrimSpace(string(body)))
 		return
 	}
-	result := sse.CollectStream(resp, thinkingEnabled, true)
-	textParsed := util.ParseToolCallsDetailed(result.Text, toolNames)
-	thinkingParsed := util.ParseToolCallsDetailed(result.Thinking, toolNames)
-	logResponsesToolPolicyRejection(traceID, toolChoice, textParsed, "text")
-	logResponsesToolPolicyRejection(traceID, toolChoice, thinkingParsed, "thinking")
+	result := sse.CollectStreamWithPolicy(resp, stdReq.Thinking, true, stdReq.StopPolicy)
+	textParsed := util.ParseToolCallsDetailed(result.Text, stdReq.ToolNames)
+	thinkingParsed := util.ParseToolCallsDetailed(result.Thinking, stdReq.ToolNames)
+	logResponsesToolPolicyRejection(traceID, stdReq.ToolChoice, textParsed, "text")
+	logResponsesToolPolicyRejection(traceID, stdReq.ToolChoice, thinkingParsed, "thinking")
 
-	callCount := len(textParsed.Calls)
-	if callCount == 0 {
-		callCount = len(thinkingParsed.Calls)
+	detected, rawText := textParsed.Calls, result.Text
+	if len(detected) == 0 {
+		detected, rawText = thinkingParsed.Calls, result.Thinking
+	}
+	detected, fallback := toolGate.resolve(rawText, detected)
+	finalText := result.Text
+	var citations []util.CitationSpan
+	if fallback != "" {
+		finalText = fallback
+	} else if stdReq.Search {
+		finalText, citations = util.ResolveCitations(finalText, result.SearchResults)
 	}
-	if toolChoice.IsRequired() && callCount == 0 {
+	callCount := len(detected)
+	if stdReq.ToolChoice.IsRequired() && callCount == 0 {
 		writeOpenAIErrorWithCode(w, http.StatusUnprocessableEntity, "tool_choice requires at least one valid tool call.", "tool_choice_violation")
 		return
 	}
 
-	responseObj := openaifmt.BuildResponseObject(responseID, model, finalPrompt, result.Thinking, result.Text, toolNames)
+	responseObj := openaifmt.BuildResponseObjectFromCalls(responseID, stdReq.ResponseModel, stdReq.FinalPrompt, result.Thinking, finalText, detected)
+	openaifmt.AttachResponseAnnotations(responseObj, citations)
+	openaifmt.AttachCachedTokens(responseObj, cachedTokens)
+	if result.FinishReason == util.OutputFinishMaxTokens && callCount == 0 {
+		openaifmt.MarkResponseIncomplete(responseObj, "max_output_tokens")
+	}
 	h.getResponseStore().put(owner, responseID, responseObj)
 	writeJSON(w, http.StatusOK, responseObj)
 }
 
-func (h *Handler) handleResponsesStream(w http.ResponseWriter, r *http.Request, resp *http.Response, owner, responseID, model, finalPrompt string, thinkingEnabled, searchEnabled bool, toolNames []string, toolChoice util.ToolChoicePolicy, traceID string) {
+func (h *Handler) handleResponsesStream(w http.ResponseWriter, r *http.Request, resp *http.Response, owner, responseID, traceID string, stdReq util.StandardRequest, cachedTokens int, toolGate toolCallGate) {
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
@@ -147,36 +158,39 @@ func (h *Handler) handleResponsesStream(w http.ResponseWriter, r *http.Request,
 	_, canFlush := w.(http.Flusher)
 
 	initialType := "text"
-	if thinkingEnabled {
+	if stdReq.Thinking {
 		initialType = "thinking"
 	}
-	bufferToolContent := len(toolNames) > 0 && h.toolcallFeatureMatchEnabled()
-	emitEarlyToolDeltas := h.toolcallEarlyEmitHighConfidence()
+	bufferToolContent := len(stdReq.ToolNames) > 0 && h.toolcallFeatureMatchEnabled()
+	emitEarlyToolDeltas := h.toolcallEarlyEmitHighConfidence() && !toolGate.active()
 
 	streamRuntime := newResponsesStreamRuntime(
 		w,
 		rc,
 		canFlush,
 		responseID,
-		model,
-		finalPrompt,
-		thinkingEnabled,
-		searchEnabled,
-		toolNames,
+		stdReq.ResponseModel,
+		stdReq.FinalPrompt,
+		stdReq.Thinking,
+		stdReq.Search,
+		stdReq.ToolNames,
 		bufferToolContent,
 		emitEarlyToolDeltas,
-		toolChoice,
+		stdReq.ToolChoice,
 		traceID,
 		func(obj map[string]any) {
 			h.getResponseStore().put(owner, responseID, obj)
 		},
+		stdReq.StopPolicy,
+		toolGate,
 	)
+	streamRuntime.cachedTokens = cachedTokens
 	streamRuntime.sendCreated()
 
 	streamengine.ConsumeSSE(streamengine.ConsumeConfig{
 		Context:             r.Context(),
 		Body:                resp.Body,
-		ThinkingEnabled:     thinkingEnabled,
+		ThinkingEnabled:     stdReq.Thinking,
 		InitialType:         initialType,
 		KeepAliveInterval:   time.Duration(deepseek.KeepAliveTimeout) * time.Second,
 		IdleTimeout:         time.Duration(deepseek.StreamIdleTimeout) * time.Second,
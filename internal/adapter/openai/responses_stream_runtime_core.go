@@ -16,12 +16,13 @@ type responsesStreamRuntime struct {
 	rc       *http.ResponseController
 	canFlush bool
 
-	responseID  string
-	model       string
-	finalPrompt string
-	toolNames   []string
-	traceID     string
-	toolChoice  util.ToolChoicePolicy
+	responseID   string
+	model        string
+	finalPrompt  string
+	cachedTokens int
+	toolNames    []string
+	traceID      string
+	toolChoice   util.ToolChoicePolicy
 
 	thinkingEnabled bool
 	searchEnabled   bool
@@ -30,12 +31,19 @@ type responsesStreamRuntime struct {
 	emitEarlyToolDeltas  bool
 	toolCallsEmitted     bool
 	toolCallsDoneEmitted bool
+	toolCallsResolved    bool
 
+	toolGate          toolCallGate
+	resolvedCalls     []util.ParsedToolCall
+	limiter           *util.OutputLimiter
 	sieve             toolStreamSieveState
 	thinkingSieve     toolStreamSieveState
 	thinking          strings.Builder
 	text              strings.Builder
 	visibleText       strings.Builder
+	visibleRunes      int
+	citations         *util.CitationRewriter
+	annotations       []map[string]any
 	streamToolCallIDs map[int]string
 	functionItemIDs   map[int]string
 	functionOutputIDs map[int]int
@@ -69,7 +77,13 @@ func newResponsesStreamRuntime(
 	toolChoice util.ToolChoicePolicy,
 	traceID string,
 	persistResponse func(obj map[string]any),
+	stopPolicy util.StopPolicy,
+	toolGate toolCallGate,
 ) *responsesStreamRuntime {
+	var citations *util.CitationRewriter
+	if searchEnabled {
+		citations = util.NewCitationRewriter()
+	}
 	return &responsesStreamRuntime{
 		w:                   w,
 		rc:                  rc,
@@ -93,10 +107,14 @@ func newResponsesStreamRuntime(
 		toolChoice:          toolChoice,
 		traceID:             traceID,
 		persistResponse:     persistResponse,
+		limiter:             util.NewOutputLimiter(stopPolicy),
+		toolGate:            toolGate,
+		citations:           citations,
 	}
 }
 
 func (s *responsesStreamRuntime) finalize() {
+	s.handleText(s.limiter.Flush())
 	finalThinking := s.thinking.String()
 	finalText := s.text.String()
 
@@ -112,6 +130,16 @@ func (s *responsesStreamRuntime) finalize() {
 		detected = thinkingParsed.Calls
 	}
 	s.logToolPolicyRejections(textParsed, thinkingParsed)
+	if s.toolCallsResolved {
+		detected = s.resolvedCalls
+	} else if len(detected) > 0 {
+		var fallback string
+		detected, fallback = s.resolveToolCalls(detected)
+		s.emitTextDelta(fallback)
+	}
+	if s.citations != nil {
+		s.emitVisibleText(s.citations.Flush(), nil)
+	}
 
 	if len(detected) > 0 {
 		s.toolCallsEmitted = true
@@ -150,6 +178,15 @@ func (s *responsesStreamRuntime) finalize() {
 	s.closeIncompleteFunctionItems()
 
 	obj := s.buildCompletedResponseObject(finalThinking, finalText, detected)
+	if s.limiter.FinishReason() == util.OutputFinishMaxTokens && len(detected) == 0 {
+		openaifmt.MarkResponseIncomplete(obj, "max_output_tokens")
+		if s.persistResponse != nil {
+			s.persistResponse(obj)
+		}
+		s.sendEvent("response.incomplete", openaifmt.BuildResponsesIncompletePayload(obj))
+		s.sendDone()
+		return
+	}
 	if s.persistResponse != nil {
 		s.persistResponse(obj)
 	}
@@ -192,34 +229,45 @@ func (s *responsesStreamRuntime) onParsed(parsed sse.LineResult) streamengine.Pa
 		return streamengine.ParsedDecision{Stop: true}
 	}
 
+	if s.citations != nil {
+		s.citations.AddSources(parsed.SearchResults)
+	}
 	contentSeen := false
 	for _, p := range parsed.Parts {
 		if p.Text == "" {
 			continue
 		}
-		if p.Type != "thinking" && s.searchEnabled && sse.IsCitation(p.Text) {
-			continue
-		}
 		contentSeen = true
 		if p.Type == "thinking" {
 			if !s.thinkingEnabled {
 				continue
 			}
-			s.thinking.WriteString(p.Text)
-			s.sendEvent("response.reasoning.delta", openaifmt.BuildResponsesReasoningDeltaPayload(s.responseID, p.Text))
-			if s.bufferToolContent {
-				s.processToolStreamEvents(processToolSieveChunk(&s.thinkingSieve, p.Text, s.toolNames), false)
+			if text := s.limiter.PushThinking(p.Text); text != "" {
+				s.thinking.WriteString(text)
+				s.sendEvent("response.reasoning.delta", openaifmt.BuildResponsesReasoningDeltaPayload(s.responseID, text))
+				if s.bufferToolContent {
+					s.processToolStreamEvents(processToolSieveChunk(&s.thinkingSieve, text, s.toolNames), false)
+				}
 			}
-			continue
+		} else {
+			s.handleText(s.limiter.PushText(p.Text))
 		}
-
-		s.text.WriteString(p.Text)
-		if !s.bufferToolContent {
-			s.emitTextDelta(p.Text)
-			continue
+		if s.limiter.Done() {
+			return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReason(s.limiter.FinishReason()), ContentSeen: contentSeen}
 		}
-		s.processToolStreamEvents(processToolSieveChunk(&s.sieve, p.Text, s.toolNames), true)
 	}
 
 	return streamengine.ParsedDecision{ContentSeen: contentSeen}
 }
+
+func (s *responsesStreamRuntime) handleText(text string) {
+	if text == "" {
+		return
+	}
+	s.text.WriteString(text)
+	if !s.bufferToolContent {
+		s.emitTextDelta(text)
+		return
+	}
+	s.processToolStreamEvents(processToolSieveChunk(&s.sieve, text, s.toolNames), true)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	openaifmt "ds2api/internal/format/openai"
+	"ds2api/internal/util"
 )
 
 func (s *responsesStreamRuntime) nextSequence() int {
@@ -55,7 +56,24 @@ func (s *responsesStreamRuntime) processToolStreamEvents(events []toolStreamEven
 			s.emitFunctionCallDeltaEvents(filtered)
 		}
 		if len(evt.ToolCalls) > 0 {
-			s.emitFunctionCallDoneEvents(evt.ToolCalls)
+			calls, fallback := s.resolveToolCalls(evt.ToolCalls)
+			s.emitTextDelta(fallback)
+			s.emitFunctionCallDoneEvents(calls)
 		}
 	}
 }
+
+// resolveToolCalls runs complete calls through the request's tool call policy
+// and remembers what was accepted so finalize reports the same calls.
+func (s *responsesStreamRuntime) resolveToolCalls(calls []util.ParsedToolCall) ([]util.ParsedToolCall, string) {
+	if !s.toolGate.active() {
+		return calls, ""
+	}
+	s.toolCallsResolved = true
+	if s.toolGate.policy.DisableParallel && s.toolCallsDoneEmitted {
+		return nil, ""
+	}
+	calls, fallback := s.toolGate.resolve(s.text.String(), calls)
+	s.resolvedCalls = append(s.resolvedCalls, calls...)
+	return calls, fallback
+}
@@ -3,6 +3,7 @@ package openai
 import (
 	"encoding/json"
 	"strings"
+	"unicode/utf8"
 
 	openaifmt "ds2api/internal/format/openai"
 	"ds2api/internal/util"
@@ -68,12 +69,32 @@ func (s *responsesStreamRuntime) ensureMessageContentPartAdded() {
 	s.messagePartAdded = true
 }
 
+// emitTextDelta rewrites [citation:N] markers for search models before the
+// text becomes visible.
 func (s *responsesStreamRuntime) emitTextDelta(content string) {
+	if s.citations == nil {
+		s.emitVisibleText(content, nil)
+		return
+	}
+	base := s.citations.RuneOffset()
+	content, spans := s.citations.Push(content)
+	for i := range spans {
+		spans[i].RuneStart -= base
+		spans[i].RuneEnd -= base
+	}
+	s.emitVisibleText(content, spans)
+}
+
+// emitVisibleText sends an output_text delta followed by its annotations;
+// span offsets are relative to content.
+func (s *responsesStreamRuntime) emitVisibleText(content string, spans []util.CitationSpan) {
 	if strings.TrimSpace(content) == "" {
 		return
 	}
 	s.ensureMessageContentPartAdded()
+	offset := s.visibleRunes
 	s.visibleText.WriteString(content)
+	s.visibleRunes += utf8.RuneCountInString(content)
 	s.sendEvent(
 		"response.output_text.delta",
 		openaifmt.BuildResponsesTextDeltaPayload(
@@ -84,6 +105,30 @@ func (s *responsesStreamRuntime) emitTextDelta(content string) {
 			content,
 		),
 	)
+	for _, span := range spans {
+		annotation := openaifmt.BuildResponsesURLCitation(span, offset)
+		s.sendEvent(
+			"response.output_text.annotation.added",
+			openaifmt.BuildResponsesAnnotationAddedPayload(
+				s.responseID,
+				s.ensureMessageItemID(),
+				s.ensureMessageOutputIndex(),
+				0,
+				len(s.annotations),
+				annotation,
+			),
+		)
+		s.annotations = append(s.annotations, annotation)
+	}
+}
+
+// outputTextPart renders the streamed message text, with any citations.
+func (s *responsesStreamRuntime) outputTextPart() map[string]any {
+	part := map[string]any{"type": "output_text", "text": s.visibleText.String()}
+	if len(s.annotations) > 0 {
+		part["annotations"] = s.annotations
+	}
+	return part
 }
 
 func (s *responsesStreamRuntime) closeMessageItem() {
@@ -92,7 +137,6 @@ func (s *responsesStreamRuntime) closeMessageItem() {
 	}
 	itemID := s.ensureMessageItemID()
 	outputIndex := s.ensureMessageOutputIndex()
-	text := s.visibleText.String()
 	if s.messagePartAdded {
 		s.sendEvent(
 			"response.content_part.done",
@@ -101,22 +145,17 @@ func (s *responsesStreamRuntime) closeMessageItem() {
 				itemID,
 				outputIndex,
 				0,
-				map[string]any{"type": "output_text", "text": text},
+				s.outputTextPart(),
 			),
 		)
 		s.messagePartAdded = false
 	}
 	item := map[string]any{
-		"id":     itemID,
-		"type":   "message",
-		"role":   "assistant",
-		"status": "completed",
-		"content": []map[string]any{
-			{
-				"type": "output_text",
-				"text": text,
-			},
-		},
+		"id":      itemID,
+		"type":    "message",
+		"role":    "assistant",
+		"status":  "completed",
+		"content": []map[string]any{s.outputTextPart()},
 	}
 	s.sendEvent(
 		"response.output_item.done",
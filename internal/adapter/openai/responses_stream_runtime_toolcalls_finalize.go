@@ -65,20 +65,14 @@ func (s *responsesStreamRuntime) buildCompletedResponseObject(finalThinking, fin
 	indexed := make([]indexedItem, 0, len(calls)+1)
 
 	if s.messageAdded {
-		text := s.visibleText.String()
 		indexed = append(indexed, indexedItem{
 			index: s.ensureMessageOutputIndex(),
 			item: map[string]any{
-				"id":     s.ensureMessageItemID(),
-				"type":   "message",
-				"role":   "assistant",
-				"status": "completed",
-				"content": []map[string]any{
-					{
-						"type": "output_text",
-						"text": text,
-					},
-				},
+				"id":      s.ensureMessageItemID(),
+				"type":    "message",
+				"role":    "assistant",
+				"status":  "completed",
+				"content": []map[string]any{s.outputTextPart()},
 			},
 		})
 	} else if len(calls) == 0 {
@@ -144,7 +138,7 @@ func (s *responsesStreamRuntime) buildCompletedResponseObject(finalThinking, fin
 		}
 	}
 
-	return openaifmt.BuildResponseObjectFromItems(
+	obj := openaifmt.BuildResponseObjectFromItems(
 		s.responseID,
 		s.model,
 		s.finalPrompt,
@@ -153,4 +147,6 @@ func (s *responsesStreamRuntime) buildCompletedResponseObject(finalThinking, fin
 		output,
 		outputText,
 	)
+	openaifmt.AttachCachedTokens(obj, s.cachedTokens)
+	return obj
 }
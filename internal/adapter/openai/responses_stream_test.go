@@ -32,7 +32,7 @@ func TestHandleResponsesStreamToolCallsHideRawOutputTextInCompleted(t *testing.T
 		Body:       io.NopCloser(strings.NewReader(streamBody)),
 	}
 
-	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, false, []string{"read_file"}, util.DefaultToolChoicePolicy(), "")
+	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"read_file"}, ToolChoice: util.DefaultToolChoicePolicy()}, 0, toolCallGate{})
 
 	completed, ok := extractSSEEventPayload(rec.Body.String(), "response.completed")
 	if !ok {
@@ -91,7 +91,7 @@ func TestHandleResponsesStreamUsesOfficialOutputItemEvents(t *testing.T) {
 		Body:       io.NopCloser(strings.NewReader(streamBody)),
 	}
 
-	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, false, []string{"read_file"}, util.DefaultToolChoicePolicy(), "")
+	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"read_file"}, ToolChoice: util.DefaultToolChoicePolicy()}, 0, toolCallGate{})
 	body := rec.Body.String()
 	if !strings.Contains(body, "event: response.output_item.added") {
 		t.Fatalf("expected response.output_item.added event, body=%s", body)
@@ -173,7 +173,7 @@ func TestHandleResponsesStreamDoesNotEmitReasoningTextCompatEvents(t *testing.T)
 		Body:       io.NopCloser(strings.NewReader(streamBody)),
 	}
 
-	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "deepseek-reasoner", "prompt", true, false, nil, util.DefaultToolChoicePolicy(), "")
+	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "", util.StandardRequest{ResponseModel: "deepseek-reasoner", FinalPrompt: "prompt", Thinking: true, ToolChoice: util.DefaultToolChoicePolicy()}, 0, toolCallGate{})
 
 	body := rec.Body.String()
 	if !strings.Contains(body, "event: response.reasoning.delta") {
@@ -205,7 +205,7 @@ func TestHandleResponsesStreamMultiToolCallKeepsNameAndCallIDAligned(t *testing.
 		Body:       io.NopCloser(strings.NewReader(streamBody)),
 	}
 
-	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, false, []string{"search_web", "eval_javascript"}, util.DefaultToolChoicePolicy(), "")
+	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"search_web", "eval_javascript"}, ToolChoice: util.DefaultToolChoicePolicy()}, 0, toolCallGate{})
 
 	body := rec.Body.String()
 	donePayloads := extractAllSSEEventPayloads(body, "response.function_call_arguments.done")
@@ -248,7 +248,7 @@ func TestHandleResponsesStreamOutputTextDeltaCarriesItemIndexes(t *testing.T) {
 		Body:       io.NopCloser(strings.NewReader(streamBody)),
 	}
 
-	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, false, nil, util.DefaultToolChoicePolicy(), "")
+	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolChoice: util.DefaultToolChoicePolicy()}, 0, toolCallGate{})
 	body := rec.Body.String()
 
 	deltaPayload, ok := extractSSEEventPayload(body, "response.output_text.delta")
@@ -288,7 +288,7 @@ func TestHandleResponsesStreamThinkingTextAndToolUseDistinctOutputIndexes(t *tes
 		Body:       io.NopCloser(strings.NewReader(streamBody)),
 	}
 
-	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "deepseek-reasoner", "prompt", true, false, []string{"read_file"}, util.DefaultToolChoicePolicy(), "")
+	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "", util.StandardRequest{ResponseModel: "deepseek-reasoner", FinalPrompt: "prompt", Thinking: true, ToolNames: []string{"read_file"}, ToolChoice: util.DefaultToolChoicePolicy()}, 0, toolCallGate{})
 
 	addedPayloads := extractAllSSEEventPayloads(rec.Body.String(), "response.output_item.added")
 	if len(addedPayloads) < 2 {
@@ -353,7 +353,7 @@ func TestHandleResponsesStreamToolChoiceNoneRejectsFunctionCall(t *testing.T) {
 	}
 	policy := util.ToolChoicePolicy{Mode: util.ToolChoiceNone}
 
-	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, false, nil, policy, "")
+	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolChoice: policy}, 0, toolCallGate{})
 	body := rec.Body.String()
 	if strings.Contains(body, "event: response.function_call_arguments.done") {
 		t.Fatalf("did not expect function_call events for tool_choice=none, body=%s", body)
@@ -380,7 +380,7 @@ func TestHandleResponsesStreamMalformedToolJSONClosesInProgressFunctionItem(t *t
 		Body:       io.NopCloser(strings.NewReader(streamBody)),
 	}
 
-	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, false, []string{"read_file"}, util.DefaultToolChoicePolicy(), "")
+	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"read_file"}, ToolChoice: util.DefaultToolChoicePolicy()}, 0, toolCallGate{})
 	body := rec.Body.String()
 	if !strings.Contains(body, "event: response.function_call_arguments.delta") {
 		t.Fatalf("expected response.function_call_arguments.delta event for malformed payload, body=%s", body)
@@ -419,7 +419,7 @@ func TestHandleResponsesStreamRequiredToolChoiceFailure(t *testing.T) {
 		Mode:    util.ToolChoiceRequired,
 		Allowed: map[string]struct{}{"read_file": {}},
 	}
-	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, false, []string{"read_file"}, policy, "")
+	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"read_file"}, ToolChoice: policy}, 0, toolCallGate{})
 
 	body := rec.Body.String()
 	if !strings.Contains(body, "event: response.failed") {
@@ -453,7 +453,7 @@ func TestHandleResponsesStreamRequiredMalformedToolPayloadFails(t *testing.T) {
 		Allowed: map[string]struct{}{"read_file": {}},
 	}
 
-	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, false, []string{"read_file"}, policy, "")
+	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"read_file"}, ToolChoice: policy}, 0, toolCallGate{})
 
 	body := rec.Body.String()
 	if !strings.Contains(body, "event: response.failed") {
@@ -483,7 +483,7 @@ func TestHandleResponsesStreamRejectsUnknownToolName(t *testing.T) {
 		Body:       io.NopCloser(strings.NewReader(streamBody)),
 	}
 
-	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, false, []string{"read_file"}, util.DefaultToolChoicePolicy(), "")
+	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"read_file"}, ToolChoice: util.DefaultToolChoicePolicy()}, 0, toolCallGate{})
 	body := rec.Body.String()
 	if strings.Contains(body, "event: response.function_call_arguments.done") {
 		t.Fatalf("did not expect function_call events for unknown tool, body=%s", body)
@@ -505,7 +505,7 @@ func TestHandleResponsesNonStreamRequiredToolChoiceViolation(t *testing.T) {
 		Allowed: map[string]struct{}{"read_file": {}},
 	}
 
-	h.handleResponsesNonStream(rec, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, []string{"read_file"}, policy, "")
+	h.handleResponsesNonStream(rec, resp, "owner-a", "resp_test", "", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolNames: []string{"read_file"}, ToolChoice: policy}, 0, toolCallGate{})
 	if rec.Code != http.StatusUnprocessableEntity {
 		t.Fatalf("expected 422 for required tool_choice violation, got %d body=%s", rec.Code, rec.Body.String())
 	}
@@ -528,7 +528,7 @@ func TestHandleResponsesNonStreamToolChoiceNoneRejectsFunctionCall(t *testing.T)
 	}
 	policy := util.ToolChoicePolicy{Mode: util.ToolChoiceNone}
 
-	h.handleResponsesNonStream(rec, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, nil, policy, "")
+	h.handleResponsesNonStream(rec, resp, "owner-a", "resp_test", "", util.StandardRequest{ResponseModel: "deepseek-chat", FinalPrompt: "prompt", ToolChoice: policy}, 0, toolCallGate{})
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected 200 for tool_choice=none passthrough text, got %d body=%s", rec.Code, rec.Body.String())
 	}
@@ -23,6 +23,11 @@ func normalizeOpenAIChatRequest(store ConfigReader, req map[string]any, traceID
 	if responseModel == "" {
 		responseModel = resolvedModel
 	}
+	reasoning, err := openAIReasoningControl(req)
+	if err != nil {
+		return util.StandardRequest{}, err
+	}
+	thinkingEnabled, stopPolicy := reasoning.Apply(thinkingEnabled, openAIStopPolicy(req, "max_completion_tokens", "max_tokens"))
 	toolPolicy := util.DefaultToolChoicePolicy()
 	finalPrompt, toolNames := buildOpenAIFinalPromptWithPolicy(messagesRaw, req["tools"], traceID, toolPolicy)
 	passThrough := collectOpenAIChatPassThrough(req)
@@ -36,7 +41,10 @@ func normalizeOpenAIChatRequest(store ConfigReader, req map[string]any, traceID
 		FinalPrompt:    finalPrompt,
 		ToolNames:      toolNames,
 		ToolChoice:     toolPolicy,
+		ToolCalls:      openAIToolCallPolicy(store, req),
 		Stream:         util.ToBool(req["stream"]),
+		IncludeUsage:   streamIncludeUsage(req),
+		StopPolicy:     stopPolicy,
 		Thinking:       thinkingEnabled,
 		Search:         searchEnabled,
 		PassThrough:    passThrough,
@@ -73,6 +81,11 @@ func normalizeOpenAIResponsesRequest(store ConfigReader, req map[string]any, tra
 	if err != nil {
 		return util.StandardRequest{}, err
 	}
+	reasoning, err := openAIReasoningControl(req)
+	if err != nil {
+		return util.StandardRequest{}, err
+	}
+	thinkingEnabled, stopPolicy := reasoning.Apply(thinkingEnabled, openAIStopPolicy(req, "max_output_tokens", "max_completion_tokens", "max_tokens"))
 	finalPrompt, toolNames := buildOpenAIFinalPromptWithPolicy(messagesRaw, req["tools"], traceID, toolPolicy)
 	if toolPolicy.IsNone() {
 		toolNames = nil
@@ -91,7 +104,9 @@ func normalizeOpenAIResponsesRequest(store ConfigReader, req map[string]any, tra
 		FinalPrompt:    finalPrompt,
 		ToolNames:      toolNames,
 		ToolChoice:     toolPolicy,
+		ToolCalls:      openAIToolCallPolicy(store, req),
 		Stream:         util.ToBool(req["stream"]),
+		StopPolicy:     stopPolicy,
 		Thinking:       thinkingEnabled,
 		Search:         searchEnabled,
 		PassThrough:    passThrough,
@@ -116,6 +131,50 @@ func collectOpenAIChatPassThrough(req map[string]any) map[string]any {
 	return out
 }
 
+// openAIStopPolicy reads the limits the upstream web endpoint ignores; the
+// first positive value among maxTokenKeys wins.
+func openAIStopPolicy(req map[string]any, maxTokenKeys ...string) util.StopPolicy {
+	values := make([]any, 0, len(maxTokenKeys))
+	for _, k := range maxTokenKeys {
+		values = append(values, req[k])
+	}
+	return util.StopPolicy{
+		Sequences: util.ParseStopSequences(req["stop"]),
+		MaxTokens: util.PositiveIntFrom(values...),
+	}
+}
+
+// openAIReasoningControl reads `reasoning_effort` (Chat Completions) or
+// `reasoning.effort` (Responses). An explicit `reasoning.summary` decides
+// whether reasoning is relayed: auto/concise/detailed show it, anything else
+// (including null) hides it.
+func openAIReasoningControl(req map[string]any) (util.ReasoningControl, error) {
+	reasoning, _ := req["reasoning"].(map[string]any)
+	effort := req["reasoning_effort"]
+	if effort == nil {
+		effort = reasoning["effort"]
+	}
+	ctrl, err := util.ParseReasoningEffort(effort)
+	if err != nil {
+		return util.ReasoningControl{}, err
+	}
+	if summary, ok := reasoning["summary"]; ok {
+		s, _ := summary.(string)
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "auto", "concise", "detailed":
+			ctrl.Hide = util.BoolPtr(false)
+		default:
+			ctrl.Hide = util.BoolPtr(true)
+		}
+	}
+	return ctrl, nil
+}
+
+func streamIncludeUsage(req map[string]any) bool {
+	opts, _ := req["stream_options"].(map[string]any)
+	return util.ToBool(opts["include_usage"])
+}
+
 func parseToolChoicePolicy(toolChoiceRaw any, toolsRaw any) (util.ToolChoicePolicy, error) {
 	policy := util.DefaultToolChoicePolicy()
 	declaredNames := extractDeclaredToolNames(toolsRaw)
@@ -324,3 +383,86 @@ func namesToSet(names []string) map[string]struct{} {
 	}
 	return out
 }
+
+// normalizeOpenAICompletionRequest maps a legacy /v1/completions request onto
+// one StandardRequest per prompt; an array prompt yields one choice per item.
+// The raw prompts are returned alongside for `echo`.
+func normalizeOpenAICompletionRequest(store ConfigReader, req map[string]any, traceID string) ([]string, []util.StandardRequest, error) {
+	model := strings.TrimSpace(asString(req["model"]))
+	if model == "" {
+		return nil, nil, fmt.Errorf("Request must include 'model' and 'prompt'.")
+	}
+	prompts, err := completionPrompts(req["prompt"])
+	if err != nil {
+		return nil, nil, err
+	}
+	resolvedModel, ok := config.ResolveModel(store, model)
+	if !ok {
+		return nil, nil, fmt.Errorf("Model '%s' is not available.", model)
+	}
+	thinkingEnabled, searchEnabled, _ := config.GetModelConfig(resolvedModel)
+	suffix := asString(req["suffix"])
+	passThrough := collectOpenAIChatPassThrough(req)
+
+	out := make([]util.StandardRequest, 0, len(prompts))
+	for _, p := range prompts {
+		messagesRaw := []any{map[string]any{"role": "user", "content": completionInstruction(p, suffix)}}
+		finalPrompt, _ := buildOpenAIFinalPrompt(messagesRaw, nil, traceID)
+		out = append(out, util.StandardRequest{
+			Surface:        "openai_completions",
+			RequestedModel: model,
+			ResolvedModel:  resolvedModel,
+			ResponseModel:  model,
+			Messages:       messagesRaw,
+			FinalPrompt:    finalPrompt,
+			ToolChoice:     util.DefaultToolChoicePolicy(),
+			Stream:         util.ToBool(req["stream"]),
+			IncludeUsage:   streamIncludeUsage(req),
+			StopPolicy:     openAIStopPolicy(req, "max_tokens"),
+			Thinking:       thinkingEnabled,
+			Search:         searchEnabled,
+			PassThrough:    passThrough,
+		})
+	}
+	return prompts, out, nil
+}
+
+// completionsMaxPrompts caps the prompts of one text completion request, as
+// each runs as its own upstream completion.
+const completionsMaxPrompts = 16
+
+func completionPrompts(raw any) ([]string, error) {
+	switch x := raw.(type) {
+	case string:
+		return []string{x}, nil
+	case []any:
+		if len(x) == 0 {
+			return nil, fmt.Errorf("prompt must not be empty.")
+		}
+		if len(x) > completionsMaxPrompts {
+			return nil, fmt.Errorf("prompt must not have more than %d items.", completionsMaxPrompts)
+		}
+		out := make([]string, 0, len(x))
+		for _, item := range x {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("prompt must be a string or an array of strings; token arrays are not supported.")
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	case nil:
+		return nil, fmt.Errorf("Request must include 'model' and 'prompt'.")
+	default:
+		return nil, fmt.Errorf("prompt must be a string or an array of strings.")
+	}
+}
+
+// completionInstruction turns a raw text-completion prompt into a chat turn the
+// upstream model can answer; with a suffix it becomes a fill-in-the-middle ask.
+func completionInstruction(prompt, suffix string) string {
+	if suffix == "" {
+		return "Continue the text below. Reply with the continuation only, without repeating the text or adding any commentary.\n\n" + prompt
+	}
+	return "Write the text that belongs between <prefix> and <suffix> below. Reply with the missing text only, without the tags or any commentary.\n\n<prefix>" + prompt + "</prefix>\n<suffix>" + suffix + "</suffix>"
+}
@@ -178,3 +178,55 @@ func TestNormalizeOpenAIResponsesRequestToolChoiceNoneDisablesTools(t *testing.T
 		t.Fatalf("expected no tool names when tool_choice=none, got %#v", n.ToolNames)
 	}
 }
+
+func TestNormalizeOpenAIChatRequestReasoningEffort(t *testing.T) {
+	store := newEmptyStoreForNormalizeTest(t)
+	req := map[string]any{
+		"model":            "deepseek-chat",
+		"messages":         []any{map[string]any{"role": "user", "content": "hello"}},
+		"reasoning_effort": "low",
+	}
+	n, err := normalizeOpenAIChatRequest(store, req, "")
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if !n.Thinking || n.StopPolicy.ThinkingBudget <= 0 {
+		t.Fatalf("expected low effort to enable capped thinking, got thinking=%v policy=%#v", n.Thinking, n.StopPolicy)
+	}
+	if n.CompletionPayload("sid")["thinking_enabled"] != true {
+		t.Fatalf("expected thinking_enabled on upstream payload")
+	}
+
+	req["model"] = "deepseek-reasoner"
+	req["reasoning_effort"] = "none"
+	n, err = normalizeOpenAIChatRequest(store, req, "")
+	if err != nil || n.Thinking {
+		t.Fatalf("expected none to disable thinking on reasoner, got thinking=%v err=%v", n.Thinking, err)
+	}
+
+	req["reasoning_effort"] = "turbo"
+	if _, err := normalizeOpenAIChatRequest(store, req, ""); err == nil {
+		t.Fatalf("expected invalid reasoning_effort to be rejected")
+	}
+}
+
+func TestNormalizeOpenAIResponsesRequestReasoningSummary(t *testing.T) {
+	store := newEmptyStoreForNormalizeTest(t)
+	req := map[string]any{
+		"model":     "deepseek-reasoner",
+		"input":     "ping",
+		"reasoning": map[string]any{"effort": "minimal", "summary": nil},
+	}
+	n, err := normalizeOpenAIResponsesRequest(store, req, "")
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if !n.Thinking || !n.StopPolicy.HideThinking || n.StopPolicy.ThinkingBudget <= 0 {
+		t.Fatalf("expected hidden capped reasoning, got thinking=%v policy=%#v", n.Thinking, n.StopPolicy)
+	}
+	req["reasoning"] = map[string]any{"summary": "auto"}
+	n, err = normalizeOpenAIResponsesRequest(store, req, "")
+	if err != nil || n.StopPolicy.HideThinking || !n.Thinking {
+		t.Fatalf("expected visible reasoning, got thinking=%v policy=%#v err=%v", n.Thinking, n.StopPolicy, err)
+	}
+}
@@ -0,0 +1,88 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ds2api/internal/config"
+	"ds2api/internal/tokenizer"
+	"ds2api/internal/util"
+)
+
+// Tokenize is a debugging aid: it reports how a prompt (or the final prompt
+// built from chat messages) is tokenized. Without a loaded vocabulary it
+// only returns the heuristic count.
+func (h *Handler) Tokenize(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.chatCompletionCaller(w, r); !ok {
+		return
+	}
+	var req map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	model, _ := req["model"].(string)
+	model = strings.TrimSpace(model)
+	var text string
+	if _, ok := req["messages"]; ok {
+		stdReq, err := normalizeOpenAIChatRequest(h.Store, req, requestTraceID(r))
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		text = stdReq.FinalPrompt
+	} else {
+		prompt, ok := req["prompt"].(string)
+		if !ok {
+			writeOpenAIError(w, http.StatusBadRequest, "Request must include 'prompt' (string) or 'messages'.")
+			return
+		}
+		if model != "" {
+			if _, ok := config.ResolveModel(h.Store, model); !ok {
+				writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("Model '%s' is not available.", model))
+				return
+			}
+		}
+		text = prompt
+	}
+
+	out := map[string]any{"model": model}
+	if tok := tokenizer.Default(); tok != nil {
+		ids := tok.Encode(text)
+		out["tokenizer"] = "deepseek-bpe"
+		out["count"] = len(ids)
+		out["tokens"] = ids
+	} else {
+		out["tokenizer"] = "estimate"
+		out["count"] = util.EstimateTokens(text)
+		out["tokens"] = nil
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *Handler) Detokenize(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.chatCompletionCaller(w, r); !ok {
+		return
+	}
+	var req struct {
+		Model  string `json:"model"`
+		Tokens []int  `json:"tokens"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "Request must include 'tokens' as an array of integers.")
+		return
+	}
+	tok := tokenizer.Default()
+	if tok == nil {
+		writeOpenAIError(w, http.StatusNotImplemented, "This build has no tokenizer vocabulary. Set DS2API_TOKENIZER_PATH to DeepSeek's tokenizer.json.")
+		return
+	}
+	text, err := tok.Decode(req.Tokens)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"model": strings.TrimSpace(req.Model), "prompt": text})
+}
@@ -0,0 +1,76 @@
+package openai
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/tokenizer"
+)
+
+func serveTokenize(t *testing.T, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	h := &Handler{Store: mockOpenAIConfig{wideInput: true}, Auth: streamStatusAuthStub{}}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTokenizePromptUsesVocabularyOrEstimate(t *testing.T) {
+	rec := serveTokenize(t, "/v1/tokenize", `{"model":"deepseek-chat","prompt":"hello world, this is a test"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d body=%s", rec.Code, rec.Body.String())
+	}
+	out := decodeJSONBody(t, rec.Body.String())
+	if tokenizer.Default() != nil {
+		if tokens, _ := out["tokens"].([]any); out["tokenizer"] != "deepseek-bpe" || float64(len(tokens)) != out["count"] {
+			t.Fatalf("expected the embedded vocabulary to be used, got %#v", out)
+		}
+	} else if out["tokenizer"] != "estimate" || out["tokens"] != nil {
+		t.Fatalf("expected heuristic fallback without a vocabulary, got %#v", out)
+	}
+	if n, _ := out["count"].(float64); n <= 0 {
+		t.Fatalf("expected positive count, got %#v", out)
+	}
+}
+
+func TestTokenizeMessagesUsesFinalPrompt(t *testing.T) {
+	short := decodeJSONBody(t, serveTokenize(t, "/v1/tokenize", `{"model":"deepseek-chat","prompt":"hi"}`).Body.String())
+	rec := serveTokenize(t, "/v1/tokenize", `{"model":"deepseek-chat","messages":[{"role":"system","content":"You are a careful assistant."},{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d body=%s", rec.Code, rec.Body.String())
+	}
+	out := decodeJSONBody(t, rec.Body.String())
+	if out["count"].(float64) <= short["count"].(float64) {
+		t.Fatalf("expected chat template tokens to be counted, got %#v vs %#v", out, short)
+	}
+}
+
+func TestTokenizeRejectsMissingInputAndUnknownModel(t *testing.T) {
+	if rec := serveTokenize(t, "/v1/tokenize", `{"model":"deepseek-chat"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without prompt, got %d", rec.Code)
+	}
+	if rec := serveTokenize(t, "/v1/tokenize", `{"model":"nope","prompt":"x"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown model, got %d", rec.Code)
+	}
+}
+
+func TestDetokenizeRequiresVocabulary(t *testing.T) {
+	rec := serveTokenize(t, "/v1/detokenize", `{"tokens":[1,2,3]}`)
+	if tokenizer.Default() != nil {
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected the embedded vocabulary to decode, got %d body=%s", rec.Code, rec.Body.String())
+		}
+		return
+	}
+	if rec.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 without a vocabulary, got %d body=%s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,96 @@
+package openai
+
+import "ds2api/internal/util"
+
+// ToolSieve exposes the streaming tool-call sieve so other protocol adapters
+// (for example Ollama) hold back raw tool JSON exactly like chat completions.
+// By default callers receive whole calls; StreamArguments additionally
+// surfaces each call's argument JSON as it arrives.
+type ToolSieve struct {
+	state     toolStreamSieveState
+	toolNames []string
+	allowed   map[string]struct{}
+	ordinals  map[int]int
+	next      int
+}
+
+type ToolSieveEvent struct {
+	Content   string
+	ToolCalls []util.ParsedToolCall
+	ArgDeltas []ToolArgDelta
+}
+
+// ToolArgDelta is a fragment of a call that is still streaming. Index counts
+// only allowed calls, so it matches the position of the call in the
+// ToolCalls event that later completes it. Name is set on the first delta.
+type ToolArgDelta struct {
+	Index     int
+	Name      string
+	Arguments string
+}
+
+func NewToolSieve(toolNames []string) *ToolSieve {
+	return &ToolSieve{toolNames: toolNames, allowed: namesToSet(toolNames)}
+}
+
+// StreamArguments switches the sieve to emit ArgDeltas for every call in a
+// tool_calls array while it is still being generated.
+func (s *ToolSieve) StreamArguments() *ToolSieve {
+	s.state.streamArgs = true
+	s.ordinals = map[int]int{}
+	return s
+}
+
+// Push feeds streamed text and returns what may be emitted now.
+func (s *ToolSieve) Push(chunk string) []ToolSieveEvent {
+	return s.adapt(processToolSieveChunk(&s.state, chunk, s.toolNames))
+}
+
+// Flush releases held-back text and any complete tool call at stream end.
+func (s *ToolSieve) Flush() []ToolSieveEvent {
+	return s.adapt(flushToolSieve(&s.state, s.toolNames))
+}
+
+func (s *ToolSieve) adapt(events []toolStreamEvent) []ToolSieveEvent {
+	out := make([]ToolSieveEvent, 0, len(events))
+	for _, evt := range events {
+		var deltas []ToolArgDelta
+		if s.state.streamArgs {
+			deltas = s.adaptDeltas(evt.ToolCallDeltas)
+		}
+		if evt.Content == "" && len(evt.ToolCalls) == 0 && len(deltas) == 0 {
+			continue
+		}
+		if len(evt.ToolCalls) > 0 {
+			// The next tool_calls array starts counting from zero again.
+			s.ordinals = map[int]int{}
+			s.next = 0
+		}
+		out = append(out, ToolSieveEvent{Content: evt.Content, ToolCalls: evt.ToolCalls, ArgDeltas: deltas})
+	}
+	if !s.state.capturing && s.next > 0 {
+		s.ordinals = map[int]int{}
+		s.next = 0
+	}
+	return out
+}
+
+// adaptDeltas drops calls to undeclared tools, which the final parse rejects.
+func (s *ToolSieve) adaptDeltas(deltas []toolCallDelta) []ToolArgDelta {
+	var out []ToolArgDelta
+	for _, d := range deltas {
+		if d.Name != "" {
+			if _, ok := s.allowed[d.Name]; !ok {
+				continue
+			}
+			s.ordinals[d.Index] = s.next
+			s.next++
+			out = append(out, ToolArgDelta{Index: s.ordinals[d.Index], Name: d.Name})
+			continue
+		}
+		if ordinal, ok := s.ordinals[d.Index]; ok {
+			out = append(out, ToolArgDelta{Index: ordinal, Arguments: d.Arguments})
+		}
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package openai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToolSieveStreamArgumentsEmitsPartialJSON(t *testing.T) {
+	sieve := NewToolSieve([]string{"write"}).StreamArguments()
+	chunks := []string{
+		`Saving. {"tool_calls":[{"name":"write","input":{"path":"a.txt","body":"`,
+		`hello `,
+		`world"}},{"name":"unknown","input":{}},{"name":"write","input":{"path":"b`,
+		`.txt"}}]}`,
+	}
+	var content strings.Builder
+	args := map[int]string{}
+	names := map[int]string{}
+	var calls int
+	for _, chunk := range chunks {
+		events := sieve.Push(chunk)
+		for _, evt := range events {
+			content.WriteString(evt.Content)
+			for _, d := range evt.ArgDeltas {
+				if d.Name != "" {
+					names[d.Index] = d.Name
+				}
+				args[d.Index] += d.Arguments
+			}
+			calls += len(evt.ToolCalls)
+		}
+		if chunk == `hello ` && !strings.Contains(args[0], "hello") {
+			t.Fatalf("expected arguments to stream before the call closes, got %q", args[0])
+		}
+	}
+	for _, evt := range sieve.Flush() {
+		content.WriteString(evt.Content)
+		calls += len(evt.ToolCalls)
+	}
+	if strings.TrimSpace(content.String()) != "Saving." {
+		t.Fatalf("unexpected content: %q", content.String())
+	}
+	if calls != 2 || len(names) != 2 || names[1] != "write" {
+		t.Fatalf("expected two allowed calls, got calls=%d names=%v", calls, names)
+	}
+	if args[0] != `{"path":"a.txt","body":"hello world"}` || args[1] != `{"path":"b.txt"}` {
+		t.Fatalf("unexpected streamed arguments: %#v", args)
+	}
+}
+
+func TestToolSieveWithoutStreamArgumentsOnlyReturnsWholeCalls(t *testing.T) {
+	sieve := NewToolSieve([]string{"write"})
+	events := sieve.Push(`{"tool_calls":[{"name":"write","input":{"path":"a"}}]}`)
+	events = append(events, sieve.Flush()...)
+	for _, evt := range events {
+		if len(evt.ArgDeltas) > 0 {
+			t.Fatalf("unexpected arg deltas: %#v", evt.ArgDeltas)
+		}
+	}
+	if len(events) != 1 || len(events[0].ToolCalls) != 1 {
+		t.Fatalf("expected a single tool call event, got %#v", events)
+	}
+}
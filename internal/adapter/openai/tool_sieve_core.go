@@ -26,7 +26,9 @@ func processToolSieveChunk(state *toolStreamSieveState, chunk string, toolNames
 			}
 			prefix, calls, suffix, ready := consumeToolCapture(state, toolNames)
 			if !ready {
-				if state.capture.Len() > toolSieveCaptureLimit {
+				// A call whose arguments are already streaming is committed to;
+				// the capture limit only guards unrecognised JSON.
+				if state.capture.Len() > toolSieveCaptureLimit && len(state.argStreams) == 0 {
 					content := state.capture.String()
 					state.capture.Reset()
 					state.capturing = false
@@ -3,6 +3,9 @@ package openai
 import "strings"
 
 func buildIncrementalToolDeltas(state *toolStreamSieveState) []toolCallDelta {
+	if state.streamArgs {
+		return buildStreamingToolArgDeltas(state)
+	}
 	if state.disableDeltas {
 		return nil
 	}
@@ -83,6 +86,64 @@ func buildIncrementalToolDeltas(state *toolStreamSieveState) []toolCallDelta {
 	return deltas
 }
 
+// buildStreamingToolArgDeltas walks every call in the captured array and
+// emits its name once known, then its raw argument JSON as it grows. Calls
+// whose arguments arrive as a JSON string are left for the final parse.
+func buildStreamingToolArgDeltas(state *toolStreamSieveState) []toolCallDelta {
+	captured := state.capture.String()
+	keyIdx := strings.Index(strings.ToLower(captured), "tool_calls")
+	if keyIdx < 0 {
+		return nil
+	}
+	start := strings.LastIndex(captured[:keyIdx], "{")
+	if start < 0 || insideCodeFence(state.recentTextTail+captured[:start]) {
+		return nil
+	}
+	arrStart, ok := findToolCallsArrayStart(captured, keyIdx)
+	if !ok {
+		return nil
+	}
+	var deltas []toolCallDelta
+	pos := arrStart + 1
+	for idx := 0; ; idx++ {
+		pos = skipSpaces(captured, pos)
+		if pos >= len(captured) || captured[pos] != '{' {
+			break
+		}
+		if idx == len(state.argStreams) {
+			name, ok := extractToolCallName(captured, pos)
+			if !ok || name == "" {
+				break
+			}
+			argsStart, stringMode, ok := findToolCallArgsStart(captured, pos)
+			if !ok {
+				break
+			}
+			state.argStreams = append(state.argStreams, toolArgStream{start: argsStart, sent: argsStart, skip: stringMode})
+			deltas = append(deltas, toolCallDelta{Index: idx, Name: name})
+		}
+		stream := &state.argStreams[idx]
+		if !stream.skip && !stream.done {
+			end, complete, ok := scanToolCallArgsProgress(captured, stream.start, false)
+			if ok && end > stream.sent {
+				deltas = append(deltas, toolCallDelta{Index: idx, Arguments: captured[stream.sent:end]})
+				stream.sent = end
+			}
+			stream.done = complete
+		}
+		_, end, ok := extractJSONObjectFrom(captured, pos)
+		if !ok {
+			break
+		}
+		pos = skipSpaces(captured, end)
+		if pos >= len(captured) || captured[pos] != ',' {
+			break
+		}
+		pos++
+	}
+	return deltas
+}
+
 func classifyToolCallsIncrementalSafety(text string, keyIdx int) (certainSingle bool, hasMultiple bool) {
 	arrStart, ok := findToolCallsArrayStart(text, keyIdx)
 	if !ok {
@@ -18,6 +18,17 @@ type toolStreamSieveState struct {
 	toolArgsSent   int
 	toolArgsString bool
 	toolArgsDone   bool
+	streamArgs     bool
+	argStreams     []toolArgStream
+}
+
+// toolArgStream tracks one call of the captured tool_calls array when
+// arguments are streamed as they arrive instead of after the array closes.
+type toolArgStream struct {
+	start int
+	sent  int
+	skip  bool
+	done  bool
 }
 
 type toolStreamEvent struct {
@@ -43,6 +54,7 @@ func (s *toolStreamSieveState) resetIncrementalToolState() {
 	s.toolArgsSent = -1
 	s.toolArgsString = false
 	s.toolArgsDone = false
+	s.argStreams = nil
 }
 
 func (s *toolStreamSieveState) noteText(content string) {
@@ -32,11 +32,7 @@ func (h *Handler) handleVercelStreamPrepare(w http.ResponseWriter, r *http.Reque
 
 	a, err := h.Auth.Determine(r)
 	if err != nil {
-		status := http.StatusUnauthorized
-		if err == auth.ErrNoAccount {
-			status = http.StatusTooManyRequests
-		}
-		writeOpenAIError(w, status, err.Error())
+		writeOpenAIAuthError(w, err)
 		return
 	}
 	leased := false
@@ -98,7 +94,10 @@ func (h *Handler) handleVercelStreamPrepare(w http.ResponseWriter, r *http.Reque
 		"model":                    stdReq.ResponseModel,
 		"final_prompt":             stdReq.FinalPrompt,
 		"thinking_enabled":         stdReq.Thinking,
+		"thinking_budget":          stdReq.StopPolicy.ThinkingBudget,
+		"hide_thinking":            stdReq.StopPolicy.HideThinking,
 		"search_enabled":           stdReq.Search,
+		"include_usage":            stdReq.IncludeUsage,
 		"tool_names":               stdReq.ToolNames,
 		"toolcall_feature_match":   h.toolcallFeatureMatchEnabled(),
 		"toolcall_early_emit_high": h.toolcallEarlyEmitHighConfidence(),
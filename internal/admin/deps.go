@@ -8,11 +8,13 @@ import (
 	"ds2api/internal/auth"
 	"ds2api/internal/config"
 	"ds2api/internal/deepseek"
+	"ds2api/internal/ratelimit"
 )
 
 type ConfigStore interface {
 	Snapshot() config.Config
-	Keys() []string
+	KeyPrefixes() []string
+	HasAPIKey(k string) bool
 	Accounts() []config.Account
 	FindAccount(identifier string) (config.Account, bool)
 	UpdateAccountToken(identifier, token string) error
@@ -26,6 +28,7 @@ type ConfigStore interface {
 	RuntimeAccountMaxInflight() int
 	RuntimeAccountMaxQueue(defaultSize int) int
 	RuntimeGlobalMaxInflight(defaultSize int) int
+	APIKeyID(k string) (string, bool)
 }
 
 type PoolController interface {
@@ -34,6 +37,10 @@ type PoolController interface {
 	ApplyRuntimeLimits(maxInflightPerAccount, maxQueueSize, globalMaxInflight int)
 }
 
+type RateLimitReporter interface {
+	Status(id string, limits config.RateLimitConfig) ratelimit.Status
+}
+
 type DeepSeekCaller interface {
 	Login(ctx context.Context, acc config.Account) (string, error)
 	CreateSession(ctx context.Context, a *auth.RequestAuth, maxAttempts int) (string, error)
@@ -44,3 +51,4 @@ type DeepSeekCaller interface {
 var _ ConfigStore = (*config.Store)(nil)
 var _ PoolController = (*account.Pool)(nil)
 var _ DeepSeekCaller = (*deepseek.Client)(nil)
+var _ RateLimitReporter = (*ratelimit.Limiter)(nil)
@@ -12,6 +12,7 @@ import (
 
 	"ds2api/internal/config"
 	"ds2api/internal/monitor"
+	"ds2api/internal/usage"
 )
 
 type Handler struct {
@@ -21,6 +22,8 @@ type Handler struct {
 	APIKeyManager *config.APIKeyManager
 	Monitor       *monitor.Monitor
 	Notifier      *monitor.Notifier
+	RateLimiter   RateLimitReporter
+	Usage         *usage.Ledger
 }
 
 func RegisterRoutes(r chi.Router, h *Handler) {
@@ -41,6 +44,13 @@ func RegisterRoutes(r chi.Router, h *Handler) {
 		pr.Get("/keys/metadata", h.getAPIKeysMetadata)
 		pr.Get("/keys/expiring", h.getExpiringKeys)
 		pr.Get("/keys/expired", h.getExpiredKeys)
+		pr.Post("/keys/{key}/renew", h.renewKey)
+		pr.Put("/keys/{key}/scopes", h.updateKeyScopes)
+		pr.Get("/keys/rate-limits", h.getKeyRateLimits)
+		pr.Put("/keys/{key}/rate-limits", h.updateKeyRateLimits)
+		pr.Put("/keys/{key}/quota", h.updateKeyQuota)
+		pr.Get("/usage", h.getUsage)
+		pr.Get("/usage/quotas", h.getUsageQuotas)
 		pr.Get("/accounts", h.listAccounts)
 		pr.Post("/accounts", h.addAccount)
 		pr.Put("/accounts/{identifier}", h.updateAccount)
@@ -129,6 +139,38 @@ func (h *Handler) getExpiredKeys(w http.ResponseWriter, r *http.Request) {
 	h.writeJSONResponse(w, h.APIKeyManager.GetExpiredKeys())
 }
 
+// getKeyRateLimits reports each configured key's effective limits and what
+// is left of them; budgets of unlimited dimensions are null.
+func (h *Handler) getKeyRateLimits(w http.ResponseWriter, r *http.Request) {
+	if !requireService(h.RateLimiter, "Rate Limiter", w) {
+		return
+	}
+	snap := h.Store.Snapshot()
+	items := make([]map[string]any, 0, len(snap.APIKeys))
+	for _, metadata := range snap.APIKeys {
+		id, limits := metadata.Identifier(), snap.KeyRateLimits(metadata)
+		st := h.RateLimiter.Status(id, limits)
+		item := map[string]any{
+			"id":                 id,
+			"key":                metadata.KeyPrefix,
+			"limits":             limits,
+			"remaining_requests": nil,
+			"remaining_tokens":   nil,
+			"inflight":           st.Inflight,
+		}
+		if limits.RequestsPerMinute > 0 {
+			item["remaining_requests"] = st.RemainingRequests
+			item["reset_requests_seconds"] = st.ResetRequests.Seconds()
+		}
+		if limits.TokensPerMinute > 0 {
+			item["remaining_tokens"] = st.RemainingTokens
+			item["reset_tokens_seconds"] = st.ResetTokens.Seconds()
+		}
+		items = append(items, item)
+	}
+	h.writeJSONResponse(w, map[string]any{"defaults": snap.RateLimits, "keys": items})
+}
+
 func (h *Handler) getNotifications(w http.ResponseWriter, r *http.Request) {
 	if !requireService(h.Notifier, "Notifier", w) {
 		return
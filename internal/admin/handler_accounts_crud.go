@@ -57,6 +57,7 @@ func (h *Handler) listAccounts(w http.ResponseWriter, r *http.Request) {
 			"has_password":  acc.Password != "",
 			"has_token":     token != "",
 			"token_preview": preview,
+			"group":         acc.Group,
 		})
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"items": items, "total": total, "page": page, "page_size": pageSize, "total_pages": totalPages})
@@ -160,6 +161,9 @@ func (h *Handler) updateAccount(w http.ResponseWriter, r *http.Request) {
 		if updatedAcc.Token != "" {
 			c.Accounts[idx].Token = updatedAcc.Token
 		}
+		if _, ok := req["group"]; ok {
+			c.Accounts[idx].Group = updatedAcc.Group
+		}
 
 		fmt.Printf("[UPDATE] After update: email='%s', password_len=%d\n", c.Accounts[idx].Email, len(c.Accounts[idx].Password))
 
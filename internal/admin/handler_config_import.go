@@ -51,22 +51,14 @@ func (h *Handler) configImport(w http.ResponseWriter, r *http.Request) {
 			next = incoming.Clone()
 			next.VercelSyncHash = c.VercelSyncHash
 			next.VercelSyncTime = c.VercelSyncTime
-			importedKeys = len(next.Keys)
+			importedKeys = len(next.Keys) + len(next.APIKeys)
 			importedAccounts = len(next.Accounts)
 		} else {
-			existingKeys := map[string]struct{}{}
-			for _, k := range next.Keys {
-				existingKeys[k] = struct{}{}
-			}
 			for _, k := range incoming.Keys {
 				key := strings.TrimSpace(k)
-				if key == "" {
-					continue
-				}
-				if _, ok := existingKeys[key]; ok {
+				if key == "" || next.HasAPIKey(key) {
 					continue
 				}
-				existingKeys[key] = struct{}{}
 				next.Keys = append(next.Keys, key)
 				importedKeys++
 			}
@@ -119,12 +111,24 @@ func (h *Handler) configImport(w http.ResponseWriter, r *http.Request) {
 			if strings.TrimSpace(incoming.Toolcall.EarlyEmitConfidence) != "" {
 				next.Toolcall.EarlyEmitConfidence = incoming.Toolcall.EarlyEmitConfidence
 			}
+			if strings.TrimSpace(incoming.Toolcall.InvalidArgs) != "" {
+				next.Toolcall.InvalidArgs = incoming.Toolcall.InvalidArgs
+			}
 			if incoming.Responses.StoreTTLSeconds > 0 {
 				next.Responses.StoreTTLSeconds = incoming.Responses.StoreTTLSeconds
 			}
 			if strings.TrimSpace(incoming.Embeddings.Provider) != "" {
 				next.Embeddings.Provider = incoming.Embeddings.Provider
 			}
+			if incoming.APIKeyExpiry.TTLDays != 0 {
+				next.APIKeyExpiry.TTLDays = incoming.APIKeyExpiry.TTLDays
+			}
+			if !incoming.RateLimits.IsZero() {
+				next.RateLimits = incoming.RateLimits
+			}
+			if incoming.Usage.MonthlyTokenQuota != 0 {
+				next.Usage.MonthlyTokenQuota = incoming.Usage.MonthlyTokenQuota
+			}
 			if strings.TrimSpace(incoming.Admin.PasswordHash) != "" {
 				next.Admin.PasswordHash = incoming.Admin.PasswordHash
 			}
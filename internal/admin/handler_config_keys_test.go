@@ -1,13 +1,18 @@
 package admin
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 
 	"ds2api/internal/config"
+	"ds2api/internal/ratelimit"
 )
 
 func TestAddKeyWithManagerPersistsAndListedInConfig(t *testing.T) {
@@ -27,6 +32,12 @@ func TestAddKeyWithManagerPersistsAndListedInConfig(t *testing.T) {
 	if ok, _ := addPayload["persisted"].(bool); !ok {
 		t.Fatalf("expected persisted=true in add response: %#v", addPayload)
 	}
+	if addPayload["key"] != "sk-test-123" || addPayload["key_prefix"] != "sk-te" || addPayload["id"] == "" {
+		t.Fatalf("expected the key, its prefix and ID in add response: %#v", addPayload)
+	}
+	if exported, _, _ := h.Store.ExportJSONAndBase64(); strings.Contains(exported, "sk-test-123") {
+		t.Fatalf("expected the key to be stored hashed: %s", exported)
+	}
 
 	cfgReq := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
 	cfgRec := httptest.NewRecorder()
@@ -40,11 +51,42 @@ func TestAddKeyWithManagerPersistsAndListedInConfig(t *testing.T) {
 		t.Fatalf("decode response failed: %v", err)
 	}
 	keys, _ := payload["keys"].([]any)
-	if len(keys) != 1 || keys[0] != "sk-test-123" {
+	if len(keys) != 1 || keys[0] != "sk-te" {
 		t.Fatalf("unexpected keys list: %#v", keys)
 	}
 }
 
+func TestAddKeyGeneratesKeyAndDeleteByPrefix(t *testing.T) {
+	h := newAdminTestHandler(t, `{}`)
+	h.APIKeyManager = config.NewAPIKeyManager(h.Store.(*config.Store))
+
+	addRec := httptest.NewRecorder()
+	h.addKey(addRec, httptest.NewRequest(http.MethodPost, "/admin/keys", strings.NewReader(`{}`)))
+	if addRec.Code != http.StatusOK {
+		t.Fatalf("unexpected add status: %d body=%s", addRec.Code, addRec.Body.String())
+	}
+	var added struct {
+		Key       string `json:"key"`
+		KeyPrefix string `json:"key_prefix"`
+	}
+	if err := json.Unmarshal(addRec.Body.Bytes(), &added); err != nil {
+		t.Fatalf("decode add response failed: %v", err)
+	}
+	if !strings.HasPrefix(added.Key, "sk-") || !strings.HasPrefix(added.Key, added.KeyPrefix) || !h.Store.HasAPIKey(added.Key) {
+		t.Fatalf("expected a generated, stored key: %#v", added)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/keys/"+added.KeyPrefix, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("key", added.KeyPrefix)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rec := httptest.NewRecorder()
+	h.deleteKey(rec, req)
+	if rec.Code != http.StatusOK || h.Store.HasAPIKey(added.Key) {
+		t.Fatalf("expected delete by prefix to remove the key: %d body=%s", rec.Code, rec.Body.String())
+	}
+}
+
 func TestAddKeyRejectsInvalidJSON(t *testing.T) {
 	h := newAdminTestHandler(t, `{}`)
 
@@ -75,7 +117,84 @@ func TestGetConfigIncludesStoredAPIKeysWithoutManager(t *testing.T) {
 	}
 
 	keys, _ := payload["keys"].([]any)
-	if len(keys) != 2 || keys[0] != "legacy-k1" || keys[1] != "sk-meta-001" {
+	if len(keys) != 2 || keys[0] != "sk-me" || keys[1] != "lega" {
 		t.Fatalf("unexpected keys list: %#v", keys)
 	}
 }
+
+func TestRenewKeyExtendsExpiredKey(t *testing.T) {
+	h := newAdminTestHandler(t, `{"api_keys":[{"id":"apikey:1","key":"sk-old-001","created_at":"2020-01-01T00:00:00Z","expires_at":"2020-01-31T00:00:00Z"}]}`)
+	store := h.Store.(*config.Store)
+	h.APIKeyManager = config.NewAPIKeyManager(store)
+	if !store.IsAPIKeyExpired("sk-old-001") {
+		t.Fatal("expected fixture key to start expired")
+	}
+
+	renew := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/keys/sk-old-001/renew", strings.NewReader(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("key", "sk-old-001")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := httptest.NewRecorder()
+		h.renewKey(rec, req)
+		return rec
+	}
+
+	if rec := renew(`{"expires_at":"2020-06-01T00:00:00Z"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected a past expires_at to be rejected, got %d", rec.Code)
+	}
+	rec := renew(`{"ttl_days":10}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected renew status: %d body=%s", rec.Code, rec.Body.String())
+	}
+	metadata, _ := h.APIKeyManager.GetAPIKeyMetadata("sk-old-001")
+	if until := time.Until(metadata.ExpiresAt); until < 9*24*time.Hour || until > 10*24*time.Hour {
+		t.Fatalf("expected expiry about 10 days out, got %v", metadata.ExpiresAt)
+	}
+	if !store.HasValidAPIKey("sk-old-001") {
+		t.Fatal("expected renewed key to be valid")
+	}
+}
+
+func TestKeyRateLimitsReportsRemainingBudgets(t *testing.T) {
+	h := newAdminTestHandler(t, `{"rate_limits":{"requests_per_minute":5},"api_keys":[{"id":"apikey:1","key":"sk-limited-001"}]}`)
+	store := h.Store.(*config.Store)
+	h.APIKeyManager = config.NewAPIKeyManager(store)
+	limiter := ratelimit.New()
+	h.RateLimiter = limiter
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/keys/sk-limited-001/rate-limits", strings.NewReader(`{"tokens_per_minute":1000}`))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("key", "sk-limited-001")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rec := httptest.NewRecorder()
+	h.updateKeyRateLimits(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected update status: %d body=%s", rec.Code, rec.Body.String())
+	}
+
+	id, limits, _ := store.APIKeyRateLimits("sk-limited-001")
+	limiter.Acquire(id, limits, 400, false)
+
+	rec = httptest.NewRecorder()
+	h.getKeyRateLimits(rec, httptest.NewRequest(http.MethodGet, "/admin/keys/rate-limits", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rec.Code, rec.Body.String())
+	}
+	var payload struct {
+		Keys []map[string]any `json:"keys"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if len(payload.Keys) != 1 {
+		t.Fatalf("expected one key, got %#v", payload.Keys)
+	}
+	item := payload.Keys[0]
+	if item["id"] != "apikey:1" || item["remaining_requests"] != float64(4) || item["remaining_tokens"] != float64(600) {
+		t.Fatalf("unexpected budgets: %#v", item)
+	}
+	if strings.Contains(rec.Body.String(), "sk-limited-001") {
+		t.Fatal("expected the raw key to be masked")
+	}
+}
@@ -2,33 +2,18 @@ package admin
 
 import (
 	"net/http"
-	"slices"
 	"strings"
 )
 
 func (h *Handler) getConfig(w http.ResponseWriter, _ *http.Request) {
 	snap := h.Store.Snapshot()
-	keys := slices.Clone(snap.Keys)
+	// Keys are stored hashed, so only their visible prefixes can be listed.
+	keys := h.Store.KeyPrefixes()
 	if h.APIKeyManager != nil {
-		keys = h.APIKeyManager.GetValidKeys()
-	} else if len(snap.APIKeys) > 0 {
-		seen := make(map[string]struct{}, len(snap.Keys)+len(snap.APIKeys))
-		keys = make([]string, 0, len(snap.Keys)+len(snap.APIKeys))
-
-		for _, key := range snap.Keys {
-			if _, exists := seen[key]; exists {
-				continue
-			}
-			seen[key] = struct{}{}
-			keys = append(keys, key)
-		}
-
-		for _, metadata := range snap.APIKeys {
-			if _, exists := seen[metadata.Key]; exists {
-				continue
-			}
-			seen[metadata.Key] = struct{}{}
-			keys = append(keys, metadata.Key)
+		valid := h.APIKeyManager.GetValidAPIKeysMetadata()
+		keys = make([]string, 0, len(valid))
+		for _, metadata := range valid {
+			keys = append(keys, metadata.KeyPrefix)
 		}
 	}
 	safe := map[string]any{
@@ -59,6 +44,7 @@ func (h *Handler) getConfig(w http.ResponseWriter, _ *http.Request) {
 			"has_password":  strings.TrimSpace(acc.Password) != "",
 			"has_token":     token != "",
 			"token_preview": preview,
+			"group":         acc.Group,
 		})
 	}
 	safe["accounts"] = accounts
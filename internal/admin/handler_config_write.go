@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
@@ -19,8 +20,14 @@ func (h *Handler) updateConfig(w http.ResponseWriter, r *http.Request) {
 	}
 	old := h.Store.Snapshot()
 	err := h.Store.Update(func(c *config.Config) error {
+		// Keys are stored hashed and cannot be listed back, so "keys" only
+		// adds new ones; DELETE /admin/keys/{key} removes them.
 		if keys, ok := toStringSlice(req["keys"]); ok {
-			c.Keys = keys
+			for _, key := range keys {
+				if key != "" && !c.HasAPIKey(key) {
+					c.Keys = append(c.Keys, key)
+				}
+			}
 		}
 		if accountsRaw, ok := req["accounts"].([]any); ok {
 			existing := map[string]config.Account{}
@@ -64,6 +71,8 @@ func (h *Handler) updateConfig(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]any{"success": true, "message": "配置已更新"})
 }
 
+// addKey stores a key, generating one when the body has none. The response
+// is the only place the full key is shown; it is stored as a hash.
 func (h *Handler) addKey(w http.ResponseWriter, r *http.Request) {
 	var req map[string]any
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -74,8 +83,17 @@ func (h *Handler) addKey(w http.ResponseWriter, r *http.Request) {
 	key, _ := req["key"].(string)
 	key = strings.TrimSpace(key)
 	if key == "" {
-		config.Logger.Warn("[admin][keys] rejected empty key")
-		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "Key 不能为空"})
+		key = config.GenerateAPIKey()
+	}
+
+	expiresAt, err := parseKeyExpiry(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	scopes, err := parseKeyScopes(req["scopes"])
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
 		return
 	}
 
@@ -83,13 +101,20 @@ func (h *Handler) addKey(w http.ResponseWriter, r *http.Request) {
 	config.Logger.Info("[admin][keys] add key requested", "key", masked, "has_manager", h.APIKeyManager != nil)
 
 	if h.APIKeyManager != nil {
-		if err := h.APIKeyManager.AddAPIKey(key); err != nil {
+		metadata, err := h.APIKeyManager.AddAPIKeyWithExpiry(key, expiresAt)
+		if err != nil {
 			config.Logger.Error("[admin][keys] failed to persist key via manager", "key", masked, "error", err)
 			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error(), "success": false, "persisted": false})
 			return
 		}
+		if scopes != nil {
+			if _, err := h.APIKeyManager.SetAPIKeyScopes(key, *scopes); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error(), "success": false, "persisted": false})
+				return
+			}
+		}
 		persisted := h.APIKeyManager.IsAPIKeyValid(key)
-		totalKeys := len(h.APIKeyManager.GetValidKeys())
+		totalKeys := len(h.APIKeyManager.GetValidAPIKeysMetadata())
 		if err := h.ensureEnvBackedConfigPersistence(r.Context()); err != nil {
 			config.Logger.Error("[admin][keys] env-backed persistence failed", "key", masked, "error", err)
 			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": err.Error(), "success": false, "persisted": false})
@@ -100,15 +125,13 @@ func (h *Handler) addKey(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": "key persistence validation failed", "success": false, "persisted": false})
 			return
 		}
-		writeJSON(w, http.StatusOK, map[string]any{"success": true, "persisted": true, "total_keys": totalKeys})
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "persisted": true, "total_keys": totalKeys, "key": key, "id": metadata.ID, "key_prefix": metadata.KeyPrefix})
 		return
 	}
 
-	err := h.Store.Update(func(c *config.Config) error {
-		for _, k := range c.Keys {
-			if k == key {
-				return fmt.Errorf("Key 已存在")
-			}
+	err = h.Store.Update(func(c *config.Config) error {
+		if c.HasAPIKey(key) {
+			return fmt.Errorf("Key 已存在")
 		}
 		c.Keys = append(c.Keys, key)
 		return nil
@@ -118,15 +141,8 @@ func (h *Handler) addKey(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error(), "success": false, "persisted": false})
 		return
 	}
-	snapshot := h.Store.Snapshot()
-	persisted := false
-	for _, existingKey := range snapshot.Keys {
-		if existingKey == key {
-			persisted = true
-			break
-		}
-	}
-	totalKeys := len(snapshot.Keys)
+	persisted := h.Store.HasAPIKey(key)
+	totalKeys := len(h.Store.KeyPrefixes())
 	if err := h.ensureEnvBackedConfigPersistence(r.Context()); err != nil {
 		config.Logger.Error("[admin][keys] env-backed persistence failed", "key", masked, "error", err)
 		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": err.Error(), "success": false, "persisted": false})
@@ -137,9 +153,12 @@ func (h *Handler) addKey(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": "key persistence validation failed", "success": false, "persisted": false})
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"success": true, "persisted": true, "total_keys": totalKeys})
+	id, _ := h.Store.APIKeyID(key)
+	writeJSON(w, http.StatusOK, map[string]any{"success": true, "persisted": true, "total_keys": totalKeys, "key": key, "id": id, "key_prefix": config.APIKeyPrefix(key)})
 }
 
+// deleteKey removes the key named by the path: the key, its ID or its
+// prefix.
 func (h *Handler) deleteKey(w http.ResponseWriter, r *http.Request) {
 	key := chi.URLParam(r, "key")
 
@@ -152,22 +171,16 @@ func (h *Handler) deleteKey(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": err.Error()})
 			return
 		}
-		writeJSON(w, http.StatusOK, map[string]any{"success": true, "total_keys": len(h.APIKeyManager.GetValidKeys())})
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "total_keys": len(h.APIKeyManager.GetValidAPIKeysMetadata())})
 		return
 	}
 
 	err := h.Store.Update(func(c *config.Config) error {
-		idx := -1
-		for i, k := range c.Keys {
-			if k == key {
-				idx = i
-				break
-			}
-		}
-		if idx < 0 {
+		idx, ok := config.FindAPIKeyIndex(c, key)
+		if !ok {
 			return fmt.Errorf("Key 不存在")
 		}
-		c.Keys = append(c.Keys[:idx], c.Keys[idx+1:]...)
+		c.APIKeys = append(c.APIKeys[:idx], c.APIKeys[idx+1:]...)
 		return nil
 	})
 	if err != nil {
@@ -178,7 +191,169 @@ func (h *Handler) deleteKey(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": err.Error()})
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"success": true, "total_keys": len(h.Store.Snapshot().Keys)})
+	writeJSON(w, http.StatusOK, map[string]any{"success": true, "total_keys": len(h.Store.KeyPrefixes())})
+}
+
+// renewKey moves a key's expiry. The body is optional: without expires_at or
+// ttl_days the key gets a fresh configured TTL from now.
+func (h *Handler) renewKey(w http.ResponseWriter, r *http.Request) {
+	if !requireService(h.APIKeyManager, "API Key Manager", w) {
+		return
+	}
+	key := chi.URLParam(r, "key")
+	req := map[string]any{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid json"})
+			return
+		}
+	}
+	expiresAt, err := parseKeyExpiry(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	metadata, err := h.APIKeyManager.RenewAPIKey(key, expiresAt)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": err.Error()})
+		return
+	}
+	if err := h.ensureEnvBackedConfigPersistence(r.Context()); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": err.Error()})
+		return
+	}
+	config.Logger.Info("[admin][keys] key renewed", "key", safeTruncate(key, 8), "expires_at", metadata.ExpiresAt)
+	writeJSON(w, http.StatusOK, map[string]any{"success": true, "id": metadata.ID, "expires_at": metadata.ExpiresAt})
+}
+
+// updateKeyScopes replaces a key's scopes with the request body; an empty
+// object restores full access.
+func (h *Handler) updateKeyScopes(w http.ResponseWriter, r *http.Request) {
+	if !requireService(h.APIKeyManager, "API Key Manager", w) {
+		return
+	}
+	key := chi.URLParam(r, "key")
+	var req map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid json"})
+		return
+	}
+	scopes, err := parseKeyScopes(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	metadata, err := h.APIKeyManager.SetAPIKeyScopes(key, *scopes)
+	if err != nil {
+		status := http.StatusNotFound
+		if err == config.ErrInvalidAPIKeyScopes {
+			status = http.StatusBadRequest
+		}
+		writeJSON(w, status, map[string]any{"detail": err.Error()})
+		return
+	}
+	if err := h.ensureEnvBackedConfigPersistence(r.Context()); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": err.Error()})
+		return
+	}
+	config.Logger.Info("[admin][keys] key scopes updated", "key", safeTruncate(key, 8))
+	writeJSON(w, http.StatusOK, map[string]any{"success": true, "id": metadata.ID, "scopes": metadata.Scopes})
+}
+
+// updateKeyRateLimits replaces a key's rate limits with the request body;
+// zero fields inherit the global defaults and negative ones lift the limit.
+func (h *Handler) updateKeyRateLimits(w http.ResponseWriter, r *http.Request) {
+	if !requireService(h.APIKeyManager, "API Key Manager", w) {
+		return
+	}
+	key := chi.URLParam(r, "key")
+	var limits config.RateLimitConfig
+	if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid json"})
+		return
+	}
+	metadata, err := h.APIKeyManager.SetAPIKeyRateLimits(key, limits)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": err.Error()})
+		return
+	}
+	if err := h.ensureEnvBackedConfigPersistence(r.Context()); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": err.Error()})
+		return
+	}
+	config.Logger.Info("[admin][keys] key rate limits updated", "key", safeTruncate(key, 8))
+	writeJSON(w, http.StatusOK, map[string]any{"success": true, "id": metadata.ID, "rate_limits": metadata.RateLimits})
+}
+
+// updateKeyQuota sets a key's monthly token quota from
+// {"monthly_token_quota": n}.
+func (h *Handler) updateKeyQuota(w http.ResponseWriter, r *http.Request) {
+	if !requireService(h.APIKeyManager, "API Key Manager", w) {
+		return
+	}
+	key := chi.URLParam(r, "key")
+	var req struct {
+		MonthlyTokenQuota *int64 `json:"monthly_token_quota"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MonthlyTokenQuota == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "monthly_token_quota must be an integer"})
+		return
+	}
+	metadata, err := h.APIKeyManager.SetAPIKeyQuota(key, *req.MonthlyTokenQuota)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": err.Error()})
+		return
+	}
+	if err := h.ensureEnvBackedConfigPersistence(r.Context()); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": err.Error()})
+		return
+	}
+	config.Logger.Info("[admin][keys] key quota updated", "key", safeTruncate(key, 8), "monthly_token_quota", metadata.MonthlyTokenQuota)
+	writeJSON(w, http.StatusOK, map[string]any{"success": true, "id": metadata.ID, "monthly_token_quota": metadata.MonthlyTokenQuota})
+}
+
+// parseKeyScopes decodes an optional scopes object; nil means the request
+// did not mention scopes.
+func parseKeyScopes(raw any) (*config.APIKeyScopes, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	if _, ok := raw.(map[string]any); !ok {
+		return nil, fmt.Errorf("scopes must be an object")
+	}
+	data, _ := json.Marshal(raw)
+	var scopes config.APIKeyScopes
+	if err := json.Unmarshal(data, &scopes); err != nil {
+		return nil, fmt.Errorf("invalid scopes: %v", err)
+	}
+	if err := scopes.Validate(); err != nil {
+		return nil, err
+	}
+	return &scopes, nil
+}
+
+// parseKeyExpiry reads an optional expires_at (RFC 3339) or ttl_days; the
+// zero time leaves the choice to the configured TTL.
+func parseKeyExpiry(req map[string]any) (time.Time, error) {
+	if raw, ok := req["expires_at"]; ok && raw != nil {
+		s, _ := raw.(string)
+		expiresAt, err := time.Parse(time.RFC3339, strings.TrimSpace(s))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("expires_at must be an RFC 3339 timestamp")
+		}
+		if !expiresAt.After(time.Now()) {
+			return time.Time{}, fmt.Errorf("expires_at must be in the future")
+		}
+		return expiresAt, nil
+	}
+	if raw, ok := req["ttl_days"]; ok && raw != nil {
+		days, ok := raw.(float64)
+		if !ok || days != float64(int(days)) || days < 1 {
+			return time.Time{}, fmt.Errorf("ttl_days must be a positive integer")
+		}
+		return time.Now().Add(time.Duration(days) * 24 * time.Hour), nil
+	}
+	return time.Time{}, nil
 }
 
 func (h *Handler) batchImport(w http.ResponseWriter, r *http.Request) {
@@ -190,17 +365,12 @@ func (h *Handler) batchImport(w http.ResponseWriter, r *http.Request) {
 	importedKeys, importedAccounts := 0, 0
 	err := h.Store.Update(func(c *config.Config) error {
 		if keys, ok := req["keys"].([]any); ok {
-			existing := map[string]bool{}
-			for _, k := range c.Keys {
-				existing[k] = true
-			}
 			for _, k := range keys {
 				key := strings.TrimSpace(fmt.Sprintf("%v", k))
-				if key == "" || existing[key] {
+				if key == "" || c.HasAPIKey(key) {
 					continue
 				}
 				c.Keys = append(c.Keys, key)
-				existing[key] = true
 				importedKeys++
 			}
 		}
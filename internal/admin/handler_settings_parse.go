@@ -79,6 +79,15 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 				return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("toolcall.early_emit_confidence must be high, low or off")
 			}
 		}
+		if v, exists := raw["invalid_args"]; exists {
+			mode := strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", v)))
+			switch mode {
+			case "pass_through", "drop", "repair":
+				cfg.InvalidArgs = mode
+			default:
+				return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("toolcall.invalid_args must be pass_through, drop or repair")
+			}
+		}
 		toolcallCfg = cfg
 	}
 
@@ -103,6 +112,39 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 			}
 			cfg.Provider = p
 		}
+		if v, exists := raw["base_url"]; exists {
+			cfg.BaseURL = strings.TrimSpace(fmt.Sprintf("%v", v))
+		}
+		if v, exists := raw["api_key"]; exists {
+			cfg.APIKey = strings.TrimSpace(fmt.Sprintf("%v", v))
+		}
+		if v, exists := raw["model_map"].(map[string]any); exists {
+			cfg.ModelMap = map[string]string{}
+			for k, mv := range v {
+				key := strings.TrimSpace(k)
+				val := strings.TrimSpace(fmt.Sprintf("%v", mv))
+				if key != "" && val != "" {
+					cfg.ModelMap[key] = val
+				}
+			}
+		}
+		if v, exists := raw["dimensions"]; exists {
+			n := intFrom(v)
+			if n < 0 || n > 8192 {
+				return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("embeddings.dimensions must be between 0 and 8192")
+			}
+			cfg.Dimensions = n
+		}
+		if v, exists := raw["timeout_seconds"]; exists {
+			n := intFrom(v)
+			if n < 0 || n > 600 {
+				return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("embeddings.timeout_seconds must be between 0 and 600")
+			}
+			cfg.TimeoutSeconds = n
+		}
+		if v, exists := raw["task_type_field"]; exists {
+			cfg.TaskTypeField = strings.TrimSpace(fmt.Sprintf("%v", v))
+		}
 		embCfg = cfg
 	}
 
@@ -27,10 +27,24 @@ func (h *Handler) getSettings(w http.ResponseWriter, _ *http.Request) {
 		},
 		"toolcall":          snap.Toolcall,
 		"responses":         snap.Responses,
-		"embeddings":        snap.Embeddings,
+		"embeddings":        settingsEmbeddings(snap.Embeddings),
 		"claude_mapping":    settingsClaudeMapping(snap),
 		"model_aliases":     snap.ModelAliases,
 		"env_backed":        h.Store.IsEnvBacked(),
 		"needs_vercel_sync": needsSync,
 	})
 }
+
+// settingsEmbeddings hides the upstream API key; the UI only needs to know
+// whether one is set.
+func settingsEmbeddings(cfg config.EmbeddingsConfig) map[string]any {
+	return map[string]any{
+		"provider":        cfg.Provider,
+		"base_url":        cfg.BaseURL,
+		"has_api_key":     cfg.APIKey != "",
+		"model_map":       cfg.ModelMap,
+		"dimensions":      cfg.Dimensions,
+		"timeout_seconds": cfg.TimeoutSeconds,
+		"task_type_field": cfg.TaskTypeField,
+	}
+}
@@ -178,7 +178,7 @@ func TestConfigImportMergeAndReplace(t *testing.T) {
 	if mergeRec.Code != http.StatusOK {
 		t.Fatalf("merge status=%d body=%s", mergeRec.Code, mergeRec.Body.String())
 	}
-	if got := len(h.Store.Keys()); got != 2 {
+	if got := len(h.Store.KeyPrefixes()); got != 2 {
 		t.Fatalf("keys after merge=%d want=2", got)
 	}
 	if got := len(h.Store.Accounts()); got != 2 {
@@ -198,8 +198,8 @@ func TestConfigImportMergeAndReplace(t *testing.T) {
 	if replaceRec.Code != http.StatusOK {
 		t.Fatalf("replace status=%d body=%s", replaceRec.Code, replaceRec.Body.String())
 	}
-	keys := h.Store.Keys()
-	if len(keys) != 1 || keys[0] != "k9" {
+	keys := h.Store.KeyPrefixes()
+	if len(keys) != 1 || !h.Store.HasAPIKey("k9") {
 		t.Fatalf("unexpected keys after replace: %#v", keys)
 	}
 	if got := len(h.Store.Accounts()); got != 0 {
@@ -228,8 +228,8 @@ func TestConfigImportRejectsInvalidRuntimeBounds(t *testing.T) {
 	if !bytes.Contains(rec.Body.Bytes(), []byte("runtime.account_max_inflight")) {
 		t.Fatalf("expected runtime bound detail, got %s", rec.Body.String())
 	}
-	keys := h.Store.Keys()
-	if len(keys) != 1 || keys[0] != "k1" {
+	keys := h.Store.KeyPrefixes()
+	if len(keys) != 1 || !h.Store.HasAPIKey("k1") {
 		t.Fatalf("store should remain unchanged, keys=%v", keys)
 	}
 }
@@ -265,3 +265,36 @@ func TestConfigImportRejectsMergedRuntimeConflict(t *testing.T) {
 		t.Fatalf("runtime should remain unchanged, runtime=%+v", snap.Runtime)
 	}
 }
+
+func TestSettingsEmbeddingsRoundTripMasksAPIKey(t *testing.T) {
+	h := newAdminTestHandler(t, `{"keys":["k1"],"embeddings":{"provider":"deterministic"}}`)
+	payload := map[string]any{
+		"embeddings": map[string]any{
+			"provider":  "openai",
+			"base_url":  "http://127.0.0.1:9000/v1",
+			"api_key":   "sk-upstream",
+			"model_map": map[string]any{"*": "bge-m3"},
+		},
+	}
+	b, _ := json.Marshal(payload)
+	rec := httptest.NewRecorder()
+	h.updateSettings(rec, httptest.NewRequest(http.MethodPut, "/admin/settings", bytes.NewReader(b)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if got := h.Store.Snapshot().Embeddings; got.APIKey != "sk-upstream" || got.ModelMap["*"] != "bge-m3" {
+		t.Fatalf("embeddings not persisted: %#v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.getSettings(rec, httptest.NewRequest(http.MethodGet, "/admin/settings", nil))
+	if bytes.Contains(rec.Body.Bytes(), []byte("sk-upstream")) {
+		t.Fatalf("settings leaked the embeddings api key: %s", rec.Body.String())
+	}
+	var body map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	emb, _ := body["embeddings"].(map[string]any)
+	if emb["provider"] != "openai" || emb["has_api_key"] != true {
+		t.Fatalf("unexpected embeddings settings: %#v", emb)
+	}
+}
@@ -53,12 +53,35 @@ func (h *Handler) updateSettings(w http.ResponseWriter, r *http.Request) {
 			if strings.TrimSpace(toolcallCfg.EarlyEmitConfidence) != "" {
 				c.Toolcall.EarlyEmitConfidence = strings.TrimSpace(toolcallCfg.EarlyEmitConfidence)
 			}
+			if strings.TrimSpace(toolcallCfg.InvalidArgs) != "" {
+				c.Toolcall.InvalidArgs = strings.TrimSpace(toolcallCfg.InvalidArgs)
+			}
 		}
 		if responsesCfg != nil && responsesCfg.StoreTTLSeconds > 0 {
 			c.Responses.StoreTTLSeconds = responsesCfg.StoreTTLSeconds
 		}
-		if embeddingsCfg != nil && strings.TrimSpace(embeddingsCfg.Provider) != "" {
-			c.Embeddings.Provider = strings.TrimSpace(embeddingsCfg.Provider)
+		if embeddingsCfg != nil {
+			if strings.TrimSpace(embeddingsCfg.Provider) != "" {
+				c.Embeddings.Provider = strings.TrimSpace(embeddingsCfg.Provider)
+			}
+			if embeddingsCfg.BaseURL != "" {
+				c.Embeddings.BaseURL = embeddingsCfg.BaseURL
+			}
+			if embeddingsCfg.APIKey != "" {
+				c.Embeddings.APIKey = embeddingsCfg.APIKey
+			}
+			if embeddingsCfg.ModelMap != nil {
+				c.Embeddings.ModelMap = embeddingsCfg.ModelMap
+			}
+			if embeddingsCfg.Dimensions > 0 {
+				c.Embeddings.Dimensions = embeddingsCfg.Dimensions
+			}
+			if embeddingsCfg.TimeoutSeconds > 0 {
+				c.Embeddings.TimeoutSeconds = embeddingsCfg.TimeoutSeconds
+			}
+			if embeddingsCfg.TaskTypeField != "" {
+				c.Embeddings.TaskTypeField = embeddingsCfg.TaskTypeField
+			}
 		}
 		if claudeMap != nil {
 			c.ClaudeMapping = claudeMap
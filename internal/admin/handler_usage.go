@@ -0,0 +1,199 @@
+package admin
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ds2api/internal/config"
+	"ds2api/internal/usage"
+)
+
+// getUsage aggregates the usage ledger. Filters: key (ID, raw key or
+// prefix), model (requested or resolved), surface, account, from/to (RFC
+// 3339 or YYYY-MM-DD; a date "to" includes that day). group_by is a comma list of
+// hour/day/month, key, surface, model, resolved_model and account, "key" by
+// default. The range defaults to the current UTC month; format=csv exports
+// the rows.
+func (h *Handler) getUsage(w http.ResponseWriter, r *http.Request) {
+	if !requireService(h.Usage, "Usage Ledger", w) {
+		return
+	}
+	q, err := h.parseUsageQuery(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	rows := h.Usage.Query(q)
+	if strings.EqualFold(r.URL.Query().Get("format"), "csv") {
+		writeUsageCSV(w, q.GroupBy, rows)
+		return
+	}
+	h.writeJSONResponse(w, map[string]any{
+		"from":     q.From,
+		"to":       nilIfZeroTime(q.To),
+		"group_by": q.GroupBy,
+		"rows":     rows,
+		"totals":   usage.Sum(rows),
+	})
+}
+
+// getUsageQuotas reports each key's monthly token quota and what is left of
+// it this UTC month; quota and remaining are null for unlimited keys.
+func (h *Handler) getUsageQuotas(w http.ResponseWriter, r *http.Request) {
+	if !requireService(h.Usage, "Usage Ledger", w) {
+		return
+	}
+	now := time.Now().UTC()
+	resetsAt := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	snap := h.Store.Snapshot()
+	items := make([]map[string]any, 0, len(snap.APIKeys))
+	for _, metadata := range snap.APIKeys {
+		id, quota := metadata.Identifier(), snap.KeyMonthlyQuota(metadata)
+		used := h.Usage.MonthTokens(id, now)
+		item := map[string]any{
+			"id":                  id,
+			"key":                 metadata.KeyPrefix,
+			"monthly_token_quota": nilIfZero(quota),
+			"used":                used,
+			"remaining":           nil,
+			"resets_at":           resetsAt,
+		}
+		if quota > 0 {
+			item["remaining"] = max(quota-used, 0)
+		}
+		items = append(items, item)
+	}
+	h.writeJSONResponse(w, map[string]any{"default_monthly_token_quota": snap.Usage.MonthlyTokenQuota, "keys": items})
+}
+
+func (h *Handler) parseUsageQuery(r *http.Request) (usage.Query, error) {
+	values := r.URL.Query()
+	q := usage.Query{
+		KeyID:   strings.TrimSpace(values.Get("key")),
+		Model:   strings.TrimSpace(values.Get("model")),
+		Surface: strings.TrimSpace(values.Get("surface")),
+		Account: strings.TrimSpace(values.Get("account")),
+	}
+	if q.KeyID != "" && !strings.HasPrefix(q.KeyID, "apikey:") && !strings.HasPrefix(q.KeyID, "caller:") {
+		snap := h.Store.Snapshot()
+		if i, ok := config.FindAPIKeyIndex(&snap, q.KeyID); ok {
+			q.KeyID = snap.APIKeys[i].Identifier()
+		}
+	}
+	var err error
+	if q.From, err = parseUsageTime(values.Get("from"), false); err != nil {
+		return q, fmt.Errorf("from: %w", err)
+	}
+	if q.To, err = parseUsageTime(values.Get("to"), true); err != nil {
+		return q, fmt.Errorf("to: %w", err)
+	}
+	if q.From.IsZero() && q.To.IsZero() {
+		now := time.Now().UTC()
+		q.From = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	q.GroupBy = []string{usage.GroupKey}
+	if raw := strings.TrimSpace(values.Get("group_by")); raw != "" {
+		q.GroupBy = nil
+		for _, g := range strings.Split(raw, ",") {
+			g = strings.ToLower(strings.TrimSpace(g))
+			if !usage.ValidGrouping(g) {
+				return q, fmt.Errorf("unknown group_by %q", g)
+			}
+			q.GroupBy = append(q.GroupBy, g)
+		}
+	}
+	return q, nil
+}
+
+// parseUsageTime accepts RFC 3339 or a date; endOfDay moves a date to the
+// start of the next day so an inclusive "to" date covers it.
+func parseUsageTime(raw string, endOfDay bool) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("must be RFC 3339 or YYYY-MM-DD")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func nilIfZeroTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// writeUsageCSV writes one column per grouped dimension, in query order,
+// followed by the metrics.
+func writeUsageCSV(w http.ResponseWriter, groupBy []string, rows []usage.Row) {
+	var dims []string
+	hasPeriod := false
+	for _, g := range groupBy {
+		switch g {
+		case usage.GroupHour, usage.GroupDay, usage.GroupMonth:
+			if !hasPeriod {
+				dims = append(dims, "period")
+				hasPeriod = true
+			}
+		case usage.GroupKey:
+			dims = append(dims, "key_id")
+		default:
+			dims = append(dims, g)
+		}
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="usage.csv"`)
+	cw := csv.NewWriter(w)
+	_ = cw.Write(append(dims, "requests", "errors", "input_tokens", "output_tokens", "reasoning_tokens", "total_tokens", "avg_latency_ms"))
+	for _, row := range rows {
+		record := make([]string, 0, len(dims)+7)
+		for _, d := range dims {
+			record = append(record, usageDimension(row, d))
+		}
+		record = append(record,
+			strconv.FormatInt(row.Requests, 10),
+			strconv.FormatInt(row.Errors, 10),
+			strconv.FormatInt(row.InputTokens, 10),
+			strconv.FormatInt(row.OutputTokens, 10),
+			strconv.FormatInt(row.ReasoningTokens, 10),
+			strconv.FormatInt(row.TotalTokens, 10),
+			strconv.FormatFloat(row.AvgLatencyMs, 'f', 1, 64),
+		)
+		_ = cw.Write(record)
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		config.Logger.Error("[admin][usage] failed to write CSV", "error", err)
+	}
+}
+
+func usageDimension(row usage.Row, dim string) string {
+	switch dim {
+	case "period":
+		return row.Period
+	case "key_id":
+		return row.KeyID
+	case usage.GroupSurface:
+		return row.Surface
+	case usage.GroupModel:
+		return row.Model
+	case usage.GroupResolvedModel:
+		return row.ResolvedModel
+	case usage.GroupAccount:
+		return row.Account
+	}
+	return ""
+}
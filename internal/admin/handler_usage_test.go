@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ds2api/internal/usage"
+)
+
+func newUsageTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	h := newAdminTestHandler(t, `{"usage":{"monthly_token_quota":1000},"api_keys":[{"id":"apikey:team","key":"sk-team-001"},{"id":"apikey:free","key":"sk-free-001","monthly_token_quota":-1}]}`)
+	ledger, _ := usage.Open("")
+	for _, e := range []usage.Entry{
+		{KeyID: "apikey:team", Surface: "openai", Model: "gpt-4o", ResolvedModel: "deepseek-chat", InputTokens: 300, OutputTokens: 100, Status: 200},
+		{KeyID: "apikey:team", Surface: "claude", Model: "claude-sonnet-4", ResolvedModel: "deepseek-chat", InputTokens: 50, OutputTokens: 50, Status: 200},
+		{KeyID: "apikey:free", Surface: "openai", Model: "gpt-4o", ResolvedModel: "deepseek-chat", InputTokens: 10, OutputTokens: 10, Status: 500},
+	} {
+		if err := ledger.Record(e); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	h.Usage = ledger
+	return h
+}
+
+func TestGetUsageFiltersAndGroups(t *testing.T) {
+	h := newUsageTestHandler(t)
+
+	rec := httptest.NewRecorder()
+	h.getUsage(rec, httptest.NewRequest(http.MethodGet, "/admin/usage?key=sk-team-001&group_by=key,surface", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rec.Code, rec.Body.String())
+	}
+	var payload struct {
+		Rows   []usage.Row `json:"rows"`
+		Totals usage.Row   `json:"totals"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if len(payload.Rows) != 2 || payload.Rows[0].Surface != "claude" || payload.Rows[1].KeyID != "apikey:team" || payload.Totals.TotalTokens != 500 {
+		t.Fatalf("expected the raw key to resolve to its ID and group by surface, got %+v", payload)
+	}
+
+	rec = httptest.NewRecorder()
+	h.getUsage(rec, httptest.NewRequest(http.MethodGet, "/admin/usage?group_by=model&format=csv", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("expected CSV, got %q", ct)
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 || lines[0] != "model,requests,errors,input_tokens,output_tokens,reasoning_tokens,total_tokens,avg_latency_ms" || !strings.HasPrefix(lines[2], "gpt-4o,2,1,310,110,0,420,") {
+		t.Fatalf("unexpected CSV: %q", lines)
+	}
+
+	for _, query := range []string{"group_by=week", "from=yesterday"} {
+		rec = httptest.NewRecorder()
+		h.getUsage(rec, httptest.NewRequest(http.MethodGet, "/admin/usage?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestGetUsageQuotasReportsRemaining(t *testing.T) {
+	h := newUsageTestHandler(t)
+	rec := httptest.NewRecorder()
+	h.getUsageQuotas(rec, httptest.NewRequest(http.MethodGet, "/admin/usage/quotas", nil))
+	var payload struct {
+		Keys []map[string]any `json:"keys"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if len(payload.Keys) != 2 {
+		t.Fatalf("expected two keys, got %#v", payload.Keys)
+	}
+	team, free := payload.Keys[0], payload.Keys[1]
+	if team["used"] != float64(500) || team["remaining"] != float64(500) || team["monthly_token_quota"] != float64(1000) {
+		t.Fatalf("unexpected team quota: %#v", team)
+	}
+	if free["monthly_token_quota"] != nil || free["remaining"] != nil || free["used"] != float64(20) {
+		t.Fatalf("expected an unlimited key to report null quota, got %#v", free)
+	}
+}
@@ -65,6 +65,7 @@ func toAccount(m map[string]any) config.Account {
 		Mobile:   fieldString(m, "mobile"),
 		Password: fieldString(m, "password"),
 		Token:    fieldString(m, "token"),
+		Group:    fieldString(m, "group"),
 	}
 }
 
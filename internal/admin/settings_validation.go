@@ -14,6 +14,7 @@ func normalizeSettingsConfig(c *config.Config) {
 	c.Admin.PasswordHash = strings.TrimSpace(c.Admin.PasswordHash)
 	c.Toolcall.Mode = strings.ToLower(strings.TrimSpace(c.Toolcall.Mode))
 	c.Toolcall.EarlyEmitConfidence = strings.ToLower(strings.TrimSpace(c.Toolcall.EarlyEmitConfidence))
+	c.Toolcall.InvalidArgs = strings.ToLower(strings.TrimSpace(c.Toolcall.InvalidArgs))
 	c.Embeddings.Provider = strings.TrimSpace(c.Embeddings.Provider)
 }
 
@@ -41,6 +42,13 @@ func validateSettingsConfig(c config.Config) error {
 			return fmt.Errorf("toolcall.early_emit_confidence must be high, low or off")
 		}
 	}
+	if mode := strings.TrimSpace(c.Toolcall.InvalidArgs); mode != "" {
+		switch mode {
+		case "pass_through", "drop", "repair":
+		default:
+			return fmt.Errorf("toolcall.invalid_args must be pass_through, drop or repair")
+		}
+	}
 	if c.Embeddings.Provider != "" && strings.TrimSpace(c.Embeddings.Provider) == "" {
 		return fmt.Errorf("embeddings.provider cannot be empty")
 	}
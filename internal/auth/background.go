@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"ds2api/internal/config"
+	"ds2api/internal/usage"
+)
+
+// minBackgroundRateLimitWait keeps a rate-limited background request from
+// spinning when the limiter reports no wait.
+const minBackgroundRateLimitWait = 50 * time.Millisecond
+
+// ErrAPIKeyRemoved is returned for background work whose key was deleted
+// after the work was submitted.
+var ErrAPIKeyRemoved = errors.New("unauthorized: API key is no longer configured")
+
+// BackgroundRequest is one request run outside HTTP, such as a batch line,
+// on behalf of the managed key that submitted it.
+type BackgroundRequest struct {
+	CallerID string
+	KeyID    string
+	// Path is the endpoint the request would have been sent to.
+	Path string
+	// Body is the request body; nil checks the key and route only.
+	Body []byte
+}
+
+// CheckBackground applies the submitting key's checks to job, so background
+// work cannot do more than the key could over HTTP. Runners call
+// AcquireBackground, which checks again, before every request, because the
+// key may have changed since submission.
+func (r *Resolver) CheckBackground(job BackgroundRequest) error {
+	_, err := r.backgroundKey(job)
+	return err
+}
+
+// backgroundKey returns the key job runs under, refusing keys deleted or
+// expired since submission and requests outside its route and model scopes.
+func (r *Resolver) backgroundKey(job BackgroundRequest) (config.APIKeyMetadata, error) {
+	metadata, active, ok := r.Store.APIKeyByID(job.KeyID)
+	if !ok {
+		return metadata, ErrAPIKeyRemoved
+	}
+	if !active {
+		return metadata, ErrAPIKeyExpired
+	}
+	model := ""
+	if len(metadata.Scopes.Models) > 0 {
+		model = bodyModel(job.Body)
+	}
+	if err := r.checkRouteAndModel(metadata.Scopes, job.Path, model, job.Body != nil); err != nil {
+		return metadata, err
+	}
+	return metadata, nil
+}
+
+// admitBackground checks job and the key's quota and charges job to the
+// key's rate limits, checking again after every wait.
+func (r *Resolver) admitBackground(ctx context.Context, job BackgroundRequest) (config.APIKeyMetadata, string, error) {
+	for {
+		metadata, err := r.backgroundKey(job)
+		if err != nil {
+			return metadata, "", err
+		}
+		if err := r.checkKeyQuota(job.KeyID, r.Store.KeyMonthlyQuota(metadata)); err != nil {
+			return metadata, "", err
+		}
+		rateLimitID, err := r.chargeRateLimit(job.KeyID, r.Store.KeyRateLimits(metadata), job.Body, true)
+		var limited *RateLimitError
+		if !errors.As(err, &limited) {
+			return metadata, rateLimitID, err
+		}
+		timer := time.NewTimer(max(limited.Decision.RetryAfter, minBackgroundRateLimitWait))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return metadata, "", ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// AcquireBackground checks job and binds a pooled account for it. It yields
+// to interactive traffic and must be paired with Release like any other
+// managed auth. Rate limits are waited out rather than refused, since no
+// client is waiting on the response; ctx bounds the wait.
+func (r *Resolver) AcquireBackground(ctx context.Context, job BackgroundRequest) (*RequestAuth, error) {
+	metadata, rateLimitID, err := r.admitBackground(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	group := metadata.Scopes.AccountGroup
+	acc, ok := r.Pool.AcquireLowPriority(ctx, r.groupExclude(group, nil))
+	if !ok {
+		r.releaseRateLimit(rateLimitID)
+		return nil, ErrNoAccount
+	}
+	a := &RequestAuth{
+		UseConfigToken: true,
+		CallerID:       job.CallerID,
+		KeyID:          job.KeyID,
+		AccountID:      acc.Identifier(),
+		Account:        acc,
+		AccountGroup:   group,
+		TriedAccounts:  map[string]bool{},
+		resolver:       r,
+		rateLimitID:    rateLimitID,
+	}
+	if acc.Token == "" {
+		if err := r.loginAndPersist(ctx, a); err != nil {
+			r.Pool.Release(a.AccountID)
+			r.releaseRateLimit(rateLimitID)
+			return nil, err
+		}
+	} else {
+		a.DeepSeekToken = acc.Token
+	}
+	return a, nil
+}
+
+// ServeBackground runs serve for req, a background request bound to a by
+// AcquireBackground, and records it in the usage ledger under the key that
+// submitted it, as the HTTP middleware does for interactive requests.
+func (r *Resolver) ServeBackground(a *RequestAuth, w http.ResponseWriter, req *http.Request, serve http.HandlerFunc) {
+	usage.Middleware(r.Usage)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.noteUsage(req, a.KeyID)
+		usage.FromContext(req.Context()).SetAccount(a.AccountID)
+		serve(w, req)
+	})).ServeHTTP(w, req)
+}
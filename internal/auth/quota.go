@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"ds2api/internal/config"
+	"ds2api/internal/usage"
+)
+
+// QuotaError is returned when a managed key has used up its monthly token
+// quota; adapters turn it into 429 with Retry-After until ResetsAt.
+type QuotaError struct {
+	Used     int64
+	Quota    int64
+	ResetsAt time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota exceeded: API key has used %d of its %d monthly tokens, resets at %s", e.Used, e.Quota, e.ResetsAt.Format(time.RFC3339))
+}
+
+// RetryAfterSeconds is the wait until the quota resets, at least 1.
+func (e *QuotaError) RetryAfterSeconds() int {
+	return max(int(time.Until(e.ResetsAt).Seconds()+0.5), 1)
+}
+
+// nextMonthStart returns the start of the UTC calendar month after t, when
+// monthly quotas reset.
+func nextMonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// checkQuota refuses a managed key that has used its monthly token quota.
+// Usage lands in the ledger when a request finishes, so the request that
+// crosses the quota still completes.
+func (r *Resolver) checkQuota(callerKey string) error {
+	if r.Usage == nil {
+		return nil
+	}
+	id, quota, ok := r.Store.APIKeyQuota(callerKey)
+	if !ok {
+		return nil
+	}
+	return r.checkKeyQuota(id, quota)
+}
+
+// checkKeyQuota refuses key id once it has used quota tokens this month.
+func (r *Resolver) checkKeyQuota(id string, quota int64) error {
+	if r.Usage == nil || quota <= 0 {
+		return nil
+	}
+	now := time.Now()
+	if used := r.Usage.MonthTokens(id, now); used >= quota {
+		return &QuotaError{Used: used, Quota: quota, ResetsAt: nextMonthStart(now)}
+	}
+	return nil
+}
+
+// noteUsage bills the request's usage record, if any, to keyID and records
+// the model it asks for.
+func (r *Resolver) noteUsage(req *http.Request, keyID string) {
+	rec := usage.FromContext(req.Context())
+	if rec == nil {
+		return
+	}
+	rec.SetCaller(keyID, RouteFamily(req.URL.Path))
+	if model := requestModel(req); model != "" {
+		resolved := ""
+		if r.Store != nil {
+			resolved, _ = config.ResolveModel(r.Store, model)
+		}
+		rec.SetModel(model, resolved)
+	}
+}
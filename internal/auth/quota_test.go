@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ds2api/internal/account"
+	"ds2api/internal/config"
+	"ds2api/internal/usage"
+)
+
+func TestDetermineBillsUsageAndEnforcesMonthlyQuota(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"usage":{"monthly_token_quota":100},
+		"api_keys":[
+			{"id":"apikey:metered","key":"metered-key"},
+			{"id":"apikey:vip","key":"vip-key","monthly_token_quota":-1}
+		],
+		"accounts":[{"email":"a@example.com","password":"pwd","token":"token-a"}]
+	}`)
+	store := config.LoadStore()
+	r := NewResolver(store, account.NewPool(store), func(_ context.Context, _ config.Account) (string, error) {
+		return "fresh-token", nil
+	})
+	ledger, _ := usage.Open("")
+	r.Usage = ledger
+
+	handler := usage.Middleware(ledger)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		a, err := r.Determine(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		defer r.Release(a)
+		_, _ = w.Write([]byte(`{"usage":{"prompt_tokens":80,"completion_tokens":40}}` + "\n"))
+	}))
+	serve := func(key string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, scopedRequest("/v1/chat/completions", key, `{"model":"gpt-4o"}`))
+		return rec.Code
+	}
+
+	// The request crossing the quota completes; the next one is refused.
+	if code := serve("metered-key"); code != http.StatusOK {
+		t.Fatalf("expected the first request to pass, got %d", code)
+	}
+	_, err := r.Determine(scopedRequest("/v1/chat/completions", "metered-key", `{}`))
+	var quota *QuotaError
+	if !errors.As(err, &quota) || quota.Used != 120 || quota.Quota != 100 || quota.RetryAfterSeconds() < 1 {
+		t.Fatalf("expected a quota error, got %v", err)
+	}
+	if code := serve("metered-key"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the refused request to fail, got %d", code)
+	}
+	for i := 0; i < 2; i++ {
+		if code := serve("vip-key"); code != http.StatusOK {
+			t.Fatalf("expected a lifted quota to pass, got %d", code)
+		}
+	}
+
+	rows := ledger.Query(usage.Query{KeyID: "apikey:metered", GroupBy: []string{usage.GroupKey, usage.GroupSurface, usage.GroupModel, usage.GroupResolvedModel, usage.GroupAccount}})
+	if len(rows) != 2 {
+		t.Fatalf("expected the served and refused requests in separate rows, got %+v", rows)
+	}
+	for _, row := range rows {
+		if row.Surface != "openai" || row.Model != "gpt-4o" || row.ResolvedModel == "" {
+			t.Fatalf("expected caller, surface and models to be recorded, got %+v", row)
+		}
+	}
+	if rows[1].Account != "a@example.com" || rows[1].TotalTokens != 120 || rows[0].Account != "" || rows[0].Errors != 1 {
+		t.Fatalf("unexpected rows: %+v", rows)
+	}
+}
+
+func TestBackgroundRequestsAreBilledAndQuotaLimited(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"api_keys":[{"id":"apikey:metered","key":"metered-key","monthly_token_quota":100}],
+		"accounts":[{"email":"a@example.com","password":"pwd","token":"token-a"}]
+	}`)
+	store := config.LoadStore()
+	r := NewResolver(store, account.NewPool(store), nil)
+	ledger, _ := usage.Open("")
+	r.Usage = ledger
+	job := BackgroundRequest{CallerID: "caller:metered", KeyID: "apikey:metered", Path: "/v1/chat/completions", Body: []byte(`{"model":"gpt-4o"}`)}
+
+	a, err := r.AcquireBackground(context.Background(), job)
+	if err != nil {
+		t.Fatalf("expected the batch line to run, got %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, job.Path, strings.NewReader(string(job.Body)))
+	r.ServeBackground(a, httptest.NewRecorder(), req, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"usage":{"prompt_tokens":80,"completion_tokens":40}}`))
+	})
+	r.Release(a)
+	if used := ledger.MonthTokens("apikey:metered", time.Now()); used != 120 {
+		t.Fatalf("expected the batch line to be billed to its key, got %d tokens", used)
+	}
+	rows := ledger.Query(usage.Query{KeyID: "apikey:metered", GroupBy: []string{usage.GroupSurface, usage.GroupModel, usage.GroupAccount}})
+	if len(rows) != 1 || rows[0].Surface != "openai" || rows[0].Model != "gpt-4o" || rows[0].Account != "a@example.com" {
+		t.Fatalf("unexpected usage rows: %+v", rows)
+	}
+
+	_, err = r.AcquireBackground(context.Background(), job)
+	var quota *QuotaError
+	if !errors.As(err, &quota) || quota.Used != 120 {
+		t.Fatalf("expected the next batch line to be refused over quota, got %v", err)
+	}
+}
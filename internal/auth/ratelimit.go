@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"ds2api/internal/config"
+	"ds2api/internal/ratelimit"
+	"ds2api/internal/util"
+)
+
+// RateLimitError is returned when a managed key is over one of its limits;
+// adapters turn it into 429 with Retry-After and rate limit headers.
+type RateLimitError struct {
+	Decision ratelimit.Decision
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded: API key is over its %s limit, retry after %ds", e.Decision.Reason, e.Decision.RetryAfterSeconds())
+}
+
+// takeRateLimit charges a managed key's request against its limits and
+// returns the limiter ID to release when hold took a concurrency slot.
+func (r *Resolver) takeRateLimit(req *http.Request, callerKey string, hold bool) (string, error) {
+	if r.Limiter == nil {
+		return "", nil
+	}
+	id, limits, ok := r.Store.APIKeyRateLimits(callerKey)
+	if !ok || limits.IsZero() {
+		return "", nil
+	}
+	var body []byte
+	if limits.TokensPerMinute > 0 {
+		body = peekBody(req)
+	}
+	return r.chargeRateLimit(id, limits, body, hold)
+}
+
+// chargeRateLimit charges a request with body to the limits of key id.
+func (r *Resolver) chargeRateLimit(id string, limits config.RateLimitConfig, body []byte, hold bool) (string, error) {
+	if r.Limiter == nil || limits.IsZero() {
+		return "", nil
+	}
+	tokens := 0
+	if limits.TokensPerMinute > 0 {
+		tokens = estimateRequestTokens(body)
+	}
+	hold = hold && limits.MaxConcurrent > 0
+	d := r.Limiter.Acquire(id, limits, tokens, hold)
+	if !d.Allowed {
+		return "", &RateLimitError{Decision: d}
+	}
+	if !hold {
+		return "", nil
+	}
+	return id, nil
+}
+
+func (r *Resolver) releaseRateLimit(id string) {
+	if id != "" && r.Limiter != nil {
+		r.Limiter.Release(id)
+	}
+}
+
+// estimateRequestTokens approximates the tokens-per-minute cost of a request:
+// the body text plus the requested output cap, since output counts too.
+func estimateRequestTokens(body []byte) int {
+	if len(body) == 0 {
+		return 0
+	}
+	var probe struct {
+		MaxTokens           float64 `json:"max_tokens"`
+		MaxCompletionTokens float64 `json:"max_completion_tokens"`
+		MaxOutputTokens     float64 `json:"max_output_tokens"`
+		GenerationConfig    struct {
+			MaxOutputTokens float64 `json:"maxOutputTokens"`
+		} `json:"generationConfig"`
+		Options struct {
+			NumPredict float64 `json:"num_predict"`
+		} `json:"options"`
+	}
+	_ = json.Unmarshal(body, &probe)
+	output := max(probe.MaxTokens, probe.MaxCompletionTokens, probe.MaxOutputTokens, probe.GenerationConfig.MaxOutputTokens, probe.Options.NumPredict, 0)
+	return util.EstimateTokens(string(body)) + int(output)
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ds2api/internal/account"
+	"ds2api/internal/config"
+	"ds2api/internal/ratelimit"
+)
+
+func newRateLimitedResolver(t *testing.T) *Resolver {
+	t.Helper()
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"rate_limits":{"requests_per_minute":2},
+		"api_keys":[
+			{"id":"apikey:busy","key":"busy-key","rate_limits":{"max_concurrent":1,"requests_per_minute":-1}},
+			{"id":"apikey:plain","key":"plain-key"},
+			{"id":"apikey:tokens","key":"tokens-key","rate_limits":{"tokens_per_minute":100,"requests_per_minute":-1}}
+		],
+		"accounts":[
+			{"email":"a@example.com","password":"pwd","token":"token-a"},
+			{"email":"b@example.com","password":"pwd","token":"token-b"}
+		]
+	}`)
+	store := config.LoadStore()
+	return NewResolver(store, account.NewPool(store), func(_ context.Context, _ config.Account) (string, error) {
+		return "fresh-token", nil
+	})
+}
+
+func TestDetermineAppliesDefaultRateLimits(t *testing.T) {
+	r := newRateLimitedResolver(t)
+	for i := 0; i < 2; i++ {
+		a, err := r.Determine(scopedRequest("/v1/chat/completions", "plain-key", `{}`))
+		if err != nil {
+			t.Fatalf("request %d: expected allowed, got %v", i, err)
+		}
+		r.Release(a)
+	}
+	_, err := r.Determine(scopedRequest("/v1/chat/completions", "plain-key", `{}`))
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || limited.Decision.Reason != ratelimit.ReasonRequests {
+		t.Fatalf("expected a requests rate limit error, got %v", err)
+	}
+	if _, err := r.DetermineCaller(scopedRequest("/v1/responses/x", "plain-key", `{}`)); !errors.As(err, &limited) {
+		t.Fatalf("expected caller-only routes to share the budget, got %v", err)
+	}
+
+	// Unmanaged tokens pass through to DeepSeek and are never limited.
+	for i := 0; i < 3; i++ {
+		if _, err := r.Determine(scopedRequest("/v1/chat/completions", "raw-deepseek-token", `{}`)); err != nil {
+			t.Fatalf("expected passthrough tokens to skip rate limits, got %v", err)
+		}
+	}
+}
+
+func TestDetermineHoldsConcurrencyUntilRelease(t *testing.T) {
+	r := newRateLimitedResolver(t)
+	a, err := r.Determine(scopedRequest("/v1/chat/completions", "busy-key", `{}`))
+	if err != nil {
+		t.Fatalf("expected the first request to pass, got %v", err)
+	}
+	var limited *RateLimitError
+	if _, err := r.Determine(scopedRequest("/v1/chat/completions", "busy-key", `{}`)); !errors.As(err, &limited) || limited.Decision.Reason != ratelimit.ReasonConcurrency {
+		t.Fatalf("expected a concurrency rate limit error, got %v", err)
+	}
+	r.Release(a)
+	r.Release(a)
+	b, err := r.Determine(scopedRequest("/v1/chat/completions", "busy-key", `{}`))
+	if err != nil {
+		t.Fatalf("expected the released slot to be reusable, got %v", err)
+	}
+	r.Release(b)
+	if st := r.Limiter.Status("apikey:busy", config.RateLimitConfig{MaxConcurrent: 1}); st.Inflight != 0 {
+		t.Fatalf("expected no slots held after release, got %d", st.Inflight)
+	}
+}
+
+func TestDetermineChargesEstimatedTokens(t *testing.T) {
+	r := newRateLimitedResolver(t)
+	if a, err := r.Determine(scopedRequest("/v1/chat/completions", "tokens-key", `{"messages":[],"max_tokens":60}`)); err != nil {
+		t.Fatalf("expected the first request to fit, got %v", err)
+	} else {
+		r.Release(a)
+	}
+	_, err := r.Determine(scopedRequest("/v1/chat/completions", "tokens-key", `{"messages":[],"max_tokens":60}`))
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || limited.Decision.Reason != ratelimit.ReasonTokens {
+		t.Fatalf("expected a tokens rate limit error, got %v", err)
+	}
+}
+
+func TestDetermineChargesTokensWhateverContentType(t *testing.T) {
+	r := newRateLimitedResolver(t)
+	for i := 0; i < 2; i++ {
+		req := scopedRequest("/v1/chat/completions", "tokens-key", `{"messages":[],"max_tokens":60}`)
+		req.Header.Set("Content-Type", "text/plain")
+		a, err := r.Determine(req)
+		if i == 0 {
+			if err != nil {
+				t.Fatalf("expected the first request to fit, got %v", err)
+			}
+			r.Release(a)
+			continue
+		}
+		var limited *RateLimitError
+		if !errors.As(err, &limited) || limited.Decision.Reason != ratelimit.ReasonTokens {
+			t.Fatalf("expected a text/plain body to be charged too, got %v", err)
+		}
+	}
+}
+
+func TestAcquireBackgroundWaitsOutRateLimits(t *testing.T) {
+	r := newRateLimitedResolver(t)
+	held, err := r.Determine(scopedRequest("/v1/chat/completions", "busy-key", `{}`))
+	if err != nil {
+		t.Fatalf("expected the interactive request to pass, got %v", err)
+	}
+	job := BackgroundRequest{CallerID: "caller:busy", KeyID: "apikey:busy", Path: "/v1/chat/completions", Body: []byte(`{}`)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := r.AcquireBackground(ctx, job); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the batch line to wait for the concurrency slot, got %v", err)
+	}
+
+	time.AfterFunc(100*time.Millisecond, func() { r.Release(held) })
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	a, err := r.AcquireBackground(ctx, job)
+	if err != nil {
+		t.Fatalf("expected the batch line to run once the slot was free, got %v", err)
+	}
+	if st := r.Limiter.Status("apikey:busy", config.RateLimitConfig{MaxConcurrent: 1}); st.Inflight != 1 {
+		t.Fatalf("expected the batch line to hold the slot, got %d", st.Inflight)
+	}
+	r.Release(a)
+	if st := r.Limiter.Status("apikey:busy", config.RateLimitConfig{MaxConcurrent: 1}); st.Inflight != 0 {
+		t.Fatalf("expected release to return the slot, got %d", st.Inflight)
+	}
+}
@@ -10,6 +10,8 @@ import (
 
 	"ds2api/internal/account"
 	"ds2api/internal/config"
+	"ds2api/internal/ratelimit"
+	"ds2api/internal/usage"
 )
 
 type ctxKey string
@@ -19,16 +21,27 @@ const authCtxKey ctxKey = "auth_context"
 var (
 	ErrUnauthorized = errors.New("unauthorized: missing auth token")
 	ErrNoAccount   = errors.New("no accounts configured or all accounts are busy")
+	// ErrAPIKeyExpired is returned for a configured key past its expires_at,
+	// instead of passing it through as a DeepSeek token.
+	ErrAPIKeyExpired = errors.New("unauthorized: API key has expired")
 )
 
 type RequestAuth struct {
 	UseConfigToken bool
 	DeepSeekToken  string
 	CallerID       string
+	// KeyID is the metadata ID of a managed caller key, empty for direct
+	// DeepSeek tokens.
+	KeyID          string
 	AccountID      string
 	Account        config.Account
+	// AccountGroup is the caller key's account_group scope; SwitchAccount
+	// stays inside it.
+	AccountGroup   string
 	TriedAccounts  map[string]bool
 	resolver       *Resolver
+	// rateLimitID names the concurrency slot Release must return.
+	rateLimitID    string
 }
 
 type LoginFunc func(ctx context.Context, acc config.Account) (string, error)
@@ -37,10 +50,14 @@ type Resolver struct {
 	Store *config.Store
 	Pool  *account.Pool
 	Login LoginFunc
+	// Limiter enforces per-key rate limits; nil disables them.
+	Limiter *ratelimit.Limiter
+	// Usage backs monthly token quotas; nil disables them.
+	Usage *usage.Ledger
 }
 
 func NewResolver(store *config.Store, pool *account.Pool, login LoginFunc) *Resolver {
-	return &Resolver{Store: store, Pool: pool, Login: login}
+	return &Resolver{Store: store, Pool: pool, Login: login, Limiter: ratelimit.New()}
 }
 
 func (r *Resolver) Determine(req *http.Request) (*RequestAuth, error) {
@@ -51,7 +68,11 @@ func (r *Resolver) Determine(req *http.Request) (*RequestAuth, error) {
 
 	callerID := callerTokenID(callerKey)
 	ctx := req.Context()
+	if r.Store.IsAPIKeyExpired(callerKey) {
+		return nil, ErrAPIKeyExpired
+	}
 	if !r.Store.HasValidAPIKey(callerKey) {
+		r.noteUsage(req, callerID)
 		return &RequestAuth{
 			UseConfigToken: false,
 			DeepSeekToken:  callerKey,
@@ -60,23 +81,45 @@ func (r *Resolver) Determine(req *http.Request) (*RequestAuth, error) {
 			TriedAccounts:  map[string]bool{},
 		}, nil
 	}
+	keyID, _ := r.Store.APIKeyID(callerKey)
+	r.noteUsage(req, keyID)
 
+	scopes, err := r.checkScopes(req, callerKey, true)
+	if err != nil {
+		return nil, err
+	}
 	target := strings.TrimSpace(req.Header.Get("X-Ds2-Target-Account"))
-	acc, ok := r.Pool.AcquireWait(ctx, target, nil)
+	if err := r.checkTargetAccount(scopes, target); err != nil {
+		return nil, err
+	}
+	if err := r.checkQuota(callerKey); err != nil {
+		return nil, err
+	}
+	rateLimitID, err := r.takeRateLimit(req, callerKey, true)
+	if err != nil {
+		return nil, err
+	}
+	acc, ok := r.Pool.AcquireWait(ctx, target, r.groupExclude(scopes.AccountGroup, nil))
 	if !ok {
+		r.releaseRateLimit(rateLimitID)
 		return nil, ErrNoAccount
 	}
 	a := &RequestAuth{
 		UseConfigToken: true,
 		CallerID:       callerID,
+		KeyID:          keyID,
 		AccountID:      acc.Identifier(),
 		Account:        acc,
+		AccountGroup:   scopes.AccountGroup,
 		TriedAccounts:  map[string]bool{},
 		resolver:       r,
+		rateLimitID:    rateLimitID,
 	}
+	usage.FromContext(ctx).SetAccount(a.AccountID)
 	if acc.Token == "" {
 		if err := r.loginAndPersist(ctx, a); err != nil {
 			r.Pool.Release(a.AccountID)
+			r.releaseRateLimit(rateLimitID)
 			return nil, err
 		}
 	} else {
@@ -92,6 +135,9 @@ func (r *Resolver) DetermineCaller(req *http.Request) (*RequestAuth, error) {
 	if callerKey == "" {
 		return nil, ErrUnauthorized
 	}
+	if r != nil && r.Store != nil && r.Store.IsAPIKeyExpired(callerKey) {
+		return nil, ErrAPIKeyExpired
+	}
 	callerID := callerTokenID(callerKey)
 	a := &RequestAuth{
 		UseConfigToken: false,
@@ -101,6 +147,22 @@ func (r *Resolver) DetermineCaller(req *http.Request) (*RequestAuth, error) {
 	}
 	if r == nil || r.Store == nil || !r.Store.HasValidAPIKey(callerKey) {
 		a.DeepSeekToken = callerKey
+		if r != nil {
+			r.noteUsage(req, callerID)
+		}
+		return a, nil
+	}
+	keyID, _ := r.Store.APIKeyID(callerKey)
+	a.KeyID = keyID
+	r.noteUsage(req, keyID)
+	if _, err := r.checkScopes(req, callerKey, false); err != nil {
+		return nil, err
+	}
+	if err := r.checkQuota(callerKey); err != nil {
+		return nil, err
+	}
+	if _, err := r.takeRateLimit(req, callerKey, false); err != nil {
+		return nil, err
 	}
 	return a, nil
 }
@@ -158,12 +220,13 @@ func (r *Resolver) SwitchAccount(ctx context.Context, a *RequestAuth) bool {
 		a.TriedAccounts[a.AccountID] = true
 		r.Pool.Release(a.AccountID)
 	}
-	acc, ok := r.Pool.Acquire("", a.TriedAccounts)
+	acc, ok := r.Pool.Acquire("", r.groupExclude(a.AccountGroup, a.TriedAccounts))
 	if !ok {
 		return false
 	}
 	a.Account = acc
 	a.AccountID = acc.Identifier()
+	usage.FromContext(ctx).SetAccount(a.AccountID)
 	if acc.Token == "" {
 		if err := r.loginAndPersist(ctx, a); err != nil {
 			return false
@@ -175,7 +238,12 @@ func (r *Resolver) SwitchAccount(ctx context.Context, a *RequestAuth) bool {
 }
 
 func (r *Resolver) Release(a *RequestAuth) {
-	if a == nil || !a.UseConfigToken || a.AccountID == "" {
+	if a == nil {
+		return
+	}
+	r.releaseRateLimit(a.rateLimitID)
+	a.rateLimitID = ""
+	if !a.UseConfigToken || a.AccountID == "" {
 		return
 	}
 	r.Pool.Release(a.AccountID)
@@ -193,3 +193,48 @@ func TestDetermineCallerMissingToken(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestAcquireBackgroundBindsPooledAccount(t *testing.T) {
+	r := newTestResolver(t)
+	keyID, _ := r.Store.APIKeyID("managed-key")
+
+	auth, err := r.AcquireBackground(context.Background(), BackgroundRequest{CallerID: "caller:batch", KeyID: keyID})
+	if err != nil {
+		t.Fatalf("acquire background failed: %v", err)
+	}
+	defer r.Release(auth)
+	if !auth.UseConfigToken || auth.AccountID != "acc@example.com" {
+		t.Fatalf("unexpected auth: %#v", auth)
+	}
+	if auth.CallerID != "caller:batch" || auth.KeyID != keyID || auth.DeepSeekToken != "account-token" {
+		t.Fatalf("unexpected caller/key/token: %q %q %q", auth.CallerID, auth.KeyID, auth.DeepSeekToken)
+	}
+}
+
+func TestAcquireBackgroundRefusesRemovedKey(t *testing.T) {
+	r := newTestResolver(t)
+	if _, err := r.AcquireBackground(context.Background(), BackgroundRequest{CallerID: "caller:batch", KeyID: "apikey:gone"}); err != ErrAPIKeyRemoved {
+		t.Fatalf("expected ErrAPIKeyRemoved, got %v", err)
+	}
+	if _, err := r.AcquireBackground(context.Background(), BackgroundRequest{CallerID: "caller:batch"}); err != ErrAPIKeyRemoved {
+		t.Fatalf("expected batches without a key ID to be refused, got %v", err)
+	}
+}
+
+func TestDetermineRejectsExpiredManagedKey(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"api_keys":[{"id":"apikey:old","key":"expired-key","created_at":"2020-01-01T00:00:00Z","expires_at":"2020-01-31T00:00:00Z"}],
+		"accounts":[{"email":"acc@example.com","password":"pwd","token":"account-token"}]
+	}`)
+	store := config.LoadStore()
+	r := NewResolver(store, account.NewPool(store), nil)
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("Authorization", "Bearer expired-key")
+
+	if _, err := r.Determine(req); err != ErrAPIKeyExpired {
+		t.Fatalf("expected ErrAPIKeyExpired, got %v", err)
+	}
+	if _, err := r.DetermineCaller(req); err != ErrAPIKeyExpired {
+		t.Fatalf("expected ErrAPIKeyExpired from DetermineCaller, got %v", err)
+	}
+}
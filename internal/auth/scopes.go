@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"ds2api/internal/config"
+)
+
+// ErrForbidden matches every scope violation; adapters map it to 403.
+var ErrForbidden = errors.New("forbidden: API key scope does not allow this request")
+
+// scopeError keeps the specific reason while matching ErrForbidden.
+type scopeError struct {
+	reason string
+}
+
+func (e *scopeError) Error() string {
+	return "forbidden: " + e.reason
+}
+
+func (e *scopeError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+func forbidden(format string, args ...any) error {
+	return &scopeError{reason: fmt.Sprintf(format, args...)}
+}
+
+// RouteFamily classifies a business route for key scopes. Embeddings routes
+// of every protocol form their own family.
+func RouteFamily(path string) string {
+	switch {
+	case path == "/v1/embeddings" || strings.HasSuffix(path, ":embedContent") || strings.HasSuffix(path, ":batchEmbedContents"):
+		return config.RouteFamilyEmbeddings
+	case strings.HasPrefix(path, "/anthropic/") || strings.HasPrefix(path, "/v1/messages") || strings.HasPrefix(path, "/messages"):
+		return config.RouteFamilyClaude
+	case strings.HasPrefix(path, "/v1beta/") || (strings.HasPrefix(path, "/v1/models/") && strings.Contains(path, ":")):
+		return config.RouteFamilyGemini
+	case strings.HasPrefix(path, "/api/"):
+		return config.RouteFamilyOllama
+	}
+	return config.RouteFamilyOpenAI
+}
+
+// checkScopes enforces the route and model scopes of a managed key and
+// returns the scopes for the account checks that follow.
+func (r *Resolver) checkScopes(req *http.Request, callerKey string, requireModel bool) (config.APIKeyScopes, error) {
+	scopes := r.Store.APIKeyScopes(callerKey)
+	model := ""
+	if len(scopes.Models) > 0 {
+		model = requestModel(req)
+	}
+	return scopes, r.checkRouteAndModel(scopes, req.URL.Path, model, requireModel)
+}
+
+// checkRouteAndModel enforces scopes for a request to path naming model.
+// With requireModel, a model-scoped key is refused when the request names no
+// model, since the handler might still find one the probe could not.
+func (r *Resolver) checkRouteAndModel(scopes config.APIKeyScopes, path, model string, requireModel bool) error {
+	if family := RouteFamily(path); !scopes.AllowsRoute(family) {
+		return forbidden("API key is not allowed to use %s endpoints", family)
+	}
+	if len(scopes.Models) == 0 {
+		return nil
+	}
+	if model == "" {
+		if requireModel {
+			return forbidden("API key is limited to specific models and the request names none")
+		}
+		return nil
+	}
+	resolved, _ := config.ResolveModel(r.Store, model)
+	if !scopes.AllowsModel(model, resolved) {
+		return forbidden("API key is not allowed to use model %q", model)
+	}
+	return nil
+}
+
+// checkTargetAccount enforces pinning permission and the account group for
+// an X-Ds2-Target-Account request.
+func (r *Resolver) checkTargetAccount(scopes config.APIKeyScopes, target string) error {
+	if target == "" {
+		return nil
+	}
+	if !scopes.AllowsTargetAccount() {
+		return forbidden("API key may not pin X-Ds2-Target-Account")
+	}
+	if acc, ok := r.Store.FindAccount(target); ok && !scopes.AllowsAccount(acc) {
+		return forbidden("account %q is outside the API key's account group", target)
+	}
+	return nil
+}
+
+// groupExclude extends exclude with every account outside group, so pool
+// acquisition only hands out accounts of that group. exclude is not modified.
+func (r *Resolver) groupExclude(group string, exclude map[string]bool) map[string]bool {
+	out := make(map[string]bool, len(exclude))
+	for id, v := range exclude {
+		out[id] = v
+	}
+	if strings.TrimSpace(group) == "" {
+		return out
+	}
+	scopes := config.APIKeyScopes{AccountGroup: group}
+	for _, acc := range r.Store.Accounts() {
+		if !scopes.AllowsAccount(acc) {
+			out[acc.Identifier()] = true
+		}
+	}
+	return out
+}
+
+// requestModel finds the requested model: the Gemini path segment, or the
+// "model" field of a JSON body.
+func requestModel(req *http.Request) string {
+	if idx := strings.Index(req.URL.Path, "/models/"); idx >= 0 {
+		if name, _, ok := strings.Cut(req.URL.Path[idx+len("/models/"):], ":"); ok {
+			return name
+		}
+	}
+	return bodyModel(peekBody(req))
+}
+
+// bodyModel returns the "model" field of a JSON body.
+func bodyModel(raw []byte) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var probe struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(raw, &probe)
+	return strings.TrimSpace(probe.Model)
+}
+
+// peekBody reads a POST body and restores it for the handler. Handlers decode
+// bodies as JSON whatever their Content-Type, so only multipart uploads, which
+// never carry a model, are left unread.
+func peekBody(req *http.Request) []byte {
+	if req.Body == nil || req.Method != http.MethodPost {
+		return nil
+	}
+	if strings.HasPrefix(strings.ToLower(req.Header.Get("Content-Type")), "multipart/") {
+		return nil
+	}
+	raw, err := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	return raw
+}
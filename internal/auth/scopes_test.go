@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"ds2api/internal/account"
+	"ds2api/internal/config"
+)
+
+func newScopedResolver(t *testing.T) *Resolver {
+	t.Helper()
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"api_keys":[
+			{"id":"apikey:team","key":"team-key","scopes":{"models":["deepseek-chat","gpt-4o*"],"routes":["openai","gemini"],"account_group":"team-a","allow_target_account":false}},
+			{"id":"apikey:free","key":"free-key"}
+		],
+		"accounts":[
+			{"email":"shared@example.com","password":"pwd","token":"shared-token"},
+			{"email":"team@example.com","password":"pwd","token":"team-token","group":"team-a"}
+		]
+	}`)
+	store := config.LoadStore()
+	return NewResolver(store, account.NewPool(store), func(_ context.Context, _ config.Account) (string, error) {
+		return "fresh-token", nil
+	})
+}
+
+func scopedRequest(path, key, body string) *http.Request {
+	req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestDetermineEnforcesRouteAndModelScopes(t *testing.T) {
+	r := newScopedResolver(t)
+
+	req := scopedRequest("/v1/chat/completions", "team-key", `{"model":"gpt-4o-mini","messages":[]}`)
+	a, err := r.Determine(req)
+	if err != nil {
+		t.Fatalf("expected allowed model pattern, got %v", err)
+	}
+	if a.AccountID != "team@example.com" {
+		t.Fatalf("expected an account from the key's group, got %q", a.AccountID)
+	}
+	r.Release(a)
+	var body strings.Builder
+	if _, err := io.Copy(&body, req.Body); err != nil || !strings.Contains(body.String(), "gpt-4o-mini") {
+		t.Fatalf("expected the body to be restored for the handler, got %q", body.String())
+	}
+
+	cases := []*http.Request{
+		scopedRequest("/v1/chat/completions", "team-key", `{"model":"deepseek-reasoner"}`),
+		scopedRequest("/anthropic/v1/messages", "team-key", `{"model":"deepseek-chat"}`),
+		scopedRequest("/v1/embeddings", "team-key", `{"model":"deepseek-chat"}`),
+		scopedRequest("/v1beta/models/gemini-2.5-pro-search:generateContent", "team-key", `{}`),
+	}
+	for _, req := range cases {
+		if _, err := r.Determine(req); !errors.Is(err, ErrForbidden) {
+			t.Fatalf("%s: expected ErrForbidden, got %v", req.URL.Path, err)
+		}
+	}
+
+	// gemini-2.5-pro resolves to deepseek-chat, which the key allows.
+	if a, err := r.Determine(scopedRequest("/v1beta/models/gemini-2.5-pro:generateContent", "team-key", `{}`)); err != nil {
+		t.Fatalf("expected resolved model to match, got %v", err)
+	} else {
+		r.Release(a)
+	}
+
+	if a, err := r.Determine(scopedRequest("/anthropic/v1/messages", "free-key", `{"model":"deepseek-reasoner"}`)); err != nil {
+		t.Fatalf("expected an unscoped key to keep full access, got %v", err)
+	} else {
+		r.Release(a)
+	}
+}
+
+func TestDetermineModelScopeIgnoresContentType(t *testing.T) {
+	r := newScopedResolver(t)
+
+	req := scopedRequest("/v1/chat/completions", "team-key", `{"model":"deepseek-reasoner","messages":[]}`)
+	req.Header.Set("Content-Type", "text/plain")
+	if _, err := r.Determine(req); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected a text/plain body to be checked too, got %v", err)
+	}
+
+	for _, body := range []string{`{"messages":[]}`, `not json`} {
+		req := scopedRequest("/v1/chat/completions", "team-key", body)
+		if _, err := r.Determine(req); !errors.Is(err, ErrForbidden) {
+			t.Fatalf("%s: expected a request without a model to be refused, got %v", body, err)
+		}
+	}
+	req = scopedRequest("/v1/chat/completions", "team-key", `{"model":"deepseek-reasoner"}`)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	if _, err := r.Determine(req); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected an unread multipart body to be refused, got %v", err)
+	}
+
+	if a, err := r.Determine(scopedRequest("/v1/chat/completions", "free-key", `{"messages":[]}`)); err != nil {
+		t.Fatalf("expected a key without model scope to skip the check, got %v", err)
+	} else {
+		r.Release(a)
+	}
+}
+
+func TestDetermineEnforcesTargetAccountScopes(t *testing.T) {
+	r := newScopedResolver(t)
+
+	req := scopedRequest("/v1/chat/completions", "team-key", `{"model":"deepseek-chat"}`)
+	req.Header.Set("X-Ds2-Target-Account", "team@example.com")
+	if _, err := r.Determine(req); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected pinning to be refused, got %v", err)
+	}
+
+	r.Store.Update(func(c *config.Config) error {
+		allow := true
+		c.APIKeys[0].Scopes.AllowTargetAccount = &allow
+		return nil
+	})
+	req.Header.Set("X-Ds2-Target-Account", "shared@example.com")
+	if _, err := r.Determine(req); !errors.Is(err, ErrForbidden) || !strings.Contains(err.Error(), "account group") {
+		t.Fatalf("expected an out-of-group account to be refused, got %v", err)
+	}
+}
+
+func TestSwitchAccountStaysInGroup(t *testing.T) {
+	r := newScopedResolver(t)
+	a, err := r.Determine(scopedRequest("/v1/chat/completions", "team-key", `{"model":"deepseek-chat"}`))
+	if err != nil {
+		t.Fatalf("determine failed: %v", err)
+	}
+	if r.SwitchAccount(context.Background(), a) {
+		t.Fatalf("expected no other account in the group, got %q", a.AccountID)
+	}
+}
+
+func TestDetermineCallerEnforcesScopes(t *testing.T) {
+	r := newScopedResolver(t)
+	if _, err := r.DetermineCaller(scopedRequest("/v1beta/models/text-embedding-004:embedContent", "team-key", `{}`)); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected embeddings to be outside the key's routes, got %v", err)
+	}
+	if _, err := r.DetermineCaller(scopedRequest("/v1beta/models/gemini-2.5-pro:countTokens", "team-key", `{}`)); err != nil {
+		t.Fatalf("expected gemini countTokens to be allowed, got %v", err)
+	}
+}
+
+func TestAcquireBackgroundEnforcesScopes(t *testing.T) {
+	r := newScopedResolver(t)
+	job := func(path, body string) BackgroundRequest {
+		return BackgroundRequest{CallerID: "caller:team", KeyID: "apikey:team", Path: path, Body: []byte(body)}
+	}
+
+	a, err := r.AcquireBackground(context.Background(), job("/v1/chat/completions", `{"model":"gpt-4o"}`))
+	if err != nil {
+		t.Fatalf("expected an allowed batch line, got %v", err)
+	}
+	if a.AccountID != "team@example.com" || a.AccountGroup != "team-a" {
+		t.Fatalf("expected an account from the key's group, got %q in %q", a.AccountID, a.AccountGroup)
+	}
+	r.Release(a)
+
+	refused := []BackgroundRequest{
+		job("/v1/chat/completions", `{"model":"deepseek-reasoner"}`),
+		job("/v1/chat/completions", `{"messages":[]}`),
+		job("/v1/embeddings", `{"model":"deepseek-chat","input":"x"}`),
+		job("/v1/messages", `{"model":"deepseek-chat"}`),
+	}
+	for _, job := range refused {
+		if _, err := r.AcquireBackground(context.Background(), job); !errors.Is(err, ErrForbidden) {
+			t.Fatalf("%s %s: expected ErrForbidden, got %v", job.Path, job.Body, err)
+		}
+	}
+	if err := r.CheckBackground(BackgroundRequest{KeyID: "apikey:team", Path: "/v1/embeddings"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected the batch endpoint to be checked on creation, got %v", err)
+	}
+	if err := r.CheckBackground(BackgroundRequest{KeyID: "apikey:team", Path: "/v1/chat/completions"}); err != nil {
+		t.Fatalf("expected a route-only check to pass without a model, got %v", err)
+	}
+}
+
+func TestRouteFamily(t *testing.T) {
+	cases := map[string]string{
+		"/v1/chat/completions":                          config.RouteFamilyOpenAI,
+		"/v1/responses":                                 config.RouteFamilyOpenAI,
+		"/v1/embeddings":                                config.RouteFamilyEmbeddings,
+		"/anthropic/v1/messages":                        config.RouteFamilyClaude,
+		"/v1/messages/count_tokens":                     config.RouteFamilyClaude,
+		"/v1beta/models/gemini-2.5-pro:generateContent": config.RouteFamilyGemini,
+		"/v1/models/gemini-2.5-pro:countTokens":         config.RouteFamilyGemini,
+		"/v1/models/text-embedding-004:embedContent":    config.RouteFamilyEmbeddings,
+		"/api/chat":                                     config.RouteFamilyOllama,
+	}
+	for path, want := range cases {
+		if got := RouteFamily(path); got != want {
+			t.Fatalf("%s: expected %s, got %s", path, want, got)
+		}
+	}
+}
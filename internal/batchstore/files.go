@@ -0,0 +1,102 @@
+package batchstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+)
+
+// ValidID reports whether id is prefix followed by letters and digits only,
+// so it is safe to use in a file name.
+func ValidID(id, prefix string) bool {
+	if !strings.HasPrefix(id, prefix) || len(id) == len(prefix) {
+		return false
+	}
+	for _, r := range id[len(prefix):] {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func ReadJSON(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// WriteJSONAtomic replaces path with v, so readers never see a partial file.
+func WriteJSONAtomic(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// AppendJSONLine appends v to the JSONL file at path.
+func AppendJSONLine(path string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ReadJSONLines returns the lines of the JSONL file at path, none if it does
+// not exist. A line torn by a crash is dropped and the file rewritten so
+// appends stay valid.
+func ReadJSONLines(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var kept [][]byte
+	torn := false
+	reader := bufio.NewReader(f)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			if !bytes.HasSuffix(line, []byte("\n")) || !json.Valid(trimmed) {
+				torn = true
+			} else {
+				kept = append(kept, trimmed)
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	_ = f.Close()
+	if torn {
+		var buf []byte
+		for _, line := range kept {
+			buf = append(append(buf, line...), '\n')
+		}
+		if err := os.WriteFile(path, buf, 0o644); err != nil {
+			return nil, err
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,34 @@
+package batchstore
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// Response buffers what a regular handler writes for one batch request.
+type Response struct {
+	header http.Header
+	Status int
+	Body   bytes.Buffer
+}
+
+func NewResponse() *Response {
+	return &Response{header: http.Header{}}
+}
+
+func (w *Response) Header() http.Header {
+	return w.header
+}
+
+func (w *Response) WriteHeader(status int) {
+	if w.Status == 0 {
+		w.Status = status
+	}
+}
+
+func (w *Response) Write(b []byte) (int, error) {
+	if w.Status == 0 {
+		w.Status = http.StatusOK
+	}
+	return w.Body.Write(b)
+}
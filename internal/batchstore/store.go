@@ -0,0 +1,209 @@
+// Package batchstore keeps background batch jobs as JSON files and runs them
+// one at a time. The OpenAI batches and Claude message batches adapters
+// build their stores on it.
+package batchstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+var ErrNotFound = errors.New("batch not found")
+
+// Batch is implemented by the persisted record of a batch.
+type Batch interface {
+	BatchID() string
+	BatchOwner() string
+	BatchCreatedAt() int64
+	// Runnable reports whether the runner still has work to do on the batch.
+	Runnable() bool
+}
+
+// Store persists batches as <dir>/<id>.json, where every id starts with the
+// store's prefix. Side files of a batch live next to it under Path.
+type Store[B Batch] struct {
+	mu      sync.Mutex
+	dir     string
+	prefix  string
+	wake    chan struct{}
+	running map[string]context.CancelFunc
+}
+
+func New[B Batch](dir, prefix string) *Store[B] {
+	return &Store[B]{
+		dir:     dir,
+		prefix:  prefix,
+		wake:    make(chan struct{}, 1),
+		running: map[string]context.CancelFunc{},
+	}
+}
+
+// NewID returns a fresh batch id.
+func (s *Store[B]) NewID() string {
+	return s.prefix + strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+// Path returns the path of the file of batch id with suffix, such as
+// ".json" for the record itself.
+func (s *Store[B]) Path(id, suffix string) string {
+	return filepath.Join(s.dir, id+suffix)
+}
+
+// Create persists a new batch. Side files may be written before it, once
+// the directory exists: MkdirAll makes sure it does.
+func (s *Store[B]) Create(b B) error {
+	if err := s.MkdirAll(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return WriteJSONAtomic(s.Path(b.BatchID(), ".json"), b)
+}
+
+func (s *Store[B]) MkdirAll() error {
+	return os.MkdirAll(s.dir, 0o755)
+}
+
+// Get returns batch id if owner owns it.
+func (s *Store[B]) Get(owner, id string) (B, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.loadLocked(id)
+	if err != nil || b.BatchOwner() != owner {
+		var zero B
+		return zero, ErrNotFound
+	}
+	return b, nil
+}
+
+// Load returns batch id whoever owns it.
+func (s *Store[B]) Load(id string) (B, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadLocked(id)
+}
+
+func (s *Store[B]) loadLocked(id string) (B, error) {
+	var b B
+	if !ValidID(id, s.prefix) {
+		return b, ErrNotFound
+	}
+	if err := ReadJSON(s.Path(id, ".json"), &b); err != nil {
+		return b, ErrNotFound
+	}
+	return b, nil
+}
+
+// Update applies fn to the latest persisted state, so runner progress and a
+// concurrent cancel never overwrite each other.
+func (s *Store[B]) Update(id string, fn func(b *B)) (B, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.loadLocked(id)
+	if err != nil {
+		return b, err
+	}
+	fn(&b)
+	if err := WriteJSONAtomic(s.Path(id, ".json"), b); err != nil {
+		var zero B
+		return zero, err
+	}
+	return b, nil
+}
+
+// List returns batches for owner, or every batch when owner is empty, oldest
+// first.
+func (s *Store[B]) List(owner string) []B {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	matches, _ := filepath.Glob(filepath.Join(s.dir, s.prefix+"*.json"))
+	out := make([]B, 0, len(matches))
+	for _, path := range matches {
+		var b B
+		if err := ReadJSON(path, &b); err != nil {
+			continue
+		}
+		if owner != "" && b.BatchOwner() != owner {
+			continue
+		}
+		out = append(out, b)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].BatchCreatedAt() != out[j].BatchCreatedAt() {
+			return out[i].BatchCreatedAt() < out[j].BatchCreatedAt()
+		}
+		return out[i].BatchID() < out[j].BatchID()
+	})
+	return out
+}
+
+// NextRunnable returns the oldest batch that still has work to do.
+func (s *Store[B]) NextRunnable() (B, bool) {
+	for _, b := range s.List("") {
+		if b.Runnable() {
+			return b, true
+		}
+	}
+	var zero B
+	return zero, false
+}
+
+// Run processes runnable batches one at a time until ctx ends, waiting for
+// Notify once none is left. Batches left running by a previous process are
+// picked up first, as they are the oldest.
+func (s *Store[B]) Run(ctx context.Context, run func(ctx context.Context, b B)) {
+	for {
+		for ctx.Err() == nil {
+			b, ok := s.NextRunnable()
+			if !ok {
+				break
+			}
+			run(ctx, b)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		}
+	}
+}
+
+// Notify wakes Run after a batch was created or changed.
+func (s *Store[B]) Notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start marks batch id as running and returns a context that Cancel(id)
+// ends. The returned func must be called once the run is over.
+func (s *Store[B]) Start(ctx context.Context, id string) (context.Context, func()) {
+	runCtx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.running[id] = cancel
+	s.mu.Unlock()
+	return runCtx, func() {
+		s.mu.Lock()
+		delete(s.running, id)
+		s.mu.Unlock()
+		cancel()
+	}
+}
+
+// Cancel abandons the request in flight of batch id, if it is running.
+func (s *Store[B]) Cancel(id string) {
+	s.mu.Lock()
+	cancel := s.running[id]
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
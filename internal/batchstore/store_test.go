@@ -0,0 +1,98 @@
+package batchstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testBatch struct {
+	ID        string `json:"id"`
+	Owner     string `json:"owner"`
+	CreatedAt int64  `json:"created_at"`
+	Done      bool   `json:"done"`
+}
+
+func (b testBatch) BatchID() string {
+	return b.ID
+}
+
+func (b testBatch) BatchOwner() string {
+	return b.Owner
+}
+
+func (b testBatch) BatchCreatedAt() int64 {
+	return b.CreatedAt
+}
+
+func (b testBatch) Runnable() bool {
+	return !b.Done
+}
+
+func TestStoreKeepsBatchesPerOwnerOldestFirst(t *testing.T) {
+	s := New[testBatch](t.TempDir(), "tb_")
+	first := testBatch{ID: s.NewID(), Owner: "a", CreatedAt: 1}
+	second := testBatch{ID: s.NewID(), Owner: "b", CreatedAt: 2}
+	for _, b := range []testBatch{second, first} {
+		if err := s.Create(b); err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+	}
+
+	if _, err := s.Get("b", first.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected another owner's batch to be hidden, got %v", err)
+	}
+	if _, err := s.Load("tb_../x"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected an unsafe id to be refused, got %v", err)
+	}
+	if all := s.List(""); len(all) != 2 || all[0].ID != first.ID {
+		t.Fatalf("expected both batches oldest first, got %+v", all)
+	}
+	if owned := s.List("b"); len(owned) != 1 || owned[0].ID != second.ID {
+		t.Fatalf("expected only b's batch, got %+v", owned)
+	}
+
+	if _, err := s.Update(first.ID, func(b *testBatch) { b.Done = true }); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if next, ok := s.NextRunnable(); !ok || next.ID != second.ID {
+		t.Fatalf("expected the unfinished batch next, got %+v %v", next, ok)
+	}
+}
+
+func TestStoreCancelEndsRunningBatch(t *testing.T) {
+	s := New[testBatch](t.TempDir(), "tb_")
+	runCtx, finish := s.Start(context.Background(), "tb_1")
+	s.Cancel("tb_1")
+	if runCtx.Err() == nil {
+		t.Fatal("expected Cancel to end the run context")
+	}
+	finish()
+	s.Cancel("tb_1")
+}
+
+func TestReadJSONLinesDropsTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	if lines, err := ReadJSONLines(path); err != nil || lines != nil {
+		t.Fatalf("expected no lines for a missing file, got %v %v", lines, err)
+	}
+	for _, v := range []map[string]any{{"custom_id": "a"}, {"custom_id": "b"}} {
+		if err := AppendJSONLine(path, v); err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+	}
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	_, _ = f.WriteString(`{"custom_id":"c"`)
+	_ = f.Close()
+
+	lines, err := ReadJSONLines(path)
+	if err != nil || len(lines) != 2 {
+		t.Fatalf("expected the two complete lines, got %q %v", lines, err)
+	}
+	raw, _ := os.ReadFile(path)
+	if string(raw) != "{\"custom_id\":\"a\"}\n{\"custom_id\":\"b\"}\n" {
+		t.Fatalf("expected the torn line to be removed from the file, got %q", raw)
+	}
+}
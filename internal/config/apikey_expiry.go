@@ -2,16 +2,42 @@ package config
 
 import "time"
 
+// APIKeyExpiryFrom returns when a key created at createdAt expires under the
+// default APIKeyTTL.
 func APIKeyExpiryFrom(createdAt time.Time) time.Time {
-	return time.Time{}
+	return apiKeyExpiryAfter(createdAt, APIKeyTTL)
 }
 
+// ResolveAPIKeyExpiry returns the effective expiry of a key: its own
+// expires_at, or CreatedAt plus the default TTL for entries stored without
+// one. The zero time means the key never expires.
 func ResolveAPIKeyExpiry(metadata APIKeyMetadata) time.Time {
-	_ = metadata
-	return time.Time{}
+	return resolveAPIKeyExpiry(metadata, APIKeyTTL)
 }
 
+// IsAPIKeyActiveAt reports whether the key may still be used at now.
 func IsAPIKeyActiveAt(metadata APIKeyMetadata, now time.Time) bool {
-	_ = now
-	return metadata.Key != ""
+	return apiKeyActiveAt(metadata, now, APIKeyTTL)
+}
+
+func apiKeyExpiryAfter(createdAt time.Time, ttl time.Duration) time.Time {
+	if createdAt.IsZero() || ttl <= 0 {
+		return time.Time{}
+	}
+	return createdAt.Add(ttl)
+}
+
+func resolveAPIKeyExpiry(metadata APIKeyMetadata, ttl time.Duration) time.Time {
+	if !metadata.ExpiresAt.IsZero() {
+		return metadata.ExpiresAt
+	}
+	return apiKeyExpiryAfter(metadata.CreatedAt, ttl)
+}
+
+func apiKeyActiveAt(metadata APIKeyMetadata, now time.Time, ttl time.Duration) bool {
+	if metadata.Key == "" && metadata.KeyHash == "" {
+		return false
+	}
+	expiresAt := resolveAPIKeyExpiry(metadata, ttl)
+	return expiresAt.IsZero() || now.Before(expiresAt)
 }
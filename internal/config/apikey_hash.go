@@ -0,0 +1,133 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+// API keys are stored as HMAC-SHA256 digests under the config's
+// api_key_salt. The salt is shared by all keys of a config, so validating a
+// presented key costs one HMAC and one map lookup.
+
+const (
+	apiKeyPrefixLen    = 8
+	generatedKeyPrefix = "sk-"
+)
+
+func newAPIKeySalt() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// HashAPIKey returns the stored form of key under salt.
+func HashAPIKey(salt, key string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// APIKeyPrefix returns the part of key kept in the clear to tell keys
+// apart: up to 8 characters, and never more than half of the key.
+func APIKeyPrefix(key string) string {
+	runes := []rune(key)
+	return string(runes[:min(apiKeyPrefixLen, len(runes)/2)])
+}
+
+// GenerateAPIKey returns a new random key.
+func GenerateAPIKey() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return generatedKeyPrefix + hex.EncodeToString(b)
+}
+
+// apiKeyIDFromHash derives the ID of a hashed key. IDs used to be an
+// unsalted digest of the key (generateAPIKeyID), which must not outlive the
+// plaintext: migration re-keys the usage ledger instead of keeping them.
+func apiKeyIDFromHash(hash string) string {
+	return "apikey:" + hash[:min(32, len(hash))]
+}
+
+// Identifier returns the ID of the key, deriving one from its hash for
+// entries stored without.
+func (m APIKeyMetadata) Identifier() string {
+	if m.ID != "" {
+		return m.ID
+	}
+	return apiKeyIDFromHash(m.KeyHash)
+}
+
+// hashAPIKeyEntry moves the plaintext of metadata into KeyHash and
+// KeyPrefix. Generated IDs are re-derived from the hash; custom IDs stay.
+func hashAPIKeyEntry(salt string, metadata APIKeyMetadata) APIKeyMetadata {
+	key := strings.TrimSpace(metadata.Key)
+	metadata.KeyHash = HashAPIKey(salt, key)
+	metadata.KeyPrefix = APIKeyPrefix(key)
+	if metadata.ID == "" || metadata.ID == generateAPIKeyID(key) {
+		metadata.ID = apiKeyIDFromHash(metadata.KeyHash)
+	}
+	metadata.Key = ""
+	return metadata
+}
+
+// hasPlaintextAPIKeys reports whether cfg still holds any key in the clear.
+func hasPlaintextAPIKeys(cfg Config) bool {
+	if len(cfg.Keys) > 0 {
+		return true
+	}
+	for _, metadata := range cfg.APIKeys {
+		if metadata.Key != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// FindAPIKeyIndex returns the index in c.APIKeys of the key ref names: the
+// key itself, its ID, or a prefix shared by no other key. Entries must be
+// hashed already, as they are in any store snapshot.
+func FindAPIKeyIndex(c *Config, ref string) (int, bool) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return -1, false
+	}
+	hash := HashAPIKey(c.APIKeySalt, ref)
+	for i, metadata := range c.APIKeys {
+		if metadata.KeyHash == hash || metadata.ID == ref {
+			return i, true
+		}
+	}
+	found := -1
+	for i, metadata := range c.APIKeys {
+		if metadata.KeyPrefix != "" && metadata.KeyPrefix == ref {
+			if found >= 0 {
+				return -1, false
+			}
+			found = i
+		}
+	}
+	return found, found >= 0
+}
+
+// HasAPIKey reports whether key is configured in c, whether still in the
+// clear or already hashed. Unlike FindAPIKeyIndex it matches the key only.
+func (c *Config) HasAPIKey(key string) bool {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return false
+	}
+	if slices.Contains(c.Keys, key) {
+		return true
+	}
+	hash := HashAPIKey(c.APIKeySalt, key)
+	for _, metadata := range c.APIKeys {
+		if metadata.Key == key || (metadata.KeyHash != "" && metadata.KeyHash == hash) {
+			return true
+		}
+	}
+	return false
+}
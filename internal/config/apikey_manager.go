@@ -4,11 +4,14 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"slices"
+	"strings"
 	"time"
 
 	apierrors "ds2api/internal/errors"
 )
 
+// APIKeyManager edits the configured keys. Methods that look a key up accept
+// the key itself, its ID, or its prefix when no other key shares it.
 type APIKeyManager struct {
 	store *Store
 }
@@ -18,47 +21,137 @@ func NewAPIKeyManager(store *Store) *APIKeyManager {
 }
 
 func (m *APIKeyManager) AddAPIKey(key string) error {
+	_, err := m.AddAPIKeyWithExpiry(key, time.Time{})
+	return err
+}
+
+// AddAPIKeyWithExpiry stores the hash of key with an explicit expiry; the
+// zero time uses the configured TTL. Adding a configured key again resets
+// its metadata but keeps its ID.
+func (m *APIKeyManager) AddAPIKeyWithExpiry(key string, expiresAt time.Time) (APIKeyMetadata, error) {
+	key = strings.TrimSpace(key)
 	if key == "" {
-		return ErrInvalidAPIKey
+		return APIKeyMetadata{}, ErrInvalidAPIKey
 	}
 
-	metadata := APIKeyMetadata{
-		ID:        generateAPIKeyID(key),
-		Key:       key,
-		CreatedAt: time.Now(),
+	now := time.Now()
+	if expiresAt.IsZero() {
+		expiresAt = apiKeyExpiryAfter(now, m.store.APIKeyTTL())
 	}
-
-	return m.store.Update(func(c *Config) error {
+	var added APIKeyMetadata
+	err := m.store.Update(func(c *Config) error {
+		if c.APIKeySalt == "" {
+			c.APIKeySalt = newAPIKeySalt()
+		}
+		added = hashAPIKeyEntry(c.APIKeySalt, APIKeyMetadata{
+			Key:       key,
+			CreatedAt: now,
+			ExpiresAt: expiresAt,
+		})
 		for i, existing := range c.APIKeys {
-			if existing.Key == key {
-				c.APIKeys[i] = metadata
+			if existing.KeyHash == added.KeyHash {
+				added.ID = existing.Identifier()
+				c.APIKeys[i] = added
 				return nil
 			}
 		}
-		c.APIKeys = append(c.APIKeys, metadata)
+		c.APIKeys = append(c.APIKeys, added)
 		return nil
 	})
+	return added, err
 }
 
-func (m *APIKeyManager) RemoveAPIKey(key string) error {
+func (m *APIKeyManager) RemoveAPIKey(ref string) error {
 	return m.store.Update(func(c *Config) error {
-		for i, metadata := range c.APIKeys {
-			if metadata.Key == key {
-				c.APIKeys = append(c.APIKeys[:i], c.APIKeys[i+1:]...)
-				return nil
-			}
+		i, ok := FindAPIKeyIndex(c, ref)
+		if !ok {
+			return ErrAPIKeyNotFound
+		}
+		c.APIKeys = append(c.APIKeys[:i], c.APIKeys[i+1:]...)
+		return nil
+	})
+}
+
+// SetAPIKeyScopes replaces the scopes of key; zero scopes restore full
+// access.
+func (m *APIKeyManager) SetAPIKeyScopes(key string, scopes APIKeyScopes) (APIKeyMetadata, error) {
+	if err := scopes.Validate(); err != nil {
+		return APIKeyMetadata{}, err
+	}
+	var updated APIKeyMetadata
+	err := m.store.Update(func(c *Config) error {
+		i, ok := FindAPIKeyIndex(c, key)
+		if !ok {
+			return ErrAPIKeyNotFound
+		}
+		c.APIKeys[i].Scopes = scopes
+		updated = c.APIKeys[i]
+		return nil
+	})
+	return updated, err
+}
+
+// SetAPIKeyRateLimits replaces the rate limits of key; zero limits inherit
+// the global defaults.
+func (m *APIKeyManager) SetAPIKeyRateLimits(key string, limits RateLimitConfig) (APIKeyMetadata, error) {
+	var updated APIKeyMetadata
+	err := m.store.Update(func(c *Config) error {
+		i, ok := FindAPIKeyIndex(c, key)
+		if !ok {
+			return ErrAPIKeyNotFound
+		}
+		c.APIKeys[i].RateLimits = limits
+		updated = c.APIKeys[i]
+		return nil
+	})
+	return updated, err
+}
+
+// SetAPIKeyQuota replaces the monthly token quota of key; 0 inherits
+// usage.monthly_token_quota and a negative value lifts it.
+func (m *APIKeyManager) SetAPIKeyQuota(key string, quota int64) (APIKeyMetadata, error) {
+	var updated APIKeyMetadata
+	err := m.store.Update(func(c *Config) error {
+		i, ok := FindAPIKeyIndex(c, key)
+		if !ok {
+			return ErrAPIKeyNotFound
 		}
-		return ErrAPIKeyNotFound
+		c.APIKeys[i].MonthlyTokenQuota = quota
+		updated = c.APIKeys[i]
+		return nil
+	})
+	return updated, err
+}
+
+// RenewAPIKey moves the expiry of key to expiresAt, or to now plus the
+// configured TTL when expiresAt is zero, and returns the updated metadata.
+// Expired keys can be renewed; CreatedAt is kept.
+func (m *APIKeyManager) RenewAPIKey(key string, expiresAt time.Time) (APIKeyMetadata, error) {
+	if expiresAt.IsZero() {
+		expiresAt = apiKeyExpiryAfter(time.Now(), m.store.APIKeyTTL())
+	}
+	var renewed APIKeyMetadata
+	err := m.store.Update(func(c *Config) error {
+		i, ok := FindAPIKeyIndex(c, key)
+		if !ok {
+			return ErrAPIKeyNotFound
+		}
+		c.APIKeys[i].ExpiresAt = expiresAt
+		renewed = c.APIKeys[i]
+		return nil
 	})
+	return renewed, err
 }
 
 type KeyFilterFunc func(APIKeyMetadata) bool
 
 func (m *APIKeyManager) filterKeys(filter KeyFilterFunc) []APIKeyMetadata {
 	cfg := m.store.Snapshot()
+	ttl := m.store.APIKeyTTL()
 	result := make([]APIKeyMetadata, 0)
 	for _, metadata := range cfg.APIKeys {
 		if filter(metadata) {
+			metadata.ExpiresAt = resolveAPIKeyExpiry(metadata, ttl)
 			result = append(result, metadata)
 		}
 	}
@@ -66,50 +159,60 @@ func (m *APIKeyManager) filterKeys(filter KeyFilterFunc) []APIKeyMetadata {
 }
 
 func (m *APIKeyManager) IsAPIKeyValid(key string) bool {
-	cfg := m.store.Snapshot()
-	for _, metadata := range cfg.APIKeys {
-		if metadata.Key == key {
-			return IsAPIKeyActiveAt(metadata, time.Time{})
-		}
-	}
-
-	for _, k := range cfg.Keys {
-		if k == key {
-			return true
-		}
-	}
-
-	return false
+	return m.store.HasValidAPIKey(key)
 }
 
-func (m *APIKeyManager) GetAPIKeyMetadata(key string) (APIKeyMetadata, bool) {
+func (m *APIKeyManager) GetAPIKeyMetadata(ref string) (APIKeyMetadata, bool) {
 	cfg := m.store.Snapshot()
-	for _, metadata := range cfg.APIKeys {
-		if metadata.Key == key {
-			return metadata, true
-		}
+	i, ok := FindAPIKeyIndex(&cfg, ref)
+	if !ok {
+		return APIKeyMetadata{}, false
 	}
-
-	return APIKeyMetadata{}, false
+	return cfg.APIKeys[i], true
 }
 
+// GetExpiringKeys returns active keys that expire within daysBefore days.
+// ExpiresAt is filled in for keys stored without one.
 func (m *APIKeyManager) GetExpiringKeys(daysBefore int) []APIKeyMetadata {
-	_ = daysBefore
+	now := time.Now()
+	ttl := m.store.APIKeyTTL()
+	deadline := now.Add(time.Duration(daysBefore) * 24 * time.Hour)
 	return m.filterKeys(func(k APIKeyMetadata) bool {
-		_ = k
-		return false
+		expiresAt := resolveAPIKeyExpiry(k, ttl)
+		return !expiresAt.IsZero() && now.Before(expiresAt) && !expiresAt.After(deadline)
 	})
 }
 
+// GetExpiredKeys returns keys whose expiry has passed.
 func (m *APIKeyManager) GetExpiredKeys() []APIKeyMetadata {
+	now := time.Now()
+	ttl := m.store.APIKeyTTL()
 	return m.filterKeys(func(k APIKeyMetadata) bool {
-		_ = k
-		return false
+		return !apiKeyActiveAt(k, now, ttl)
 	})
 }
 
+// CleanExpiredKeys removes expired keys and returns how many were dropped.
 func (m *APIKeyManager) CleanExpiredKeys() (int, error) {
-	return 0, nil
+	now := time.Now()
+	ttl := m.store.APIKeyTTL()
+	removed := 0
+	err := m.store.Update(func(c *Config) error {
+		kept := make([]APIKeyMetadata, 0, len(c.APIKeys))
+		for _, metadata := range c.APIKeys {
+			if apiKeyActiveAt(metadata, now, ttl) {
+				kept = append(kept, metadata)
+				continue
+			}
+			removed++
+		}
+		c.APIKeys = kept
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
 }
 
 func (m *APIKeyManager) GetAllAPIKeysMetadata() []APIKeyMetadata {
@@ -117,36 +220,14 @@ func (m *APIKeyManager) GetAllAPIKeysMetadata() []APIKeyMetadata {
 	return slices.Clone(cfg.APIKeys)
 }
 
-func (m *APIKeyManager) GetValidKeys() []string {
-	cfg := m.store.Snapshot()
-	validKeys := make([]string, 0, len(cfg.APIKeys)+len(cfg.Keys))
-	seen := make(map[string]struct{}, len(cfg.APIKeys)+len(cfg.Keys))
-
-	for _, metadata := range cfg.APIKeys {
-		if IsAPIKeyActiveAt(metadata, time.Time{}) {
-			if _, exists := seen[metadata.Key]; !exists {
-				seen[metadata.Key] = struct{}{}
-				validKeys = append(validKeys, metadata.Key)
-			}
-		}
-	}
-
-	for _, k := range cfg.Keys {
-		if _, exists := seen[k]; !exists {
-			seen[k] = struct{}{}
-			validKeys = append(validKeys, k)
-		}
-	}
-
-	return validKeys
-}
-
 func (m *APIKeyManager) GetValidAPIKeysMetadata() []APIKeyMetadata {
 	cfg := m.store.Snapshot()
 	validMetadata := make([]APIKeyMetadata, 0, len(cfg.APIKeys))
 
+	now := time.Now()
+	ttl := m.store.APIKeyTTL()
 	for _, metadata := range cfg.APIKeys {
-		if IsAPIKeyActiveAt(metadata, time.Time{}) {
+		if apiKeyActiveAt(metadata, now, ttl) {
 			validMetadata = append(validMetadata, metadata)
 		}
 	}
@@ -164,6 +245,8 @@ var (
 	ErrAPIKeyNotFound = apierrors.NewAppError("API_KEY_NOT_FOUND", "API key not found", nil)
 	ErrAPIKeyExpired  = apierrors.NewAppError("API_KEY_EXPIRED", "API key has expired", nil)
 	ErrAPIKeyExpiring = apierrors.NewAppError("API_KEY_EXPIRING", "API key is expiring soon", nil)
+
+	ErrInvalidAPIKeyScopes = apierrors.NewAppError("INVALID_REQUEST", "invalid API key scopes: routes must be openai, claude, gemini, ollama or embeddings and models must be valid patterns", nil)
 )
 
 func maskAPIKey(key string) string {
@@ -1,13 +1,22 @@
 package config
 
 import (
+	"path/filepath"
 	"slices"
 	"testing"
 	"time"
 )
 
+// newTestStore returns an empty store saving to a temporary config file, so
+// tests never write config.json into the package directory.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	t.Setenv("DS2API_CONFIG_PATH", filepath.Join(t.TempDir(), "config.json"))
+	return NewStore(nil, "")
+}
+
 func TestAPIKeyManager_AddAPIKey(t *testing.T) {
-	store := NewStore(nil, "")
+	store := newTestStore(t)
 	store.Update(func(c *Config) error {
 		c.Keys = []string{}
 		c.APIKeys = []APIKeyMetadata{}
@@ -26,22 +35,25 @@ func TestAPIKeyManager_AddAPIKey(t *testing.T) {
 	if !found {
 		t.Fatal("expected key metadata to be found")
 	}
-	if metadata.Key != testKey {
-		t.Fatalf("expected key %q, got %q", testKey, metadata.Key)
+	if metadata.Key != "" || metadata.KeyHash == "" || metadata.KeyPrefix != "sk-test-" {
+		t.Fatalf("expected the key to be stored as a hash with its prefix, got %#v", metadata)
 	}
-	if metadata.ID == "" {
-		t.Fatal("expected metadata ID to be set")
+	if metadata.ID == "" || metadata.ID == generateAPIKeyID(testKey) {
+		t.Fatalf("expected a salted metadata ID, got %q", metadata.ID)
+	}
+	if byID, ok := manager.GetAPIKeyMetadata(metadata.ID); !ok || byID.KeyHash != metadata.KeyHash {
+		t.Fatal("expected key metadata to be found by ID")
 	}
 	if metadata.CreatedAt.IsZero() {
 		t.Fatal("expected CreatedAt to be set")
 	}
-	if !metadata.ExpiresAt.IsZero() {
-		t.Fatal("expected ExpiresAt to be empty")
+	if !metadata.ExpiresAt.Equal(metadata.CreatedAt.Add(APIKeyTTL)) {
+		t.Fatalf("expected ExpiresAt to default to CreatedAt+%s, got %v", APIKeyTTL, metadata.ExpiresAt)
 	}
 }
 
 func TestAPIKeyManager_AddDuplicateKey(t *testing.T) {
-	store := NewStore(nil, "")
+	store := newTestStore(t)
 	store.Update(func(c *Config) error {
 		c.Keys = []string{}
 		c.APIKeys = []APIKeyMetadata{}
@@ -69,7 +81,7 @@ func TestAPIKeyManager_AddDuplicateKey(t *testing.T) {
 }
 
 func TestAPIKeyManager_RemoveAPIKey(t *testing.T) {
-	store := NewStore(nil, "")
+	store := newTestStore(t)
 	store.Update(func(c *Config) error {
 		c.Keys = []string{}
 		c.APIKeys = []APIKeyMetadata{}
@@ -93,7 +105,7 @@ func TestAPIKeyManager_RemoveAPIKey(t *testing.T) {
 }
 
 func TestAPIKeyManager_RemoveNonExistentKey(t *testing.T) {
-	store := NewStore(nil, "")
+	store := newTestStore(t)
 	manager := NewAPIKeyManager(store)
 
 	err := manager.RemoveAPIKey("sk-non-existent")
@@ -106,7 +118,7 @@ func TestAPIKeyManager_RemoveNonExistentKey(t *testing.T) {
 }
 
 func TestAPIKeyManager_IsAPIKeyValid(t *testing.T) {
-	store := NewStore(nil, "")
+	store := newTestStore(t)
 	store.Update(func(c *Config) error {
 		c.Keys = []string{}
 		c.APIKeys = []APIKeyMetadata{}
@@ -127,7 +139,7 @@ func TestAPIKeyManager_IsAPIKeyValid(t *testing.T) {
 }
 
 func TestAPIKeyManager_GetExpiringKeys(t *testing.T) {
-	store := NewStore(nil, "")
+	store := newTestStore(t)
 	store.Update(func(c *Config) error {
 		c.Keys = []string{}
 		c.APIKeys = []APIKeyMetadata{}
@@ -149,13 +161,16 @@ func TestAPIKeyManager_GetExpiringKeys(t *testing.T) {
 	})
 
 	expiring := manager.GetExpiringKeys(7)
-	if len(expiring) != 0 {
-		t.Fatalf("expected 0 expiring keys, got %d", len(expiring))
+	if len(expiring) != 2 || !expiring[0].ExpiresAt.Equal(now.Add(5*24*time.Hour)) || !expiring[1].ExpiresAt.Equal(now.Add(3*24*time.Hour)) {
+		t.Fatalf("expected the two keys expiring within 7 days, got %#v", expiring)
+	}
+	if len(manager.GetExpiringKeys(4)) != 1 {
+		t.Fatal("expected one key expiring within 4 days")
 	}
 }
 
 func TestAPIKeyManager_GetExpiredKeys(t *testing.T) {
-	store := NewStore(nil, "")
+	store := newTestStore(t)
 	store.Update(func(c *Config) error {
 		c.Keys = []string{}
 		c.APIKeys = []APIKeyMetadata{}
@@ -176,13 +191,16 @@ func TestAPIKeyManager_GetExpiredKeys(t *testing.T) {
 	})
 
 	expired := manager.GetExpiredKeys()
-	if len(expired) != 0 {
-		t.Fatalf("expected 0 expired keys, got %d", len(expired))
+	if len(expired) != 2 {
+		t.Fatalf("expected 2 expired keys, got %d", len(expired))
+	}
+	if manager.IsAPIKeyValid("sk-expired-1") || !store.IsAPIKeyExpired("sk-expired-1") || store.HasValidAPIKey("sk-expired-1") {
+		t.Fatal("expected sk-expired-1 to be rejected as expired")
 	}
 }
 
 func TestAPIKeyManager_CleanExpiredKeys(t *testing.T) {
-	store := NewStore(nil, "")
+	store := newTestStore(t)
 	store.Update(func(c *Config) error {
 		c.Keys = []string{}
 		c.APIKeys = []APIKeyMetadata{}
@@ -206,24 +224,22 @@ func TestAPIKeyManager_CleanExpiredKeys(t *testing.T) {
 	if err != nil {
 		t.Fatalf("CleanExpiredKeys returned error: %v", err)
 	}
-	if removed != 0 {
-		t.Fatalf("expected 0 removed keys, got %d", removed)
+	if removed != 2 {
+		t.Fatalf("expected 2 removed keys, got %d", removed)
+	}
+	if cfg := store.Snapshot(); len(cfg.APIKeys) != 1 || !store.HasValidAPIKey("sk-valid") {
+		t.Fatalf("expected only sk-valid to remain, got %#v", cfg.APIKeys)
 	}
 }
 
-func TestAPIKeyManager_GetValidKeys(t *testing.T) {
-	store := NewStore(nil, "")
-	store.Update(func(c *Config) error {
-		c.Keys = []string{"sk-legacy-key"}
-		c.APIKeys = []APIKeyMetadata{}
-		return nil
-	})
-
+func TestAPIKeyManager_GetValidAPIKeysMetadata(t *testing.T) {
+	store := newTestStore(t)
 	manager := NewAPIKeyManager(store)
 
 	now := time.Now()
 
 	store.Update(func(c *Config) error {
+		c.Keys = []string{"sk-legacy-key"}
 		c.APIKeys = []APIKeyMetadata{
 			{Key: "sk-valid-1", CreatedAt: now, ExpiresAt: now.Add(APIKeyTTL)},
 			{Key: "sk-expired", CreatedAt: now, ExpiresAt: now.Add(-time.Hour)},
@@ -231,14 +247,98 @@ func TestAPIKeyManager_GetValidKeys(t *testing.T) {
 		return nil
 	})
 
-	validKeys := manager.GetValidKeys()
-	if !slices.Contains(validKeys, "sk-legacy-key") {
-		t.Fatal("expected legacy key in valid keys")
+	valid := manager.GetValidAPIKeysMetadata()
+	prefixes := make([]string, 0, len(valid))
+	for _, metadata := range valid {
+		prefixes = append(prefixes, metadata.KeyPrefix)
+	}
+	if len(valid) != 2 || !slices.Contains(prefixes, "sk-leg") || !slices.Contains(prefixes, "sk-va") {
+		t.Fatalf("expected the legacy and the active key, got %#v", prefixes)
+	}
+	if !store.HasValidAPIKey("sk-legacy-key") || !store.HasValidAPIKey("sk-valid-1") || store.HasValidAPIKey("sk-expired") {
+		t.Fatal("expected only the legacy and the active key to validate")
+	}
+}
+
+func TestAPIKeyExpiryDefaultsAndTTLConfig(t *testing.T) {
+	created := time.Now().Add(-40 * 24 * time.Hour)
+	legacy := APIKeyMetadata{Key: "sk-legacy", CreatedAt: created}
+	if got := ResolveAPIKeyExpiry(legacy); !got.Equal(created.Add(APIKeyTTL)) {
+		t.Fatalf("expected expiry to default from CreatedAt, got %v", got)
+	}
+	if IsAPIKeyActiveAt(legacy, time.Now()) {
+		t.Fatal("expected a 40 day old key without expires_at to be expired")
+	}
+	if !IsAPIKeyActiveAt(APIKeyMetadata{Key: "sk-undated"}, time.Now()) {
+		t.Fatal("expected a key without any dates to stay active")
+	}
+
+	store := newTestStore(t)
+	store.Update(func(c *Config) error {
+		c.APIKeys = []APIKeyMetadata{legacy}
+		c.APIKeyExpiry.TTLDays = 60
+		return nil
+	})
+	if !store.HasValidAPIKey("sk-legacy") || store.IsAPIKeyExpired("sk-legacy") {
+		t.Fatal("expected ttl_days=60 to keep the key active")
+	}
+	store.Update(func(c *Config) error {
+		c.APIKeyExpiry.TTLDays = -1
+		return nil
+	})
+	if store.APIKeyTTL() != 0 || !store.HasValidAPIKey("sk-legacy") {
+		t.Fatal("expected a negative ttl_days to disable expiry")
+	}
+}
+
+func TestAPIKeyManager_RenewAPIKey(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Now()
+	store.Update(func(c *Config) error {
+		c.Keys = []string{}
+		c.APIKeys = []APIKeyMetadata{{Key: "sk-expired", CreatedAt: now.Add(-48 * time.Hour), ExpiresAt: now.Add(-time.Hour)}}
+		return nil
+	})
+	manager := NewAPIKeyManager(store)
+
+	renewed, err := manager.RenewAPIKey("sk-expired", time.Time{})
+	if err != nil {
+		t.Fatalf("RenewAPIKey returned error: %v", err)
+	}
+	if renewed.ExpiresAt.Before(now.Add(APIKeyTTL)) || !store.HasValidAPIKey("sk-expired") {
+		t.Fatalf("expected the key to be active for a fresh TTL, got %v", renewed.ExpiresAt)
+	}
+	if !renewed.CreatedAt.Equal(now.Add(-48 * time.Hour)) {
+		t.Fatal("expected CreatedAt to be preserved")
+	}
+	if _, err := manager.RenewAPIKey("sk-missing", time.Time{}); err != ErrAPIKeyNotFound {
+		t.Fatalf("expected ErrAPIKeyNotFound, got %v", err)
+	}
+}
+
+func TestAPIKeyManager_SetAPIKeyScopes(t *testing.T) {
+	store := newTestStore(t)
+	manager := NewAPIKeyManager(store)
+	if err := manager.AddAPIKey("sk-scoped"); err != nil {
+		t.Fatalf("AddAPIKey returned error: %v", err)
+	}
+
+	if _, err := manager.SetAPIKeyScopes("sk-scoped", APIKeyScopes{Routes: []string{"grpc"}}); err != ErrInvalidAPIKeyScopes {
+		t.Fatalf("expected ErrInvalidAPIKeyScopes for an unknown route, got %v", err)
+	}
+	if _, err := manager.SetAPIKeyScopes("sk-scoped", APIKeyScopes{Models: []string{"deepseek-["}}); err != ErrInvalidAPIKeyScopes {
+		t.Fatalf("expected ErrInvalidAPIKeyScopes for a bad pattern, got %v", err)
+	}
+
+	scopes := APIKeyScopes{Models: []string{"DeepSeek-*"}, Routes: []string{"openai"}, AccountGroup: "team-a"}
+	if _, err := manager.SetAPIKeyScopes("sk-scoped", scopes); err != nil {
+		t.Fatalf("SetAPIKeyScopes returned error: %v", err)
 	}
-	if !slices.Contains(validKeys, "sk-valid-1") {
-		t.Fatal("expected valid metadata key in valid keys")
+	got := store.APIKeyScopes("sk-scoped")
+	if !got.AllowsModel("gpt-4o", "deepseek-chat") || got.AllowsModel("gpt-4o", "") || !got.AllowsRoute(RouteFamilyOpenAI) || got.AllowsRoute(RouteFamilyClaude) {
+		t.Fatalf("unexpected scope checks for %#v", got)
 	}
-	if !slices.Contains(validKeys, "sk-expired") {
-		t.Fatal("expected metadata key in valid keys")
+	if got.AllowsAccount(Account{Email: "a@example.com"}) || !got.AllowsAccount(Account{Email: "b@example.com", Group: "team-a"}) || !got.AllowsTargetAccount() {
+		t.Fatalf("unexpected account checks for %#v", got)
 	}
 }
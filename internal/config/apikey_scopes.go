@@ -0,0 +1,100 @@
+package config
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// Route families a key can be scoped to.
+const (
+	RouteFamilyOpenAI     = "openai"
+	RouteFamilyClaude     = "claude"
+	RouteFamilyGemini     = "gemini"
+	RouteFamilyOllama     = "ollama"
+	RouteFamilyEmbeddings = "embeddings"
+)
+
+var routeFamilies = []string{RouteFamilyOpenAI, RouteFamilyClaude, RouteFamilyGemini, RouteFamilyOllama, RouteFamilyEmbeddings}
+
+// APIKeyScopes restricts what a key can reach. Empty fields impose no limit,
+// so keys without scopes keep full access.
+type APIKeyScopes struct {
+	// Models holds model IDs or path.Match patterns such as "deepseek-*". A
+	// request passes when its model or the DeepSeek model it resolves to
+	// matches.
+	Models []string `json:"models,omitempty"`
+	// Routes holds route families; embeddings endpoints of every protocol
+	// count as "embeddings" rather than their protocol family.
+	Routes []string `json:"routes,omitempty"`
+	// AccountGroup limits pooled accounts to those with the same Group.
+	AccountGroup string `json:"account_group,omitempty"`
+	// AllowTargetAccount permits X-Ds2-Target-Account pinning; nil allows it.
+	AllowTargetAccount *bool `json:"allow_target_account,omitempty"`
+}
+
+// Validate rejects unknown route families and malformed model patterns.
+func (s APIKeyScopes) Validate() error {
+	for _, route := range s.Routes {
+		if !slices.Contains(routeFamilies, lower(strings.TrimSpace(route))) {
+			return ErrInvalidAPIKeyScopes
+		}
+	}
+	for _, pattern := range s.Models {
+		if _, err := path.Match(lower(strings.TrimSpace(pattern)), ""); err != nil || strings.TrimSpace(pattern) == "" {
+			return ErrInvalidAPIKeyScopes
+		}
+	}
+	return nil
+}
+
+func (s APIKeyScopes) AllowsRoute(family string) bool {
+	if len(s.Routes) == 0 {
+		return true
+	}
+	for _, route := range s.Routes {
+		if lower(strings.TrimSpace(route)) == family {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsModel matches requested and resolved (either may be empty) against
+// the model scopes, case-insensitively.
+func (s APIKeyScopes) AllowsModel(requested, resolved string) bool {
+	if len(s.Models) == 0 {
+		return true
+	}
+	for _, candidate := range []string{requested, resolved} {
+		candidate = lower(strings.TrimSpace(candidate))
+		if candidate == "" {
+			continue
+		}
+		for _, pattern := range s.Models {
+			if ok, _ := path.Match(lower(strings.TrimSpace(pattern)), candidate); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s APIKeyScopes) AllowsTargetAccount() bool {
+	return s.AllowTargetAccount == nil || *s.AllowTargetAccount
+}
+
+// AllowsAccount reports whether acc belongs to the key's account group.
+func (s APIKeyScopes) AllowsAccount(acc Account) bool {
+	group := strings.TrimSpace(s.AccountGroup)
+	return group == "" || strings.TrimSpace(acc.Group) == group
+}
+
+// APIKeyScopes returns the scopes of a configured key; legacy keys and
+// unknown keys have none.
+func (s *Store) APIKeyScopes(k string) APIKeyScopes {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	metadata, _ := s.findAPIKeyMetadataLocked(k)
+	return metadata.Scopes
+}
@@ -20,6 +20,18 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	if len(c.APIKeys) > 0 {
 		m["api_keys"] = c.APIKeys
 	}
+	if c.APIKeySalt != "" {
+		m["api_key_salt"] = c.APIKeySalt
+	}
+	if c.APIKeyExpiry.TTLDays != 0 {
+		m["api_key_expiry"] = c.APIKeyExpiry
+	}
+	if !c.RateLimits.IsZero() {
+		m["rate_limits"] = c.RateLimits
+	}
+	if c.Usage.MonthlyTokenQuota != 0 {
+		m["usage"] = c.Usage
+	}
 	if len(c.Accounts) > 0 {
 		m["accounts"] = c.Accounts
 	}
@@ -41,7 +53,7 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	if c.Compat.WideInputStrictOutput != nil {
 		m["compat"] = c.Compat
 	}
-	if strings.TrimSpace(c.Toolcall.Mode) != "" || strings.TrimSpace(c.Toolcall.EarlyEmitConfidence) != "" {
+	if strings.TrimSpace(c.Toolcall.Mode) != "" || strings.TrimSpace(c.Toolcall.EarlyEmitConfidence) != "" || strings.TrimSpace(c.Toolcall.InvalidArgs) != "" {
 		m["toolcall"] = c.Toolcall
 	}
 	if c.Responses.StoreTTLSeconds > 0 {
@@ -50,6 +62,9 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	if strings.TrimSpace(c.Embeddings.Provider) != "" {
 		m["embeddings"] = c.Embeddings
 	}
+	if strings.TrimSpace(c.Thinking.SignatureSecret) != "" || c.Thinking.ReinjectHistory {
+		m["thinking"] = c.Thinking
+	}
 	if c.VercelSyncHash != "" {
 		m["_vercel_sync_hash"] = c.VercelSyncHash
 	}
@@ -75,6 +90,22 @@ func (c *Config) UnmarshalJSON(b []byte) error {
 			if err := json.Unmarshal(v, &c.APIKeys); err != nil {
 				return fmt.Errorf("invalid field %q: %w", k, err)
 			}
+		case "api_key_salt":
+			if err := json.Unmarshal(v, &c.APIKeySalt); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "api_key_expiry":
+			if err := json.Unmarshal(v, &c.APIKeyExpiry); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "rate_limits":
+			if err := json.Unmarshal(v, &c.RateLimits); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "usage":
+			if err := json.Unmarshal(v, &c.Usage); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
 		case "accounts":
 			if err := json.Unmarshal(v, &c.Accounts); err != nil {
 				return fmt.Errorf("invalid field %q: %w", k, err)
@@ -115,6 +146,10 @@ func (c *Config) UnmarshalJSON(b []byte) error {
 			if err := json.Unmarshal(v, &c.Embeddings); err != nil {
 				return fmt.Errorf("invalid field %q: %w", k, err)
 			}
+		case "thinking":
+			if err := json.Unmarshal(v, &c.Thinking); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
 		case "_vercel_sync_hash":
 			if err := json.Unmarshal(v, &c.VercelSyncHash); err != nil {
 				return fmt.Errorf("invalid field %q: %w", k, err)
@@ -137,6 +172,10 @@ func (c Config) Clone() Config {
 	clone := Config{
 		Keys:      slices.Clone(c.Keys),
 		APIKeys:   slices.Clone(c.APIKeys),
+		APIKeySalt:   c.APIKeySalt,
+		APIKeyExpiry: c.APIKeyExpiry,
+		RateLimits:   c.RateLimits,
+		Usage:        c.Usage,
 		Accounts:  slices.Clone(c.Accounts),
 		ClaudeMapping:  cloneStringMap(c.ClaudeMapping),
 		ClaudeModelMap: cloneStringMap(c.ClaudeModelMap),
@@ -149,10 +188,12 @@ func (c Config) Clone() Config {
 		Toolcall:         c.Toolcall,
 		Responses:        c.Responses,
 		Embeddings:       c.Embeddings,
+		Thinking:         c.Thinking,
 		VercelSyncHash:   c.VercelSyncHash,
 		VercelSyncTime:   c.VercelSyncTime,
 		AdditionalFields: map[string]any{},
 	}
+	clone.Embeddings.ModelMap = cloneStringMap(c.Embeddings.ModelMap)
 	for k, v := range c.AdditionalFields {
 		clone.AdditionalFields[k] = v
 	}
@@ -3,28 +3,49 @@ package config
 import "time"
 
 type Config struct {
-	Keys             []string          `json:"keys,omitempty"`
-	APIKeys          []APIKeyMetadata  `json:"api_keys,omitempty"`
-	Accounts         []Account         `json:"accounts,omitempty"`
-	ClaudeMapping    map[string]string `json:"claude_mapping,omitempty"`
-	ClaudeModelMap   map[string]string `json:"claude_model_mapping,omitempty"`
-	ModelAliases     map[string]string `json:"model_aliases,omitempty"`
-	Admin            AdminConfig       `json:"admin,omitempty"`
-	Runtime          RuntimeConfig     `json:"runtime,omitempty"`
-	Compat           CompatConfig      `json:"compat,omitempty"`
-	Toolcall         ToolcallConfig    `json:"toolcall,omitempty"`
-	Responses        ResponsesConfig   `json:"responses,omitempty"`
-	Embeddings       EmbeddingsConfig  `json:"embeddings,omitempty"`
-	VercelSyncHash   string            `json:"_vercel_sync_hash,omitempty"`
-	VercelSyncTime   int64             `json:"_vercel_sync_time,omitempty"`
-	AdditionalFields map[string]any    `json:"-"`
+	Keys             []string           `json:"keys,omitempty"`
+	APIKeys          []APIKeyMetadata   `json:"api_keys,omitempty"`
+	APIKeySalt       string             `json:"api_key_salt,omitempty"`
+	APIKeyExpiry     APIKeyExpiryConfig `json:"api_key_expiry,omitempty"`
+	RateLimits       RateLimitConfig    `json:"rate_limits,omitempty"`
+	Usage            UsageConfig        `json:"usage,omitempty"`
+	Accounts         []Account          `json:"accounts,omitempty"`
+	ClaudeMapping    map[string]string  `json:"claude_mapping,omitempty"`
+	ClaudeModelMap   map[string]string  `json:"claude_model_mapping,omitempty"`
+	ModelAliases     map[string]string  `json:"model_aliases,omitempty"`
+	Admin            AdminConfig        `json:"admin,omitempty"`
+	Runtime          RuntimeConfig      `json:"runtime,omitempty"`
+	Compat           CompatConfig       `json:"compat,omitempty"`
+	Toolcall         ToolcallConfig     `json:"toolcall,omitempty"`
+	Responses        ResponsesConfig    `json:"responses,omitempty"`
+	Embeddings       EmbeddingsConfig   `json:"embeddings,omitempty"`
+	Thinking         ThinkingConfig     `json:"thinking,omitempty"`
+	VercelSyncHash   string             `json:"_vercel_sync_hash,omitempty"`
+	VercelSyncTime   int64              `json:"_vercel_sync_time,omitempty"`
+	AdditionalFields map[string]any     `json:"-"`
 }
 
 type APIKeyMetadata struct {
-	ID        string    `json:"id"`
-	Key       string    `json:"key"`
-	CreatedAt time.Time `json:"created_at"`
-	ExpiresAt time.Time `json:"expires_at"`
+	ID string `json:"id"`
+	// Key is a plaintext key not yet hashed; the store replaces it with
+	// KeyHash and KeyPrefix before the config is saved.
+	Key       string       `json:"key,omitempty"`
+	KeyHash   string       `json:"key_hash,omitempty"`
+	KeyPrefix string       `json:"key_prefix,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	Scopes    APIKeyScopes `json:"scopes,omitzero"`
+	// RateLimits overrides the global rate_limits for this key.
+	RateLimits RateLimitConfig `json:"rate_limits,omitzero"`
+	// MonthlyTokenQuota overrides usage.monthly_token_quota; negative lifts it.
+	MonthlyTokenQuota int64 `json:"monthly_token_quota,omitempty"`
+}
+
+// APIKeyExpiryConfig sets the lifetime given to new keys and to stored keys
+// without an expires_at. TTLDays 0 uses APIKeyTTLDays; a negative value
+// disables expiry.
+type APIKeyExpiryConfig struct {
+	TTLDays int `json:"ttl_days,omitempty"`
 }
 
 type Account struct {
@@ -32,6 +53,8 @@ type Account struct {
 	Mobile   string `json:"mobile,omitempty"`
 	Password string `json:"password,omitempty"`
 	Token    string `json:"token,omitempty"`
+	// Group ties the account to keys scoped to the same account_group.
+	Group string `json:"group,omitempty"`
 }
 
 type CompatConfig struct {
@@ -53,6 +76,7 @@ type RuntimeConfig struct {
 type ToolcallConfig struct {
 	Mode                string `json:"mode,omitempty"`
 	EarlyEmitConfidence string `json:"early_emit_confidence,omitempty"`
+	InvalidArgs         string `json:"invalid_args,omitempty"`
 }
 
 type ResponsesConfig struct {
@@ -61,4 +85,23 @@ type ResponsesConfig struct {
 
 type EmbeddingsConfig struct {
 	Provider string `json:"provider,omitempty"`
+	// BaseURL, APIKey and ModelMap configure the "openai" forwarding
+	// provider; ModelMap["*"] is the fallback upstream model.
+	BaseURL        string            `json:"base_url,omitempty"`
+	APIKey         string            `json:"api_key,omitempty"`
+	ModelMap       map[string]string `json:"model_map,omitempty"`
+	TimeoutSeconds int               `json:"timeout_seconds,omitempty"`
+	// TaskTypeField names the upstream request field that receives a Gemini
+	// taskType (e.g. "task_type"); empty drops it, as OpenAI rejects
+	// unknown fields.
+	TaskTypeField string `json:"task_type_field,omitempty"`
+	// Dimensions is the default vector size of the "local" provider.
+	Dimensions int `json:"dimensions,omitempty"`
+}
+
+// ThinkingConfig covers Anthropic thinking blocks: the key used to sign them
+// and whether signed reasoning from earlier turns is replayed to upstream.
+type ThinkingConfig struct {
+	SignatureSecret string `json:"signature_secret,omitempty"`
+	ReinjectHistory bool   `json:"reinject_history,omitempty"`
 }
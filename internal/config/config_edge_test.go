@@ -288,8 +288,8 @@ func TestStoreSnapshotReturnsClone(t *testing.T) {
 	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k1"],"accounts":[{"email":"u@test.com","token":"t1"}]}`)
 	store := LoadStore()
 	snap := store.Snapshot()
-	snap.Keys[0] = "modified"
-	if store.Keys()[0] != "k1" {
+	snap.APIKeys[0].KeyHash = "modified"
+	if !store.HasAPIKey("k1") || store.Snapshot().APIKeys[0].KeyHash == "modified" {
 		t.Fatal("snapshot modification should not affect store")
 	}
 }
@@ -435,15 +435,19 @@ func TestStoreExportJSONAndBase64(t *testing.T) {
 	if err != nil {
 		t.Fatalf("export error: %v", err)
 	}
-	if !strings.Contains(jsonStr, "export-key") {
-		t.Fatalf("expected JSON to contain key: %q", jsonStr)
+	if strings.Contains(jsonStr, "export-key") || !strings.Contains(jsonStr, `"key_hash"`) {
+		t.Fatalf("expected JSON to hold the key hash only: %q", jsonStr)
 	}
 	decoded, err := base64.StdEncoding.DecodeString(b64Str)
 	if err != nil {
 		t.Fatalf("base64 decode error: %v", err)
 	}
-	if !strings.Contains(string(decoded), "export-key") {
-		t.Fatalf("expected base64-decoded to contain key: %q", string(decoded))
+	if string(decoded) != jsonStr {
+		t.Fatalf("expected base64 to decode to the JSON export: %q", string(decoded))
+	}
+	t.Setenv("DS2API_CONFIG_JSON", jsonStr)
+	if !LoadStore().HasValidAPIKey("export-key") {
+		t.Fatal("expected the exported config to still accept the key")
 	}
 }
 
@@ -2,6 +2,9 @@ package config
 
 import (
 	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -75,7 +78,7 @@ func TestStoreUpdateAccountTokenKeepsOldAndNewIdentifierResolvable(t *testing.T)
 func TestLoadStoreRejectsInvalidFieldType(t *testing.T) {
 	t.Setenv("DS2API_CONFIG_JSON", `{"keys":"not-array","accounts":[]}`)
 	store := LoadStore()
-	if len(store.Keys()) != 0 || len(store.Accounts()) != 0 {
+	if len(store.KeyPrefixes()) != 0 || len(store.Accounts()) != 0 {
 		t.Fatalf("expected empty store when config type is invalid")
 	}
 }
@@ -121,3 +124,122 @@ func TestLoadConfigOnVercelWithoutConfigFileFallsBackToMemory(t *testing.T) {
 		t.Fatalf("expected empty bootstrap config, got keys=%d accounts=%d", len(cfg.Keys), len(cfg.Accounts))
 	}
 }
+
+func TestMigrateAPIKeysToHashes(t *testing.T) {
+	cfg := Config{
+		Keys: []string{"legacy-key-001", "sk-meta-001"},
+		APIKeys: []APIKeyMetadata{
+			{ID: "apikey:team", Key: "sk-meta-001"},
+			{Key: "sk-plain-001"},
+		},
+	}
+	migrated, renamed := migrateAPIKeysToHashes(&cfg)
+	if !migrated {
+		t.Fatal("expected plaintext keys to be migrated")
+	}
+	if cfg.APIKeySalt == "" || len(cfg.Keys) != 0 || len(cfg.APIKeys) != 3 {
+		t.Fatalf("unexpected migrated config: %#v", cfg)
+	}
+	for _, metadata := range cfg.APIKeys {
+		if metadata.Key != "" || metadata.KeyHash == "" || metadata.KeyPrefix == "" {
+			t.Fatalf("expected hashed entry, got %#v", metadata)
+		}
+	}
+	if cfg.APIKeys[0].ID != "apikey:team" || cfg.APIKeys[0].KeyHash != HashAPIKey(cfg.APIKeySalt, "sk-meta-001") {
+		t.Fatalf("expected custom ID to be kept, got %#v", cfg.APIKeys[0])
+	}
+	if cfg.APIKeys[1].ID != apiKeyIDFromHash(cfg.APIKeys[1].KeyHash) {
+		t.Fatalf("expected the generated ID to be derived from the hash, got %#v", cfg.APIKeys[1])
+	}
+	if cfg.APIKeys[2].ID != apiKeyIDFromHash(cfg.APIKeys[2].KeyHash) || !cfg.APIKeys[2].ExpiresAt.IsZero() {
+		t.Fatalf("expected legacy key with a hash-derived ID and no expiry, got %#v", cfg.APIKeys[2])
+	}
+	wantRenamed := map[string]string{
+		generateAPIKeyID("sk-plain-001"):   cfg.APIKeys[1].ID,
+		generateAPIKeyID("legacy-key-001"): cfg.APIKeys[2].ID,
+		generateAPIKeyID("sk-meta-001"):    "apikey:team",
+	}
+	if !reflect.DeepEqual(renamed, wantRenamed) {
+		t.Fatalf("expected former IDs to map to the new ones, got %#v", renamed)
+	}
+	if MigrateAPIKeysToHashes(&cfg) {
+		t.Fatal("expected migration to be idempotent")
+	}
+}
+
+func TestLoadStoreHashesPlaintextKeysInFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	raw := `{"keys":["legacy-key-001"],"api_keys":[{"id":"apikey:team","key":"sk-meta-001"}]}`
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DS2API_CONFIG_JSON", "")
+	t.Setenv("CONFIG_JSON", "")
+	t.Setenv("DS2API_CONFIG_PATH", path)
+
+	store := LoadStore()
+	if !store.HasValidAPIKey("legacy-key-001") || !store.HasValidAPIKey("sk-meta-001") {
+		t.Fatal("expected migrated keys to validate")
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(saved), "legacy-key-001") || strings.Contains(string(saved), "sk-meta-001") {
+		t.Fatalf("expected no plaintext keys in saved config: %s", saved)
+	}
+	backups, _ := filepath.Glob(path + ".backup.*")
+	if len(backups) != 0 {
+		t.Fatalf("expected the plaintext backup to be removed, got %v", backups)
+	}
+}
+
+func TestThinkingSignatureSecretIsGeneratedAndSaved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"admin":{"password_hash":"hash"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DS2API_CONFIG_JSON", "")
+	t.Setenv("CONFIG_JSON", "")
+	t.Setenv("DS2API_CONFIG_PATH", path)
+	t.Setenv("DS2API_THINKING_SECRET", "")
+	t.Setenv("DS2API_ADMIN_KEY", "admin")
+
+	secret := LoadStore().ThinkingSignatureSecret()
+	if len(secret) != 64 || secret == "hash" || secret == "admin" {
+		t.Fatalf("expected a random secret, got %q", secret)
+	}
+	if again := LoadStore().ThinkingSignatureSecret(); again != secret {
+		t.Fatalf("expected the secret to be saved with the config, got %q then %q", secret, again)
+	}
+}
+
+func TestThinkingSignatureSecretOfEnvBackedConfig(t *testing.T) {
+	t.Setenv("CONFIG_JSON", "")
+	t.Setenv("DS2API_THINKING_SECRET", "")
+	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k1"]}`)
+	if err := LoadStore().CheckThinkingSecret(); err == nil {
+		t.Fatal("expected an env-backed config without a salt or secret to be refused")
+	}
+
+	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k1"],"api_key_salt":"salt-001"}`)
+	store := LoadStore()
+	secret := store.ThinkingSignatureSecret()
+	if err := store.CheckThinkingSecret(); err != nil || len(secret) != 64 {
+		t.Fatalf("expected a secret derived from the salt, got %q %v", secret, err)
+	}
+	if again := LoadStore().ThinkingSignatureSecret(); again != secret {
+		t.Fatalf("expected every instance to derive the same secret, got %q then %q", secret, again)
+	}
+
+	t.Setenv("DS2API_CONFIG_JSON", `{}`)
+	if err := LoadStore().CheckThinkingSecret(); err != nil {
+		t.Fatalf("expected an empty config to be set up from /admin, got %v", err)
+	}
+
+	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k1"]}`)
+	t.Setenv("DS2API_THINKING_SECRET", "shared-secret")
+	if err := LoadStore().CheckThinkingSecret(); err != nil {
+		t.Fatalf("expected the env secret to be accepted, got %v", err)
+	}
+}
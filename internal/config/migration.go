@@ -37,7 +37,7 @@ func MigrateAPIKeysToV2(cfg *Config) bool {
 			ID:        generateAPIKeyID(key),
 			Key:       key,
 			CreatedAt: now,
-			ExpiresAt: APIKeyExpiryFrom(now),
+			ExpiresAt: apiKeyExpiryAfter(now, apiKeyTTLFor(cfg.APIKeyExpiry)),
 		}
 		apiKeys = append(apiKeys, metadata)
 		existing[key] = struct{}{}
@@ -51,6 +51,68 @@ func MigrateAPIKeysToV2(cfg *Config) bool {
 	return true
 }
 
+// MigrateAPIKeysToHashes replaces every plaintext key in cfg with its
+// salted hash and visible prefix, creating api_key_salt on first use. Legacy
+// keys become metadata entries without an expiry, as they had none before.
+// Keys already present as a hash are dropped as duplicates.
+func MigrateAPIKeysToHashes(cfg *Config) bool {
+	migrated, _ := migrateAPIKeysToHashes(cfg)
+	return migrated
+}
+
+// migrateAPIKeysToHashes is MigrateAPIKeysToHashes, also returning the
+// former ID of every key whose ID changed mapped to its new one. Keys were
+// known by an unsalted digest (generateAPIKeyID) while in the clear, and the
+// usage ledger must be re-keyed for their history and quotas to carry over.
+func migrateAPIKeysToHashes(cfg *Config) (bool, map[string]string) {
+	if !hasPlaintextAPIKeys(*cfg) {
+		return false, nil
+	}
+	if cfg.APIKeySalt == "" {
+		cfg.APIKeySalt = newAPIKeySalt()
+	}
+
+	renamed := map[string]string{}
+	apiKeys := make([]APIKeyMetadata, 0, len(cfg.APIKeys)+len(cfg.Keys))
+	existing := make(map[string]string, len(cfg.APIKeys)+len(cfg.Keys))
+	add := func(metadata APIKeyMetadata, formerID string) {
+		id, ok := existing[metadata.KeyHash]
+		if !ok || metadata.KeyHash == "" {
+			existing[metadata.KeyHash] = metadata.Identifier()
+			apiKeys = append(apiKeys, metadata)
+			id = metadata.Identifier()
+		}
+		if formerID != "" && formerID != id {
+			renamed[formerID] = id
+		}
+	}
+	for _, metadata := range cfg.APIKeys {
+		formerID := ""
+		if metadata.Key != "" {
+			key := strings.TrimSpace(metadata.Key)
+			if key == "" {
+				continue
+			}
+			formerID = metadata.ID
+			if formerID == "" {
+				formerID = generateAPIKeyID(key)
+			}
+			metadata = hashAPIKeyEntry(cfg.APIKeySalt, metadata)
+		}
+		add(metadata, formerID)
+	}
+	for _, key := range cfg.Keys {
+		if strings.TrimSpace(key) == "" {
+			continue
+		}
+		add(hashAPIKeyEntry(cfg.APIKeySalt, APIKeyMetadata{Key: key}), generateAPIKeyID(key))
+	}
+
+	cfg.APIKeys = apiKeys
+	cfg.Keys = nil
+	return true, renamed
+}
+
 func BackupConfig(filePath string) (string, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
@@ -40,3 +40,8 @@ func WASMPath() string {
 func StaticAdminDir() string {
 	return ResolvePath("DS2API_STATIC_ADMIN_DIR", "static/admin")
 }
+
+// DataDir holds local runtime state such as uploaded files and batch jobs.
+func DataDir() string {
+	return ResolvePath("DS2API_DATA_DIR", "data")
+}
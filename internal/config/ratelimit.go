@@ -0,0 +1,70 @@
+package config
+
+// RateLimitConfig caps the traffic of one API key. On a key, zero fields
+// inherit the global default and negative ones lift that limit; in the
+// global default, zero means unlimited.
+type RateLimitConfig struct {
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+	TokensPerMinute   int `json:"tokens_per_minute,omitempty"`
+	MaxConcurrent     int `json:"max_concurrent,omitempty"`
+}
+
+func (c RateLimitConfig) IsZero() bool {
+	return c.RequestsPerMinute == 0 && c.TokensPerMinute == 0 && c.MaxConcurrent == 0
+}
+
+// Merge fills zero fields from defaults and turns negative values into 0,
+// so the result only holds positive limits or 0 for unlimited.
+func (c RateLimitConfig) Merge(defaults RateLimitConfig) RateLimitConfig {
+	pick := func(own, def int) int {
+		switch {
+		case own < 0:
+			return 0
+		case own > 0:
+			return own
+		}
+		if def < 0 {
+			return 0
+		}
+		return def
+	}
+	return RateLimitConfig{
+		RequestsPerMinute: pick(c.RequestsPerMinute, defaults.RequestsPerMinute),
+		TokensPerMinute:   pick(c.TokensPerMinute, defaults.TokensPerMinute),
+		MaxConcurrent:     pick(c.MaxConcurrent, defaults.MaxConcurrent),
+	}
+}
+
+// APIKeyRateLimits returns the metadata ID and effective limits of a
+// configured key. Legacy keys get the global defaults; ok is false for keys
+// the store does not manage.
+func (s *Store) APIKeyRateLimits(k string) (id string, limits RateLimitConfig, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id, metadata, ok := s.apiKeyIDLocked(k)
+	if !ok {
+		return "", RateLimitConfig{}, false
+	}
+	return id, s.cfg.KeyRateLimits(metadata), true
+}
+
+// KeyRateLimits returns the limits of metadata under the current global
+// defaults.
+func (s *Store) KeyRateLimits(metadata APIKeyMetadata) RateLimitConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.KeyRateLimits(metadata)
+}
+
+// KeyRateLimits returns the limits of metadata merged over the global
+// defaults.
+func (c Config) KeyRateLimits(metadata APIKeyMetadata) RateLimitConfig {
+	return metadata.RateLimits.Merge(c.RateLimits)
+}
+
+// DefaultRateLimits returns the global per-key defaults.
+func (s *Store) DefaultRateLimits() RateLimitConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.RateLimits
+}
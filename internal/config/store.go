@@ -8,6 +8,7 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Store struct {
@@ -15,9 +16,14 @@ type Store struct {
 	cfg        Config
 	path       string
 	fromEnv    bool
-	keyMap     map[string]struct{}       // O(1) legacy API key lookup index
-	keyMetaMap map[string]APIKeyMetadata // O(1) API key metadata lookup
+	envSalt    string                    // api_key_salt as given by an env-backed config
+	keyMetaMap map[string]APIKeyMetadata // O(1) API key lookup: key hash -> metadata
 	accMap     map[string]int            // O(1) account lookup: identifier -> slice index
+
+	// keyIDRenames holds key ID changes from hashing migrations until
+	// renameKeyIDs has moved their usage history.
+	keyIDRenames map[string]string
+	renameKeyIDs func(map[string]string) error
 }
 
 func NewStore(cfg *Config, path string) *Store {
@@ -30,8 +36,9 @@ func NewStore(cfg *Config, path string) *Store {
 	if cfg != nil {
 		initial = cfg.Clone()
 	}
+	_, renamed := migrateAPIKeysToHashes(&initial)
 
-	s := &Store{cfg: initial, path: storePath}
+	s := &Store{cfg: initial, path: storePath, keyIDRenames: renamed}
 	s.rebuildIndexes()
 	return s
 }
@@ -41,11 +48,16 @@ func LoadStore() *Store {
 	if err != nil {
 		Logger.Warn("[config] load failed", "error", err)
 	}
-	if len(cfg.Keys) == 0 && len(cfg.Accounts) == 0 {
+	if len(cfg.Keys) == 0 && len(cfg.APIKeys) == 0 && len(cfg.Accounts) == 0 {
 		Logger.Warn("[config] empty config loaded")
 	}
 
-	if len(cfg.Keys) > 0 && len(cfg.APIKeys) == 0 && !fromEnv {
+	envSalt := ""
+	if fromEnv {
+		envSalt = strings.TrimSpace(cfg.APIKeySalt)
+	}
+	var renamed map[string]string
+	if hasPlaintextAPIKeys(cfg) && !fromEnv {
 		tempPath := ConfigPath() + ".tmp"
 
 		backupPath, err := BackupConfig(ConfigPath())
@@ -56,12 +68,16 @@ func LoadStore() *Store {
 			Logger.Info("[config] config backed up", "path", backupPath)
 
 			originalCfg := cfg.Clone()
-			if MigrateAPIKeysToV2(&cfg) {
+			if len(cfg.APIKeys) == 0 {
+				MigrateAPIKeysToV2(&cfg)
+			}
+			if migrated, keyIDRenames := migrateAPIKeysToHashes(&cfg); migrated {
+				renamed = keyIDRenames
 				if err := SaveConfigToPath(&cfg, tempPath); err != nil {
 					Logger.Error("[config] failed to write migrated config to temp file", "error", err)
 					Logger.Warn("[config] cleaning up temp file and aborting migration")
 					os.Remove(tempPath)
-					cfg = originalCfg
+					cfg, renamed = originalCfg, nil
 				} else {
 					if err := os.Rename(tempPath, ConfigPath()); err != nil {
 						Logger.Error("[config] failed to rename temp file to config", "error", err)
@@ -70,16 +86,25 @@ func LoadStore() *Store {
 							Logger.Error("[config] failed to restore config from backup", "error", restoreErr)
 						}
 						os.Remove(tempPath)
-						cfg = originalCfg
+						cfg, renamed = originalCfg, nil
 					} else {
 						Logger.Info("[config] migration completed successfully")
+						// The backup is the last copy of the plaintext keys.
+						if err := os.Remove(backupPath); err != nil {
+							Logger.Warn("[config] failed to remove pre-migration backup", "path", backupPath, "error", err)
+						}
 					}
 				}
 			}
 		}
 	}
 
-	s := &Store{cfg: cfg, path: ConfigPath(), fromEnv: fromEnv}
+	// Env-backed configs, and files whose migration failed, are hashed in
+	// memory so plaintext keys are never exported or saved later.
+	if _, keyIDRenames := migrateAPIKeysToHashes(&cfg); keyIDRenames != nil {
+		renamed = keyIDRenames
+	}
+	s := &Store{cfg: cfg, path: ConfigPath(), fromEnv: fromEnv, envSalt: envSalt, keyIDRenames: renamed}
 	s.rebuildIndexes()
 	return s
 }
@@ -121,40 +146,64 @@ func (s *Store) Snapshot() Config {
 }
 
 func (s *Store) findAPIKeyMetadataLocked(k string) (APIKeyMetadata, bool) {
-	metadata, ok := s.keyMetaMap[k]
+	if len(s.keyMetaMap) == 0 {
+		return APIKeyMetadata{}, false
+	}
+	metadata, ok := s.keyMetaMap[HashAPIKey(s.cfg.APIKeySalt, k)]
 	return metadata, ok
 }
 
 func (s *Store) HasAPIKey(k string) bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	if _, found := s.findAPIKeyMetadataLocked(k); found {
-		return true
-	}
-	_, ok := s.keyMap[k]
-	return ok
+	_, found := s.findAPIKeyMetadataLocked(k)
+	return found
 }
 
+// HasValidAPIKey reports whether k is a configured key that has not expired.
+// Keys migrated from the legacy keys list never expire.
 func (s *Store) HasValidAPIKey(k string) bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	if _, found := s.findAPIKeyMetadataLocked(k); found {
-		return true
-	}
-	_, ok := s.keyMap[k]
-	return ok
+	metadata, found := s.findAPIKeyMetadataLocked(k)
+	return found && apiKeyActiveAt(metadata, time.Now(), s.apiKeyTTLLocked())
 }
 
+// IsAPIKeyExpired reports whether k is a configured key past its expiry, so
+// callers can tell it apart from an unknown key.
 func (s *Store) IsAPIKeyExpired(k string) bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return false
+	metadata, found := s.findAPIKeyMetadataLocked(k)
+	if !found {
+		return false
+	}
+	return !apiKeyActiveAt(metadata, time.Now(), s.apiKeyTTLLocked())
+}
+
+// APIKeyByID returns the configured key with ID id and whether it is still
+// active, for work that outlives the request that presented the key.
+func (s *Store) APIKeyByID(id string) (metadata APIKeyMetadata, active, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, metadata := range s.cfg.APIKeys {
+		if id != "" && metadata.Identifier() == id {
+			return metadata, apiKeyActiveAt(metadata, time.Now(), s.apiKeyTTLLocked()), true
+		}
+	}
+	return APIKeyMetadata{}, false, false
 }
 
-func (s *Store) Keys() []string {
+// KeyPrefixes returns the visible prefixes of the configured keys; the keys
+// themselves are only stored as hashes.
+func (s *Store) KeyPrefixes() []string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return slices.Clone(s.cfg.Keys)
+	prefixes := make([]string, 0, len(s.cfg.APIKeys))
+	for _, metadata := range s.cfg.APIKeys {
+		prefixes = append(prefixes, metadata.KeyPrefix)
+	}
+	return prefixes
 }
 
 func (s *Store) Accounts() []Account {
@@ -198,10 +247,39 @@ func (s *Store) UpdateAccountToken(identifier, token string) error {
 	return s.saveLocked()
 }
 
+// OnAPIKeyIDRenames registers fn to move usage history when hashing a
+// plaintext key gives it a new ID. Renames from loading the config are
+// passed at once; a failed call is retried with the next rename.
+func (s *Store) OnAPIKeyIDRenames(fn func(renamed map[string]string) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.renameKeyIDs = fn
+	s.noteKeyIDRenamesLocked(nil)
+}
+
+func (s *Store) noteKeyIDRenamesLocked(renamed map[string]string) {
+	for from, to := range renamed {
+		if s.keyIDRenames == nil {
+			s.keyIDRenames = map[string]string{}
+		}
+		s.keyIDRenames[from] = to
+	}
+	if s.renameKeyIDs == nil || len(s.keyIDRenames) == 0 {
+		return
+	}
+	if err := s.renameKeyIDs(s.keyIDRenames); err != nil {
+		Logger.Warn("[config] failed to move usage history to new key IDs", "error", err)
+		return
+	}
+	s.keyIDRenames = nil
+}
+
 func (s *Store) Replace(cfg Config) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.cfg = cfg.Clone()
+	_, renamed := migrateAPIKeysToHashes(&s.cfg)
+	s.noteKeyIDRenamesLocked(renamed)
 	s.rebuildIndexes()
 	return s.saveLocked()
 }
@@ -213,6 +291,8 @@ func (s *Store) Update(mutator func(*Config) error) error {
 	if err := mutator(&cfg); err != nil {
 		return err
 	}
+	_, renamed := migrateAPIKeysToHashes(&cfg)
+	s.noteKeyIDRenamesLocked(renamed)
 	s.cfg = cfg
 	s.rebuildIndexes()
 	return s.saveLocked()
@@ -1,9 +1,15 @@
 package config
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func (s *Store) ClaudeMapping() map[string]string {
@@ -62,6 +68,18 @@ func (s *Store) ToolcallEarlyEmitConfidence() string {
 	return level
 }
 
+// ToolcallInvalidArgs controls what happens to tool calls whose arguments fail
+// schema validation: pass_through (default), drop or repair.
+func (s *Store) ToolcallInvalidArgs() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	mode := strings.TrimSpace(strings.ToLower(s.cfg.Toolcall.InvalidArgs))
+	if mode == "" {
+		return "pass_through"
+	}
+	return mode
+}
+
 func (s *Store) ResponsesStoreTTLSeconds() int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -71,12 +89,118 @@ func (s *Store) ResponsesStoreTTLSeconds() int {
 	return 900
 }
 
+// APIKeyTTL is the lifetime of new keys and of stored keys without an
+// expires_at; zero means keys do not expire.
+func (s *Store) APIKeyTTL() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.apiKeyTTLLocked()
+}
+
+func (s *Store) apiKeyTTLLocked() time.Duration {
+	return apiKeyTTLFor(s.cfg.APIKeyExpiry)
+}
+
+func apiKeyTTLFor(cfg APIKeyExpiryConfig) time.Duration {
+	days := cfg.TTLDays
+	if days == 0 {
+		if raw := strings.TrimSpace(os.Getenv("DS2API_API_KEY_TTL_DAYS")); raw != "" {
+			if n, err := strconv.Atoi(raw); err == nil {
+				days = n
+			}
+		}
+	}
+	switch {
+	case days < 0:
+		return 0
+	case days == 0:
+		return APIKeyTTL
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
 func (s *Store) EmbeddingsProvider() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return strings.TrimSpace(s.cfg.Embeddings.Provider)
 }
 
+// EmbeddingsSettings returns a copy of the embeddings section.
+func (s *Store) EmbeddingsSettings() EmbeddingsConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := s.cfg.Embeddings
+	out.ModelMap = cloneStringMap(s.cfg.Embeddings.ModelMap)
+	return out
+}
+
+// ThinkingSignatureSecret keys the HMAC on Anthropic thinking blocks. Like
+// api_key_salt, a random secret is generated on first use and saved with the
+// config, so signatures survive restarts without extra configuration.
+// Env-backed configs are never saved, so they derive the secret from
+// api_key_salt instead, the same on every instance (see CheckThinkingSecret).
+func (s *Store) ThinkingSignatureSecret() string {
+	if v := strings.TrimSpace(os.Getenv("DS2API_THINKING_SECRET")); v != "" {
+		return v
+	}
+	s.mu.RLock()
+	secret := strings.TrimSpace(s.cfg.Thinking.SignatureSecret)
+	salt := strings.TrimSpace(s.cfg.APIKeySalt)
+	fromEnv := s.fromEnv
+	s.mu.RUnlock()
+	if secret != "" {
+		return secret
+	}
+	if fromEnv && salt != "" {
+		mac := hmac.New(sha256.New, []byte(salt))
+		mac.Write([]byte("ds2api thinking signature secret"))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	err := s.Update(func(c *Config) error {
+		if strings.TrimSpace(c.Thinking.SignatureSecret) == "" {
+			c.Thinking.SignatureSecret = newThinkingSecret()
+		}
+		secret = strings.TrimSpace(c.Thinking.SignatureSecret)
+		return nil
+	})
+	if err != nil {
+		// The secret is kept in memory; it is saved with the next write.
+		Logger.Warn("[config] failed to save thinking signature secret", "error", err)
+	}
+	return secret
+}
+
+// CheckThinkingSecret fails for an env-backed config that sets neither a
+// thinking secret nor api_key_salt: each instance would sign with its own
+// secret and reject the thinking blocks of the others. An empty config is
+// let through, so a fresh Vercel deployment can still be set up from /admin.
+func (s *Store) CheckThinkingSecret() error {
+	if strings.TrimSpace(os.Getenv("DS2API_THINKING_SECRET")) != "" {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.fromEnv || s.envSalt != "" || strings.TrimSpace(s.cfg.Thinking.SignatureSecret) != "" {
+		return nil
+	}
+	if len(s.cfg.APIKeys) == 0 && len(s.cfg.Accounts) == 0 {
+		return nil
+	}
+	return errors.New("env-backed config has no stable thinking signature secret: set DS2API_THINKING_SECRET, or thinking.signature_secret or api_key_salt in the config")
+}
+
+func newThinkingSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *Store) ThinkingReinjectHistory() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.Thinking.ReinjectHistory
+}
+
 func (s *Store) AdminPasswordHash() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
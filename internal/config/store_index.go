@@ -2,13 +2,11 @@ package config
 
 // rebuildIndexes must be called with the lock already held (or during init).
 func (s *Store) rebuildIndexes() {
-	s.keyMap = make(map[string]struct{}, len(s.cfg.Keys))
-	for _, k := range s.cfg.Keys {
-		s.keyMap[k] = struct{}{}
-	}
 	s.keyMetaMap = make(map[string]APIKeyMetadata, len(s.cfg.APIKeys))
 	for _, metadata := range s.cfg.APIKeys {
-		s.keyMetaMap[metadata.Key] = metadata
+		if metadata.KeyHash != "" {
+			s.keyMetaMap[metadata.KeyHash] = metadata
+		}
 	}
 	s.accMap = make(map[string]int, len(s.cfg.Accounts))
 	for i, acc := range s.cfg.Accounts {
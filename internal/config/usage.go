@@ -0,0 +1,56 @@
+package config
+
+// UsageConfig holds the defaults of the usage ledger.
+type UsageConfig struct {
+	// MonthlyTokenQuota caps the input plus output tokens a managed key may
+	// use per UTC calendar month; 0 means unlimited.
+	MonthlyTokenQuota int64 `json:"monthly_token_quota,omitempty"`
+}
+
+// APIKeyQuota returns the metadata ID and effective monthly token quota of a
+// configured key, 0 meaning unlimited. A key's own quota wins over the
+// global one and a negative value lifts it; ok is false for keys the store
+// does not manage.
+func (s *Store) APIKeyQuota(k string) (id string, quota int64, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id, metadata, ok := s.apiKeyIDLocked(k)
+	if !ok {
+		return "", 0, false
+	}
+	return id, s.cfg.KeyMonthlyQuota(metadata), true
+}
+
+// KeyMonthlyQuota returns the monthly token quota of metadata under the
+// current global default; 0 means unlimited.
+func (s *Store) KeyMonthlyQuota(metadata APIKeyMetadata) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.KeyMonthlyQuota(metadata)
+}
+
+// KeyMonthlyQuota returns the monthly token quota of metadata, falling back
+// to usage.monthly_token_quota; 0 means unlimited.
+func (c Config) KeyMonthlyQuota(metadata APIKeyMetadata) int64 {
+	quota := metadata.MonthlyTokenQuota
+	if quota == 0 {
+		quota = c.Usage.MonthlyTokenQuota
+	}
+	return max(quota, 0)
+}
+
+// APIKeyID returns the ID of a configured key.
+func (s *Store) APIKeyID(k string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id, _, ok := s.apiKeyIDLocked(k)
+	return id, ok
+}
+
+func (s *Store) apiKeyIDLocked(k string) (string, APIKeyMetadata, bool) {
+	metadata, found := s.findAPIKeyMetadataLocked(k)
+	if !found {
+		return "", APIKeyMetadata{}, false
+	}
+	return metadata.Identifier(), metadata, true
+}
@@ -0,0 +1,42 @@
+package embeddings
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+const deterministicDefaultDims = 64
+
+// deterministicProvider returns stable pseudo-random vectors derived from
+// SHA-256. They keep the response shape valid but carry no meaning.
+type deterministicProvider struct{}
+
+func (deterministicProvider) Embed(_ context.Context, req Request) (Result, error) {
+	dims := req.Dimensions
+	if dims <= 0 {
+		dims = deterministicDefaultDims
+	}
+	out := Result{Vectors: make([][]float64, 0, len(req.Inputs))}
+	for _, input := range req.Inputs {
+		out.Vectors = append(out.Vectors, deterministicEmbedding(input, dims))
+	}
+	return out, nil
+}
+
+func deterministicEmbedding(input string, dims int) []float64 {
+	out := make([]float64, dims)
+	seed := sha256.Sum256([]byte(input))
+	buf := seed[:]
+	for i := 0; i < dims; i++ {
+		if len(buf) < 4 {
+			next := sha256.Sum256(buf)
+			buf = next[:]
+		}
+		v := binary.BigEndian.Uint32(buf[:4])
+		buf = buf[4:]
+		// map [0, 2^32) -> [-1, 1]
+		out[i] = (float64(v)/2147483647.5 - 1.0)
+	}
+	return out
+}
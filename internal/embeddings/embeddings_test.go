@@ -0,0 +1,194 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ds2api/internal/config"
+)
+
+func cosine(a, b []float64) float64 {
+	dot, na, nb := 0.0, 0.0, 0.0
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+func TestDeterministicEmbeddingStable(t *testing.T) {
+	a := deterministicEmbedding("hello", deterministicDefaultDims)
+	b := deterministicEmbedding("hello", deterministicDefaultDims)
+	if len(a) != 64 || len(b) != 64 {
+		t.Fatalf("expected 64 dims, got %d and %d", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("expected stable embedding at %d: %v != %v", i, a[i], b[i])
+		}
+	}
+}
+
+func TestLocalProviderRanksRelatedTextsCloser(t *testing.T) {
+	p, err := New(config.EmbeddingsConfig{Provider: "local"})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	res, err := p.Embed(context.Background(), Request{Inputs: []string{
+		"How do I reset my account password?",
+		"Steps to reset a forgotten password for your account",
+		"Best recipes for a chocolate cake",
+	}})
+	if err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	if len(res.Vectors[0]) != localDefaultDims {
+		t.Fatalf("expected default dims %d, got %d", localDefaultDims, len(res.Vectors[0]))
+	}
+	related := cosine(res.Vectors[0], res.Vectors[1])
+	unrelated := cosine(res.Vectors[0], res.Vectors[2])
+	if related <= unrelated+0.1 {
+		t.Fatalf("expected related texts to be closer: related=%.3f unrelated=%.3f", related, unrelated)
+	}
+}
+
+func TestLocalProviderHonoursDimensions(t *testing.T) {
+	p, _ := New(config.EmbeddingsConfig{Provider: "local", Dimensions: 128})
+	res, _ := p.Embed(context.Background(), Request{Inputs: []string{"你好世界"}})
+	if len(res.Vectors[0]) != 128 {
+		t.Fatalf("expected configured dims, got %d", len(res.Vectors[0]))
+	}
+	res, _ = p.Embed(context.Background(), Request{Inputs: []string{"你好世界"}, Dimensions: 32})
+	if len(res.Vectors[0]) != 32 {
+		t.Fatalf("expected request dims to win, got %d", len(res.Vectors[0]))
+	}
+	norm := 0.0
+	for _, v := range res.Vectors[0] {
+		norm += v * v
+	}
+	if math.Abs(norm-1) > 1e-9 {
+		t.Fatalf("expected unit vector, norm^2=%v", norm)
+	}
+}
+
+func TestRemoteProviderForwardsWithModelMap(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" || r.Header.Get("Authorization") != "Bearer up-key" {
+			t.Errorf("unexpected upstream request %s auth=%q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"data":[{"index":1,"embedding":"AACAPwAAAEA="},{"index":0,"embedding":[0.5,0.25]}],"usage":{"prompt_tokens":7}}`))
+	}))
+	defer srv.Close()
+
+	p, err := New(config.EmbeddingsConfig{Provider: "openai", BaseURL: srv.URL + "/v1/", APIKey: "up-key", ModelMap: map[string]string{"deepseek-chat": "text-embedding-3-small"}})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	res, err := p.Embed(context.Background(), Request{Model: "deepseek-chat", Inputs: []string{"a", "b"}, Dimensions: 2})
+	if err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	if got["model"] != "text-embedding-3-small" || got["dimensions"] != float64(2) {
+		t.Fatalf("unexpected forwarded payload: %#v", got)
+	}
+	if res.PromptTokens != 7 || res.Vectors[0][0] != 0.5 || res.Vectors[1][0] != 1 || res.Vectors[1][1] != 2 {
+		t.Fatalf("unexpected result: %#v", res)
+	}
+}
+
+func TestRemoteProviderForwardsTaskTypeOnlyWhenConfigured(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = nil
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"data":[{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer srv.Close()
+
+	req := Request{Model: "m", Inputs: []string{"a"}, TaskType: "RETRIEVAL_QUERY"}
+	plain, _ := New(config.EmbeddingsConfig{Provider: "openai", BaseURL: srv.URL})
+	if _, err := plain.Embed(context.Background(), req); err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	if _, ok := got["task_type"]; ok {
+		t.Fatalf("task type must not be forwarded by default: %#v", got)
+	}
+	forwarding, _ := New(config.EmbeddingsConfig{Provider: "openai", BaseURL: srv.URL, TaskTypeField: "task_type"})
+	if _, err := forwarding.Embed(context.Background(), req); err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	if got["task_type"] != "RETRIEVAL_QUERY" {
+		t.Fatalf("expected task type to be forwarded, got %#v", got)
+	}
+}
+
+func TestRemoteProviderMapsUpstreamErrors(t *testing.T) {
+	cases := map[int]int{
+		http.StatusUnauthorized:        http.StatusBadGateway,
+		http.StatusForbidden:           http.StatusBadGateway,
+		http.StatusUnprocessableEntity: http.StatusBadRequest,
+		http.StatusTooManyRequests:     http.StatusTooManyRequests,
+		http.StatusInternalServerError: http.StatusBadGateway,
+	}
+	for upstream, want := range cases {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(upstream)
+			_, _ = w.Write([]byte(`{"error":{"message":"upstream says no"}}`))
+		}))
+		p, _ := New(config.EmbeddingsConfig{Provider: "openai", BaseURL: srv.URL})
+		_, err := p.Embed(context.Background(), Request{Model: "m", Inputs: []string{"a"}})
+		srv.Close()
+		var perr *Error
+		if !errors.As(err, &perr) || perr.Status != want || perr.Message == "" {
+			t.Fatalf("upstream %d: expected status %d, got %#v", upstream, want, err)
+		}
+	}
+}
+
+func TestRemoteProviderRejectsBadIndexes(t *testing.T) {
+	for _, data := range []string{
+		`[{"index":0,"embedding":[1]},{"index":0,"embedding":[2]}]`,
+		`[{"index":0,"embedding":[1]},{"index":2,"embedding":[2]}]`,
+		`[{"index":-1,"embedding":[1]},{"index":1,"embedding":[2]}]`,
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"data":` + data + `}`))
+		}))
+		p, _ := New(config.EmbeddingsConfig{Provider: "openai", BaseURL: srv.URL})
+		_, err := p.Embed(context.Background(), Request{Model: "m", Inputs: []string{"a", "b"}})
+		srv.Close()
+		var perr *Error
+		if !errors.As(err, &perr) || perr.Status != http.StatusBadGateway {
+			t.Fatalf("data %s: expected 502, got %#v", data, err)
+		}
+	}
+}
+
+func TestNewRejectsUnknownAndIncompleteProviders(t *testing.T) {
+	for _, cfg := range []config.EmbeddingsConfig{{}, {Provider: "nope"}, {Provider: "openai"}} {
+		var perr *Error
+		if _, err := New(cfg); !errors.As(err, &perr) || perr.Status != http.StatusNotImplemented {
+			t.Fatalf("expected 501 error for %#v, got %v", cfg, err)
+		}
+	}
+}
+
+func TestEncodeBase64RoundTrips(t *testing.T) {
+	enc := EncodeBase64([]float64{1, 2})
+	if enc != "AACAPwAAAEA=" {
+		t.Fatalf("unexpected encoding %q", enc)
+	}
+	raw, _ := json.Marshal(enc)
+	vec, err := decodeEmbedding(raw)
+	if err != nil || len(vec) != 2 || vec[1] != 2 {
+		t.Fatalf("unexpected decode %v %v", vec, err)
+	}
+}
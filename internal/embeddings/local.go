@@ -0,0 +1,124 @@
+package embeddings
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+const localDefaultDims = 256
+
+// Feature weights: whole words carry most of the signal, character trigrams
+// make the vectors tolerant to inflection and typos, and word bigrams keep a
+// little ordering information.
+const (
+	localWordWeight    = 1.0
+	localBigramWeight  = 0.5
+	localTrigramWeight = 0.35
+	localStopwordScale = 0.15
+)
+
+// localProvider builds offline vectors with signed feature hashing over words,
+// word bigrams and character trigrams, using sublinear term frequency and a
+// small stopword list as a stand-in for IDF. Texts sharing vocabulary end up
+// close under cosine similarity, which is enough for simple retrieval.
+type localProvider struct {
+	defaultDims int
+}
+
+func (p localProvider) Embed(_ context.Context, req Request) (Result, error) {
+	dims := req.Dimensions
+	if dims <= 0 {
+		dims = p.defaultDims
+	}
+	if dims <= 0 {
+		dims = localDefaultDims
+	}
+	out := Result{Vectors: make([][]float64, 0, len(req.Inputs))}
+	for _, input := range req.Inputs {
+		out.Vectors = append(out.Vectors, localEmbedding(input, dims))
+	}
+	return out, nil
+}
+
+func localEmbedding(text string, dims int) []float64 {
+	features := map[string]float64{}
+	words := localWords(text)
+	for i, w := range words {
+		scale := 1.0
+		if localStopwords[w] {
+			scale = localStopwordScale
+		}
+		features["w:"+w] += localWordWeight * scale
+		if i > 0 {
+			features["b:"+words[i-1]+" "+w] += localBigramWeight * scale
+		}
+		runes := []rune("<" + w + ">")
+		for j := 0; j+3 <= len(runes); j++ {
+			features["c:"+string(runes[j:j+3])] += localTrigramWeight * scale
+		}
+	}
+
+	vec := make([]float64, dims)
+	for feature, tf := range features {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(feature))
+		sum := h.Sum64()
+		weight := 1 + math.Log(tf+1)
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vec[int(sum%uint64(dims))] += weight
+	}
+	norm := 0.0
+	for _, v := range vec {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vec {
+			vec[i] /= norm
+		}
+	}
+	return vec
+}
+
+// localWords lowercases text and splits it into words. Han, kana and hangul
+// characters have no spaces between words, so each one becomes a word and
+// the bigram features recover multi-character terms.
+func localWords(text string) []string {
+	var words []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			words = append(words, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flush()
+			words = append(words, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			cur.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words
+}
+
+var localStopwords = func() map[string]bool {
+	out := map[string]bool{}
+	for _, w := range strings.Fields(`a an and are as at be but by for from has have he her his i in is it its
+		of on or she that the their them they this to was were will with you your we our not no do does
+		did so if then than there these those what which who whom how when where why can could would should
+		的 了 是 在 和 也 有 就 都 而 及 与 着 或`) {
+		out[w] = true
+	}
+	return out
+}()
@@ -0,0 +1,58 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ds2api/internal/config"
+)
+
+// Request is one embeddings call after the adapter has validated it.
+type Request struct {
+	Model      string
+	Inputs     []string
+	Dimensions int
+	// TaskType is a Gemini task hint such as RETRIEVAL_QUERY. Only the remote
+	// provider forwards it, and only when a task type field is configured.
+	TaskType string
+}
+
+// Result carries one vector per input, in order. PromptTokens is zero when the
+// provider does not report usage; the adapter then counts tokens itself.
+type Result struct {
+	Vectors      [][]float64
+	PromptTokens int
+}
+
+type Provider interface {
+	Embed(ctx context.Context, req Request) (Result, error)
+}
+
+// Error is returned for failures the caller should see with a specific
+// HTTP status (e.g. an upstream rejecting the request).
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// New builds the provider named by cfg.Provider.
+func New(cfg config.EmbeddingsConfig) (Provider, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Provider)) {
+	case "mock", "deterministic", "builtin":
+		return deterministicProvider{}, nil
+	case "local", "hashing", "tfidf":
+		return localProvider{defaultDims: cfg.Dimensions}, nil
+	case "openai", "openai_compatible", "remote":
+		return newRemoteProvider(cfg)
+	case "":
+		return nil, &Error{Status: http.StatusNotImplemented, Message: "Embeddings provider is not configured. Set embeddings.provider in config."}
+	default:
+		return nil, &Error{Status: http.StatusNotImplemented, Message: fmt.Sprintf("Embeddings provider '%s' is not supported.", cfg.Provider)}
+	}
+}
@@ -0,0 +1,180 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"ds2api/internal/config"
+)
+
+const remoteDefaultTimeout = 60 * time.Second
+
+// remoteProvider forwards requests to any OpenAI-compatible /embeddings
+// endpoint, translating model names through the configured model map.
+type remoteProvider struct {
+	endpoint string
+	apiKey   string
+	modelMap map[string]string
+	taskType string
+	client   *http.Client
+}
+
+func newRemoteProvider(cfg config.EmbeddingsConfig) (Provider, error) {
+	base := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if base == "" {
+		return nil, &Error{Status: http.StatusNotImplemented, Message: "Embeddings provider 'openai' requires embeddings.base_url."}
+	}
+	timeout := remoteDefaultTimeout
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	return &remoteProvider{
+		endpoint: base + "/embeddings",
+		apiKey:   strings.TrimSpace(cfg.APIKey),
+		modelMap: cfg.ModelMap,
+		taskType: strings.TrimSpace(cfg.TaskTypeField),
+		client:   &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (p *remoteProvider) Embed(ctx context.Context, req Request) (Result, error) {
+	model := req.Model
+	if mapped := strings.TrimSpace(p.modelMap[model]); mapped != "" {
+		model = mapped
+	} else if fallback := strings.TrimSpace(p.modelMap["*"]); fallback != "" {
+		model = fallback
+	}
+	payload := map[string]any{
+		"model":           model,
+		"input":           req.Inputs,
+		"encoding_format": "float",
+	}
+	if req.Dimensions > 0 {
+		payload["dimensions"] = req.Dimensions
+	}
+	if p.taskType != "" && req.TaskType != "" {
+		payload[p.taskType] = req.TaskType
+	}
+	body, _ := json.Marshal(payload)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return Result{}, &Error{Status: http.StatusBadGateway, Message: fmt.Sprintf("embeddings upstream request failed: %v", err)}
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return Result{}, &Error{Status: http.StatusBadGateway, Message: fmt.Sprintf("embeddings upstream read failed: %v", err)}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Result{}, &Error{Status: upstreamStatus(resp.StatusCode), Message: fmt.Sprintf("embeddings upstream returned %d: %s", resp.StatusCode, upstreamMessage(raw))}
+	}
+
+	var parsed struct {
+		Data []struct {
+			Index     int             `json:"index"`
+			Embedding json.RawMessage `json:"embedding"`
+		} `json:"data"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return Result{}, &Error{Status: http.StatusBadGateway, Message: "embeddings upstream returned invalid JSON."}
+	}
+	if len(parsed.Data) != len(req.Inputs) {
+		return Result{}, &Error{Status: http.StatusBadGateway, Message: fmt.Sprintf("embeddings upstream returned %d vectors for %d inputs.", len(parsed.Data), len(req.Inputs))}
+	}
+	vectors := make([][]float64, len(req.Inputs))
+	for _, item := range parsed.Data {
+		idx := item.Index
+		if idx < 0 || idx >= len(vectors) || vectors[idx] != nil {
+			return Result{}, &Error{Status: http.StatusBadGateway, Message: fmt.Sprintf("embeddings upstream returned a duplicate or out-of-range index %d.", idx)}
+		}
+		vec, err := decodeEmbedding(item.Embedding)
+		if err != nil {
+			return Result{}, &Error{Status: http.StatusBadGateway, Message: err.Error()}
+		}
+		vectors[idx] = vec
+	}
+	return Result{Vectors: vectors, PromptTokens: parsed.Usage.PromptTokens}, nil
+}
+
+// decodeEmbedding accepts both float arrays and base64 float32 payloads, since
+// some compatible servers ignore encoding_format.
+func decodeEmbedding(raw json.RawMessage) ([]float64, error) {
+	var floats []float64
+	if err := json.Unmarshal(raw, &floats); err == nil {
+		return floats, nil
+	}
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err != nil {
+		return nil, fmt.Errorf("embeddings upstream returned an unreadable vector.")
+	}
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(b)%4 != 0 {
+		return nil, fmt.Errorf("embeddings upstream returned an unreadable vector.")
+	}
+	out := make([]float64, len(b)/4)
+	for i := range out {
+		out[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:])))
+	}
+	return out, nil
+}
+
+// EncodeBase64 renders a vector the way OpenAI does for encoding_format
+// "base64": little-endian float32 values, base64 encoded.
+func EncodeBase64(vec []float64) string {
+	b := make([]byte, 4*len(vec))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// upstreamStatus maps an upstream error onto ours. A rejected credential is
+// our misconfiguration, not the client's, so 401/403 become 502.
+func upstreamStatus(status int) int {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return http.StatusTooManyRequests
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return http.StatusBadGateway
+	case status >= 400 && status < 500:
+		return http.StatusBadRequest
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+func upstreamMessage(raw []byte) string {
+	var parsed struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &parsed); err == nil && parsed.Error.Message != "" {
+		return parsed.Error.Message
+	}
+	msg := strings.TrimSpace(string(raw))
+	if len(msg) > 200 {
+		msg = msg[:200]
+	}
+	return msg
+}
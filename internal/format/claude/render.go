@@ -8,14 +8,37 @@ import (
 )
 
 func BuildMessageResponse(messageID, model string, normalizedMessages []any, finalThinking, finalText string, toolNames []string) map[string]any {
+	return BuildMessageResponseWithStop(messageID, model, normalizedMessages, finalThinking, finalText, toolNames, util.OutputFinishNone, "")
+}
+
+// BuildMessageResponseWithStop reports stop_sequence / max_tokens when the
+// local stop policy ended generation; detected tool calls still win.
+func BuildMessageResponseWithStop(messageID, model string, normalizedMessages []any, finalThinking, finalText string, toolNames []string, finishReason, matchedSequence string) map[string]any {
 	detected := util.ParseToolCalls(finalText, toolNames)
+	return BuildMessageResponseFromCalls(messageID, model, normalizedMessages, finalThinking, finalText, detected, finishReason, matchedSequence)
+}
+
+// BuildMessageResponseFromCalls renders calls the caller already parsed and
+// filtered, e.g. after applying tool_choice and the single-call limit.
+func BuildMessageResponseFromCalls(messageID, model string, normalizedMessages []any, finalThinking, finalText string, detected []util.ParsedToolCall, finishReason, matchedSequence string) map[string]any {
 	content := make([]map[string]any, 0, 4)
 	if finalThinking != "" {
 		content = append(content, map[string]any{"type": "thinking", "thinking": finalThinking})
 	}
 	stopReason := "end_turn"
+	var stopSequence any
+	switch finishReason {
+	case util.OutputFinishStopSequence:
+		stopReason = "stop_sequence"
+		stopSequence = matchedSequence
+	case util.OutputFinishMaxTokens:
+		stopReason = "max_tokens"
+	case util.OutputFinishContentFilter:
+		stopReason = "refusal"
+	}
 	if len(detected) > 0 {
 		stopReason = "tool_use"
+		stopSequence = nil
 		for i, tc := range detected {
 			content = append(content, map[string]any{
 				"type":  "tool_use",
@@ -37,10 +60,22 @@ func BuildMessageResponse(messageID, model string, normalizedMessages []any, fin
 		"model":         model,
 		"content":       content,
 		"stop_reason":   stopReason,
-		"stop_sequence": nil,
-		"usage": map[string]any{
-			"input_tokens":  util.EstimateTokens(fmt.Sprintf("%v", normalizedMessages)),
-			"output_tokens": util.EstimateTokens(finalThinking) + util.EstimateTokens(finalText),
-		},
+		"stop_sequence": stopSequence,
+		"usage":         BuildMessageUsage(normalizedMessages, finalThinking, finalText),
 	}
 }
+
+// BuildMessageUsage is shared by the non-stream response body and the
+// streaming message_delta event so both report the same token counts.
+func BuildMessageUsage(normalizedMessages []any, finalThinking, finalText string) map[string]any {
+	return map[string]any{
+		"input_tokens":                EstimateInputTokens(normalizedMessages),
+		"output_tokens":               util.CountTokens(finalThinking) + util.CountTokens(finalText),
+		"cache_creation_input_tokens": 0,
+		"cache_read_input_tokens":     0,
+	}
+}
+
+func EstimateInputTokens(normalizedMessages []any) int {
+	return util.CountTokens(fmt.Sprintf("%v", normalizedMessages))
+}
@@ -0,0 +1,23 @@
+package claude
+
+import "ds2api/internal/promptcache"
+
+// ApplyPromptCacheUsage reports prompt-cache activity the Anthropic way:
+// input_tokens only counts the part of the prompt after the last cache
+// breakpoint, next to cache_creation_input_tokens and cache_read_input_tokens.
+func ApplyPromptCacheUsage(usage map[string]any, cache promptcache.Usage) {
+	if usage == nil {
+		return
+	}
+	usage["cache_creation_input_tokens"] = cache.CreationTokens
+	usage["cache_read_input_tokens"] = cache.ReadTokens
+	if cache.CreationTokens+cache.ReadTokens > 0 {
+		usage["input_tokens"] = cache.Uncached()
+	}
+}
+
+// AttachPromptCacheUsage applies cache accounting to a message response.
+func AttachPromptCacheUsage(resp map[string]any, cache promptcache.Usage) {
+	usage, _ := resp["usage"].(map[string]any)
+	ApplyPromptCacheUsage(usage, cache)
+}
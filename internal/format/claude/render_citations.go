@@ -0,0 +1,81 @@
+package claude
+
+import (
+	"encoding/base64"
+	"strconv"
+
+	"ds2api/internal/util"
+)
+
+// CitedSegment is a run of text ending at a group of adjacent citations.
+// The trailing uncited run has no citations.
+type CitedSegment struct {
+	Text      string
+	Citations []map[string]any
+}
+
+// BuildWebSearchCitation renders a search result the way Claude reports web
+// search citations on text blocks.
+func BuildWebSearchCitation(src util.SearchResult) map[string]any {
+	return map[string]any{
+		"type":            "web_search_result_location",
+		"url":             src.URL,
+		"title":           src.Title,
+		"encrypted_index": base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(src.Index))),
+		"cited_text":      src.Snippet,
+	}
+}
+
+// SplitCitedText cuts text after every group of adjacent citations so each
+// cited passage can become its own text block. Span offsets are byte offsets
+// relative to text.
+func SplitCitedText(text string, spans []util.CitationSpan) []CitedSegment {
+	out := make([]CitedSegment, 0, len(spans)+1)
+	pos := 0
+	for i := 0; i < len(spans); {
+		j := i
+		for j+1 < len(spans) && spans[j+1].Start == spans[j].End {
+			j++
+		}
+		end := spans[j].End
+		if end < pos || end > len(text) {
+			break
+		}
+		citations := make([]map[string]any, 0, j-i+1)
+		for _, span := range spans[i : j+1] {
+			citations = append(citations, BuildWebSearchCitation(span.Source))
+		}
+		out = append(out, CitedSegment{Text: text[pos:end], Citations: citations})
+		pos = end
+		i = j + 1
+	}
+	if pos < len(text) {
+		out = append(out, CitedSegment{Text: text[pos:]})
+	}
+	return out
+}
+
+// AttachCitations replaces the text block of a message response with one
+// block per cited passage.
+func AttachCitations(resp map[string]any, text string, spans []util.CitationSpan) {
+	if len(spans) == 0 {
+		return
+	}
+	content, _ := resp["content"].([]map[string]any)
+	for i, block := range content {
+		if block["type"] != "text" || block["text"] != text {
+			continue
+		}
+		blocks := make([]map[string]any, 0, len(spans)+1)
+		for _, seg := range SplitCitedText(text, spans) {
+			b := map[string]any{"type": "text", "text": seg.Text}
+			if len(seg.Citations) > 0 {
+				b["citations"] = seg.Citations
+			}
+			blocks = append(blocks, b)
+		}
+		out := append(append(append([]map[string]any{}, content[:i]...), blocks...), content[i+1:]...)
+		resp["content"] = out
+		return
+	}
+}
@@ -0,0 +1,49 @@
+package claude
+
+import (
+	"testing"
+
+	"ds2api/internal/util"
+)
+
+func TestAttachCitationsSplitsTextBlocks(t *testing.T) {
+	results := []util.SearchResult{{Index: 1, URL: "https://a.example", Title: "A"}, {Index: 2, URL: "https://b.example", Title: "B"}}
+	text, spans := util.ResolveCitations("One[citation:1][citation:2] two.", results)
+	resp := BuildMessageResponse("msg_1", "claude-sonnet-4-5", nil, "", text, nil)
+	AttachCitations(resp, text, spans)
+
+	content, _ := resp["content"].([]map[string]any)
+	if len(content) != 2 {
+		t.Fatalf("expected cited block plus tail, got %#v", content)
+	}
+	if content[0]["text"] != "One[1][2]" || content[1]["text"] != " two." {
+		t.Fatalf("unexpected split %#v", content)
+	}
+	citations, _ := content[0]["citations"].([]map[string]any)
+	if len(citations) != 2 || citations[1]["url"] != "https://b.example" {
+		t.Fatalf("unexpected citations %#v", content[0])
+	}
+	if _, ok := content[1]["citations"]; ok {
+		t.Fatalf("tail block should carry no citations: %#v", content[1])
+	}
+}
+
+func TestAttachThinkingSignaturesVerifies(t *testing.T) {
+	resp := BuildMessageResponse("msg_1", "claude-opus-4-6", nil, "step by step", "answer", nil)
+	AttachThinkingSignatures(resp, "secret")
+
+	content, _ := resp["content"].([]map[string]any)
+	sig, _ := content[0]["signature"].(string)
+	if content[0]["type"] != "thinking" || sig == "" {
+		t.Fatalf("expected signed thinking block, got %#v", content[0])
+	}
+	if !VerifyThinkingSignature("secret", "step by step", sig) {
+		t.Fatalf("signature should verify")
+	}
+	if VerifyThinkingSignature("secret", "step by step!", sig) || VerifyThinkingSignature("other", "step by step", sig) {
+		t.Fatalf("signature must not verify for altered text or another secret")
+	}
+	if _, ok := content[1]["signature"]; ok {
+		t.Fatalf("text block must not be signed: %#v", content[1])
+	}
+}
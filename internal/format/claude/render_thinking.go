@@ -0,0 +1,38 @@
+package claude
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// SignThinking returns the opaque signature carried by a thinking block: an
+// HMAC-SHA256 of the reasoning text, so clients can send the block back in
+// later turns and the server can tell it was not altered.
+func SignThinking(secret, thinking string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(thinking))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func VerifyThinkingSignature(secret, thinking, signature string) bool {
+	got, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(thinking))
+	return hmac.Equal(mac.Sum(nil), got)
+}
+
+// AttachThinkingSignatures signs every thinking block of a message response.
+func AttachThinkingSignatures(resp map[string]any, secret string) {
+	content, _ := resp["content"].([]map[string]any)
+	for _, block := range content {
+		if block["type"] != "thinking" {
+			continue
+		}
+		thinking, _ := block["thinking"].(string)
+		block["signature"] = SignThinking(secret, thinking)
+	}
+}
@@ -8,8 +8,19 @@ import (
 )
 
 func BuildChatCompletion(completionID, model, finalPrompt, finalThinking, finalText string, toolNames []string) map[string]any {
+	return BuildChatCompletionWithFinishReason(completionID, model, finalPrompt, finalThinking, finalText, toolNames, "stop")
+}
+
+// BuildChatCompletionWithFinishReason lets callers report "length" when the
+// output was cut at max_tokens; detected tool calls still win.
+func BuildChatCompletionWithFinishReason(completionID, model, finalPrompt, finalThinking, finalText string, toolNames []string, finishReason string) map[string]any {
 	detected := util.ParseToolCalls(finalText, toolNames)
-	finishReason := "stop"
+	return BuildChatCompletionFromCalls(completionID, model, finalPrompt, finalThinking, finalText, detected, finishReason)
+}
+
+// BuildChatCompletionFromCalls renders already parsed (and policy-checked)
+// tool calls instead of re-detecting them from finalText.
+func BuildChatCompletionFromCalls(completionID, model, finalPrompt, finalThinking, finalText string, detected []util.ParsedToolCall, finishReason string) map[string]any {
 	messageObj := map[string]any{"role": "assistant", "content": finalText}
 	if strings.TrimSpace(finalThinking) != "" {
 		messageObj["reasoning_content"] = finalThinking
@@ -58,3 +69,14 @@ func BuildChatStreamChunk(completionID string, created int64, model string, choi
 	}
 	return out
 }
+
+func BuildChatStreamUsageChunk(completionID string, created int64, model string, usage map[string]any) map[string]any {
+	return map[string]any{
+		"id":      completionID,
+		"object":  "chat.completion.chunk",
+		"created": created,
+		"model":   model,
+		"choices": []map[string]any{},
+		"usage":   usage,
+	}
+}
@@ -0,0 +1,79 @@
+package openai
+
+import "ds2api/internal/util"
+
+// BuildChatURLCitation renders a citation span as a chat completion
+// url_citation annotation. Indices count characters of the message content.
+func BuildChatURLCitation(span util.CitationSpan) map[string]any {
+	return map[string]any{
+		"type": "url_citation",
+		"url_citation": map[string]any{
+			"start_index": span.RuneStart,
+			"end_index":   span.RuneEnd,
+			"url":         span.Source.URL,
+			"title":       span.Source.Title,
+		},
+	}
+}
+
+// BuildResponsesURLCitation renders a citation span as a Responses API
+// url_citation annotation; offset shifts the span into the output_text part.
+func BuildResponsesURLCitation(span util.CitationSpan, offset int) map[string]any {
+	return map[string]any{
+		"type":        "url_citation",
+		"start_index": span.RuneStart + offset,
+		"end_index":   span.RuneEnd + offset,
+		"url":         span.Source.URL,
+		"title":       span.Source.Title,
+	}
+}
+
+// AttachChatAnnotations adds url_citation annotations to the assistant
+// message of a chat completion. Tool call responses carry no content, so
+// they are left untouched.
+func AttachChatAnnotations(resp map[string]any, spans []util.CitationSpan) {
+	if len(spans) == 0 {
+		return
+	}
+	choices, _ := resp["choices"].([]map[string]any)
+	if len(choices) == 0 {
+		return
+	}
+	msg, _ := choices[0]["message"].(map[string]any)
+	if msg == nil || msg["content"] == nil {
+		return
+	}
+	annotations := make([]map[string]any, 0, len(spans))
+	for _, span := range spans {
+		annotations = append(annotations, BuildChatURLCitation(span))
+	}
+	msg["annotations"] = annotations
+}
+
+// AttachResponseAnnotations adds url_citation annotations to the output_text
+// part of a response's message item.
+func AttachResponseAnnotations(resp map[string]any, spans []util.CitationSpan) {
+	if len(spans) == 0 {
+		return
+	}
+	output, _ := resp["output"].([]any)
+	for _, item := range output {
+		m, _ := item.(map[string]any)
+		if m == nil || m["type"] != "message" {
+			continue
+		}
+		content, _ := m["content"].([]any)
+		for _, part := range content {
+			pm, _ := part.(map[string]any)
+			if pm == nil || pm["type"] != "output_text" {
+				continue
+			}
+			annotations := make([]map[string]any, 0, len(spans))
+			for _, span := range spans {
+				annotations = append(annotations, BuildResponsesURLCitation(span, 0))
+			}
+			pm["annotations"] = annotations
+			return
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package openai
+
+// BuildTextCompletionChoice renders one legacy /v1/completions choice;
+// finishReason is nil on intermediate stream chunks.
+func BuildTextCompletionChoice(index int, text string, finishReason any) map[string]any {
+	return map[string]any{
+		"text":          text,
+		"index":         index,
+		"logprobs":      nil,
+		"finish_reason": finishReason,
+	}
+}
+
+func BuildTextCompletion(completionID string, created int64, model string, choices []map[string]any, usage map[string]any) map[string]any {
+	return map[string]any{
+		"id":      completionID,
+		"object":  "text_completion",
+		"created": created,
+		"model":   model,
+		"choices": choices,
+		"usage":   usage,
+	}
+}
+
+func BuildTextCompletionChunk(completionID string, created int64, model string, choices []map[string]any, usage map[string]any) map[string]any {
+	out := map[string]any{
+		"id":      completionID,
+		"object":  "text_completion",
+		"created": created,
+		"model":   model,
+		"choices": choices,
+	}
+	if usage != nil {
+		out["usage"] = usage
+	}
+	return out
+}
@@ -17,6 +17,12 @@ func BuildResponseObject(responseID, model, finalPrompt, finalThinking, finalTex
 	if len(detected) == 0 && strings.TrimSpace(finalThinking) != "" {
 		detected = util.ParseToolCalls(finalThinking, toolNames)
 	}
+	return BuildResponseObjectFromCalls(responseID, model, finalPrompt, finalThinking, finalText, detected)
+}
+
+// BuildResponseObjectFromCalls renders already parsed (and policy-checked)
+// tool calls instead of re-detecting them from the output text.
+func BuildResponseObjectFromCalls(responseID, model, finalPrompt, finalThinking, finalText string, detected []util.ParsedToolCall) map[string]any {
 	exposedOutputText := finalText
 	output := make([]any, 0, 2)
 	if len(detected) > 0 {
@@ -74,6 +80,13 @@ func BuildResponseObjectFromItems(responseID, model, finalPrompt, finalThinking,
 	}
 }
 
+// MarkResponseIncomplete flags a response object whose output was cut short,
+// e.g. by max_output_tokens.
+func MarkResponseIncomplete(response map[string]any, reason string) {
+	response["status"] = "incomplete"
+	response["incomplete_details"] = map[string]any{"reason": reason}
+}
+
 func toResponsesFunctionCallItems(toolCalls []util.ParsedToolCall) []any {
 	if len(toolCalls) == 0 {
 		return nil
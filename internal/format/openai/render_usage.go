@@ -3,26 +3,61 @@ package openai
 import "ds2api/internal/util"
 
 func BuildChatUsage(finalPrompt, finalThinking, finalText string) map[string]any {
-	promptTokens := util.EstimateTokens(finalPrompt)
-	reasoningTokens := util.EstimateTokens(finalThinking)
-	completionTokens := util.EstimateTokens(finalText)
+	return BuildChatUsageFromCounts(util.CountTokens(finalPrompt), util.CountTokens(finalThinking), util.CountTokens(finalText))
+}
+
+// BuildChatUsageFromCounts renders chat-style usage for callers that
+// aggregate token counts themselves, e.g. multi-prompt text completions.
+func BuildChatUsageFromCounts(promptTokens, reasoningTokens, completionTokens int) map[string]any {
 	return map[string]any{
 		"prompt_tokens":     promptTokens,
 		"completion_tokens": reasoningTokens + completionTokens,
 		"total_tokens":      promptTokens + reasoningTokens + completionTokens,
+		"prompt_tokens_details": map[string]any{
+			"cached_tokens": 0,
+		},
 		"completion_tokens_details": map[string]any{
 			"reasoning_tokens": reasoningTokens,
 		},
 	}
 }
 
+// SetCachedTokens records prompt-cache reads in chat usage
+// (prompt_tokens_details) or Responses usage (input_tokens_details), capped
+// at the reported prompt size.
+func SetCachedTokens(usage map[string]any, cached int) {
+	if usage == nil || cached <= 0 {
+		return
+	}
+	for _, pair := range [][2]string{{"prompt_tokens", "prompt_tokens_details"}, {"input_tokens", "input_tokens_details"}} {
+		total, ok := usage[pair[0]].(int)
+		if !ok {
+			continue
+		}
+		usage[pair[1]] = map[string]any{"cached_tokens": min(cached, total)}
+	}
+}
+
+// AttachCachedTokens applies SetCachedTokens to a chat completion or
+// Responses object.
+func AttachCachedTokens(obj map[string]any, cached int) {
+	usage, _ := obj["usage"].(map[string]any)
+	SetCachedTokens(usage, cached)
+}
+
 func BuildResponsesUsage(finalPrompt, finalThinking, finalText string) map[string]any {
-	promptTokens := util.EstimateTokens(finalPrompt)
-	reasoningTokens := util.EstimateTokens(finalThinking)
-	completionTokens := util.EstimateTokens(finalText)
+	promptTokens := util.CountTokens(finalPrompt)
+	reasoningTokens := util.CountTokens(finalThinking)
+	completionTokens := util.CountTokens(finalText)
 	return map[string]any{
 		"input_tokens":  promptTokens,
 		"output_tokens": reasoningTokens + completionTokens,
 		"total_tokens":  promptTokens + reasoningTokens + completionTokens,
+		"input_tokens_details": map[string]any{
+			"cached_tokens": 0,
+		},
+		"output_tokens_details": map[string]any{
+			"reasoning_tokens": reasoningTokens,
+		},
 	}
 }
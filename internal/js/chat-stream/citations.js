@@ -0,0 +1,189 @@
+'use strict';
+
+// Mirrors internal/sse/search.go and internal/util/citations.go so the Vercel
+// stream reports url_citation annotations exactly like the Go runtime.
+
+const CITATION_PREFIX = '[citation:';
+const CITATION_MAX_LEN = 48;
+
+function parseSearchResults(chunk) {
+  if (!chunk || typeof chunk !== 'object') {
+    return [];
+  }
+  return searchResultsAt(typeof chunk.p === 'string' ? chunk.p : '', chunk.v);
+}
+
+function searchResultsAt(pathValue, v) {
+  if (pathValue === 'response/search_results' || isFragmentResultsPath(pathValue)) {
+    return searchResultsFromList(v);
+  }
+  if (pathValue === 'response/fragments') {
+    return searchResultsFromFragments(v);
+  }
+  if (pathValue === 'response') {
+    if (!Array.isArray(v)) {
+      return [];
+    }
+    const out = [];
+    for (const item of v) {
+      if (item && typeof item === 'object') {
+        out.push(...searchResultsAt(`response/${typeof item.p === 'string' ? item.p : ''}`, item.v));
+      }
+    }
+    return out;
+  }
+  if (pathValue === '' && v && typeof v === 'object' && !Array.isArray(v)) {
+    const resp = v.response && typeof v.response === 'object' ? v.response : v;
+    return [...searchResultsFromList(resp.search_results), ...searchResultsFromFragments(resp.fragments)];
+  }
+  return [];
+}
+
+function isFragmentResultsPath(pathValue) {
+  return pathValue.startsWith('response/fragments/') && pathValue.endsWith('/results');
+}
+
+function searchResultsFromFragments(frags) {
+  if (!Array.isArray(frags)) {
+    return [];
+  }
+  const out = [];
+  for (const frag of frags) {
+    if (frag && typeof frag === 'object' && String(frag.type || '').toUpperCase() === 'SEARCH') {
+      out.push(...searchResultsFromList(frag.results));
+    }
+  }
+  return out;
+}
+
+function searchResultsFromList(items) {
+  if (!Array.isArray(items)) {
+    return [];
+  }
+  const out = [];
+  for (const item of items) {
+    if (!item || typeof item !== 'object' || typeof item.url !== 'string' || !item.url.trim()) {
+      continue;
+    }
+    out.push({
+      index: Number.isInteger(item.cite_index) && item.cite_index > 0 ? item.cite_index : 0,
+      url: item.url,
+      title: typeof item.title === 'string' ? item.title.trim() : '',
+      snippet: typeof item.snippet === 'string' ? item.snippet.trim() : '',
+    });
+  }
+  return out;
+}
+
+function createCitationRewriter() {
+  const sources = new Map();
+  let nextIndex = 1;
+  let pending = '';
+  let charPos = 0;
+
+  const addSources = (results) => {
+    for (const r of results || []) {
+      if (!r || !String(r.url || '').trim()) {
+        continue;
+      }
+      const index = r.index > 0 ? r.index : nextIndex;
+      if (index >= nextIndex) {
+        nextIndex = index + 1;
+      }
+      sources.set(index, { ...r, index });
+    }
+  };
+
+  const push = (text) => {
+    let buf = pending + (text || '');
+    pending = '';
+    let out = '';
+    const annotations = [];
+    const emit = (s) => {
+      out += s;
+      charPos += Array.from(s).length;
+    };
+    while (buf.length > 0) {
+      const i = buf.indexOf('[');
+      if (i < 0) {
+        emit(buf);
+        break;
+      }
+      emit(buf.slice(0, i));
+      buf = buf.slice(i);
+      if (!buf.startsWith(CITATION_PREFIX)) {
+        if (CITATION_PREFIX.startsWith(buf)) {
+          pending = buf;
+          break;
+        }
+        emit(buf[0]);
+        buf = buf.slice(1);
+        continue;
+      }
+      const end = buf.indexOf(']');
+      if (end < 0) {
+        if (buf.length < CITATION_MAX_LEN) {
+          pending = buf;
+          break;
+        }
+        emit(buf[0]);
+        buf = buf.slice(1);
+        continue;
+      }
+      const indices = parseCitationIndices(buf.slice(CITATION_PREFIX.length, end));
+      if (!indices) {
+        emit(buf[0]);
+        buf = buf.slice(1);
+        continue;
+      }
+      for (const idx of indices) {
+        const src = sources.get(idx);
+        if (!src) {
+          continue;
+        }
+        const ref = `[${idx}]`;
+        annotations.push({
+          type: 'url_citation',
+          url_citation: {
+            start_index: charPos,
+            end_index: charPos + ref.length,
+            url: src.url,
+            title: src.title,
+          },
+        });
+        emit(ref);
+      }
+      buf = buf.slice(end + 1);
+    }
+    return { text: out, annotations };
+  };
+
+  const flush = () => {
+    const out = pending;
+    pending = '';
+    charPos += Array.from(out).length;
+    return out;
+  };
+
+  return { addSources, push, flush };
+}
+
+function parseCitationIndices(raw) {
+  const fields = raw.split(/[, ]+/).filter(Boolean);
+  if (fields.length === 0) {
+    return null;
+  }
+  const out = [];
+  for (const f of fields) {
+    if (!/^\d+$/.test(f)) {
+      return null;
+    }
+    out.push(Number(f));
+  }
+  return out;
+}
+
+module.exports = {
+  parseSearchResults,
+  createCitationRewriter,
+};
@@ -15,7 +15,12 @@ const {
 } = require('./toolcall_policy');
 const {
   estimateTokens,
+  createThinkingGate,
 } = require('./token_usage');
+const {
+  parseSearchResults,
+  createCitationRewriter,
+} = require('./citations');
 const {
   setCorsHeaders,
   readRawBody,
@@ -35,6 +40,11 @@ async function handler(req, res) {
     res.end();
     return;
   }
+  if (req.method === 'GET') {
+    // Stored chat completion listing lives on the Go side.
+    await proxyToGo(req, res, null);
+    return;
+  }
   if (req.method !== 'POST') {
     writeOpenAIError(res, 405, 'method not allowed');
     return;
@@ -58,7 +68,8 @@ async function handler(req, res) {
   }
 
   // Keep all non-stream behavior on Go side to avoid compatibility regressions.
-  if (!toBool(payload.stream)) {
+  // Stored (`store: true`) completions are persisted by Go as well.
+  if (!toBool(payload.stream) || toBool(payload.store)) {
     await proxyToGo(req, res, rawBody);
     return;
   }
@@ -85,4 +96,7 @@ module.exports.__test = {
   normalizePreparedToolNames,
   boolDefaultTrue,
   estimateTokens,
+  createThinkingGate,
+  parseSearchResults,
+  createCitationRewriter,
 };
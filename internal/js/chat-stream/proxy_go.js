@@ -29,10 +29,11 @@ async function proxyToGo(req, res, rawBody) {
   try {
     let upstream;
     try {
+      const hasBody = rawBody !== null && rawBody !== undefined;
       upstream = await fetch(url.toString(), {
-        method: 'POST',
-        headers: buildInternalGoHeaders(req, { withContentType: true }),
-        body: rawBody,
+        method: hasBody ? 'POST' : 'GET',
+        headers: buildInternalGoHeaders(req, { withContentType: hasBody }),
+        body: hasBody ? rawBody : undefined,
         signal: controller.signal,
       });
     } catch (err) {
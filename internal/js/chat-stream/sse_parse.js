@@ -36,7 +36,7 @@ function parseChunkForContent(chunk, thinkingEnabled, currentType) {
       } else if (fragType === 'RESPONSE') {
         newType = 'text';
         parts.push({ text: content, type: 'text' });
-      } else {
+      } else if (fragType !== 'SEARCH') {
         parts.push({ text: content, type: 'text' });
       }
     }
@@ -28,9 +28,35 @@ function createChatCompletionEmitter({ res, sessionID, created, model, isClosed
     });
   };
 
+  // With stream_options.include_usage the usage travels in a trailing chunk
+  // with empty choices; otherwise it rides on the finish chunk.
+  const sendFinishFrames = (reason, usage, includeUsage) => {
+    const finishFrame = {
+      id: sessionID,
+      object: 'chat.completion.chunk',
+      created,
+      model,
+      choices: [{ delta: {}, index: 0, finish_reason: reason }],
+    };
+    if (!includeUsage) {
+      sendFrame({ ...finishFrame, usage });
+      return;
+    }
+    sendFrame(finishFrame);
+    sendFrame({
+      id: sessionID,
+      object: 'chat.completion.chunk',
+      created,
+      model,
+      choices: [],
+      usage,
+    });
+  };
+
   return {
     sendFrame,
     sendDeltaFrame,
+    sendFinishFrames,
   };
 }
 
@@ -32,6 +32,38 @@ function estimateTokens(text) {
   return n < 1 ? 1 : n;
 }
 
+// createThinkingGate mirrors the Go OutputLimiter thinking controls: reasoning
+// is dropped when hidden and cut once it exceeds the budget.
+function createThinkingGate({ budget = 0, hidden = false } = {}) {
+  let asciiChars = 0;
+  let nonASCIIChars = 0;
+  let cut = false;
+  return {
+    push(text) {
+      if (hidden || cut || !text) {
+        return '';
+      }
+      if (!(budget > 0)) {
+        return text;
+      }
+      let out = '';
+      for (const ch of Array.from(text)) {
+        const ascii = ch.charCodeAt(0) < 128;
+        const a = asciiChars + (ascii ? 1 : 0);
+        const n = nonASCIIChars + (ascii ? 0 : 1);
+        if (Math.max(1, Math.floor(a / 4) + Math.floor((n * 10 + 7) / 13)) > budget) {
+          cut = true;
+          break;
+        }
+        asciiChars = a;
+        nonASCIIChars = n;
+        out += ch;
+      }
+      return out;
+    },
+  };
+}
+
 function asString(v) {
   if (typeof v === 'string') {
     return v.trim();
@@ -48,4 +80,5 @@ function asString(v) {
 module.exports = {
   buildUsage,
   estimateTokens,
+  createThinkingGate,
 };
@@ -4,6 +4,7 @@ const crypto = require('crypto');
 
 const {
   extractToolNames,
+  formatOpenAIStreamToolCalls,
 } = require('../helpers/stream-tool-sieve');
 
 function resolveToolcallPolicy(prepBody, payloadTools) {
@@ -99,8 +100,34 @@ function asString(v) {
   return String(v).trim();
 }
 
+// routeToolSieveEvents forwards tool sieve output as chat deltas and reports
+// whether any tool call went out.
+function routeToolSieveEvents(events, { emitEarlyToolDeltas, streamToolCallIDs, sendDeltaFrame }) {
+  let toolCallsEmitted = false;
+  for (const evt of events) {
+    if (evt.type === 'tool_call_deltas' && Array.isArray(evt.deltas) && evt.deltas.length > 0) {
+      if (!emitEarlyToolDeltas) {
+        continue;
+      }
+      toolCallsEmitted = true;
+      sendDeltaFrame({ tool_calls: formatIncrementalToolCallDeltas(evt.deltas, streamToolCallIDs) });
+      continue;
+    }
+    if (evt.type === 'tool_calls') {
+      toolCallsEmitted = true;
+      sendDeltaFrame({ tool_calls: formatOpenAIStreamToolCalls(evt.calls) });
+      continue;
+    }
+    if (evt.text) {
+      sendDeltaFrame({ content: evt.text });
+    }
+  }
+  return toolCallsEmitted;
+}
+
 module.exports = {
   resolveToolcallPolicy,
+  routeToolSieveEvents,
   normalizePreparedToolNames,
   boolDefaultTrue,
   formatIncrementalToolCallDeltas,
@@ -20,11 +20,13 @@ const {
   isCitation,
 } = require('./sse_parse');
 const {
-  buildUsage,
-} = require('./token_usage');
+  parseSearchResults,
+  createCitationRewriter,
+} = require('./citations');
+const { buildUsage, createThinkingGate } = require('./token_usage');
 const {
   resolveToolcallPolicy,
-  formatIncrementalToolCallDeltas,
+  routeToolSieveEvents,
 } = require('./toolcall_policy');
 const {
   createChatCompletionEmitter,
@@ -56,6 +58,7 @@ async function handleVercelStream(req, res, rawBody, payload) {
   const finalPrompt = asString(prep.body.final_prompt);
   const thinkingEnabled = toBool(prep.body.thinking_enabled);
   const searchEnabled = toBool(prep.body.search_enabled);
+  const includeUsage = toBool(prep.body.include_usage);
   const toolPolicy = resolveToolcallPolicy(prep.body, payload.tools);
   const toolNames = toolPolicy.toolNames;
 
@@ -128,17 +131,19 @@ async function handleVercelStream(req, res, rawBody, payload) {
     const created = Math.floor(Date.now() / 1000);
     let currentType = thinkingEnabled ? 'thinking' : 'text';
     let thinkingText = '';
+    const thinkingGate = createThinkingGate({ budget: Number(prep.body.thinking_budget) || 0, hidden: toBool(prep.body.hide_thinking) });
     let outputText = '';
     const toolSieveEnabled = toolPolicy.toolSieveEnabled;
     const emitEarlyToolDeltas = toolPolicy.emitEarlyToolDeltas;
     const toolSieveState = createToolSieveState();
     let toolCallsEmitted = false;
     const streamToolCallIDs = new Map();
+    const citations = searchEnabled ? createCitationRewriter() : null;
     const decoder = new TextDecoder();
     reader = completionRes.body.getReader();
     let buffered = '';
     let ended = false;
-    const { sendFrame, sendDeltaFrame } = createChatCompletionEmitter({
+    const { sendDeltaFrame, sendFinishFrames } = createChatCompletionEmitter({
       res,
       sessionID,
       created,
@@ -146,6 +151,21 @@ async function handleVercelStream(req, res, rawBody, payload) {
       isClosed: () => clientClosed,
     });
 
+    const handleText = (text) => {
+      if (!text) {
+        return;
+      }
+      outputText += text;
+      if (!toolSieveEnabled) {
+        sendDeltaFrame({ content: text });
+        return;
+      }
+      const events = processToolSieveChunk(toolSieveState, text, toolNames);
+      if (routeToolSieveEvents(events, { emitEarlyToolDeltas, streamToolCallIDs, sendDeltaFrame })) {
+        toolCallsEmitted = true;
+      }
+    };
+
     const finish = async (reason) => {
       if (ended) {
         return;
@@ -155,6 +175,9 @@ async function handleVercelStream(req, res, rawBody, payload) {
         await releaseLease();
         return;
       }
+      if (citations) {
+        handleText(citations.flush());
+      }
       const detected = parseToolCalls(outputText, toolNames);
       if (detected.length > 0 && !toolCallsEmitted) {
         toolCallsEmitted = true;
@@ -170,14 +193,7 @@ async function handleVercelStream(req, res, rawBody, payload) {
       if (detected.length > 0 || toolCallsEmitted) {
         reason = 'tool_calls';
       }
-      sendFrame({
-        id: sessionID,
-        object: 'chat.completion.chunk',
-        created,
-        model,
-        choices: [{ delta: {}, index: 0, finish_reason: reason }],
-        usage: buildUsage(finalPrompt, thinkingText, outputText),
-      });
+      sendFinishFrames(reason, buildUsage(finalPrompt, thinkingText, outputText), includeUsage);
       if (!res.writableEnded && !res.destroyed) {
         res.write('data: [DONE]\n\n');
       }
@@ -227,6 +243,9 @@ async function handleVercelStream(req, res, rawBody, payload) {
           }
           const parsed = parseChunkForContent(chunk, thinkingEnabled, currentType);
           currentType = parsed.newType;
+          if (citations) {
+            citations.addSources(parseSearchResults(chunk));
+          }
           if (parsed.finished) {
             await finish('stop');
             return;
@@ -236,39 +255,23 @@ async function handleVercelStream(req, res, rawBody, payload) {
             if (!p.text) {
               continue;
             }
-            if (searchEnabled && isCitation(p.text)) {
-              continue;
-            }
             if (p.type === 'thinking') {
-              if (thinkingEnabled) {
-                thinkingText += p.text;
-                sendDeltaFrame({ reasoning_content: p.text });
-              }
-            } else {
-              outputText += p.text;
-              if (!toolSieveEnabled) {
-                sendDeltaFrame({ content: p.text });
+              if (searchEnabled && isCitation(p.text)) {
                 continue;
               }
-              const events = processToolSieveChunk(toolSieveState, p.text, toolNames);
-              for (const evt of events) {
-                if (evt.type === 'tool_call_deltas' && Array.isArray(evt.deltas) && evt.deltas.length > 0) {
-                  if (!emitEarlyToolDeltas) {
-                    continue;
-                  }
-                  toolCallsEmitted = true;
-                  sendDeltaFrame({ tool_calls: formatIncrementalToolCallDeltas(evt.deltas, streamToolCallIDs) });
-                  continue;
-                }
-                if (evt.type === 'tool_calls') {
-                  toolCallsEmitted = true;
-                  sendDeltaFrame({ tool_calls: formatOpenAIStreamToolCalls(evt.calls) });
-                  continue;
-                }
-                if (evt.text) {
-                  sendDeltaFrame({ content: evt.text });
-                }
+              const shown = thinkingEnabled ? thinkingGate.push(p.text) : '';
+              if (shown) {
+                thinkingText += shown;
+                sendDeltaFrame({ reasoning_content: shown });
               }
+            } else if (citations) {
+              const cited = citations.push(p.text);
+              handleText(cited.text);
+              if (cited.annotations.length > 0) {
+                sendDeltaFrame({ annotations: cited.annotations });
+              }
+            } else {
+              handleText(p.text);
             }
           }
         }
@@ -2,6 +2,7 @@ package monitor
 
 import (
 	"context"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -9,6 +10,14 @@ import (
 	"ds2api/internal/config"
 )
 
+// newTestStore returns an empty store saving to a temporary config file, so
+// tests never write config.json into the package directory.
+func newTestStore(t *testing.T) *config.Store {
+	t.Helper()
+	t.Setenv("DS2API_CONFIG_PATH", filepath.Join(t.TempDir(), "config.json"))
+	return config.NewStore(nil, "")
+}
+
 func TestNotifier_Subscribe(t *testing.T) {
 	notifier := NewNotifier()
 	ctx, cancel := context.WithCancel(context.Background())
@@ -97,7 +106,7 @@ func TestNotifier_GetHistory(t *testing.T) {
 }
 
 func TestMonitor_GetStatus(t *testing.T) {
-	store := config.NewStore(nil, "")
+	store := newTestStore(t)
 	store.Update(func(c *config.Config) error {
 		c.Keys = []string{}
 		c.APIKeys = []config.APIKeyMetadata{}
@@ -121,7 +130,7 @@ func TestMonitor_GetStatus(t *testing.T) {
 }
 
 func TestMonitor_CheckExpirations(t *testing.T) {
-	store := config.NewStore(nil, "")
+	store := newTestStore(t)
 	store.Update(func(c *config.Config) error {
 		c.Keys = []string{}
 		c.APIKeys = []config.APIKeyMetadata{}
@@ -146,13 +155,29 @@ func TestMonitor_CheckExpirations(t *testing.T) {
 	monitor.CheckNow()
 
 	history := notifier.GetHistory()
-	if len(history) != 0 {
-		t.Fatalf("expected 0 notifications, got %d", len(history))
+	if len(history) != 2 {
+		t.Fatalf("expected 2 notifications, got %d", len(history))
+	}
+	if history[0].Type != config.NotificationTypeWarning || history[1].Type != config.NotificationTypeExpired {
+		t.Fatalf("expected a warning then an expiration, got %#v", history)
+	}
+
+	monitor.CheckNow()
+	if len(notifier.GetHistory()) != 2 {
+		t.Fatal("expected repeated checks not to repeat notifications")
+	}
+
+	if _, err := apiKeyManager.RenewAPIKey("sk-expired", time.Time{}); err != nil {
+		t.Fatalf("RenewAPIKey returned error: %v", err)
+	}
+	monitor.CheckNow()
+	if status := monitor.GetStatus(); status["expired_keys"] != 0 || len(notifier.GetHistory()) != 2 {
+		t.Fatalf("expected the renewed key to clear without new notifications, got %#v", status)
 	}
 }
 
 func TestMonitor_SetWarningDays(t *testing.T) {
-	store := config.NewStore(nil, "")
+	store := newTestStore(t)
 	apiKeyManager := config.NewAPIKeyManager(store)
 	notifier := NewNotifier()
 	monitor := NewMonitor(store, apiKeyManager, notifier)
@@ -166,7 +191,7 @@ func TestMonitor_SetWarningDays(t *testing.T) {
 }
 
 func TestMonitor_SetCheckInterval(t *testing.T) {
-	store := config.NewStore(nil, "")
+	store := newTestStore(t)
 	apiKeyManager := config.NewAPIKeyManager(store)
 	notifier := NewNotifier()
 	monitor := NewMonitor(store, apiKeyManager, notifier)
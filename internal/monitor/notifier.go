@@ -22,6 +22,9 @@ type Notifier struct {
 	subscribers map[chan Notification]struct{}
 	history     []Notification
 	maxHistory  int
+	// sent holds the IDs already announced. IDs embed the expiry, so a key
+	// warns once per expiry and again after it is renewed.
+	sent map[string]struct{}
 }
 
 func NewNotifier(maxHistory ...int) *Notifier {
@@ -33,6 +36,7 @@ func NewNotifier(maxHistory ...int) *Notifier {
 		subscribers: make(map[chan Notification]struct{}),
 		history:     make([]Notification, 0),
 		maxHistory:  mh,
+		sent:        make(map[string]struct{}),
 	}
 }
 
@@ -66,13 +70,12 @@ func (n *Notifier) notifyExpiring(keys []config.APIKeyMetadata) {
 		notification := Notification{
 			ID:        key.ID + ":" + key.ExpiresAt.Format(time.RFC3339Nano) + ":warning",
 			Type:      config.NotificationTypeWarning,
-			APIKey:    maskAPIKey(key.Key),
+			APIKey:    maskAPIKey(key),
 			Message:   "API key expiring soon",
 			ExpiresAt: key.ExpiresAt,
 			Timestamp: time.Now(),
 		}
-		n.addToHistory(notification)
-		n.broadcast(notification)
+		n.publish(notification)
 	}
 }
 
@@ -84,13 +87,12 @@ func (n *Notifier) notifyExpired(keys []config.APIKeyMetadata) {
 		notification := Notification{
 			ID:        key.ID + ":" + key.ExpiresAt.Format(time.RFC3339Nano) + ":expired",
 			Type:      config.NotificationTypeExpired,
-			APIKey:    maskAPIKey(key.Key),
+			APIKey:    maskAPIKey(key),
 			Message:   "API key has expired",
 			ExpiresAt: key.ExpiresAt,
 			Timestamp: time.Now(),
 		}
-		n.addToHistory(notification)
-		n.broadcast(notification)
+		n.publish(notification)
 	}
 }
 
@@ -102,6 +104,17 @@ func (n *Notifier) GetHistory() []Notification {
 	return result
 }
 
+// publish records and broadcasts a notification unless its ID was already
+// sent; the monitor re-checks every interval and must not repeat itself.
+func (n *Notifier) publish(notification Notification) {
+	if _, ok := n.sent[notification.ID]; ok {
+		return
+	}
+	n.sent[notification.ID] = struct{}{}
+	n.addToHistory(notification)
+	n.broadcast(notification)
+}
+
 func (n *Notifier) addToHistory(notification Notification) {
 	if len(n.history) < n.maxHistory {
 		n.history = append(n.history, notification)
@@ -124,9 +137,14 @@ func (n *Notifier) broadcast(notification Notification) {
 	}
 }
 
-func maskAPIKey(key string) string {
-	if len(key) <= 17 {
+// maskAPIKey shows the stored prefix of a key, or masks the plaintext of a
+// key that has not been hashed.
+func maskAPIKey(key config.APIKeyMetadata) string {
+	if key.KeyPrefix != "" {
+		return key.KeyPrefix + "****"
+	}
+	if len(key.Key) <= 17 {
 		return "****"
 	}
-	return key[:11] + "****" + key[len(key)-4:]
+	return key.Key[:11] + "****" + key.Key[len(key.Key)-4:]
 }
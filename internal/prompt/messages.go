@@ -82,3 +82,9 @@ func NormalizeContent(v any) string {
 		return string(b)
 	}
 }
+
+// AppendTurns extends an already prepared prompt with an assistant reply and a
+// follow-up user turn, e.g. for a corrective re-prompt.
+func AppendTurns(prepared, assistantText, userText string) string {
+	return prepared + "<｜Assistant｜>" + assistantText + "<｜end▁of▁sentence｜>" + "<｜User｜>" + userText
+}
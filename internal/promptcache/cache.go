@@ -0,0 +1,116 @@
+// Package promptcache tracks recently seen prompt prefixes so usage can report
+// cache creation and cache read token counts the way upstream providers do.
+// It only does accounting: the full prompt is still sent upstream.
+package promptcache
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// TTLDefault is the lifetime of an ephemeral entry ("5m").
+	TTLDefault = 5 * time.Minute
+	// TTLExtended is the optional one hour lifetime ("1h").
+	TTLExtended = time.Hour
+	// MinTokens is the shortest prefix worth caching; shorter breakpoints
+	// are ignored, as upstream providers do.
+	MinTokens = 1024
+
+	sweepInterval = time.Minute
+)
+
+// Usage is the cache accounting for one request. PromptTokens counts the
+// whole prompt on the same scale as the two cache counts, so the uncached
+// remainder is PromptTokens - CreationTokens - ReadTokens.
+type Usage struct {
+	PromptTokens   int
+	CreationTokens int
+	ReadTokens     int
+}
+
+// Uncached returns the prompt tokens that were neither written to nor read
+// from the cache.
+func (u Usage) Uncached() int {
+	n := u.PromptTokens - u.CreationTokens - u.ReadTokens
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+type entry struct {
+	tokens    int
+	ttl       time.Duration
+	expiresAt time.Time
+}
+
+// Cache is an in-memory prefix cache safe for concurrent use. A nil *Cache
+// reports no cache activity.
+type Cache struct {
+	mu        sync.Mutex
+	entries   map[string]entry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func New() *Cache {
+	return &Cache{entries: map[string]entry{}, now: time.Now}
+}
+
+// Apply looks up the longest cached prefix of p, then writes every breakpoint
+// after it. Entries that are read have their lifetime refreshed.
+func (c *Cache) Apply(p *Prefix) Usage {
+	usage := Usage{PromptTokens: p.Tokens()}
+	if c == nil || p == nil {
+		return usage
+	}
+	var marks []Breakpoint
+	for _, bp := range p.Breakpoints() {
+		if bp.Tokens >= MinTokens {
+			marks = append(marks, bp)
+		}
+	}
+	if len(marks) == 0 {
+		return usage
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.sweepLocked(now)
+	hit := -1
+	for i := len(marks) - 1; i >= 0; i-- {
+		if e, ok := c.entries[marks[i].Key]; ok && now.Before(e.expiresAt) {
+			hit = i
+			usage.ReadTokens = e.tokens
+			break
+		}
+	}
+	for i, bp := range marks {
+		if i <= hit {
+			if e, ok := c.entries[bp.Key]; ok {
+				e.expiresAt = now.Add(e.ttl)
+				c.entries[bp.Key] = e
+			}
+			continue
+		}
+		c.entries[bp.Key] = entry{tokens: bp.Tokens, ttl: bp.TTL, expiresAt: now.Add(bp.TTL)}
+	}
+	if last := marks[len(marks)-1]; last.Tokens > usage.ReadTokens {
+		usage.CreationTokens = last.Tokens - usage.ReadTokens
+	}
+	return usage
+}
+
+func (c *Cache) sweepLocked(now time.Time) {
+	if now.Sub(c.lastSweep) < sweepInterval {
+		return
+	}
+	c.lastSweep = now
+	for key, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+}
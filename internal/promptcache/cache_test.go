@@ -0,0 +1,90 @@
+package promptcache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func longSegment(word string) string {
+	return strings.Repeat(word+" ", 1200)
+}
+
+func TestCacheCreatesThenReadsPrefix(t *testing.T) {
+	c := New()
+	now := time.Unix(1_700_000_000, 0)
+	c.now = func() time.Time { return now }
+
+	build := func(question string) *Prefix {
+		p := NewPrefix("caller", "model")
+		p.Add(longSegment("system"))
+		p.Mark(TTLDefault)
+		p.Add(question)
+		return p
+	}
+
+	first := c.Apply(build("first question"))
+	if first.ReadTokens != 0 || first.CreationTokens == 0 || first.CreationTokens >= first.PromptTokens {
+		t.Fatalf("unexpected first usage: %#v", first)
+	}
+	second := c.Apply(build("another question"))
+	if second.ReadTokens != first.CreationTokens || second.CreationTokens != 0 {
+		t.Fatalf("expected a cache read, got %#v", second)
+	}
+	if second.Uncached() != second.PromptTokens-second.ReadTokens {
+		t.Fatalf("unexpected uncached count: %#v", second)
+	}
+
+	other := NewPrefix("other-caller", "model")
+	other.Add(longSegment("system"))
+	other.Mark(TTLDefault)
+	if got := c.Apply(other); got.ReadTokens != 0 {
+		t.Fatalf("cache must not leak across scopes: %#v", got)
+	}
+
+	now = now.Add(TTLDefault + time.Second)
+	if got := c.Apply(build("late question")); got.ReadTokens != 0 || got.CreationTokens == 0 {
+		t.Fatalf("expected the entry to expire, got %#v", got)
+	}
+}
+
+func TestCacheReadsLongestPrefixAndHonorsTTL(t *testing.T) {
+	c := New()
+	now := time.Unix(1_700_000_000, 0)
+	c.now = func() time.Time { return now }
+
+	p := NewPrefix("caller")
+	p.Add(longSegment("tools"))
+	p.Mark(TTLExtended)
+	p.Add(longSegment("history"))
+	p.Mark(TTLDefault)
+	created := c.Apply(p)
+
+	now = now.Add(30 * time.Minute)
+	q := NewPrefix("caller")
+	q.Add(longSegment("tools"))
+	q.Mark(TTLExtended)
+	q.Add(longSegment("history"))
+	q.Mark(TTLDefault)
+	got := c.Apply(q)
+	if got.ReadTokens == 0 || got.ReadTokens >= created.CreationTokens {
+		t.Fatalf("expected only the 1h prefix to survive, got %#v (created %#v)", got, created)
+	}
+	if got.ReadTokens+got.CreationTokens != created.CreationTokens {
+		t.Fatalf("expected the 5m tail to be written again, got %#v", got)
+	}
+}
+
+func TestCacheIgnoresShortPrefixes(t *testing.T) {
+	c := New()
+	p := NewPrefix("caller")
+	p.Add("short system prompt")
+	p.Mark(TTLDefault)
+	if got := c.Apply(p); got.CreationTokens != 0 || got.ReadTokens != 0 || got.PromptTokens == 0 {
+		t.Fatalf("unexpected usage for a short prefix: %#v", got)
+	}
+	var nilCache *Cache
+	if got := nilCache.Apply(p); got.PromptTokens != p.Tokens() {
+		t.Fatalf("nil cache should only report prompt tokens: %#v", got)
+	}
+}
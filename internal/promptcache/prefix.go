@@ -0,0 +1,84 @@
+package promptcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"ds2api/internal/util"
+)
+
+// Prefix accumulates the segments of a prompt in order (tools, system,
+// messages) and records breakpoints where a cached prefix ends. Each
+// breakpoint key chains the hash of everything before it, so two prompts
+// share a key only when their prefixes match exactly.
+type Prefix struct {
+	hash        [sha256.Size]byte
+	tokens      int
+	breakpoints []Breakpoint
+}
+
+// Breakpoint is the end of one cacheable prefix.
+type Breakpoint struct {
+	Key    string
+	Tokens int
+	TTL    time.Duration
+}
+
+// NewPrefix starts a prefix scoped by scope (e.g. caller and model), so
+// entries never match across callers or models.
+func NewPrefix(scope ...string) *Prefix {
+	p := &Prefix{}
+	for _, s := range scope {
+		p.chain([]byte(s))
+	}
+	return p
+}
+
+// Add appends one segment. Segments are hashed in their canonical JSON form
+// and counted with the same tokenizer as the rest of usage.
+func (p *Prefix) Add(segment any) {
+	b, err := json.Marshal(segment)
+	if err != nil {
+		b = []byte(fmt.Sprintf("%v", segment))
+	}
+	p.chain(b)
+	p.tokens += util.CountTokens(fmt.Sprintf("%v", segment))
+}
+
+// Mark records a breakpoint after the segments added so far.
+func (p *Prefix) Mark(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = TTLDefault
+	}
+	p.breakpoints = append(p.breakpoints, Breakpoint{
+		Key:    hex.EncodeToString(p.hash[:]),
+		Tokens: p.tokens,
+		TTL:    ttl,
+	})
+}
+
+// Tokens is the token count of every segment added so far.
+func (p *Prefix) Tokens() int {
+	if p == nil {
+		return 0
+	}
+	return p.tokens
+}
+
+// Breakpoints returns the recorded breakpoints in prompt order.
+func (p *Prefix) Breakpoints() []Breakpoint {
+	if p == nil {
+		return nil
+	}
+	return p.breakpoints
+}
+
+func (p *Prefix) chain(b []byte) {
+	h := sha256.New()
+	h.Write(p.hash[:])
+	h.Write(b)
+	copy(p.hash[:], h.Sum(nil))
+}
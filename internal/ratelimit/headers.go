@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds, at least 1, for
+// the Retry-After header.
+func (d Decision) RetryAfterSeconds() int {
+	secs := int(math.Ceil(d.RetryAfter.Seconds()))
+	if secs < 1 {
+		return 1
+	}
+	return secs
+}
+
+// WriteRejection sets Retry-After and the OpenAI-style x-ratelimit-* headers
+// for a refused request.
+func (d Decision) WriteRejection(h http.Header) {
+	h.Set("Retry-After", strconv.Itoa(d.RetryAfterSeconds()))
+	d.Status.WriteHeaders(h)
+}
+
+// WriteHeaders sets OpenAI's x-ratelimit-* headers for every limited
+// dimension; reset values use Go duration syntax ("6s", "1m0s") as OpenAI
+// does.
+func (s Status) WriteHeaders(h http.Header) {
+	if s.Limits.RequestsPerMinute > 0 {
+		h.Set("x-ratelimit-limit-requests", strconv.Itoa(s.Limits.RequestsPerMinute))
+		h.Set("x-ratelimit-remaining-requests", strconv.Itoa(s.RemainingRequests))
+		h.Set("x-ratelimit-reset-requests", s.ResetRequests.Round(time.Millisecond).String())
+	}
+	if s.Limits.TokensPerMinute > 0 {
+		h.Set("x-ratelimit-limit-tokens", strconv.Itoa(s.Limits.TokensPerMinute))
+		h.Set("x-ratelimit-remaining-tokens", strconv.Itoa(s.RemainingTokens))
+		h.Set("x-ratelimit-reset-tokens", s.ResetTokens.Round(time.Millisecond).String())
+	}
+}
+
+// WriteAnthropicHeaders sets the anthropic-ratelimit-* equivalents, whose
+// reset values are RFC 3339 timestamps.
+func (s Status) WriteAnthropicHeaders(h http.Header) {
+	if s.Limits.RequestsPerMinute > 0 {
+		h.Set("anthropic-ratelimit-requests-limit", strconv.Itoa(s.Limits.RequestsPerMinute))
+		h.Set("anthropic-ratelimit-requests-remaining", strconv.Itoa(s.RemainingRequests))
+		h.Set("anthropic-ratelimit-requests-reset", s.At.Add(s.ResetRequests).UTC().Format(time.RFC3339))
+	}
+	if s.Limits.TokensPerMinute > 0 {
+		h.Set("anthropic-ratelimit-tokens-limit", strconv.Itoa(s.Limits.TokensPerMinute))
+		h.Set("anthropic-ratelimit-tokens-remaining", strconv.Itoa(s.RemainingTokens))
+		h.Set("anthropic-ratelimit-tokens-reset", s.At.Add(s.ResetTokens).UTC().Format(time.RFC3339))
+	}
+}
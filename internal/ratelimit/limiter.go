@@ -0,0 +1,175 @@
+// Package ratelimit enforces per-API-key limits: token buckets for requests
+// and estimated tokens per minute, and a cap on concurrent requests. State is
+// in memory and per process.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"ds2api/internal/config"
+)
+
+// Dimensions a request can be refused on.
+const (
+	ReasonRequests    = "requests"
+	ReasonTokens      = "tokens"
+	ReasonConcurrency = "concurrency"
+)
+
+// concurrencyRetryAfter is suggested when only the concurrency cap is hit;
+// there is no refill schedule to derive it from.
+const concurrencyRetryAfter = time.Second
+
+type Limiter struct {
+	mu   sync.Mutex
+	keys map[string]*keyState
+	now  func() time.Time
+}
+
+type keyState struct {
+	requests bucket
+	tokens   bucket
+	inflight int
+}
+
+// bucket refills its whole capacity once per minute.
+type bucket struct {
+	available float64
+	capacity  int
+	updated   time.Time
+}
+
+// Status is the budget of one key at a point in time. Zero limits are
+// unlimited and report no remaining budget or reset.
+type Status struct {
+	Limits            config.RateLimitConfig
+	RemainingRequests int
+	RemainingTokens   int
+	ResetRequests     time.Duration
+	ResetTokens       time.Duration
+	Inflight          int
+	At                time.Time
+}
+
+// Decision is the outcome of Acquire. On refusal Reason names the exhausted
+// dimension and RetryAfter when it frees up.
+type Decision struct {
+	Status
+	Allowed    bool
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func New() *Limiter {
+	return &Limiter{keys: map[string]*keyState{}, now: time.Now}
+}
+
+// Acquire charges one request and tokens estimated tokens to id. With hold
+// set it also takes a concurrency slot that Release must return. A refused
+// request charges nothing.
+func (l *Limiter) Acquire(id string, limits config.RateLimitConfig, tokens int, hold bool) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	st := l.stateLocked(id, limits, now)
+	if limits.TokensPerMinute > 0 && tokens > limits.TokensPerMinute {
+		// A request larger than the whole bucket waits for a full bucket
+		// instead of being refused forever.
+		tokens = limits.TokensPerMinute
+	}
+
+	d := Decision{Allowed: true}
+	switch {
+	case hold && limits.MaxConcurrent > 0 && st.inflight >= limits.MaxConcurrent:
+		d = Decision{Reason: ReasonConcurrency, RetryAfter: concurrencyRetryAfter}
+	case limits.RequestsPerMinute > 0 && st.requests.available < 1:
+		d = Decision{Reason: ReasonRequests, RetryAfter: st.requests.wait(1)}
+	case limits.TokensPerMinute > 0 && st.tokens.available < float64(tokens):
+		d = Decision{Reason: ReasonTokens, RetryAfter: st.tokens.wait(tokens)}
+	}
+	if d.Allowed {
+		if limits.RequestsPerMinute > 0 {
+			st.requests.available--
+		}
+		if limits.TokensPerMinute > 0 {
+			st.tokens.available -= float64(tokens)
+		}
+		if hold {
+			st.inflight++
+		}
+	}
+	d.Status = st.status(limits, now)
+	return d
+}
+
+// Release returns a concurrency slot taken by Acquire with hold set.
+func (l *Limiter) Release(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if st, ok := l.keys[id]; ok && st.inflight > 0 {
+		st.inflight--
+	}
+}
+
+// Status reports the current budget of id without charging it.
+func (l *Limiter) Status(id string, limits config.RateLimitConfig) Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	return l.stateLocked(id, limits, now).status(limits, now)
+}
+
+func (l *Limiter) stateLocked(id string, limits config.RateLimitConfig, now time.Time) *keyState {
+	st, ok := l.keys[id]
+	if !ok {
+		st = &keyState{}
+		l.keys[id] = st
+	}
+	st.requests.refill(limits.RequestsPerMinute, now)
+	st.tokens.refill(limits.TokensPerMinute, now)
+	return st
+}
+
+func (s *keyState) status(limits config.RateLimitConfig, now time.Time) Status {
+	out := Status{Limits: limits, Inflight: s.inflight, At: now}
+	if limits.RequestsPerMinute > 0 {
+		out.RemainingRequests = int(math.Floor(s.requests.available))
+		out.ResetRequests = s.requests.wait(limits.RequestsPerMinute)
+	}
+	if limits.TokensPerMinute > 0 {
+		out.RemainingTokens = int(math.Floor(s.tokens.available))
+		out.ResetTokens = s.tokens.wait(limits.TokensPerMinute)
+	}
+	return out
+}
+
+// refill tops the bucket up for the time since the last call. A new bucket
+// starts full; a resized one keeps its balance, capped to the new size.
+func (b *bucket) refill(capacity int, now time.Time) {
+	if capacity <= 0 {
+		*b = bucket{}
+		return
+	}
+	if b.updated.IsZero() {
+		*b = bucket{available: float64(capacity), capacity: capacity, updated: now}
+		return
+	}
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.available += elapsed * float64(capacity) / 60
+	}
+	b.capacity = capacity
+	b.available = math.Min(b.available, float64(capacity))
+	b.updated = now
+}
+
+// wait is how long until n units are available.
+func (b *bucket) wait(n int) time.Duration {
+	missing := float64(n) - b.available
+	if missing <= 0 || b.capacity <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(missing * 60 / float64(b.capacity) * float64(time.Second)))
+}
@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"ds2api/internal/config"
+)
+
+func newTestLimiter(now *time.Time) *Limiter {
+	l := New()
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestAcquireRefillsRequestsPerMinute(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newTestLimiter(&now)
+	limits := config.RateLimitConfig{RequestsPerMinute: 2}
+
+	for i := 0; i < 2; i++ {
+		if d := l.Acquire("k", limits, 0, false); !d.Allowed {
+			t.Fatalf("request %d: expected allowed, got %+v", i, d)
+		}
+	}
+	d := l.Acquire("k", limits, 0, false)
+	if d.Allowed || d.Reason != ReasonRequests {
+		t.Fatalf("expected a requests refusal, got %+v", d)
+	}
+	if d.RetryAfter != 30*time.Second || d.RetryAfterSeconds() != 30 {
+		t.Fatalf("expected 30s until one request refills, got %v", d.RetryAfter)
+	}
+	if d.RemainingRequests != 0 || d.ResetRequests != time.Minute {
+		t.Fatalf("unexpected status %+v", d.Status)
+	}
+
+	now = now.Add(30 * time.Second)
+	if d := l.Acquire("k", limits, 0, false); !d.Allowed {
+		t.Fatalf("expected a refilled request to pass, got %+v", d)
+	}
+	if st := l.Status("other", limits); st.RemainingRequests != 2 {
+		t.Fatalf("expected keys to have separate buckets, got %+v", st)
+	}
+}
+
+func TestAcquireChargesEstimatedTokens(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newTestLimiter(&now)
+	limits := config.RateLimitConfig{TokensPerMinute: 1000}
+
+	if d := l.Acquire("k", limits, 800, false); !d.Allowed || d.RemainingTokens != 200 {
+		t.Fatalf("expected 200 tokens left, got %+v", d)
+	}
+	d := l.Acquire("k", limits, 500, false)
+	if d.Allowed || d.Reason != ReasonTokens || d.RetryAfter != 18*time.Second {
+		t.Fatalf("expected an 18s tokens refusal, got %+v", d)
+	}
+	if st := l.Status("k", limits); st.RemainingTokens != 200 {
+		t.Fatalf("a refused request must not be charged, got %+v", st)
+	}
+
+	// Oversized requests wait for a full bucket instead of failing forever.
+	now = now.Add(time.Minute)
+	if d := l.Acquire("k", limits, 5000, false); !d.Allowed || d.RemainingTokens != 0 {
+		t.Fatalf("expected an oversized request to drain a full bucket, got %+v", d)
+	}
+}
+
+func TestAcquireHoldsConcurrencySlots(t *testing.T) {
+	now := time.Now()
+	l := newTestLimiter(&now)
+	limits := config.RateLimitConfig{MaxConcurrent: 1}
+
+	if d := l.Acquire("k", limits, 0, true); !d.Allowed || d.Inflight != 1 {
+		t.Fatalf("expected the first slot, got %+v", d)
+	}
+	if d := l.Acquire("k", limits, 0, true); d.Allowed || d.Reason != ReasonConcurrency || d.RetryAfterSeconds() != 1 {
+		t.Fatalf("expected a concurrency refusal, got %+v", d)
+	}
+	if d := l.Acquire("k", limits, 0, false); !d.Allowed {
+		t.Fatalf("requests that hold no slot ignore the cap, got %+v", d)
+	}
+	l.Release("k")
+	if d := l.Acquire("k", limits, 0, true); !d.Allowed {
+		t.Fatalf("expected the released slot to be reusable, got %+v", d)
+	}
+}
+
+func TestWriteHeaders(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newTestLimiter(&now)
+	limits := config.RateLimitConfig{RequestsPerMinute: 1, TokensPerMinute: 600}
+	l.Acquire("k", limits, 60, false)
+	d := l.Acquire("k", limits, 60, false)
+
+	h := http.Header{}
+	d.WriteRejection(h)
+	d.WriteAnthropicHeaders(h)
+	want := map[string]string{
+		"Retry-After":                            "60",
+		"x-ratelimit-limit-requests":             "1",
+		"x-ratelimit-remaining-requests":         "0",
+		"x-ratelimit-reset-requests":             "1m0s",
+		"x-ratelimit-limit-tokens":               "600",
+		"x-ratelimit-remaining-tokens":           "540",
+		"x-ratelimit-reset-tokens":               "6s",
+		"anthropic-ratelimit-requests-remaining": "0",
+		"anthropic-ratelimit-requests-reset":     "2026-01-01T00:01:00Z",
+		"anthropic-ratelimit-tokens-limit":       "600",
+	}
+	for name, value := range want {
+		if got := h.Get(name); got != value {
+			t.Fatalf("%s: expected %q, got %q", name, value, got)
+		}
+	}
+
+	h = http.Header{}
+	Status{Limits: config.RateLimitConfig{MaxConcurrent: 2}}.WriteHeaders(h)
+	if len(h) != 0 {
+		t.Fatalf("unlimited dimensions must not emit headers, got %v", h)
+	}
+}
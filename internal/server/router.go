@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -13,12 +14,15 @@ import (
 	"ds2api/internal/account"
 	"ds2api/internal/adapter/claude"
 	"ds2api/internal/adapter/gemini"
+	"ds2api/internal/adapter/ollama"
 	"ds2api/internal/adapter/openai"
 	"ds2api/internal/admin"
 	"ds2api/internal/auth"
 	"ds2api/internal/config"
 	"ds2api/internal/deepseek"
 	"ds2api/internal/monitor"
+	"ds2api/internal/promptcache"
+	"ds2api/internal/usage"
 	"ds2api/internal/webui"
 )
 
@@ -30,8 +34,11 @@ type App struct {
 	Router   http.Handler
 }
 
-func NewApp() *App {
+func NewApp() (*App, error) {
 	store := config.LoadStore()
+	if err := store.CheckThinkingSecret(); err != nil {
+		return nil, err
+	}
 	pool := account.NewPool(store)
 	var dsClient *deepseek.Client
 	resolver := auth.NewResolver(store, pool, func(ctx context.Context, acc config.Account) (string, error) {
@@ -44,15 +51,27 @@ func NewApp() *App {
 		config.Logger.Info("[WASM] module preloaded", "path", config.WASMPath())
 	}
 
+	usageLedger := openUsageLedger()
+	resolver.Usage = usageLedger
+	store.OnAPIKeyIDRenames(usageLedger.RenameKeys)
+
 	apiKeyManager := config.NewAPIKeyManager(store)
 	notifier := monitor.NewNotifier()
 	monitorService := monitor.NewMonitor(store, apiKeyManager, notifier)
 
 	go monitorService.Start(context.Background())
 
-	openaiHandler := &openai.Handler{Store: store, Auth: resolver, DS: dsClient}
-	claudeHandler := &claude.Handler{Store: store, Auth: resolver, DS: dsClient}
+	promptCache := promptcache.New()
+	openaiHandler := &openai.Handler{Store: store, Auth: resolver, DS: dsClient, PromptCache: promptCache}
+	if !config.IsVercel() {
+		go openaiHandler.RunBatches(context.Background())
+	}
+	claudeHandler := &claude.Handler{Store: store, Auth: resolver, DS: dsClient, PromptCache: promptCache}
+	if !config.IsVercel() {
+		go claudeHandler.RunMessageBatches(context.Background())
+	}
 	geminiHandler := &gemini.Handler{Store: store, Auth: resolver, DS: dsClient}
+	ollamaHandler := &ollama.Handler{Store: store, Auth: resolver, DS: dsClient}
 	adminHandler := &admin.Handler{
 		Store:         store,
 		Pool:          pool,
@@ -60,6 +79,8 @@ func NewApp() *App {
 		APIKeyManager: apiKeyManager,
 		Monitor:       monitorService,
 		Notifier:      notifier,
+		RateLimiter:   resolver.Limiter,
+		Usage:         usageLedger,
 	}
 	webuiHandler := webui.NewHandler()
 	metrics := newRequestMetrics()
@@ -92,9 +113,13 @@ func NewApp() *App {
 	r.Get("/metrics", func(w http.ResponseWriter, r *http.Request) {
 		metrics.handleMetrics(w, r, pool.Status())
 	})
-	openai.RegisterRoutes(r, openaiHandler)
-	claude.RegisterRoutes(r, claudeHandler)
-	gemini.RegisterRoutes(r, geminiHandler)
+	r.Group(func(br chi.Router) {
+		br.Use(usage.Middleware(usageLedger))
+		openai.RegisterRoutes(br, openaiHandler)
+		claude.RegisterRoutes(br, claudeHandler)
+		gemini.RegisterRoutes(br, geminiHandler)
+		ollama.RegisterRoutes(br, ollamaHandler)
+	})
 	r.Route("/admin", func(ar chi.Router) {
 		admin.RegisterRoutes(ar, adminHandler)
 	})
@@ -106,7 +131,23 @@ func NewApp() *App {
 		http.NotFound(w, req)
 	})
 
-	return &App{Store: store, Pool: pool, Resolver: resolver, DS: dsClient, Router: r}
+	return &App{Store: store, Pool: pool, Resolver: resolver, DS: dsClient, Router: r}, nil
+}
+
+// openUsageLedger opens the usage ledger under DataDir()/usage. Vercel has
+// no persistent disk, and a ledger that fails to load falls back to memory
+// rather than keeping the service down.
+func openUsageLedger() *usage.Ledger {
+	dir := filepath.Join(config.DataDir(), "usage")
+	if config.IsVercel() {
+		dir = ""
+	}
+	ledger, err := usage.Open(dir)
+	if err != nil {
+		config.Logger.Warn("[usage] ledger unavailable, keeping usage in memory", "dir", dir, "error", err)
+		ledger, _ = usage.Open("")
+	}
+	return ledger
 }
 
 func timeout(d time.Duration) func(http.Handler) http.Handler {
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"ds2api/internal/deepseek"
+	"ds2api/internal/util"
 )
 
 // CollectResult holds the aggregated text and thinking content from a
@@ -12,6 +13,17 @@ import (
 type CollectResult struct {
 	Text     string
 	Thinking string
+	// FinishReason is util.OutputFinishStopSequence or util.OutputFinishMaxTokens
+	// when the stop policy cut the stream short, util.OutputFinishContentFilter
+	// or util.OutputFinishUpstreamError when the upstream ended it, and empty
+	// otherwise.
+	FinishReason string
+	StopSequence string
+	// ErrorMessage is the upstream error behind util.OutputFinishUpstreamError.
+	ErrorMessage string
+	// SearchResults lists the web search hits of *-search models in the
+	// order they were reported.
+	SearchResults []util.SearchResult
 }
 
 // CollectStream fully consumes a DeepSeek SSE response and separates
@@ -21,11 +33,21 @@ type CollectResult struct {
 //
 // The caller is responsible for closing resp.Body unless closeBody is true.
 func CollectStream(resp *http.Response, thinkingEnabled bool, closeBody bool) CollectResult {
+	return CollectStreamWithPolicy(resp, thinkingEnabled, closeBody, util.StopPolicy{})
+}
+
+// CollectStreamWithPolicy is CollectStream with local enforcement of stop
+// sequences and max output tokens. Reading stops as soon as the policy is
+// satisfied so the upstream account is released early.
+func CollectStreamWithPolicy(resp *http.Response, thinkingEnabled bool, closeBody bool, policy util.StopPolicy) CollectResult {
 	if closeBody {
 		defer resp.Body.Close()
 	}
+	limiter := util.NewOutputLimiter(policy)
 	text := strings.Builder{}
 	thinking := strings.Builder{}
+	var searchResults []util.SearchResult
+	upstreamFinish, errorMessage := util.OutputFinishNone, ""
 	currentType := "text"
 	if thinkingEnabled {
 		currentType = "thinking"
@@ -36,17 +58,38 @@ func CollectStream(resp *http.Response, thinkingEnabled bool, closeBody bool) Co
 		if !result.Parsed {
 			return true
 		}
+		searchResults = append(searchResults, result.SearchResults...)
+		if result.ContentFilter {
+			upstreamFinish = util.OutputFinishContentFilter
+		} else if result.ErrorMessage != "" {
+			upstreamFinish, errorMessage = util.OutputFinishUpstreamError, result.ErrorMessage
+		}
 		if result.Stop {
 			return false
 		}
 		for _, p := range result.Parts {
 			if p.Type == "thinking" {
-				thinking.WriteString(p.Text)
+				thinking.WriteString(limiter.PushThinking(p.Text))
 			} else {
-				text.WriteString(p.Text)
+				text.WriteString(limiter.PushText(p.Text))
+			}
+			if limiter.Done() {
+				return false
 			}
 		}
 		return true
 	})
-	return CollectResult{Text: text.String(), Thinking: thinking.String()}
+	text.WriteString(limiter.Flush())
+	finish := limiter.FinishReason()
+	if finish == util.OutputFinishNone {
+		finish = upstreamFinish
+	}
+	return CollectResult{
+		Text:          text.String(),
+		Thinking:      thinking.String(),
+		FinishReason:  finish,
+		StopSequence:  limiter.MatchedSequence(),
+		ErrorMessage:  errorMessage,
+		SearchResults: searchResults,
+	}
 }
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+
+	"ds2api/internal/util"
 )
 
 // ─── CollectStream edge cases ────────────────────────────────────────
@@ -138,3 +140,33 @@ func TestCollectStreamStatusFinished(t *testing.T) {
 		t.Fatalf("expected 'Hello', got %q", result.Text)
 	}
 }
+
+func TestCollectStreamWithPolicyStopsAtSequence(t *testing.T) {
+	resp := makeHTTPResponse(
+		"data: {\"p\":\"response/content\",\"v\":\"answer EN\"}\n" +
+			"data: {\"p\":\"response/content\",\"v\":\"D trailing\"}\n" +
+			"data: {\"p\":\"response/content\",\"v\":\" never read\"}\n" +
+			"data: [DONE]\n",
+	)
+	result := CollectStreamWithPolicy(resp, false, false, util.StopPolicy{Sequences: []string{"END"}})
+	if result.Text != "answer " {
+		t.Fatalf("expected text cut before stop sequence, got %q", result.Text)
+	}
+	if result.FinishReason != util.OutputFinishStopSequence || result.StopSequence != "END" {
+		t.Fatalf("unexpected finish: %q %q", result.FinishReason, result.StopSequence)
+	}
+}
+
+func TestCollectStreamWithPolicyMaxTokens(t *testing.T) {
+	resp := makeHTTPResponse(
+		"data: {\"p\":\"response/content\",\"v\":\"" + strings.Repeat("x", 100) + "\"}\n" +
+			"data: [DONE]\n",
+	)
+	result := CollectStreamWithPolicy(resp, false, false, util.StopPolicy{MaxTokens: 5})
+	if util.EstimateTokens(result.Text) > 5 {
+		t.Fatalf("expected text within 5 tokens, got %q", result.Text)
+	}
+	if result.FinishReason != util.OutputFinishMaxTokens {
+		t.Fatalf("expected max_tokens finish, got %q", result.FinishReason)
+	}
+}
@@ -1,6 +1,10 @@
 package sse
 
-import "fmt"
+import (
+	"fmt"
+
+	"ds2api/internal/util"
+)
 
 // LineResult is the normalized parse result for one DeepSeek SSE line.
 type LineResult struct {
@@ -9,6 +13,9 @@ type LineResult struct {
 	ContentFilter bool
 	ErrorMessage  string
 	Parts         []ContentPart
+	// SearchResults carries web search hits reported by *-search models; the
+	// adapters map [citation:N] markers in the text onto them.
+	SearchResults []util.SearchResult
 	NextType      string
 }
 
@@ -41,9 +48,10 @@ func ParseDeepSeekContentLine(raw []byte, thinkingEnabled bool, currentType stri
 	}
 	parts, finished, nextType := ParseSSEChunkForContent(chunk, thinkingEnabled, currentType)
 	return LineResult{
-		Parsed:   true,
-		Stop:     finished,
-		Parts:    parts,
-		NextType: nextType,
+		Parsed:        true,
+		Stop:          finished,
+		Parts:         parts,
+		SearchResults: parseSearchResults(chunk),
+		NextType:      nextType,
 	}
 }
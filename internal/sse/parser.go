@@ -95,6 +95,8 @@ func collectDirectFragments(path string, chunk map[string]any, v any, newType *s
 		case "RESPONSE":
 			*newType = "text"
 			appendContentPart(parts, content, "text")
+		case "SEARCH":
+			// Search hits are surfaced through LineResult.SearchResults.
 		default:
 			appendContentPart(parts, content, "text")
 		}
@@ -0,0 +1,103 @@
+package sse
+
+import (
+	"strings"
+
+	"ds2api/internal/util"
+)
+
+// parseSearchResults pulls web search hits out of a DeepSeek chunk. Search
+// models report them on response/search_results (older payloads) or as the
+// results of a SEARCH fragment; the content parser ignores both.
+func parseSearchResults(chunk map[string]any) []util.SearchResult {
+	path, _ := chunk["p"].(string)
+	return searchResultsAt(path, chunk["v"])
+}
+
+func searchResultsAt(path string, v any) []util.SearchResult {
+	switch {
+	case path == "response/search_results" || isFragmentResultsPath(path):
+		items, _ := v.([]any)
+		return searchResultsFromList(items)
+	case path == "response/fragments":
+		frags, _ := v.([]any)
+		return searchResultsFromFragments(frags)
+	case path == "response":
+		// Batched patches: {"p":"response","o":"BATCH","v":[{"p":"search_results","v":[...]}]}
+		items, _ := v.([]any)
+		var out []util.SearchResult
+		for _, it := range items {
+			m, ok := it.(map[string]any)
+			if !ok {
+				continue
+			}
+			itemPath, _ := m["p"].(string)
+			out = append(out, searchResultsAt("response/"+itemPath, m["v"])...)
+		}
+		return out
+	case path == "":
+		// Initial snapshot: {"v":{"response":{"search_results":[...],"fragments":[...]}}}
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		if wrapped, ok := m["response"].(map[string]any); ok {
+			m = wrapped
+		}
+		items, _ := m["search_results"].([]any)
+		out := searchResultsFromList(items)
+		frags, _ := m["fragments"].([]any)
+		return append(out, searchResultsFromFragments(frags)...)
+	}
+	return nil
+}
+
+func isFragmentResultsPath(path string) bool {
+	return strings.HasPrefix(path, "response/fragments/") && strings.HasSuffix(path, "/results")
+}
+
+func searchResultsFromFragments(frags []any) []util.SearchResult {
+	var out []util.SearchResult
+	for _, frag := range frags {
+		m, ok := frag.(map[string]any)
+		if !ok {
+			continue
+		}
+		if typeName, _ := m["type"].(string); !strings.EqualFold(typeName, "SEARCH") {
+			continue
+		}
+		items, _ := m["results"].([]any)
+		out = append(out, searchResultsFromList(items)...)
+	}
+	return out
+}
+
+func searchResultsFromList(items []any) []util.SearchResult {
+	out := make([]util.SearchResult, 0, len(items))
+	for _, it := range items {
+		m, ok := it.(map[string]any)
+		if !ok {
+			continue
+		}
+		url, _ := m["url"].(string)
+		if strings.TrimSpace(url) == "" {
+			continue
+		}
+		title, _ := m["title"].(string)
+		snippet, _ := m["snippet"].(string)
+		index := 0
+		if n, ok := m["cite_index"].(float64); ok && n > 0 {
+			index = int(n)
+		}
+		out = append(out, util.SearchResult{
+			Index:   index,
+			URL:     url,
+			Title:   strings.TrimSpace(title),
+			Snippet: strings.TrimSpace(snippet),
+		})
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
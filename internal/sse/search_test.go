@@ -0,0 +1,28 @@
+package sse
+
+import "testing"
+
+func TestParseDeepSeekContentLineCapturesSearchResults(t *testing.T) {
+	res := ParseDeepSeekContentLine([]byte(`data: {"p":"response/search_results","v":[{"url":"https://a.example","title":" A ","snippet":"first","cite_index":1},{"url":"","title":"skip"}]}`), false, "text")
+	if !res.Parsed || len(res.Parts) != 0 {
+		t.Fatalf("search results must not leak as content: %#v", res)
+	}
+	if len(res.SearchResults) != 1 || res.SearchResults[0].Title != "A" || res.SearchResults[0].Index != 1 {
+		t.Fatalf("unexpected search results: %#v", res.SearchResults)
+	}
+}
+
+func TestParseDeepSeekContentLineCapturesSearchFragments(t *testing.T) {
+	res := ParseDeepSeekContentLine([]byte(`data: {"p":"response/fragments","o":"APPEND","v":[{"type":"SEARCH","content":"","results":[{"url":"https://b.example","title":"B"}]}]}`), false, "text")
+	if len(res.Parts) != 0 || len(res.SearchResults) != 1 || res.SearchResults[0].URL != "https://b.example" {
+		t.Fatalf("unexpected fragment parse: %#v", res)
+	}
+	res = ParseDeepSeekContentLine([]byte(`data: {"p":"response/fragments/-1/results","o":"SET","v":[{"url":"https://c.example","cite_index":4}]}`), false, "text")
+	if len(res.SearchResults) != 1 || res.SearchResults[0].Index != 4 {
+		t.Fatalf("unexpected results patch: %#v", res.SearchResults)
+	}
+	res = ParseDeepSeekContentLine([]byte(`data: {"p":"response","o":"BATCH","v":[{"p":"search_results","v":[{"url":"https://d.example"}]}]}`), false, "text")
+	if len(res.SearchResults) != 1 || res.SearchResults[0].URL != "https://d.example" {
+		t.Fatalf("unexpected batched results: %#v", res.SearchResults)
+	}
+}
@@ -110,10 +110,16 @@ func (r *Runner) caseConfigWriteIsolated(ctx context.Context, cc *caseContext) e
 		return err
 	}
 	cc.assert("add_key_status_200", add.StatusCode == http.StatusOK, fmt.Sprintf("status=%d", add.StatusCode))
+	// Keys are stored hashed, so the isolated config is checked for the ID.
+	var added struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(add.Body, &added)
+	cc.assert("add_key_returns_id", added.ID != "", fmt.Sprintf("body=%s", string(add.Body)))
 
 	cfg1, err := cc.request(ctx, requestSpec{
 		Method: http.MethodGet,
-		Path:   "/admin/config",
+		Path:   "/admin/keys/metadata",
 		Headers: map[string]string{
 			"Authorization": "Bearer " + r.adminJWT,
 		},
@@ -122,7 +128,7 @@ func (r *Runner) caseConfigWriteIsolated(ctx context.Context, cc *caseContext) e
 	if err != nil {
 		return err
 	}
-	containsAdded := strings.Contains(string(cfg1.Body), k)
+	containsAdded := added.ID != "" && strings.Contains(string(cfg1.Body), added.ID)
 	cc.assert("key_present_in_isolated_config", containsAdded, "added key not found in isolated config")
 
 	delPath := "/admin/keys/" + url.PathEscape(k)
@@ -141,7 +147,7 @@ func (r *Runner) caseConfigWriteIsolated(ctx context.Context, cc *caseContext) e
 
 	cfg2, err := cc.request(ctx, requestSpec{
 		Method: http.MethodGet,
-		Path:   "/admin/config",
+		Path:   "/admin/keys/metadata",
 		Headers: map[string]string{
 			"Authorization": "Bearer " + r.adminJWT,
 		},
@@ -150,7 +156,7 @@ func (r *Runner) caseConfigWriteIsolated(ctx context.Context, cc *caseContext) e
 	if err != nil {
 		return err
 	}
-	cc.assert("key_removed_in_isolated_config", !strings.Contains(string(cfg2.Body), k), "temporary key still present")
+	cc.assert("key_removed_in_isolated_config", !strings.Contains(string(cfg2.Body), added.ID), "temporary key still present")
 
 	if err := r.ensureOriginalConfigUntouched(); err != nil {
 		cc.assert("original_config_unchanged", false, err.Error())
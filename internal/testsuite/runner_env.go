@@ -106,6 +106,17 @@ func preflightSteps() [][]string {
 	}
 }
 
+// withRunAPIKey returns the config raw with key added to its legacy keys list.
+func withRunAPIKey(raw []byte, key string) ([]byte, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	keys, _ := doc["keys"].([]any)
+	doc["keys"] = append(keys, key)
+	return json.MarshalIndent(doc, "", "  ")
+}
+
 func (r *Runner) prepareConfigIsolation() error {
 	abs, err := filepath.Abs(r.opts.ConfigPath)
 	if err != nil {
@@ -123,18 +134,23 @@ func (r *Runner) prepareConfigIsolation() error {
 	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
 		return err
 	}
-	r.configCopyPath = filepath.Join(tmpDir, "config.json")
-	if err := os.WriteFile(r.configCopyPath, raw, 0o644); err != nil {
-		return err
-	}
 	var cfg runConfig
 	if err := json.Unmarshal(raw, &cfg); err != nil {
 		return fmt.Errorf("parse config failed: %w", err)
 	}
-	r.configRaw = cfg
-	if len(cfg.Keys) > 0 {
-		r.apiKey = strings.TrimSpace(cfg.Keys[0])
+	// Configured keys are stored hashed, so the run adds a key of its own to
+	// the isolated copy; the server hashes it on load.
+	r.apiKey = "testsuite-run-" + sanitizeID(r.runID)
+	copyRaw, err := withRunAPIKey(raw, r.apiKey)
+	if err != nil {
+		return fmt.Errorf("prepare config copy failed: %w", err)
 	}
+	r.configCopyPath = filepath.Join(tmpDir, "config.json")
+	if err := os.WriteFile(r.configCopyPath, copyRaw, 0o644); err != nil {
+		return err
+	}
+	cfg.Keys = append(cfg.Keys, r.apiKey)
+	r.configRaw = cfg
 	for _, acc := range cfg.Accounts {
 		id := strings.TrimSpace(acc.Email)
 		if id == "" {
@@ -0,0 +1,282 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const wordCacheLimit = 1 << 16
+
+// Tokenizer is a byte-level BPE tokenizer loaded from a HuggingFace
+// tokenizer.json (the format DeepSeek publishes with its models).
+type Tokenizer struct {
+	vocab   map[string]int
+	tokens  map[int]string
+	ranks   map[[2]string]int
+	added   map[int]string
+	byFirst map[rune][]string
+	addedID map[string]int
+	// maxAdded is the length in bytes of the longest added token.
+	maxAdded int
+
+	cacheMu sync.Mutex
+	cache   map[string][]int
+}
+
+type hfTokenizerFile struct {
+	AddedTokens []struct {
+		ID      int    `json:"id"`
+		Content string `json:"content"`
+	} `json:"added_tokens"`
+	Model struct {
+		Type   string            `json:"type"`
+		Vocab  map[string]int    `json:"vocab"`
+		Merges []json.RawMessage `json:"merges"`
+	} `json:"model"`
+}
+
+// Load parses a HuggingFace tokenizer.json with a BPE model.
+func Load(r io.Reader) (*Tokenizer, error) {
+	var f hfTokenizerFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("decode tokenizer: %w", err)
+	}
+	if f.Model.Type != "" && f.Model.Type != "BPE" {
+		return nil, fmt.Errorf("unsupported tokenizer model %q", f.Model.Type)
+	}
+	if len(f.Model.Vocab) == 0 {
+		return nil, errors.New("tokenizer vocabulary is empty")
+	}
+	t := &Tokenizer{
+		vocab:   f.Model.Vocab,
+		tokens:  make(map[int]string, len(f.Model.Vocab)),
+		ranks:   make(map[[2]string]int, len(f.Model.Merges)),
+		added:   map[int]string{},
+		byFirst: map[rune][]string{},
+		addedID: map[string]int{},
+		cache:   map[string][]int{},
+	}
+	for tok, id := range f.Model.Vocab {
+		t.tokens[id] = tok
+	}
+	for i, raw := range f.Model.Merges {
+		pair, err := parseMerge(raw)
+		if err != nil {
+			return nil, fmt.Errorf("merge %d: %w", i, err)
+		}
+		if _, ok := t.ranks[pair]; !ok {
+			t.ranks[pair] = i
+		}
+	}
+	for _, tok := range f.AddedTokens {
+		if tok.Content == "" {
+			continue
+		}
+		t.added[tok.ID] = tok.Content
+		t.addedID[tok.Content] = tok.ID
+		t.maxAdded = max(t.maxAdded, len(tok.Content))
+		first, _ := utf8.DecodeRuneInString(tok.Content)
+		t.byFirst[first] = append(t.byFirst[first], tok.Content)
+	}
+	for first := range t.byFirst {
+		list := t.byFirst[first]
+		sort.Slice(list, func(i, j int) bool { return len(list[i]) > len(list[j]) })
+	}
+	return t, nil
+}
+
+// Merges are either "a b" strings (older files) or ["a","b"] pairs.
+func parseMerge(raw json.RawMessage) ([2]string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		left, right, ok := strings.Cut(s, " ")
+		if !ok {
+			return [2]string{}, fmt.Errorf("malformed merge %q", s)
+		}
+		return [2]string{left, right}, nil
+	}
+	var pair []string
+	if err := json.Unmarshal(raw, &pair); err != nil || len(pair) != 2 {
+		return [2]string{}, fmt.Errorf("malformed merge %s", string(raw))
+	}
+	return [2]string{pair[0], pair[1]}, nil
+}
+
+// Encode returns the token ids for text. Added (special) tokens appearing in
+// the text are kept whole, as the reference tokenizer does.
+func (t *Tokenizer) Encode(text string) []int {
+	var ids []int
+	for _, piece := range t.split(text) {
+		ids = append(ids, t.encodePiece(piece)...)
+	}
+	return ids
+}
+
+// Count returns the number of tokens in text.
+func (t *Tokenizer) Count(text string) int {
+	n := 0
+	for _, piece := range t.split(text) {
+		n += len(t.encodePiece(piece))
+	}
+	return n
+}
+
+// split cuts text into the pieces encoded independently: added tokens and
+// pre-tokenized words.
+func (t *Tokenizer) split(text string) []string {
+	var pieces []string
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if tok := t.matchAdded(text[i:], r); tok != "" {
+			if start < i {
+				pieces = append(pieces, pretokenize(text[start:i])...)
+			}
+			pieces = append(pieces, tok)
+			i += len(tok)
+			start = i
+			continue
+		}
+		i += size
+	}
+	if start < len(text) {
+		pieces = append(pieces, pretokenize(text[start:])...)
+	}
+	return pieces
+}
+
+func (t *Tokenizer) encodePiece(piece string) []int {
+	if id, ok := t.addedID[piece]; ok {
+		return []int{id}
+	}
+	return t.encodeWord(piece)
+}
+
+// Counter counts the tokens of text that arrives in chunks, such as a
+// streamed reply. Only the last pieces, which later text may still extend,
+// are encoded again on each call.
+type Counter struct {
+	tok     *Tokenizer
+	settled int
+	tail    string
+}
+
+// counterTailPieces is how many trailing pieces stay open: a whitespace run
+// gives up its last space to the word that follows it. Pieces an added token
+// still being received could span stay open as well.
+const counterTailPieces = 2
+
+func (t *Tokenizer) NewCounter() *Counter {
+	return &Counter{tok: t}
+}
+
+// CountWith returns the tokens of the text added so far followed by more,
+// without adding more.
+func (c *Counter) CountWith(more string) int {
+	return c.settled + c.tok.Count(c.tail+more)
+}
+
+// Add appends text.
+func (c *Counter) Add(text string) {
+	s := c.tail + text
+	pieces := c.tok.split(s)
+	limit := len(s) - max(c.tok.maxAdded-1, 0)
+	end := 0
+	for _, piece := range pieces[:max(len(pieces)-counterTailPieces, 0)] {
+		if end+len(piece) > limit {
+			break
+		}
+		end += len(piece)
+		c.settled += len(c.tok.encodePiece(piece))
+	}
+	c.tail = s[end:]
+}
+
+// Decode turns ids back into text. Unknown ids are reported as an error.
+func (t *Tokenizer) Decode(ids []int) (string, error) {
+	var b strings.Builder
+	for _, id := range ids {
+		if tok, ok := t.added[id]; ok {
+			b.WriteString(tok)
+			continue
+		}
+		tok, ok := t.tokens[id]
+		if !ok {
+			return "", fmt.Errorf("unknown token id %d", id)
+		}
+		b.Write(decodeBytes(tok))
+	}
+	return b.String(), nil
+}
+
+func (t *Tokenizer) matchAdded(s string, first rune) string {
+	for _, tok := range t.byFirst[first] {
+		if strings.HasPrefix(s, tok) {
+			return tok
+		}
+	}
+	return ""
+}
+
+func (t *Tokenizer) encodeWord(word string) []int {
+	t.cacheMu.Lock()
+	if cached, ok := t.cache[word]; ok {
+		t.cacheMu.Unlock()
+		return cached
+	}
+	t.cacheMu.Unlock()
+
+	symbols := encodeBytes(word)
+	for len(symbols) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i+1 < len(symbols); i++ {
+			rank, ok := t.ranks[[2]string{symbols[i], symbols[i+1]}]
+			if ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		left, right := symbols[best], symbols[best+1]
+		merged := make([]string, 0, len(symbols)-1)
+		for i := 0; i < len(symbols); i++ {
+			if i+1 < len(symbols) && symbols[i] == left && symbols[i+1] == right {
+				merged = append(merged, left+right)
+				i++
+				continue
+			}
+			merged = append(merged, symbols[i])
+		}
+		symbols = merged
+	}
+
+	ids := make([]int, 0, len(symbols))
+	for _, sym := range symbols {
+		if id, ok := t.vocab[sym]; ok {
+			ids = append(ids, id)
+			continue
+		}
+		// Byte-level vocabularies cover every byte, so this only matters
+		// for a truncated vocabulary: fall back to single-byte symbols.
+		for _, r := range sym {
+			if id, ok := t.vocab[string(r)]; ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	t.cacheMu.Lock()
+	if len(t.cache) >= wordCacheLimit {
+		t.cache = map[string][]int{}
+	}
+	t.cache[word] = ids
+	t.cacheMu.Unlock()
+	return ids
+}
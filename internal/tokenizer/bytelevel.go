@@ -0,0 +1,42 @@
+package tokenizer
+
+// Byte-level BPE vocabularies store tokens as printable runes, one per byte,
+// using the GPT-2 byte-to-unicode table.
+var (
+	byteToRune [256]rune
+	runeToByte = map[rune]byte{}
+)
+
+func init() {
+	n := 0
+	for b := 0; b < 256; b++ {
+		printable := (b >= '!' && b <= '~') || (b >= 0xA1 && b <= 0xAC) || (b >= 0xAE && b <= 0xFF)
+		if printable {
+			byteToRune[b] = rune(b)
+		} else {
+			byteToRune[b] = rune(256 + n)
+			n++
+		}
+		runeToByte[byteToRune[b]] = byte(b)
+	}
+}
+
+func encodeBytes(s string) []string {
+	out := make([]string, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		out = append(out, string(byteToRune[s[i]]))
+	}
+	return out
+}
+
+func decodeBytes(token string) []byte {
+	out := make([]byte, 0, len(token))
+	for _, r := range token {
+		if b, ok := runeToByte[r]; ok {
+			out = append(out, b)
+			continue
+		}
+		out = append(out, string(r)...)
+	}
+	return out
+}
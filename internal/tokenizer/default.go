@@ -0,0 +1,95 @@
+package tokenizer
+
+import (
+	"compress/gzip"
+	"embed"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+
+	"ds2api/internal/config"
+)
+
+//go:generate sh -c "curl -fsSL https://huggingface.co/deepseek-ai/DeepSeek-V3/resolve/main/tokenizer.json | gzip -9n > vocab/tokenizer.json.gz"
+
+// vocabFS holds the gzipped DeepSeek vocabulary built into the binary.
+//
+//go:embed vocab
+var vocabFS embed.FS
+
+const embeddedVocab = "vocab/tokenizer.json.gz"
+
+var (
+	defaultOnce sync.Once
+	defaultTok  *Tokenizer
+)
+
+// overridePath is the tokenizer.json (optionally .gz) named by
+// DS2API_TOKENIZER_PATH, or "" to use the embedded vocabulary.
+func overridePath() string {
+	if strings.TrimSpace(os.Getenv("DS2API_TOKENIZER_PATH")) == "" {
+		return ""
+	}
+	return config.ResolvePath("DS2API_TOKENIZER_PATH", "")
+}
+
+// Default returns the process-wide DeepSeek tokenizer: the vocabulary at
+// DS2API_TOKENIZER_PATH when set, the embedded one otherwise. It is nil only
+// when neither loads; callers then fall back to util.EstimateTokens.
+func Default() *Tokenizer {
+	defaultOnce.Do(func() {
+		if path := overridePath(); path != "" {
+			tok, err := LoadFile(path)
+			if err == nil {
+				defaultTok = tok
+				config.Logger.Info("[tokenizer] vocabulary loaded", "path", path, "tokens", len(tok.tokens)+len(tok.added))
+				return
+			}
+			config.Logger.Warn("[tokenizer] override load failed, using the embedded vocabulary", "path", path, "error", err)
+		}
+		tok, err := loadEmbedded()
+		if err != nil {
+			config.Logger.Warn("[tokenizer] embedded vocabulary unavailable, using estimates", "error", err)
+			return
+		}
+		defaultTok = tok
+	})
+	return defaultTok
+}
+
+func loadEmbedded() (*Tokenizer, error) {
+	f, err := vocabFS.Open(embeddedVocab)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.New("this build has no " + embeddedVocab)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return loadGzip(f)
+}
+
+// LoadFile loads a tokenizer.json, transparently gunzipping *.gz files.
+func LoadFile(path string) (*Tokenizer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.HasSuffix(path, ".gz") {
+		return loadGzip(f)
+	}
+	return Load(f)
+}
+
+func loadGzip(r io.Reader) (*Tokenizer, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return Load(gz)
+}
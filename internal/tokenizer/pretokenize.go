@@ -0,0 +1,156 @@
+package tokenizer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// pretokenize mirrors the DeepSeek-V3 pre-tokenizer sequence: digit runs are
+// isolated in groups of up to three, CJK/kana runs are isolated, and the rest
+// is split with the GPT-4 style word pattern. Go's regexp has no look-ahead,
+// so the word pattern is implemented by hand.
+func pretokenize(text string) []string {
+	var pieces []string
+	for _, seg := range splitIsolated(text, matchDigits) {
+		for _, sub := range splitIsolated(seg, matchCJK) {
+			pieces = append(pieces, splitIsolated(sub, matchWord)...)
+		}
+	}
+	return pieces
+}
+
+// matcher reports the length in bytes of a match starting at s[0], or 0.
+type matcher func(s string) int
+
+// splitIsolated behaves like a HuggingFace Split with "Isolated" behaviour:
+// matches and the gaps between them both become pieces.
+func splitIsolated(s string, match matcher) []string {
+	var out []string
+	gapStart := 0
+	for i := 0; i < len(s); {
+		if n := match(s[i:]); n > 0 {
+			if gapStart < i {
+				out = append(out, s[gapStart:i])
+			}
+			out = append(out, s[i:i+n])
+			i += n
+			gapStart = i
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	if gapStart < len(s) {
+		out = append(out, s[gapStart:])
+	}
+	return out
+}
+
+// matchDigits implements \p{N}{1,3}.
+func matchDigits(s string) int {
+	n := 0
+	for count := 0; count < 3 && n < len(s); count++ {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if !unicode.IsNumber(r) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+// matchCJK implements [一-龥぀-ゟ゠-ヿ]+.
+func matchCJK(s string) int {
+	return spanWhile(s, func(r rune) bool {
+		return (r >= 0x4E00 && r <= 0x9FA5) || (r >= 0x3040 && r <= 0x309F) || (r >= 0x30A0 && r <= 0x30FF)
+	})
+}
+
+// matchWord implements, with leftmost-alternative semantics:
+//
+//	[!-/:-@[-`{-~][A-Za-z]+ | [^\r\n\p{L}\p{P}\p{S}]?[\p{L}\p{M}]+ |
+//	 ?[\p{P}\p{S}]+[\r\n]* | \s*[\r\n]+ | \s+(?!\S) | \s+
+func matchWord(s string) int {
+	if s == "" {
+		return 0
+	}
+	r0, size0 := utf8.DecodeRuneInString(s)
+
+	if isASCIIPunct(r0) {
+		if n := spanWhile(s[size0:], isASCIILetter); n > 0 {
+			return size0 + n
+		}
+	}
+
+	if isLetterOrMark(r0) {
+		return spanWhile(s, isLetterOrMark)
+	}
+	if !isNewline(r0) && !isPunctOrSymbol(r0) {
+		if n := spanWhile(s[size0:], isLetterOrMark); n > 0 {
+			return size0 + n
+		}
+	}
+
+	start := 0
+	if r0 == ' ' {
+		start = size0
+	}
+	if n := spanWhile(s[start:], isPunctOrSymbol); n > 0 {
+		end := start + n
+		return end + spanWhile(s[end:], isNewline)
+	}
+
+	ws := spanWhile(s, unicode.IsSpace)
+	if ws == 0 {
+		return 0
+	}
+	lastNewline := -1
+	for i, r := range s[:ws] {
+		if isNewline(r) {
+			lastNewline = i
+		}
+	}
+	if lastNewline >= 0 {
+		return lastNewline + 1
+	}
+	if ws == len(s) {
+		return ws
+	}
+	_, lastSize := utf8.DecodeLastRuneInString(s[:ws])
+	if ws > lastSize {
+		return ws - lastSize
+	}
+	return ws
+}
+
+func spanWhile(s string, ok func(rune) bool) int {
+	n := 0
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if !ok(r) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+func isASCIIPunct(r rune) bool {
+	return (r >= '!' && r <= '/') || (r >= ':' && r <= '@') || (r >= '[' && r <= '`') || (r >= '{' && r <= '~')
+}
+
+func isASCIILetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isLetterOrMark(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsMark(r)
+}
+
+func isPunctOrSymbol(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+func isNewline(r rune) bool {
+	return r == '\r' || r == '\n'
+}
@@ -0,0 +1,166 @@
+package tokenizer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestTokenizer builds a tiny byte-level vocabulary: every byte symbol plus
+// a handful of merges for "hello", " world" and "12".
+func newTestTokenizer(t *testing.T) *Tokenizer {
+	t.Helper()
+	return mustLoad(t, testTokenizerJSON(t))
+}
+
+func testTokenizerJSON(t *testing.T) []byte {
+	t.Helper()
+	vocab := map[string]int{}
+	for b := 0; b < 256; b++ {
+		vocab[string(byteToRune[b])] = b
+	}
+	merges := [][2]string{{"h", "e"}, {"l", "l"}, {"he", "ll"}, {"hell", "o"}, {"Ġ", "w"}, {"o", "r"}, {"Ġw", "or"}, {"l", "d"}, {"Ġwor", "ld"}, {"1", "2"}}
+	next := 256
+	rawMerges := make([]any, 0, len(merges))
+	for i, m := range merges {
+		vocab[m[0]+m[1]] = next
+		next++
+		if i%2 == 0 {
+			rawMerges = append(rawMerges, m[0]+" "+m[1])
+		} else {
+			rawMerges = append(rawMerges, []string{m[0], m[1]})
+		}
+	}
+	doc := map[string]any{
+		"added_tokens": []any{map[string]any{"id": 1000, "content": "<｜User｜>"}},
+		"model":        map[string]any{"type": "BPE", "vocab": vocab, "merges": rawMerges},
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return b
+}
+
+func mustLoad(t *testing.T, data []byte) *Tokenizer {
+	t.Helper()
+	tok, err := Load(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	return tok
+}
+
+func TestPretokenizeSplitsDigitsCJKAndWords(t *testing.T) {
+	got := pretokenize("Hello, world!  12345 你好ab\n\nx")
+	want := []string{"Hello", ",", " world", "!", "  ", "123", "45", " ", "你好", "ab", "\n\n", "x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected pieces:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestPretokenizeTrailingWhitespaceKeepsLastSpaceForNextWord(t *testing.T) {
+	got := pretokenize("a   b")
+	want := []string{"a", "  ", " b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected pieces: got %q want %q", got, want)
+	}
+}
+
+func TestEncodeAppliesMergesByRank(t *testing.T) {
+	tok := newTestTokenizer(t)
+	ids := tok.Encode("hello world")
+	if len(ids) != 2 {
+		t.Fatalf("expected two merged tokens, got %v", ids)
+	}
+	if tok.Count("hello world 12") != 4 {
+		t.Fatalf("unexpected count %d", tok.Count("hello world 12"))
+	}
+}
+
+func TestEncodeKeepsAddedTokensWhole(t *testing.T) {
+	tok := newTestTokenizer(t)
+	ids := tok.Encode("<｜User｜>hello")
+	if len(ids) != 2 || ids[0] != 1000 {
+		t.Fatalf("expected added token then word, got %v", ids)
+	}
+}
+
+func TestDecodeRoundTripsBytes(t *testing.T) {
+	tok := newTestTokenizer(t)
+	for _, text := range []string{"hello world", "中文 ünïcode\n\ttabs", "<｜User｜>hi 12"} {
+		got, err := tok.Decode(tok.Encode(text))
+		if err != nil {
+			t.Fatalf("decode %q: %v", text, err)
+		}
+		if got != text {
+			t.Fatalf("round trip mismatch: got %q want %q", got, text)
+		}
+	}
+	if _, err := tok.Decode([]int{99999}); err == nil || !strings.Contains(err.Error(), "99999") {
+		t.Fatalf("expected unknown id error, got %v", err)
+	}
+}
+
+func TestLoadFileReadsGzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, _ = gz.Write(testTokenizerJSON(t))
+	_ = gz.Close()
+	path := filepath.Join(t.TempDir(), "tokenizer.json.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	tok, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("load gz: %v", err)
+	}
+	if tok.Count("hello") != 1 {
+		t.Fatalf("expected merged hello, got %v", tok.Encode("hello"))
+	}
+}
+
+func TestLoadRejectsNonBPEModels(t *testing.T) {
+	if _, err := Load(strings.NewReader(`{"model":{"type":"WordPiece","vocab":{"a":0}}}`)); err == nil {
+		t.Fatal("expected error for non-BPE model")
+	}
+}
+
+func TestCounterMatchesCountAcrossChunks(t *testing.T) {
+	tok := newTestTokenizer(t)
+	text := "hello world   12345<｜User｜>hello\n\n  wor" + "ld 你好 hello"
+	for _, size := range []int{1, 2, 3, 7, len(text)} {
+		c := tok.NewCounter()
+		runes := []rune(text)
+		for i := 0; i < len(runes); i += size {
+			c.Add(string(runes[i:min(i+size, len(runes))]))
+		}
+		if got, want := c.CountWith(""), tok.Count(text); got != want {
+			t.Fatalf("chunks of %d runes: counted %d, want %d", size, got, want)
+		}
+	}
+	c := tok.NewCounter()
+	c.Add("hello")
+	if c.CountWith(" world") != 2 || c.CountWith("") != 1 {
+		t.Fatalf("expected CountWith to leave the counter unchanged, got %d", c.CountWith(""))
+	}
+}
+
+func TestEmbeddedVocabularyLoads(t *testing.T) {
+	tok, err := loadEmbedded()
+	if err != nil {
+		t.Fatalf("embedded vocabulary missing, run go generate ./internal/tokenizer: %v", err)
+	}
+	if ids := tok.Encode("<｜begin▁of▁sentence｜>hello world<｜end▁of▁sentence｜>"); len(ids) != 4 || ids[0] != 0 || ids[3] != 1 {
+		t.Fatalf("unexpected DeepSeek encoding %v", ids)
+	}
+	text := "Hello, 世界! 12345"
+	if got, err := tok.Decode(tok.Encode(text)); err != nil || got != text {
+		t.Fatalf("round trip mismatch: got %q err=%v", got, err)
+	}
+}
@@ -0,0 +1,154 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"ds2api/internal/config"
+)
+
+var usageMarkers = [][]byte{[]byte(`"usage`), []byte(`eval_count"`)}
+
+// Middleware records the requests it wraps in l. A request is recorded once
+// auth has billed it to a caller through the context Record; others, such
+// as requests without credentials, are not. Token counts are read from the
+// usage block each protocol already writes, so streamed and buffered
+// responses are covered alike.
+func Middleware(l *Ledger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &Record{}
+			cw := &captureWriter{ResponseWriter: w, rec: rec}
+			start := time.Now()
+			next.ServeHTTP(cw, r.WithContext(WithRecord(r.Context(), rec)))
+			if rec.entry.KeyID == "" {
+				return
+			}
+			rec.entry.Path = r.URL.Path
+			rec.entry.Status = cw.status
+			if rec.entry.Status == 0 {
+				rec.entry.Status = http.StatusOK
+			}
+			rec.entry.LatencyMs = time.Since(start).Milliseconds()
+			if err := l.Record(rec.entry); err != nil {
+				config.Logger.Warn("[usage] failed to record entry", "path", r.URL.Path, "error", err)
+			}
+		})
+	}
+}
+
+// captureWriter passes the response through while picking usage blocks out
+// of JSON bodies, SSE data lines and NDJSON lines. The adapters write each
+// payload object in a single Write.
+type captureWriter struct {
+	http.ResponseWriter
+	rec    *Record
+	status int
+}
+
+func (w *captureWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.scan(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *captureWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *captureWriter) scan(p []byte) {
+	if !bytes.Contains(p, usageMarkers[0]) && !bytes.Contains(p, usageMarkers[1]) {
+		return
+	}
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		line = bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		if input, output, reasoning, ok := extractUsage(line); ok {
+			w.rec.observe(input, output, reasoning)
+		}
+	}
+}
+
+type tokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	InputTokens      int `json:"input_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	OutputTokens     int `json:"output_tokens"`
+	CompletionDetail struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+	OutputDetail struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"output_tokens_details"`
+}
+
+// extractUsage reads token counts from one payload: OpenAI chat, completions
+// and embeddings "usage", Responses "response.usage", Claude "usage" and
+// "message.usage", Gemini "usageMetadata" and Ollama's eval counts.
+func extractUsage(payload []byte) (input, output, reasoning int, ok bool) {
+	var probe struct {
+		Usage    *tokenUsage `json:"usage"`
+		Response *struct {
+			Usage *tokenUsage `json:"usage"`
+		} `json:"response"`
+		Message *struct {
+			Usage *tokenUsage `json:"usage"`
+		} `json:"message"`
+		UsageMetadata *struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+			ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+		} `json:"usageMetadata"`
+		PromptEvalCount *int `json:"prompt_eval_count"`
+		EvalCount       *int `json:"eval_count"`
+	}
+	if json.Unmarshal(payload, &probe) != nil {
+		return 0, 0, 0, false
+	}
+	u := probe.Usage
+	if u == nil && probe.Response != nil {
+		u = probe.Response.Usage
+	}
+	if u == nil && probe.Message != nil {
+		u = probe.Message.Usage
+	}
+	switch {
+	case u != nil:
+		return max(u.PromptTokens, u.InputTokens), max(u.CompletionTokens, u.OutputTokens), max(u.CompletionDetail.ReasoningTokens, u.OutputDetail.ReasoningTokens), true
+	case probe.UsageMetadata != nil:
+		m := probe.UsageMetadata
+		return m.PromptTokenCount, m.CandidatesTokenCount, m.ThoughtsTokenCount, true
+	case probe.PromptEvalCount != nil || probe.EvalCount != nil:
+		if probe.PromptEvalCount != nil {
+			input = *probe.PromptEvalCount
+		}
+		if probe.EvalCount != nil {
+			output = *probe.EvalCount
+		}
+		return input, output, 0, true
+	}
+	return 0, 0, 0, false
+}
@@ -0,0 +1,73 @@
+package usage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExtractUsageReadsEveryProtocol(t *testing.T) {
+	cases := []struct {
+		name                     string
+		payload                  string
+		input, output, reasoning int
+	}{
+		{"openai chat", `{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":8,"completion_tokens_details":{"reasoning_tokens":3}}}`, 12, 8, 3},
+		{"openai responses", `{"type":"response.completed","response":{"usage":{"input_tokens":4,"output_tokens":6,"output_tokens_details":{"reasoning_tokens":2}}}}`, 4, 6, 2},
+		{"claude message_start", `{"type":"message_start","message":{"usage":{"input_tokens":9,"output_tokens":0}}}`, 9, 0, 0},
+		{"claude message_delta", `{"type":"message_delta","usage":{"output_tokens":15}}`, 0, 15, 0},
+		{"gemini", `{"candidates":[],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":7,"thoughtsTokenCount":1}}`, 5, 7, 1},
+		{"ollama", `{"done":true,"message":{"role":"assistant","content":"hi"},"prompt_eval_count":3,"eval_count":2}`, 3, 2, 0},
+	}
+	for _, tc := range cases {
+		input, output, reasoning, ok := extractUsage([]byte(tc.payload))
+		if !ok || input != tc.input || output != tc.output || reasoning != tc.reasoning {
+			t.Fatalf("%s: got %d/%d/%d ok=%v", tc.name, input, output, reasoning, ok)
+		}
+	}
+	if _, _, _, ok := extractUsage([]byte(`{"choices":[{"delta":{"content":"\"usage"}}]}`)); ok {
+		t.Fatal("usage text inside content must not count")
+	}
+}
+
+func TestMiddlewareRecordsBilledRequests(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	l, _ := Open("")
+	l.now = func() time.Time { return now }
+
+	handler := Middleware(l)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		rec := FromContext(r.Context())
+		rec.SetCaller("apikey:a", "claude")
+		rec.SetModel("claude-sonnet-4", "deepseek-chat")
+		rec.SetAccount("a@example.com")
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("event: message_start\ndata: "))
+		_, _ = w.Write([]byte(`{"type":"message_start","message":{"usage":{"input_tokens":9,"output_tokens":0}}}`))
+		_, _ = w.Write([]byte("\n\n"))
+		_, _ = w.Write([]byte("event: message_delta\ndata: "))
+		_, _ = w.Write([]byte(`{"type":"message_delta","usage":{"output_tokens":15}}`))
+		_, _ = w.Write([]byte("\n\n"))
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("expected the capture writer to stay flushable")
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
+	req.Header.Set("Authorization", "Bearer k")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil))
+
+	rows := l.Query(Query{GroupBy: []string{GroupKey, GroupModel, GroupAccount}})
+	if len(rows) != 1 {
+		t.Fatalf("expected only the billed request, got %+v", rows)
+	}
+	row := rows[0]
+	if row.KeyID != "apikey:a" || row.Model != "claude-sonnet-4" || row.Account != "a@example.com" || row.InputTokens != 9 || row.OutputTokens != 15 || row.Errors != 0 {
+		t.Fatalf("unexpected row: %+v", row)
+	}
+}
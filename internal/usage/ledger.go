@@ -0,0 +1,450 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ledgerPrefix = "ledger-"
+	ledgerSuffix = ".jsonl"
+	rollupsFile  = "rollups.jsonl"
+	monthLayout  = "2006-01"
+)
+
+// Bucket sums the entries of one UTC hour that share key, surface, models
+// and account.
+type Bucket struct {
+	Hour            time.Time `json:"hour"`
+	KeyID           string    `json:"key_id"`
+	Surface         string    `json:"surface,omitempty"`
+	Model           string    `json:"model,omitempty"`
+	ResolvedModel   string    `json:"resolved_model,omitempty"`
+	Account         string    `json:"account,omitempty"`
+	Requests        int64     `json:"requests"`
+	Errors          int64     `json:"errors"`
+	InputTokens     int64     `json:"input_tokens"`
+	OutputTokens    int64     `json:"output_tokens"`
+	ReasoningTokens int64     `json:"reasoning_tokens"`
+	LatencyMs       int64     `json:"latency_ms"`
+}
+
+type bucketKey struct {
+	hour                                          int64
+	keyID, surface, model, resolvedModel, account string
+}
+
+type monthKey struct {
+	month, keyID string
+}
+
+// rollupLine is one line of rollups.jsonl: every bucket of a closed hour,
+// written at once so a torn write loses the whole hour and it is replayed.
+type rollupLine struct {
+	Hour    time.Time `json:"hour"`
+	Buckets []Bucket  `json:"buckets"`
+}
+
+// Ledger appends entries to monthly ledger-YYYY-MM.jsonl files and keeps
+// hourly rollups. Rollups of finished hours are appended to rollups.jsonl;
+// on Open the entries after the last persisted hour are replayed, so the
+// raw log stays the source of truth.
+type Ledger struct {
+	mu          sync.Mutex
+	dir         string
+	now         func() time.Time
+	closed      []Bucket
+	open        map[bucketKey]*Bucket
+	monthTokens map[monthKey]int64
+	file        *os.File
+	fileMonth   string
+}
+
+// Open loads the ledger kept in dir. An empty dir keeps it in memory only.
+func Open(dir string) (*Ledger, error) {
+	l := &Ledger{
+		dir:         dir,
+		now:         time.Now,
+		open:        map[bucketKey]*Bucket{},
+		monthTokens: map[monthKey]int64{},
+	}
+	if dir == "" {
+		return l, nil
+	}
+	watermark, err := l.loadRollups()
+	if err != nil {
+		return nil, err
+	}
+	if err := l.replay(watermark); err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.closeHoursBeforeLocked(l.now().UTC().Truncate(time.Hour)); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Record stamps e with the current time and appends it.
+func (l *Ledger) Record(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.Time = l.now().UTC()
+	if err := l.closeHoursBeforeLocked(e.Time.Truncate(time.Hour)); err != nil {
+		return err
+	}
+	if err := l.appendLocked(e); err != nil {
+		return err
+	}
+	l.addLocked(e)
+	return nil
+}
+
+// MonthTokens returns the input plus output tokens keyID used in the UTC
+// calendar month containing at.
+func (l *Ledger) MonthTokens(keyID string, at time.Time) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.monthTokens[monthKey{month: at.UTC().Format(monthLayout), keyID: keyID}]
+}
+
+// Close releases the ledger file. The open hour is not rolled up; Open
+// replays it from the ledger.
+func (l *Ledger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// RenameKeys moves the history of every key ID in renamed to its new ID,
+// rewriting the ledger and rollup files, so usage and monthly quotas follow
+// a key whose ID changed.
+func (l *Ledger) RenameKeys(renamed map[string]string) error {
+	if len(renamed) == 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.dir != "" {
+		if l.file != nil {
+			_ = l.file.Close()
+			l.file = nil
+		}
+		names, err := filepath.Glob(filepath.Join(l.dir, ledgerPrefix+"*"+ledgerSuffix))
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			err := rewriteLines(name, func(raw []byte) []byte {
+				var e Entry
+				if json.Unmarshal(raw, &e) != nil || renamed[e.KeyID] == "" {
+					return raw
+				}
+				e.KeyID = renamed[e.KeyID]
+				b, _ := json.Marshal(e)
+				return b
+			})
+			if err != nil {
+				return err
+			}
+		}
+		err = rewriteLines(filepath.Join(l.dir, rollupsFile), func(raw []byte) []byte {
+			var line rollupLine
+			if json.Unmarshal(raw, &line) != nil {
+				return raw
+			}
+			for i := range line.Buckets {
+				if to := renamed[line.Buckets[i].KeyID]; to != "" {
+					line.Buckets[i].KeyID = to
+				}
+			}
+			b, _ := json.Marshal(line)
+			return b
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range l.closed {
+		if to := renamed[l.closed[i].KeyID]; to != "" {
+			l.closed[i].KeyID = to
+		}
+	}
+	open := make(map[bucketKey]*Bucket, len(l.open))
+	for key, b := range l.open {
+		if to := renamed[key.keyID]; to != "" {
+			key.keyID, b.KeyID = to, to
+		}
+		if into, ok := open[key]; ok {
+			into.merge(*b)
+			continue
+		}
+		open[key] = b
+	}
+	l.open = open
+	monthTokens := make(map[monthKey]int64, len(l.monthTokens))
+	for key, tokens := range l.monthTokens {
+		if to := renamed[key.keyID]; to != "" {
+			key.keyID = to
+		}
+		monthTokens[key] += tokens
+	}
+	l.monthTokens = monthTokens
+	return nil
+}
+
+func (b *Bucket) merge(o Bucket) {
+	b.Requests += o.Requests
+	b.Errors += o.Errors
+	b.InputTokens += o.InputTokens
+	b.OutputTokens += o.OutputTokens
+	b.ReasoningTokens += o.ReasoningTokens
+	b.LatencyMs += o.LatencyMs
+}
+
+// buckets returns a copy of every rollup, closed and open.
+func (l *Ledger) buckets() []Bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]Bucket, 0, len(l.closed)+len(l.open))
+	out = append(out, l.closed...)
+	for _, b := range l.open {
+		out = append(out, *b)
+	}
+	return out
+}
+
+func (l *Ledger) addLocked(e Entry) {
+	hour := e.Time.UTC().Truncate(time.Hour)
+	key := bucketKey{
+		hour:          hour.Unix(),
+		keyID:         e.KeyID,
+		surface:       e.Surface,
+		model:         e.Model,
+		resolvedModel: e.ResolvedModel,
+		account:       e.Account,
+	}
+	b, ok := l.open[key]
+	if !ok {
+		b = &Bucket{Hour: hour, KeyID: e.KeyID, Surface: e.Surface, Model: e.Model, ResolvedModel: e.ResolvedModel, Account: e.Account}
+		l.open[key] = b
+	}
+	b.Requests++
+	if e.Status >= 400 {
+		b.Errors++
+	}
+	b.InputTokens += int64(e.InputTokens)
+	b.OutputTokens += int64(e.OutputTokens)
+	b.ReasoningTokens += int64(e.ReasoningTokens)
+	b.LatencyMs += e.LatencyMs
+	l.monthTokens[monthKey{month: hour.Format(monthLayout), keyID: e.KeyID}] += int64(e.InputTokens + e.OutputTokens)
+}
+
+func (l *Ledger) appendLocked(e Entry) error {
+	if l.dir == "" {
+		return nil
+	}
+	month := e.Time.Format(monthLayout)
+	if l.file == nil || l.fileMonth != month {
+		if l.file != nil {
+			_ = l.file.Close()
+			l.file = nil
+		}
+		if err := os.MkdirAll(l.dir, 0o755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(filepath.Join(l.dir, ledgerPrefix+month+ledgerSuffix), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		l.file, l.fileMonth = f, month
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = l.file.Write(append(b, '\n'))
+	return err
+}
+
+// closeHoursBeforeLocked moves open buckets of hours before hour to the
+// closed rollups, persisting them first.
+func (l *Ledger) closeHoursBeforeLocked(hour time.Time) error {
+	byHour := map[int64][]Bucket{}
+	for key, b := range l.open {
+		if key.hour < hour.Unix() {
+			byHour[key.hour] = append(byHour[key.hour], *b)
+		}
+	}
+	if len(byHour) == 0 {
+		return nil
+	}
+	hours := make([]int64, 0, len(byHour))
+	for h := range byHour {
+		hours = append(hours, h)
+	}
+	sort.Slice(hours, func(i, j int) bool { return hours[i] < hours[j] })
+	for _, h := range hours {
+		buckets := byHour[h]
+		sortBuckets(buckets)
+		if err := l.appendRollupLocked(rollupLine{Hour: time.Unix(h, 0).UTC(), Buckets: buckets}); err != nil {
+			return err
+		}
+		l.closed = append(l.closed, buckets...)
+		for key := range l.open {
+			if key.hour == h {
+				delete(l.open, key)
+			}
+		}
+	}
+	return nil
+}
+
+func (l *Ledger) appendRollupLocked(line rollupLine) error {
+	if l.dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(l.dir, rollupsFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// loadRollups reads the persisted rollups and returns the first hour they
+// do not cover.
+func (l *Ledger) loadRollups() (time.Time, error) {
+	var watermark time.Time
+	err := readLines(filepath.Join(l.dir, rollupsFile), func(raw []byte) {
+		var line rollupLine
+		if json.Unmarshal(raw, &line) != nil {
+			return
+		}
+		for _, b := range line.Buckets {
+			l.closed = append(l.closed, b)
+			l.monthTokens[monthKey{month: b.Hour.UTC().Format(monthLayout), keyID: b.KeyID}] += b.InputTokens + b.OutputTokens
+		}
+		if next := line.Hour.UTC().Add(time.Hour); next.After(watermark) {
+			watermark = next
+		}
+	})
+	return watermark, err
+}
+
+// replay rebuilds the open rollups from ledger entries at or after
+// watermark.
+func (l *Ledger) replay(watermark time.Time) error {
+	names, err := filepath.Glob(filepath.Join(l.dir, ledgerPrefix+"*"+ledgerSuffix))
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	fromMonth := watermark.Format(monthLayout)
+	for _, name := range names {
+		month := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(name), ledgerPrefix), ledgerSuffix)
+		if !watermark.IsZero() && month < fromMonth {
+			continue
+		}
+		err := readLines(name, func(raw []byte) {
+			var e Entry
+			if json.Unmarshal(raw, &e) != nil || e.Time.Before(watermark) {
+				return
+			}
+			l.addLocked(e)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLines calls fn for every non-empty line of path; a missing file has
+// no lines.
+func readLines(path string, fn func([]byte)) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if trimmed := strings.TrimSpace(string(line)); trimmed != "" {
+			fn([]byte(trimmed))
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// rewriteLines replaces every line of path with fn's result, atomically; a
+// missing file is left alone.
+func rewriteLines(path string, fn func([]byte) []byte) error {
+	var out []byte
+	found := false
+	err := readLines(path, func(raw []byte) {
+		found = true
+		out = append(append(out, fn(raw)...), '\n')
+	})
+	if err != nil || !found {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, out, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func sortBuckets(buckets []Bucket) {
+	sort.Slice(buckets, func(i, j int) bool {
+		a, b := buckets[i], buckets[j]
+		if !a.Hour.Equal(b.Hour) {
+			return a.Hour.Before(b.Hour)
+		}
+		if a.KeyID != b.KeyID {
+			return a.KeyID < b.KeyID
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		if a.Surface != b.Surface {
+			return a.Surface < b.Surface
+		}
+		return a.Account < b.Account
+	})
+}
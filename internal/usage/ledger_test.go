@@ -0,0 +1,151 @@
+package usage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ds2api/internal/config"
+)
+
+func openTestLedger(t *testing.T, dir string, now *time.Time) *Ledger {
+	t.Helper()
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	l.now = func() time.Time { return *now }
+	t.Cleanup(func() { _ = l.Close() })
+	return l
+}
+
+func TestLedgerRollsUpHoursAndReplaysOnOpen(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 18, 9, 15, 0, 0, time.UTC)
+	l := openTestLedger(t, dir, &now)
+
+	record := func(e Entry) {
+		t.Helper()
+		if err := l.Record(e); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	record(Entry{KeyID: "apikey:a", Surface: "openai", Model: "gpt-4o", ResolvedModel: "deepseek-chat", InputTokens: 100, OutputTokens: 50, Status: 200})
+	record(Entry{KeyID: "apikey:a", Surface: "openai", Model: "gpt-4o", ResolvedModel: "deepseek-chat", InputTokens: 10, OutputTokens: 5, Status: 500})
+	now = now.Add(time.Hour)
+	record(Entry{KeyID: "apikey:b", Surface: "claude", Model: "claude-sonnet-4", InputTokens: 7, OutputTokens: 3, ReasoningTokens: 2, Status: 200})
+
+	raw, err := os.ReadFile(filepath.Join(dir, rollupsFile))
+	if err != nil || strings.Count(string(raw), "\n") != 1 || !strings.Contains(string(raw), `"hour":"2026-10-18T09:00:00Z"`) {
+		t.Fatalf("expected the 09:00 hour to be rolled up, got %q err=%v", raw, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ledger-2026-10.jsonl")); err != nil {
+		t.Fatalf("expected a monthly ledger file: %v", err)
+	}
+	if got := l.MonthTokens("apikey:a", now); got != 165 {
+		t.Fatalf("expected 165 tokens for apikey:a, got %d", got)
+	}
+
+	_ = l.Close()
+	reopened := openTestLedger(t, dir, &now)
+	if got := reopened.MonthTokens("apikey:a", now); got != 165 {
+		t.Fatalf("expected closed rollups to reload, got %d", got)
+	}
+	if got := reopened.MonthTokens("apikey:b", now); got != 10 {
+		t.Fatalf("expected the open hour to be replayed from the ledger, got %d", got)
+	}
+	if got := reopened.MonthTokens("apikey:a", now.AddDate(0, 1, 0)); got != 0 {
+		t.Fatalf("expected a new month to start from zero, got %d", got)
+	}
+}
+
+func TestLedgerQueryFiltersAndGroups(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	l := openTestLedger(t, "", &now)
+	entries := []Entry{
+		{KeyID: "apikey:a", Surface: "openai", Model: "gpt-4o", ResolvedModel: "deepseek-chat", InputTokens: 100, OutputTokens: 50, LatencyMs: 100, Status: 200},
+		{KeyID: "apikey:a", Surface: "gemini", Model: "gemini-2.5-pro", ResolvedModel: "deepseek-chat", InputTokens: 20, OutputTokens: 10, LatencyMs: 300, Status: 429},
+		{KeyID: "apikey:b", Surface: "openai", Model: "deepseek-reasoner", ResolvedModel: "deepseek-reasoner", InputTokens: 5, OutputTokens: 5, ReasoningTokens: 3, Status: 200},
+	}
+	for i, e := range entries {
+		now = now.Add(time.Duration(i) * 24 * time.Hour)
+		if err := l.Record(e); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	rows := l.Query(Query{GroupBy: []string{GroupKey}})
+	if len(rows) != 2 || rows[0].KeyID != "apikey:a" || rows[0].Requests != 2 || rows[0].Errors != 1 || rows[0].TotalTokens != 180 || rows[0].AvgLatencyMs != 200 {
+		t.Fatalf("unexpected per-key rows: %+v", rows)
+	}
+
+	rows = l.Query(Query{Model: "DEEPSEEK-CHAT", GroupBy: []string{GroupDay, GroupSurface}})
+	if len(rows) != 2 || rows[0].Period != "2026-10-18" || rows[0].Surface != "openai" || rows[1].Period != "2026-10-19" || rows[1].KeyID != "" {
+		t.Fatalf("expected resolved model matches grouped by day and surface, got %+v", rows)
+	}
+
+	rows = l.Query(Query{From: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), To: time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC), GroupBy: []string{GroupMonth}})
+	if len(rows) != 1 || rows[0].Period != "2026-10" || rows[0].Requests != 1 {
+		t.Fatalf("expected only the 19th inside the range, got %+v", rows)
+	}
+	if total := Sum(l.Query(Query{})); total.Requests != 3 || total.ReasoningTokens != 3 || total.TotalTokens != 190 {
+		t.Fatalf("unexpected totals: %+v", total)
+	}
+}
+
+func TestMonthTokensSurviveKeyHashing(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 15, 0, 0, time.UTC)
+	dir := t.TempDir()
+	l := openTestLedger(t, dir, &now)
+	// Before keys were hashed, a key without an ID was known by an unsalted
+	// digest of itself.
+	legacyID := func(key string) string {
+		sum := sha256.Sum256([]byte(key))
+		return "apikey:" + hex.EncodeToString(sum[:16])
+	}
+	cfg := config.Config{
+		Keys:    []string{"legacy-key-001"},
+		APIKeys: []config.APIKeyMetadata{{Key: "sk-plain-001"}, {ID: "apikey:team", Key: "sk-team-001"}},
+	}
+	recorded := map[string]int64{legacyID("sk-plain-001"): 30, "apikey:team": 20, legacyID("legacy-key-001"): 10}
+	for id, tokens := range recorded {
+		if err := l.Record(Entry{KeyID: id, InputTokens: int(tokens), Status: 200}); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	store := config.NewStore(&cfg, filepath.Join(dir, "config.json"))
+	store.OnAPIKeyIDRenames(l.RenameKeys)
+	snap := store.Snapshot()
+	byHash := map[string]int64{
+		config.HashAPIKey(snap.APIKeySalt, "sk-plain-001"):   30,
+		config.HashAPIKey(snap.APIKeySalt, "sk-team-001"):    20,
+		config.HashAPIKey(snap.APIKeySalt, "legacy-key-001"): 10,
+	}
+	want := map[string]int64{}
+	for _, metadata := range snap.APIKeys {
+		if recorded[metadata.Identifier()] != 0 && metadata.Identifier() != "apikey:team" {
+			t.Fatalf("expected the unsalted ID to be replaced, got %#v", metadata)
+		}
+		want[metadata.Identifier()] = byHash[metadata.KeyHash]
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	for _, l := range []*Ledger{l, openTestLedger(t, dir, &now)} {
+		for id, tokens := range want {
+			if got := l.MonthTokens(id, now); got != tokens {
+				t.Fatalf("expected the month total of %s to carry over, got %d want %d", id, got, tokens)
+			}
+		}
+		for id := range recorded {
+			if _, ok := want[id]; !ok && l.MonthTokens(id, now) != 0 {
+				t.Fatalf("expected nothing left under the former ID %s", id)
+			}
+		}
+	}
+}
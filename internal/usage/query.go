@@ -0,0 +1,172 @@
+package usage
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Dimensions a Query can group by. Only one time grouping applies; the
+// finest one given wins.
+const (
+	GroupHour          = "hour"
+	GroupDay           = "day"
+	GroupMonth         = "month"
+	GroupKey           = "key"
+	GroupSurface       = "surface"
+	GroupModel         = "model"
+	GroupResolvedModel = "resolved_model"
+	GroupAccount       = "account"
+)
+
+var groupings = []string{GroupHour, GroupDay, GroupMonth, GroupKey, GroupSurface, GroupModel, GroupResolvedModel, GroupAccount}
+
+// ValidGrouping reports whether g is a known group-by dimension.
+func ValidGrouping(g string) bool {
+	return slices.Contains(groupings, g)
+}
+
+// Query selects rollups. Empty filters match everything; Model matches the
+// requested or the resolved model. The range is hour-granular: From is
+// rounded down to its hour and To is exclusive, with zero meaning open.
+type Query struct {
+	KeyID   string
+	Model   string
+	Surface string
+	Account string
+	From    time.Time
+	To      time.Time
+	GroupBy []string
+}
+
+// Row is one group of a query result; only grouped dimensions are set.
+type Row struct {
+	Period          string  `json:"period,omitempty"`
+	KeyID           string  `json:"key_id,omitempty"`
+	Surface         string  `json:"surface,omitempty"`
+	Model           string  `json:"model,omitempty"`
+	ResolvedModel   string  `json:"resolved_model,omitempty"`
+	Account         string  `json:"account,omitempty"`
+	Requests        int64   `json:"requests"`
+	Errors          int64   `json:"errors"`
+	InputTokens     int64   `json:"input_tokens"`
+	OutputTokens    int64   `json:"output_tokens"`
+	ReasoningTokens int64   `json:"reasoning_tokens"`
+	TotalTokens     int64   `json:"total_tokens"`
+	AvgLatencyMs    float64 `json:"avg_latency_ms"`
+	latencyMs       int64
+}
+
+// Query aggregates the rollups matching q, ordered by its dimensions.
+func (l *Ledger) Query(q Query) []Row {
+	groups := map[string]*Row{}
+	for _, b := range l.buckets() {
+		if !q.matches(b) {
+			continue
+		}
+		row := q.group(b)
+		id := strings.Join([]string{row.Period, row.KeyID, row.Surface, row.Model, row.ResolvedModel, row.Account}, "\x00")
+		acc, ok := groups[id]
+		if !ok {
+			acc = &row
+			groups[id] = acc
+		}
+		acc.add(b)
+	}
+	rows := make([]Row, 0, len(groups))
+	for _, row := range groups {
+		row.finish()
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		for _, pair := range [][2]string{{a.Period, b.Period}, {a.KeyID, b.KeyID}, {a.Surface, b.Surface}, {a.Model, b.Model}, {a.ResolvedModel, b.ResolvedModel}, {a.Account, b.Account}} {
+			if pair[0] != pair[1] {
+				return pair[0] < pair[1]
+			}
+		}
+		return false
+	})
+	return rows
+}
+
+// Sum totals rows into one ungrouped row.
+func Sum(rows []Row) Row {
+	var total Row
+	for _, row := range rows {
+		total.Requests += row.Requests
+		total.Errors += row.Errors
+		total.InputTokens += row.InputTokens
+		total.OutputTokens += row.OutputTokens
+		total.ReasoningTokens += row.ReasoningTokens
+		total.latencyMs += row.latencyMs
+	}
+	total.finish()
+	return total
+}
+
+func (q Query) matches(b Bucket) bool {
+	if q.KeyID != "" && b.KeyID != q.KeyID {
+		return false
+	}
+	if q.Model != "" && !strings.EqualFold(b.Model, q.Model) && !strings.EqualFold(b.ResolvedModel, q.Model) {
+		return false
+	}
+	if q.Surface != "" && !strings.EqualFold(b.Surface, q.Surface) {
+		return false
+	}
+	if q.Account != "" && b.Account != q.Account {
+		return false
+	}
+	if !q.From.IsZero() && b.Hour.Before(q.From.UTC().Truncate(time.Hour)) {
+		return false
+	}
+	if !q.To.IsZero() && !b.Hour.Before(q.To) {
+		return false
+	}
+	return true
+}
+
+func (q Query) group(b Bucket) Row {
+	var row Row
+	switch hour := b.Hour.UTC(); {
+	case slices.Contains(q.GroupBy, GroupHour):
+		row.Period = hour.Format(time.RFC3339)
+	case slices.Contains(q.GroupBy, GroupDay):
+		row.Period = hour.Format(time.DateOnly)
+	case slices.Contains(q.GroupBy, GroupMonth):
+		row.Period = hour.Format(monthLayout)
+	}
+	for _, g := range q.GroupBy {
+		switch g {
+		case GroupKey:
+			row.KeyID = b.KeyID
+		case GroupSurface:
+			row.Surface = b.Surface
+		case GroupModel:
+			row.Model = b.Model
+		case GroupResolvedModel:
+			row.ResolvedModel = b.ResolvedModel
+		case GroupAccount:
+			row.Account = b.Account
+		}
+	}
+	return row
+}
+
+func (r *Row) add(b Bucket) {
+	r.Requests += b.Requests
+	r.Errors += b.Errors
+	r.InputTokens += b.InputTokens
+	r.OutputTokens += b.OutputTokens
+	r.ReasoningTokens += b.ReasoningTokens
+	r.latencyMs += b.LatencyMs
+}
+
+func (r *Row) finish() {
+	r.TotalTokens = r.InputTokens + r.OutputTokens
+	if r.Requests > 0 {
+		r.AvgLatencyMs = float64(r.latencyMs) / float64(r.Requests)
+	}
+}
@@ -0,0 +1,81 @@
+// Package usage keeps a per-request usage ledger for chargeback: an
+// append-only log of entries plus hourly rollups, which also back the
+// monthly token quotas of API keys.
+package usage
+
+import (
+	"context"
+	"time"
+)
+
+// Entry is one ledger line. Token counts are the estimates reported to the
+// client in the response's usage block; OutputTokens includes reasoning.
+type Entry struct {
+	Time            time.Time `json:"time"`
+	KeyID           string    `json:"key_id"`
+	Surface         string    `json:"surface"`
+	Path            string    `json:"path"`
+	Model           string    `json:"model,omitempty"`
+	ResolvedModel   string    `json:"resolved_model,omitempty"`
+	Account         string    `json:"account,omitempty"`
+	InputTokens     int       `json:"input_tokens"`
+	OutputTokens    int       `json:"output_tokens"`
+	ReasoningTokens int       `json:"reasoning_tokens"`
+	LatencyMs       int64     `json:"latency_ms"`
+	Status          int       `json:"status"`
+}
+
+// Record collects an entry while its request is served. Auth fills in the
+// caller, model and account; the middleware adds tokens, status and latency.
+// Methods are no-ops on a nil Record, so callers need not check whether the
+// request is tracked.
+type Record struct {
+	entry Entry
+}
+
+type ctxKey struct{}
+
+func WithRecord(ctx context.Context, rec *Record) context.Context {
+	return context.WithValue(ctx, ctxKey{}, rec)
+}
+
+// FromContext returns the request's Record, or nil when usage is not
+// tracked for it.
+func FromContext(ctx context.Context) *Record {
+	rec, _ := ctx.Value(ctxKey{}).(*Record)
+	return rec
+}
+
+// SetCaller names the key the request is billed to and its route family.
+func (r *Record) SetCaller(keyID, surface string) {
+	if r == nil {
+		return
+	}
+	r.entry.KeyID = keyID
+	r.entry.Surface = surface
+}
+
+func (r *Record) SetModel(requested, resolved string) {
+	if r == nil {
+		return
+	}
+	r.entry.Model = requested
+	r.entry.ResolvedModel = resolved
+}
+
+// SetAccount records the pooled account serving the request; a retry on
+// another account overwrites it.
+func (r *Record) SetAccount(account string) {
+	if r == nil {
+		return
+	}
+	r.entry.Account = account
+}
+
+// observe keeps the largest count seen per field, since streams repeat
+// running totals and some protocols split input and output across events.
+func (r *Record) observe(input, output, reasoning int) {
+	r.entry.InputTokens = max(r.entry.InputTokens, input)
+	r.entry.OutputTokens = max(r.entry.OutputTokens, output)
+	r.entry.ReasoningTokens = max(r.entry.ReasoningTokens, reasoning)
+}
@@ -0,0 +1,238 @@
+package util
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	citationMarkerPrefix = "[citation:"
+	// citationMarkerMaxLen bounds how far a marker may run before its closing
+	// bracket; anything longer is treated as literal text.
+	citationMarkerMaxLen = 48
+)
+
+// SearchResult is one web search hit reported by a *-search model. Index is
+// the number the model uses in its [citation:N] markers.
+type SearchResult struct {
+	Index   int
+	URL     string
+	Title   string
+	Snippet string
+}
+
+// CitationSpan locates one rewritten citation in the visible text. Start/End
+// are byte offsets of the "[N]" reference and RuneStart/RuneEnd the same range
+// in characters. SegmentStart/SegmentEnd delimit the cited passage: it runs
+// from the previous citation or line break up to the first reference of the
+// group this span belongs to.
+type CitationSpan struct {
+	Start        int
+	End          int
+	RuneStart    int
+	RuneEnd      int
+	SegmentStart int
+	SegmentEnd   int
+	Source       SearchResult
+}
+
+// CitationRewriter turns DeepSeek's [citation:N] markers into "[N]"
+// references and records where each one lands. It works incrementally, so a
+// marker split across stream chunks is still recognised; markers pointing at
+// unknown results are dropped instead of leaking into the output.
+type CitationRewriter struct {
+	sources   map[int]SearchResult
+	nextIndex int
+	pending   string
+
+	bytePos       int
+	runePos       int
+	segmentStart  int
+	lastMarkerEnd int
+	lastSegment   [2]int
+}
+
+func NewCitationRewriter() *CitationRewriter {
+	return &CitationRewriter{sources: map[int]SearchResult{}, nextIndex: 1, lastMarkerEnd: -1}
+}
+
+// AddSources registers search results. Results without a cite index get the
+// next free number, matching the order DeepSeek lists them in.
+func (c *CitationRewriter) AddSources(results []SearchResult) {
+	for _, r := range results {
+		if strings.TrimSpace(r.URL) == "" {
+			continue
+		}
+		if r.Index <= 0 {
+			r.Index = c.nextIndex
+		}
+		if r.Index >= c.nextIndex {
+			c.nextIndex = r.Index + 1
+		}
+		c.sources[r.Index] = r
+	}
+}
+
+// Sources returns every registered result ordered by index.
+func (c *CitationRewriter) Sources() []SearchResult {
+	out := make([]SearchResult, 0, len(c.sources))
+	for _, r := range c.sources {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Index < out[j].Index })
+	return out
+}
+
+// Push rewrites the next chunk of text. A trailing fragment that could still
+// become a marker is held back until the following Push or Flush.
+func (c *CitationRewriter) Push(text string) (string, []CitationSpan) {
+	buf := c.pending + text
+	c.pending = ""
+	var out strings.Builder
+	var spans []CitationSpan
+	for len(buf) > 0 {
+		i := strings.IndexByte(buf, '[')
+		if i < 0 {
+			c.emit(&out, buf)
+			break
+		}
+		c.emit(&out, buf[:i])
+		buf = buf[i:]
+		if !strings.HasPrefix(buf, citationMarkerPrefix) {
+			if strings.HasPrefix(citationMarkerPrefix, buf) {
+				c.pending = buf
+				break
+			}
+			c.emit(&out, buf[:1])
+			buf = buf[1:]
+			continue
+		}
+		end := strings.IndexByte(buf, ']')
+		if end < 0 {
+			if len(buf) < citationMarkerMaxLen {
+				c.pending = buf
+				break
+			}
+			c.emit(&out, buf[:1])
+			buf = buf[1:]
+			continue
+		}
+		indices, ok := parseCitationIndices(buf[len(citationMarkerPrefix):end])
+		if !ok {
+			c.emit(&out, buf[:1])
+			buf = buf[1:]
+			continue
+		}
+		spans = append(spans, c.emitMarker(&out, indices)...)
+		buf = buf[end+1:]
+	}
+	return out.String(), spans
+}
+
+// Offset reports how many bytes have been emitted so far.
+func (c *CitationRewriter) Offset() int {
+	return c.bytePos
+}
+
+// RuneOffset reports how many characters have been emitted so far.
+func (c *CitationRewriter) RuneOffset() int {
+	return c.runePos
+}
+
+// Flush releases any held-back fragment as literal text.
+func (c *CitationRewriter) Flush() string {
+	if c.pending == "" {
+		return ""
+	}
+	var out strings.Builder
+	c.emit(&out, c.pending)
+	c.pending = ""
+	return out.String()
+}
+
+func (c *CitationRewriter) emit(out *strings.Builder, text string) {
+	if text == "" {
+		return
+	}
+	if nl := strings.LastIndexByte(text, '\n'); nl >= 0 {
+		c.segmentStart = c.bytePos + nl + 1
+	}
+	out.WriteString(text)
+	c.bytePos += len(text)
+	c.runePos += utf8.RuneCountInString(text)
+}
+
+func (c *CitationRewriter) emitMarker(out *strings.Builder, indices []int) []CitationSpan {
+	segment := [2]int{c.segmentStart, c.bytePos}
+	if c.lastMarkerEnd == c.bytePos {
+		// Adjacent markers ("[1][2]") cite the same passage.
+		segment = c.lastSegment
+	}
+	var spans []CitationSpan
+	for _, idx := range indices {
+		src, ok := c.sources[idx]
+		if !ok {
+			continue
+		}
+		ref := "[" + strconv.Itoa(idx) + "]"
+		span := CitationSpan{
+			Start:        c.bytePos,
+			End:          c.bytePos + len(ref),
+			RuneStart:    c.runePos,
+			RuneEnd:      c.runePos + len(ref),
+			SegmentStart: segment[0],
+			SegmentEnd:   segment[1],
+			Source:       src,
+		}
+		out.WriteString(ref)
+		c.bytePos += len(ref)
+		c.runePos += len(ref)
+		spans = append(spans, span)
+	}
+	if len(spans) > 0 {
+		c.lastMarkerEnd = c.bytePos
+		c.lastSegment = segment
+		c.segmentStart = c.bytePos
+	}
+	return spans
+}
+
+func parseCitationIndices(raw string) ([]int, bool) {
+	fields := strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(fields) == 0 {
+		return nil, false
+	}
+	out := make([]int, 0, len(fields))
+	for _, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		out = append(out, n)
+	}
+	return out, true
+}
+
+// ResolveCitations rewrites a complete text in one go.
+func ResolveCitations(text string, results []SearchResult) (string, []CitationSpan) {
+	c := NewCitationRewriter()
+	c.AddSources(results)
+	out, spans := c.Push(text)
+	return out + c.Flush(), spans
+}
+
+// CitationSegment returns the cited passage for span within text, trimmed of
+// surrounding whitespace, together with its byte range.
+func CitationSegment(text string, span CitationSpan) (string, int, int) {
+	start, end := span.SegmentStart, span.SegmentEnd
+	if start < 0 || end > len(text) || start > end {
+		return "", span.Start, span.Start
+	}
+	seg := text[start:end]
+	trimmedLeft := strings.TrimLeft(seg, " \t\r\n")
+	start += len(seg) - len(trimmedLeft)
+	seg = strings.TrimRight(trimmedLeft, " \t\r\n")
+	return seg, start, start + len(seg)
+}
@@ -0,0 +1,61 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveCitationsRewritesKnownMarkersAndDropsUnknown(t *testing.T) {
+	results := []SearchResult{
+		{Index: 1, URL: "https://a.example", Title: "A"},
+		{Index: 2, URL: "https://b.example", Title: "B"},
+	}
+	text, spans := ResolveCitations("Go is fast[citation:1][citation:2]. Rust too[citation:9].", results)
+	if text != "Go is fast[1][2]. Rust too." {
+		t.Fatalf("unexpected text %q", text)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("expected two spans, got %#v", spans)
+	}
+	if text[spans[0].Start:spans[0].End] != "[1]" || text[spans[1].Start:spans[1].End] != "[2]" {
+		t.Fatalf("spans do not cover references: %#v", spans)
+	}
+	seg, _, _ := CitationSegment(text, spans[1])
+	if seg != "Go is fast" {
+		t.Fatalf("adjacent citations should share the passage, got %q", seg)
+	}
+}
+
+func TestCitationRewriterHandlesMarkersSplitAcrossChunks(t *testing.T) {
+	c := NewCitationRewriter()
+	c.AddSources([]SearchResult{{URL: "https://first.example"}, {URL: "https://second.example"}})
+	var out string
+	var spans []CitationSpan
+	for _, chunk := range []string{"你好[cit", "ation:", "2] done [x] [citation:1,2]", "[cita"} {
+		text, s := c.Push(chunk)
+		out += text
+		spans = append(spans, s...)
+	}
+	out += c.Flush()
+	if out != "你好[2] done [x] [1][2][cita" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if len(spans) != 3 || spans[0].Source.URL != "https://second.example" {
+		t.Fatalf("unexpected spans %#v", spans)
+	}
+	if spans[0].RuneStart != 2 || spans[0].Start != len("你好") {
+		t.Fatalf("unexpected offsets %#v", spans[0])
+	}
+}
+
+func TestCitationRewriterSourcesAreOrderedByIndex(t *testing.T) {
+	c := NewCitationRewriter()
+	c.AddSources([]SearchResult{{Index: 3, URL: "https://c"}, {URL: "https://d"}, {Index: 1, URL: "https://a"}, {Index: 2}})
+	got := []int{}
+	for _, r := range c.Sources() {
+		got = append(got, r.Index)
+	}
+	if !reflect.DeepEqual(got, []int{1, 3, 4}) {
+		t.Fatalf("unexpected indices %v", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"ds2api/internal/claudeconv"
 	"ds2api/internal/config"
 	"ds2api/internal/prompt"
+	"ds2api/internal/tokenizer"
 )
 
 const ClaudeDefaultModel = "claude-sonnet-4-5"
@@ -25,6 +26,18 @@ func ConvertClaudeToDeepSeek(claudeReq map[string]any, store *config.Store) map[
 	return claudeconv.ConvertClaudeToDeepSeek(claudeReq, store, ClaudeDefaultModel)
 }
 
+// CountTokens counts text with the DeepSeek tokenizer when its vocabulary is
+// installed and falls back to EstimateTokens otherwise.
+func CountTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	if tok := tokenizer.Default(); tok != nil {
+		return tok.Count(text)
+	}
+	return EstimateTokens(text)
+}
+
 // EstimateTokens provides a rough token count approximation.
 // For ASCII text (English, code, etc.) we use ~4 chars per token.
 // For non-ASCII text (Chinese, Japanese, Korean, etc.) we use ~1.3 chars per token,
@@ -42,6 +55,13 @@ func EstimateTokens(text string) int {
 			nonASCIIChars++
 		}
 	}
+	return estimateTokensFromCounts(asciiChars, nonASCIIChars)
+}
+
+func estimateTokensFromCounts(asciiChars, nonASCIIChars int) int {
+	if asciiChars == 0 && nonASCIIChars == 0 {
+		return 0
+	}
 	// ASCII: ~4 chars per token; non-ASCII (CJK): ~1.3 chars per token
 	n := asciiChars/4 + (nonASCIIChars*10+7)/13
 	if n < 1 {
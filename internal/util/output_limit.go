@@ -0,0 +1,312 @@
+package util
+
+import (
+	"sort"
+	"strings"
+
+	"ds2api/internal/tokenizer"
+)
+
+// Output finish reasons reported by OutputLimiter. Each surface maps them onto
+// its own vocabulary (OpenAI stop/length, Claude stop_sequence/max_tokens,
+// Gemini STOP/MAX_TOKENS).
+const (
+	OutputFinishNone         = ""
+	OutputFinishStopSequence = "stop_sequence"
+	OutputFinishMaxTokens    = "max_tokens"
+)
+
+// Finish reasons for streams the upstream ended itself: its safety filter
+// fired (OpenAI content_filter, Claude refusal, Gemini SAFETY) or it reported
+// an error mid-stream.
+const (
+	OutputFinishContentFilter = "content_filter"
+	OutputFinishUpstreamError = "upstream_error"
+)
+
+// StopPolicy describes the client-side generation limits that the DeepSeek web
+// endpoint ignores, so they are enforced locally while streaming.
+// ThinkingBudget caps the reasoning tokens relayed to the client (the rest of
+// the reasoning is dropped and the answer follows); HideThinking drops
+// reasoning output entirely.
+type StopPolicy struct {
+	Sequences      []string
+	MaxTokens      int
+	ThinkingBudget int
+	HideThinking   bool
+}
+
+func (p StopPolicy) Enabled() bool {
+	return len(p.Sequences) > 0 || p.MaxTokens > 0 || p.ThinkingBudget > 0 || p.HideThinking
+}
+
+// ParseStopSequences accepts the OpenAI-style `stop` value (string or array of
+// strings) and returns the non-empty sequences.
+func ParseStopSequences(v any) []string {
+	var raw []string
+	switch x := v.(type) {
+	case string:
+		raw = []string{x}
+	case []string:
+		raw = x
+	case []any:
+		for _, item := range x {
+			if s, ok := item.(string); ok {
+				raw = append(raw, s)
+			}
+		}
+	}
+	out := make([]string, 0, len(raw))
+	seen := map[string]struct{}{}
+	for _, s := range raw {
+		if s == "" {
+			continue
+		}
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		out = append(out, s)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// PositiveIntFrom returns the first positive integer among the given
+// JSON-decoded values, or 0 when none is set.
+func PositiveIntFrom(values ...any) int {
+	for _, v := range values {
+		if n := IntFrom(v); n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// OutputLimiter enforces stop sequences and an output token budget over
+// incrementally streamed text. Text that could be the beginning of a stop
+// sequence split across chunks is held back until it can be decided.
+type OutputLimiter struct {
+	policy       StopPolicy
+	maxStopLen   int
+	pending      string
+	counters     [2]outputCounter
+	done         bool
+	thinkingCut  bool
+	finishReason string
+	matched      string
+}
+
+const (
+	outputChannelText = iota
+	outputChannelThinking
+)
+
+func NewOutputLimiter(policy StopPolicy) *OutputLimiter {
+	l := &OutputLimiter{policy: policy}
+	if tok := tokenizer.Default(); tok != nil {
+		for i := range l.counters {
+			l.counters[i].tok = tok.NewCounter()
+		}
+	}
+	for _, s := range policy.Sequences {
+		if len(s) > l.maxStopLen {
+			l.maxStopLen = len(s)
+		}
+	}
+	return l
+}
+
+// Done reports whether a stop sequence or the token budget has been reached;
+// callers should stop consuming upstream output once it returns true.
+func (l *OutputLimiter) Done() bool {
+	return l != nil && l.done
+}
+
+func (l *OutputLimiter) FinishReason() string {
+	if l == nil {
+		return OutputFinishNone
+	}
+	return l.finishReason
+}
+
+// MatchedSequence returns the stop sequence that ended generation, if any.
+func (l *OutputLimiter) MatchedSequence() string {
+	if l == nil {
+		return ""
+	}
+	return l.matched
+}
+
+// PushThinking counts reasoning output against the token budgets. Stop
+// sequences only apply to visible text.
+func (l *OutputLimiter) PushThinking(text string) string {
+	if l == nil {
+		return text
+	}
+	if l.done || l.thinkingCut || l.policy.HideThinking {
+		return ""
+	}
+	return l.consumeBudget(text, outputChannelThinking)
+}
+
+// ThinkingTruncated reports whether reasoning output was cut at the thinking
+// budget.
+func (l *OutputLimiter) ThinkingTruncated() bool {
+	return l != nil && l.thinkingCut
+}
+
+// PushText returns the part of text that may be emitted now.
+func (l *OutputLimiter) PushText(text string) string {
+	if l == nil {
+		return text
+	}
+	if l.done {
+		return ""
+	}
+	if len(l.policy.Sequences) == 0 {
+		return l.consumeBudget(text, outputChannelText)
+	}
+	buf := l.pending + text
+	l.pending = ""
+	if idx, seq := l.firstStop(buf); idx >= 0 {
+		out := l.consumeBudget(buf[:idx], outputChannelText)
+		if !l.done {
+			l.done = true
+			l.finishReason = OutputFinishStopSequence
+			l.matched = seq
+		}
+		return out
+	}
+	keep := l.partialStopSuffixLen(buf)
+	l.pending = buf[len(buf)-keep:]
+	return l.consumeBudget(buf[:len(buf)-keep], outputChannelText)
+}
+
+// Flush releases text held back as a possible stop-sequence prefix once the
+// upstream stream has ended.
+func (l *OutputLimiter) Flush() string {
+	if l == nil || l.done || l.pending == "" {
+		return ""
+	}
+	tail := l.pending
+	l.pending = ""
+	return l.consumeBudget(tail, outputChannelText)
+}
+
+func (l *OutputLimiter) firstStop(buf string) (int, string) {
+	best := -1
+	bestSeq := ""
+	for _, seq := range l.policy.Sequences {
+		if idx := strings.Index(buf, seq); idx >= 0 && (best < 0 || idx < best) {
+			best = idx
+			bestSeq = seq
+		}
+	}
+	return best, bestSeq
+}
+
+func (l *OutputLimiter) partialStopSuffixLen(buf string) int {
+	maxKeep := l.maxStopLen - 1
+	if maxKeep > len(buf) {
+		maxKeep = len(buf)
+	}
+	for n := maxKeep; n > 0; n-- {
+		suffix := buf[len(buf)-n:]
+		for _, seq := range l.policy.Sequences {
+			if strings.HasPrefix(seq, suffix) {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// outputCounter counts the tokens emitted on one channel the way CountTokens
+// would: with the DeepSeek tokenizer, or the estimate without a vocabulary.
+type outputCounter struct {
+	tok           *tokenizer.Counter
+	asciiChars    int
+	nonASCIIChars int
+}
+
+// countWith returns the tokens emitted so far followed by more.
+func (c *outputCounter) countWith(more string) int {
+	if c.tok != nil {
+		return c.tok.CountWith(more)
+	}
+	ascii, nonASCII := c.asciiChars, c.nonASCIIChars
+	for _, r := range more {
+		if r < 128 {
+			ascii++
+		} else {
+			nonASCII++
+		}
+	}
+	return estimateTokensFromCounts(ascii, nonASCII)
+}
+
+func (c *outputCounter) add(text string) {
+	if c.tok != nil {
+		c.tok.Add(text)
+		return
+	}
+	for _, r := range text {
+		if r < 128 {
+			c.asciiChars++
+		} else {
+			c.nonASCIIChars++
+		}
+	}
+}
+
+// consumeBudget returns the longest prefix of text that fits the budgets and
+// counts it, marking the limiter cut or done when text does not fit whole.
+func (l *OutputLimiter) consumeBudget(text string, channel int) string {
+	thinkingBudget := 0
+	if channel == outputChannelThinking {
+		thinkingBudget = l.policy.ThinkingBudget
+	}
+	if l.policy.MaxTokens <= 0 && thinkingBudget <= 0 {
+		return text
+	}
+	other := l.counters[1-channel].countWith("")
+	// fits reports which budget, if any, text[:n] would exceed.
+	fits := func(n int) (thinkingOK, maxOK bool) {
+		used := l.counters[channel].countWith(text[:n])
+		return thinkingBudget <= 0 || used <= thinkingBudget, l.policy.MaxTokens <= 0 || used+other <= l.policy.MaxTokens
+	}
+	if thinkingOK, maxOK := fits(len(text)); thinkingOK && maxOK {
+		l.counters[channel].add(text)
+		return text
+	}
+	// Token counts only grow with the text, so binary search the rune
+	// boundaries for the first prefix that no longer fits.
+	ends := make([]int, 0, len(text))
+	for i := range text {
+		if i > 0 {
+			ends = append(ends, i)
+		}
+	}
+	ends = append(ends, len(text))
+	k := sort.Search(len(ends), func(i int) bool {
+		thinkingOK, maxOK := fits(ends[i])
+		return !thinkingOK || !maxOK
+	})
+	keep := 0
+	if k > 0 {
+		keep = ends[k-1]
+	}
+	thinkingOK, _ := fits(ends[k])
+	l.counters[channel].add(text[:keep])
+	if !thinkingOK {
+		l.thinkingCut = true
+		return text[:keep]
+	}
+	l.done = true
+	l.finishReason = OutputFinishMaxTokens
+	l.pending = ""
+	return text[:keep]
+}
@@ -0,0 +1,115 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOutputLimiterStopSequenceSplitAcrossChunks(t *testing.T) {
+	l := NewOutputLimiter(StopPolicy{Sequences: []string{"\nObservation:"}})
+	var out strings.Builder
+	for _, chunk := range []string{"Action: search\nObs", "ervation: done", " more"} {
+		out.WriteString(l.PushText(chunk))
+		if l.Done() {
+			break
+		}
+	}
+	out.WriteString(l.Flush())
+	if out.String() != "Action: search" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+	if l.FinishReason() != OutputFinishStopSequence || l.MatchedSequence() != "\nObservation:" {
+		t.Fatalf("unexpected finish: reason=%q matched=%q", l.FinishReason(), l.MatchedSequence())
+	}
+}
+
+func TestOutputLimiterReleasesHeldPrefixWhenNoMatch(t *testing.T) {
+	l := NewOutputLimiter(StopPolicy{Sequences: []string{"STOP"}})
+	first := l.PushText("hello ST")
+	if first != "hello " {
+		t.Fatalf("expected possible stop prefix held back, got %q", first)
+	}
+	second := l.PushText("ay")
+	if second != "STay" {
+		t.Fatalf("expected held prefix released, got %q", second)
+	}
+	l.PushText("S")
+	if tail := l.Flush(); tail != "S" {
+		t.Fatalf("expected flush to release tail, got %q", tail)
+	}
+	if l.Done() || l.FinishReason() != OutputFinishNone {
+		t.Fatalf("expected limiter not done, reason=%q", l.FinishReason())
+	}
+}
+
+func TestOutputLimiterMaxTokensCountsThinkingAndText(t *testing.T) {
+	l := NewOutputLimiter(StopPolicy{MaxTokens: 3})
+	thinking := l.PushThinking("abcdefgh")
+	text := l.PushText(strings.Repeat("x", 20))
+	if thinking != "abcdefgh" {
+		t.Fatalf("unexpected thinking: %q", thinking)
+	}
+	if EstimateTokens(thinking)+EstimateTokens(text) > 3 {
+		t.Fatalf("budget exceeded: thinking=%q text=%q", thinking, text)
+	}
+	if !l.Done() || l.FinishReason() != OutputFinishMaxTokens {
+		t.Fatalf("expected max_tokens finish, got %q", l.FinishReason())
+	}
+	if more := l.PushText("more"); more != "" {
+		t.Fatalf("expected no output after limit, got %q", more)
+	}
+}
+
+func TestOutputLimiterZeroPolicyPassesThrough(t *testing.T) {
+	l := NewOutputLimiter(StopPolicy{})
+	if got := l.PushText("anything"); got != "anything" {
+		t.Fatalf("unexpected passthrough output: %q", got)
+	}
+	if l.Flush() != "" || l.Done() {
+		t.Fatalf("zero policy should never hold or stop")
+	}
+}
+
+func TestParseStopSequences(t *testing.T) {
+	if got := ParseStopSequences("END"); len(got) != 1 || got[0] != "END" {
+		t.Fatalf("unexpected string stop parse: %#v", got)
+	}
+	got := ParseStopSequences([]any{"a", "", "a", 3, "b"})
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Fatalf("unexpected array stop parse: %#v", got)
+	}
+	if got := ParseStopSequences(nil); got != nil {
+		t.Fatalf("expected nil for missing stop, got %#v", got)
+	}
+}
+
+func TestOutputLimiterThinkingBudgetCutsReasoningOnly(t *testing.T) {
+	l := NewOutputLimiter(StopPolicy{ThinkingBudget: 2})
+	first := l.PushThinking("abcdefgh")
+	second := l.PushThinking("ijklmnop")
+	if first != "abcdefgh" || second != "ijk" {
+		t.Fatalf("unexpected thinking output: %q %q", first, second)
+	}
+	if more := l.PushThinking("q"); more != "" {
+		t.Fatalf("expected no reasoning after the budget, got %q", more)
+	}
+	if !l.ThinkingTruncated() {
+		t.Fatalf("expected thinking to be truncated")
+	}
+	if l.Done() {
+		t.Fatalf("thinking budget must not end the answer")
+	}
+	if text := l.PushText("answer"); text != "answer" {
+		t.Fatalf("expected answer to pass through, got %q", text)
+	}
+}
+
+func TestOutputLimiterHideThinking(t *testing.T) {
+	l := NewOutputLimiter(StopPolicy{HideThinking: true, MaxTokens: 1})
+	if got := l.PushThinking(strings.Repeat("x", 40)); got != "" {
+		t.Fatalf("expected hidden thinking, got %q", got)
+	}
+	if got := l.PushText("abcd"); got != "abcd" {
+		t.Fatalf("hidden thinking must not consume the output budget, got %q", got)
+	}
+}
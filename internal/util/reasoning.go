@@ -0,0 +1,67 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReasoningControl is a per-request override of the model's thinking switch.
+// A nil pointer field leaves the model default in place.
+type ReasoningControl struct {
+	Enabled *bool
+	Budget  int
+	Hide    *bool
+}
+
+// Apply folds the override into the thinking flag and stop policy of a
+// normalized request.
+func (c ReasoningControl) Apply(thinking bool, policy StopPolicy) (bool, StopPolicy) {
+	if c.Enabled != nil {
+		thinking = *c.Enabled
+	}
+	if thinking && c.Budget > 0 {
+		policy.ThinkingBudget = c.Budget
+	}
+	if c.Hide != nil {
+		policy.HideThinking = *c.Hide
+	}
+	return thinking, policy
+}
+
+// reasoningEffortBudgets maps OpenAI reasoning effort levels onto a local
+// reasoning budget. DeepSeek has a single thinking mode, so medium and high
+// leave the reasoning uncapped.
+var reasoningEffortBudgets = map[string]int{
+	"minimal": 1024,
+	"low":     4096,
+	"medium":  0,
+	"high":    0,
+}
+
+// ParseReasoningEffort converts an OpenAI `reasoning_effort` value. "none"
+// turns thinking off; an empty value is not an override.
+func ParseReasoningEffort(v any) (ReasoningControl, error) {
+	if v == nil {
+		return ReasoningControl{}, nil
+	}
+	effort, ok := v.(string)
+	if !ok {
+		return ReasoningControl{}, fmt.Errorf("Invalid reasoning effort: expected a string.")
+	}
+	effort = strings.ToLower(strings.TrimSpace(effort))
+	if effort == "" {
+		return ReasoningControl{}, nil
+	}
+	if effort == "none" {
+		return ReasoningControl{Enabled: BoolPtr(false)}, nil
+	}
+	budget, ok := reasoningEffortBudgets[effort]
+	if !ok {
+		return ReasoningControl{}, fmt.Errorf("Invalid reasoning effort '%s'. Supported values: none, minimal, low, medium, high.", effort)
+	}
+	return ReasoningControl{Enabled: BoolPtr(true), Budget: budget}, nil
+}
+
+func BoolPtr(v bool) *bool {
+	return &v
+}
@@ -0,0 +1,39 @@
+package util
+
+import "testing"
+
+func TestParseReasoningEffort(t *testing.T) {
+	ctrl, err := ParseReasoningEffort("none")
+	if err != nil || ctrl.Enabled == nil || *ctrl.Enabled {
+		t.Fatalf("expected none to disable thinking, got %#v err=%v", ctrl, err)
+	}
+	ctrl, err = ParseReasoningEffort(" Low ")
+	if err != nil || ctrl.Enabled == nil || !*ctrl.Enabled || ctrl.Budget != reasoningEffortBudgets["low"] {
+		t.Fatalf("unexpected low mapping: %#v err=%v", ctrl, err)
+	}
+	ctrl, err = ParseReasoningEffort("high")
+	if err != nil || ctrl.Budget != 0 {
+		t.Fatalf("expected uncapped high effort, got %#v err=%v", ctrl, err)
+	}
+	if ctrl, err = ParseReasoningEffort(nil); err != nil || ctrl.Enabled != nil {
+		t.Fatalf("expected no override for nil, got %#v", ctrl)
+	}
+	if _, err = ParseReasoningEffort("extreme"); err == nil {
+		t.Fatalf("expected error for unknown effort")
+	}
+}
+
+func TestReasoningControlApply(t *testing.T) {
+	thinking, policy := ReasoningControl{Enabled: BoolPtr(true), Budget: 128, Hide: BoolPtr(true)}.Apply(false, StopPolicy{MaxTokens: 64})
+	if !thinking || policy.ThinkingBudget != 128 || !policy.HideThinking || policy.MaxTokens != 64 {
+		t.Fatalf("unexpected apply result: thinking=%v policy=%#v", thinking, policy)
+	}
+	thinking, policy = ReasoningControl{Enabled: BoolPtr(false), Budget: 128}.Apply(true, StopPolicy{})
+	if thinking || policy.ThinkingBudget != 0 {
+		t.Fatalf("expected disabled thinking without budget, got thinking=%v policy=%#v", thinking, policy)
+	}
+	thinking, _ = ReasoningControl{}.Apply(true, StopPolicy{})
+	if !thinking {
+		t.Fatalf("expected model default to be kept")
+	}
+}
@@ -22,9 +22,9 @@ func BuildOpenAIChatCompletion(completionID, model, finalPrompt, finalThinking,
 		messageObj["tool_calls"] = FormatOpenAIToolCalls(detected)
 		messageObj["content"] = nil
 	}
-	promptTokens := EstimateTokens(finalPrompt)
-	reasoningTokens := EstimateTokens(finalThinking)
-	completionTokens := EstimateTokens(finalText)
+	promptTokens := CountTokens(finalPrompt)
+	reasoningTokens := CountTokens(finalThinking)
+	completionTokens := CountTokens(finalText)
 
 	return map[string]any{
 		"id":      completionID,
@@ -85,9 +85,9 @@ func BuildOpenAIResponseObject(responseID, model, finalPrompt, finalThinking, fi
 			"content": content,
 		})
 	}
-	promptTokens := EstimateTokens(finalPrompt)
-	reasoningTokens := EstimateTokens(finalThinking)
-	completionTokens := EstimateTokens(finalText)
+	promptTokens := CountTokens(finalPrompt)
+	reasoningTokens := CountTokens(finalThinking)
+	completionTokens := CountTokens(finalText)
 	return map[string]any{
 		"id":          responseID,
 		"type":        "response",
@@ -139,8 +139,8 @@ func BuildClaudeMessageResponse(messageID, model string, normalizedMessages []an
 		"stop_reason":   stopReason,
 		"stop_sequence": nil,
 		"usage": map[string]any{
-			"input_tokens":  EstimateTokens(fmt.Sprintf("%v", normalizedMessages)),
-			"output_tokens": EstimateTokens(finalThinking) + EstimateTokens(finalText),
+			"input_tokens":  CountTokens(fmt.Sprintf("%v", normalizedMessages)),
+			"output_tokens": CountTokens(finalThinking) + CountTokens(finalText),
 		},
 	}
 }
@@ -9,7 +9,10 @@ type StandardRequest struct {
 	FinalPrompt    string
 	ToolNames      []string
 	ToolChoice     ToolChoicePolicy
+	ToolCalls      ToolCallPolicy
 	Stream         bool
+	IncludeUsage   bool
+	StopPolicy     StopPolicy
 	Thinking       bool
 	Search         bool
 	PassThrough    map[string]any
@@ -0,0 +1,119 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+type InvalidToolCallMode string
+
+const (
+	InvalidToolCallPassThrough InvalidToolCallMode = "pass_through"
+	InvalidToolCallDrop        InvalidToolCallMode = "drop"
+	InvalidToolCallRepair      InvalidToolCallMode = "repair"
+)
+
+func NormalizeInvalidToolCallMode(v string) InvalidToolCallMode {
+	switch InvalidToolCallMode(strings.ToLower(strings.TrimSpace(v))) {
+	case InvalidToolCallDrop:
+		return InvalidToolCallDrop
+	case InvalidToolCallRepair:
+		return InvalidToolCallRepair
+	default:
+		return InvalidToolCallPassThrough
+	}
+}
+
+// ToolCallPolicy governs what happens to parsed tool calls before they are
+// returned to the client: argument validation against the declared schemas
+// and the parallel_tool_calls=false single-call limit.
+type ToolCallPolicy struct {
+	DisableParallel bool
+	Schemas         map[string]map[string]any
+	Invalid         InvalidToolCallMode
+}
+
+type InvalidToolCall struct {
+	Call   ParsedToolCall
+	Errors []string
+}
+
+type ToolCallCheckResult struct {
+	Calls   []ParsedToolCall
+	Invalid []InvalidToolCall
+}
+
+// Active reports whether Check can change a set of calls, in which case
+// streaming callers must buffer calls instead of emitting them incrementally.
+func (p ToolCallPolicy) Active() bool {
+	return p.DisableParallel || len(p.Schemas) > 0
+}
+
+func (p ToolCallPolicy) Check(calls []ParsedToolCall) ToolCallCheckResult {
+	result := ToolCallCheckResult{}
+	if len(calls) == 0 {
+		return result
+	}
+	for _, call := range calls {
+		schema := p.Schemas[call.Name]
+		if len(schema) == 0 {
+			result.Calls = append(result.Calls, call)
+			continue
+		}
+		coerced, errs := ValidateToolCallInput(schema, call.Input)
+		call.Input = coerced
+		if len(errs) == 0 {
+			result.Calls = append(result.Calls, call)
+			continue
+		}
+		result.Invalid = append(result.Invalid, InvalidToolCall{Call: call, Errors: errs})
+		if p.Invalid == InvalidToolCallPassThrough || p.Invalid == "" {
+			result.Calls = append(result.Calls, call)
+		}
+	}
+	if p.DisableParallel && len(result.Calls) > 1 {
+		result.Calls = result.Calls[:1]
+	}
+	return result
+}
+
+// NeedsRepair reports whether the check dropped every call because of
+// invalid arguments and the policy asks for a repair round-trip.
+func (p ToolCallPolicy) NeedsRepair(result ToolCallCheckResult) bool {
+	return p.Invalid == InvalidToolCallRepair && len(result.Calls) == 0 && len(result.Invalid) > 0
+}
+
+// WithoutRepair returns the policy used to judge the repaired output, so a
+// second invalid answer falls back to drop instead of looping.
+func (p ToolCallPolicy) WithoutRepair() ToolCallPolicy {
+	if p.Invalid == InvalidToolCallRepair {
+		p.Invalid = InvalidToolCallDrop
+	}
+	return p
+}
+
+// InvalidToolCallsFallbackText is the assistant text returned in place of
+// tool calls that were dropped for failing validation.
+func InvalidToolCallsFallbackText(invalid []InvalidToolCall) string {
+	if len(invalid) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("The tool call was not executed because its arguments did not match the tool schema:")
+	for _, item := range invalid {
+		b.WriteString(fmt.Sprintf("\n- %s: %s", item.Call.Name, strings.Join(item.Errors, "; ")))
+	}
+	return b.String()
+}
+
+// ToolCallRepairInstruction is the user turn appended to the conversation when
+// asking the model to re-emit invalid tool calls.
+func ToolCallRepairInstruction(invalid []InvalidToolCall) string {
+	var b strings.Builder
+	b.WriteString("Your previous tool call arguments did not match the tool schema:")
+	for _, item := range invalid {
+		b.WriteString(fmt.Sprintf("\n- %s: %s", item.Call.Name, strings.Join(item.Errors, "; ")))
+	}
+	b.WriteString("\nReply again with only the corrected tool_calls JSON, using the exact parameter names and types from the schema.")
+	return b.String()
+}
@@ -0,0 +1,273 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidateToolCallInput checks input against a tool's JSON Schema `parameters`
+// object. Obvious type mismatches (a numeric string for a number, "true" for a
+// boolean, a scalar for a single-item array, ...) are coerced in the returned
+// copy; anything that still does not fit is reported as a path-prefixed error.
+// Only the commonly used keywords are supported: type, properties, required,
+// additionalProperties=false, items and enum.
+func ValidateToolCallInput(schema map[string]any, input map[string]any) (map[string]any, []string) {
+	if len(schema) == 0 {
+		return input, nil
+	}
+	var errs []string
+	out := validateSchemaValue(schema, any(input), "$", &errs)
+	coerced, ok := out.(map[string]any)
+	if !ok {
+		coerced = input
+	}
+	return coerced, errs
+}
+
+func validateSchemaValue(schema map[string]any, value any, path string, errs *[]string) any {
+	types := schemaTypes(schema["type"])
+	if len(types) > 0 && !matchesAnySchemaType(types, value) {
+		coerced, ok := coerceSchemaValue(types, value)
+		if !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonTypeName(value)))
+			return value
+		}
+		value = coerced
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		value = validateSchemaObject(schema, v, path, errs)
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			out := make([]any, len(v))
+			for i, item := range v {
+				out[i] = validateSchemaValue(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+			value = out
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		matched := false
+		for _, candidate := range enum {
+			if schemaValuesEqual(candidate, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			*errs = append(*errs, fmt.Sprintf("%s: value %s is not one of the allowed enum values", path, compactJSON(value)))
+		}
+	}
+	return value
+}
+
+func validateSchemaObject(schema map[string]any, obj map[string]any, path string, errs *[]string) map[string]any {
+	props, _ := schema["properties"].(map[string]any)
+	out := make(map[string]any, len(obj))
+	for k, v := range obj {
+		out[k] = v
+	}
+	for _, name := range schemaRequired(schema["required"]) {
+		if _, ok := out[name]; !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: missing required field %q", path, name))
+		}
+	}
+	keys := make([]string, 0, len(out))
+	for k := range out {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		propSchema, ok := props[k].(map[string]any)
+		if !ok {
+			if additional, ok := schema["additionalProperties"].(bool); ok && !additional && props != nil {
+				*errs = append(*errs, fmt.Sprintf("%s: unexpected field %q", path, k))
+			}
+			continue
+		}
+		out[k] = validateSchemaValue(propSchema, out[k], path+"."+k, errs)
+	}
+	return out
+}
+
+func schemaTypes(raw any) []string {
+	switch x := raw.(type) {
+	case string:
+		if t := strings.TrimSpace(x); t != "" {
+			return []string{t}
+		}
+	case []any:
+		out := make([]string, 0, len(x))
+		for _, item := range x {
+			if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+				out = append(out, strings.TrimSpace(s))
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func schemaRequired(raw any) []string {
+	items, _ := raw.([]any)
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func matchesAnySchemaType(types []string, value any) bool {
+	for _, t := range types {
+		if matchesSchemaType(t, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesSchemaType(t string, value any) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		f, ok := toFloat(value)
+		return ok && f == math.Trunc(f)
+	}
+	return true
+}
+
+func coerceSchemaValue(types []string, value any) (any, bool) {
+	for _, t := range types {
+		switch t {
+		case "number", "integer":
+			var f float64
+			switch v := value.(type) {
+			case string:
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+				if err != nil {
+					continue
+				}
+				f = parsed
+			case float64:
+				f = v
+			default:
+				continue
+			}
+			if t == "integer" && f != math.Trunc(f) {
+				continue
+			}
+			return f, true
+		case "boolean":
+			if s, ok := value.(string); ok {
+				switch strings.ToLower(strings.TrimSpace(s)) {
+				case "true":
+					return true, true
+				case "false":
+					return false, true
+				}
+			}
+		case "string":
+			switch v := value.(type) {
+			case float64:
+				return strconv.FormatFloat(v, 'f', -1, 64), true
+			case bool:
+				return strconv.FormatBool(v), true
+			}
+		case "array":
+			if value != nil {
+				if _, isObj := value.(map[string]any); !isObj {
+					if s, ok := value.(string); ok {
+						var arr []any
+						if err := json.Unmarshal([]byte(strings.TrimSpace(s)), &arr); err == nil {
+							return arr, true
+						}
+					}
+					return []any{value}, true
+				}
+			}
+		case "object":
+			if s, ok := value.(string); ok {
+				var obj map[string]any
+				if err := json.Unmarshal([]byte(strings.TrimSpace(s)), &obj); err == nil && obj != nil {
+					return obj, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	if _, ok := toFloat(value); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func schemaValuesEqual(a, b any) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func compactJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
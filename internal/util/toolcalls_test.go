@@ -1,6 +1,9 @@
 package util
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestParseToolCalls(t *testing.T) {
 	text := `prefix {"tool_calls":[{"name":"search","input":{"q":"golang"}}]} suffix`
@@ -104,3 +107,64 @@ func TestParseStandaloneToolCallsIgnoresFencedCodeBlock(t *testing.T) {
 		t.Fatalf("expected fenced tool_call example to be ignored, got %#v", calls)
 	}
 }
+
+func TestValidateToolCallInputCoercesObviousMismatches(t *testing.T) {
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"count":   map[string]any{"type": "integer"},
+			"verbose": map[string]any{"type": "boolean"},
+			"tags":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			"label":   map[string]any{"type": "string"},
+		},
+	}
+	out, errs := ValidateToolCallInput(schema, map[string]any{"count": "3", "verbose": "true", "tags": "x", "label": 7.0})
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if out["count"] != 3.0 || out["verbose"] != true || out["label"] != "7" {
+		t.Fatalf("unexpected coercion: %#v", out)
+	}
+	if tags, _ := out["tags"].([]any); len(tags) != 1 || tags[0] != "x" {
+		t.Fatalf("expected scalar wrapped into array, got %#v", out["tags"])
+	}
+}
+
+func TestValidateToolCallInputReportsErrors(t *testing.T) {
+	schema := map[string]any{
+		"type":                 "object",
+		"required":             []any{"mode"},
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"mode":  map[string]any{"type": "string", "enum": []any{"fast", "slow"}},
+			"count": map[string]any{"type": "integer"},
+		},
+	}
+	_, errs := ValidateToolCallInput(schema, map[string]any{"count": "1.5", "extra": 1.0})
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", errs)
+	}
+	_, errs = ValidateToolCallInput(schema, map[string]any{"mode": "medium"})
+	if len(errs) != 1 || !strings.Contains(errs[0], "enum") {
+		t.Fatalf("expected enum error, got %v", errs)
+	}
+}
+
+func TestToolCallPolicyCheck(t *testing.T) {
+	schemas := map[string]map[string]any{
+		"search": {"type": "object", "required": []any{"q"}},
+	}
+	calls := []ParsedToolCall{
+		{Name: "search", Input: map[string]any{}},
+		{Name: "search", Input: map[string]any{"q": "a"}},
+		{Name: "search", Input: map[string]any{"q": "b"}},
+	}
+	pass := ToolCallPolicy{Schemas: schemas, Invalid: InvalidToolCallPassThrough}.Check(calls)
+	if len(pass.Calls) != 3 || len(pass.Invalid) != 1 {
+		t.Fatalf("pass_through should keep invalid calls: %#v", pass)
+	}
+	drop := ToolCallPolicy{Schemas: schemas, Invalid: InvalidToolCallDrop, DisableParallel: true}.Check(calls)
+	if len(drop.Calls) != 1 || drop.Calls[0].Input["q"] != "a" {
+		t.Fatalf("expected first valid call only: %#v", drop.Calls)
+	}
+}
@@ -167,3 +167,46 @@ test('parseChunkForContent supports wrapped response.fragments object shape', ()
   assert.equal(parsed.finished, false);
   assert.equal(parsed.parts.map((p) => p.text).join(''), 'AB');
 });
+
+test('parseSearchResults reads search_results and SEARCH fragments', () => {
+  const { parseSearchResults } = handler.__test;
+  const direct = parseSearchResults({
+    p: 'response/search_results',
+    v: [{ url: 'https://go.dev', title: ' Go ', cite_index: 1 }, { url: '' }],
+  });
+  assert.deepEqual(direct, [{ index: 1, url: 'https://go.dev', title: 'Go', snippet: '' }]);
+  const frag = parseSearchResults({
+    p: 'response/fragments',
+    o: 'APPEND',
+    v: [{ type: 'SEARCH', results: [{ url: 'https://b.example', title: 'B' }] }],
+  });
+  assert.equal(frag.length, 1);
+  assert.equal(frag[0].url, 'https://b.example');
+  const parsed = parseChunkForContent({ p: 'response/fragments', o: 'APPEND', v: [{ type: 'SEARCH', content: 'q' }] }, false, 'text');
+  assert.deepEqual(parsed.parts, []);
+});
+
+test('createCitationRewriter maps split markers to url_citation annotations', () => {
+  const { createCitationRewriter } = handler.__test;
+  const c = createCitationRewriter();
+  c.addSources([{ index: 1, url: 'https://go.dev', title: 'Go' }]);
+  const a = c.push('你好[cit');
+  const b = c.push('ation:1][citation:9] done');
+  const tail = c.flush();
+  assert.equal(a.text + b.text + tail, '你好[1] done');
+  assert.deepEqual(b.annotations, [{
+    type: 'url_citation',
+    url_citation: { start_index: 2, end_index: 5, url: 'https://go.dev', title: 'Go' },
+  }]);
+});
+
+test('createThinkingGate cuts reasoning at the budget and hides on request', () => {
+  const { createThinkingGate } = handler.__test;
+  const gate = createThinkingGate({ budget: 2 });
+  assert.equal(gate.push('abcdefgh'), 'abcdefgh');
+  assert.equal(gate.push('ijklmnop'), 'ijk');
+  assert.equal(gate.push('q'), '');
+  const hidden = createThinkingGate({ hidden: true });
+  assert.equal(hidden.push('secret'), '');
+  assert.equal(createThinkingGate().push('free'), 'free');
+});
@@ -34,8 +34,6 @@ export default function AccountManagerContainer({ config, onRefresh, onMessage,
         setShowEditAccount,
         newKey,
         setNewKey,
-        copiedKey,
-        setCopiedKey,
         newAccount,
         setNewAccount,
         editingAccount,
@@ -77,8 +75,6 @@ export default function AccountManagerContainer({ config, onRefresh, onMessage,
                     setShowAddKey(show)
                     if (show) setAddKeyError('')
                 }}
-                copiedKey={copiedKey}
-                setCopiedKey={setCopiedKey}
                 onDeleteKey={deleteKey}
             />
 
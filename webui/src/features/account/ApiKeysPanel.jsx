@@ -1,4 +1,4 @@
-import { Check, ChevronDown, Copy, Plus, Trash2, Clock, AlertTriangle, AlertCircle } from 'lucide-react'
+import { ChevronDown, Plus, Trash2, Clock, AlertTriangle, AlertCircle } from 'lucide-react'
 import clsx from 'clsx'
 import { getKeyExpiryStatusFromMetadata } from '../../utils/apiKeyUtils'
 
@@ -8,13 +8,12 @@ export default function ApiKeysPanel({
     keysExpanded,
     setKeysExpanded,
     setShowAddKey,
-    copiedKey,
-    setCopiedKey,
     onDeleteKey,
     apiKeysMetadata = [],
 }) {
-    const getKeyExpiryStatus = (key) => {
-        const metadata = apiKeysMetadata.find(m => m.key === key)
+    // Keys are stored hashed; config.keys lists their visible prefixes.
+    const getKeyExpiryStatus = (prefix) => {
+        const metadata = apiKeysMetadata.find(m => m.key_prefix === prefix)
         return getKeyExpiryStatusFromMetadata(metadata)
     }
 
@@ -46,13 +45,13 @@ export default function ApiKeysPanel({
             {keysExpanded && (
                 <div className="divide-y divide-border border-t border-border">
                     {config.keys?.length > 0 ? (
-                        config.keys.map((key, i) => {
-                            const expiryStatus = getKeyExpiryStatus(key)
+                        config.keys.map((prefix, i) => {
+                            const expiryStatus = getKeyExpiryStatus(prefix)
                             return (
                                 <div key={i} className="p-4 flex items-center justify-between hover:bg-muted/50 transition-colors group">
                                     <div className="flex items-center gap-3">
                                         <div className="font-mono text-sm bg-muted/50 px-3 py-1 rounded inline-block">
-                                            {key.slice(0, 16)}****
+                                            {prefix}****
                                         </div>
                                         {expiryStatus.status !== 'valid' && (
                                             <span className={clsx(
@@ -72,24 +71,10 @@ export default function ApiKeysPanel({
                                                 }
                                             </span>
                                         )}
-                                        {copiedKey === key && (
-                                            <span className="text-xs text-green-500 animate-pulse">{t('accountManager.copied')}</span>
-                                        )}
                                     </div>
                                     <div className="flex items-center gap-1">
                                         <button
-                                            onClick={() => {
-                                                navigator.clipboard.writeText(key)
-                                                setCopiedKey(key)
-                                                setTimeout(() => setCopiedKey(null), 2000)
-                                            }}
-                                            className="p-2 text-muted-foreground hover:text-primary hover:bg-primary/10 rounded-md transition-colors opacity-0 group-hover:opacity-100"
-                                            title={t('accountManager.copyKeyTitle')}
-                                        >
-                                            {copiedKey === key ? <Check className="w-4 h-4 text-green-500" /> : <Copy className="w-4 h-4" />}
-                                        </button>
-                                        <button
-                                            onClick={() => onDeleteKey(key)}
+                                            onClick={() => onDeleteKey(prefix)}
                                             className="p-2 text-muted-foreground hover:text-destructive hover:bg-destructive/10 rounded-md transition-colors opacity-0 group-hover:opacity-100"
                                             title={t('accountManager.deleteKeyTitle')}
                                         >
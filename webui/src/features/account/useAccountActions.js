@@ -5,7 +5,6 @@ export function useAccountActions({ apiFetch, t, onMessage, onRefresh, config, f
     const [showAddAccount, setShowAddAccount] = useState(false)
     const [showEditAccount, setShowEditAccount] = useState(false)
     const [newKey, setNewKey] = useState('')
-    const [copiedKey, setCopiedKey] = useState(null)
     const [newAccount, setNewAccount] = useState({ email: '', mobile: '', password: '' })
     const [editingAccount, setEditingAccount] = useState({ email: '', mobile: '', password: '', identifier: '' })
     const [loading, setLoading] = useState(false)
@@ -47,8 +46,9 @@ export function useAccountActions({ apiFetch, t, onMessage, onRefresh, config, f
                     throw new Error(t('accountManager.keyPersistValidationFailed'))
                 }
 
+                // Keys are listed by their visible prefix only.
                 const latestKeys = await fetchLatestKeys()
-                if (!latestKeys.includes(normalizedKey)) {
+                if (!latestKeys.some(prefix => normalizedKey.startsWith(prefix))) {
                     throw new Error(t('accountManager.keyPersistValidationFailed'))
                 }
 
@@ -267,8 +267,6 @@ export function useAccountActions({ apiFetch, t, onMessage, onRefresh, config, f
         setShowEditAccount,
         newKey,
         setNewKey,
-        copiedKey,
-        setCopiedKey,
         newAccount,
         setNewAccount,
         editingAccount,
@@ -40,12 +40,12 @@ export default function ApiTesterContainer({ config, onMessage, authFetch }) {
         if (!acc || typeof acc !== 'object') return ''
         return String(acc.identifier || acc.email || acc.mobile || '').trim()
     }
-    const configuredKeys = config.keys || []
-    const trimmedApiKey = apiKey.trim()
-    const defaultKey = configuredKeys[0] || ''
-    const effectiveKey = trimmedApiKey || defaultKey
-    const customKeyActive = trimmedApiKey !== ''
-    const customKeyManaged = customKeyActive && configuredKeys.includes(trimmedApiKey)
+    // Stored keys are hashed: config.keys only holds their visible prefixes,
+    // so the key to test with has to be entered.
+    const keyPrefixes = config.keys || []
+    const effectiveKey = apiKey.trim()
+    const customKeyActive = effectiveKey !== ''
+    const customKeyManaged = customKeyActive && keyPrefixes.some(prefix => effectiveKey.startsWith(prefix))
 
     const models = [
         { id: 'deepseek-chat', name: 'deepseek-chat', icon: 'MessageSquare', desc: t('apiTester.models.chat'), color: 'text-amber-500' },
@@ -87,7 +87,6 @@ export default function ApiTesterContainer({ config, onMessage, authFetch }) {
                 resolveAccountIdentifier={resolveAccountIdentifier}
                 apiKey={apiKey}
                 setApiKey={setApiKey}
-                config={config}
                 customKeyActive={customKeyActive}
                 customKeyManaged={customKeyManaged}
             />
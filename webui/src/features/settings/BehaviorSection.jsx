@@ -32,6 +32,21 @@ export default function BehaviorSection({ t, form, setForm }) {
                         <option value="off">off</option>
                     </select>
                 </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.toolcallInvalidArgs')}</span>
+                    <select
+                        value={form.toolcall.invalid_args}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            toolcall: { ...prev.toolcall, invalid_args: e.target.value },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    >
+                        <option value="pass_through">pass_through</option>
+                        <option value="drop">drop</option>
+                        <option value="repair">repair</option>
+                    </select>
+                </label>
                 <label className="text-sm space-y-2">
                     <span className="text-muted-foreground">{t('settings.responsesTTL')}</span>
                     <input
@@ -13,7 +13,7 @@ const MAX_AUTO_FETCH_FAILURES = 3
 const DEFAULT_FORM = {
     admin: { jwt_expire_hours: 24 },
     runtime: { account_max_inflight: 2, account_max_queue: 10, global_max_inflight: 10 },
-    toolcall: { mode: 'feature_match', early_emit_confidence: 'high' },
+    toolcall: { mode: 'feature_match', early_emit_confidence: 'high', invalid_args: 'pass_through' },
     responses: { store_ttl_seconds: 900 },
     embeddings: { provider: '' },
     claude_mapping_text: '{\n  "fast": "deepseek-chat",\n  "slow": "deepseek-reasoner"\n}',
@@ -48,6 +48,7 @@ function fromServerForm(data) {
         toolcall: {
             mode: data.toolcall?.mode || 'feature_match',
             early_emit_confidence: data.toolcall?.early_emit_confidence || 'high',
+            invalid_args: data.toolcall?.invalid_args || 'pass_through',
         },
         responses: {
             store_ttl_seconds: Number(data.responses?.store_ttl_seconds || 900),
@@ -71,6 +72,7 @@ function toServerPayload(form) {
         toolcall: {
             mode: String(form.toolcall.mode || '').trim(),
             early_emit_confidence: String(form.toolcall.early_emit_confidence || '').trim(),
+            invalid_args: String(form.toolcall.invalid_args || '').trim(),
         },
         responses: { store_ttl_seconds: Number(form.responses.store_ttl_seconds) },
         embeddings: { provider: String(form.embeddings.provider || '').trim() },